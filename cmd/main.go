@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 
 	"github.com/Zolet-hash/smart-rentals/internal/api"
 	"github.com/Zolet-hash/smart-rentals/internal/config"
 	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/repository"
 	"github.com/gin-gonic/gin"
 )
 
@@ -35,6 +37,11 @@ func main() {
 	}
 	defer db.DB.Close()
 
+	// SQL access checks read role grants from tables kept in line with the code
+	if err := repository.NewPostgres(db).SyncPermissions(context.Background()); err != nil {
+		log.Fatalf("CRITICAL: Failed to sync permission grants: %v", err)
+	}
+
 	log.Println("Database connected successfully")
 	// Set Gin mode
 	if cfg.Environment == "production" {
@@ -43,8 +50,13 @@ func main() {
 
 	// Initialize router with middleware
 	r := gin.New()
+	// Only configured proxies may set the client IP used by per-IP rate limits
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
 	r.Use(gin.Recovery())
-	r.Use(gin.Logger())
+	// The event stream authenticates with a query token; keep it out of the log
+	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{SkipPaths: []string{api.StreamPath}}))
 
 	// Health check route for production verification
 	r.GET("/", func(c *gin.Context) {
@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"github.com/Zolet-hash/smart-rentals/internal/api"
 	"github.com/Zolet-hash/smart-rentals/internal/config"
 	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/repository"
 	"github.com/gin-gonic/gin"
 )
 
@@ -21,13 +23,23 @@ func main() {
 	}
 	defer db.DB.Close()
 
+	// SQL access checks read role grants from tables kept in line with the code
+	if err := repository.NewPostgres(db).SyncPermissions(context.Background()); err != nil {
+		log.Fatal("Failed to sync permission grants:", err)
+	}
+
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	r := gin.New()
+	// Only configured proxies may set the client IP used by per-IP rate limits
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
 	r.Use(gin.Recovery())
-	r.Use(gin.Logger())
+	// The event stream authenticates with a query token; keep it out of the log
+	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{SkipPaths: []string{api.StreamPath}}))
 
 	// Register routes
 	api.SetupRoutes(r, db, cfg)
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type AccountingHandler struct {
+	Service *services.AccountingService
+}
+
+func NewAccountingHandler(service *services.AccountingService) *AccountingHandler {
+	return &AccountingHandler{Service: service}
+}
+
+type accountingSettingsInput struct {
+	LandlordID  int               `json:"landlord_id"`
+	Accounts    map[string]string `json:"accounts"`
+	XeroTaxRate *string           `json:"xero_tax_rate"`
+}
+
+// Export downloads the journal entries not exported before as a file for
+// QuickBooks or Xero, and records them as a batch so they are not exported
+// again. Query: format (quickbooks_iif, quickbooks_csv, xero_csv), from, to
+// (YYYY-MM-DD, inclusive; to defaults to today), landlord_id for staff.
+// Without a format, or with preview=true, the entries are returned as JSON
+// and nothing is recorded. 204 when there is nothing new to export.
+func (h *AccountingHandler) Export(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	filter, ok := accountingFilter(c)
+	if !ok {
+		return
+	}
+	format := c.Query("format")
+	preview, err := strconv.ParseBool(c.DefaultQuery("preview", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "preview must be true or false"})
+		return
+	}
+
+	batch, err := h.Service.Export(c.Request.Context(), userID, filter, format, format != "" && !preview)
+	if err != nil {
+		accountingError(c, "exportAccounting", err)
+		return
+	}
+	if format == "" || preview {
+		c.JSON(http.StatusOK, gin.H{"data": batch})
+		return
+	}
+	if batch.EntryCount == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	owner := int(batch.LandlordID)
+	middleware.AuditEntity(c, batch.ID, &owner)
+	summary := batch
+	summary.Entries = nil
+	middleware.AuditAfter(c, summary)
+	h.writeJournal(c, "exportAccounting", userID, batch, format)
+}
+
+// ListBatches returns recorded exports without their entries, newest first.
+// Query: limit, offset.
+func (h *AccountingHandler) ListBatches(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	limit, offset, err := pageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	batches, err := h.Service.ListBatches(c.Request.Context(), userID, limit, offset)
+	if err != nil {
+		accountingError(c, "listAccountingBatches", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": batches})
+}
+
+// GetBatch returns a recorded export with its entries. Query: format to
+// download it again as a file, in its own format or another.
+func (h *AccountingHandler) GetBatch(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := int64Param(c, "id", "Invalid export batch ID")
+	if !ok {
+		return
+	}
+
+	batch, err := h.Service.GetBatch(c.Request.Context(), userID, id)
+	if err != nil {
+		accountingError(c, "getAccountingBatch", err)
+		return
+	}
+	format := c.Query("format")
+	if format == "" {
+		c.JSON(http.StatusOK, gin.H{"data": batch})
+		return
+	}
+	h.writeJournal(c, "getAccountingBatch", userID, batch, format)
+}
+
+// GetSettings returns the chart of accounts entries post to, defaults
+// included. Query: landlord_id for staff.
+func (h *AccountingHandler) GetSettings(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	landlordID, err := strconv.Atoi(c.DefaultQuery("landlord_id", "0"))
+	if err != nil || landlordID < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid landlord ID"})
+		return
+	}
+
+	set, err := h.Service.Settings(c.Request.Context(), userID, landlordID)
+	if err != nil {
+		accountingError(c, "getAccountingSettings", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": set})
+}
+
+// UpdateSettings maps accounts to the landlord's own names or codes. Body:
+// {"accounts": {"rental_income": "4000"}, "xero_tax_rate": "Tax Exempt"}.
+// An empty name restores the default; keys left out are unchanged.
+func (h *AccountingHandler) UpdateSettings(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var input accountingSettingsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var before models.AccountingSettings
+	if middleware.Auditing(c) {
+		before, _ = h.Service.Settings(ctx, userID, input.LandlordID)
+	}
+	set, err := h.Service.SaveSettings(ctx, userID, input.LandlordID, input.Accounts, input.XeroTaxRate)
+	if err != nil {
+		accountingError(c, "updateAccountingSettings", err)
+		return
+	}
+	owner := int(set.LandlordID)
+	middleware.AuditEntity(c, owner, &owner)
+	middleware.AuditBefore(c, before)
+	middleware.AuditAfter(c, set)
+	c.JSON(http.StatusOK, gin.H{"message": "Accounts saved", "data": set})
+}
+
+// writeJournal streams a batch's entries as an import file
+func (h *AccountingHandler) writeJournal(c *gin.Context, fn string, userID int, batch models.AccountingBatch, format string) {
+	if !services.ValidAccountingFormat(format) {
+		accountingError(c, fn, services.ErrUnknownAccountingFormat)
+		return
+	}
+	set, err := h.Service.Settings(c.Request.Context(), userID, int(batch.LandlordID))
+	if err != nil {
+		accountingError(c, fn, err)
+		return
+	}
+
+	ext, contentType := services.AccountingFile(format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=journal_%d_%s.%s", batch.ID, batch.To, ext))
+	c.Status(http.StatusOK)
+	if err := services.WriteJournal(c.Writer, format, batch.Entries, set.XeroTaxRate); err != nil {
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] %s: aborted after streaming started: %v", reqID, fn, err)
+		c.Abort()
+	}
+}
+
+// accountingFilter reads the export range and landlord from the query
+func accountingFilter(c *gin.Context) (services.AccountingFilter, bool) {
+	var filter services.AccountingFilter
+	var err error
+	if v := c.Query("landlord_id"); v != "" {
+		if filter.LandlordID, err = strconv.Atoi(v); err != nil || filter.LandlordID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid landlord ID"})
+			return filter, false
+		}
+	}
+	if v := c.Query("from"); v != "" {
+		if filter.From, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return filter, false
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if filter.To, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return filter, false
+		}
+	}
+	return filter, true
+}
+
+// accountingError maps service errors to responses
+func accountingError(c *gin.Context, fn string, err error) {
+	switch {
+	case errors.Is(err, services.ErrLandlordNotFound), errors.Is(err, services.ErrBatchNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUnknownAccountingFormat), errors.Is(err, services.ErrUnknownAccount),
+		errors.Is(err, services.ErrInvalidAccountName), errors.Is(err, services.ErrInvalidDateRange):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] %s: %v", reqID, fn, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process accounting export", "trace_id": reqID})
+	}
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+	"github.com/Zolet-hash/smart-rentals/internal/repository"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+type AuditHandler struct {
+	Service *services.AuditService
+}
+
+func NewAuditHandler(service *services.AuditService) *AuditHandler {
+	return &AuditHandler{Service: service}
+}
+
+// ListAll returns the whole audit log. Admin only.
+func (h *AuditHandler) ListAll(c *gin.Context) {
+	filter, ok := auditFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	if v := c.Query("landlord_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid landlord_id"})
+			return
+		}
+		filter.LandlordID = &id
+	}
+	h.respond(c, filter)
+}
+
+// ListForLandlord returns events affecting the caller's data or made by the caller
+func (h *AuditHandler) ListForLandlord(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	filter, ok := auditFilterFromQuery(c)
+	if !ok {
+		return
+	}
+	filter.LandlordID = &userID
+	h.respond(c, filter)
+}
+
+// Verify walks the hash chain and reports whether it is intact. Admin only.
+func (h *AuditHandler) Verify(c *gin.Context) {
+	result, err := h.Service.Verify(c.Request.Context())
+	if err != nil {
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] verifyAudit: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify audit log", "trace_id": reqID})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// respond writes events as JSON, or as a CSV download when format=csv
+func (h *AuditHandler) respond(c *gin.Context, filter services.AuditFilter) {
+	if c.Query("format") == "csv" {
+		h.exportCSV(c, filter)
+		return
+	}
+
+	events, err := h.Service.List(c.Request.Context(), filter)
+	if err != nil {
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] listAudit: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log", "trace_id": reqID})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": events, "limit": filter.Limit, "offset": filter.Offset})
+}
+
+// auditCSVFlushEvery bounds how many exported rows are buffered
+const auditCSVFlushEvery = 100
+
+// exportCSV streams every matching event as CSV straight from the query.
+// Text fields are user-controlled, so they are written formula-safe.
+func (h *AuditHandler) exportCSV(c *gin.Context, filter services.AuditFilter) {
+	filter.Limit, filter.Offset = 0, 0 // exports are not paginated
+
+	filename := fmt.Sprintf("audit-%s.csv", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", documents.ContentTypeCSV)
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"id", "occurred_at", "actor_id", "actor_role", "request_id", "action", "entity_type",
+		"entity_id", "landlord_id", "before", "after", "ip_address", "hash"})
+	rows := 0
+	err := h.Service.Each(c.Request.Context(), filter, func(ev services.AuditEvent) error {
+		w.Write([]string{
+			strconv.FormatInt(ev.ID, 10),
+			ev.OccurredAt.Format(time.RFC3339),
+			optionalInt(ev.ActorID),
+			documents.CSVText(ev.ActorRole),
+			documents.CSVText(ev.RequestID),
+			documents.CSVText(ev.Action),
+			documents.CSVText(ev.EntityType),
+			documents.CSVText(ev.EntityID),
+			optionalInt(ev.LandlordID),
+			documents.CSVText(string(ev.Before)),
+			documents.CSVText(string(ev.After)),
+			documents.CSVText(ev.IPAddress),
+			ev.Hash,
+		})
+		if rows++; rows%auditCSVFlushEvery == 0 {
+			w.Flush()
+		}
+		return w.Error()
+	})
+	w.Flush()
+	if err != nil {
+		// Headers are already sent; the truncated file is all we can give
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] exportAudit: %v", reqID, err)
+	}
+}
+
+// auditFilterFromQuery reads actor_id, action, entity_type, entity_id,
+// from/to (YYYY-MM-DD, to inclusive), limit and offset
+func auditFilterFromQuery(c *gin.Context) (services.AuditFilter, bool) {
+	filter := services.AuditFilter{
+		Action:     c.Query("action"),
+		EntityType: c.Query("entity_type"),
+		EntityID:   c.Query("entity_id"),
+		Limit:      defaultAuditLimit,
+	}
+
+	if v := c.Query("actor_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actor_id"})
+			return filter, false
+		}
+		filter.ActorID = &id
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return filter, false
+		}
+		filter.From = t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return filter, false
+		}
+		filter.To = t.AddDate(0, 0, 1)
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return filter, false
+		}
+		if n > maxAuditLimit {
+			n = maxAuditLimit
+		}
+		filter.Limit = n
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+			return filter, false
+		}
+		filter.Offset = n
+	}
+	return filter, true
+}
+
+func optionalInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+// auditSnapshot loads a row as JSON for audit before/after data. query must
+// select a single row_to_json value; never include secrets such as password hashes.
+func auditSnapshot(db *database.Database, query string, args ...interface{}) []byte {
+	var snapshot []byte
+	if err := db.QueryRow(query, args...).Scan(&snapshot); err != nil {
+		return nil
+	}
+	return snapshot
+}
+
+// Audit snapshot queries ($1 entity id). Rows carrying a landlord_id tag the
+// event for that landlord's audit view.
+const (
+	userSnapshotQuery      = `SELECT row_to_json(x) FROM (SELECT id, email, full_name, phone, role, totp_enabled, locked_until FROM users WHERE id = $1) x`
+	propertySnapshotQuery  = `SELECT row_to_json(p) FROM properties p WHERE id = $1`
+	unitSnapshotQuery      = `SELECT row_to_json(x) FROM (SELECT u.*, p.landlord_id FROM units u JOIN properties p ON u.property_id = p.id WHERE u.id = $1) x`
+	tenantSnapshotQuery    = `SELECT row_to_json(t) FROM tenants t WHERE id = $1`
+	paymentSnapshotQuery   = `SELECT row_to_json(p) FROM payments p WHERE id = $1`
+	mfaPolicySnapshotQuery = `SELECT row_to_json(m) FROM mfa_policies m WHERE role = $1`
+)
+
+// auditBefore tags the audit entry with the entity and its state before the change
+func auditBefore(c *gin.Context, db *database.Database, query string, id interface{}) {
+	if !middleware.Auditing(c) {
+		return
+	}
+	snapshot := auditSnapshot(db, query, id)
+	middleware.AuditEntity(c, id, snapshotLandlordID(snapshot))
+	middleware.AuditBefore(c, snapshot)
+}
+
+// auditAfter tags the audit entry with the entity and its state after the change
+func auditAfter(c *gin.Context, db *database.Database, query string, id interface{}) {
+	if !middleware.Auditing(c) {
+		return
+	}
+	snapshot := auditSnapshot(db, query, id)
+	middleware.AuditEntity(c, id, snapshotLandlordID(snapshot))
+	middleware.AuditAfter(c, snapshot)
+}
+
+// auditBeforeRecord is auditBefore for a record loaded through a repository
+func auditBeforeRecord(c *gin.Context, s repository.Snapshotter, id int) {
+	if !middleware.Auditing(c) {
+		return
+	}
+	snapshot, _ := s.Snapshot(c.Request.Context(), id)
+	middleware.AuditEntity(c, id, snapshotLandlordID(snapshot))
+	middleware.AuditBefore(c, snapshot)
+}
+
+// auditAfterRecord is auditAfter for a record loaded through a repository
+func auditAfterRecord(c *gin.Context, s repository.Snapshotter, id int) {
+	if !middleware.Auditing(c) {
+		return
+	}
+	snapshot, _ := s.Snapshot(c.Request.Context(), id)
+	middleware.AuditEntity(c, id, snapshotLandlordID(snapshot))
+	middleware.AuditAfter(c, snapshot)
+}
+
+func snapshotLandlordID(snapshot []byte) *int {
+	var row struct {
+		LandlordID *int `json:"landlord_id"`
+	}
+	if json.Unmarshal(snapshot, &row) != nil {
+		return nil
+	}
+	return row.LandlordID
+}
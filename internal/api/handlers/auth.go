@@ -2,33 +2,75 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/config"
 	"github.com/Zolet-hash/smart-rentals/internal/database"
 	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/notify"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
 	"github.com/Zolet-hash/smart-rentals/internal/pkg/utils"
+	"github.com/Zolet-hash/smart-rentals/internal/repository"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type AuthHandler struct {
 	db        *database.Database
+	users     repository.UserRepo
 	jwtSecret []byte
 	// Add token expiration configuration
 	tokenExpiration time.Duration
+
+	// Password reset delivery
+	notifier         notify.Notifier
+	passwordResetTTL time.Duration
+	frontendURL      string
+
+	// Account lockout
+	maxLoginAttempts   int
+	lockoutDuration    time.Duration
+	lockoutMaxDuration time.Duration
 }
 
 // NewAuthHandler creates a new authentication handler
-func NewAuthHandler(db *database.Database, jwtSecret []byte) *AuthHandler {
+func NewAuthHandler(db *database.Database, cfg *config.Config, notifier notify.Notifier) *AuthHandler {
 	return &AuthHandler{
-		db:              db,
-		jwtSecret:       jwtSecret,
-		tokenExpiration: 24 * time.Hour, // Default 24 hour expiration
+		db:               db,
+		users:            repository.NewPostgres(db).Users(),
+		jwtSecret:        []byte(cfg.JWT.Secret),
+		tokenExpiration:  24 * time.Hour, // Default 24 hour expiration
+		notifier:         notifier,
+		passwordResetTTL: cfg.Security.PasswordResetTTL,
+		frontendURL:      cfg.FrontendURL,
+
+		maxLoginAttempts:   cfg.Security.LoginMaxAttempts,
+		lockoutDuration:    cfg.Security.LockoutDuration,
+		lockoutMaxDuration: cfg.Security.LockoutMaxDuration,
+	}
+}
+
+// issueToken signs a session JWT for the user. The token version ties the
+// token to users.token_version so it can be revoked by bumping the version;
+// mfa records whether the session passed a second factor.
+func (h *AuthHandler) issueToken(userID int, email string, tokenVersion int, mfa bool) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"email":   email,
+		"ver":     tokenVersion,
+		"mfa":     mfa,
+		"iat":     now.Unix(),
+		"exp":     now.Add(h.tokenExpiration).Unix(),
 	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(h.jwtSecret)
 }
 
 // Register handles user registration
@@ -53,6 +95,10 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !permissions.IsValidRole(user.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
 
 	// Check if user already exists
 	var exists bool
@@ -110,6 +156,8 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	auditAfter(c, h.db, userSnapshotQuery, id)
+
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "User registered successfully",
 		"user_id": id,
@@ -126,14 +174,19 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// Get user from database
 	var user models.User
+	var mfaRequired bool
+	var lockedUntil sql.NullTime
 	err := h.db.DB.QueryRow(`
-        SELECT id, email, password_hash, role 
-        FROM users 
-        WHERE email = $1`,
+        SELECT u.id, u.email, u.password_hash, u.role, u.token_version, u.totp_enabled,
+               COALESCE(p.required, FALSE), u.locked_until
+        FROM users u
+        LEFT JOIN mfa_policies p ON p.role = u.role
+        WHERE u.email = $1`,
 		login.Email,
-	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role)
+	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.TokenVersion, &user.TOTPEnabled, &mfaRequired, &lockedUntil)
 
 	if err == sql.ErrNoRows {
+		h.recordLoginAttempt(c, nil, login.Email, false, "unknown_email")
 		// Don't specify whether email or password was wrong
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
@@ -148,24 +201,51 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// Refuse locked accounts before checking the password
+	if lockedUntil.Valid && lockedUntil.Time.After(time.Now()) {
+		h.recordLoginAttempt(c, &user.ID, login.Email, false, "locked")
+		respondLocked(c, lockedUntil.Time)
+		return
+	}
+
 	// Verify password
 	if !utils.CheckPasswordHash(login.Password, user.PasswordHash) {
+		h.recordLoginAttempt(c, &user.ID, login.Email, false, "bad_password")
+		locked, err := h.recordLoginFailure(user.ID)
+		if err != nil {
+			reqID, _ := c.Get("request_id")
+			log.Printf("[%v] login: failed to record failure: %v", reqID, err)
+		}
+		if locked != nil {
+			respondLocked(c, *locked)
+			return
+		}
 		// Use same message as above for security
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Generate JWT with claims
-	now := time.Now()
-	claims := jwt.MapClaims{
-		"user_id": user.ID,
-		"email":   user.Email,
-		"iat":     now.Unix(),
-		"exp":     now.Add(h.tokenExpiration).Unix(),
+	// Second step required: hand out a short-lived challenge instead of a session.
+	// Failure counters are only cleared once the second factor succeeds.
+	if user.TOTPEnabled {
+		challenge, err := h.issueMFAChallenge(user.ID, user.TokenVersion)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required": true,
+			"mfa_token":    challenge,
+			"expires_in":   mfaChallengeExpiration.Seconds(),
+		})
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(h.jwtSecret)
+	h.resetLoginFailures(user.ID)
+	h.recordLoginAttempt(c, &user.ID, login.Email, true, "")
+
+	// Generate JWT with claims
+	tokenString, err := h.issueToken(user.ID, user.Email, user.TokenVersion, false)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
 		return
@@ -173,10 +253,11 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// Return token with expiration
 	c.JSON(http.StatusOK, gin.H{
-		"token":      tokenString,
-		"expires_in": h.tokenExpiration.Seconds(),
-		"token_type": "Bearer",
-		"role":       user.Role,
+		"token":                   tokenString,
+		"expires_in":              h.tokenExpiration.Seconds(),
+		"token_type":              "Bearer",
+		"role":                    user.Role,
+		"mfa_enrollment_required": mfaRequired, // role policy demands 2FA but user has not enrolled
 	})
 
 }
@@ -184,22 +265,23 @@ func (h *AuthHandler) Login(c *gin.Context) {
 // RefreshToken generates a new token for valid users
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("user_id")
-	if !exists {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Generate new token
-	now := time.Now()
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"iat":     now.Unix(),
-		"exp":     now.Add(h.tokenExpiration).Unix(),
+	// Reload email and token version so the new token stays revocable
+	var email string
+	var tokenVersion int
+	err = h.db.DB.QueryRow("SELECT email, token_version FROM users WHERE id = $1", userID).Scan(&email, &tokenVersion)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(h.jwtSecret)
+	// Generate new token, carrying over whether this session passed 2FA
+	tokenString, err := h.issueToken(userID, email, tokenVersion, c.GetBool("mfa"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token refresh failed"})
 		return
@@ -222,51 +304,39 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	})
 }
 
-// ListUsers returns all users in the system
+// ListUsers lists the users in the system a page at a time. Query: role, q
+// (email, name or phone), sort (created_at, email, full_name; prefix - for
+// descending), limit, cursor, include_total.
 func (h *AuthHandler) ListUsers(c *gin.Context) {
-	query := `
-		SELECT id, email, full_name, phone, role, created_at 
-		FROM users 
-		ORDER BY created_at DESC
-	`
-	rows, err := h.db.DB.Query(query)
-	if err != nil {
-		reqID, _ := c.Get("request_id")
-		log.Printf("[%v] listUsers: db error: %v", reqID, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+	page, filter, ok := listParams(c, repository.UserList)
+	if !ok {
 		return
 	}
-	defer rows.Close()
 
-	users := []gin.H{}
-	for rows.Next() {
-		var u struct {
-			ID        int
-			Email     string
-			FullName  string
-			Phone     string
-			Role      string
-			CreatedAt time.Time
-		}
-		if err := rows.Scan(&u.ID, &u.Email, &u.FullName, &u.Phone, &u.Role, &u.CreatedAt); err != nil {
-			continue
-		}
-		users = append(users, gin.H{
+	users, err := h.users.List(c.Request.Context(), c.Query("role"), page, filter)
+	if err != nil {
+		listError(c, "listUsers", "Failed to fetch users", err)
+		return
+	}
+	writePage(c, mapPage(users, func(u models.User) gin.H {
+		return gin.H{
 			"id":         u.ID,
 			"email":      u.Email,
 			"full_name":  u.FullName,
 			"phone":      u.Phone,
 			"role":       u.Role,
-			"created_at": u.CreatedAt,
-		})
-	}
-
-	c.JSON(http.StatusOK, gin.H{"data": users})
+			"created_at": u.CreatedAT,
+			"locked":     u.LockedUntil != nil && u.LockedUntil.After(time.Now()),
+		}
+	}))
 }
 
 // UpdateUser allows an admin to modify user details
 func (h *AuthHandler) UpdateUser(c *gin.Context) {
-	userID := c.Param("id")
+	userID, ok := intParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
 	var input struct {
 		Email    string `json:"email"`
 		FullName string `json:"full_name"`
@@ -278,25 +348,40 @@ func (h *AuthHandler) UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
+	if !permissions.IsValidRole(input.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	auditBeforeRecord(c, h.users, userID)
 
-	query := `
-		UPDATE users 
-		SET email = $1, full_name = $2, phone = $3, role = $4, updated_at = NOW() 
-		WHERE id = $5
-	`
-	_, err := h.db.DB.Exec(query, input.Email, input.FullName, input.Phone, input.Role, userID)
+	err := h.users.Update(c.Request.Context(), models.User{
+		ID:       userID,
+		Email:    input.Email,
+		FullName: input.FullName,
+		Phone:    input.Phone,
+		Role:     input.Role,
+	})
+	if errors.Is(err, repository.ErrConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email is already in use"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		storeError(c, "updateUser", "User not found", "Failed to update user", err)
 		return
 	}
 
+	auditAfterRecord(c, h.users, userID)
+
 	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
 }
 
 // DeleteUser removes a user from the system
 func (h *AuthHandler) DeleteUser(c *gin.Context) {
-	userIDStr := c.Param("id")
-	userID, _ := strconv.Atoi(userIDStr)
+	userID, ok := intParam(c, "id", "Invalid user ID")
+	if !ok {
+		return
+	}
 
 	// Pre-check: Don't allow an admin to delete themselves via this endpoint (prevent lockout)
 	currentUserID, err := middleware.GetUserID(c)
@@ -305,9 +390,15 @@ func (h *AuthHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	_, err = h.db.DB.Exec("DELETE FROM users WHERE id = $1", userID)
+	auditBeforeRecord(c, h.users, userID)
+
+	err = h.users.Delete(c.Request.Context(), userID)
+	if errors.Is(err, repository.ErrInUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is the landlord of tenants; remove them first"})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		storeError(c, "deleteUser", "User not found", "Failed to delete user", err)
 		return
 	}
 
@@ -364,8 +455,8 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	// Update password in database
-	query := `UPDATE users SET password_hash = $1, updated_at = NOW() WHERE id = $2`
+	// Update password in database and revoke the user's existing sessions
+	query := `UPDATE users SET password_hash = $1, token_version = token_version + 1, updated_at = NOW() WHERE id = $2`
 	result, err := h.db.DB.Exec(query, hashedPassword, userID)
 	if err != nil {
 		reqID, _ := c.Get("request_id")
@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ConversationHandler struct {
+	Service *services.MessagingService
+}
+
+func NewConversationHandler(service *services.MessagingService) *ConversationHandler {
+	return &ConversationHandler{Service: service}
+}
+
+// CreateConversationInput starts a thread. TenantID is required for staff
+// and ignored for tenants. Also accepted as multipart/form-data with
+// "attachments" files.
+type CreateConversationInput struct {
+	TenantID int    `json:"tenant_id" form:"tenant_id"`
+	Subject  string `json:"subject" form:"subject" binding:"required,max=255"`
+	Content  string `json:"content" form:"content" binding:"required"`
+}
+
+// SendMessageInput is a reply; also accepted as multipart/form-data
+type SendMessageInput struct {
+	Content string `json:"content" form:"content" binding:"required"`
+}
+
+type BroadcastInput struct {
+	PropertyID int    `json:"property_id" binding:"required"`
+	Subject    string `json:"subject" binding:"required,max=255"`
+	Content    string `json:"content" binding:"required"`
+}
+
+// List returns the caller's conversations with unread counts.
+// Query: tenant_id, property_id, limit (default 50, max 200), offset.
+func (h *ConversationHandler) List(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+
+	var filter services.ConversationFilter
+	var err error
+	if filter.Limit, filter.Offset, err = pageParams(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if v := c.Query("tenant_id"); v != "" {
+		if filter.TenantID, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant_id"})
+			return
+		}
+	}
+	if v := c.Query("property_id"); v != "" {
+		if filter.PropertyID, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property_id"})
+			return
+		}
+	}
+
+	list, err := h.Service.ListConversations(c.Request.Context(), p, filter)
+	if err != nil {
+		conversationError(c, "listConversations", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list, "limit": filter.Limit, "offset": filter.Offset})
+}
+
+// Create starts a conversation with a first message
+func (h *ConversationHandler) Create(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+
+	var input CreateConversationInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !p.Tenant && input.TenantID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tenant_id is required"})
+		return
+	}
+	attachments, ok := formAttachments(c)
+	if !ok {
+		return
+	}
+
+	conv, err := h.Service.CreateConversation(c.Request.Context(), p, input.TenantID, input.Subject, input.Content, attachments)
+	if err != nil {
+		conversationError(c, "createConversation", err)
+		return
+	}
+	landlordID := int(conv.LandlordID)
+	middleware.AuditEntity(c, conv.ID, &landlordID)
+	middleware.AuditAfter(c, conv)
+	c.JSON(http.StatusCreated, gin.H{"message": "Conversation started", "data": conv})
+}
+
+// Get returns one conversation
+func (h *ConversationHandler) Get(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+	id, ok := conversationIDParam(c)
+	if !ok {
+		return
+	}
+
+	conv, err := h.Service.GetConversation(c.Request.Context(), p, id)
+	if err != nil {
+		conversationError(c, "getConversation", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": conv})
+}
+
+// Messages returns a conversation's messages, newest first.
+// Query: limit (default 50, max 200), offset.
+func (h *ConversationHandler) Messages(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+	id, ok := conversationIDParam(c)
+	if !ok {
+		return
+	}
+	limit, offset, err := pageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	list, err := h.Service.ListMessages(c.Request.Context(), p, id, limit, offset)
+	if err != nil {
+		conversationError(c, "listMessages", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list, "limit": limit, "offset": offset})
+}
+
+// Send replies in a conversation
+func (h *ConversationHandler) Send(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+	id, ok := conversationIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input SendMessageInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	attachments, ok := formAttachments(c)
+	if !ok {
+		return
+	}
+
+	msg, err := h.Service.SendMessage(c.Request.Context(), p, id, input.Content, attachments)
+	if err != nil {
+		conversationError(c, "sendMessage", err)
+		return
+	}
+	if middleware.Auditing(c) {
+		var landlordID *int
+		if conv, err := h.Service.GetConversation(c.Request.Context(), p, id); err == nil {
+			v := int(conv.LandlordID)
+			landlordID = &v
+		}
+		middleware.AuditEntity(c, msg.ID, landlordID)
+		middleware.AuditAfter(c, msg)
+	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Message sent", "data": msg})
+}
+
+// MarkRead records a read receipt for the other side's messages
+func (h *ConversationHandler) MarkRead(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+	id, ok := conversationIDParam(c)
+	if !ok {
+		return
+	}
+
+	n, err := h.Service.MarkRead(c.Request.Context(), p, id)
+	if err != nil {
+		conversationError(c, "markConversationRead", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation marked as read", "data": gin.H{"updated": n}})
+}
+
+// Attachment downloads a file sent in a conversation
+func (h *ConversationHandler) Attachment(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+	id, ok := conversationIDParam(c)
+	if !ok {
+		return
+	}
+	attachmentID, err := strconv.ParseInt(c.Param("attachmentId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	a, err := h.Service.GetAttachment(c.Request.Context(), p, id, attachmentID)
+	if err != nil {
+		conversationError(c, "getAttachment", err)
+		return
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	c.Data(http.StatusOK, a.ContentType, a.Data)
+}
+
+// Broadcast messages every tenant of a property
+func (h *ConversationHandler) Broadcast(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+
+	var input BroadcastInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	b, err := h.Service.Broadcast(c.Request.Context(), p, input.PropertyID, input.Subject, input.Content)
+	if err != nil {
+		conversationError(c, "broadcast", err)
+		return
+	}
+	landlordID := int(b.LandlordID)
+	middleware.AuditEntity(c, b.ID, &landlordID)
+	middleware.AuditAfter(c, b)
+	c.JSON(http.StatusCreated, gin.H{"message": "Broadcast sent", "data": b})
+}
+
+func participant(c *gin.Context) (services.Participant, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return services.Participant{}, false
+	}
+	return services.NewParticipant(userID, middleware.GetRole(c)), true
+}
+
+func conversationIDParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid conversation ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// pageParams reads limit (default 50, capped at 200) and offset
+func pageParams(c *gin.Context) (int, int, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 {
+		return 0, 0, errors.New("invalid limit")
+	}
+	if limit > 200 {
+		limit = 200
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		return 0, 0, errors.New("invalid offset")
+	}
+	return limit, offset, nil
+}
+
+// formAttachments reads "attachments" files from a multipart request;
+// JSON requests have none
+func formAttachments(c *gin.Context) ([]models.MessageAttachment, bool) {
+	files, ok := formFiles(c, "attachments", services.MaxMessageAttachments, services.MaxAttachmentSize,
+		services.ErrTooManyAttachments, services.ErrAttachmentTooLarge)
+	if !ok {
+		return nil, false
+	}
+	var list []models.MessageAttachment
+	for _, f := range files {
+		list = append(list, models.MessageAttachment{Filename: f.Filename, ContentType: f.ContentType, Data: f.Data})
+	}
+	return list, true
+}
+
+// uploadedFile is a file read from a multipart form
+type uploadedFile struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// formFiles reads up to maxFiles files of at most maxSize bytes from a
+// multipart field, responding with tooMany or tooLarge when exceeded.
+// Requests that are not multipart have none.
+func formFiles(c *gin.Context, field string, maxFiles int, maxSize int64, tooMany, tooLarge error) ([]uploadedFile, bool) {
+	if c.ContentType() != "multipart/form-data" {
+		return nil, true
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart form"})
+		return nil, false
+	}
+
+	files := form.File[field]
+	if len(files) > maxFiles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": tooMany.Error()})
+		return nil, false
+	}
+	var list []uploadedFile
+	for _, fh := range files {
+		if fh.Size > maxSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLarge.Error()})
+			return nil, false
+		}
+		f, err := fh.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read " + field})
+			return nil, false
+		}
+		data, err := io.ReadAll(io.LimitReader(f, maxSize+1))
+		f.Close()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read " + field})
+			return nil, false
+		}
+		contentType := fh.Header.Get("Content-Type")
+		if contentType == "" || contentType == "application/octet-stream" {
+			contentType = http.DetectContentType(data)
+		}
+		list = append(list, uploadedFile{Filename: fh.Filename, ContentType: contentType, Data: data})
+	}
+	return list, true
+}
+
+// conversationError maps service errors to responses
+func conversationError(c *gin.Context, fn string, err error) {
+	switch {
+	case errors.Is(err, services.ErrConversationNotFound), errors.Is(err, services.ErrAttachmentNotFound),
+		errors.Is(err, services.ErrRecipientNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTenantNotLinked), errors.Is(err, services.ErrStaffOnly):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAttachmentTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmptyMessage), errors.Is(err, services.ErrMessageTooLong),
+		errors.Is(err, services.ErrTooManyAttachments), errors.Is(err, services.ErrNoRecipients):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] %s: %v", reqID, fn, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process message", "trace_id": reqID})
+	}
+}
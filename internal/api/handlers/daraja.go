@@ -3,11 +3,11 @@ package handlers
 import (
 	"context"
 	"database/sql"
-	"regexp"
 	"strings"
 	"time"
 
 	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/phone"
 	"github.com/gin-gonic/gin"
 )
 
@@ -30,29 +30,6 @@ type NormalizedPayment struct {
 	Receipt    string
 }
 
-func normalizePhone(msisdn string) string {
-	// Remove all spaces, dashes, parentheses
-	re := regexp.MustCompile(`[^\d\+]`)
-	msisdn = re.ReplaceAllString(msisdn, "")
-
-	// Remove leading/trailing spaces
-	msisdn = strings.TrimSpace(msisdn)
-
-	switch {
-	case strings.HasPrefix(msisdn, "07"):
-		return "254" + msisdn[1:]
-	case strings.HasPrefix(msisdn, "01"):
-		return "254" + msisdn[1:]
-	case strings.HasPrefix(msisdn, "+254"):
-		return strings.TrimPrefix(msisdn, "+")
-	case strings.HasPrefix(msisdn, "254"):
-		return msisdn
-	default:
-		// return as-is or return "" if invalid
-		return msisdn
-	}
-}
-
 func MpesaValidation(c *gin.Context) {
 	c.JSON(200, gin.H{
 		"ResultCode": 0,
@@ -71,7 +48,7 @@ func MpesaPaymentConfirmation(db *database.Database) gin.HandlerFunc {
 		payment := NormalizedPayment{
 			Provider:   "MPESA",
 			BusinessID: payload.BusinessShortCode,
-			Phone:      normalizePhone(payload.MSISDN),
+			Phone:      phone.PaymentNumber(payload.MSISDN),
 			Amount:     int64(payload.TransAmount),
 			Receipt:    payload.TransID,
 		}
@@ -147,7 +124,7 @@ func handleTillPayment(
 		SELECT id
 		FROM tenants
 		WHERE landlord_id = $1
-		  AND $2 IN (payment_no1, payment_no2)
+		  AND $2 <> '' AND $2 IN (payment_no1, payment_no2)
 		LIMIT 1
 	`, landlordID, payment.Phone).Scan(&tenantID)
 
@@ -250,7 +227,7 @@ func handlePaybillPayment(
 		SELECT id
 		FROM tenants
 		WHERE landlord_id = $1
-		  AND $2 IN (payment_no1, payment_no2)
+		  AND $2 <> '' AND $2 IN (payment_no1, payment_no2)
 		LIMIT 1
 	`, landlordID, payment.Phone).Scan(&tenantID)
 
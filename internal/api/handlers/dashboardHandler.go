@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type DashboardHandler struct {
+	Service *services.DashboardService
+}
+
+func NewDashboardHandler(service *services.DashboardService) *DashboardHandler {
+	return &DashboardHandler{Service: service}
+}
+
+// Summary returns occupancy, rent expected against collected this month,
+// arrears aging, unmatched payments and the 12-month collection trend.
+// Query: property_id, refresh=true to skip the cache.
+func (h *DashboardHandler) Summary(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var propertyID int
+	if v := c.Query("property_id"); v != "" {
+		var err error
+		if propertyID, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property_id"})
+			return
+		}
+	}
+
+	summary, err := h.Service.Summary(c.Request.Context(), userID, propertyID, c.Query("refresh") == "true")
+	if errors.Is(err, services.ErrPropertyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] dashboardSummary: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dashboard", "trace_id": reqID})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": summary})
+}
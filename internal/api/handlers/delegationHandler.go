@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+type GrantDelegationInput struct {
+	GranteeID    int                      `json:"grantee_id"`
+	GranteeEmail string                   `json:"grantee_email"`
+	PropertyID   int                      `json:"property_id" binding:"required"`
+	Permissions  []permissions.Permission `json:"permissions" binding:"required,min=1"`
+}
+
+// ListDelegations returns the delegations the landlord has granted
+func ListDelegations(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		landlordID, err := middleware.GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		query := `
+			SELECT d.id, d.grantee_id, u.email, u.full_name, u.role, d.property_id, p.title, d.permissions, d.created_at, d.updated_at
+			FROM property_delegations d
+			JOIN users u ON d.grantee_id = u.id
+			JOIN properties p ON d.property_id = p.id
+			WHERE d.landlord_id = $1
+			ORDER BY d.created_at DESC
+		`
+
+		rows, err := db.Query(query, landlordID)
+		if err != nil {
+			reqID, _ := c.Get("request_id")
+			log.Printf("[%v] listDelegations: query failed: %v", reqID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch delegations", "trace_id": reqID})
+			return
+		}
+		defer rows.Close()
+
+		delegations := []gin.H{}
+		for rows.Next() {
+			var d struct {
+				ID            int64
+				GranteeID     int
+				GranteeEmail  string
+				GranteeName   sql.NullString
+				GranteeRole   string
+				PropertyID    int
+				PropertyTitle string
+				Permissions   []string
+				CreatedAt     time.Time
+				UpdatedAt     time.Time
+			}
+			if err := rows.Scan(&d.ID, &d.GranteeID, &d.GranteeEmail, &d.GranteeName, &d.GranteeRole,
+				&d.PropertyID, &d.PropertyTitle, pq.Array(&d.Permissions), &d.CreatedAt, &d.UpdatedAt); err != nil {
+				continue
+			}
+			delegations = append(delegations, gin.H{
+				"id": d.ID,
+				"grantee": gin.H{
+					"id":        d.GranteeID,
+					"email":     d.GranteeEmail,
+					"full_name": d.GranteeName.String,
+					"role":      d.GranteeRole,
+				},
+				"property_id":    d.PropertyID,
+				"property_title": d.PropertyTitle,
+				"permissions":    d.Permissions,
+				"created_at":     d.CreatedAt,
+				"updated_at":     d.UpdatedAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": delegations})
+	}
+}
+
+// GrantDelegation gives a staff account access to one of the landlord's
+// properties. Granting again for the same grantee and property replaces the
+// permission list.
+func GrantDelegation(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		landlordID, err := middleware.GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var input GrantDelegationInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if input.GranteeID == 0 && input.GranteeEmail == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "grantee_id or grantee_email is required"})
+			return
+		}
+
+		reqID, _ := c.Get("request_id")
+
+		// 1. Property must be owned by the caller; delegated access cannot be re-delegated
+		var owned bool
+		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM properties WHERE id = $1 AND landlord_id = $2)", input.PropertyID, landlordID).Scan(&owned)
+		if err != nil || !owned {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found or unauthorized"})
+			return
+		}
+
+		// 2. Resolve grantee; only staff accounts can receive delegations
+		var granteeID int
+		var granteeRole string
+		if input.GranteeID != 0 {
+			err = db.QueryRow("SELECT id, role FROM users WHERE id = $1", input.GranteeID).Scan(&granteeID, &granteeRole)
+		} else {
+			err = db.QueryRow("SELECT id, role FROM users WHERE email = $1", input.GranteeEmail).Scan(&granteeID, &granteeRole)
+		}
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Grantee not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("[%v] grantDelegation: grantee lookup failed: %v", reqID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "trace_id": reqID})
+			return
+		}
+		if !permissions.IsStaffRole(granteeRole) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Delegations can only be granted to caretaker, agent or accountant accounts"})
+			return
+		}
+
+		// 3. Each permission must be delegatable and within the grantee's role
+		perms := make([]string, 0, len(input.Permissions))
+		seen := map[permissions.Permission]bool{}
+		for _, p := range input.Permissions {
+			if !permissions.IsDelegatable(p) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Permission cannot be delegated", "permission": p})
+				return
+			}
+			if !permissions.Has(granteeRole, p) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Permission not allowed for the grantee's role", "permission": p, "role": granteeRole})
+				return
+			}
+			if !seen[p] {
+				seen[p] = true
+				perms = append(perms, string(p))
+			}
+		}
+
+		// 4. Upsert
+		var id int64
+		err = db.QueryRow(`
+			INSERT INTO property_delegations (landlord_id, grantee_id, property_id, permissions)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (grantee_id, property_id)
+			DO UPDATE SET permissions = EXCLUDED.permissions, updated_at = NOW()
+			RETURNING id`,
+			landlordID, granteeID, input.PropertyID, pq.Array(perms),
+		).Scan(&id)
+		if err != nil {
+			log.Printf("[%v] grantDelegation: upsert failed: %v", reqID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save delegation", "trace_id": reqID})
+			return
+		}
+
+		data := gin.H{
+			"id":          id,
+			"grantee_id":  granteeID,
+			"property_id": input.PropertyID,
+			"permissions": perms,
+		}
+		middleware.AuditEntity(c, id, &landlordID)
+		middleware.AuditAfter(c, data)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Delegation saved successfully",
+			"data":    data,
+		})
+	}
+}
+
+// RevokeDelegation removes a delegation granted by the landlord
+func RevokeDelegation(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		landlordID, err := middleware.GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		delegationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delegation ID"})
+			return
+		}
+
+		result, err := db.Exec("DELETE FROM property_delegations WHERE id = $1 AND landlord_id = $2", delegationID, landlordID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke delegation"})
+			return
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delegation not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Delegation revoked successfully"})
+	}
+}
+
+// GetMyPermissions returns the caller's role permissions and any property
+// delegations they have received
+func GetMyPermissions(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := middleware.GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		role := middleware.GetRole(c)
+
+		rows, err := db.Query(`
+			SELECT d.property_id, p.title, d.landlord_id, d.permissions
+			FROM property_delegations d
+			JOIN properties p ON d.property_id = p.id
+			WHERE d.grantee_id = $1
+			ORDER BY d.property_id`,
+			userID,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+			return
+		}
+		defer rows.Close()
+
+		delegations := []gin.H{}
+		for rows.Next() {
+			var propertyID, landlordID int
+			var title string
+			var perms []string
+			if err := rows.Scan(&propertyID, &title, &landlordID, pq.Array(&perms)); err != nil {
+				continue
+			}
+			delegations = append(delegations, gin.H{
+				"property_id":    propertyID,
+				"property_title": title,
+				"landlord_id":    landlordID,
+				"permissions":    perms,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": gin.H{
+				"role":        role,
+				"permissions": permissions.ForRole(role),
+				"delegations": delegations,
+			},
+		})
+	}
+}
@@ -0,0 +1,552 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ExpenseHandler struct {
+	Service *services.ExpenseService
+}
+
+func NewExpenseHandler(service *services.ExpenseService) *ExpenseHandler {
+	return &ExpenseHandler{Service: service}
+}
+
+// ExpenseInput records an expense. ExpenseDate (YYYY-MM-DD) defaults to
+// today. Also accepted as multipart/form-data with a "receipt" file.
+type ExpenseInput struct {
+	PropertyID  int     `json:"property_id" form:"property_id" binding:"required"`
+	UnitID      int     `json:"unit_id" form:"unit_id"`
+	VendorID    int64   `json:"vendor_id" form:"vendor_id"`
+	Category    string  `json:"category" form:"category" binding:"required"`
+	Amount      float64 `json:"amount" form:"amount" binding:"required,gt=0"`
+	Description string  `json:"description" form:"description" binding:"required,max=255"`
+	ExpenseDate string  `json:"expense_date" form:"expense_date"`
+	Reference   string  `json:"reference" form:"reference" binding:"max=100"`
+}
+
+type RecurringExpenseInput struct {
+	PropertyID  int     `json:"property_id" binding:"required"`
+	UnitID      int     `json:"unit_id"`
+	VendorID    int64   `json:"vendor_id"`
+	Category    string  `json:"category" binding:"required"`
+	Amount      float64 `json:"amount" binding:"required,gt=0"`
+	Description string  `json:"description" binding:"required,max=255"`
+	Frequency   string  `json:"frequency" binding:"required"`
+	StartDate   string  `json:"start_date" binding:"required"`
+	EndDate     string  `json:"end_date"`
+}
+
+// UpdateRecurringExpenseInput changes a schedule. end_date "" removes the
+// end date; vendor_id 0 removes the vendor.
+type UpdateRecurringExpenseInput struct {
+	Amount      *float64 `json:"amount" binding:"omitempty,gt=0"`
+	Description *string  `json:"description" binding:"omitempty,min=1,max=255"`
+	VendorID    *int64   `json:"vendor_id"`
+	EndDate     *string  `json:"end_date"`
+	Active      *bool    `json:"active"`
+}
+
+// List returns expenses, latest first. Query: property_id, unit_id,
+// vendor_id, category, from, to (YYYY-MM-DD, inclusive), limit, offset.
+func (h *ExpenseHandler) List(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	filter := services.ExpenseFilter{Category: c.Query("category")}
+	var err error
+	if filter.Limit, filter.Offset, err = pageParams(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for param, dst := range map[string]*int{"property_id": &filter.PropertyID, "unit_id": &filter.UnitID} {
+		if v := c.Query(param); v != "" {
+			if *dst, err = strconv.Atoi(v); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+				return
+			}
+		}
+	}
+	if v := c.Query("vendor_id"); v != "" {
+		if filter.VendorID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vendor_id"})
+			return
+		}
+	}
+	if v := c.Query("from"); v != "" {
+		if filter.From, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	list, err := h.Service.ListExpenses(c.Request.Context(), userID, filter)
+	if err != nil {
+		expenseError(c, "listExpenses", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list, "limit": filter.Limit, "offset": filter.Offset})
+}
+
+// Create records an expense, with a receipt when sent as multipart
+func (h *ExpenseHandler) Create(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	input, ok := bindExpense(c)
+	if !ok {
+		return
+	}
+	receipt, ok := formReceipt(c)
+	if !ok {
+		return
+	}
+
+	e, err := h.Service.CreateExpense(c.Request.Context(), userID, input, receipt)
+	if err != nil {
+		expenseError(c, "createExpense", err)
+		return
+	}
+	landlordID := int(e.LandlordID)
+	middleware.AuditEntity(c, e.ID, &landlordID)
+	middleware.AuditAfter(c, e)
+	c.JSON(http.StatusCreated, gin.H{"message": "Expense recorded", "data": e})
+}
+
+func (h *ExpenseHandler) Get(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := int64Param(c, "id", "Invalid expense ID")
+	if !ok {
+		return
+	}
+
+	e, err := h.Service.GetExpense(c.Request.Context(), userID, permissions.ExpensesRead, id)
+	if err != nil {
+		expenseError(c, "getExpense", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": e})
+}
+
+// Update replaces an expense's details; its receipt is kept
+func (h *ExpenseHandler) Update(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := int64Param(c, "id", "Invalid expense ID")
+	if !ok {
+		return
+	}
+	input, ok := bindExpense(c)
+	if !ok {
+		return
+	}
+
+	before, err := h.Service.GetExpense(c.Request.Context(), userID, permissions.ExpensesWrite, id)
+	if err != nil {
+		expenseError(c, "updateExpense", err)
+		return
+	}
+	middleware.AuditBefore(c, before)
+	e, err := h.Service.UpdateExpense(c.Request.Context(), userID, id, input)
+	if err != nil {
+		expenseError(c, "updateExpense", err)
+		return
+	}
+	landlordID := int(e.LandlordID)
+	middleware.AuditEntity(c, e.ID, &landlordID)
+	middleware.AuditAfter(c, e)
+	c.JSON(http.StatusOK, gin.H{"message": "Expense updated", "data": e})
+}
+
+func (h *ExpenseHandler) Delete(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := int64Param(c, "id", "Invalid expense ID")
+	if !ok {
+		return
+	}
+
+	e, err := h.Service.GetExpense(c.Request.Context(), userID, permissions.ExpensesWrite, id)
+	if err != nil {
+		expenseError(c, "deleteExpense", err)
+		return
+	}
+	if err := h.Service.DeleteExpense(c.Request.Context(), userID, id); err != nil {
+		expenseError(c, "deleteExpense", err)
+		return
+	}
+	landlordID := int(e.LandlordID)
+	middleware.AuditEntity(c, e.ID, &landlordID)
+	middleware.AuditBefore(c, e)
+	c.JSON(http.StatusOK, gin.H{"message": "Expense deleted"})
+}
+
+// UploadReceipt attaches a "receipt" file (multipart/form-data)
+func (h *ExpenseHandler) UploadReceipt(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := int64Param(c, "id", "Invalid expense ID")
+	if !ok {
+		return
+	}
+	receipt, ok := formReceipt(c)
+	if !ok {
+		return
+	}
+	if receipt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload the file in \"receipt\""})
+		return
+	}
+
+	e, err := h.Service.SetReceipt(c.Request.Context(), userID, id, *receipt)
+	if err != nil {
+		expenseError(c, "uploadExpenseReceipt", err)
+		return
+	}
+	landlordID := int(e.LandlordID)
+	middleware.AuditEntity(c, e.ID, &landlordID)
+	middleware.AuditAfter(c, e)
+	c.JSON(http.StatusOK, gin.H{"message": "Receipt attached", "data": e})
+}
+
+// Receipt downloads an expense's receipt
+func (h *ExpenseHandler) Receipt(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := int64Param(c, "id", "Invalid expense ID")
+	if !ok {
+		return
+	}
+
+	r, err := h.Service.GetReceipt(c.Request.Context(), userID, id)
+	if err != nil {
+		expenseError(c, "getExpenseReceipt", err)
+		return
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": r.Filename}))
+	c.Data(http.StatusOK, r.ContentType, r.Data)
+}
+
+// --- Recurring expenses ---
+
+// ListRecurring returns expense schedules. Query: property_id.
+func (h *ExpenseHandler) ListRecurring(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var propertyID int
+	if v := c.Query("property_id"); v != "" {
+		var err error
+		if propertyID, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property_id"})
+			return
+		}
+	}
+
+	list, err := h.Service.ListRecurring(c.Request.Context(), userID, propertyID)
+	if err != nil {
+		expenseError(c, "listRecurringExpenses", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// CreateRecurring adds a schedule; occurrences from start_date up to today
+// are recorded immediately
+func (h *ExpenseHandler) CreateRecurring(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var input RecurringExpenseInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	start, err := time.Parse("2006-01-02", input.StartDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_date, expected YYYY-MM-DD"})
+		return
+	}
+	var end *time.Time
+	if input.EndDate != "" {
+		t, err := time.Parse("2006-01-02", input.EndDate)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date, expected YYYY-MM-DD"})
+			return
+		}
+		end = &t
+	}
+
+	r, err := h.Service.CreateRecurring(c.Request.Context(), userID, services.RecurringExpenseInput{
+		PropertyID:  input.PropertyID,
+		UnitID:      input.UnitID,
+		VendorID:    input.VendorID,
+		Category:    input.Category,
+		Amount:      input.Amount,
+		Description: input.Description,
+		Frequency:   input.Frequency,
+		StartDate:   start,
+		EndDate:     end,
+	})
+	if err != nil {
+		expenseError(c, "createRecurringExpense", err)
+		return
+	}
+	landlordID := int(r.LandlordID)
+	middleware.AuditEntity(c, r.ID, &landlordID)
+	middleware.AuditAfter(c, r)
+	c.JSON(http.StatusCreated, gin.H{"message": "Recurring expense created", "data": r})
+}
+
+// UpdateRecurring changes, pauses or resumes a schedule
+func (h *ExpenseHandler) UpdateRecurring(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := int64Param(c, "id", "Invalid recurring expense ID")
+	if !ok {
+		return
+	}
+	var input UpdateRecurringExpenseInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	update := services.RecurringExpenseUpdate{
+		Amount:      input.Amount,
+		Description: input.Description,
+		VendorID:    input.VendorID,
+		Active:      input.Active,
+	}
+	if input.EndDate != nil {
+		if *input.EndDate == "" {
+			update.ClearEnd = true
+		} else {
+			t, err := time.Parse("2006-01-02", *input.EndDate)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_date, expected YYYY-MM-DD"})
+				return
+			}
+			update.EndDate = &t
+		}
+	}
+
+	r, err := h.Service.UpdateRecurring(c.Request.Context(), userID, id, update)
+	if err != nil {
+		expenseError(c, "updateRecurringExpense", err)
+		return
+	}
+	landlordID := int(r.LandlordID)
+	middleware.AuditEntity(c, r.ID, &landlordID)
+	middleware.AuditAfter(c, r)
+	c.JSON(http.StatusOK, gin.H{"message": "Recurring expense updated", "data": r})
+}
+
+// DeleteRecurring stops a schedule; expenses already recorded are kept
+func (h *ExpenseHandler) DeleteRecurring(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := int64Param(c, "id", "Invalid recurring expense ID")
+	if !ok {
+		return
+	}
+
+	r, err := h.Service.DeleteRecurring(c.Request.Context(), userID, id)
+	if err != nil {
+		expenseError(c, "deleteRecurringExpense", err)
+		return
+	}
+	landlordID := int(r.LandlordID)
+	middleware.AuditEntity(c, r.ID, &landlordID)
+	middleware.AuditBefore(c, r)
+	c.JSON(http.StatusOK, gin.H{"message": "Recurring expense deleted"})
+}
+
+// --- Reports ---
+
+// ProfitAndLoss reports rent collected against expenses per property.
+// Query: from, to (YYYY-MM-DD, inclusive; default this month), property_id,
+// format=csv for a spreadsheet download.
+func (h *ExpenseHandler) ProfitAndLoss(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	from, to, err := statementPeriod(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var propertyID int
+	if v := c.Query("property_id"); v != "" {
+		if propertyID, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property_id"})
+			return
+		}
+	}
+
+	report, err := h.Service.ProfitAndLoss(c.Request.Context(), userID, from, to, propertyID)
+	if err != nil {
+		expenseError(c, "profitAndLoss", err)
+		return
+	}
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, gin.H{"data": report})
+		return
+	}
+
+	filename := fmt.Sprintf("profit-and-loss-%s-to-%s.csv", report.From, report.To)
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	header := []string{"property_id", "property", "rent_collected", "payments"}
+	for _, category := range services.ExpenseCategories {
+		header = append(header, "expense_"+category)
+	}
+	w.Write(append(header, "total_expenses", "net_operating_income"))
+	for _, p := range report.Properties {
+		w.Write(profitAndLossRow(strconv.Itoa(int(p.PropertyID)), p.PropertyTitle, p))
+	}
+	w.Write(profitAndLossRow("", "Total", report.Total))
+	w.Flush()
+}
+
+func profitAndLossRow(id, title string, p models.PropertyProfitAndLoss) []string {
+	row := []string{id, title, formatAmount(p.RentCollected), strconv.Itoa(p.PaymentCount)}
+	for _, category := range services.ExpenseCategories {
+		row = append(row, formatAmount(p.Expenses[category]))
+	}
+	return append(row, formatAmount(p.TotalExpenses), formatAmount(p.NetOperatingIncome))
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// bindExpense reads an expense from JSON or a form
+func bindExpense(c *gin.Context) (services.ExpenseInput, bool) {
+	var input ExpenseInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return services.ExpenseInput{}, false
+	}
+	date := time.Now()
+	if input.ExpenseDate != "" {
+		var err error
+		if date, err = time.Parse("2006-01-02", input.ExpenseDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expense_date, expected YYYY-MM-DD"})
+			return services.ExpenseInput{}, false
+		}
+	}
+	return services.ExpenseInput{
+		PropertyID:  input.PropertyID,
+		UnitID:      input.UnitID,
+		VendorID:    input.VendorID,
+		Category:    input.Category,
+		Amount:      input.Amount,
+		Description: input.Description,
+		Date:        date,
+		Reference:   input.Reference,
+	}, true
+}
+
+// formReceipt reads the optional "receipt" file of a multipart request
+func formReceipt(c *gin.Context) (*services.ExpenseReceipt, bool) {
+	files, ok := formFiles(c, "receipt", 1, services.MaxReceiptSize,
+		errors.New("upload a single receipt"), services.ErrReceiptTooLarge)
+	if !ok || len(files) == 0 {
+		return nil, ok
+	}
+	f := files[0]
+	return &services.ExpenseReceipt{Filename: f.Filename, ContentType: f.ContentType, Data: f.Data}, true
+}
+
+func currentUserID(c *gin.Context) (int, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, false
+	}
+	return userID, true
+}
+
+func int64Param(c *gin.Context, name, message string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return 0, false
+	}
+	return id, true
+}
+
+func intParam(c *gin.Context, name, message string) (int, bool) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return 0, false
+	}
+	return id, true
+}
+
+// expenseError maps service errors to responses
+func expenseError(c *gin.Context, fn string, err error) {
+	switch {
+	case errors.Is(err, services.ErrExpenseNotFound), errors.Is(err, services.ErrRecurringExpenseNotFound),
+		errors.Is(err, services.ErrReceiptNotFound), errors.Is(err, services.ErrPropertyNotFound),
+		errors.Is(err, services.ErrVendorNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrReceiptTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidExpenseCategory), errors.Is(err, services.ErrInvalidFrequency),
+		errors.Is(err, services.ErrInvalidAmount), errors.Is(err, services.ErrInvalidDateRange),
+		errors.Is(err, services.ErrUnitNotOnProperty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] %s: %v", reqID, fn, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process expense", "trace_id": reqID})
+	}
+}
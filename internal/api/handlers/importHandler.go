@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ImportHandler struct {
+	Service *services.ImportService
+}
+
+func NewImportHandler(service *services.ImportService) *ImportHandler {
+	return &ImportHandler{Service: service}
+}
+
+var importErrorColumns = []documents.Column{
+	{Title: "Row", Width: 15},
+	{Title: "Column", Width: 35},
+	{Title: "Value", Width: 50},
+	{Title: "Message", Width: 90},
+}
+
+// Import creates a property's units, and tenants where given, from a "file"
+// upload (multipart/form-data) in CSV or XLSX. Columns: unit_name,
+// unit_type, unit_price and optionally tenant_name, payment_no1,
+// payment_no2, rent, rent_due_day, email. With dry_run=true the file is only
+// validated. A file with any row error imports nothing.
+func (h *ImportHandler) Import(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	propertyID, err := strconv.Atoi(c.Param("propertyId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+		return
+	}
+	dryRun, err := strconv.ParseBool(c.DefaultPostForm("dry_run", c.DefaultQuery("dry_run", "false")))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+		return
+	}
+	files, ok := formFiles(c, "file", 1, services.MaxImportSize, errors.New("upload a single file"), services.ErrImportTooLarge)
+	if !ok {
+		return
+	}
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload the spreadsheet in \"file\""})
+		return
+	}
+	f := files[0]
+	format := importFormat(c.PostForm("format"), f.Filename, f.ContentType)
+	if format == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file must be a .csv or .xlsx spreadsheet"})
+		return
+	}
+
+	job, err := h.Service.Import(c.Request.Context(), userID, propertyID, services.ImportFile{Filename: f.Filename, Format: format, Data: f.Data}, dryRun)
+	if err != nil {
+		importError(c, "importUnits", err)
+		return
+	}
+
+	switch job.Status {
+	case services.ImportInvalid:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("%d problems found; nothing was imported", job.ErrorCount), "data": job})
+		return
+	case services.ImportValidated:
+		c.JSON(http.StatusOK, gin.H{"message": "File is valid; nothing was imported", "data": job})
+	default:
+		c.JSON(http.StatusCreated, gin.H{"message": "Import completed", "data": job})
+	}
+	landlordID := int(job.LandlordID)
+	middleware.AuditEntity(c, job.ID, &landlordID)
+	summary := job
+	summary.Errors = nil
+	middleware.AuditAfter(c, summary)
+}
+
+// ListJobs returns a property's imports. Query: limit, offset.
+func (h *ImportHandler) ListJobs(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	propertyID, err := strconv.Atoi(c.Param("propertyId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+		return
+	}
+	limit, offset, err := pageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	jobs, err := h.Service.ListJobs(c.Request.Context(), userID, propertyID, limit, offset)
+	if err != nil {
+		importError(c, "listImports", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": jobs})
+}
+
+// GetJob returns an import with its row errors
+func (h *ImportHandler) GetJob(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := int64Param(c, "id", "Invalid import ID")
+	if !ok {
+		return
+	}
+
+	job, err := h.Service.GetJob(c.Request.Context(), userID, id)
+	if err != nil {
+		importError(c, "getImport", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
+
+// ErrorReport downloads an import's row errors. Query: format=csv|xlsx
+// (default csv).
+func (h *ImportHandler) ErrorReport(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := int64Param(c, "id", "Invalid import ID")
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", documents.FormatCSV)
+	if format != documents.FormatCSV && format != documents.FormatXLSX {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or xlsx"})
+		return
+	}
+
+	job, err := h.Service.GetJob(c.Request.Context(), userID, id)
+	if err != nil {
+		importError(c, "importErrorReport", err)
+		return
+	}
+
+	c.Header("Content-Type", documents.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=import-%d-errors.%s", job.ID, format))
+	c.Status(http.StatusOK)
+	tw, err := documents.NewTableWriter(c.Writer, format, documents.Table{Title: "Import errors", Columns: importErrorColumns})
+	if err == nil {
+		for _, e := range job.Errors {
+			var row interface{}
+			if e.Row > 0 {
+				row = e.Row
+			}
+			if err = tw.Row(row, e.Column, e.Value, e.Message); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err != nil {
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] importErrorReport: aborted after streaming started: %v", reqID, err)
+		c.Abort()
+	}
+}
+
+// importFormat picks the spreadsheet format from an explicit format field,
+// the file extension or its content type
+func importFormat(format, filename, contentType string) string {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(filename)), ".")
+	}
+	switch {
+	case format == documents.FormatCSV, format == documents.FormatXLSX:
+		return format
+	case format == "" && contentType == documents.ContentTypeXLSX:
+		return documents.FormatXLSX
+	case format == "" && strings.HasPrefix(contentType, documents.ContentTypeCSV):
+		return documents.FormatCSV
+	}
+	return ""
+}
+
+// importError maps service errors to responses
+func importError(c *gin.Context, fn string, err error) {
+	switch {
+	case errors.Is(err, services.ErrPropertyNotFound), errors.Is(err, services.ErrImportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrImportTooLarge), errors.Is(err, documents.ErrSpreadsheetTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmptyImport), errors.Is(err, services.ErrTooManyImportRows),
+		errors.Is(err, services.ErrImportColumns), errors.Is(err, documents.ErrUnreadableSpreadsheet),
+		errors.Is(err, documents.ErrUnknownFormat):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] %s: %v", reqID, fn, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process import", "trace_id": reqID})
+	}
+}
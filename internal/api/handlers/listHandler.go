@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Zolet-hash/smart-rentals/internal/listing"
+	"github.com/Zolet-hash/smart-rentals/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// listParams reads a list's page, sort and filters from the query string
+func listParams(c *gin.Context, spec listing.Spec) (listing.Page, listing.Filter, bool) {
+	page, err := listing.ParsePage(c.Request.URL.Query(), spec)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return page, listing.Filter{}, false
+	}
+	filter, err := listing.ParseFilter(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return page, filter, false
+	}
+	return page, filter, true
+}
+
+// writePage responds with a page in the list envelope: {"data": [...],
+// "next_cursor": "..." or null, "total": n}, total only when asked for with
+// include_total=true
+func writePage[T any](c *gin.Context, p repository.Page[T]) {
+	resp := gin.H{"data": p.Items, "next_cursor": p.NextCursor}
+	if p.Total != nil {
+		resp["total"] = *p.Total
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// mapPage converts the items of a page, e.g. to the JSON a list has always
+// returned
+func mapPage[T, U any](p repository.Page[T], fn func(T) U) repository.Page[U] {
+	items := make([]U, len(p.Items))
+	for i, item := range p.Items {
+		items[i] = fn(item)
+	}
+	return repository.Page[U]{Items: items, NextCursor: p.NextCursor, Total: p.Total}
+}
+
+// listError maps list errors to responses. A cursor whose value does not fit
+// the sort is rejected by the repository.
+func listError(c *gin.Context, fn, failMsg string, err error) {
+	if errors.Is(err, listing.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": listing.ErrInvalidCursor.Error()})
+		return
+	}
+	reqID, _ := c.Get("request_id")
+	log.Printf("[%v] %s: %v", reqID, fn, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": failMsg, "trace_id": reqID})
+}
+
+// storeError maps repository errors to responses: notFound for records that
+// do not exist or that the caller may not reach, failMsg for failures
+func storeError(c *gin.Context, fn, notFound, failMsg string, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		return
+	}
+	reqID, _ := c.Get("request_id")
+	log.Printf("[%v] %s: %v", reqID, fn, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": failMsg, "trace_id": reqID})
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordLoginAttempt logs an attempt to login_attempts. Failures to log are
+// not fatal to the login itself.
+func (h *AuthHandler) recordLoginAttempt(c *gin.Context, userID *int, email string, success bool, reason string) {
+	_, err := h.db.DB.Exec(`
+		INSERT INTO login_attempts (user_id, email, ip_address, success, reason)
+		VALUES ($1, $2, $3, $4, $5)`,
+		userID, email, c.ClientIP(), success, reason,
+	)
+	if err != nil {
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] login: failed to record attempt: %v", reqID, err)
+	}
+}
+
+// recordLoginFailure counts a failed attempt and locks the account once the
+// threshold is reached. Returns the lock expiry when a lock was applied.
+func (h *AuthHandler) recordLoginFailure(userID int) (*time.Time, error) {
+	tx, err := h.db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var attempts, lockouts int
+	err = tx.QueryRow("SELECT failed_login_attempts, lockout_count FROM users WHERE id = $1 FOR UPDATE", userID).
+		Scan(&attempts, &lockouts)
+	if err != nil {
+		return nil, err
+	}
+	attempts, lockouts, lock := nextLoginFailure(attempts, lockouts, h.maxLoginAttempts, h.lockoutDuration, h.lockoutMaxDuration)
+
+	var lockedUntil sql.NullTime
+	err = tx.QueryRow(`
+		UPDATE users SET
+			failed_login_attempts = $2,
+			lockout_count = $3,
+			locked_until = CASE WHEN $4 > 0 THEN NOW() + $4 * INTERVAL '1 second' ELSE locked_until END,
+			last_failed_login_at = NOW()
+		WHERE id = $1
+		RETURNING CASE WHEN locked_until > NOW() THEN locked_until END`,
+		userID, attempts, lockouts, lock.Seconds(),
+	).Scan(&lockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if !lockedUntil.Valid {
+		return nil, nil
+	}
+	return &lockedUntil.Time, nil
+}
+
+// nextLoginFailure counts one more failed attempt. Reaching maxAttempts
+// resets the count and locks the account for base, doubled for each earlier
+// consecutive lockout up to max; lock is 0 when no lock is applied.
+func nextLoginFailure(attempts, lockouts, maxAttempts int, base, max time.Duration) (int, int, time.Duration) {
+	attempts++
+	if attempts < maxAttempts {
+		return attempts, lockouts, 0
+	}
+	lock := base
+	for i := 0; i < lockouts && lock < max; i++ {
+		lock *= 2
+	}
+	return 0, lockouts + 1, min(lock, max)
+}
+
+// resetLoginFailures clears failure counters after a successful login
+func (h *AuthHandler) resetLoginFailures(userID int) {
+	h.db.DB.Exec(`
+		UPDATE users SET failed_login_attempts = 0, lockout_count = 0, locked_until = NULL
+		WHERE id = $1 AND (failed_login_attempts > 0 OR lockout_count > 0 OR locked_until IS NOT NULL)`,
+		userID,
+	)
+}
+
+// respondLocked replies 423 with a Retry-After header for a locked account
+func respondLocked(c *gin.Context, lockedUntil time.Time) {
+	seconds := int(math.Ceil(time.Until(lockedUntil).Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusLocked, gin.H{
+		"error":        "Account temporarily locked due to too many failed login attempts",
+		"locked_until": lockedUntil,
+		"retry_after":  seconds,
+	})
+}
+
+// UnlockUser clears a user's lockout and failed attempt counters
+func (h *AuthHandler) UnlockUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	result, err := h.db.DB.Exec(`
+		UPDATE users
+		SET failed_login_attempts = 0, lockout_count = 0, locked_until = NULL, updated_at = NOW()
+		WHERE id = $1`,
+		userID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	log.Printf("Admin unlocked user ID: %d", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked successfully", "user_id": userID})
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextLoginFailure(t *testing.T) {
+	const maxAttempts = 5
+	base, max := 15*time.Minute, 2*time.Hour
+
+	attempts, lockouts, lock := nextLoginFailure(3, 0, maxAttempts, base, max)
+	if attempts != 4 || lockouts != 0 || lock != 0 {
+		t.Errorf("below the threshold: got %d, %d, %v; want 4, 0, no lock", attempts, lockouts, lock)
+	}
+
+	// Each consecutive lockout doubles the lock, up to the maximum
+	for i, want := range []time.Duration{15 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour, 2 * time.Hour} {
+		attempts, lockouts, lock := nextLoginFailure(maxAttempts-1, i, maxAttempts, base, max)
+		if attempts != 0 || lockouts != i+1 || lock != want {
+			t.Errorf("lockout %d: got %d, %d, %v; want 0, %d, %v", i+1, attempts, lockouts, lock, i+1, want)
+		}
+	}
+
+	// A long run of lockouts cannot overflow past the maximum
+	if _, _, lock := nextLoginFailure(maxAttempts-1, 200, maxAttempts, base, max); lock != max {
+		t.Errorf("after 200 lockouts: lock = %v, want %v", lock, max)
+	}
+}
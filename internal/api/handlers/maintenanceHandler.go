@@ -0,0 +1,521 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type MaintenanceHandler struct {
+	Service *services.MaintenanceService
+}
+
+func NewMaintenanceHandler(service *services.MaintenanceService) *MaintenanceHandler {
+	return &MaintenanceHandler{Service: service}
+}
+
+// CreateTicketInput reports a repair. UnitID is required for staff and
+// ignored for tenants. Also accepted as multipart/form-data with "photos".
+type CreateTicketInput struct {
+	UnitID      int    `json:"unit_id" form:"unit_id"`
+	TenantID    int    `json:"tenant_id" form:"tenant_id"`
+	Title       string `json:"title" form:"title" binding:"required,max=255"`
+	Description string `json:"description" form:"description"`
+	Category    string `json:"category" form:"category" binding:"required"`
+	Priority    string `json:"priority" form:"priority"`
+}
+
+type UpdateTicketInput struct {
+	Title       *string `json:"title" binding:"omitempty,min=1,max=255"`
+	Description *string `json:"description"`
+	Category    *string `json:"category"`
+	Priority    *string `json:"priority"`
+}
+
+// AssignTicketInput sets who does the work; 0 or omitted clears a field
+type AssignTicketInput struct {
+	AssigneeID int   `json:"assignee_id"`
+	VendorID   int64 `json:"vendor_id"`
+}
+
+type TicketStatusInput struct {
+	Status string `json:"status" binding:"required"`
+	Note   string `json:"note"` // resolution note when resolving
+}
+
+// TicketCostInput records a repair cost. With recharge_tenant the charge
+// amount (default: the full cost) is added to the tenant's balance.
+type TicketCostInput struct {
+	Cost              *float64 `json:"cost" binding:"required,gte=0"`
+	RechargeTenant    bool     `json:"recharge_tenant"`
+	ChargeAmount      float64  `json:"charge_amount" binding:"gte=0"`
+	ChargeDescription string   `json:"charge_description" binding:"max=255"`
+}
+
+// VendorInput creates or updates a vendor. Staff add vendors for a landlord
+// they work for with landlord_id; it defaults to the caller.
+type VendorInput struct {
+	LandlordID int    `json:"landlord_id"`
+	Name       string `json:"name" binding:"required,max=255"`
+	Phone      string `json:"phone" binding:"max=50"`
+	Email      string `json:"email" binding:"omitempty,email,max=255"`
+	Trade      string `json:"trade" binding:"max=100"`
+}
+
+// List returns maintenance tickets, newest first. Query: status, priority,
+// category, property_id, unit_id, assignee_id, overdue=true, limit, offset.
+func (h *MaintenanceHandler) List(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+
+	filter := services.TicketFilter{
+		Status:   c.Query("status"),
+		Priority: c.Query("priority"),
+		Category: c.Query("category"),
+		Overdue:  c.Query("overdue") == "true",
+	}
+	var err error
+	if filter.Limit, filter.Offset, err = pageParams(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for param, dst := range map[string]*int{
+		"property_id": &filter.PropertyID,
+		"unit_id":     &filter.UnitID,
+		"assignee_id": &filter.AssigneeID,
+	} {
+		if v := c.Query(param); v != "" {
+			if *dst, err = strconv.Atoi(v); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+				return
+			}
+		}
+	}
+
+	list, err := h.Service.ListTickets(c.Request.Context(), p, filter)
+	if err != nil {
+		maintenanceError(c, "listTickets", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list, "limit": filter.Limit, "offset": filter.Offset})
+}
+
+// Create reports a repair
+func (h *MaintenanceHandler) Create(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+
+	var input CreateTicketInput
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !p.Tenant && input.UnitID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unit_id is required"})
+		return
+	}
+	photos, ok := formPhotos(c)
+	if !ok {
+		return
+	}
+
+	tk, err := h.Service.CreateTicket(c.Request.Context(), p, services.TicketInput{
+		UnitID:      input.UnitID,
+		TenantID:    input.TenantID,
+		Title:       input.Title,
+		Description: input.Description,
+		Category:    input.Category,
+		Priority:    input.Priority,
+	}, photos)
+	if err != nil {
+		maintenanceError(c, "createTicket", err)
+		return
+	}
+	landlordID := int(tk.LandlordID)
+	middleware.AuditEntity(c, tk.ID, &landlordID)
+	middleware.AuditAfter(c, tk)
+	c.JSON(http.StatusCreated, gin.H{"message": "Maintenance request created", "data": tk})
+}
+
+// Get returns a ticket with its photos
+func (h *MaintenanceHandler) Get(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+	id, ok := ticketIDParam(c)
+	if !ok {
+		return
+	}
+
+	tk, err := h.Service.GetTicket(c.Request.Context(), p, id)
+	if err != nil {
+		maintenanceError(c, "getTicket", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tk})
+}
+
+// Update changes a ticket's title, description, category or priority
+func (h *MaintenanceHandler) Update(c *gin.Context) {
+	var input UpdateTicketInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.change(c, "updateTicket", func(p services.Participant, id int64) (models.MaintenanceTicket, error) {
+		return h.Service.UpdateTicket(c.Request.Context(), p, id, services.TicketUpdate{
+			Title:       input.Title,
+			Description: input.Description,
+			Category:    input.Category,
+			Priority:    input.Priority,
+		})
+	})
+}
+
+// Assign hands a ticket to a caretaker or other staff member and/or a vendor
+func (h *MaintenanceHandler) Assign(c *gin.Context) {
+	var input AssignTicketInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.change(c, "assignTicket", func(p services.Participant, id int64) (models.MaintenanceTicket, error) {
+		return h.Service.AssignTicket(c.Request.Context(), p, id, input.AssigneeID, input.VendorID)
+	})
+}
+
+// SetStatus moves a ticket along open, assigned, in_progress and resolved
+func (h *MaintenanceHandler) SetStatus(c *gin.Context) {
+	var input TicketStatusInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.change(c, "setTicketStatus", func(p services.Participant, id int64) (models.MaintenanceTicket, error) {
+		return h.Service.SetStatus(c.Request.Context(), p, id, input.Status, input.Note)
+	})
+}
+
+// SetCost records a repair cost and optionally charges it to the tenant
+func (h *MaintenanceHandler) SetCost(c *gin.Context) {
+	var input TicketCostInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.change(c, "setTicketCost", func(p services.Participant, id int64) (models.MaintenanceTicket, error) {
+		return h.Service.SetCost(c.Request.Context(), p, id, services.TicketCost{
+			Cost:              *input.Cost,
+			Recharge:          input.RechargeTenant,
+			ChargeAmount:      input.ChargeAmount,
+			ChargeDescription: input.ChargeDescription,
+		})
+	})
+}
+
+// change loads the ticket for the audit trail, applies fn and responds
+// with the updated ticket
+func (h *MaintenanceHandler) change(c *gin.Context, fn string, apply func(services.Participant, int64) (models.MaintenanceTicket, error)) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+	id, ok := ticketIDParam(c)
+	if !ok {
+		return
+	}
+
+	if middleware.Auditing(c) {
+		if before, err := h.Service.GetTicket(c.Request.Context(), p, id); err == nil {
+			middleware.AuditBefore(c, before)
+		}
+	}
+	tk, err := apply(p, id)
+	if err != nil {
+		maintenanceError(c, fn, err)
+		return
+	}
+	landlordID := int(tk.LandlordID)
+	middleware.AuditEntity(c, tk.ID, &landlordID)
+	middleware.AuditAfter(c, tk)
+	c.JSON(http.StatusOK, gin.H{"message": "Maintenance request updated", "data": tk})
+}
+
+// AddPhotos uploads "photos" (multipart/form-data) to a ticket
+func (h *MaintenanceHandler) AddPhotos(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+	id, ok := ticketIDParam(c)
+	if !ok {
+		return
+	}
+	photos, ok := formPhotos(c)
+	if !ok {
+		return
+	}
+	if len(photos) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload at least one file in \"photos\""})
+		return
+	}
+
+	tk, err := h.Service.AddPhotos(c.Request.Context(), p, id, photos)
+	if err != nil {
+		maintenanceError(c, "addTicketPhotos", err)
+		return
+	}
+	landlordID := int(tk.LandlordID)
+	middleware.AuditEntity(c, tk.ID, &landlordID)
+	middleware.AuditAfter(c, tk)
+	c.JSON(http.StatusCreated, gin.H{"message": "Photos added", "data": tk})
+}
+
+// Photo downloads a ticket photo
+func (h *MaintenanceHandler) Photo(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+	id, ok := ticketIDParam(c)
+	if !ok {
+		return
+	}
+	photoID, err := strconv.ParseInt(c.Param("photoId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid photo ID"})
+		return
+	}
+
+	ph, err := h.Service.GetPhoto(c.Request.Context(), p, id, photoID)
+	if err != nil {
+		maintenanceError(c, "getTicketPhoto", err)
+		return
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": ph.Filename}))
+	c.Data(http.StatusOK, ph.ContentType, ph.Data)
+}
+
+// SLA reports resolution times against SLA deadlines per property for
+// tickets reported in a period. Query: from, to (YYYY-MM-DD, default this
+// month), property_id.
+func (h *MaintenanceHandler) SLA(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+	from, to, err := statementPeriod(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var propertyID int
+	if v := c.Query("property_id"); v != "" {
+		if propertyID, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property_id"})
+			return
+		}
+	}
+
+	report, err := h.Service.SLAReport(c.Request.Context(), p, from, to, propertyID)
+	if err != nil {
+		maintenanceError(c, "maintenanceSLA", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": report,
+		"from": from.Format("2006-01-02"),
+		"to":   to.AddDate(0, 0, -1).Format("2006-01-02"),
+	})
+}
+
+// TenantCharges lists charges added to a tenant's balance
+func (h *MaintenanceHandler) TenantCharges(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	tenantID, err := strconv.Atoi(c.Param("tenantId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return
+	}
+
+	list, err := h.Service.ListCharges(c.Request.Context(), userID, tenantID)
+	if err != nil {
+		maintenanceError(c, "listTenantCharges", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// --- Vendors ---
+
+func (h *MaintenanceHandler) ListVendors(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+	list, err := h.Service.ListVendors(c.Request.Context(), p)
+	if err != nil {
+		maintenanceError(c, "listVendors", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+func (h *MaintenanceHandler) CreateVendor(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+	var input VendorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	v, err := h.Service.CreateVendor(c.Request.Context(), p, input.LandlordID, models.Vendor{
+		Name:  input.Name,
+		Phone: input.Phone,
+		Email: input.Email,
+		Trade: input.Trade,
+	})
+	if err != nil {
+		maintenanceError(c, "createVendor", err)
+		return
+	}
+	landlordID := int(v.LandlordID)
+	middleware.AuditEntity(c, v.ID, &landlordID)
+	middleware.AuditAfter(c, v)
+	c.JSON(http.StatusCreated, gin.H{"message": "Vendor created", "data": v})
+}
+
+func (h *MaintenanceHandler) UpdateVendor(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+	id, ok := vendorIDParam(c)
+	if !ok {
+		return
+	}
+	var input VendorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	v, err := h.Service.GetVendor(c.Request.Context(), p, id)
+	if err != nil {
+		maintenanceError(c, "updateVendor", err)
+		return
+	}
+	middleware.AuditBefore(c, v)
+	v.Name, v.Phone, v.Email, v.Trade = input.Name, input.Phone, input.Email, input.Trade
+	if v, err = h.Service.UpdateVendor(c.Request.Context(), p, v); err != nil {
+		maintenanceError(c, "updateVendor", err)
+		return
+	}
+	landlordID := int(v.LandlordID)
+	middleware.AuditEntity(c, v.ID, &landlordID)
+	middleware.AuditAfter(c, v)
+	c.JSON(http.StatusOK, gin.H{"message": "Vendor updated", "data": v})
+}
+
+func (h *MaintenanceHandler) DeleteVendor(c *gin.Context) {
+	p, ok := participant(c)
+	if !ok {
+		return
+	}
+	id, ok := vendorIDParam(c)
+	if !ok {
+		return
+	}
+
+	v, err := h.Service.GetVendor(c.Request.Context(), p, id)
+	if err != nil {
+		maintenanceError(c, "deleteVendor", err)
+		return
+	}
+	if err := h.Service.DeleteVendor(c.Request.Context(), p, id); err != nil {
+		maintenanceError(c, "deleteVendor", err)
+		return
+	}
+	landlordID := int(v.LandlordID)
+	middleware.AuditEntity(c, v.ID, &landlordID)
+	middleware.AuditBefore(c, v)
+	c.JSON(http.StatusOK, gin.H{"message": "Vendor deleted"})
+}
+
+func ticketIDParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+func vendorIDParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid vendor ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// formPhotos reads "photos" files from a multipart request
+func formPhotos(c *gin.Context) ([]models.MaintenancePhoto, bool) {
+	files, ok := formFiles(c, "photos", services.MaxTicketPhotos, services.MaxPhotoSize,
+		services.ErrTooManyPhotos, services.ErrPhotoTooLarge)
+	if !ok {
+		return nil, false
+	}
+	var list []models.MaintenancePhoto
+	for _, f := range files {
+		list = append(list, models.MaintenancePhoto{Filename: f.Filename, ContentType: f.ContentType, Data: f.Data})
+	}
+	return list, true
+}
+
+// maintenanceError maps service errors to responses
+func maintenanceError(c *gin.Context, fn string, err error) {
+	switch {
+	case errors.Is(err, services.ErrTicketNotFound), errors.Is(err, services.ErrPhotoNotFound),
+		errors.Is(err, services.ErrVendorNotFound), errors.Is(err, services.ErrUnitNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTenantNotLinked), errors.Is(err, services.ErrStaffOnly),
+		errors.Is(err, services.ErrChargeUnauthorized):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAlreadyCharged), errors.Is(err, services.ErrInvalidTransition),
+		errors.Is(err, services.ErrTicketUnassigned):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPhotoTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCategory), errors.Is(err, services.ErrInvalidPriority),
+		errors.Is(err, services.ErrInvalidStatus), errors.Is(err, services.ErrInvalidAssignee),
+		errors.Is(err, services.ErrTenantNotOnUnit), errors.Is(err, services.ErrNoTenantToCharge),
+		errors.Is(err, services.ErrInvalidCharge), errors.Is(err, services.ErrTooManyPhotos),
+		errors.Is(err, services.ErrNotAnImage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] %s: %v", reqID, fn, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process maintenance request", "trace_id": reqID})
+	}
+}
@@ -0,0 +1,408 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+	"github.com/Zolet-hash/smart-rentals/internal/notify"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHandler struct {
+	Service *services.NotificationService
+}
+
+func NewNotificationHandler(service *services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{Service: service}
+}
+
+// UpdateSMSSettingsInput changes only the fields that are present
+type UpdateSMSSettingsInput struct {
+	SenderID           *string `json:"sms_sender_id"`
+	PaymentReceipts    *bool   `json:"payment_receipts"`
+	BalanceReminders   *bool   `json:"balance_reminders"`
+	OverdueNotices     *bool   `json:"overdue_notices"`
+	ReminderDaysBefore *int    `json:"reminder_days_before"`
+}
+
+// GetSMSSettings returns the landlord's SMS sender ID and message preferences
+func (h *NotificationHandler) GetSMSSettings(c *gin.Context) {
+	landlordID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	settings, err := h.Service.GetSettings(c.Request.Context(), landlordID)
+	if err != nil {
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] getSMSSettings: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch settings", "trace_id": reqID})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": settings})
+}
+
+// UpdateSMSSettings saves the landlord's SMS preferences
+func (h *NotificationHandler) UpdateSMSSettings(c *gin.Context) {
+	landlordID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input UpdateSMSSettingsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reqID, _ := c.Get("request_id")
+	ctx := c.Request.Context()
+	settings, err := h.Service.GetSettings(ctx, landlordID)
+	if err != nil {
+		log.Printf("[%v] updateSMSSettings: load failed: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch settings", "trace_id": reqID})
+		return
+	}
+	middleware.AuditEntity(c, landlordID, &landlordID)
+	middleware.AuditBefore(c, settings)
+
+	if input.SenderID != nil {
+		settings.SenderID = *input.SenderID
+	}
+	if input.PaymentReceipts != nil {
+		settings.PaymentReceipts = *input.PaymentReceipts
+	}
+	if input.BalanceReminders != nil {
+		settings.BalanceReminders = *input.BalanceReminders
+	}
+	if input.OverdueNotices != nil {
+		settings.OverdueNotices = *input.OverdueNotices
+	}
+	if input.ReminderDaysBefore != nil {
+		settings.ReminderDaysBefore = *input.ReminderDaysBefore
+	}
+
+	if err := h.Service.SaveSettings(ctx, landlordID, settings); err != nil {
+		if errors.Is(err, services.ErrInvalidSenderID) || errors.Is(err, services.ErrInvalidReminderDays) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("[%v] updateSMSSettings: save failed: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings", "trace_id": reqID})
+		return
+	}
+	middleware.AuditAfter(c, settings)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Settings saved successfully", "data": settings})
+}
+
+// ListSMSMessages returns the landlord's SMS log with delivery status.
+// Filters: tenant_id, status, template, limit (default 50, max 200).
+func (h *NotificationHandler) ListSMSMessages(c *gin.Context) {
+	h.listMessages(c, notify.ChannelSMS)
+}
+
+// ListEmailMessages returns the landlord's email log, filtered like ListSMSMessages
+func (h *NotificationHandler) ListEmailMessages(c *gin.Context) {
+	h.listMessages(c, notify.ChannelEmail)
+}
+
+func (h *NotificationHandler) listMessages(c *gin.Context, channel string) {
+	landlordID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	filter := services.NotificationFilter{
+		Channel:  channel,
+		Status:   c.Query("status"),
+		Template: c.Query("template"),
+	}
+	if v := c.Query("tenant_id"); v != "" {
+		if filter.TenantID, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant_id"})
+			return
+		}
+	}
+	filter.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || filter.Limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	if filter.Limit > 200 {
+		filter.Limit = 200
+	}
+
+	list, err := h.Service.ListNotifications(c.Request.Context(), landlordID, filter)
+	if err != nil {
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] listMessages(%s): %v", reqID, channel, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages", "trace_id": reqID})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// UpdatePreferencesInput changes only the fields that are present
+type UpdatePreferencesInput struct {
+	EmailPaymentReceipts   *bool `json:"email_payment_receipts"`
+	EmailMonthlyStatements *bool `json:"email_monthly_statements"`
+}
+
+// GetPreferences returns the current user's email preferences
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	prefs, err := h.Service.GetPreferences(c.Request.Context(), userID)
+	if err != nil {
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] getPreferences: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences", "trace_id": reqID})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": prefs})
+}
+
+// UpdatePreferences saves the current user's email preferences
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input UpdatePreferencesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reqID, _ := c.Get("request_id")
+	ctx := c.Request.Context()
+	prefs, err := h.Service.GetPreferences(ctx, userID)
+	if err != nil {
+		log.Printf("[%v] updatePreferences: load failed: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences", "trace_id": reqID})
+		return
+	}
+	if input.EmailPaymentReceipts != nil {
+		prefs.EmailPaymentReceipts = *input.EmailPaymentReceipts
+	}
+	if input.EmailMonthlyStatements != nil {
+		prefs.EmailMonthlyStatements = *input.EmailMonthlyStatements
+	}
+
+	if err := h.Service.SavePreferences(ctx, userID, prefs); err != nil {
+		log.Printf("[%v] updatePreferences: save failed: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save preferences", "trace_id": reqID})
+		return
+	}
+	middleware.AuditEntity(c, userID, nil)
+	middleware.AuditAfter(c, prefs)
+	c.JSON(http.StatusOK, gin.H{"message": "Preferences saved successfully", "data": prefs})
+}
+
+// DownloadTenantStatement renders a tenant's statement as a PDF.
+// Query: month=YYYY-MM (defaults to last month), or from and to
+// (YYYY-MM-DD, inclusive) for any other range.
+func (h *NotificationHandler) DownloadTenantStatement(c *gin.Context) {
+	tenantID, start, end, ok := h.statementRequest(c, permissions.PaymentsRead)
+	if !ok {
+		return
+	}
+
+	reqID, _ := c.Get("request_id")
+	statement, err := h.Service.TenantStatement(c.Request.Context(), tenantID, start, end)
+	if err != nil {
+		log.Printf("[%v] downloadTenantStatement: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate statement", "trace_id": reqID})
+		return
+	}
+	pdf, err := documents.StatementPDF(statement)
+	if err != nil {
+		log.Printf("[%v] downloadTenantStatement: render failed: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate statement", "trace_id": reqID})
+		return
+	}
+
+	filename := fmt.Sprintf("statement-%d-%s.pdf", tenantID, start.Format("2006-01"))
+	if c.Query("from") != "" || c.Query("to") != "" {
+		filename = fmt.Sprintf("statement-%d-%s-to-%s.pdf", tenantID, start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02"))
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, documents.ContentTypePDF, pdf)
+}
+
+// EmailTenantStatement queues a tenant's statement to their email address.
+// Query as for DownloadTenantStatement.
+func (h *NotificationHandler) EmailTenantStatement(c *gin.Context) {
+	tenantID, start, end, ok := h.statementRequest(c, permissions.TenantsWrite)
+	if !ok {
+		return
+	}
+
+	err := h.Service.EmailTenantStatement(c.Request.Context(), tenantID, start, end)
+	if errors.Is(err, services.ErrNoTenantEmail) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] emailTenantStatement: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue statement", "trace_id": reqID})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Statement queued for delivery"})
+}
+
+// tenantAccessQuery checks the tenant's unit is on a property the caller may
+// act on ($1 tenant, $2 user, $3 permission)
+const tenantAccessQuery = `
+	SELECT EXISTS(
+		SELECT 1 FROM tenants t
+		JOIN units u ON t.unit_id = u.id
+		WHERE t.id = $1 AND u.property_id IN (SELECT accessible_property_ids($2, $3))
+	)`
+
+// statementRequest checks access to the tenant and parses the month or
+// date range, writing the error response when it fails
+func (h *NotificationHandler) statementRequest(c *gin.Context, perm permissions.Permission) (int, time.Time, time.Time, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, time.Time{}, time.Time{}, false
+	}
+	tenantID, err := strconv.Atoi(c.Param("tenantId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return 0, time.Time{}, time.Time{}, false
+	}
+	var start, end time.Time
+	if c.Query("from") != "" || c.Query("to") != "" {
+		start, end, err = services.StatementRange(c.Query("from"), c.Query("to"))
+	} else {
+		start, end, err = services.StatementPeriod(c.Query("month"), time.Now())
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, time.Time{}, time.Time{}, false
+	}
+
+	var exists bool
+	err = h.Service.DB.QueryRow(tenantAccessQuery, tenantID, userID, perm).Scan(&exists)
+	if err != nil || !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found or unauthorized"})
+		return 0, time.Time{}, time.Time{}, false
+	}
+	return tenantID, start, end, true
+}
+
+// ListInbox returns the current user's in-app notifications with the unread
+// count. Query: unread=true, limit (default 50, max 200), offset.
+func (h *NotificationHandler) ListInbox(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	filter := services.InboxFilter{UnreadOnly: c.Query("unread") == "true"}
+	if filter.Limit, filter.Offset, err = pageParams(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reqID, _ := c.Get("request_id")
+	ctx := c.Request.Context()
+	list, err := h.Service.Inbox(ctx, userID, filter)
+	if err != nil {
+		log.Printf("[%v] listInbox: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications", "trace_id": reqID})
+		return
+	}
+	unread, err := h.Service.UnreadCount(ctx, userID)
+	if err != nil {
+		log.Printf("[%v] listInbox: unread count: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications", "trace_id": reqID})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list, "unread_count": unread})
+}
+
+// UnreadCount returns how many in-app notifications the current user has not read
+func (h *NotificationHandler) UnreadCount(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	unread, err := h.Service.UnreadCount(c.Request.Context(), userID)
+	if err != nil {
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] unreadCount: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications", "trace_id": reqID})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"unread_count": unread}})
+}
+
+// MarkRead marks one in-app notification as read
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	err = h.Service.MarkRead(c.Request.Context(), userID, id)
+	if errors.Is(err, services.ErrNotificationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	if err != nil {
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] markRead: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification", "trace_id": reqID})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+// MarkAllRead marks every unread in-app notification of the current user as read
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	n, err := h.Service.MarkAllRead(c.Request.Context(), userID)
+	if err != nil {
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] markAllRead: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications", "trace_id": reqID})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Notifications marked as read", "data": gin.H{"updated": n}})
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Inbox requests are validated before the service (here without a database)
+// is reached
+func TestInboxRequestValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewNotificationHandler(&services.NotificationService{})
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if c.GetHeader("X-User-ID") != "0" {
+			c.Set("user_id", 7)
+		}
+	})
+	r.GET("/notifications", h.ListInbox)
+	r.PATCH("/notifications/:id/read", h.MarkRead)
+
+	tests := []struct {
+		name   string
+		userID int
+		method string
+		path   string
+		want   int
+	}{
+		{"not signed in", 0, http.MethodGet, "/notifications", http.StatusUnauthorized},
+		{"bad limit", 7, http.MethodGet, "/notifications?limit=abc", http.StatusBadRequest},
+		{"zero limit", 7, http.MethodGet, "/notifications?limit=0", http.StatusBadRequest},
+		{"negative offset", 7, http.MethodGet, "/notifications?offset=-1", http.StatusBadRequest},
+		{"bad notification id", 7, http.MethodPatch, "/notifications/abc/read", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if got := call(t, r, tt.userID, tt.method, tt.path, nil, nil); got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
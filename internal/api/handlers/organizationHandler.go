@@ -0,0 +1,924 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/gin-gonic/gin"
+)
+
+type CreateOrganizationInput struct {
+	Name                 string  `json:"name" binding:"required"`
+	ManagementFeePercent float64 `json:"management_fee_percent" binding:"gte=0,lte=100"`
+}
+
+type UpdateOrganizationInput struct {
+	Name                 *string  `json:"name"`
+	ManagementFeePercent *float64 `json:"management_fee_percent" binding:"omitempty,gte=0,lte=100"`
+}
+
+type OrganizationInviteInput struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+type SetPropertyOrganizationInput struct {
+	OrganizationID *int `json:"organization_id"` // null hands management back to the owner
+}
+
+// orgMemberRole returns the caller's role within an organization, or sql.ErrNoRows if not a member
+func orgMemberRole(db *database.Database, orgID, userID int) (string, error) {
+	var role string
+	err := db.QueryRow("SELECT role FROM organization_members WHERE organization_id = $1 AND user_id = $2", orgID, userID).Scan(&role)
+	return role, err
+}
+
+// orgMemberPermissions resolves what a member may do on the organization's
+// properties from their org role and current account role
+func orgMemberPermissions(db *database.Database, orgID, userID int) ([]permissions.Permission, error) {
+	var role, accountRole string
+	err := db.QueryRow(`
+		SELECT m.role, u.role FROM organization_members m
+		JOIN users u ON m.user_id = u.id
+		WHERE m.organization_id = $1 AND m.user_id = $2`,
+		orgID, userID,
+	).Scan(&role, &accountRole)
+	if err != nil {
+		return nil, err
+	}
+	return permissions.ForOrgMember(role, accountRole), nil
+}
+
+// orgIDParam parses :orgId and loads the caller's membership role
+func orgIDParam(c *gin.Context, db *database.Database) (orgID, userID int, role string, ok bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, 0, "", false
+	}
+	orgID, err = strconv.Atoi(c.Param("orgId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return 0, 0, "", false
+	}
+	role, err = orgMemberRole(db, orgID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found or unauthorized"})
+		return 0, 0, "", false
+	}
+	return orgID, userID, role, true
+}
+
+// CreateOrganization creates an organization with the caller as its admin
+func CreateOrganization(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := middleware.GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var input CreateOrganizationInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		reqID, _ := c.Get("request_id")
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction start failed"})
+			return
+		}
+
+		var orgID int
+		var createdAt time.Time
+		err = tx.QueryRow(`
+			INSERT INTO organizations (name, management_fee_percent, created_by)
+			VALUES ($1, $2, $3)
+			RETURNING id, created_at`,
+			input.Name, input.ManagementFeePercent, userID,
+		).Scan(&orgID, &createdAt)
+		if err != nil {
+			tx.Rollback()
+			log.Printf("[%v] createOrganization: insert failed: %v", reqID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization", "trace_id": reqID})
+			return
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO organization_members (organization_id, user_id, role)
+			VALUES ($1, $2, $3)`,
+			orgID, userID, permissions.OrgRoleAdmin,
+		)
+		if err != nil {
+			tx.Rollback()
+			log.Printf("[%v] createOrganization: add admin failed: %v", reqID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization", "trace_id": reqID})
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Organization created successfully",
+			"data": gin.H{
+				"id":                     orgID,
+				"name":                   input.Name,
+				"management_fee_percent": input.ManagementFeePercent,
+				"role":                   permissions.OrgRoleAdmin,
+				"created_at":             createdAt,
+			},
+		})
+	}
+}
+
+// ListOrganizations returns the organizations the caller is a member of
+func ListOrganizations(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := middleware.GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT o.id, o.name, o.management_fee_percent, m.role, u.role, o.created_at,
+				(SELECT COUNT(*) FROM properties p WHERE p.organization_id = o.id)
+			FROM organizations o
+			JOIN organization_members m ON m.organization_id = o.id
+			JOIN users u ON m.user_id = u.id
+			WHERE m.user_id = $1
+			ORDER BY o.name`,
+			userID,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organizations"})
+			return
+		}
+		defer rows.Close()
+
+		orgs := []gin.H{}
+		for rows.Next() {
+			var o struct {
+				ID            int
+				Name          string
+				FeePercent    float64
+				Role          string
+				AccountRole   string
+				CreatedAt     time.Time
+				PropertyCount int
+			}
+			if err := rows.Scan(&o.ID, &o.Name, &o.FeePercent, &o.Role, &o.AccountRole, &o.CreatedAt, &o.PropertyCount); err != nil {
+				continue
+			}
+			orgs = append(orgs, gin.H{
+				"id":                     o.ID,
+				"name":                   o.Name,
+				"management_fee_percent": o.FeePercent,
+				"role":                   o.Role,
+				"permissions":            permissions.ForOrgMember(o.Role, o.AccountRole),
+				"property_count":         o.PropertyCount,
+				"created_at":             o.CreatedAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": orgs})
+	}
+}
+
+// GetOrganization returns an organization with its members, and for admins
+// the invitations still waiting to be accepted
+func GetOrganization(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, _, role, ok := orgIDParam(c, db)
+		if !ok {
+			return
+		}
+
+		var name string
+		var feePercent float64
+		var createdAt time.Time
+		err := db.QueryRow("SELECT name, management_fee_percent, created_at FROM organizations WHERE id = $1", orgID).Scan(&name, &feePercent, &createdAt)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT u.id, u.email, u.full_name, u.role, m.role, m.created_at
+			FROM organization_members m
+			JOIN users u ON m.user_id = u.id
+			WHERE m.organization_id = $1
+			ORDER BY m.created_at`,
+			orgID,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+			return
+		}
+		defer rows.Close()
+
+		members := []gin.H{}
+		for rows.Next() {
+			var m struct {
+				UserID      int
+				Email       string
+				FullName    string
+				AccountRole string
+				Role        string
+				JoinedAt    time.Time
+			}
+			if err := rows.Scan(&m.UserID, &m.Email, &m.FullName, &m.AccountRole, &m.Role, &m.JoinedAt); err != nil {
+				continue
+			}
+			members = append(members, gin.H{
+				"user_id":      m.UserID,
+				"email":        m.Email,
+				"full_name":    m.FullName,
+				"account_role": m.AccountRole,
+				"role":         m.Role,
+				"permissions":  permissions.ForOrgMember(m.Role, m.AccountRole),
+				"joined_at":    m.JoinedAt,
+			})
+		}
+
+		data := gin.H{
+			"id":                     orgID,
+			"name":                   name,
+			"management_fee_percent": feePercent,
+			"role":                   role,
+			"members":                members,
+			"created_at":             createdAt,
+		}
+		if role == permissions.OrgRoleAdmin {
+			invites, err := orgInvites(db, orgID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+				return
+			}
+			data["invites"] = invites
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": data})
+	}
+}
+
+// UpdateOrganization changes the name or management fee. Org admins only.
+func UpdateOrganization(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, _, role, ok := orgIDParam(c, db)
+		if !ok {
+			return
+		}
+		if role != permissions.OrgRoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only organization admins can update the organization"})
+			return
+		}
+
+		var input UpdateOrganizationInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		_, err := db.Exec(`
+			UPDATE organizations SET
+				name = COALESCE($1, name),
+				management_fee_percent = COALESCE($2, management_fee_percent),
+				updated_at = NOW()
+			WHERE id = $3`,
+			input.Name, input.ManagementFeePercent, orgID,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update organization"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Organization updated successfully"})
+	}
+}
+
+// InviteOrganizationMember invites an email address to the organization;
+// the account holder becomes a member once they accept. The response is the
+// same whether or not an account exists for the email. Org admins only.
+func InviteOrganizationMember(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, userID, role, ok := orgIDParam(c, db)
+		if !ok {
+			return
+		}
+		if role != permissions.OrgRoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only organization admins can manage members"})
+			return
+		}
+
+		var input OrganizationInviteInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !permissions.IsValidOrgRole(input.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization role"})
+			return
+		}
+		email := strings.ToLower(strings.TrimSpace(input.Email))
+
+		var inviteID int64
+		var createdAt time.Time
+		err := db.QueryRow(`
+			INSERT INTO organization_invites (organization_id, email, role, invited_by)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (organization_id, email) DO UPDATE SET
+				role = EXCLUDED.role,
+				invited_by = EXCLUDED.invited_by,
+				created_at = NOW()
+			RETURNING id, created_at`,
+			orgID, email, input.Role, userID,
+		).Scan(&inviteID, &createdAt)
+		if err != nil {
+			reqID, _ := c.Get("request_id")
+			log.Printf("[%v] inviteOrganizationMember: insert failed: %v", reqID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invite member", "trace_id": reqID})
+			return
+		}
+		middleware.AuditEntity(c, inviteID, nil)
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Invitation created; the user joins once they accept it",
+			"data": gin.H{
+				"id":         inviteID,
+				"email":      email,
+				"role":       input.Role,
+				"created_at": createdAt,
+			},
+		})
+	}
+}
+
+// RevokeOrganizationInvite withdraws an invitation that has not been
+// accepted. Org admins only.
+func RevokeOrganizationInvite(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, _, role, ok := orgIDParam(c, db)
+		if !ok {
+			return
+		}
+		if role != permissions.OrgRoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only organization admins can manage members"})
+			return
+		}
+
+		inviteID, err := strconv.ParseInt(c.Param("inviteId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+			return
+		}
+
+		res, err := db.Exec("DELETE FROM organization_invites WHERE id = $1 AND organization_id = $2", inviteID, orgID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+			return
+		}
+		middleware.AuditEntity(c, inviteID, nil)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+	}
+}
+
+// orgInvites lists an organization's pending invitations
+func orgInvites(db *database.Database, orgID int) ([]gin.H, error) {
+	rows, err := db.Query(`
+		SELECT id, email, role, created_at FROM organization_invites
+		WHERE organization_id = $1
+		ORDER BY created_at`,
+		orgID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []gin.H{}
+	for rows.Next() {
+		var id int64
+		var email, role string
+		var createdAt time.Time
+		if err := rows.Scan(&id, &email, &role, &createdAt); err != nil {
+			continue
+		}
+		invites = append(invites, gin.H{"id": id, "email": email, "role": role, "created_at": createdAt})
+	}
+	return invites, rows.Err()
+}
+
+// ListMyOrganizationInvites returns the invitations addressed to the caller's email
+func ListMyOrganizationInvites(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := middleware.GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT i.id, i.organization_id, o.name, i.role, inviter.full_name, i.created_at
+			FROM organization_invites i
+			JOIN organizations o ON o.id = i.organization_id
+			JOIN users u ON i.email = lower(u.email)
+			LEFT JOIN users inviter ON inviter.id = i.invited_by
+			WHERE u.id = $1
+			ORDER BY i.created_at DESC`,
+			userID,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invitations"})
+			return
+		}
+		defer rows.Close()
+
+		invites := []gin.H{}
+		for rows.Next() {
+			var id int64
+			var orgID int
+			var orgName, role string
+			var invitedBy sql.NullString
+			var createdAt time.Time
+			if err := rows.Scan(&id, &orgID, &orgName, &role, &invitedBy, &createdAt); err != nil {
+				continue
+			}
+			invites = append(invites, gin.H{
+				"id":                id,
+				"organization_id":   orgID,
+				"organization_name": orgName,
+				"role":              role,
+				"invited_by":        invitedBy.String,
+				"created_at":        createdAt,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": invites})
+	}
+}
+
+// AcceptOrganizationInvite makes the caller a member of the organization
+// that invited their email
+func AcceptOrganizationInvite(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := middleware.GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		inviteID, err := strconv.ParseInt(c.Param("inviteId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+			return
+		}
+
+		reqID, _ := c.Get("request_id")
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction start failed"})
+			return
+		}
+		defer tx.Rollback()
+
+		var orgID int
+		var role, accountRole string
+		err = tx.QueryRow(`
+			SELECT i.organization_id, i.role, u.role
+			FROM organization_invites i
+			JOIN users u ON i.email = lower(u.email)
+			WHERE i.id = $1 AND u.id = $2
+			FOR UPDATE OF i`,
+			inviteID, userID,
+		).Scan(&orgID, &role, &accountRole)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("[%v] acceptOrganizationInvite: lookup failed: %v", reqID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "trace_id": reqID})
+			return
+		}
+		if role == permissions.OrgRoleOwner && accountRole != permissions.RoleLandlord {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only landlord accounts can be organization owners"})
+			return
+		}
+
+		// An existing member keeps their role; the invitation is used up either way
+		_, err = tx.Exec(`
+			INSERT INTO organization_members (organization_id, user_id, role)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (organization_id, user_id) DO NOTHING`,
+			orgID, userID, role,
+		)
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM organization_invites WHERE id = $1", inviteID)
+		}
+		if err != nil {
+			log.Printf("[%v] acceptOrganizationInvite: join failed: %v", reqID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation", "trace_id": reqID})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+			return
+		}
+		middleware.AuditEntity(c, orgID, nil)
+
+		role, _ = orgMemberRole(db, orgID, userID)
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Invitation accepted",
+			"data": gin.H{
+				"organization_id": orgID,
+				"role":            role,
+				"permissions":     permissions.ForOrgMember(role, accountRole),
+			},
+		})
+	}
+}
+
+// DeclineOrganizationInvite discards an invitation addressed to the caller
+func DeclineOrganizationInvite(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := middleware.GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		inviteID, err := strconv.ParseInt(c.Param("inviteId"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+			return
+		}
+
+		res, err := db.Exec(`
+			DELETE FROM organization_invites i
+			USING users u
+			WHERE i.id = $1 AND u.id = $2 AND i.email = lower(u.email)`,
+			inviteID, userID,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decline invitation"})
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Invitation declined"})
+	}
+}
+
+// UpdateOrganizationMember changes a member's org role. Org admins only.
+func UpdateOrganizationMember(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, _, role, ok := orgIDParam(c, db)
+		if !ok {
+			return
+		}
+		if role != permissions.OrgRoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only organization admins can manage members"})
+			return
+		}
+
+		memberID, err := strconv.Atoi(c.Param("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var input struct {
+			Role string `json:"role" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !permissions.IsValidOrgRole(input.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization role"})
+			return
+		}
+
+		var currentRole, accountRole string
+		err = db.QueryRow(`
+			SELECT m.role, u.role FROM organization_members m
+			JOIN users u ON m.user_id = u.id
+			WHERE m.organization_id = $1 AND m.user_id = $2`,
+			orgID, memberID,
+		).Scan(&currentRole, &accountRole)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+			return
+		}
+		// Owners let the organization act on their own properties, which they
+		// only agree to by accepting an owner invitation
+		if input.Role == permissions.OrgRoleOwner && currentRole != permissions.OrgRoleOwner {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Owners join by accepting an owner invitation"})
+			return
+		}
+		if currentRole == permissions.OrgRoleAdmin && input.Role != permissions.OrgRoleAdmin && lastOrgAdmin(db, orgID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "An organization must keep at least one admin"})
+			return
+		}
+
+		_, err = db.Exec(`
+			UPDATE organization_members SET role = $1, updated_at = NOW()
+			WHERE organization_id = $2 AND user_id = $3`,
+			input.Role, orgID, memberID,
+		)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Member updated successfully",
+			"data":    gin.H{"user_id": memberID, "role": input.Role, "permissions": permissions.ForOrgMember(input.Role, accountRole)},
+		})
+	}
+}
+
+// RemoveOrganizationMember removes a member. Org admins only.
+func RemoveOrganizationMember(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, _, role, ok := orgIDParam(c, db)
+		if !ok {
+			return
+		}
+		if role != permissions.OrgRoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only organization admins can manage members"})
+			return
+		}
+
+		memberID, err := strconv.Atoi(c.Param("userId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		memberRole, err := orgMemberRole(db, orgID, memberID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+			return
+		}
+		if memberRole == permissions.OrgRoleAdmin && lastOrgAdmin(db, orgID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "An organization must keep at least one admin"})
+			return
+		}
+
+		_, err = db.Exec("DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2", orgID, memberID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+	}
+}
+
+// lastOrgAdmin reports whether the organization has a single admin left
+func lastOrgAdmin(db *database.Database, orgID int) bool {
+	var admins int
+	db.QueryRow("SELECT COUNT(*) FROM organization_members WHERE organization_id = $1 AND role = $2", orgID, permissions.OrgRoleAdmin).Scan(&admins)
+	return admins <= 1
+}
+
+// SetPropertyOrganization hands management of a property to an organization
+// the owner belongs to, or takes it back. Property owners only.
+func SetPropertyOrganization(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := middleware.GetUserID(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		propertyID, err := strconv.Atoi(c.Param("propertyId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property ID"})
+			return
+		}
+
+		var input SetPropertyOrganizationInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var owned bool
+		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM properties WHERE id = $1 AND landlord_id = $2)", propertyID, userID).Scan(&owned)
+		if err != nil || !owned {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found or unauthorized"})
+			return
+		}
+
+		if input.OrganizationID != nil {
+			if _, err := orgMemberRole(db, *input.OrganizationID, userID); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "You must be a member of the organization"})
+				return
+			}
+		}
+
+		auditBefore(c, db, propertySnapshotQuery, propertyID)
+
+		_, err = db.Exec("UPDATE properties SET organization_id = $1, updated_at = NOW() WHERE id = $2", input.OrganizationID, propertyID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update property"})
+			return
+		}
+
+		auditAfter(c, db, propertySnapshotQuery, propertyID)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Property management updated successfully",
+			"data":    gin.H{"property_id": propertyID, "organization_id": input.OrganizationID},
+		})
+	}
+}
+
+// GetOwnerStatements summarises rent collected per owner on the organization's
+// properties over a period, less the organization's management fee. Members
+// with payments:read see every owner; owners see only their own statement.
+// Query: from, to (YYYY-MM-DD, to inclusive; defaults to the current month), owner_id.
+func GetOwnerStatements(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgID, userID, _, ok := orgIDParam(c, db)
+		if !ok {
+			return
+		}
+
+		from, to, err := statementPeriod(c.Query("from"), c.Query("to"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var ownerFilter *int
+		if v := c.Query("owner_id"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid owner_id"})
+				return
+			}
+			ownerFilter = &id
+		}
+
+		perms, _ := orgMemberPermissions(db, orgID, userID)
+		if !slices.Contains(perms, permissions.PaymentsRead) {
+			if ownerFilter != nil && *ownerFilter != userID {
+				c.JSON(http.StatusForbidden, gin.H{"error": "forbidden: insufficient permissions"})
+				return
+			}
+			ownerFilter = &userID
+		}
+
+		var orgName string
+		var feePercent float64
+		err = db.QueryRow("SELECT name, management_fee_percent FROM organizations WHERE id = $1", orgID).Scan(&orgName, &feePercent)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+
+		// Per property totals; payments reach a property through tenant -> unit
+		rows, err := db.Query(`
+			SELECT p.landlord_id, u.full_name, u.email, p.id, p.title,
+				COALESCE(SUM(pay.amount), 0), COUNT(pay.id)
+			FROM properties p
+			JOIN users u ON p.landlord_id = u.id
+			LEFT JOIN units un ON un.property_id = p.id
+			LEFT JOIN tenants t ON t.unit_id = un.id
+			LEFT JOIN payments pay ON pay.tenant_id = t.id
+				AND pay.status = 'COMPLETED'
+				AND pay.created_at >= $2 AND pay.created_at < $3
+			WHERE p.organization_id = $1 AND ($4::INTEGER IS NULL OR p.landlord_id = $4)
+			GROUP BY p.landlord_id, u.full_name, u.email, p.id, p.title
+			ORDER BY u.full_name, p.title`,
+			orgID, from, to, ownerFilter,
+		)
+		if err != nil {
+			reqID, _ := c.Get("request_id")
+			log.Printf("[%v] getOwnerStatements: query failed: %v", reqID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build statements", "trace_id": reqID})
+			return
+		}
+		defer rows.Close()
+
+		type ownerStatement struct {
+			OwnerID    int
+			Name       string
+			Email      string
+			Collected  float64
+			Payments   int
+			Properties []gin.H
+		}
+		statements := []*ownerStatement{}
+		byOwner := map[int]*ownerStatement{}
+		for rows.Next() {
+			var ownerID, propertyID, count int
+			var name, email, title string
+			var collected float64
+			if err := rows.Scan(&ownerID, &name, &email, &propertyID, &title, &collected, &count); err != nil {
+				continue
+			}
+			s, exists := byOwner[ownerID]
+			if !exists {
+				s = &ownerStatement{OwnerID: ownerID, Name: name, Email: email, Properties: []gin.H{}}
+				byOwner[ownerID] = s
+				statements = append(statements, s)
+			}
+			s.Collected += collected
+			s.Payments += count
+			s.Properties = append(s.Properties, gin.H{
+				"property_id":    propertyID,
+				"property_title": title,
+				"collected":      collected,
+				"payment_count":  count,
+			})
+		}
+
+		data := []gin.H{}
+		for _, s := range statements {
+			fee := roundMoney(s.Collected * feePercent / 100)
+			data = append(data, gin.H{
+				"owner_id":       s.OwnerID,
+				"owner_name":     s.Name,
+				"owner_email":    s.Email,
+				"collected":      roundMoney(s.Collected),
+				"payment_count":  s.Payments,
+				"management_fee": fee,
+				"net_payable":    roundMoney(s.Collected - fee),
+				"properties":     s.Properties,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": gin.H{
+				"organization_id":        orgID,
+				"organization_name":      orgName,
+				"management_fee_percent": feePercent,
+				"from":                   from.Format("2006-01-02"),
+				"to":                     to.AddDate(0, 0, -1).Format("2006-01-02"),
+				"statements":             data,
+			},
+		})
+	}
+}
+
+// statementPeriod parses an inclusive YYYY-MM-DD range into [from, to).
+// Missing bounds default to the current month.
+func statementPeriod(fromStr, toStr string) (time.Time, time.Time, error) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 1, 0)
+
+	if fromStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", fromStr, time.Local)
+		if err != nil {
+			return from, to, errors.New("Invalid from date, expected YYYY-MM-DD")
+		}
+		from = t
+	}
+	if toStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", toStr, time.Local)
+		if err != nil {
+			return from, to, errors.New("Invalid to date, expected YYYY-MM-DD")
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		return from, to, errors.New("Invalid to date, expected YYYY-MM-DD")
+	}
+	return from, to, nil
+}
+
+// roundMoney rounds to cents
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,405 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/events"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/Zolet-hash/smart-rentals/internal/repository"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// rentalRouter serves the property, unit, tenant and payment routes over
+// store, acting as the user in the X-User-ID header
+func rentalRouter(store repository.Store) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		var userID int
+		fmt.Sscan(c.GetHeader("X-User-ID"), &userID)
+		c.Set("user_id", userID)
+	})
+
+	bus := events.NewBus()
+	propertySvc := services.NewPropertyService(store)
+	unitSvc := services.NewUnitService(store)
+	tenantSvc := services.NewTenantService(store, bus)
+
+	r.POST("/properties", CreateProperty(propertySvc))
+	r.GET("/properties/:propertyId", GetProperty(propertySvc))
+	r.PATCH("/properties/:propertyId", UpdateProperty(propertySvc))
+	r.DELETE("/properties/:propertyId", DeleteProperty(propertySvc))
+	r.POST("/properties/:propertyId/units", CreateUnit(unitSvc))
+	r.PATCH("/properties/:propertyId/units/:unitId", UpdateUnit(unitSvc))
+	r.POST("/units/:unitId/tenants", CreateTenant(tenantSvc))
+	r.GET("/tenants/:tenantId", GetTenant(tenantSvc))
+	r.DELETE("/tenants/:tenantId", RemoveTenant(tenantSvc))
+	r.GET("/payments", ListPayments(store))
+	r.POST("/payments/cash", RecordCashPayment(store, bus))
+	r.PATCH("/payments/:id/assign", AssignPayment(store, bus))
+	r.GET("/tenants/:tenantId/history", GetTenantHistory(store))
+	return r
+}
+
+// call makes a request as userID and decodes the JSON response into out
+// when it is not nil
+func call(t *testing.T, r *gin.Engine, userID int, method, path string, body, out interface{}) int {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", fmt.Sprint(userID))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: %v: %s", method, path, err, w.Body)
+		}
+	}
+	return w.Code
+}
+
+// rental is a landlord's property with one unit and one tenant
+type rental struct {
+	property, unit, tenant int
+}
+
+func newRental(t *testing.T, r *gin.Engine, landlordID int) rental {
+	t.Helper()
+	var created struct {
+		Data struct {
+			ID int `json:"id"`
+		} `json:"data"`
+	}
+	var rt rental
+	if code := call(t, r, landlordID, http.MethodPost, "/properties",
+		gin.H{"title": "Block A", "location": "Nairobi", "property_type": "apartment"}, &created); code != http.StatusCreated {
+		t.Fatalf("create property: %d", code)
+	}
+	rt.property = created.Data.ID
+	if code := call(t, r, landlordID, http.MethodPost, fmt.Sprintf("/properties/%d/units", rt.property),
+		gin.H{"unit_name": "A1", "unit_type": "1BR", "unit_price": 15000}, &created); code != http.StatusCreated {
+		t.Fatalf("create unit: %d", code)
+	}
+	rt.unit = created.Data.ID
+	if code := call(t, r, landlordID, http.MethodPost, fmt.Sprintf("/units/%d/tenants", rt.unit),
+		gin.H{"tenant_name": "Jane Wanjiru", "payment_no1": "0712 345 678", "rent": 15000}, &created); code != http.StatusCreated {
+		t.Fatalf("create tenant: %d", code)
+	}
+	rt.tenant = created.Data.ID
+	return rt
+}
+
+func landlords(store *repository.Memory) (owner, other int) {
+	a := store.AddUser(models.User{FullName: "Owner", Email: "owner@example.com", Role: permissions.RoleLandlord})
+	b := store.AddUser(models.User{FullName: "Other", Email: "other@example.com", Role: permissions.RoleLandlord})
+	return a.ID, b.ID
+}
+
+// ownershipStore is a store seeded with two landlords that the ownership
+// checks run against
+type ownershipStore struct {
+	repository.Store
+	owner, other int
+	// delegate lets a grantee act on a property with perms
+	delegate func(propertyID, granteeID int, perms ...permissions.Permission)
+}
+
+// eachStore runs the checks against the memory store and, when
+// TEST_DATABASE_URL names a migrated database, against Postgres, so both
+// enforce ownership the same way
+func eachStore(t *testing.T, checks func(t *testing.T, s ownershipStore)) {
+	t.Run("memory", func(t *testing.T) {
+		store := repository.NewMemory()
+		owner, other := landlords(store)
+		checks(t, ownershipStore{Store: store, owner: owner, other: other, delegate: store.Delegate})
+	})
+	t.Run("postgres", func(t *testing.T) {
+		url := os.Getenv("TEST_DATABASE_URL")
+		if url == "" {
+			t.Skip("TEST_DATABASE_URL not set")
+		}
+		checks(t, postgresStore(t, url))
+	})
+}
+
+// postgresStore adds two landlords to the database at url and removes them,
+// with everything they own, when the test ends
+func postgresStore(t *testing.T, url string) ownershipStore {
+	t.Helper()
+	db, err := database.NewDatabase(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+	store := repository.NewPostgres(db)
+	if err := store.SyncPermissions(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int64
+	t.Cleanup(func() {
+		for _, table := range []string{"payments", "tenants"} {
+			if _, err := db.ExecContext(ctx, "DELETE FROM "+table+" WHERE landlord_id = ANY($1)", pq.Array(ids)); err != nil {
+				t.Errorf("clean up %s: %v", table, err)
+			}
+		}
+		if _, err := db.ExecContext(ctx, "DELETE FROM users WHERE id = ANY($1)", pq.Array(ids)); err != nil {
+			t.Errorf("clean up users: %v", err)
+		}
+	})
+	for _, name := range []string{"Owner", "Other"} {
+		var id int64
+		email := fmt.Sprintf("%s-%d@example.com", strings.ToLower(name), time.Now().UnixNano())
+		err := db.QueryRowContext(ctx,
+			"INSERT INTO users (email, password_hash, full_name, role) VALUES ($1, '', $2, $3) RETURNING id",
+			email, name, permissions.RoleLandlord).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	delegate := func(propertyID, granteeID int, perms ...permissions.Permission) {
+		granted := make([]string, len(perms))
+		for i, p := range perms {
+			granted[i] = string(p)
+		}
+		_, err := db.ExecContext(ctx, `
+			INSERT INTO property_delegations (landlord_id, grantee_id, property_id, permissions)
+			SELECT landlord_id, $2, id, $3 FROM properties WHERE id = $1`,
+			propertyID, granteeID, pq.Array(granted))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ownershipStore{Store: store, owner: int(ids[0]), other: int(ids[1]), delegate: delegate}
+}
+
+func TestPropertyOwnership(t *testing.T) {
+	eachStore(t, func(t *testing.T, store ownershipStore) {
+		owner, other := store.owner, store.other
+		r := rentalRouter(store)
+		rt := newRental(t, r, owner)
+		path := fmt.Sprintf("/properties/%d", rt.property)
+
+		for _, req := range []struct {
+			method string
+			body   interface{}
+		}{{http.MethodGet, nil}, {http.MethodPatch, gin.H{"title": "Mine now"}}, {http.MethodDelete, nil}} {
+			if code := call(t, r, other, req.method, path, req.body, nil); code != http.StatusNotFound {
+				t.Errorf("%s by another landlord: got %d, want 404", req.method, code)
+			}
+		}
+		if code := call(t, r, owner, http.MethodGet, path, nil, nil); code != http.StatusOK {
+			t.Errorf("GET by owner: got %d, want 200", code)
+		}
+
+		// A delegation grants what it names and nothing more
+		store.delegate(rt.property, other, permissions.PropertiesRead)
+		if code := call(t, r, other, http.MethodGet, path, nil, nil); code != http.StatusOK {
+			t.Errorf("GET by delegate: got %d, want 200", code)
+		}
+		if code := call(t, r, other, http.MethodPatch, path, gin.H{"title": "Mine now"}, nil); code != http.StatusNotFound {
+			t.Errorf("PATCH by read-only delegate: got %d, want 404", code)
+		}
+
+		// Properties with tenants cannot be deleted
+		if code := call(t, r, owner, http.MethodDelete, path, nil, nil); code != http.StatusConflict {
+			t.Errorf("DELETE with a tenant: got %d, want 409", code)
+		}
+	})
+}
+
+func TestOrganizationPropertyOwner(t *testing.T) {
+	store := repository.NewMemory()
+	admin, victim := landlords(store)
+	r := rentalRouter(store)
+	const orgID = 500
+	store.AddMember(orgID, admin, permissions.OrgRoleAdmin)
+
+	body := gin.H{"title": "Block B", "location": "Nakuru", "property_type": "apartment", "organization_id": orgID, "owner_id": victim}
+	if code := call(t, r, admin, http.MethodPost, "/properties", body, nil); code != http.StatusBadRequest {
+		t.Fatalf("property for an owner who never joined: got %d, want 400", code)
+	}
+
+	store.AddMember(orgID, victim, permissions.OrgRoleOwner)
+	var created struct {
+		Data models.Property `json:"data"`
+	}
+	if code := call(t, r, admin, http.MethodPost, "/properties", body, &created); code != http.StatusCreated {
+		t.Fatalf("property for an accepted owner: got %d, want 201", code)
+	}
+	if int(created.Data.LandlordID) != victim {
+		t.Errorf("landlord_id = %d, want the owner %d", created.Data.LandlordID, victim)
+	}
+	if code := call(t, r, admin, http.MethodGet, fmt.Sprintf("/properties/%d", created.Data.ID), nil, nil); code != http.StatusOK {
+		t.Errorf("GET by org admin: got %d, want 200", code)
+	}
+}
+
+func TestUnitOwnership(t *testing.T) {
+	eachStore(t, func(t *testing.T, store ownershipStore) {
+		owner, other := store.owner, store.other
+		r := rentalRouter(store)
+		rt := newRental(t, r, owner)
+
+		unit := gin.H{"unit_name": "B1", "unit_type": "studio", "unit_price": 9000}
+		if code := call(t, r, other, http.MethodPost, fmt.Sprintf("/properties/%d/units", rt.property), unit, nil); code != http.StatusNotFound {
+			t.Errorf("unit on another landlord's property: got %d, want 404", code)
+		}
+		path := fmt.Sprintf("/properties/%d/units/%d", rt.property, rt.unit)
+		if code := call(t, r, other, http.MethodPatch, path, gin.H{"unit_price": 1}, nil); code != http.StatusNotFound {
+			t.Errorf("PATCH by another landlord: got %d, want 404", code)
+		}
+
+		// A unit is addressed through its own property only
+		otherRental := newRental(t, r, other)
+		path = fmt.Sprintf("/properties/%d/units/%d", otherRental.property, rt.unit)
+		if code := call(t, r, other, http.MethodPatch, path, gin.H{"unit_price": 1}, nil); code != http.StatusNotFound {
+			t.Errorf("PATCH through another property: got %d, want 404", code)
+		}
+	})
+}
+
+func TestTenantOwnership(t *testing.T) {
+	eachStore(t, func(t *testing.T, store ownershipStore) {
+		owner, other := store.owner, store.other
+		r := rentalRouter(store)
+		rt := newRental(t, r, owner)
+
+		tenant := gin.H{"tenant_name": "John Otieno", "payment_no1": "0722000111", "rent": 9000}
+		if code := call(t, r, other, http.MethodPost, fmt.Sprintf("/units/%d/tenants", rt.unit), tenant, nil); code != http.StatusNotFound {
+			t.Errorf("tenant on another landlord's unit: got %d, want 404", code)
+		}
+		path := fmt.Sprintf("/tenants/%d", rt.tenant)
+		for _, method := range []string{http.MethodGet, http.MethodDelete} {
+			if code := call(t, r, other, method, path, nil, nil); code != http.StatusNotFound {
+				t.Errorf("%s by another landlord: got %d, want 404", method, code)
+			}
+		}
+
+		var got models.TenantWithUnit
+		if code := call(t, r, owner, http.MethodGet, path, nil, &got); code != http.StatusOK {
+			t.Fatalf("GET by owner: got %d, want 200", code)
+		}
+		// Stored the way M-Pesa reports payers, so callbacks match it
+		if got.PaymentNo1 != "254712345678" {
+			t.Errorf("payment_no1 = %q, want 254712345678", got.PaymentNo1)
+		}
+		if got.Balance != 15000 {
+			t.Errorf("balance = %v, want a month's rent", got.Balance)
+		}
+	})
+}
+
+func TestPaymentOwnership(t *testing.T) {
+	eachStore(t, func(t *testing.T, store ownershipStore) {
+		owner, other := store.owner, store.other
+		r := rentalRouter(store)
+		rt := newRental(t, r, owner)
+
+		cash := gin.H{"tenant_id": rt.tenant, "amount": 5000}
+		if code := call(t, r, other, http.MethodPost, "/payments/cash", cash, nil); code != http.StatusNotFound {
+			t.Errorf("cash for another landlord's tenant: got %d, want 404", code)
+		}
+		if code := call(t, r, owner, http.MethodPost, "/payments/cash", cash, nil); code != http.StatusCreated {
+			t.Fatalf("cash by owner: got %d, want 201", code)
+		}
+
+		var page struct {
+			Data []gin.H `json:"data"`
+		}
+		if code := call(t, r, other, http.MethodGet, "/payments", nil, &page); code != http.StatusOK || len(page.Data) != 0 {
+			t.Errorf("another landlord's payments: got %d with %d payments, want 200 with none", code, len(page.Data))
+		}
+		if code := call(t, r, owner, http.MethodGet, "/payments", nil, &page); code != http.StatusOK || len(page.Data) != 1 {
+			t.Errorf("owner's payments: got %d with %d payments, want 200 with one", code, len(page.Data))
+		}
+		history := fmt.Sprintf("/tenants/%d/history", rt.tenant)
+		if code := call(t, r, other, http.MethodGet, history, nil, nil); code != http.StatusNotFound {
+			t.Errorf("history by another landlord: got %d, want 404", code)
+		}
+
+		// An unassigned payment of one landlord cannot be moved to another's tenant
+		unassigned := models.Payment{LandlordID: uint(owner), Amount: 700, Status: "PENDING", Method: "MPESA_PAYBILL", Receipt: "QAB123"}
+		if err := store.Payments().Create(context.Background(), &unassigned); err != nil {
+			t.Fatal(err)
+		}
+		otherRental := newRental(t, r, other)
+		assign := fmt.Sprintf("/payments/%d/assign", unassigned.ID)
+		if code := call(t, r, other, http.MethodPatch, assign, gin.H{"tenant_id": otherRental.tenant}, nil); code != http.StatusNotFound {
+			t.Errorf("assigning another landlord's payment: got %d, want 404", code)
+		}
+		if code := call(t, r, owner, http.MethodPatch, assign, gin.H{"tenant_id": rt.tenant}, nil); code != http.StatusOK {
+			t.Errorf("assigning by owner: got %d, want 200", code)
+		}
+	})
+}
+
+// failingBalances is a Store whose balance updates fail
+type failingBalances struct{ repository.Store }
+
+type failingTenants struct{ repository.TenantRepo }
+
+var errBalance = errors.New("balance update failed")
+
+func (s failingBalances) Tenants() repository.TenantRepo {
+	return failingTenants{s.Store.Tenants()}
+}
+
+func (s failingBalances) WithTx(ctx context.Context, fn func(tx repository.Store) error) error {
+	return s.Store.WithTx(ctx, func(tx repository.Store) error {
+		return fn(failingBalances{tx})
+	})
+}
+
+func (failingTenants) AdjustBalance(ctx context.Context, id int, delta float64) error {
+	return errBalance
+}
+
+func TestRecordCashPaymentRollsBack(t *testing.T) {
+	store := repository.NewMemory()
+	owner, _ := landlords(store)
+	rt := newRental(t, rentalRouter(store), owner)
+
+	r := rentalRouter(failingBalances{store})
+	if code := call(t, r, owner, http.MethodPost, "/payments/cash", gin.H{"tenant_id": rt.tenant, "amount": 5000}, nil); code != http.StatusInternalServerError {
+		t.Fatalf("cash with a failing balance update: got %d, want 500", code)
+	}
+
+	// The payment written before the balance update went with the transaction
+	var page struct {
+		Data []gin.H `json:"data"`
+	}
+	if code := call(t, r, owner, http.MethodGet, "/payments", nil, &page); code != http.StatusOK || len(page.Data) != 0 {
+		t.Errorf("payments after rollback: got %d with %d payments, want 200 with none", code, len(page.Data))
+	}
+	tenant, err := store.Tenants().Get(context.Background(), owner, permissions.TenantsRead, rt.tenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tenant.Balance != 15000 {
+		t.Errorf("balance after rollback = %v, want 15000", tenant.Balance)
+	}
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/notify"
+	"github.com/Zolet-hash/smart-rentals/internal/pkg/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type ForgotPasswordInput struct {
+	Email   string `json:"email" binding:"required,email"`
+	Channel string `json:"channel" binding:"omitempty,oneof=email sms"` // defaults to email
+}
+
+type CompletePasswordResetInput struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// forgotPasswordResponse is returned whether or not the account exists so
+// the endpoint cannot be used to enumerate registered emails
+var forgotPasswordResponse = gin.H{
+	"message": "If an account with that email exists, password reset instructions have been sent",
+}
+
+// ForgotPassword issues a single-use reset token and sends it to the user
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var input ForgotPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A valid email is required"})
+		return
+	}
+	if input.Channel == "" {
+		input.Channel = notify.ChannelEmail
+	}
+
+	reqID, _ := c.Get("request_id")
+
+	var userID int
+	var email string
+	var phone sql.NullString
+	err := h.db.DB.QueryRow("SELECT id, email, phone FROM users WHERE email = $1", input.Email).Scan(&userID, &email, &phone)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusOK, forgotPasswordResponse)
+		return
+	}
+	if err != nil {
+		log.Printf("[%v] forgotPassword: db error: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "trace_id": reqID})
+		return
+	}
+
+	recipient := email
+	if input.Channel == notify.ChannelSMS {
+		if !phone.Valid || phone.String == "" {
+			// Nothing to send to; respond the same way to avoid leaking account details
+			c.JSON(http.StatusOK, forgotPasswordResponse)
+			return
+		}
+		recipient = phone.String
+	}
+
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+		return
+	}
+
+	tx, err := h.db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction start failed"})
+		return
+	}
+
+	// Only the latest token is valid: supersede any outstanding ones
+	_, err = tx.Exec("UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL", userID)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("[%v] forgotPassword: supersede tokens failed: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "trace_id": reqID})
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO password_reset_tokens (user_id, token_hash, channel, expires_at)
+		VALUES ($1, $2, $3, $4)`,
+		userID, utils.HashToken(token), input.Channel, time.Now().Add(h.passwordResetTTL),
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("[%v] forgotPassword: insert token failed: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "trace_id": reqID})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		return
+	}
+
+	msg := notify.Message{
+		Channel: input.Channel,
+		To:      recipient,
+		Subject: "Reset your Smart Rentals password",
+		Body:    h.passwordResetBody(token),
+	}
+	if err := h.notifier.Send(c.Request.Context(), msg); err != nil {
+		log.Printf("[%v] forgotPassword: delivery failed for user %d: %v", reqID, userID, err)
+	}
+
+	c.JSON(http.StatusOK, forgotPasswordResponse)
+}
+
+// passwordResetBody builds the reset message, linking to the frontend when configured
+func (h *AuthHandler) passwordResetBody(token string) string {
+	minutes := int(h.passwordResetTTL.Minutes())
+	if h.frontendURL == "" {
+		return fmt.Sprintf("Your password reset code is %s. It expires in %d minutes.", token, minutes)
+	}
+	link := h.frontendURL + "/reset-password?token=" + url.QueryEscape(token)
+	return fmt.Sprintf("Reset your password using this link: %s (expires in %d minutes). If you did not request this, ignore this message.", link, minutes)
+}
+
+// CompletePasswordReset sets a new password using a token from ForgotPassword
+func (h *AuthHandler) CompletePasswordReset(c *gin.Context) {
+	var input CompletePasswordResetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Token and new password are required"})
+		return
+	}
+
+	if err := utils.ValidatePassword(input.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reqID, _ := c.Get("request_id")
+
+	hashedPassword, err := utils.HashPassword(input.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password processing failed"})
+		return
+	}
+
+	tx, err := h.db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction start failed"})
+		return
+	}
+
+	// Lock the token row so concurrent requests cannot both consume it
+	var userID int
+	err = tx.QueryRow(`
+		SELECT user_id FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		FOR UPDATE`,
+		utils.HashToken(input.Token),
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if err != nil {
+		tx.Rollback()
+		log.Printf("[%v] completePasswordReset: token lookup failed: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "trace_id": reqID})
+		return
+	}
+
+	// Update password and revoke existing sessions
+	_, err = tx.Exec(`
+		UPDATE users
+		SET password_hash = $1, token_version = token_version + 1, updated_at = NOW()
+		WHERE id = $2`,
+		hashedPassword, userID,
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("[%v] completePasswordReset: update password failed: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password", "trace_id": reqID})
+		return
+	}
+
+	// Consume this and any other outstanding tokens for the user
+	_, err = tx.Exec("UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL", userID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		return
+	}
+
+	middleware.AuditEntity(c, userID, nil)
+	log.Printf("Password reset via token for user ID: %d", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully, please log in with your new password"})
+}
+
+// ChangePassword lets a logged-in user change their password. Other sessions
+// are revoked and a fresh token is returned for the current client.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	middleware.AuditEntity(c, userID, nil)
+
+	var input ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Current and new password are required"})
+		return
+	}
+
+	if err := utils.ValidatePassword(input.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reqID, _ := c.Get("request_id")
+
+	var email, passwordHash string
+	err = h.db.DB.QueryRow("SELECT email, password_hash FROM users WHERE id = $1", userID).Scan(&email, &passwordHash)
+	if err != nil {
+		log.Printf("[%v] changePassword: db error: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "trace_id": reqID})
+		return
+	}
+
+	if !utils.CheckPasswordHash(input.CurrentPassword, passwordHash) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	if input.CurrentPassword == input.NewPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New password must be different from the current password"})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(input.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password processing failed"})
+		return
+	}
+
+	var tokenVersion int
+	err = h.db.DB.QueryRow(`
+		UPDATE users
+		SET password_hash = $1, token_version = token_version + 1, updated_at = NOW()
+		WHERE id = $2
+		RETURNING token_version`,
+		hashedPassword, userID,
+	).Scan(&tokenVersion)
+	if err != nil {
+		log.Printf("[%v] changePassword: update failed: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password", "trace_id": reqID})
+		return
+	}
+
+	tokenString, err := h.issueToken(userID, email, tokenVersion, c.GetBool("mfa"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Password changed successfully",
+		"token":      tokenString,
+		"expires_in": h.tokenExpiration.Seconds(),
+		"token_type": "Bearer",
+	})
+}
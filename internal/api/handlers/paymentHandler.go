@@ -1,12 +1,15 @@
 package handlers
 
 import (
-	"database/sql"
 	"net/http"
 	"time"
 
 	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
-	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/events"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/Zolet-hash/smart-rentals/internal/repository"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,73 +17,67 @@ type CashPaymentInput struct {
 	TenantID int     `json:"tenant_id" binding:"required"`
 	Amount   float64 `json:"amount" binding:"required"`
 	Receipt  string  `json:"receipt"`
+	Category string  `json:"category"` // defaults to rent
 }
 
 type AssignPaymentInput struct {
 	TenantID int `json:"tenant_id" binding:"required"`
 }
 
-func ListPayments(db *database.Database) gin.HandlerFunc {
+type PaymentCategoryInput struct {
+	Category string `json:"category" binding:"required"`
+}
+
+// ListPayments lists payments a page at a time. Query: status, method,
+// property_id, from, to (YYYY-MM-DD, inclusive), min_amount, max_amount, q
+// (tenant name, reference or receipt number), sort (created_at, amount;
+// prefix - for descending), limit, cursor, include_total.
+func ListPayments(store repository.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		landlordID, err := middleware.GetUserID(c)
+		userID, err := middleware.GetUserID(c)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
+		page, filter, ok := listParams(c, repository.PaymentList)
+		if !ok {
+			return
+		}
 
-		// Lists payments linked to landlord's properties/tenants
-		query := `
-			SELECT p.id, p.tenant_id, t.tenant_name, p.amount, p.status, p.created_at, p.method, p.receipt
-			FROM payments p
-			LEFT JOIN tenants t ON p.tenant_id = t.id
-			WHERE p.landlord_id = $1
-			ORDER BY p.created_at DESC
-		`
-
-		rows, err := db.Query(query, landlordID)
+		// Lists payments of tenants on accessible properties, plus unassigned
+		// payments of the landlords owning those properties
+		payments, err := store.Payments().List(c.Request.Context(), userID, permissions.PaymentsRead, page, filter)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch payments"})
+			listError(c, "listPayments", "Failed to fetch payments", err)
 			return
 		}
-		defer rows.Close()
-
-		payments := []gin.H{}
-		for rows.Next() {
-			var p struct {
-				ID         int
-				TenantID   sql.NullInt64
-				TenantName sql.NullString
-				Amount     float64
-				Status     string
-				CreatedAt  time.Time
-				Method     string
-				Receipt    string
+		writePage(c, mapPage(payments, func(p models.PaymentWithTenant) gin.H {
+			var tenantID uint // 0 when unassigned
+			if p.TenantID != nil {
+				tenantID = *p.TenantID
 			}
-			if err := rows.Scan(&p.ID, &p.TenantID, &p.TenantName, &p.Amount, &p.Status, &p.CreatedAt, &p.Method, &p.Receipt); err != nil {
-				continue
-			}
-			payments = append(payments, gin.H{
+			return gin.H{
 				"id":             p.ID,
-				"tenant_id":      p.TenantID.Int64,
-				"tenant_name":    p.TenantName.String,
+				"tenant_id":      tenantID,
+				"tenant_name":    p.TenantName,
 				"amount":         p.Amount,
 				"status":         p.Status,
 				"created_at":     p.CreatedAt,
 				"payment_date":   p.CreatedAt,
 				"payment_method": p.Method,
 				"method":         p.Method,
+				"category":       p.Category,
 				"transaction_id": p.Receipt,
 				"reference":      p.Receipt,
-			})
-		}
-
-		c.JSON(http.StatusOK, gin.H{"data": payments})
+				"receipt_no":     p.ReceiptNo,
+			}
+		}))
 	}
 }
 
-func RecordCashPayment(db *database.Database) gin.HandlerFunc {
+func RecordCashPayment(store repository.Store, publisher events.Publisher) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		landlordID, err := middleware.GetUserID(c)
+		userID, err := middleware.GetUserID(c)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
@@ -91,177 +88,209 @@ func RecordCashPayment(db *database.Database) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-
-		// Verify Tenant Ownership
-		var exists bool
-		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM tenants WHERE id = $1 AND landlord_id = $2)", input.TenantID, landlordID).Scan(&exists)
-		if err != nil || !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found or unauthorized"})
+		if input.Category == "" {
+			input.Category = "rent"
+		}
+		if !services.ValidPaymentCategory(input.Category) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidPaymentCategory.Error()})
 			return
 		}
 
-		// Transaction: Insert Payment + Update Tenant Balance
-		tx, err := db.Begin()
+		// Verify Tenant Access; the payment belongs to the tenant's landlord
+		ctx := c.Request.Context()
+		tenant, err := store.Tenants().Get(ctx, userID, permissions.PaymentsRecordCash, input.TenantID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			storeError(c, "recordCashPayment", "Tenant not found or unauthorized", "Failed to record payment", err)
 			return
 		}
 
-		// Insert Payment
-		query := `
-			INSERT INTO payments (landlord_id, tenant_id, amount, status, method, receipt)
-			VALUES ($1, $2, $3, 'COMPLETED', 'CASH', $4)
-			RETURNING id
-		`
-		var paymentID int
 		receipt := input.Receipt
 		if receipt == "" {
 			receipt = "CASH-" + time.Now().Format("20060102150405")
 		}
-		err = tx.QueryRow(query, landlordID, input.TenantID, input.Amount, receipt).Scan(&paymentID)
-		if err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record payment"})
-			return
+		recordedBy := uint(userID)
+		payment := models.Payment{
+			LandlordID: tenant.LandlordID,
+			TenantID:   &tenant.ID,
+			Amount:     input.Amount,
+			Status:     "COMPLETED",
+			Method:     "CASH",
+			Category:   input.Category,
+			Receipt:    receipt,
+			RecordedBy: &recordedBy,
 		}
 
-		// Update Tenant Balance (Decrease balance by amount paid)
-		_, err = tx.Exec("UPDATE tenants SET balance = balance - $1 WHERE id = $2", input.Amount, input.TenantID)
+		// Transaction: Insert Payment + Decrease tenant balance by amount paid
+		err = store.WithTx(ctx, func(tx repository.Store) error {
+			if err := tx.Payments().Create(ctx, &payment); err != nil {
+				return err
+			}
+			return tx.Tenants().AdjustBalance(ctx, input.TenantID, -input.Amount)
+		})
 		if err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tenant balance"})
+			storeError(c, "recordCashPayment", "Tenant not found or unauthorized", "Failed to record payment", err)
 			return
 		}
 
-		if err := tx.Commit(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
-			return
-		}
+		auditAfterRecord(c, store.Payments(), int(payment.ID))
+		publisher.Publish(ctx, events.New(events.PaymentCompleted, int(tenant.LandlordID), gin.H{
+			"payment_id": payment.ID,
+			"tenant_id":  input.TenantID,
+			"amount":     input.Amount,
+			"receipt":    receipt,
+			"method":     "CASH",
+			"category":   input.Category,
+			"status":     "COMPLETED",
+		}))
 
 		c.JSON(http.StatusCreated, gin.H{
 			"message":    "Payment recorded successfully",
-			"payment_id": paymentID,
+			"payment_id": payment.ID,
+			"receipt_no": payment.ReceiptNo,
 		})
 	}
 }
 
-func AssignPayment(db *database.Database) gin.HandlerFunc {
+func AssignPayment(store repository.Store, publisher events.Publisher) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		landlordID, err := middleware.GetUserID(c)
+		userID, err := middleware.GetUserID(c)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
+		paymentID, ok := intParam(c, "id", "Invalid payment ID")
+		if !ok {
+			return
+		}
 
-		paymentID := c.Param("id")
 		var input AssignPaymentInput
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// Verify Tenant Ownership
-		var exists bool
-		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM tenants WHERE id = $1 AND landlord_id = $2)", input.TenantID, landlordID).Scan(&exists)
-		if err != nil || !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found or unauthorized"})
+		// Verify Tenant Access
+		ctx := c.Request.Context()
+		tenant, err := store.Tenants().Get(ctx, userID, permissions.PaymentsAssign, input.TenantID)
+		if err != nil {
+			storeError(c, "assignPayment", "Tenant not found or unauthorized", "Failed to assign payment", err)
 			return
 		}
+		landlordID := int(tenant.LandlordID)
 
-		// Transaction
-		tx, err := db.Begin()
+		// Meant for unassigned payments: a payment moved from another tenant
+		// keeps that tenant's balance as it was
+		var amount float64
+		err = store.WithTx(ctx, func(tx repository.Store) error {
+			// Lock the payment; it must belong to the tenant's landlord
+			payment, err := tx.Payments().GetForUpdate(ctx, landlordID, paymentID)
+			if err != nil {
+				return err
+			}
+			amount = payment.Amount
+			auditBeforeRecord(c, tx.Payments(), paymentID)
+
+			if err := tx.Payments().Assign(ctx, paymentID, input.TenantID, userID); err != nil {
+				return err
+			}
+			return tx.Tenants().AdjustBalance(ctx, input.TenantID, -amount)
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			storeError(c, "assignPayment", "Payment not found", "Failed to assign payment", err)
 			return
 		}
 
-		// Assign Payment (Verify it's unassigned? Or re-assignable? Assuming unassigned logic or just overwriting)
-		// Also verify payment logic - usually we just update tenant_id.
-		// BUT we must also update balances.
-		// Complexity: If it was assigned to someone else, we must revert that balance.
-		// Simplified: Assume strictly for assigning 'Unassigned' payments.
+		auditAfterRecord(c, store.Payments(), paymentID)
+		assigned := gin.H{
+			"payment_id": paymentID,
+			"tenant_id":  input.TenantID,
+			"amount":     amount,
+			"status":     "COMPLETED",
+			"assigned":   true,
+		}
+		publisher.Publish(ctx, events.New(events.PaymentCompleted, landlordID, assigned))
+		publisher.Publish(ctx, events.New(events.PaymentMatched, landlordID, assigned))
 
-		var amount float64
-		// Fetch payment details and lock
-		err = tx.QueryRow("SELECT amount FROM payments WHERE id = $1 FOR UPDATE", paymentID).Scan(&amount)
+		c.JSON(http.StatusOK, gin.H{"message": "Payment assigned successfully"})
+	}
+}
+
+// SetPaymentCategory records what a payment was for, e.g. a deposit rather
+// than rent, which keeps it out of Monthly Rental Income tax
+func SetPaymentCategory(store repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := middleware.GetUserID(c)
 		if err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusNotFound, gin.H{"error": "Payment not found"})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		paymentID, ok := intParam(c, "id", "Invalid payment ID")
+		if !ok {
 			return
 		}
 
-		// Assign
-		_, err = tx.Exec("UPDATE payments SET tenant_id = $1, status = 'COMPLETED' WHERE id = $2", input.TenantID, paymentID)
-		if err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign payment"})
+		var input PaymentCategoryInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !services.ValidPaymentCategory(input.Category) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidPaymentCategory.Error()})
 			return
 		}
 
-		// Update Balance
-		_, err = tx.Exec("UPDATE tenants SET balance = balance - $1 WHERE id = $2", amount, input.TenantID)
-		if err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tenant balance"})
+		// Payments of tenants on accessible properties, or unassigned
+		// payments of their landlords
+		ctx := c.Request.Context()
+		if _, err := store.Payments().Get(ctx, userID, permissions.PaymentsAssign, paymentID); err != nil {
+			storeError(c, "setPaymentCategory", "Payment not found", "Failed to update payment", err)
 			return
 		}
+		auditBeforeRecord(c, store.Payments(), paymentID)
 
-		if err := tx.Commit(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		if err := store.Payments().SetCategory(ctx, paymentID, input.Category); err != nil {
+			storeError(c, "setPaymentCategory", "Payment not found", "Failed to update payment", err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Payment assigned successfully"})
+		auditAfterRecord(c, store.Payments(), paymentID)
+		c.JSON(http.StatusOK, gin.H{"message": "Payment category updated", "category": input.Category})
 	}
 }
 
-func GetTenantHistory(db *database.Database) gin.HandlerFunc {
+// GetTenantHistory lists a tenant's payments a page at a time. Query:
+// status, method, from, to (YYYY-MM-DD, inclusive), min_amount, max_amount,
+// sort (date, amount; prefix - for descending), limit, cursor, include_total.
+func GetTenantHistory(store repository.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		landlordID, err := middleware.GetUserID(c)
+		userID, err := middleware.GetUserID(c)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
-
-		tenantID := c.Param("tenantId")
-
-		// Verify Ownership
-		var exists bool
-		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM tenants WHERE id = $1 AND landlord_id = $2)", tenantID, landlordID).Scan(&exists)
-		if err != nil || !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found or unauthorized"})
+		tenantID, ok := intParam(c, "tenantId", "Invalid tenant ID")
+		if !ok {
 			return
 		}
 
-		// Fetch history (Payments)
-		query := `
-			SELECT id, amount, status, created_at, method, receipt
-			FROM payments
-			WHERE tenant_id = $1
-			ORDER BY created_at DESC
-		`
+		// Verify Access
+		ctx := c.Request.Context()
+		if _, err := store.Tenants().Get(ctx, userID, permissions.PaymentsRead, tenantID); err != nil {
+			storeError(c, "getTenantHistory", "Tenant not found or unauthorized", "Failed to fetch history", err)
+			return
+		}
+		page, filter, ok := listParams(c, repository.TenantPaymentList)
+		if !ok {
+			return
+		}
 
-		rows, err := db.Query(query, tenantID)
+		payments, err := store.Payments().ListByTenant(ctx, tenantID, page, filter)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch history"})
+			listError(c, "getTenantHistory", "Failed to fetch history", err)
 			return
 		}
-		defer rows.Close()
-
-		history := []gin.H{}
-		for rows.Next() {
-			var p struct {
-				ID        int
-				Amount    float64
-				Status    string
-				CreatedAt time.Time
-				Method    string
-				Receipt   string
-			}
-			if err := rows.Scan(&p.ID, &p.Amount, &p.Status, &p.CreatedAt, &p.Method, &p.Receipt); err != nil {
-				continue
-			}
-			history = append(history, gin.H{
+		writePage(c, mapPage(payments, func(p models.Payment) gin.H {
+			return gin.H{
 				"type":      "PAYMENT",
 				"id":        p.ID,
 				"amount":    p.Amount,
@@ -269,9 +298,7 @@ func GetTenantHistory(db *database.Database) gin.HandlerFunc {
 				"date":      p.CreatedAt,
 				"method":    p.Method,
 				"reference": p.Receipt,
-			})
-		}
-
-		c.JSON(http.StatusOK, gin.H{"data": history})
+			}
+		}))
 	}
 }
@@ -1,37 +1,44 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
+	"log"
 	"net/http"
-	"strconv"
 
 	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
-	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/listing"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/Zolet-hash/smart-rentals/internal/repository"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
 type CreatePropertyInput struct {
-	Title        string  `json:"title" binding:"required"`
-	Description  string  `json:"description"`
-	Location     string  `json:"location" binding:"required"`
-	PropertyType string  `json:"property_type" binding:"required"`
-	Vacancy      bool    `json:"vacancy"`
-	TotalRent    float64 `json:"total_rent"`
+	Title        string `json:"title" binding:"required"`
+	Description  string `json:"description"`
+	Location     string `json:"location" binding:"required"`
+	PropertyType string `json:"property_type" binding:"required"`
+	Vacancy      bool   `json:"vacancy"`
+	TotalRent    int    `json:"total_rent"`
+	// Optional: create the property under an organization's management on
+	// behalf of an owner who is a member of that organization
+	OrganizationID *int `json:"organization_id"`
+	OwnerID        *int `json:"owner_id"`
 }
 
 type UpdatePropertyInput struct {
-	Title        *string  `json:"title"`
-	Description  *string  `json:"description"`
-	Location     *string  `json:"location"`
-	PropertyType *string  `json:"property_type"`
-	Vacancy      *bool    `json:"vacancy"`
-	TotalRent    *float64 `json:"total_rent"`
+	Title        *string `json:"title"`
+	Description  *string `json:"description"`
+	Location     *string `json:"location"`
+	PropertyType *string `json:"property_type"`
+	Vacancy      *bool   `json:"vacancy"`
+	TotalRent    *int    `json:"total_rent"`
 }
 
-func CreateProperty(db *database.Database) gin.HandlerFunc {
+func CreateProperty(svc *services.PropertyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 1. Get landlord from context
-		landlordID, err := middleware.GetUserID(c)
+		// 1. Get caller from context
+		userID, err := middleware.GetUserID(c)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
@@ -44,157 +51,96 @@ func CreateProperty(db *database.Database) gin.HandlerFunc {
 			return
 		}
 
-		// 3. Execute query (scoped by landlord)
-		query := `
-			INSERT INTO properties (landlord_id, title, description, location, property_type, vacancy, total_rent)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
-			RETURNING id, created_at, updated_at
-		`
-
-		var propertyID int
-		var createdAt, updatedAt string
-		err = db.QueryRow(query, landlordID, input.Title, input.Description, input.Location, input.PropertyType, input.Vacancy, input.TotalRent).
-			Scan(&propertyID, &createdAt, &updatedAt)
-
+		// 3. Store; the service resolves the owner
+		in := services.PropertyInput{
+			Title:        input.Title,
+			Description:  input.Description,
+			Location:     input.Location,
+			PropertyType: input.PropertyType,
+			Vacancy:      input.Vacancy,
+			TotalRent:    input.TotalRent,
+		}
+		if input.OrganizationID != nil {
+			in.OrganizationID = *input.OrganizationID
+		}
+		if input.OwnerID != nil {
+			in.OwnerID = *input.OwnerID
+		}
+		property, err := svc.CreateProperty(c.Request.Context(), userID, in)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create property"})
+			rentalError(c, "createProperty", "Failed to create property", err)
 			return
 		}
 
+		auditAfterRecord(c, svc, int(property.ID))
+
 		// 4. Respond
 		c.JSON(http.StatusCreated, gin.H{
 			"message": "Property created successfully",
-			"data": gin.H{
-				"id":            propertyID,
-				"landlord_id":   landlordID,
-				"title":         input.Title,
-				"description":   input.Description,
-				"location":      input.Location,
-				"property_type": input.PropertyType,
-				"vacancy":       input.Vacancy,
-				"total_rent":    input.TotalRent,
-				"created_at":    createdAt,
-				"updated_at":    updatedAt,
-			},
+			"data":    property,
 		})
 	}
 }
 
-func ListProperties(db *database.Database) gin.HandlerFunc {
+// ListProperties lists the caller's properties, plus any delegated to them,
+// a page at a time. Query: q (title or location), min_amount and max_amount
+// (total rent), sort (created_at, title, total_rent; prefix - for
+// descending), limit, cursor, include_total.
+func ListProperties(svc *services.PropertyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		landlordID, err := middleware.GetUserID(c)
+		userID, err := middleware.GetUserID(c)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
-
-		query := `
-			SELECT id, title, description, location, property_type, vacancy, total_rent, created_at, updated_at
-			FROM properties
-			WHERE landlord_id = $1
-			ORDER BY created_at DESC
-		`
-
-		rows, err := db.Query(query, landlordID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch properties"})
+		page, filter, ok := listParams(c, repository.PropertyList)
+		if !ok {
 			return
 		}
-		defer rows.Close()
 
-		properties := []gin.H{}
-		for rows.Next() {
-			var p struct {
-				ID           int
-				Title        string
-				Description  string
-				Location     string
-				PropertyType string
-				Vacancy      bool
-				TotalRent    float64
-				CreatedAt    string
-				UpdatedAt    string
-			}
-			if err := rows.Scan(&p.ID, &p.Title, &p.Description, &p.Location, &p.PropertyType, &p.Vacancy, &p.TotalRent, &p.CreatedAt, &p.UpdatedAt); err != nil {
-				continue
-			}
-			properties = append(properties, gin.H{
-				"id":            p.ID,
-				"title":         p.Title,
-				"description":   p.Description,
-				"location":      p.Location,
-				"property_type": p.PropertyType,
-				"vacancy":       p.Vacancy,
-				"total_rent":    p.TotalRent,
-				"created_at":    p.CreatedAt,
-				"updated_at":    p.UpdatedAt,
-			})
+		// Own properties plus any delegated to the caller
+		properties, err := svc.ListProperties(c.Request.Context(), userID, page, filter)
+		if err != nil {
+			rentalError(c, "listProperties", "Failed to fetch properties", err)
+			return
 		}
-
-		c.JSON(http.StatusOK, gin.H{"data": properties})
+		writePage(c, properties)
 	}
 }
 
-func GetProperty(db *database.Database) gin.HandlerFunc {
+func GetProperty(svc *services.PropertyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		landlordID, err := middleware.GetUserID(c)
+		userID, err := middleware.GetUserID(c)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
-
-		propertyID := c.Param("propertyId")
-
-		query := `
-			SELECT id, title, description, location, property_type, vacancy, total_rent, created_at, updated_at
-			FROM properties
-			WHERE id = $1 AND landlord_id = $2
-		`
-
-		var p struct {
-			ID           int
-			Title        string
-			Description  string
-			Location     string
-			PropertyType string
-			Vacancy      bool
-			TotalRent    float64
-			CreatedAt    string
-			UpdatedAt    string
+		propertyID, ok := intParam(c, "propertyId", "Invalid property ID")
+		if !ok {
+			return
 		}
 
-		err = db.QueryRow(query, propertyID, landlordID).Scan(&p.ID, &p.Title, &p.Description, &p.Location, &p.PropertyType, &p.Vacancy, &p.TotalRent, &p.CreatedAt, &p.UpdatedAt)
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found or unauthorized"})
-			return
-		} else if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch property"})
+		property, err := svc.GetProperty(c.Request.Context(), userID, permissions.PropertiesRead, propertyID)
+		if err != nil {
+			rentalError(c, "getProperty", "Failed to fetch property", err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"data": gin.H{
-			"id":            p.ID,
-			"title":         p.Title,
-			"description":   p.Description,
-			"location":      p.Location,
-			"property_type": p.PropertyType,
-			"vacancy":       p.Vacancy,
-			"total_rent":    p.TotalRent,
-			"created_at":    p.CreatedAt,
-			"updated_at":    p.UpdatedAt,
-		}})
+		c.JSON(http.StatusOK, gin.H{"data": property})
 	}
 }
 
-func UpdateProperty(db *database.Database) gin.HandlerFunc {
+func UpdateProperty(svc *services.PropertyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		landlordID, err := middleware.GetUserID(c)
+		userID, err := middleware.GetUserID(c)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
-
-		propertyID := c.Param("propertyId")
+		propertyID, ok := intParam(c, "propertyId", "Invalid property ID")
+		if !ok {
+			return
+		}
 
 		var input UpdatePropertyInput
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -202,88 +148,85 @@ func UpdateProperty(db *database.Database) gin.HandlerFunc {
 			return
 		}
 
-		// Verify ownership first
-		var exists bool
-		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM properties WHERE id = $1 AND landlord_id = $2)", propertyID, landlordID).Scan(&exists)
-		if err != nil || !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found or unauthorized"})
+		// Verify access before recording the old state
+		ctx := c.Request.Context()
+		if _, err := svc.GetProperty(ctx, userID, permissions.PropertiesWrite, propertyID); err != nil {
+			rentalError(c, "updateProperty", "Failed to update property", err)
 			return
 		}
+		auditBeforeRecord(c, svc, propertyID)
 
-		// Build dynamic update query
-		query := "UPDATE properties SET updated_at = NOW()"
-		args := []interface{}{}
-		argID := 1
-
-		if input.Title != nil {
-			query += ", title = $" + strconv.Itoa(argID)
-			args = append(args, *input.Title)
-			argID++
-		}
-		if input.Description != nil {
-			query += ", description = $" + strconv.Itoa(argID)
-			args = append(args, *input.Description)
-			argID++
-		}
-		if input.Location != nil {
-			query += ", location = $" + strconv.Itoa(argID)
-			args = append(args, *input.Location)
-			argID++
-		}
-		if input.PropertyType != nil {
-			query += ", property_type = $" + strconv.Itoa(argID)
-			args = append(args, *input.PropertyType)
-			argID++
-		}
-		if input.Vacancy != nil {
-			query += ", vacancy = $" + strconv.Itoa(argID)
-			args = append(args, *input.Vacancy)
-			argID++
-		}
-		if input.TotalRent != nil {
-			query += ", total_rent = $" + strconv.Itoa(argID)
-			args = append(args, *input.TotalRent)
-			argID++
-		}
-
-		query += " WHERE id = $" + strconv.Itoa(argID) + " AND landlord_id = $" + strconv.Itoa(argID+1)
-		args = append(args, propertyID, landlordID)
-
-		_, err = db.Exec(query, args...)
+		err = svc.UpdateProperty(ctx, userID, propertyID, repository.PropertyUpdate{
+			Title:        input.Title,
+			Description:  input.Description,
+			Location:     input.Location,
+			PropertyType: input.PropertyType,
+			Vacancy:      input.Vacancy,
+			TotalRent:    input.TotalRent,
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update property"})
+			rentalError(c, "updateProperty", "Failed to update property", err)
 			return
 		}
 
+		auditAfterRecord(c, svc, propertyID)
+
 		c.JSON(http.StatusOK, gin.H{"message": "Property updated successfully"})
 	}
 }
-func DeleteProperty(db *database.Database) gin.HandlerFunc {
+
+func DeleteProperty(svc *services.PropertyService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		landlordID, err := middleware.GetUserID(c)
+		userID, err := middleware.GetUserID(c)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
+		propertyID, ok := intParam(c, "propertyId", "Invalid property ID")
+		if !ok {
+			return
+		}
 
-		propertyID := c.Param("propertyId")
-
-		// Verify ownership
-		var exists bool
-		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM properties WHERE id = $1 AND landlord_id = $2)", propertyID, landlordID).Scan(&exists)
-		if err != nil || !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found or unauthorized"})
+		// Verify access before recording the old state
+		ctx := c.Request.Context()
+		if _, err := svc.GetProperty(ctx, userID, permissions.PropertiesWrite, propertyID); err != nil {
+			rentalError(c, "deleteProperty", "Failed to delete property", err)
 			return
 		}
+		auditBeforeRecord(c, svc, propertyID)
 
-		// Hard delete (cascade will handle units and tenants if configured, but let's be careful.
-		// For now, simple delete. If units/tenants exist, this will fail if FK constraints are strict).
-		_, err = db.Exec("DELETE FROM properties WHERE id = $1 AND landlord_id = $2", propertyID, landlordID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete property. Ensure all units are vacated."})
+		// Units go with the property; tenants must be removed first
+		if err := svc.DeleteProperty(ctx, userID, propertyID); err != nil {
+			rentalError(c, "deleteProperty", "Failed to delete property", err)
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{"message": "Property deleted successfully"})
 	}
 }
+
+// rentalError maps property, unit and tenant service errors to responses,
+// failMsg for failures
+func rentalError(c *gin.Context, fn, failMsg string, err error) {
+	switch {
+	case errors.Is(err, services.ErrPropertyNotFound), errors.Is(err, services.ErrUnitNotFound),
+		errors.Is(err, services.ErrTenantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOrgForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPropertyOccupied), errors.Is(err, services.ErrUnitOccupied),
+		errors.Is(err, services.ErrTenantUserTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidOwner), errors.Is(err, services.ErrOwnerWithoutOrg),
+		errors.Is(err, services.ErrInvalidTotalRent), errors.Is(err, services.ErrPropertyIncomplete),
+		errors.Is(err, services.ErrInvalidUnitPrice), errors.Is(err, services.ErrUnitIncomplete),
+		errors.Is(err, services.ErrTenantIncomplete), errors.Is(err, services.ErrInvalidRent),
+		errors.Is(err, services.ErrInvalidRentDueDay), errors.Is(err, services.ErrInvalidTenantUser),
+		errors.Is(err, listing.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] %s: %v", reqID, fn, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failMsg, "trace_id": reqID})
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ReceiptHandler struct {
+	Service *services.ReceiptService
+}
+
+func NewReceiptHandler(service *services.ReceiptService) *ReceiptHandler {
+	return &ReceiptHandler{Service: service}
+}
+
+// Download renders a payment's numbered receipt as a PDF
+func (h *ReceiptHandler) Download(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := int64Param(c, "id", "Invalid payment ID")
+	if !ok {
+		return
+	}
+
+	receipt, err := h.Service.PaymentReceipt(c.Request.Context(), userID, id)
+	if err != nil {
+		receiptError(c, "downloadReceipt", err)
+		return
+	}
+	pdf, err := documents.ReceiptPDF(receipt)
+	if err != nil {
+		receiptError(c, "downloadReceipt", err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="receipt-`+receipt.Number+`.pdf"`)
+	c.Data(http.StatusOK, documents.ContentTypePDF, pdf)
+}
+
+// Verify is the public page behind the QR code on a receipt. It confirms
+// the receipt was issued without exposing more than is printed on it.
+func (h *ReceiptHandler) Verify(c *gin.Context) {
+	v, err := h.Service.Verify(c.Request.Context(), c.Param("code"))
+	if err != nil {
+		receiptError(c, "verifyReceipt", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": v})
+}
+
+// --- Branding ---
+
+// GetBranding returns the current landlord's letterhead
+func (h *ReceiptHandler) GetBranding(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	b, err := h.Service.GetBranding(c.Request.Context(), userID)
+	if err != nil {
+		receiptError(c, "getBranding", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": b})
+}
+
+// UpdateBranding replaces the letterhead details printed on receipts and
+// statements; the logo is uploaded separately
+func (h *ReceiptHandler) UpdateBranding(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var req models.LandlordBranding
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	before, err := h.Service.GetBranding(ctx, userID)
+	if err != nil {
+		receiptError(c, "updateBranding", err)
+		return
+	}
+	b, err := h.Service.SaveBranding(ctx, userID, req)
+	if err != nil {
+		receiptError(c, "updateBranding", err)
+		return
+	}
+	middleware.AuditEntity(c, userID, &userID)
+	middleware.AuditBefore(c, before)
+	middleware.AuditAfter(c, b)
+	c.JSON(http.StatusOK, gin.H{"message": "Branding saved", "data": b})
+}
+
+// UploadLogo stores a "logo" PNG or JPEG (multipart/form-data)
+func (h *ReceiptHandler) UploadLogo(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	files, ok := formFiles(c, "logo", 1, services.MaxLogoSize, errors.New("upload a single logo"), services.ErrLogoTooLarge)
+	if !ok {
+		return
+	}
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload the image in \"logo\""})
+		return
+	}
+
+	b, err := h.Service.SetLogo(c.Request.Context(), userID, files[0].Data)
+	if err != nil {
+		receiptError(c, "uploadLogo", err)
+		return
+	}
+	middleware.AuditEntity(c, userID, &userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Logo saved", "data": b})
+}
+
+// Logo returns the current landlord's logo
+func (h *ReceiptHandler) Logo(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	data, contentType, err := h.Service.Logo(c.Request.Context(), userID)
+	if err != nil {
+		receiptError(c, "getLogo", err)
+		return
+	}
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// DeleteLogo removes the current landlord's logo
+func (h *ReceiptHandler) DeleteLogo(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	if err := h.Service.DeleteLogo(c.Request.Context(), userID); err != nil {
+		receiptError(c, "deleteLogo", err)
+		return
+	}
+	middleware.AuditEntity(c, userID, &userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Logo removed"})
+}
+
+// receiptError maps service errors to responses
+func receiptError(c *gin.Context, fn string, err error) {
+	switch {
+	case errors.Is(err, services.ErrPaymentNotFound), errors.Is(err, services.ErrUnknownReceipt),
+		errors.Is(err, services.ErrNoLogo):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNoReceipt):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrLogoTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidLogo), errors.Is(err, services.ErrInvalidBranding):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] %s: %v", reqID, fn, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process receipt", "trace_id": reqID})
+	}
+}
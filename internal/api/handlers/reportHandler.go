@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ReportHandler struct {
+	Service *services.ReportService
+}
+
+func NewReportHandler(service *services.ReportService) *ReportHandler {
+	return &ReportHandler{Service: service}
+}
+
+var rentRollColumns = []documents.Column{
+	{Title: "Property", Width: 40},
+	{Title: "Unit", Width: 20},
+	{Title: "Tenant", Width: 45},
+	{Title: "Phone", Width: 28},
+	{Title: "Rent", Width: 28, Money: true},
+	{Title: "Billed", Width: 28, Money: true},
+	{Title: "Paid", Width: 28, Money: true},
+	{Title: "Balance", Width: 30, Money: true},
+}
+
+var arrearsColumns = []documents.Column{
+	{Title: "Property", Width: 36},
+	{Title: "Unit", Width: 18},
+	{Title: "Tenant", Width: 40},
+	{Title: "Phone", Width: 26},
+	{Title: "Rent", Width: 24, Money: true},
+	{Title: "Paid in period", Width: 26, Money: true},
+	{Title: "Last payment", Width: 24},
+	{Title: "Owed since", Width: 22},
+	{Title: "Days", Width: 14},
+	{Title: "Bucket", Width: 16},
+	{Title: "Balance", Width: 28, Money: true},
+}
+
+// RentRoll lists every unit with its tenant, rent, billed, paid and closing
+// balance. Query: from, to (YYYY-MM-DD, inclusive; default this month),
+// property_id, format=csv|xlsx|pdf to download instead of JSON.
+func (h *ReportHandler) RentRoll(c *gin.Context) {
+	userID, filter, format, ok := reportParams(c)
+	if !ok {
+		return
+	}
+
+	if format == "" {
+		lines := []models.RentRollLine{}
+		totals, err := h.Service.RentRoll(c.Request.Context(), userID, filter, func(l models.RentRollLine) error {
+			lines = append(lines, l)
+			return nil
+		})
+		if err != nil {
+			reportError(c, "rentRoll", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{"from": filter.From.Format("2006-01-02"), "to": reportTo(filter),
+			"units": lines, "totals": totals}})
+		return
+	}
+
+	out := h.download(c, userID, format, "rent-roll", "Rent roll", filter, rentRollColumns)
+	totals, err := h.Service.RentRoll(c.Request.Context(), userID, filter, func(l models.RentRollLine) error {
+		tw, err := out.writer()
+		if err != nil {
+			return err
+		}
+		return tw.Row(l.PropertyTitle, l.UnitName, vacantOr(l.TenantName, l.TenantID != nil), l.Phone, l.Rent, l.Billed, l.Paid, l.Balance)
+	})
+	if err == nil {
+		var tw documents.TableWriter
+		if tw, err = out.writer(); err == nil {
+			let := fmt.Sprintf("%d of %d units let", totals.OccupiedUnits, totals.Units)
+			err = tw.Total("Total", nil, let, nil, totals.Rent, totals.Billed, totals.Paid, totals.Balance)
+		}
+	}
+	out.finish(err, "rentRoll")
+}
+
+// Arrears lists tenants owing rent at the end of the period with how long
+// they have owed it. Query as for RentRoll.
+func (h *ReportHandler) Arrears(c *gin.Context) {
+	userID, filter, format, ok := reportParams(c)
+	if !ok {
+		return
+	}
+
+	if format == "" {
+		lines := []models.ArrearsLine{}
+		totals, err := h.Service.Arrears(c.Request.Context(), userID, filter, func(l models.ArrearsLine) error {
+			lines = append(lines, l)
+			return nil
+		})
+		if err != nil {
+			reportError(c, "arrearsReport", err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{"from": filter.From.Format("2006-01-02"), "to": reportTo(filter),
+			"tenants": lines, "totals": totals}})
+		return
+	}
+
+	out := h.download(c, userID, format, "arrears", "Arrears report", filter, arrearsColumns)
+	totals, err := h.Service.Arrears(c.Request.Context(), userID, filter, func(l models.ArrearsLine) error {
+		tw, err := out.writer()
+		if err != nil {
+			return err
+		}
+		var lastPaid interface{}
+		if l.LastPaymentAt != nil {
+			lastPaid = *l.LastPaymentAt
+		}
+		return tw.Row(l.PropertyTitle, l.UnitName, l.TenantName, l.Phone, l.Rent, l.PaidInPeriod, lastPaid,
+			l.OwedSince, l.DaysOverdue, l.Bucket, l.Balance)
+	})
+	if err == nil {
+		var tw documents.TableWriter
+		if tw, err = out.writer(); err == nil {
+			tenants := fmt.Sprintf("%d tenants", totals.Tenants)
+			err = tw.Total("Total", nil, tenants, nil, nil, nil, nil, nil, nil, nil, totals.Balance)
+		}
+	}
+	out.finish(err, "arrearsReport")
+}
+
+// reportParams reads the period, property and format of a report request
+func reportParams(c *gin.Context) (int, services.ReportFilter, string, bool) {
+	var filter services.ReportFilter
+	userID, ok := currentUserID(c)
+	if !ok {
+		return 0, filter, "", false
+	}
+	from, to, err := statementPeriod(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, filter, "", false
+	}
+	filter.From, filter.To = from, to
+	if v := c.Query("property_id"); v != "" {
+		if filter.PropertyID, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid property_id"})
+			return 0, filter, "", false
+		}
+	}
+
+	format := c.Query("format")
+	switch format {
+	case "", "json":
+		format = ""
+	case documents.FormatCSV, documents.FormatXLSX, documents.FormatPDF:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, csv, xlsx or pdf"})
+		return 0, filter, "", false
+	}
+	return userID, filter, format, true
+}
+
+// reportTo is the inclusive last day of the report period
+func reportTo(f services.ReportFilter) string {
+	return f.To.AddDate(0, 0, -1).Format("2006-01-02")
+}
+
+func vacantOr(name string, let bool) string {
+	if !let {
+		return "(vacant)"
+	}
+	return name
+}
+
+// reportDownload streams a report file. The response starts with the first
+// row, so errors found before it (such as an unknown property) still get a
+// JSON answer.
+type reportDownload struct {
+	c        *gin.Context
+	format   string
+	filename string
+	table    documents.Table
+	issuer   func() string // name printed on PDFs
+	w        documents.TableWriter
+}
+
+func (h *ReportHandler) download(c *gin.Context, userID int, format, name, title string, f services.ReportFilter, columns []documents.Column) *reportDownload {
+	return &reportDownload{
+		c:        c,
+		format:   format,
+		filename: fmt.Sprintf("%s-%s-to-%s.%s", name, f.From.Format("2006-01-02"), reportTo(f), format),
+		table: documents.Table{
+			Title:   title,
+			Details: []documents.Field{{Label: "Period", Value: documents.FormatPeriod(f.From, f.To)}},
+			Columns: columns,
+		},
+		issuer: func() string {
+			name, _ := h.Service.IssuedBy(c.Request.Context(), userID)
+			return name
+		},
+	}
+}
+
+func (d *reportDownload) writer() (documents.TableWriter, error) {
+	if d.w != nil {
+		return d.w, nil
+	}
+	if d.format == documents.FormatPDF {
+		d.table.IssuedBy = d.issuer()
+	}
+	d.c.Header("Content-Type", documents.ContentType(d.format))
+	d.c.Header("Content-Disposition", "attachment; filename="+d.filename)
+	d.c.Status(http.StatusOK)
+
+	w, err := documents.NewTableWriter(d.c.Writer, d.format, d.table)
+	if err != nil {
+		return nil, err
+	}
+	d.w = w
+	return w, nil
+}
+
+// finish closes the file, or answers with the error when nothing was sent.
+// Once rows have gone out an error can only cut the download short.
+func (d *reportDownload) finish(err error, fn string) {
+	if d.w == nil {
+		reportError(d.c, fn, err)
+		return
+	}
+	if err == nil {
+		err = d.w.Close()
+	}
+	if err != nil {
+		reqID, _ := d.c.Get("request_id")
+		log.Printf("[%v] %s: report aborted after streaming started: %v", reqID, fn, err)
+		d.c.Abort()
+	}
+}
+
+// reportError answers a failed report before any of it was sent
+func reportError(c *gin.Context, fn string, err error) {
+	if errors.Is(err, services.ErrPropertyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	reqID, _ := c.Get("request_id")
+	log.Printf("[%v] %s: %v", reqID, fn, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build report", "trace_id": reqID})
+}
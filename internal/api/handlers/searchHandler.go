@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	Service *services.SearchService
+}
+
+func NewSearchHandler(service *services.SearchService) *SearchHandler {
+	return &SearchHandler{Service: service}
+}
+
+// Search finds tenants (by name or phone number fragment), units, properties
+// (by title or location) and payments (by receipt code) the caller may see.
+// Query: q, limit per group (default 10, max 50). Results are grouped by
+// kind, best match first; groups the caller's role may not read are left out.
+func (h *SearchHandler) Search(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	limit := services.DefaultSearchLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > services.MaxSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		limit = n
+	}
+
+	q := c.Query("q")
+	results, err := h.Service.Search(c.Request.Context(), userID, middleware.GetRole(c), q, limit)
+	if err != nil {
+		searchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"query": q, "data": results})
+}
+
+// searchError maps service errors to responses
+func searchError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrSearchTooShort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	reqID, _ := c.Get("request_id")
+	log.Printf("[%v] search: %v", reqID, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search", "trace_id": reqID})
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/Zolet-hash/smart-rentals/internal/statements"
+	"github.com/gin-gonic/gin"
+)
+
+type StatementHandler struct {
+	Service *services.StatementService
+}
+
+func NewStatementHandler(service *services.StatementService) *StatementHandler {
+	return &StatementHandler{Service: service}
+}
+
+// Upload reconciles an M-Pesa statement (CSV or PDF) or bank statement (CSV
+// or XLSX) sent as "file" (multipart/form-data). Optional fields: password
+// for a protected PDF, landlord_id for staff. Unmatched lines become pending
+// payments, assigned with PATCH /payments/:id/assign.
+func (h *StatementHandler) Upload(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	landlordID := 0
+	if v := c.PostForm("landlord_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid landlord ID"})
+			return
+		}
+		landlordID = id
+	}
+	files, ok := formFiles(c, "file", 1, services.MaxStatementSize, errors.New("upload a single file"), services.ErrStatementTooLarge)
+	if !ok {
+		return
+	}
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload the statement in \"file\""})
+		return
+	}
+	f := files[0]
+	format := statementFormat(c.PostForm("format"), f.Filename, f.ContentType)
+	if format == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": statements.ErrUnsupportedFile.Error()})
+		return
+	}
+
+	upload, err := h.Service.Upload(c.Request.Context(), userID, landlordID, services.StatementFile{
+		Filename: f.Filename, Format: format, Password: c.PostForm("password"), Data: f.Data,
+	})
+	if err != nil {
+		statementError(c, "uploadStatement", err)
+		return
+	}
+
+	owner := int(upload.LandlordID)
+	middleware.AuditEntity(c, upload.ID, &owner)
+	summary := upload
+	summary.Lines = nil
+	middleware.AuditAfter(c, summary)
+	c.JSON(http.StatusCreated, gin.H{"message": "Statement reconciled", "data": upload})
+}
+
+// ListUploads returns uploaded statements, newest first. Query: limit, offset.
+func (h *StatementHandler) ListUploads(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	limit, offset, err := pageParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uploads, err := h.Service.ListUploads(c.Request.Context(), userID, limit, offset)
+	if err != nil {
+		statementError(c, "listStatements", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": uploads})
+}
+
+// GetUpload returns a statement with its lines. Query: unmatched=true for
+// only the lines whose payments still await a tenant.
+func (h *StatementHandler) GetUpload(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	id, ok := int64Param(c, "id", "Invalid statement ID")
+	if !ok {
+		return
+	}
+	unmatched, err := strconv.ParseBool(c.DefaultQuery("unmatched", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unmatched must be true or false"})
+		return
+	}
+
+	upload, err := h.Service.GetUpload(c.Request.Context(), userID, id, unmatched)
+	if err != nil {
+		statementError(c, "getStatement", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": upload})
+}
+
+// statementFormat picks the statement format from an explicit format field,
+// the file extension or its content type
+func statementFormat(format, filename, contentType string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if format == documents.FormatPDF || format == "" && (ext == ".pdf" || ext == "" && contentType == documents.ContentTypePDF) {
+		return documents.FormatPDF
+	}
+	return importFormat(format, filename, contentType)
+}
+
+// statementError maps service errors to responses
+func statementError(c *gin.Context, fn string, err error) {
+	switch {
+	case errors.Is(err, services.ErrLandlordNotFound), errors.Is(err, services.ErrStatementNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrStatementTooLarge), errors.Is(err, documents.ErrSpreadsheetTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, statements.ErrPasswordRequired):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, statements.ErrUnsupportedFile), errors.Is(err, statements.ErrNoTransactions),
+		errors.Is(err, services.ErrEmptyStatement), errors.Is(err, documents.ErrUnreadableSpreadsheet):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] %s: %v", reqID, fn, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process statement", "trace_id": reqID})
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/events"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/gin-gonic/gin"
+)
+
+// streamHeartbeat keeps idle connections open through proxies
+const streamHeartbeat = 25 * time.Second
+
+type StreamHandler struct {
+	DB     *database.Database
+	Events events.Stream
+}
+
+func NewStreamHandler(db *database.Database, stream events.Stream) *StreamHandler {
+	return &StreamHandler{DB: db, Events: stream}
+}
+
+// Stream pushes live events as server-sent events. Landlords receive their
+// account's payment, tenant and arrears events; every user receives their
+// own in-app notifications. Each event is sent as "event: <type>" with the
+// JSON event as data.
+func (h *StreamHandler) Stream(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	match := streamFilter(userID, middleware.GetRole(c) == permissions.RoleLandlord)
+
+	ch, unsubscribe := h.Events.Subscribe(match)
+	defer unsubscribe()
+
+	// The server's write timeout would otherwise end the stream
+	rc := http.NewResponseController(c.Writer)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("stream: clear write deadline: %v", err)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // disable proxy buffering (nginx)
+	c.Status(http.StatusOK)
+	fmt.Fprint(c.Writer, "retry: 5000\n: connected\n\n")
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				log.Printf("stream: encode %s: %v", e.Type, err)
+				continue
+			}
+			fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", e.Type, data)
+		}
+		c.Writer.Flush()
+	}
+}
+
+// streamFilter decides which events a connection receives
+func streamFilter(userID int, landlord bool) func(events.Event) bool {
+	return func(e events.Event) bool {
+		if e.Type != events.NotificationCreated {
+			return landlord && e.LandlordID == userID
+		}
+		var to struct {
+			UserID *int `json:"user_id"`
+		}
+		raw, err := json.Marshal(e.Data)
+		if err != nil || json.Unmarshal(raw, &to) != nil {
+			return false
+		}
+		return to.UserID != nil && *to.UserID == userID
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/events"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/gin-gonic/gin"
+)
+
+func TestStreamFilter(t *testing.T) {
+	seven, eight := 7, 8
+	notification := func(userID *int) events.Event {
+		return events.New(events.NotificationCreated, 7, map[string]interface{}{"user_id": userID})
+	}
+	tests := []struct {
+		name     string
+		landlord bool
+		event    events.Event
+		want     bool
+	}{
+		{"own payment", true, events.New(events.PaymentCompleted, 7, nil), true},
+		{"another landlord's payment", true, events.New(events.PaymentCompleted, 8, nil), false},
+		{"staff do not get the landlord's events", false, events.New(events.PaymentCompleted, 7, nil), false},
+		{"own notification", false, notification(&seven), true},
+		{"notification for someone else on the account", true, notification(&eight), false},
+		{"notification without a recipient", true, notification(nil), false},
+	}
+	for _, tt := range tests {
+		if got := streamFilter(7, tt.landlord)(tt.event); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	stream := events.NewMemoryStream()
+	h := NewStreamHandler(nil, stream)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", 7)
+		c.Set("role", permissions.RoleLandlord)
+	})
+	r.GET("/events/stream", h.Stream)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type %q", ct)
+	}
+
+	lines := bufio.NewScanner(resp.Body)
+	readUntilBlank := func() []string {
+		var block []string
+		for lines.Scan() && lines.Text() != "" {
+			block = append(block, lines.Text())
+		}
+		return block
+	}
+	if got := readUntilBlank(); strings.Join(got, "\n") != "retry: 5000\n: connected" {
+		t.Fatalf("preamble %q", got)
+	}
+
+	// The subscription exists once the preamble is written
+	stream.Publish(ctx, events.New(events.PaymentCompleted, 8, map[string]int{"payment_id": 1}))
+	stream.Publish(ctx, events.New(events.PaymentCompleted, 7, map[string]int{"payment_id": 2}))
+	got := readUntilBlank()
+	if len(got) != 2 || got[0] != "event: payment.completed" || !strings.Contains(got[1], `"payment_id":2`) {
+		t.Errorf("event %q, want landlord 7's payment 2 only", got)
+	}
+}
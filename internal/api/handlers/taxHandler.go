@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type TaxHandler struct {
+	Service *services.TaxService
+}
+
+func NewTaxHandler(service *services.TaxService) *TaxHandler {
+	return &TaxHandler{Service: service}
+}
+
+// itaxColumns follow the rental income details sheet of the iTax MRI return
+var itaxColumns = []documents.Column{
+	{Title: "PIN of Tenant"},
+	{Title: "Name of Tenant"},
+	{Title: "Property Name"},
+	{Title: "Unit"},
+	{Title: "Location"},
+	{Title: "Period From"},
+	{Title: "Period To"},
+	{Title: "Gross Rent Received", Money: true},
+}
+
+type kraPinInput struct {
+	KRAPin string `json:"kra_pin" binding:"required"`
+}
+
+type fileReturnInput struct {
+	PRN  string `json:"prn" binding:"required"`
+	Paid bool   `json:"paid"`
+}
+
+// GetProfile returns the landlord's KRA PIN
+func (h *TaxHandler) GetProfile(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	pin, err := h.Service.KRAPin(c.Request.Context(), userID)
+	if err != nil && !errors.Is(err, services.ErrNoKRAPin) {
+		taxError(c, "getTaxProfile", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"kra_pin": pin}})
+}
+
+// UpdateProfile sets the landlord's KRA PIN. Body: {"kra_pin": "A123456789B"}
+func (h *TaxHandler) UpdateProfile(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var input kraPinInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pin, err := h.Service.SetKRAPin(c.Request.Context(), userID, input.KRAPin)
+	if err != nil {
+		taxError(c, "updateTaxProfile", err)
+		return
+	}
+	middleware.AuditEntity(c, userID, &userID)
+	middleware.AuditAfter(c, gin.H{"kra_pin": pin})
+	c.JSON(http.StatusOK, gin.H{"message": "KRA PIN saved", "data": gin.H{"kra_pin": pin}})
+}
+
+// ListRates returns the tax rates in the rates table. Query: tax (e.g. MRI).
+func (h *TaxHandler) ListRates(c *gin.Context) {
+	rates, err := h.Service.ListRates(c.Request.Context(), c.Query("tax"))
+	if err != nil {
+		taxError(c, "listTaxRates", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rates})
+}
+
+// AddRate records a new rate from its effective date (admin)
+func (h *TaxHandler) AddRate(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var input services.TaxRateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rate, err := h.Service.AddRate(c.Request.Context(), userID, input)
+	if err != nil {
+		taxError(c, "addTaxRate", err)
+		return
+	}
+	middleware.AuditEntity(c, rate.ID, nil)
+	middleware.AuditAfter(c, rate)
+	c.JSON(http.StatusCreated, gin.H{"message": "Tax rate added", "data": rate})
+}
+
+// DeleteRate removes a rate (admin)
+func (h *TaxHandler) DeleteRate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tax rate ID"})
+		return
+	}
+	if err := h.Service.DeleteRate(c.Request.Context(), id); err != nil {
+		taxError(c, "deleteTaxRate", err)
+		return
+	}
+	middleware.AuditEntity(c, id, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Tax rate deleted"})
+}
+
+// ListReturns returns the landlord's MRI returns for each month of a year up
+// to this month. Query: year (default this year).
+func (h *TaxHandler) ListReturns(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	year := now.Year()
+	if v := c.Query("year"); v != "" {
+		y, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidYear.Error()})
+			return
+		}
+		year = y
+	}
+
+	returns, err := h.Service.Returns(c.Request.Context(), userID, year, now)
+	if err != nil {
+		taxError(c, "listTaxReturns", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": returns})
+}
+
+// GetReturn returns a month's MRI return with the rent each tenant paid
+func (h *TaxHandler) GetReturn(c *gin.Context) {
+	userID, period, ok := h.periodParams(c)
+	if !ok {
+		return
+	}
+	r, err := h.Service.Return(c.Request.Context(), userID, period, time.Now())
+	if err != nil {
+		taxError(c, "getTaxReturn", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": r})
+}
+
+// FileReturn records a month's return as filed on iTax, and paid when "paid"
+// is true. Body: {"prn": "...", "paid": false}. Filing again amends it.
+func (h *TaxHandler) FileReturn(c *gin.Context) {
+	userID, period, ok := h.periodParams(c)
+	if !ok {
+		return
+	}
+	var input fileReturnInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	r, err := h.Service.File(c.Request.Context(), userID, userID, period, input.PRN, input.Paid, time.Now())
+	if err != nil {
+		taxError(c, "fileTaxReturn", err)
+		return
+	}
+	middleware.AuditEntity(c, r.Period, &userID)
+	summary := r
+	summary.Lines = nil
+	middleware.AuditAfter(c, summary)
+	c.JSON(http.StatusOK, gin.H{"message": "Return recorded as " + r.Status, "data": r})
+}
+
+// ExportReturn downloads a month's rental income as CSV for the iTax MRI
+// return, one row per tenant
+func (h *TaxHandler) ExportReturn(c *gin.Context) {
+	userID, period, ok := h.periodParams(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	pin, err := h.Service.KRAPin(ctx, userID)
+	if err != nil {
+		taxError(c, "exportTaxReturn", err)
+		return
+	}
+	r, err := h.Service.Return(ctx, userID, period, time.Now())
+	if err != nil {
+		taxError(c, "exportTaxReturn", err)
+		return
+	}
+
+	from := period.Format("02/01/2006")
+	to := period.AddDate(0, 1, -1).Format("02/01/2006")
+	c.Header("Content-Type", documents.ContentTypeCSV)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=MRI_%s_%s.csv", pin, period.Format("200601")))
+	c.Status(http.StatusOK)
+	tw, err := documents.NewTableWriter(c.Writer, documents.FormatCSV, documents.Table{Columns: itaxColumns})
+	if err == nil {
+		for _, l := range r.Lines {
+			if err = tw.Row("", l.TenantName, l.PropertyTitle, l.UnitName, l.Location, from, to, l.Amount); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err != nil {
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] exportTaxReturn: aborted after streaming started: %v", reqID, err)
+		c.Abort()
+	}
+}
+
+func (h *TaxHandler) periodParams(c *gin.Context) (int, time.Time, bool) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return 0, time.Time{}, false
+	}
+	period, err := services.ParsePeriod(c.Param("period"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, time.Time{}, false
+	}
+	return userID, period, true
+}
+
+// taxError maps service errors to responses
+func taxError(c *gin.Context, fn string, err error) {
+	switch {
+	case errors.Is(err, services.ErrTaxRateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTaxRateExists), errors.Is(err, services.ErrPeriodOpen), errors.Is(err, services.ErrNoKRAPin):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPeriod), errors.Is(err, services.ErrInvalidYear), errors.Is(err, services.ErrInvalidPRN),
+		errors.Is(err, services.ErrInvalidKRAPin), errors.Is(err, services.ErrInvalidTaxRate), errors.Is(err, services.ErrUnknownTax):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] %s: %v", reqID, fn, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process tax request", "trace_id": reqID})
+	}
+}
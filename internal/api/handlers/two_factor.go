@@ -0,0 +1,659 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/Zolet-hash/smart-rentals/internal/pkg/utils"
+	cryptoutil "github.com/Zolet-hash/smart-rentals/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	// mfaChallengeExpiration bounds how long a user has to enter their code after the password step
+	mfaChallengeExpiration = 5 * time.Minute
+	mfaChallengePurpose    = "mfa_challenge"
+	totpIssuer             = "Smart Rentals"
+	recoveryCodeCount      = 10
+)
+
+type VerifyMFAInput struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type TOTPCodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorInput struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type UpdateMFAPolicyInput struct {
+	Required *bool `json:"required" binding:"required"`
+}
+
+// issueMFAChallenge signs the short-lived token returned by Login when the
+// user has 2FA enabled. AuthMiddleware rejects it because it has a purpose.
+func (h *AuthHandler) issueMFAChallenge(userID int, tokenVersion int) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"ver":     tokenVersion,
+		"purpose": mfaChallengePurpose,
+		"iat":     now.Unix(),
+		"exp":     now.Add(mfaChallengeExpiration).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(h.jwtSecret)
+}
+
+// parseMFAChallenge validates a challenge token and returns its user and token version
+func (h *AuthHandler) parseMFAChallenge(tokenString string) (int, int, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return h.jwtSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return 0, 0, errors.New("invalid or expired challenge")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != mfaChallengePurpose {
+		return 0, 0, errors.New("invalid challenge")
+	}
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, 0, errors.New("invalid challenge")
+	}
+	ver, _ := claims["ver"].(float64)
+	return int(userID), int(ver), nil
+}
+
+// checkTOTP verifies a code against the user's stored secret and records the
+// matched time step so the same code cannot be used twice
+func (h *AuthHandler) checkTOTP(userID int, encryptedSecret string, lastStep int64, code string) (bool, error) {
+	secret, err := cryptoutil.Decrypt(encryptedSecret, string(h.jwtSecret))
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := utils.ValidateTOTP(secret, code, time.Now(), lastStep)
+	if !ok {
+		return false, nil
+	}
+
+	// Guard against a concurrent request having accepted the same step
+	result, err := h.db.DB.Exec("UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1", step, userID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// replaceRecoveryCodes discards a user's recovery codes and stores a new hashed set
+func replaceRecoveryCodes(tx *sql.Tx, userID int) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		_, err := tx.Exec("INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)",
+			userID, utils.HashToken(utils.NormalizeRecoveryCode(code)))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// VerifyMFA completes a two-step login using a TOTP or recovery code
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var input VerifyMFAInput
+	if err := c.ShouldBindJSON(&input); err != nil || (input.Code == "" && input.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token and a code or recovery_code are required"})
+		return
+	}
+
+	userID, challengeVersion, err := h.parseMFAChallenge(input.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA challenge"})
+		return
+	}
+
+	reqID, _ := c.Get("request_id")
+
+	var email, role string
+	var tokenVersion int
+	var totpEnabled bool
+	var totpSecret sql.NullString
+	var lastStep int64
+	var lockedUntil sql.NullTime
+	err = h.db.DB.QueryRow(`
+		SELECT email, role, token_version, totp_enabled, totp_secret, totp_last_step, locked_until
+		FROM users WHERE id = $1`,
+		userID,
+	).Scan(&email, &role, &tokenVersion, &totpEnabled, &totpSecret, &lastStep, &lockedUntil)
+	if err == sql.ErrNoRows || (err == nil && (tokenVersion != challengeVersion || !totpEnabled)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA challenge"})
+		return
+	}
+	if err != nil {
+		log.Printf("[%v] verifyMFA: db error: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "trace_id": reqID})
+		return
+	}
+
+	if lockedUntil.Valid && lockedUntil.Time.After(time.Now()) {
+		respondLocked(c, lockedUntil.Time)
+		return
+	}
+
+	// Wrong second factors count towards the same lockout as wrong passwords
+	rejectCode := func(message string) {
+		h.recordLoginAttempt(c, &userID, email, false, "bad_mfa_code")
+		locked, err := h.recordLoginFailure(userID)
+		if err != nil {
+			log.Printf("[%v] verifyMFA: failed to record failure: %v", reqID, err)
+		}
+		if locked != nil {
+			respondLocked(c, *locked)
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": message})
+	}
+
+	response := gin.H{}
+	if input.Code != "" {
+		ok, err := h.checkTOTP(userID, totpSecret.String, lastStep, input.Code)
+		if err != nil {
+			log.Printf("[%v] verifyMFA: totp check failed: %v", reqID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "trace_id": reqID})
+			return
+		}
+		if !ok {
+			rejectCode("Invalid verification code")
+			return
+		}
+	} else {
+		// Recovery codes are single use
+		result, err := h.db.DB.Exec(`
+			UPDATE user_recovery_codes SET used_at = NOW()
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+			userID, utils.HashToken(utils.NormalizeRecoveryCode(input.RecoveryCode)),
+		)
+		if err != nil {
+			log.Printf("[%v] verifyMFA: recovery code update failed: %v", reqID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "trace_id": reqID})
+			return
+		}
+		if rows, _ := result.RowsAffected(); rows != 1 {
+			rejectCode("Invalid recovery code")
+			return
+		}
+
+		var remaining int
+		h.db.DB.QueryRow("SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL", userID).Scan(&remaining)
+		response["recovery_codes_remaining"] = remaining
+		log.Printf("User ID %d logged in with a recovery code (%d remaining)", userID, remaining)
+	}
+
+	h.resetLoginFailures(userID)
+	h.recordLoginAttempt(c, &userID, email, true, "")
+
+	tokenString, err := h.issueToken(userID, email, tokenVersion, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+		return
+	}
+
+	response["token"] = tokenString
+	response["expires_in"] = h.tokenExpiration.Seconds()
+	response["token_type"] = "Bearer"
+	response["role"] = role
+	c.JSON(http.StatusOK, response)
+}
+
+// GetTwoFactorStatus reports whether 2FA is enabled and required for the caller
+func (h *AuthHandler) GetTwoFactorStatus(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var enabled, required bool
+	var enabledAt sql.NullTime
+	var remaining int
+	err = h.db.DB.QueryRow(`
+		SELECT u.totp_enabled, u.totp_enabled_at, COALESCE(p.required, FALSE),
+		       (SELECT COUNT(*) FROM user_recovery_codes r WHERE r.user_id = u.id AND r.used_at IS NULL)
+		FROM users u
+		LEFT JOIN mfa_policies p ON p.role = u.role
+		WHERE u.id = $1`,
+		userID,
+	).Scan(&enabled, &enabledAt, &required, &remaining)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch 2FA status"})
+		return
+	}
+
+	status := gin.H{
+		"enabled":                  enabled,
+		"required":                 required,
+		"recovery_codes_remaining": remaining,
+	}
+	if enabledAt.Valid {
+		status["enabled_at"] = enabledAt.Time
+	}
+	c.JSON(http.StatusOK, gin.H{"data": status})
+}
+
+// SetupTwoFactor generates a pending TOTP secret and returns its provisioning
+// URI and QR code. 2FA is not active until confirmed via EnableTwoFactor.
+func (h *AuthHandler) SetupTwoFactor(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var email string
+	var enabled bool
+	err = h.db.DB.QueryRow("SELECT email, totp_enabled FROM users WHERE id = $1", userID).Scan(&email, &enabled)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	encrypted, err := cryptoutil.Encrypt(secret, string(h.jwtSecret))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to secure secret"})
+		return
+	}
+
+	_, err = h.db.DB.Exec("UPDATE users SET totp_secret = $1, totp_last_step = 0, updated_at = NOW() WHERE id = $2", encrypted, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+		return
+	}
+	middleware.AuditEntity(c, userID, nil)
+
+	uri := utils.TOTPProvisioningURI(secret, totpIssuer, email)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Scan the QR code with your authenticator app, then confirm with a code",
+		"data": gin.H{
+			"secret":           secret,
+			"provisioning_uri": uri,
+			"qr_code":          "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		},
+	})
+}
+
+// EnableTwoFactor confirms enrollment with a code from the authenticator app
+// and returns one-time recovery codes
+func (h *AuthHandler) EnableTwoFactor(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	middleware.AuditEntity(c, userID, nil)
+
+	var input TOTPCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Verification code is required"})
+		return
+	}
+
+	reqID, _ := c.Get("request_id")
+
+	var email string
+	var enabled bool
+	var secret sql.NullString
+	var lastStep int64
+	var tokenVersion int
+	err = h.db.DB.QueryRow("SELECT email, totp_enabled, totp_secret, totp_last_step, token_version FROM users WHERE id = $1", userID).
+		Scan(&email, &enabled, &secret, &lastStep, &tokenVersion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if enabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if !secret.Valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start setup before enabling two-factor authentication"})
+		return
+	}
+
+	ok, err := h.checkTOTP(userID, secret.String, lastStep, input.Code)
+	if err != nil {
+		log.Printf("[%v] enableTwoFactor: totp check failed: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "trace_id": reqID})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	tx, err := h.db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction start failed"})
+		return
+	}
+
+	_, err = tx.Exec("UPDATE users SET totp_enabled = TRUE, totp_enabled_at = NOW(), updated_at = NOW() WHERE id = $1", userID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("[%v] enableTwoFactor: recovery codes failed: %v", reqID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes", "trace_id": reqID})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		return
+	}
+
+	// The caller just proved possession of the second factor
+	tokenString, err := h.issueToken(userID, email, tokenVersion, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Two-factor authentication enabled. Store your recovery codes somewhere safe; they will not be shown again.",
+		"recovery_codes": codes,
+		"token":          tokenString,
+		"expires_in":     h.tokenExpiration.Seconds(),
+		"token_type":     "Bearer",
+	})
+}
+
+// DisableTwoFactor turns off 2FA after re-checking the password and a code.
+// Not allowed while the user's role has 2FA made mandatory.
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	middleware.AuditEntity(c, userID, nil)
+
+	var input DisableTwoFactorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password and verification code are required"})
+		return
+	}
+
+	var passwordHash string
+	var enabled, required bool
+	var secret sql.NullString
+	var lastStep int64
+	err = h.db.DB.QueryRow(`
+		SELECT u.password_hash, u.totp_enabled, u.totp_secret, u.totp_last_step, COALESCE(p.required, FALSE)
+		FROM users u
+		LEFT JOIN mfa_policies p ON p.role = u.role
+		WHERE u.id = $1`,
+		userID,
+	).Scan(&passwordHash, &enabled, &secret, &lastStep, &required)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if required {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is mandatory for your role"})
+		return
+	}
+	if !utils.CheckPasswordHash(input.Password, passwordHash) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	ok, err := h.checkTOTP(userID, secret.String, lastStep, input.Code)
+	if err != nil || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	tx, err := h.db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction start failed"})
+		return
+	}
+	_, err = tx.Exec(`
+		UPDATE users
+		SET totp_enabled = FALSE, totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0, updated_at = NOW()
+		WHERE id = $1`, userID)
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = $1", userID)
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes after checking a TOTP code
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	middleware.AuditEntity(c, userID, nil)
+
+	var input TOTPCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Verification code is required"})
+		return
+	}
+
+	var enabled bool
+	var secret sql.NullString
+	var lastStep int64
+	err = h.db.DB.QueryRow("SELECT totp_enabled, totp_secret, totp_last_step FROM users WHERE id = $1", userID).
+		Scan(&enabled, &secret, &lastStep)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if !enabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	ok, err := h.checkTOTP(userID, secret.String, lastStep, input.Code)
+	if err != nil || !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code"})
+		return
+	}
+
+	tx, err := h.db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction start failed"})
+		return
+	}
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Recovery codes regenerated; previous codes no longer work",
+		"recovery_codes": codes,
+	})
+}
+
+// ListMFAPolicies returns the per-role 2FA requirements
+func (h *AuthHandler) ListMFAPolicies(c *gin.Context) {
+	rows, err := h.db.DB.Query("SELECT role, required, updated_at FROM mfa_policies ORDER BY role")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch MFA policies"})
+		return
+	}
+	defer rows.Close()
+
+	policies := []gin.H{}
+	for rows.Next() {
+		var role string
+		var required bool
+		var updatedAt time.Time
+		if err := rows.Scan(&role, &required, &updatedAt); err != nil {
+			continue
+		}
+		policies = append(policies, gin.H{"role": role, "required": required, "updated_at": updatedAt})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": policies})
+}
+
+// UpdateMFAPolicy makes 2FA mandatory (or optional) for a role
+func (h *AuthHandler) UpdateMFAPolicy(c *gin.Context) {
+	adminID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	role := c.Param("role")
+	if !permissions.IsValidRole(role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+	var input UpdateMFAPolicyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "required (true/false) is required"})
+		return
+	}
+
+	auditBefore(c, h.db, mfaPolicySnapshotQuery, role)
+
+	_, err = h.db.DB.Exec(`
+		INSERT INTO mfa_policies (role, required, updated_by, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (role) DO UPDATE SET
+			required = EXCLUDED.required,
+			updated_by = EXCLUDED.updated_by,
+			updated_at = NOW()`,
+		role, *input.Required, adminID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update MFA policy"})
+		return
+	}
+
+	auditAfter(c, h.db, mfaPolicySnapshotQuery, role)
+
+	log.Printf("Admin %d set MFA required=%v for role %s", adminID, *input.Required, role)
+	c.JSON(http.StatusOK, gin.H{"message": "MFA policy updated", "role": role, "required": *input.Required})
+}
+
+// ResetUserTwoFactor clears a user's 2FA enrollment (e.g. lost device) and
+// revokes their sessions. The user must enroll again at next login.
+func (h *AuthHandler) ResetUserTwoFactor(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	tx, err := h.db.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction start failed"})
+		return
+	}
+
+	result, err := tx.Exec(`
+		UPDATE users
+		SET totp_enabled = FALSE, totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0,
+		    token_version = token_version + 1, updated_at = NOW()
+		WHERE id = $1`, userID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM user_recovery_codes WHERE user_id = $1", userID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction commit failed"})
+		return
+	}
+
+	log.Printf("Admin reset 2FA for user ID: %d", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset", "user_id": userID})
+}
@@ -4,7 +4,9 @@ import (
 	"net/http"
 
 	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
-	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/Zolet-hash/smart-rentals/internal/repository"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,112 +16,71 @@ type CreateUnitInput struct {
 	UnitPrice float64 `json:"unit_price" binding:"required"`
 }
 
-func CreateUnit(db *database.Database) gin.HandlerFunc {
+func CreateUnit(svc *services.UnitService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		landlordID, err := middleware.GetUserID(c)
+		userID, err := middleware.GetUserID(c)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
+		propertyID, ok := intParam(c, "propertyId", "Invalid property ID")
+		if !ok {
+			return
+		}
 
-		propertyID := c.Param("propertyId")
 		var input CreateUnitInput
 		if err := c.ShouldBindJSON(&input); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// Enforce Ownership: Property must belong to landlord
-		var exists bool
-		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM properties WHERE id = $1 AND landlord_id = $2)", propertyID, landlordID).Scan(&exists)
-		if err != nil || !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found or unauthorized"})
-			return
-		}
-
-		// Insert Unit
-		query := `
-			INSERT INTO units (property_id, unit_name, unit_type, unit_price, vacancy)
-			VALUES ($1, $2, $3, $4, true)
-			RETURNING id, created_at
-		`
-		var unitID int
-		var createdAt string
-		err = db.QueryRow(query, propertyID, input.UnitName, input.UnitType, input.UnitPrice).Scan(&unitID, &createdAt)
+		// The property must belong to (or be delegated to) the caller; new
+		// units are vacant
+		unit, err := svc.CreateUnit(c.Request.Context(), userID, propertyID, services.UnitInput{
+			UnitName:  input.UnitName,
+			UnitType:  input.UnitType,
+			UnitPrice: input.UnitPrice,
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create unit"})
+			rentalError(c, "createUnit", "Failed to create unit", err)
 			return
 		}
 
+		auditAfterRecord(c, svc, int(unit.ID))
+
 		c.JSON(http.StatusCreated, gin.H{
 			"message": "Unit created successfully",
-			"data": gin.H{
-				"id":          unitID,
-				"property_id": propertyID,
-				"unit_name":   input.UnitName,
-				"unit_type":   input.UnitType,
-				"unit_price":  input.UnitPrice,
-				"vacancy":     true,
-				"created_at":  createdAt,
-			},
+			"data":    unit,
 		})
 	}
 }
 
-func GetUnitsByProperty(db *database.Database) gin.HandlerFunc {
+// GetUnitsByProperty lists a property's units a page at a time. Query: q
+// (unit name), min_amount and max_amount (price), sort (unit_name,
+// unit_price; prefix - for descending), limit, cursor, include_total.
+func GetUnitsByProperty(svc *services.UnitService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		landlordID, err := middleware.GetUserID(c)
+		userID, err := middleware.GetUserID(c)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
-
-		propertyID := c.Param("propertyId")
-
-		// Verify ownership of property first
-		var exists bool
-		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM properties WHERE id = $1 AND landlord_id = $2)", propertyID, landlordID).Scan(&exists)
-		if err != nil || !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Property not found or unauthorized"})
+		propertyID, ok := intParam(c, "propertyId", "Invalid property ID")
+		if !ok {
 			return
 		}
 
-		query := `
-			SELECT id, unit_name, unit_type, unit_price, vacancy
-			FROM units
-			WHERE property_id = $1
-			ORDER BY unit_name ASC
-		`
+		page, filter, ok := listParams(c, repository.UnitList)
+		if !ok {
+			return
+		}
 
-		rows, err := db.Query(query, propertyID)
+		units, err := svc.ListUnits(c.Request.Context(), userID, propertyID, page, filter)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch units"})
-			return
-		}
-		defer rows.Close()
-
-		units := []gin.H{}
-		for rows.Next() {
-			var u struct {
-				ID        int
-				UnitName  string
-				UnitType  string
-				UnitPrice float64
-				Vacancy   bool
-			}
-			if err := rows.Scan(&u.ID, &u.UnitName, &u.UnitType, &u.UnitPrice, &u.Vacancy); err != nil {
-				continue
-			}
-			units = append(units, gin.H{
-				"id":         u.ID,
-				"unit_name":  u.UnitName,
-				"unit_type":  u.UnitType,
-				"unit_price": u.UnitPrice,
-				"vacancy":    u.Vacancy,
-			})
-		}
-
-		c.JSON(http.StatusOK, gin.H{"data": units})
+			rentalError(c, "listUnits", "Failed to fetch units", err)
+			return
+		}
+		writePage(c, units)
 	}
 }
 
@@ -129,16 +90,26 @@ type UpdateUnitInput struct {
 	UnitPrice *float64 `json:"unit_price"`
 }
 
-func UpdateUnit(db *database.Database) gin.HandlerFunc {
+// unitParams parses :propertyId and :unitId
+func unitParams(c *gin.Context) (propertyID, unitID int, ok bool) {
+	if propertyID, ok = intParam(c, "propertyId", "Invalid property ID"); !ok {
+		return 0, 0, false
+	}
+	unitID, ok = intParam(c, "unitId", "Invalid unit ID")
+	return propertyID, unitID, ok
+}
+
+func UpdateUnit(svc *services.UnitService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		landlordID, err := middleware.GetUserID(c)
+		userID, err := middleware.GetUserID(c)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
-
-		propertyID := c.Param("propertyId")
-		unitID := c.Param("unitId")
+		propertyID, unitID, ok := unitParams(c)
+		if !ok {
+			return
+		}
 
 		var input UpdateUnitInput
 		if err := c.ShouldBindJSON(&input); err != nil {
@@ -146,68 +117,53 @@ func UpdateUnit(db *database.Database) gin.HandlerFunc {
 			return
 		}
 
-		// Verify ownership: Unit -> Property -> Landlord
-		var exists bool
-		query := `
-			SELECT EXISTS(
-				SELECT 1 FROM units u
-				JOIN properties p ON u.property_id = p.id
-				WHERE u.id = $1 AND p.id = $2 AND p.landlord_id = $3
-			)`
-		err = db.QueryRow(query, unitID, propertyID, landlordID).Scan(&exists)
-		if err != nil || !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Unit not found or unauthorized"})
+		// Verify access: Unit -> Property -> Landlord/delegate
+		ctx := c.Request.Context()
+		if _, err := svc.GetUnit(ctx, userID, permissions.UnitsWrite, propertyID, unitID); err != nil {
+			rentalError(c, "updateUnit", "Failed to update unit", err)
 			return
 		}
+		auditBeforeRecord(c, svc, unitID)
 
-		// Update logic... (dynamic query like others)
-		// For simplicity, let's just do a direct update since it's a small object
-		_, err = db.Exec(`
-			UPDATE units SET 
-				unit_name = COALESCE($1, unit_name), 
-				unit_type = COALESCE($2, unit_type), 
-				unit_price = COALESCE($3, unit_price),
-				updated_at = NOW()
-			WHERE id = $4`,
-			input.UnitName, input.UnitType, input.UnitPrice, unitID)
-
+		err = svc.UpdateUnit(ctx, userID, propertyID, unitID, repository.UnitUpdate{
+			UnitName:  input.UnitName,
+			UnitType:  input.UnitType,
+			UnitPrice: input.UnitPrice,
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update unit"})
+			rentalError(c, "updateUnit", "Failed to update unit", err)
 			return
 		}
 
+		auditAfterRecord(c, svc, unitID)
+
 		c.JSON(http.StatusOK, gin.H{"message": "Unit updated successfully"})
 	}
 }
 
-func DeleteUnit(db *database.Database) gin.HandlerFunc {
+func DeleteUnit(svc *services.UnitService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		landlordID, err := middleware.GetUserID(c)
+		userID, err := middleware.GetUserID(c)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 			return
 		}
+		propertyID, unitID, ok := unitParams(c)
+		if !ok {
+			return
+		}
 
-		propertyID := c.Param("propertyId")
-		unitID := c.Param("unitId")
-
-		// Verify ownership
-		var exists bool
-		query := `
-			SELECT EXISTS(
-				SELECT 1 FROM units u
-				JOIN properties p ON u.property_id = p.id
-				WHERE u.id = $1 AND p.id = $2 AND p.landlord_id = $3
-			)`
-		err = db.QueryRow(query, unitID, propertyID, landlordID).Scan(&exists)
-		if err != nil || !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Unit not found or unauthorized"})
+		// Verify access
+		ctx := c.Request.Context()
+		if _, err := svc.GetUnit(ctx, userID, permissions.UnitsWrite, propertyID, unitID); err != nil {
+			rentalError(c, "deleteUnit", "Failed to delete unit", err)
 			return
 		}
+		auditBeforeRecord(c, svc, unitID)
 
-		_, err = db.Exec("DELETE FROM units WHERE id = $1", unitID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete unit. Ensure it has no active tenants."})
+		// Units with tenants are kept
+		if err := svc.DeleteUnit(ctx, userID, propertyID, unitID); err != nil {
+			rentalError(c, "deleteUnit", "Failed to delete unit", err)
 			return
 		}
 
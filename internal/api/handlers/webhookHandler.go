@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
+	"github.com/Zolet-hash/smart-rentals/internal/events"
+	"github.com/Zolet-hash/smart-rentals/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	Service *services.WebhookService
+}
+
+func NewWebhookHandler(service *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{Service: service}
+}
+
+type CreateWebhookInput struct {
+	URL         string   `json:"url" binding:"required"`
+	Description string   `json:"description"`
+	Events      []string `json:"events" binding:"required,min=1"`
+}
+
+type UpdateWebhookInput struct {
+	URL         *string  `json:"url"`
+	Description *string  `json:"description"`
+	Events      []string `json:"events"`
+	Active      *bool    `json:"active"`
+}
+
+// webhookError maps service errors to responses
+func webhookError(c *gin.Context, fn string, err error) {
+	switch {
+	case errors.Is(err, services.ErrWebhookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+	case errors.Is(err, services.ErrInvalidWebhookURL), errors.Is(err, services.ErrInvalidEventType):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "supported_events": events.Types})
+	default:
+		reqID, _ := c.Get("request_id")
+		log.Printf("[%v] %s: %v", reqID, fn, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "trace_id": reqID})
+	}
+}
+
+func webhookIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// List returns the landlord's webhook endpoints
+func (h *WebhookHandler) List(c *gin.Context) {
+	landlordID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	endpoints, err := h.Service.ListEndpoints(landlordID)
+	if err != nil {
+		webhookError(c, "listWebhooks", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": endpoints, "supported_events": events.Types})
+}
+
+// Create registers an endpoint. The signing secret is only shown in this response.
+func (h *WebhookHandler) Create(c *gin.Context) {
+	landlordID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input CreateWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	endpoint, secret, err := h.Service.CreateEndpoint(landlordID, input.URL, input.Description, input.Events)
+	if err != nil {
+		webhookError(c, "createWebhook", err)
+		return
+	}
+	middleware.AuditEntity(c, endpoint.ID, &landlordID)
+	middleware.AuditAfter(c, endpoint)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Webhook created successfully. Store the secret now, it will not be shown again.",
+		"data":    endpoint,
+		"secret":  secret,
+	})
+}
+
+// Update changes an endpoint's URL, description, events or active flag
+func (h *WebhookHandler) Update(c *gin.Context) {
+	landlordID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	endpointID, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input UpdateWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Service.UpdateEndpoint(landlordID, endpointID, input.URL, input.Description, input.Events, input.Active); err != nil {
+		webhookError(c, "updateWebhook", err)
+		return
+	}
+	middleware.AuditEntity(c, endpointID, &landlordID)
+	middleware.AuditAfter(c, input)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook updated successfully"})
+}
+
+// Delete removes an endpoint
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	landlordID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	endpointID, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Service.DeleteEndpoint(landlordID, endpointID); err != nil {
+		webhookError(c, "deleteWebhook", err)
+		return
+	}
+	middleware.AuditEntity(c, endpointID, &landlordID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// RotateSecret issues a new signing secret
+func (h *WebhookHandler) RotateSecret(c *gin.Context) {
+	landlordID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	endpointID, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	secret, err := h.Service.RotateSecret(landlordID, endpointID)
+	if err != nil {
+		webhookError(c, "rotateWebhookSecret", err)
+		return
+	}
+	middleware.AuditEntity(c, endpointID, &landlordID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Secret rotated. Store the secret now, it will not be shown again.",
+		"secret":  secret,
+	})
+}
+
+// Deliveries returns the delivery log of an endpoint (limit query param, default 50, max 200)
+func (h *WebhookHandler) Deliveries(c *gin.Context) {
+	landlordID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	endpointID, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	deliveries, err := h.Service.ListDeliveries(landlordID, endpointID, limit)
+	if err != nil {
+		webhookError(c, "listWebhookDeliveries", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": deliveries})
+}
+
+// Test sends a signed webhook.test event right away and returns the delivery result
+func (h *WebhookHandler) Test(c *gin.Context) {
+	landlordID, err := middleware.GetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	endpointID, ok := webhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.Service.SendTest(c.Request.Context(), landlordID, endpointID)
+	if err != nil {
+		webhookError(c, "testWebhook", err)
+		return
+	}
+	middleware.AuditEntity(c, endpointID, &landlordID)
+	c.JSON(http.StatusOK, gin.H{
+		"delivered": delivery.Status == services.WebhookSucceeded,
+		"data":      delivery,
+	})
+}
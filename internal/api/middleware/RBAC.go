@@ -1,73 +1,58 @@
 package middleware
 
 import (
-	"database/sql"
 	"net/http"
 
-	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
 	"github.com/gin-gonic/gin"
 )
 
-func RequireRole(db *database.Database, requiredRole string) gin.HandlerFunc {
+// RequireRole allows only users with one of the given roles. The role is read
+// from the context set by AuthMiddleware, so no extra query is made.
+func RequireRole(roles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-
-		// Get user ID from context (set by JWT auth middleware)
-		userIDValue, exists := c.Get("user_id")
-		if !exists {
+		role := GetRole(c)
+		if role == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "unauthorized: user id not found in context",
+				"error": "unauthorized: user role not found in context",
 			})
 			return
 		}
 
-		var userID uint
-		switch v := userIDValue.(type) {
-		case uint:
-			userID = v
-		case float64:
-			userID = uint(v)
-		case int:
-			userID = uint(v)
-		default:
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "invalid user id type",
-			})
-			return
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
 		}
 
-		// Fetch user role only (fast path)
-		var role string
-		err := db.DB.QueryRowContext(
-			c.Request.Context(),
-			`SELECT role FROM users WHERE id = $1`,
-			userID,
-		).Scan(&role)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "forbidden: insufficient permissions",
+		})
+	}
+}
 
-		if err == sql.ErrNoRows {
+// RequirePermission allows only users whose role grants perm. For staff roles
+// this is a ceiling: handlers still scope data to the properties delegated to
+// them via accessible_property_ids.
+func RequirePermission(perm permissions.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := GetRole(c)
+		if role == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "user not found",
-			})
-			return
-		}
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-				"error": "database error",
+				"error": "unauthorized: user role not found in context",
 			})
 			return
 		}
 
-		// Check role
-		if role != requiredRole {
+		if !permissions.Has(role, perm) {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
-				"error": "forbidden: insufficient permissions",
+				"error":      "forbidden: insufficient permissions",
+				"permission": perm,
 			})
 			return
 		}
 
-		// Store minimal user info in context
-		c.Set("userID", userID)
-		c.Set("role", role)
-
 		c.Next()
 	}
 }
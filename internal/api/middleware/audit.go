@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// AuditRecorder appends entries to the audit log
+type AuditRecorder interface {
+	Record(ctx context.Context, e models.AuditEntry) error
+}
+
+const auditContextKey = "audit"
+
+// auditDetails is filled in by handlers while the request runs
+type auditDetails struct {
+	entityID   string
+	landlordID *int
+	before     interface{}
+	after      interface{}
+}
+
+// auditEntityParams are the path params tried, in order, when a handler
+// does not name the affected entity itself
+var auditEntityParams = []string{"id", "tenantId", "unitId", "propertyId", "userId", "orgId", "role"}
+
+// Audit records a successful request to the audit log under the given action
+// (e.g. "tenant.update") and entity type. Handlers attach the affected entity
+// and before/after snapshots with AuditEntity, AuditBefore and AuditAfter.
+// Requests answered with an error status are not recorded.
+func Audit(recorder AuditRecorder, action, entityType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		details := &auditDetails{}
+		c.Set(auditContextKey, details)
+
+		c.Next()
+
+		if c.Writer.Status() >= 400 {
+			return
+		}
+
+		reqID, _ := c.Get("request_id")
+		requestID, _ := reqID.(string)
+		entry := models.AuditEntry{
+			ActorRole:  GetRole(c),
+			RequestID:  requestID,
+			Action:     action,
+			EntityType: entityType,
+			EntityID:   details.entityID,
+			LandlordID: details.landlordID,
+			Before:     details.before,
+			After:      details.after,
+			IPAddress:  c.ClientIP(),
+		}
+		if userID, err := GetUserID(c); err == nil {
+			entry.ActorID = &userID
+		}
+		if entry.EntityID == "" {
+			for _, p := range auditEntityParams {
+				if v := c.Param(p); v != "" {
+					entry.EntityID = v
+					break
+				}
+			}
+		}
+
+		// The change is already committed; a failed audit write must be loud but cannot undo it
+		if err := recorder.Record(c.Request.Context(), entry); err != nil {
+			log.Printf("[%v] audit: failed to record %s on %s %s: %v", reqID, action, entityType, entry.EntityID, err)
+		}
+	}
+}
+
+func getAuditDetails(c *gin.Context) *auditDetails {
+	v, ok := c.Get(auditContextKey)
+	if !ok {
+		return nil
+	}
+	d, _ := v.(*auditDetails)
+	return d
+}
+
+// Auditing reports whether the request is being audited, so handlers can
+// skip loading snapshots nobody will record
+func Auditing(c *gin.Context) bool {
+	return getAuditDetails(c) != nil
+}
+
+// AuditEntity names the affected entity and the landlord owning it. landlordID
+// may be nil for records not tied to a landlord (e.g. user accounts).
+func AuditEntity(c *gin.Context, entityID interface{}, landlordID *int) {
+	if d := getAuditDetails(c); d != nil {
+		d.entityID = fmt.Sprint(entityID)
+		d.landlordID = landlordID
+	}
+}
+
+// AuditBefore attaches the entity's state before the change
+func AuditBefore(c *gin.Context, v interface{}) {
+	if d := getAuditDetails(c); d != nil {
+		d.before = v
+	}
+}
+
+// AuditAfter attaches the entity's state after the change
+func AuditAfter(c *gin.Context, v interface{}) {
+	if d := getAuditDetails(c); d != nil {
+		d.after = v
+	}
+}
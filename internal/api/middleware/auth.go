@@ -1,17 +1,20 @@
 package middleware
 
 import (
+	"database/sql"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/Zolet-hash/smart-rentals/internal/database"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/time/rate"
 )
 
-// AuthMiddleware verifies JWT tokens in incoming requests
-func AuthMiddleware(jwtSecret []byte) gin.HandlerFunc {
+// AuthMiddleware verifies JWT tokens in incoming requests.
+// Tokens whose "ver" claim is older than users.token_version (bumped on
+// password change/reset) are rejected, which revokes existing sessions.
+func AuthMiddleware(db *database.Database, jwtSecret []byte) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -67,27 +70,74 @@ func AuthMiddleware(jwtSecret []byte) gin.HandlerFunc {
 			}
 		}
 
+		// Purpose-bound tokens (e.g. the MFA login challenge) are not sessions
+		if purpose, ok := claims["purpose"].(string); ok && purpose != "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+
+		// Check token has not been revoked
+		userID, ok := claims["user_id"].(float64)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+			c.Abort()
+			return
+		}
+		tokenVersion, _ := claims["ver"].(float64) // tokens issued before versioning count as 0
+
+		var currentVersion int
+		var role string
+		err = db.QueryRowContext(c.Request.Context(),
+			"SELECT token_version, role FROM users WHERE id = $1", int(userID),
+		).Scan(&currentVersion, &role)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			c.Abort()
+			return
+		}
+		if int(tokenVersion) != currentVersion {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked, please log in again"})
+			c.Abort()
+			return
+		}
+
 		// Set user information in context
 		c.Set("user_id", claims["user_id"])
 		c.Set("email", claims["email"])
+		c.Set("role", role)
+		mfa, _ := claims["mfa"].(bool)
+		c.Set("mfa", mfa)
 
 		c.Next()
 	}
 }
 
-// RateLimiter middleware to prevent brute force attacks
-func RateLimiter() gin.HandlerFunc {
-	limiter := rate.NewLimiter(rate.Every(time.Second), 10)
+// TokenFromQuery lets clients that cannot set headers, such as the browser
+// EventSource, send the JWT as ?access_token=. It must run before
+// AuthMiddleware; a header, when present, takes precedence. Keep such routes
+// out of request logs, since the URL then carries the token.
+func TokenFromQuery() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !limiter.Allow() {
-			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
-			c.Abort()
-			return
+		if c.GetHeader("Authorization") == "" {
+			if token := c.Query("access_token"); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
 		}
 		c.Next()
 	}
 }
 
+// GetRole retrieves the authenticated user's role, loaded by AuthMiddleware
+func GetRole(c *gin.Context) string {
+	return c.GetString("role")
+}
+
 // GetUserID retrieves the authenticated user ID from the context
 func GetUserID(c *gin.Context) (int, error) {
 	uid, exists := c.Get("user_id")
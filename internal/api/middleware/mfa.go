@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireMFA blocks sessions that did not complete a two-factor login when
+// the user's role has 2FA made mandatory in mfa_policies. Must run after
+// AuthMiddleware, which sets the "mfa" flag from the token claims.
+func RequireMFA(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		var required bool
+		err = db.QueryRowContext(c.Request.Context(), `
+			SELECT COALESCE(p.required, FALSE)
+			FROM users u
+			LEFT JOIN mfa_policies p ON p.role = u.role
+			WHERE u.id = $1`,
+			userID,
+		).Scan(&required)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "database error"})
+			return
+		}
+
+		if required && !c.GetBool("mfa") {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":        "two-factor authentication is required for your role",
+				"mfa_required": true,
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// KeyFunc derives the rate limit bucket for a request. Returning "" skips limiting.
+type KeyFunc func(c *gin.Context) string
+
+// KeyedLimiter keeps one token bucket per key (IP, email, user...) so one
+// noisy client cannot exhaust the budget of everyone else. Memory is bounded:
+// at most maxKeys buckets are kept and idle ones are evicted LRU-first.
+type KeyedLimiter struct {
+	mu      sync.Mutex
+	limit   rate.Limit
+	burst   int
+	maxKeys int
+	idleTTL time.Duration
+	entries map[string]*list.Element
+	lru     *list.List // front = most recently used
+}
+
+type limiterEntry struct {
+	key      string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewKeyedLimiter allows perMinute requests per key with the given burst,
+// tracking at most maxKeys keys and forgetting keys idle for idleTTL
+func NewKeyedLimiter(perMinute float64, burst, maxKeys int, idleTTL time.Duration) *KeyedLimiter {
+	return &KeyedLimiter{
+		limit:   rate.Limit(perMinute / 60),
+		burst:   burst,
+		maxKeys: maxKeys,
+		idleTTL: idleTTL,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Allow reports whether a request for key may proceed and, if not, how long
+// the caller should wait before retrying
+func (l *KeyedLimiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var entry *limiterEntry
+	if el, ok := l.entries[key]; ok {
+		entry = el.Value.(*limiterEntry)
+		l.lru.MoveToFront(el)
+	} else {
+		entry = &limiterEntry{key: key, limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.entries[key] = l.lru.PushFront(entry)
+	}
+	entry.lastSeen = now
+	l.evict(now)
+
+	r := entry.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return false, time.Minute
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now) // don't consume a token for a rejected request
+		return false, delay
+	}
+	return true, 0
+}
+
+// evict drops idle buckets and trims to maxKeys. Caller must hold l.mu.
+func (l *KeyedLimiter) evict(now time.Time) {
+	for el := l.lru.Back(); el != nil; el = l.lru.Back() {
+		entry := el.Value.(*limiterEntry)
+		if l.lru.Len() <= l.maxKeys && now.Sub(entry.lastSeen) < l.idleTTL {
+			return
+		}
+		l.lru.Remove(el)
+		delete(l.entries, entry.key)
+	}
+}
+
+// RateLimit rejects requests with 429 and a Retry-After header once the
+// bucket selected by keyFn is exhausted
+func RateLimit(limiter *KeyedLimiter, keyFn KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := keyFn(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if ok, retryAfter := limiter.Allow(key); !ok {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Too many requests",
+				"retry_after": seconds,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// KeyByIP buckets requests by client IP
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUserID buckets authenticated requests by user. Must run after AuthMiddleware.
+func KeyByUserID(c *gin.Context) string {
+	userID, err := GetUserID(c)
+	if err != nil {
+		return ""
+	}
+	return "user:" + strconv.Itoa(userID)
+}
+
+// maxKeyedBodySize caps how much of a request body is buffered to read a key
+const maxKeyedBodySize = 1 << 20
+
+// KeyByJSONField buckets requests by a string field of the JSON body, e.g.
+// the email on login. The body is restored so handlers can still bind it.
+// The field is read the way handlers bind it, case-insensitively with the
+// last duplicate winning, so a key cannot be dodged by spelling it "Email".
+func KeyByJSONField(field string) KeyFunc {
+	payloadType := reflect.StructOf([]reflect.StructField{{
+		Name: "Value",
+		Type: reflect.TypeOf(""),
+		Tag:  reflect.StructTag(`json:"` + field + `"`),
+	}})
+	return func(c *gin.Context) string {
+		if c.Request.Body == nil {
+			return ""
+		}
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxKeyedBodySize))
+		c.Request.Body.Close()
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			return ""
+		}
+
+		payload := reflect.New(payloadType)
+		if err := json.Unmarshal(body, payload.Interface()); err != nil {
+			return ""
+		}
+		value := strings.ToLower(strings.TrimSpace(payload.Elem().Field(0).String()))
+		if value == "" {
+			return ""
+		}
+		return field + ":" + value
+	}
+}
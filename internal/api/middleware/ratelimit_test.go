@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestKeyByJSONField(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	key := KeyByJSONField("email")
+	tests := []struct {
+		body, want string
+	}{
+		{`{"email":"Victim@Example.com"}`, "email:victim@example.com"},
+		// Handlers bind field names case-insensitively, the last one winning
+		{`{"Email":"victim@example.com"}`, "email:victim@example.com"},
+		{`{"email":"rand-1","EMAIL":"victim@example.com"}`, "email:victim@example.com"},
+		{`{"password":"x"}`, ""},
+		{`not json`, ""},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(tt.body))
+		if got := key(c); got != tt.want {
+			t.Errorf("key(%s) = %q, want %q", tt.body, got, tt.want)
+		}
+		// The handler still reads the whole body
+		if rest, _ := io.ReadAll(c.Request.Body); string(rest) != tt.body {
+			t.Errorf("body after keying = %q, want %q", rest, tt.body)
+		}
+	}
+}
+
+func TestKeyedLimiter(t *testing.T) {
+	l := NewKeyedLimiter(1, 2, 2, time.Hour)
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("email:a"); !ok {
+			t.Fatalf("request %d within the burst refused", i+1)
+		}
+	}
+	ok, retry := l.Allow("email:a")
+	if ok || retry <= 0 {
+		t.Errorf("request past the burst: allowed = %v, retry after %v", ok, retry)
+	}
+	// Buckets are per key
+	if ok, _ := l.Allow("email:b"); !ok {
+		t.Error("another key refused")
+	}
+	// At most maxKeys buckets are kept, least recently used dropped first
+	l.Allow("email:c")
+	if len(l.entries) != 2 {
+		t.Errorf("%d buckets kept, want 2", len(l.entries))
+	}
+	if _, kept := l.entries["email:a"]; kept {
+		t.Error("least recently used bucket kept")
+	}
+}
@@ -1,14 +1,31 @@
 package api
 
 import (
+	"context"
+	"log"
+	"time"
+
 	"github.com/Zolet-hash/smart-rentals/internal/api/handlers"
 	"github.com/Zolet-hash/smart-rentals/internal/api/middleware"
 	"github.com/Zolet-hash/smart-rentals/internal/config"
 	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/events"
+	"github.com/Zolet-hash/smart-rentals/internal/notify"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/Zolet-hash/smart-rentals/internal/repository"
 	"github.com/Zolet-hash/smart-rentals/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	apiPrefix   = "/api/v1"
+	streamRoute = "/events/stream" // relative to apiPrefix
+)
+
+// StreamPath is the full event stream path; its query carries the access
+// token, so request logging skips it
+const StreamPath = apiPrefix + streamRoute
+
 func SetupRoutes(
 	r *gin.Engine,
 	db *database.Database,
@@ -18,18 +35,102 @@ func SetupRoutes(
 	r.Use(middleware.CORS(cfg))
 	r.Use(middleware.RequestID())
 
-	authHandler := handlers.NewAuthHandler(db, []byte(cfg.JWT.Secret))
-	paymentSvc := services.NewPaymentService(db, cfg)
+	notifier := notify.NewMux(notify.NewUnconfiguredNotifier(cfg))
+	notifier.Handle(notify.ChannelSMS, notify.NewSMSNotifier(cfg))
+	notifier.Handle(notify.ChannelEmail, notify.NewEmailNotifier(cfg))
+	authHandler := handlers.NewAuthHandler(db, cfg, notifier)
+
+	// Domain events fan out to subscribers such as outbound webhooks
+	bus := events.NewBus()
+	webhookSvc := services.NewWebhookService(db, cfg)
+	bus.Subscribe(webhookSvc.HandleEvent)
+	webhookHandler := handlers.NewWebhookHandler(webhookSvc)
+	go webhookSvc.Run(context.Background(), 15*time.Second)
+
+	// SMS, email and in-app: receipts and alerts from events, reminders and
+	// statements on a schedule
+	notificationSvc := services.NewNotificationService(db, cfg, notifier, bus)
+	bus.Subscribe(notificationSvc.HandleEvent)
+	bus.Subscribe(notificationSvc.HandleInAppEvent)
+	notificationHandler := handlers.NewNotificationHandler(notificationSvc)
+	go notificationSvc.Run(context.Background(), 15*time.Second)
+	go notificationSvc.RunReminders(context.Background(), cfg.Notifications.ReminderInterval)
+	conversationHandler := handlers.NewConversationHandler(services.NewMessagingService(db, notificationSvc))
+	maintenanceHandler := handlers.NewMaintenanceHandler(services.NewMaintenanceService(db, notificationSvc))
+
+	// Expenses; recurring schedules are turned into expenses as they fall due
+	expenseSvc := services.NewExpenseService(db)
+	expenseHandler := handlers.NewExpenseHandler(expenseSvc)
+	go expenseSvc.RunRecurring(context.Background(), time.Hour)
+
+	// Live dashboard updates: bus events are relayed to stream subscribers
+	stream := newEventStream(db, cfg)
+	bus.Subscribe(stream.Publish)
+	streamHandler := handlers.NewStreamHandler(db, stream)
+
+	// Dashboard summaries are cached and refreshed early by payment events
+	dashboardSvc := services.NewDashboardService(db, cfg.Dashboard.CacheTTL)
+	bus.Subscribe(dashboardSvc.HandleEvent)
+	dashboardHandler := handlers.NewDashboardHandler(dashboardSvc)
+	reportHandler := handlers.NewReportHandler(services.NewReportService(db))
+	receiptHandler := handlers.NewReceiptHandler(services.NewReceiptService(db, cfg))
+	importHandler := handlers.NewImportHandler(services.NewImportService(db, bus))
+	statementHandler := handlers.NewStatementHandler(services.NewStatementService(db, bus))
+	taxHandler := handlers.NewTaxHandler(services.NewTaxService(db))
+	accountingHandler := handlers.NewAccountingHandler(services.NewAccountingService(db))
+	searchHandler := handlers.NewSearchHandler(services.NewSearchService(db))
+
+	// Properties, units, tenants and payments are loaded through repositories;
+	// the property, unit and tenant services hold the rules for changing them
+	store := repository.NewPostgres(db)
+	propertySvc := services.NewPropertyService(store)
+	unitSvc := services.NewUnitService(store)
+	tenantSvc := services.NewTenantService(store, bus)
+
+	paymentSvc := services.NewPaymentService(db, cfg, bus)
 	paymentHandler := handlers.NewPaymentHandler(paymentSvc)
+	auditSvc := services.NewAuditService(db)
+	auditHandler := handlers.NewAuditHandler(auditSvc)
+
+	// audit records successful state changes to the append-only audit log
+	audit := func(action, entityType string) gin.HandlerFunc {
+		return middleware.Audit(auditSvc, action, entityType)
+	}
+
+	// Rate limiters for sensitive routes, keyed per client so one attacker
+	// cannot exhaust everyone's budget (requests/minute, burst, max keys, idle TTL)
+	ipLimiter := middleware.NewKeyedLimiter(20, 10, 50000, 30*time.Minute)
+	accountLimiter := middleware.NewKeyedLimiter(10, 5, 50000, 30*time.Minute)
+	userLimiter := middleware.NewKeyedLimiter(10, 5, 50000, 30*time.Minute)
+	limitByIP := middleware.RateLimit(ipLimiter, middleware.KeyByIP)
+	limitByEmail := middleware.RateLimit(accountLimiter, middleware.KeyByJSONField("email"))
+	limitByUser := middleware.RateLimit(userLimiter, middleware.KeyByUserID)
+	limitByToken := middleware.RateLimit(accountLimiter, middleware.KeyByJSONField("token"))
 
 	// API v1
-	api := r.Group("/api/v1")
+	api := r.Group(apiPrefix)
 
 	// Public routes
 	api.POST("/login",
-		middleware.RateLimiter(), // <- limit requests
+		limitByIP, // <- limit requests
+		limitByEmail,
 		authHandler.Login,
 	)
+	api.POST("/login/mfa",
+		limitByIP,
+		authHandler.VerifyMFA,
+	)
+	api.POST("/auth/password/forgot",
+		limitByIP,
+		limitByEmail,
+		authHandler.ForgotPassword,
+	)
+	api.POST("/auth/password/reset",
+		limitByIP,
+		limitByToken,
+		audit("user.password_reset", "user"),
+		authHandler.CompletePasswordReset,
+	)
 	// api.GET("/mpesa/validation", handlers.MpesaValidation)
 	// api.POST("/mpesa/confirmation", handlers.MpesaPaymentConfirmation(db))
 
@@ -37,64 +138,231 @@ func SetupRoutes(
 	api.POST("/payments/c2b/validation", paymentHandler.C2BValidation)
 	api.POST("/payments/c2b/confirmation", paymentHandler.C2BConfirmation)
 
+	// Receipt verification, linked from the QR code on each receipt
+	api.GET("/receipts/verify/:code", limitByIP, receiptHandler.Verify)
+
+	// Server-sent event stream. EventSource cannot set headers, so the JWT
+	// may also be passed as ?access_token=
+	api.GET(streamRoute,
+		middleware.TokenFromQuery(),
+		middleware.AuthMiddleware(db, []byte(cfg.JWT.Secret)),
+		middleware.RequireMFA(db),
+		streamHandler.Stream,
+	)
+
 	// Protected routes (require authentication)
 	protected := api.Group("")
-	protected.Use(middleware.AuthMiddleware([]byte(cfg.JWT.Secret)))
+	protected.Use(middleware.AuthMiddleware(db, []byte(cfg.JWT.Secret)))
 	{
 		protected.GET("/profile", getUserProfile)
 		protected.POST("/refresh-token", authHandler.RefreshToken)
 		protected.POST("/logout", authHandler.Logout)
+		protected.GET("/me/permissions", handlers.GetMyPermissions(db))
+		protected.GET("/me/notification-preferences", notificationHandler.GetPreferences)
+		protected.PATCH("/me/notification-preferences", audit("user.notification_preferences_update", "user"), notificationHandler.UpdatePreferences)
+
+		// In-app notification center (landlords, staff and tenants)
+		protected.GET("/notifications", notificationHandler.ListInbox)
+		protected.GET("/notifications/unread-count", notificationHandler.UnreadCount)
+		protected.PATCH("/notifications/:id/read", notificationHandler.MarkRead)
+		protected.POST("/notifications/read-all", notificationHandler.MarkAllRead)
+		protected.POST("/me/password", limitByUser, audit("user.change_password", "user"), authHandler.ChangePassword)
+
+		// Two-factor authentication enrollment
+		protected.GET("/me/2fa", authHandler.GetTwoFactorStatus)
+		protected.POST("/me/2fa/setup", limitByUser, audit("user.2fa_setup", "user"), authHandler.SetupTwoFactor)
+		protected.POST("/me/2fa/enable", limitByUser, audit("user.2fa_enable", "user"), authHandler.EnableTwoFactor)
+		protected.POST("/me/2fa/disable", limitByUser, audit("user.2fa_disable", "user"), authHandler.DisableTwoFactor)
+		protected.POST("/me/2fa/recovery-codes", limitByUser, audit("user.recovery_codes_regenerate", "user"), authHandler.RegenerateRecoveryCodes)
 	}
 
 	// Admin routes
 	admin := api.Group("/sudo")
 	admin.Use(
-		middleware.AuthMiddleware([]byte(cfg.JWT.Secret)), // sets user_id
-		middleware.RequireRole(db, "admin"),               // enforces role
+		middleware.AuthMiddleware(db, []byte(cfg.JWT.Secret)), // sets user_id and role
+		middleware.RequirePermission(permissions.UsersManage), // enforces role
+		middleware.RequireMFA(db),                             // enforces 2FA policy
 	)
 	{
-		admin.POST("/register", authHandler.Register)
+		admin.POST("/register", audit("user.create", "user"), authHandler.Register)
 		admin.GET("/users", authHandler.ListUsers)
-		admin.PATCH("/users/:id", authHandler.UpdateUser)
-		admin.DELETE("/users/:id", authHandler.DeleteUser)
-		admin.PATCH("/users/:id/reset-password", authHandler.ResetPassword)
+		admin.PATCH("/users/:id", audit("user.update", "user"), authHandler.UpdateUser)
+		admin.DELETE("/users/:id", audit("user.delete", "user"), authHandler.DeleteUser)
+		admin.PATCH("/users/:id/reset-password", audit("user.reset_password", "user"), authHandler.ResetPassword)
+		admin.PATCH("/users/:id/unlock", audit("user.unlock", "user"), authHandler.UnlockUser)
+		admin.DELETE("/users/:id/2fa", audit("user.2fa_reset", "user"), authHandler.ResetUserTwoFactor)
+		admin.GET("/mfa-policies", authHandler.ListMFAPolicies)
+		admin.GET("/audit", auditHandler.ListAll)
+		admin.GET("/audit/verify", auditHandler.Verify)
+		admin.PUT("/mfa-policies/:role", audit("mfa_policy.update", "mfa_policy"), authHandler.UpdateMFAPolicy)
+		admin.GET("/tax-rates", taxHandler.ListRates)
+		admin.POST("/tax-rates", audit("tax_rate.create", "tax_rate"), taxHandler.AddRate)
+		admin.DELETE("/tax-rates/:id", audit("tax_rate.delete", "tax_rate"), taxHandler.DeleteRate)
 	}
 
-	// Landlord routes
+	// Landlord and staff routes. RequirePermission caps what each role may do;
+	// handlers further scope staff to the properties delegated to them.
 	landlord := api.Group("/")
 	landlord.Use(
-		middleware.AuthMiddleware([]byte(cfg.JWT.Secret)),
+		middleware.AuthMiddleware(db, []byte(cfg.JWT.Secret)),
+		middleware.RequireMFA(db),
 	)
 	{
 		// Properties
-		landlord.POST("/properties", handlers.CreateProperty(db))
-		landlord.GET("/properties", handlers.ListProperties(db))
-		landlord.GET("/properties/:propertyId", handlers.GetProperty(db))
-		landlord.PATCH("/properties/:propertyId", handlers.UpdateProperty(db))
-		landlord.DELETE("/properties/:propertyId", handlers.DeleteProperty(db))
+		landlord.POST("/properties", middleware.RequirePermission(permissions.PropertiesWrite), audit("property.create", "property"), handlers.CreateProperty(propertySvc))
+		landlord.GET("/properties", middleware.RequirePermission(permissions.PropertiesRead), handlers.ListProperties(propertySvc))
+		landlord.GET("/properties/:propertyId", middleware.RequirePermission(permissions.PropertiesRead), handlers.GetProperty(propertySvc))
+		landlord.PATCH("/properties/:propertyId", middleware.RequirePermission(permissions.PropertiesWrite), audit("property.update", "property"), handlers.UpdateProperty(propertySvc))
+		landlord.DELETE("/properties/:propertyId", middleware.RequirePermission(permissions.PropertiesWrite), audit("property.delete", "property"), handlers.DeleteProperty(propertySvc))
 
 		// Units
-		landlord.POST("/properties/:propertyId/units", handlers.CreateUnit(db))
-		landlord.GET("/properties/:propertyId/units", handlers.GetUnitsByProperty(db))
-		landlord.PATCH("/properties/:propertyId/units/:unitId", handlers.UpdateUnit(db))
-		landlord.DELETE("/properties/:propertyId/units/:unitId", handlers.DeleteUnit(db))
+		landlord.POST("/properties/:propertyId/units", middleware.RequirePermission(permissions.UnitsWrite), audit("unit.create", "unit"), handlers.CreateUnit(unitSvc))
+		landlord.GET("/properties/:propertyId/units", middleware.RequirePermission(permissions.UnitsRead), handlers.GetUnitsByProperty(unitSvc))
+		landlord.PATCH("/properties/:propertyId/units/:unitId", middleware.RequirePermission(permissions.UnitsWrite), audit("unit.update", "unit"), handlers.UpdateUnit(unitSvc))
+		landlord.DELETE("/properties/:propertyId/units/:unitId", middleware.RequirePermission(permissions.UnitsWrite), audit("unit.delete", "unit"), handlers.DeleteUnit(unitSvc))
+
+		// Bulk onboarding of units and tenants from a spreadsheet
+		landlord.POST("/properties/:propertyId/import", middleware.RequirePermission(permissions.UnitsWrite), middleware.RequirePermission(permissions.TenantsWrite), audit("property.import", "import_job"), importHandler.Import)
+		landlord.GET("/properties/:propertyId/imports", middleware.RequirePermission(permissions.UnitsWrite), importHandler.ListJobs)
+		landlord.GET("/imports/:id", middleware.RequirePermission(permissions.UnitsWrite), importHandler.GetJob)
+		landlord.GET("/imports/:id/errors", middleware.RequirePermission(permissions.UnitsWrite), importHandler.ErrorReport)
 
 		// Tenants
-		landlord.GET("/tenants", handlers.ListAllTenants(db))
-		landlord.POST("/units/:unitId/tenants", handlers.CreateTenant(db))
-		landlord.GET("/units/:unitId/tenants", handlers.ListAllTenants(db))
-		landlord.GET("/tenants/:tenantId", handlers.GetTenant(db))
-		landlord.PUT("/tenants/:tenantId", handlers.UpdateTenant(db))
-		landlord.DELETE("/tenants/:tenantId", handlers.RemoveTenant(db))
+		landlord.GET("/tenants", middleware.RequirePermission(permissions.TenantsRead), handlers.ListAllTenants(tenantSvc))
+		landlord.POST("/units/:unitId/tenants", middleware.RequirePermission(permissions.TenantsWrite), audit("tenant.create", "tenant"), handlers.CreateTenant(tenantSvc))
+		landlord.GET("/units/:unitId/tenants", middleware.RequirePermission(permissions.TenantsRead), handlers.ListAllTenants(tenantSvc))
+		landlord.GET("/tenants/:tenantId", middleware.RequirePermission(permissions.TenantsRead), handlers.GetTenant(tenantSvc))
+		landlord.PUT("/tenants/:tenantId", middleware.RequirePermission(permissions.TenantsWrite), audit("tenant.update", "tenant"), handlers.UpdateTenant(tenantSvc))
+		landlord.DELETE("/tenants/:tenantId", middleware.RequirePermission(permissions.TenantsWrite), audit("tenant.delete", "tenant"), handlers.RemoveTenant(tenantSvc))
 
 		// Payments
-		landlord.GET("/payments", handlers.ListPayments(db))
-		landlord.POST("/payments/cash", handlers.RecordCashPayment(db))
-		landlord.PATCH("/payments/:id/assign", handlers.AssignPayment(db))
-		landlord.GET("/tenants/:tenantId/history", handlers.GetTenantHistory(db))
+		landlord.GET("/payments", middleware.RequirePermission(permissions.PaymentsRead), handlers.ListPayments(store))
+		landlord.POST("/payments/cash", middleware.RequirePermission(permissions.PaymentsRecordCash), audit("payment.record_cash", "payment"), handlers.RecordCashPayment(store, bus))
+		landlord.PATCH("/payments/:id/assign", middleware.RequirePermission(permissions.PaymentsAssign), audit("payment.assign", "payment"), handlers.AssignPayment(store, bus))
+		landlord.PATCH("/payments/:id/category", middleware.RequirePermission(permissions.PaymentsAssign), audit("payment.categorize", "payment"), handlers.SetPaymentCategory(store))
+		landlord.GET("/payments/:id/receipt.pdf", middleware.RequirePermission(permissions.PaymentsRead), receiptHandler.Download)
+		landlord.POST("/payments/statements", middleware.RequirePermission(permissions.PaymentsAssign), audit("payment.statement_upload", "statement_upload"), statementHandler.Upload)
+		landlord.GET("/payments/statements", middleware.RequirePermission(permissions.PaymentsAssign), statementHandler.ListUploads)
+		landlord.GET("/payments/statements/:id", middleware.RequirePermission(permissions.PaymentsAssign), statementHandler.GetUpload)
+		landlord.GET("/tenants/:tenantId/history", middleware.RequirePermission(permissions.PaymentsRead), handlers.GetTenantHistory(store))
+		landlord.GET("/tenants/:tenantId/statement.pdf", middleware.RequirePermission(permissions.PaymentsRead), notificationHandler.DownloadTenantStatement)
+		landlord.POST("/tenants/:tenantId/statement/email", middleware.RequirePermission(permissions.TenantsWrite), notificationHandler.EmailTenantStatement)
 
 		// Configuration
-		landlord.POST("/config/mpesa", paymentHandler.UpdateConfig)
+		landlord.GET("/branding", middleware.RequirePermission(permissions.PaymentsConfigure), receiptHandler.GetBranding)
+		landlord.PUT("/branding", middleware.RequirePermission(permissions.PaymentsConfigure), audit("branding.update", "branding"), receiptHandler.UpdateBranding)
+		landlord.GET("/branding/logo", middleware.RequirePermission(permissions.PaymentsConfigure), receiptHandler.Logo)
+		landlord.PUT("/branding/logo", middleware.RequirePermission(permissions.PaymentsConfigure), audit("branding.logo_update", "branding"), receiptHandler.UploadLogo)
+		landlord.DELETE("/branding/logo", middleware.RequirePermission(permissions.PaymentsConfigure), audit("branding.logo_delete", "branding"), receiptHandler.DeleteLogo)
+		landlord.POST("/config/mpesa", middleware.RequirePermission(permissions.PaymentsConfigure), audit("payment_config.update", "payment_config"), paymentHandler.UpdateConfig)
+
+		// Monthly Rental Income tax
+		landlord.GET("/tax/profile", middleware.RequirePermission(permissions.TaxManage), taxHandler.GetProfile)
+		landlord.PUT("/tax/profile", middleware.RequirePermission(permissions.TaxManage), audit("tax_profile.update", "tax_profile"), taxHandler.UpdateProfile)
+		landlord.GET("/tax/rates", middleware.RequirePermission(permissions.TaxManage), taxHandler.ListRates)
+		landlord.GET("/tax/mri", middleware.RequirePermission(permissions.TaxManage), taxHandler.ListReturns)
+		landlord.GET("/tax/mri/:period", middleware.RequirePermission(permissions.TaxManage), taxHandler.GetReturn)
+		landlord.PUT("/tax/mri/:period", middleware.RequirePermission(permissions.TaxManage), audit("tax_return.file", "mri_return"), taxHandler.FileReturn)
+		landlord.GET("/tax/mri/:period/itax.csv", middleware.RequirePermission(permissions.TaxManage), taxHandler.ExportReturn)
+
+		// Audit trail
+		landlord.GET("/audit", middleware.RequirePermission(permissions.AuditRead), auditHandler.ListForLandlord)
+
+		// SMS notifications
+		landlord.GET("/sms/settings", middleware.RequirePermission(permissions.NotificationsManage), notificationHandler.GetSMSSettings)
+		landlord.PATCH("/sms/settings", middleware.RequirePermission(permissions.NotificationsManage), audit("sms_settings.update", "sms_settings"), notificationHandler.UpdateSMSSettings)
+		landlord.GET("/sms/messages", middleware.RequirePermission(permissions.NotificationsManage), notificationHandler.ListSMSMessages)
+		landlord.GET("/email/messages", middleware.RequirePermission(permissions.NotificationsManage), notificationHandler.ListEmailMessages)
+
+		// Conversations between staff and tenants; tenants see their own
+		landlord.GET("/conversations", middleware.RequirePermission(permissions.MessagesRead), conversationHandler.List)
+		landlord.POST("/conversations", middleware.RequirePermission(permissions.MessagesWrite), audit("conversation.create", "conversation"), conversationHandler.Create)
+		landlord.POST("/conversations/broadcast", middleware.RequirePermission(permissions.MessagesWrite), audit("message.broadcast", "message_broadcast"), conversationHandler.Broadcast)
+		landlord.GET("/conversations/:id", middleware.RequirePermission(permissions.MessagesRead), conversationHandler.Get)
+		landlord.GET("/conversations/:id/messages", middleware.RequirePermission(permissions.MessagesRead), conversationHandler.Messages)
+		landlord.POST("/conversations/:id/messages", middleware.RequirePermission(permissions.MessagesWrite), audit("message.send", "message"), conversationHandler.Send)
+		landlord.POST("/conversations/:id/read", middleware.RequirePermission(permissions.MessagesRead), conversationHandler.MarkRead)
+		landlord.GET("/conversations/:id/attachments/:attachmentId", middleware.RequirePermission(permissions.MessagesRead), conversationHandler.Attachment)
+
+		// Maintenance tickets; tenants report and follow their own
+		landlord.GET("/maintenance", middleware.RequirePermission(permissions.MaintenanceRead), maintenanceHandler.List)
+		landlord.POST("/maintenance", middleware.RequirePermission(permissions.MaintenanceWrite), audit("maintenance.create", "maintenance_ticket"), maintenanceHandler.Create)
+		landlord.GET("/maintenance/sla", middleware.RequirePermission(permissions.MaintenanceRead), maintenanceHandler.SLA)
+		landlord.GET("/maintenance/:id", middleware.RequirePermission(permissions.MaintenanceRead), maintenanceHandler.Get)
+		landlord.PATCH("/maintenance/:id", middleware.RequirePermission(permissions.MaintenanceWrite), audit("maintenance.update", "maintenance_ticket"), maintenanceHandler.Update)
+		landlord.PUT("/maintenance/:id/assignment", middleware.RequirePermission(permissions.MaintenanceWrite), audit("maintenance.assign", "maintenance_ticket"), maintenanceHandler.Assign)
+		landlord.PATCH("/maintenance/:id/status", middleware.RequirePermission(permissions.MaintenanceWrite), audit("maintenance.status", "maintenance_ticket"), maintenanceHandler.SetStatus)
+		landlord.PUT("/maintenance/:id/cost", middleware.RequirePermission(permissions.MaintenanceWrite), audit("maintenance.cost", "maintenance_ticket"), maintenanceHandler.SetCost)
+		landlord.POST("/maintenance/:id/photos", middleware.RequirePermission(permissions.MaintenanceWrite), audit("maintenance.photos_add", "maintenance_ticket"), maintenanceHandler.AddPhotos)
+		landlord.GET("/maintenance/:id/photos/:photoId", middleware.RequirePermission(permissions.MaintenanceRead), maintenanceHandler.Photo)
+		landlord.GET("/tenants/:tenantId/charges", middleware.RequirePermission(permissions.TenantsRead), maintenanceHandler.TenantCharges)
+
+		// Vendors
+		landlord.GET("/vendors", middleware.RequirePermission(permissions.MaintenanceRead), maintenanceHandler.ListVendors)
+		landlord.POST("/vendors", middleware.RequirePermission(permissions.MaintenanceWrite), audit("vendor.create", "vendor"), maintenanceHandler.CreateVendor)
+		landlord.PUT("/vendors/:id", middleware.RequirePermission(permissions.MaintenanceWrite), audit("vendor.update", "vendor"), maintenanceHandler.UpdateVendor)
+		landlord.DELETE("/vendors/:id", middleware.RequirePermission(permissions.MaintenanceWrite), audit("vendor.delete", "vendor"), maintenanceHandler.DeleteVendor)
+
+		// Search; each result group needs its own read permission
+		landlord.GET("/search", searchHandler.Search)
+
+		// Dashboard
+		landlord.GET("/dashboard/summary", middleware.RequirePermission(permissions.PaymentsRead), dashboardHandler.Summary)
+
+		// Reports
+		landlord.GET("/reports/rent-roll", middleware.RequirePermission(permissions.TenantsRead), middleware.RequirePermission(permissions.PaymentsRead), reportHandler.RentRoll)
+		landlord.GET("/reports/arrears", middleware.RequirePermission(permissions.TenantsRead), middleware.RequirePermission(permissions.PaymentsRead), reportHandler.Arrears)
+
+		// Expenses and profit and loss
+		landlord.GET("/expenses", middleware.RequirePermission(permissions.ExpensesRead), expenseHandler.List)
+		landlord.POST("/expenses", middleware.RequirePermission(permissions.ExpensesWrite), audit("expense.create", "expense"), expenseHandler.Create)
+		landlord.GET("/expenses/recurring", middleware.RequirePermission(permissions.ExpensesRead), expenseHandler.ListRecurring)
+		landlord.POST("/expenses/recurring", middleware.RequirePermission(permissions.ExpensesWrite), audit("recurring_expense.create", "recurring_expense"), expenseHandler.CreateRecurring)
+		landlord.PATCH("/expenses/recurring/:id", middleware.RequirePermission(permissions.ExpensesWrite), audit("recurring_expense.update", "recurring_expense"), expenseHandler.UpdateRecurring)
+		landlord.DELETE("/expenses/recurring/:id", middleware.RequirePermission(permissions.ExpensesWrite), audit("recurring_expense.delete", "recurring_expense"), expenseHandler.DeleteRecurring)
+		landlord.GET("/expenses/:id", middleware.RequirePermission(permissions.ExpensesRead), expenseHandler.Get)
+		landlord.PUT("/expenses/:id", middleware.RequirePermission(permissions.ExpensesWrite), audit("expense.update", "expense"), expenseHandler.Update)
+		landlord.DELETE("/expenses/:id", middleware.RequirePermission(permissions.ExpensesWrite), audit("expense.delete", "expense"), expenseHandler.Delete)
+		landlord.PUT("/expenses/:id/receipt", middleware.RequirePermission(permissions.ExpensesWrite), audit("expense.receipt_upload", "expense"), expenseHandler.UploadReceipt)
+		landlord.GET("/expenses/:id/receipt", middleware.RequirePermission(permissions.ExpensesRead), expenseHandler.Receipt)
+		landlord.GET("/reports/profit-and-loss", middleware.RequirePermission(permissions.ExpensesRead), middleware.RequirePermission(permissions.PaymentsRead), expenseHandler.ProfitAndLoss)
+
+		// Journal exports for QuickBooks and Xero
+		landlord.GET("/exports/accounting", middleware.RequirePermission(permissions.ExpensesRead), middleware.RequirePermission(permissions.PaymentsRead), audit("accounting.export", "accounting_export"), accountingHandler.Export)
+		landlord.GET("/exports/accounting/batches", middleware.RequirePermission(permissions.ExpensesRead), middleware.RequirePermission(permissions.PaymentsRead), accountingHandler.ListBatches)
+		landlord.GET("/exports/accounting/batches/:id", middleware.RequirePermission(permissions.ExpensesRead), middleware.RequirePermission(permissions.PaymentsRead), accountingHandler.GetBatch)
+		landlord.GET("/exports/accounting/accounts", middleware.RequirePermission(permissions.ExpensesRead), middleware.RequirePermission(permissions.PaymentsRead), accountingHandler.GetSettings)
+		landlord.PUT("/exports/accounting/accounts", middleware.RequirePermission(permissions.ExpensesWrite), middleware.RequirePermission(permissions.PaymentsRead), audit("accounting.accounts_update", "accounting_settings"), accountingHandler.UpdateSettings)
+
+		// Webhooks
+		landlord.GET("/webhooks", middleware.RequirePermission(permissions.WebhooksManage), webhookHandler.List)
+		landlord.POST("/webhooks", middleware.RequirePermission(permissions.WebhooksManage), audit("webhook.create", "webhook"), webhookHandler.Create)
+		landlord.PATCH("/webhooks/:id", middleware.RequirePermission(permissions.WebhooksManage), audit("webhook.update", "webhook"), webhookHandler.Update)
+		landlord.DELETE("/webhooks/:id", middleware.RequirePermission(permissions.WebhooksManage), audit("webhook.delete", "webhook"), webhookHandler.Delete)
+		landlord.POST("/webhooks/:id/rotate-secret", middleware.RequirePermission(permissions.WebhooksManage), audit("webhook.rotate_secret", "webhook"), webhookHandler.RotateSecret)
+		landlord.GET("/webhooks/:id/deliveries", middleware.RequirePermission(permissions.WebhooksManage), webhookHandler.Deliveries)
+		landlord.POST("/webhooks/:id/test", middleware.RequirePermission(permissions.WebhooksManage), audit("webhook.test", "webhook"), webhookHandler.Test)
+
+		// Delegations
+		landlord.GET("/delegations", middleware.RequirePermission(permissions.DelegationsManage), handlers.ListDelegations(db))
+		landlord.POST("/delegations", middleware.RequirePermission(permissions.DelegationsManage), audit("delegation.grant", "delegation"), handlers.GrantDelegation(db))
+		landlord.DELETE("/delegations/:id", middleware.RequirePermission(permissions.DelegationsManage), audit("delegation.revoke", "delegation"), handlers.RevokeDelegation(db))
+
+		// Organizations; membership and org role are checked per organization.
+		// Members join by accepting an invitation to their email.
+		landlord.POST("/organizations", middleware.RequirePermission(permissions.OrganizationsManage), audit("organization.create", "organization"), handlers.CreateOrganization(db))
+		landlord.GET("/organizations", handlers.ListOrganizations(db))
+		landlord.GET("/organizations/:orgId", handlers.GetOrganization(db))
+		landlord.PATCH("/organizations/:orgId", audit("organization.update", "organization"), handlers.UpdateOrganization(db))
+		landlord.POST("/organizations/:orgId/invites", audit("organization.invite", "organization_invite"), handlers.InviteOrganizationMember(db))
+		landlord.DELETE("/organizations/:orgId/invites/:inviteId", audit("organization.invite_revoke", "organization_invite"), handlers.RevokeOrganizationInvite(db))
+		landlord.GET("/organization-invites", handlers.ListMyOrganizationInvites(db))
+		landlord.POST("/organization-invites/:inviteId/accept", audit("organization.member_join", "organization"), handlers.AcceptOrganizationInvite(db))
+		landlord.DELETE("/organization-invites/:inviteId", audit("organization.invite_decline", "organization_invite"), handlers.DeclineOrganizationInvite(db))
+		landlord.PATCH("/organizations/:orgId/members/:userId", audit("organization.member_update", "organization"), handlers.UpdateOrganizationMember(db))
+		landlord.DELETE("/organizations/:orgId/members/:userId", audit("organization.member_remove", "organization"), handlers.RemoveOrganizationMember(db))
+		landlord.GET("/organizations/:orgId/statements", handlers.GetOwnerStatements(db))
+		landlord.PUT("/properties/:propertyId/organization", middleware.RequirePermission(permissions.PropertiesWrite), audit("property.set_organization", "property"), handlers.SetPropertyOrganization(db))
 	}
 }
 
@@ -105,5 +373,19 @@ func getUserProfile(c *gin.Context) {
 	c.JSON(200, gin.H{
 		"user_id": userID,
 		"email":   email,
+		"role":    middleware.GetRole(c),
 	})
 }
+
+// newEventStream returns the configured stream backend, falling back to the
+// in-process stream when Postgres LISTEN cannot be started
+func newEventStream(db *database.Database, cfg *config.Config) events.Stream {
+	if cfg.Events.StreamBackend == "postgres" {
+		stream, err := events.NewPostgresStream(db.DB, cfg.GetDSN())
+		if err == nil {
+			return stream
+		}
+		log.Printf("events: postgres stream unavailable, using in-process stream: %v", err)
+	}
+	return events.NewMemoryStream()
+}
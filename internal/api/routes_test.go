@@ -0,0 +1,49 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/config"
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/gin-gonic/gin"
+	_ "github.com/lib/pq"
+)
+
+func TestStreamRoutePath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Registering routes does not touch the database; the pool stays unopened
+	conn, err := sql.Open("postgres", "postgres://localhost:1/none?sslmode=disable&connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	cfg := &config.Config{}
+	cfg.JWT.Secret = "test-secret-that-is-at-least-32-bytes"
+	cfg.Notifications.ReminderInterval = time.Hour
+	cfg.Events.StreamBackend = "memory"
+	cfg.Dashboard.CacheTTL = time.Minute
+
+	r := gin.New()
+	SetupRoutes(r, &database.Database{DB: conn}, cfg)
+
+	found := false
+	for _, route := range r.Routes() {
+		if route.Method != http.MethodGet {
+			continue
+		}
+		if route.Path == StreamPath {
+			found = true
+		}
+		if route.Path == apiPrefix+StreamPath {
+			t.Errorf("stream registered with the prefix twice: %s", route.Path)
+		}
+	}
+	if !found {
+		t.Errorf("no GET route at %s; request logging would not skip the stream", StreamPath)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"errors"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,6 +16,9 @@ type Config struct {
 		Host         string
 		ReadTimeout  time.Duration
 		WriteTimeout time.Duration
+		// Reverse proxies whose X-Forwarded-For is believed when resolving the
+		// client IP; empty trusts none and uses the connection address
+		TrustedProxies []string
 	}
 	Database struct {
 		URL string // PRIMARY: Full DATABASE_URL for production
@@ -29,7 +33,43 @@ type Config struct {
 		AllowedMethods []string
 		AllowedHeaders []string
 	}
+	Security struct {
+		PasswordResetTTL   time.Duration
+		LoginMaxAttempts   int           // failed logins before the account is locked
+		LockoutDuration    time.Duration // first lock duration, doubled on each repeat lockout
+		LockoutMaxDuration time.Duration
+	}
+	SMS struct {
+		Provider string // log (development default), file or africastalking
+		Username string
+		APIKey   string
+		SenderID string // default sender ID when a landlord has not set one
+		FilePath string // output of the file provider
+	}
+	SMTP struct {
+		Host     string // email is only logged when empty, and refused in production
+		Port     string
+		Username string
+		Password string
+		From     string // e.g. "Smart Rentals <no-reply@example.com>"
+	}
+	Webhooks struct {
+		AllowPrivateHosts bool // deliver to loopback/private addresses; local development only
+	}
+	Notifications struct {
+		ReminderInterval time.Duration // how often rent reminders and overdue notices are checked
+	}
+	Events struct {
+		StreamBackend string // memory (default, single server) or postgres (LISTEN/NOTIFY across replicas)
+	}
+	Receipts struct {
+		VerifyURL string // public verification link printed as a QR code on receipts; the code is appended
+	}
+	Dashboard struct {
+		CacheTTL time.Duration // how long a dashboard summary is reused; 0 disables the cache
+	}
 	Environment          string
+	FrontendURL          string
 	MpesaEnvironment     string
 	MpesaCallbackBaseURL string
 	LogLevel             string
@@ -47,6 +87,7 @@ func Load() (*Config, error) {
 	cfg.Server.Host = getEnv("SERVER_HOST", "0.0.0.0")
 	cfg.Server.ReadTimeout = 15 * time.Second
 	cfg.Server.WriteTimeout = 15 * time.Second
+	cfg.Server.TrustedProxies = splitList(os.Getenv("TRUSTED_PROXIES"))
 
 	// Database config - DATABASE_URL is PRIMARY
 	cfg.Database.URL = os.Getenv("DATABASE_URL")
@@ -63,6 +104,35 @@ func Load() (*Config, error) {
 	cfg.JWT.TokenExpiry = expiry
 	cfg.JWT.RefreshExpiry = time.Hour * 168 // 7 days
 
+	// Security config
+	cfg.Security.PasswordResetTTL = getDuration("PASSWORD_RESET_TTL", 30*time.Minute)
+	cfg.Security.LoginMaxAttempts = getInt("LOGIN_MAX_ATTEMPTS", 5)
+	cfg.Security.LockoutDuration = getDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+	cfg.Security.LockoutMaxDuration = getDuration("LOGIN_LOCKOUT_MAX_DURATION", 24*time.Hour)
+
+	// SMS provider
+	cfg.SMS.Provider = strings.ToLower(os.Getenv("SMS_PROVIDER"))
+	cfg.SMS.Username = os.Getenv("SMS_USERNAME")
+	cfg.SMS.APIKey = os.Getenv("SMS_API_KEY")
+	cfg.SMS.SenderID = os.Getenv("SMS_SENDER_ID")
+	cfg.SMS.FilePath = getEnv("SMS_FILE_PATH", "sms.log")
+	// SMTP for email; leave SMTP_HOST empty to log emails instead
+	cfg.SMTP.Host = os.Getenv("SMTP_HOST")
+	cfg.SMTP.Port = getEnv("SMTP_PORT", "587")
+	cfg.SMTP.Username = os.Getenv("SMTP_USERNAME")
+	cfg.SMTP.Password = os.Getenv("SMTP_PASSWORD")
+	cfg.SMTP.From = getEnv("SMTP_FROM", "Smart Rentals <no-reply@localhost>")
+
+	cfg.Notifications.ReminderInterval = getDuration("REMINDER_INTERVAL", time.Hour)
+
+	// Webhook receivers on localhost are only reachable when explicitly allowed
+	cfg.Webhooks.AllowPrivateHosts = os.Getenv("WEBHOOK_ALLOW_PRIVATE_HOSTS") == "true"
+
+	// Live event stream fan-out; postgres is needed with several replicas
+	cfg.Events.StreamBackend = getEnv("EVENT_STREAM_BACKEND", "memory")
+
+	cfg.Dashboard.CacheTTL = getDuration("DASHBOARD_CACHE_TTL", time.Minute)
+
 	// CORS config - REQUIRED for production
 	originsStr := os.Getenv("CORS_ALLOWED_ORIGINS")
 	if originsStr != "" {
@@ -84,6 +154,17 @@ func Load() (*Config, error) {
 	cfg.MpesaEnvironment = getEnv("MPESA_ENV", "sandbox")
 	cfg.MpesaCallbackBaseURL = os.Getenv("MPESA_CALLBACK_BASE_URL")
 
+	// Receipt QR codes link to the public verification endpoint unless a
+	// frontend page is configured; without either receipts carry no QR code
+	cfg.Receipts.VerifyURL = os.Getenv("RECEIPT_VERIFY_URL")
+	if cfg.Receipts.VerifyURL == "" && cfg.MpesaCallbackBaseURL != "" {
+		cfg.Receipts.VerifyURL = strings.TrimRight(cfg.MpesaCallbackBaseURL, "/") + "/api/v1/receipts/verify"
+	}
+	cfg.Receipts.VerifyURL = strings.TrimRight(cfg.Receipts.VerifyURL, "/")
+
+	// Frontend URL used to build links in emails/SMS (e.g. password reset)
+	cfg.FrontendURL = strings.TrimRight(os.Getenv("FRONTEND_URL"), "/")
+
 	// Logging
 	cfg.LogLevel = getEnv("LOG_LEVEL", "info")
 
@@ -124,6 +205,26 @@ func (c *Config) Validate() error {
 		return errors.New("MPESA_CALLBACK_BASE_URL is required in production")
 	}
 
+	// SMS provider credentials
+	if c.SMS.Provider == "africastalking" && (c.SMS.Username == "" || c.SMS.APIKey == "") {
+		return errors.New("SMS_USERNAME and SMS_API_KEY are required when SMS_PROVIDER=africastalking")
+	}
+
+	// The log and file providers record message bodies, which carry password
+	// reset tokens
+	if c.Environment == "production" && (c.SMS.Provider == "log" || c.SMS.Provider == "file") {
+		return errors.New("SMS_PROVIDER=" + c.SMS.Provider + " is not allowed in production")
+	}
+
+	if c.Environment == "production" && c.Webhooks.AllowPrivateHosts {
+		return errors.New("WEBHOOK_ALLOW_PRIVATE_HOSTS is not allowed in production")
+	}
+
+	// Event stream backend
+	if c.Events.StreamBackend != "memory" && c.Events.StreamBackend != "postgres" {
+		return errors.New("EVENT_STREAM_BACKEND must be memory or postgres")
+	}
+
 	return nil
 }
 
@@ -134,6 +235,35 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// splitList splits a comma separated env value, dropping empty entries
+func splitList(value string) []string {
+	var out []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// getDuration parses a duration env var, falling back to the default when unset or invalid
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return d
+}
+
+// getInt parses an integer env var, falling back to the default when unset or invalid
+func getInt(key string, defaultValue int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return n
+}
+
 // GetDSN returns the database connection string
 // In production, this is simply the DATABASE_URL
 func (c *Config) GetDSN() string {
@@ -0,0 +1,338 @@
+// Package documents renders PDF receipts and statements, and tabular
+// reports as CSV, XLSX or PDF
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// ContentTypePDF is the MIME type of generated documents
+const ContentTypePDF = "application/pdf"
+
+// Field is a labelled value shown in a document header
+type Field struct {
+	Label string
+	Value string
+}
+
+// Branding is a landlord's letterhead on receipts and statements; the
+// document's IssuedBy is printed as the business name
+type Branding struct {
+	Address     string
+	Phone       string
+	Email       string
+	Logo        []byte // PNG or JPEG
+	LogoType    string // MIME type of Logo
+	AccentColor string // #RRGGBB, used for the name and rules
+}
+
+// Receipt is proof of a single payment
+type Receipt struct {
+	Number       string // printed receipt number
+	IssuedBy     string // landlord or organization name
+	Branding     *Branding
+	Date         time.Time
+	TenantName   string
+	PropertyName string
+	UnitName     string
+	Amount       float64
+	Method       string
+	Reference    string // M-Pesa or cash receipt reference
+	Balance      float64
+	VerifyURL    string // printed as a QR code when set
+}
+
+// StatementLine is one payment in a statement
+type StatementLine struct {
+	Date        time.Time
+	Description string
+	Reference   string
+	Amount      float64
+}
+
+// Statement lists payments over a period
+type Statement struct {
+	Title       string
+	IssuedBy    string
+	Branding    *Branding
+	PeriodStart time.Time // inclusive
+	PeriodEnd   time.Time // exclusive
+	Details     []Field
+	Lines       []StatementLine
+	Total       float64
+	Summary     []Field // printed below the total, e.g. closing balance
+}
+
+// ReceiptPDF renders a one-page payment receipt
+func ReceiptPDF(r Receipt) ([]byte, error) {
+	pdf, tr := newDocument("P", "Receipt "+r.Number)
+	pdf.AddPage()
+	letterhead(pdf, tr, "PAYMENT RECEIPT", r.IssuedBy, r.Branding)
+
+	fields(pdf, tr, []Field{
+		{"Receipt No.", r.Number},
+		{"Date", r.Date.Format("2 Jan 2006 15:04")},
+		{"Received from", r.TenantName},
+		{"Property", r.PropertyName},
+		{"Unit", r.UnitName},
+		{"Payment method", r.Method},
+		{"Reference", r.Reference},
+	})
+
+	pdf.Ln(6)
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.CellFormat(95, 12, tr("Amount received"), "1", 0, "L", true, 0, "")
+	pdf.CellFormat(95, 12, "KES "+FormatMoney(r.Amount), "1", 1, "R", true, 0, "")
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.CellFormat(95, 9, tr("Balance after payment"), "1", 0, "L", false, 0, "")
+	pdf.CellFormat(95, 9, "KES "+FormatMoney(r.Balance), "1", 1, "R", false, 0, "")
+
+	if r.VerifyURL != "" {
+		if err := verificationQR(pdf, tr, r.VerifyURL); err != nil {
+			return nil, err
+		}
+	}
+	return output(pdf)
+}
+
+// StatementPDF renders a statement with one row per payment
+func StatementPDF(s Statement) ([]byte, error) {
+	pdf, tr := newDocument("P", s.Title)
+	pdf.AddPage()
+	letterhead(pdf, tr, strings.ToUpper(s.Title), s.IssuedBy, s.Branding)
+
+	period := Field{"Period", FormatPeriod(s.PeriodStart, s.PeriodEnd)}
+	fields(pdf, tr, append([]Field{period}, s.Details...))
+	pdf.Ln(6)
+
+	widths := []float64{30, 85, 40, 35}
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(240, 240, 240)
+	for i, h := range []string{"Date", "Description", "Reference", "Amount (KES)"} {
+		align := "L"
+		if i == 3 {
+			align = "R"
+		}
+		pdf.CellFormat(widths[i], 8, h, "1", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 10)
+	if len(s.Lines) == 0 {
+		pdf.CellFormat(190, 8, "No payments in this period", "1", 1, "C", false, 0, "")
+	}
+	for _, l := range s.Lines {
+		pdf.CellFormat(widths[0], 7, l.Date.Format("02 Jan 2006"), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 7, truncate(tr(l.Description), 50), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[2], 7, truncate(tr(l.Reference), 22), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[3], 7, FormatMoney(l.Amount), "1", 1, "R", false, 0, "")
+	}
+
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(widths[0]+widths[1]+widths[2], 9, "Total", "1", 0, "R", true, 0, "")
+	pdf.CellFormat(widths[3], 9, FormatMoney(s.Total), "1", 1, "R", true, 0, "")
+
+	if len(s.Summary) > 0 {
+		pdf.Ln(4)
+		fields(pdf, tr, s.Summary)
+	}
+	return output(pdf)
+}
+
+// FormatMoney renders an amount with thousands separators, dropping zero cents
+func FormatMoney(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimSuffix(s, ".00")
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i:]
+	}
+	neg := strings.HasPrefix(intPart, "-")
+	intPart = strings.TrimPrefix(intPart, "-")
+	for i := len(intPart) - 3; i > 0; i -= 3 {
+		intPart = intPart[:i] + "," + intPart[i:]
+	}
+	if neg {
+		intPart = "-" + intPart
+	}
+	return intPart + frac
+}
+
+// FormatPeriod renders [start, end) as "1 Sep 2026 - 30 Sep 2026"
+func FormatPeriod(start, end time.Time) string {
+	return start.Format("2 Jan 2006") + " - " + end.AddDate(0, 0, -1).Format("2 Jan 2006")
+}
+
+// newDocument starts an A4 document, portrait ("P") or landscape ("L")
+func newDocument(orientation, title string) (*fpdf.Fpdf, func(string) string) {
+	pdf := fpdf.New(orientation, "mm", "A4", "")
+	pdf.SetMargins(10, 15, 10)
+	pdf.SetTitle(title, true)
+	pdf.SetCreator("Smart Rentals", false)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(0, 10, fmt.Sprintf("Generated %s - page %d/{nb}", time.Now().Format("2 Jan 2006 15:04"), pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+	// Core fonts are cp1252; translate UTF-8 input
+	return pdf, pdf.UnicodeTranslatorFromDescriptor("")
+}
+
+func header(pdf *fpdf.Fpdf, tr func(string) string, title, issuer string) {
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, tr(title), "", 1, "L", false, 0, "")
+	if issuer != "" {
+		pdf.SetFont("Helvetica", "", 11)
+		pdf.SetTextColor(90, 90, 90)
+		pdf.CellFormat(0, 6, tr(issuer), "", 1, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	}
+	pageWidth, _ := pdf.GetPageSize()
+	pdf.SetDrawColor(200, 200, 200)
+	pdf.Line(10, pdf.GetY()+3, pageWidth-10, pdf.GetY()+3)
+	pdf.Ln(8)
+}
+
+// letterhead prints the landlord's logo, name and contacts above the title,
+// or the plain header without branding
+func letterhead(pdf *fpdf.Fpdf, tr func(string) string, title, issuer string, b *Branding) {
+	if b == nil {
+		header(pdf, tr, title, issuer)
+		return
+	}
+	red, green, blue := accent(b.AccentColor)
+	top := pdf.GetY()
+	left := 10.0
+
+	if len(b.Logo) > 0 {
+		opts := fpdf.ImageOptions{ImageType: imageType(b.LogoType)}
+		info := pdf.RegisterImageOptionsReader("logo", opts, bytes.NewReader(b.Logo))
+		if !pdf.Ok() || info == nil {
+			// A logo that cannot be read is left out rather than failing the document
+			pdf.ClearError()
+		} else {
+			height := 20.0
+			width := info.Width() * height / info.Height()
+			if width > 50 {
+				width, height = 50, info.Height()*50/info.Width()
+			}
+			pdf.ImageOptions("logo", left, top, width, height, false, opts, 0, "")
+			left += width + 5
+		}
+	}
+
+	pdf.SetXY(left, top)
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.SetTextColor(red, green, blue)
+	pdf.CellFormat(0, 8, tr(issuer), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.SetTextColor(90, 90, 90)
+	for _, line := range []string{b.Address, strings.Join(nonEmpty(b.Phone, b.Email), "  |  ")} {
+		if line != "" {
+			pdf.SetX(left)
+			pdf.CellFormat(0, 5, tr(line), "", 1, "L", false, 0, "")
+		}
+	}
+	pdf.SetTextColor(0, 0, 0)
+	if pdf.GetY() < top+22 {
+		pdf.SetY(top + 22)
+	}
+
+	pageWidth, _ := pdf.GetPageSize()
+	pdf.SetDrawColor(red, green, blue)
+	pdf.SetLineWidth(0.6)
+	pdf.Line(10, pdf.GetY(), pageWidth-10, pdf.GetY())
+	pdf.SetLineWidth(0.2)
+	pdf.Ln(4)
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(0, 9, tr(title), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+}
+
+// verificationQR prints a QR code of the receipt's verification link
+func verificationQR(pdf *fpdf.Fpdf, tr func(string) string, url string) error {
+	png, err := qrcode.Encode(url, qrcode.Medium, 256)
+	if err != nil {
+		return err
+	}
+	opts := fpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader("verify-qr", opts, bytes.NewReader(png))
+
+	pdf.Ln(8)
+	top := pdf.GetY()
+	pdf.ImageOptions("verify-qr", 10, top, 35, 35, false, opts, 0, "")
+	pdf.SetXY(50, top+8)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(0, 6, "Verify this receipt", "", 2, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.SetTextColor(90, 90, 90)
+	pdf.MultiCell(140, 4, tr("Scan the code or open "+url+" to confirm it was issued by the landlord."), "", "L", false)
+	pdf.SetTextColor(0, 0, 0)
+	return pdf.Error()
+}
+
+// accent parses a #RRGGBB colour, black when unset or invalid
+func accent(hex string) (int, int, int) {
+	if len(hex) != 7 || hex[0] != '#' {
+		return 0, 0, 0
+	}
+	v, err := strconv.ParseUint(hex[1:], 16, 32)
+	if err != nil {
+		return 0, 0, 0
+	}
+	return int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff)
+}
+
+func imageType(contentType string) string {
+	if contentType == "image/jpeg" {
+		return "JPG"
+	}
+	return "PNG"
+}
+
+func nonEmpty(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func fields(pdf *fpdf.Fpdf, tr func(string) string, list []Field) {
+	for _, f := range list {
+		if f.Value == "" {
+			continue
+		}
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(45, 7, tr(f.Label), "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(0, 7, tr(f.Value), "", 1, "L", false, 0, "")
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
+
+func output(pdf *fpdf.Fpdf) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
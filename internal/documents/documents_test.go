@@ -0,0 +1,113 @@
+package documents
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func TestFormatMoney(t *testing.T) {
+	for v, want := range map[float64]string{
+		0:          "0",
+		950:        "950",
+		15000:      "15,000",
+		15000.5:    "15,000.50",
+		1234567.89: "1,234,567.89",
+		-2500:      "-2,500",
+		-999.99:    "-999.99",
+	} {
+		if got := FormatMoney(v); got != want {
+			t.Errorf("FormatMoney(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestFormatPeriod(t *testing.T) {
+	start := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	if got := FormatPeriod(start, start.AddDate(0, 1, 0)); got != "1 Sep 2026 - 30 Sep 2026" {
+		t.Errorf("FormatPeriod = %q", got)
+	}
+}
+
+func TestAccent(t *testing.T) {
+	tests := []struct {
+		hex     string
+		r, g, b int
+	}{
+		{"#1E88E5", 0x1e, 0x88, 0xe5},
+		{"#ffffff", 255, 255, 255},
+		{"", 0, 0, 0},
+		{"1E88E5", 0, 0, 0},
+		{"#1E88E", 0, 0, 0},
+		{"#GGGGGG", 0, 0, 0},
+	}
+	for _, tt := range tests {
+		if r, g, b := accent(tt.hex); r != tt.r || g != tt.g || b != tt.b {
+			t.Errorf("accent(%q) = %d,%d,%d; want %d,%d,%d", tt.hex, r, g, b, tt.r, tt.g, tt.b)
+		}
+	}
+}
+
+func TestReceiptPDF(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	img.Set(1, 1, color.RGBA{R: 30, G: 136, B: 229, A: 255})
+	var logo bytes.Buffer
+	if err := png.Encode(&logo, img); err != nil {
+		t.Fatal(err)
+	}
+	receipt := Receipt{
+		Number:       "KF-000123",
+		IssuedBy:     "Kamau Flats",
+		Date:         time.Date(2026, time.October, 5, 10, 30, 0, 0, time.UTC),
+		TenantName:   "Jane Wanjiru",
+		PropertyName: "Kamau Flats",
+		UnitName:     "A4",
+		Amount:       15000,
+		Method:       "mpesa",
+		Reference:    "SJ12ABC",
+		Balance:      0,
+		VerifyURL:    "https://rentals.example/receipts/verify/abc123",
+	}
+
+	tests := map[string]*Branding{
+		"plain":    nil,
+		"branded":  {Address: "Ngong Rd, Nairobi", Phone: "0712345678", Logo: logo.Bytes(), LogoType: "image/png", AccentColor: "#1E88E5"},
+		"bad logo": {Logo: []byte("not an image"), LogoType: "image/png"},
+	}
+	for name, branding := range tests {
+		receipt.Branding = branding
+		data, err := ReceiptPDF(receipt)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.HasPrefix(data, []byte("%PDF-")) {
+			t.Errorf("%s: not a PDF", name)
+		}
+	}
+}
+
+func TestStatementPDF(t *testing.T) {
+	start := time.Date(2026, time.September, 1, 0, 0, 0, 0, time.UTC)
+	data, err := StatementPDF(Statement{
+		Title:       "Tenant statement",
+		IssuedBy:    "Kamau Flats",
+		PeriodStart: start,
+		PeriodEnd:   start.AddDate(0, 1, 0),
+		Details:     []Field{{Label: "Tenant", Value: "Jane Wanjiru"}},
+		Lines: []StatementLine{
+			{Date: start.AddDate(0, 0, 4), Description: "Rent", Reference: "SJ12ABC", Amount: 15000},
+			{Date: start.AddDate(0, 0, 20), Description: "Water", Reference: "CASH-1", Amount: 850},
+		},
+		Total:   15850,
+		Summary: []Field{{Label: "Closing balance", Value: "KES 0"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		t.Error("not a PDF")
+	}
+}
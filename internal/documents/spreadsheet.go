@@ -0,0 +1,308 @@
+package documents
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Limits on what an XLSX workbook may expand to. Row and cell references
+// come from the file, so they are checked before any row or cell is padded.
+const (
+	MaxSpreadsheetRows    = 50000
+	MaxSpreadsheetColumns = 256
+	maxSpreadsheetXML     = 50 << 20 // decompressed bytes across the parts read
+)
+
+var (
+	ErrUnreadableSpreadsheet = errors.New("file is not a readable CSV or XLSX spreadsheet")
+	ErrSpreadsheetTooLarge   = fmt.Errorf("spreadsheets may hold at most %d rows and %d columns", MaxSpreadsheetRows, MaxSpreadsheetColumns)
+)
+
+// ReadSpreadsheet returns the rows of an uploaded CSV file, or of the first
+// sheet of an XLSX workbook, as text. Empty trailing cells are trimmed and
+// rows with no text are kept so row numbers match what the user sees.
+func ReadSpreadsheet(data []byte, format string) ([][]string, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(data)
+	case FormatXLSX:
+		return readXLSX(data)
+	}
+	return nil, ErrUnknownFormat
+}
+
+func readCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // Excel's UTF-8 byte order mark
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	var rows [][]string
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, ErrUnreadableSpreadsheet
+		}
+		// The reader skips blank lines; keep them as empty rows
+		line, _ := r.FieldPos(0)
+		for len(rows) < line-1 {
+			rows = append(rows, nil)
+		}
+		rows = append(rows, record)
+	}
+}
+
+// xlsxCell is a <c> element of a worksheet. Text is in v (shared string
+// index, number or formula result) or, for inline strings, in is.
+type xlsxCell struct {
+	Ref    string   `xml:"r,attr"`
+	Type   string   `xml:"t,attr"`
+	Value  string   `xml:"v"`
+	Inline xlsxText `xml:"is"`
+}
+
+// xlsxText is a shared or inline string: plain <t> or rich text runs
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+// xlsxParts opens the parts of a workbook, failing every read once the parts
+// together have decompressed to more than maxSpreadsheetXML bytes
+type xlsxParts struct {
+	files    map[string]*zip.File
+	left     int64
+	exceeded bool
+}
+
+func (p *xlsxParts) open(name string) (io.ReadCloser, error) {
+	f := p.files[name]
+	if f == nil {
+		return nil, ErrUnreadableSpreadsheet
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxPart{ReadCloser: rc, parts: p}, nil
+}
+
+// fail reports a read error, as too large when the byte limit caused it
+func (p *xlsxParts) fail() error {
+	if p.exceeded {
+		return ErrSpreadsheetTooLarge
+	}
+	return ErrUnreadableSpreadsheet
+}
+
+type xlsxPart struct {
+	io.ReadCloser
+	parts *xlsxParts
+}
+
+func (r *xlsxPart) Read(b []byte) (int, error) {
+	if r.parts.left <= 0 {
+		r.parts.exceeded = true
+		return 0, ErrSpreadsheetTooLarge
+	}
+	if int64(len(b)) > r.parts.left {
+		b = b[:r.parts.left]
+	}
+	n, err := r.ReadCloser.Read(b)
+	r.parts.left -= int64(n)
+	return n, err
+}
+
+func readXLSX(data []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, ErrUnreadableSpreadsheet
+	}
+	parts := &xlsxParts{files: map[string]*zip.File{}, left: maxSpreadsheetXML}
+	for _, f := range zr.File {
+		parts.files[f.Name] = f
+	}
+
+	var shared []string
+	if parts.files["xl/sharedStrings.xml"] != nil {
+		if shared, err = xlsxSharedStrings(parts); err != nil {
+			return nil, parts.fail()
+		}
+	}
+	rc, err := parts.open(xlsxFirstSheet(parts))
+	if err != nil {
+		return nil, parts.fail()
+	}
+	defer rc.Close()
+
+	var rows [][]string
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, parts.fail()
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		var row struct {
+			Ref   int        `xml:"r,attr"`
+			Cells []xlsxCell `xml:"c"`
+		}
+		if err := dec.DecodeElement(&row, &start); err != nil {
+			return nil, parts.fail()
+		}
+		// Rows left out of the sheet are blank
+		if row.Ref <= 0 {
+			row.Ref = len(rows) + 1
+		}
+		if row.Ref > MaxSpreadsheetRows {
+			return nil, ErrSpreadsheetTooLarge
+		}
+		for len(rows) < row.Ref-1 {
+			rows = append(rows, nil)
+		}
+
+		var cells []string
+		for i, c := range row.Cells {
+			col := xlsxColumnIndex(c.Ref)
+			if col < 0 {
+				col = i
+			}
+			if col >= MaxSpreadsheetColumns {
+				return nil, ErrSpreadsheetTooLarge
+			}
+			for len(cells) <= col {
+				cells = append(cells, "")
+			}
+			cells[col] = xlsxCellText(c, shared)
+		}
+		for len(cells) > 0 && cells[len(cells)-1] == "" {
+			cells = cells[:len(cells)-1]
+		}
+		rows = append(rows, cells)
+	}
+	return rows, nil
+}
+
+func xlsxSharedStrings(parts *xlsxParts) ([]string, error) {
+	rc, err := parts.open("xl/sharedStrings.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var sst struct {
+		Items []xlsxText `xml:"si"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&sst); err != nil {
+		return nil, err
+	}
+	strs := make([]string, len(sst.Items))
+	for i, si := range sst.Items {
+		strs[i] = si.String()
+	}
+	return strs, nil
+}
+
+// xlsxFirstSheet finds the part holding the workbook's first sheet, falling
+// back to the usual name
+func xlsxFirstSheet(parts *xlsxParts) string {
+	const fallback = "xl/worksheets/sheet1.xml"
+	var workbook struct {
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Items []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if xlsxDecode(parts, "xl/workbook.xml", &workbook) != nil || len(workbook.Sheets) == 0 ||
+		xlsxDecode(parts, "xl/_rels/workbook.xml.rels", &rels) != nil {
+		return fallback
+	}
+	for _, r := range rels.Items {
+		if r.ID != workbook.Sheets[0].ID {
+			continue
+		}
+		if strings.HasPrefix(r.Target, "/") {
+			return strings.TrimPrefix(r.Target, "/")
+		}
+		return path.Join("xl", r.Target)
+	}
+	return fallback
+}
+
+func xlsxDecode(parts *xlsxParts, name string, v interface{}) error {
+	rc, err := parts.open(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+func xlsxCellText(c xlsxCell, shared []string) string {
+	switch c.Type {
+	case "s":
+		i, err := strconv.Atoi(c.Value)
+		if err != nil || i < 0 || i >= len(shared) {
+			return ""
+		}
+		return strings.TrimSpace(shared[i])
+	case "inlineStr":
+		return strings.TrimSpace(c.Inline.String())
+	case "str", "b", "e":
+		return strings.TrimSpace(c.Value)
+	}
+	// Whole numbers read as typed: 712345678, not 7.12345678E+08
+	if f, err := strconv.ParseFloat(c.Value, 64); err == nil && f == float64(int64(f)) {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strings.TrimSpace(c.Value)
+}
+
+// xlsxColumnIndex returns the zero-based column of a cell reference such as
+// "AB12", or -1 when it has none. Columns past MaxSpreadsheetColumns read as
+// MaxSpreadsheetColumns so long references cannot overflow.
+func xlsxColumnIndex(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		if col = col*26 + int(r-'A'+1); col > MaxSpreadsheetColumns {
+			return MaxSpreadsheetColumns
+		}
+	}
+	return col - 1
+}
@@ -0,0 +1,78 @@
+package documents
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func xlsxWithSheet(t *testing.T, sheet string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(sheet)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	data := xlsxWithSheet(t, `<worksheet><sheetData>`+
+		`<row r="1"><c r="A1" t="inlineStr"><is><t>unit_name</t></is></c><c r="C1" t="inlineStr"><is><t>unit_price</t></is></c></row>`+
+		`<row r="3"><c r="A3" t="inlineStr"><is><t>A1</t></is></c><c r="C3"><v>15000</v></c></row>`+
+		`</sheetData></worksheet>`)
+	rows, err := ReadSpreadsheet(data, FormatXLSX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"unit_name", "", "unit_price"}, nil, {"A1", "", "15000"}}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d: %q", len(rows), len(want), rows)
+	}
+	for i := range want {
+		if strings.Join(rows[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("row %d = %q, want %q", i+1, rows[i], want[i])
+		}
+	}
+}
+
+func TestReadXLSXLimits(t *testing.T) {
+	tests := map[string]string{
+		"row index":    `<worksheet><sheetData><row r="2000000000"><c r="A1"><v>1</v></c></row></sheetData></worksheet>`,
+		"column index": `<worksheet><sheetData><row r="1"><c r="ZZZZZZZZZZZZZZZZ1"><v>1</v></c></row></sheetData></worksheet>`,
+		"expanded size": `<worksheet><sheetData><row r="1"><c r="A1" t="str"><v>` +
+			strings.Repeat("x", maxSpreadsheetXML) + `</v></c></row></sheetData></worksheet>`,
+	}
+	for name, sheet := range tests {
+		if _, err := ReadSpreadsheet(xlsxWithSheet(t, sheet), FormatXLSX); !errors.Is(err, ErrSpreadsheetTooLarge) {
+			t.Errorf("%s: got %v, want ErrSpreadsheetTooLarge", name, err)
+		}
+	}
+}
+
+func TestReadCSVKeepsBlankLines(t *testing.T) {
+	data := "\xef\xbb\xbfunit_name,unit_price\n\nA1,15000\n\"A2\nupper\",16000\n\n\nA3,17000\n"
+	rows, err := ReadSpreadsheet([]byte(data), FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rows are numbered by the line each record starts on
+	want := map[int]string{1: "unit_name|unit_price", 3: "A1|15000", 4: "A2\nupper|16000", 8: "A3|17000"}
+	if len(rows) != 8 {
+		t.Fatalf("got %d rows, want 8: %q", len(rows), rows)
+	}
+	for i, r := range rows {
+		if got := strings.Join(r, "|"); got != want[i+1] {
+			t.Errorf("row %d = %q, want %q", i+1, got, want[i+1])
+		}
+	}
+}
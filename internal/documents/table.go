@@ -0,0 +1,266 @@
+package documents
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// Report formats
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+	FormatPDF  = "pdf"
+)
+
+const (
+	ContentTypeCSV  = "text/csv"
+	ContentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+var ErrUnknownFormat = errors.New("format must be csv, xlsx or pdf")
+
+// Column is a column of a tabular report
+type Column struct {
+	Title string
+	Width float64 // PDF width in mm
+	Money bool    // float64 amounts, right aligned
+}
+
+// Table describes a tabular report. Title and Details only appear in PDFs;
+// CSV and XLSX files hold the column headers and rows.
+type Table struct {
+	Title    string
+	IssuedBy string
+	Details  []Field
+	Columns  []Column
+}
+
+// TableWriter writes a report one row at a time. Values are strings, ints,
+// float64 amounts, time.Time dates or nil for an empty cell.
+type TableWriter interface {
+	Row(values ...interface{}) error
+	Total(values ...interface{}) error // closing totals row, emphasised where the format allows
+	Close() error
+}
+
+// NewTableWriter writes a report in format to w. CSV and XLSX rows are
+// written through as they come; PDF pages are kept until Close.
+func NewTableWriter(w io.Writer, format string, t Table) (TableWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVTable(w, t)
+	case FormatXLSX:
+		return newXLSXTable(w, t)
+	case FormatPDF:
+		return newPDFTable(w, t), nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// ContentType returns the MIME type of a report format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return ContentTypeCSV
+	case FormatXLSX:
+		return ContentTypeXLSX
+	}
+	return ContentTypePDF
+}
+
+// cellText renders a value for CSV; amounts keep two decimals and no separators
+func cellText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	case time.Time:
+		return v.Format("2006-01-02")
+	}
+	return fmt.Sprint(v)
+}
+
+// CSVText neutralises user-controlled text for CSV files opened in a
+// spreadsheet: cells starting with = + - @ (or a tab or carriage return) are
+// prefixed with ' so they are shown as text rather than run as formulas
+func CSVText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// --- CSV ---
+
+// csvFlushEvery bounds how many rows are buffered before reaching the client
+const csvFlushEvery = 100
+
+type csvTable struct {
+	w    *csv.Writer
+	rows int
+}
+
+func newCSVTable(w io.Writer, t Table) (*csvTable, error) {
+	c := &csvTable{w: csv.NewWriter(w)}
+	header := make([]string, len(t.Columns))
+	for i, col := range t.Columns {
+		header[i] = col.Title
+	}
+	return c, c.w.Write(header)
+}
+
+func (c *csvTable) Row(values ...interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = cellText(v)
+	}
+	if err := c.w.Write(record); err != nil {
+		return err
+	}
+	if c.rows++; c.rows%csvFlushEvery == 0 {
+		c.w.Flush()
+		return c.w.Error()
+	}
+	return nil
+}
+
+func (c *csvTable) Total(values ...interface{}) error {
+	return c.Row(values...)
+}
+
+func (c *csvTable) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// --- PDF ---
+
+const (
+	pdfRowHeight    = 7
+	pdfBottomMargin = 20
+)
+
+type pdfTable struct {
+	out     io.Writer
+	pdf     *fpdf.Fpdf
+	tr      func(string) string
+	columns []Column
+	rows    int
+	totaled bool
+}
+
+// newPDFTable lays the table out on A4, landscape when the columns are
+// wider than a portrait page
+func newPDFTable(w io.Writer, t Table) *pdfTable {
+	width := 0.0
+	for _, col := range t.Columns {
+		width += col.Width
+	}
+	orientation := "P"
+	if width > 190 {
+		orientation = "L"
+	}
+
+	pdf, tr := newDocument(orientation, t.Title)
+	p := &pdfTable{out: w, pdf: pdf, tr: tr, columns: t.Columns}
+	pdf.SetAutoPageBreak(false, pdfBottomMargin)
+	pdf.AddPage()
+	header(pdf, tr, t.Title, t.IssuedBy)
+	fields(pdf, tr, t.Details)
+	pdf.Ln(4)
+	p.columnHeaders()
+	return p
+}
+
+func (p *pdfTable) columnHeaders() {
+	p.pdf.SetFont("Helvetica", "B", 9)
+	p.pdf.SetFillColor(240, 240, 240)
+	for _, col := range p.columns {
+		align := "L"
+		if col.Money {
+			align = "R"
+		}
+		p.pdf.CellFormat(col.Width, 8, p.tr(col.Title), "1", 0, align, true, 0, "")
+	}
+	p.pdf.Ln(-1)
+}
+
+func (p *pdfTable) Row(values ...interface{}) error {
+	p.rows++
+	return p.row(false, values)
+}
+
+func (p *pdfTable) Total(values ...interface{}) error {
+	p.emptyNotice()
+	p.totaled = true
+	return p.row(true, values)
+}
+
+// row writes a line, starting a new page with the column headers repeated
+// when the current one is full
+func (p *pdfTable) row(bold bool, values []interface{}) error {
+	_, pageHeight := p.pdf.GetPageSize()
+	if p.pdf.GetY()+pdfRowHeight > pageHeight-pdfBottomMargin {
+		p.pdf.AddPage()
+		p.columnHeaders()
+	}
+
+	style := ""
+	if bold {
+		style = "B"
+	}
+	p.pdf.SetFont("Helvetica", style, 9)
+	for i, col := range p.columns {
+		var v interface{}
+		if i < len(values) {
+			v = values[i]
+		}
+		text, align := "", "L"
+		switch v := v.(type) {
+		case float64:
+			text, align = FormatMoney(v), "R"
+		case time.Time:
+			text = v.Format("02 Jan 2006")
+		default:
+			text = cellText(v)
+		}
+		// Roughly 2mm per character at 9pt
+		p.pdf.CellFormat(col.Width, pdfRowHeight, truncate(p.tr(text), int(col.Width/2)), "1", 0, align, bold, 0, "")
+	}
+	p.pdf.Ln(-1)
+	return p.pdf.Error()
+}
+
+func (p *pdfTable) Close() error {
+	if !p.totaled {
+		p.emptyNotice()
+	}
+	return p.pdf.Output(p.out)
+}
+
+// emptyNotice fills the table body when no rows were written
+func (p *pdfTable) emptyNotice() {
+	if p.rows > 0 {
+		return
+	}
+	width := 0.0
+	for _, col := range p.columns {
+		width += col.Width
+	}
+	p.pdf.SetFont("Helvetica", "", 9)
+	p.pdf.CellFormat(width, 8, "No rows for this period", "1", 1, "C", false, 0, "")
+}
@@ -0,0 +1,131 @@
+package documents
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVText(t *testing.T) {
+	for in, want := range map[string]string{
+		"":                         "",
+		"Jane Wanjiru":             "Jane Wanjiru",
+		"=HYPERLINK(\"http://x\")": "'=HYPERLINK(\"http://x\")",
+		"+254712345678":            "'+254712345678",
+		"-1+1":                     "'-1+1",
+		"@SUM(A1)":                 "'@SUM(A1)",
+		"\t=1":                     "'\t=1",
+		"a=b":                      "a=b",
+	} {
+		if got := CSVText(in); got != want {
+			t.Errorf("CSVText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// rentRoll writes a small report with every kind of value in format
+func rentRoll(t *testing.T, format string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewTableWriter(&buf, format, Table{
+		Title:   "Rent roll: Oct/2026",
+		Columns: []Column{{Title: "Tenant", Width: 50}, {Title: "Due", Width: 25}, {Title: "Days", Width: 15}, {Title: "Balance", Width: 30, Money: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if err := w.Row("Jane & <Co>", due, 31, 15000.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Row("Vacant", nil, int64(0), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Total("Total", nil, nil, 15000.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSVTable(t *testing.T) {
+	want := "Tenant,Due,Days,Balance\n" +
+		"Jane & <Co>,2026-01-01,31,15000.50\n" +
+		"Vacant,,0,\n" +
+		"Total,,,15000.50\n"
+	if got := string(rentRoll(t, FormatCSV)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestXLSXTable(t *testing.T) {
+	data := rentRoll(t, FormatXLSX)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string]bool{}
+	for _, f := range zr.File {
+		parts[f.Name] = true
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		if !parts[name] {
+			t.Errorf("workbook lacks %s", name)
+		}
+	}
+
+	rows, err := ReadSpreadsheet(data, FormatXLSX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Dates are Excel serial days: 1 Jan 2026 is 46023
+	want := [][]string{
+		{"Tenant", "Due", "Days", "Balance"},
+		{"Jane & <Co>", "46023", "31", "15000.5"},
+		{"Vacant", "", "0"},
+		{"Total", "", "", "15000.5"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d: %q", len(rows), len(want), rows)
+	}
+	for i := range want {
+		if strings.Join(rows[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("row %d = %q, want %q", i+1, rows[i], want[i])
+		}
+	}
+}
+
+func TestPDFTable(t *testing.T) {
+	if data := rentRoll(t, FormatPDF); !bytes.HasPrefix(data, []byte("%PDF-")) {
+		t.Errorf("not a PDF: %q", data[:min(len(data), 16)])
+	}
+	if _, err := NewTableWriter(&bytes.Buffer{}, "ods", Table{}); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("unknown format: err = %v", err)
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumn(i); got != want {
+			t.Errorf("xlsxColumn(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestXLSXWorkbookSheetName(t *testing.T) {
+	tests := map[string]string{
+		"Rent roll: Oct/2026": `name="Rent roll- Oct-2026"`,
+		"":                    `name="Report"`,
+		"Arrears & rent roll for all properties 2026": `name="Arrears &amp; rent roll for all pro"`,
+	}
+	for title, want := range tests {
+		if got := xlsxWorkbook(title); !strings.Contains(got, want) {
+			t.Errorf("xlsxWorkbook(%q) lacks %s:\n%s", title, want, got)
+		}
+	}
+}
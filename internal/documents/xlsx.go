@@ -0,0 +1,203 @@
+package documents
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The workbook has one sheet. Its fixed parts are written first so the sheet
+// can follow row by row as the last entry of the zip.
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+		`</Types>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+		`</Relationships>`
+	// Cell styles: 0 plain, 1 bold, 2 amount, 3 bold amount, 4 date
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+		`<cellXfs count="5">` +
+		`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+		`<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+		`<xf numFmtId="4" fontId="1" fillId="0" borderId="0" xfId="0" applyNumberFormat="1" applyFont="1"/>` +
+		`<xf numFmtId="14" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+		`</cellXfs></styleSheet>`
+)
+
+const (
+	xlsxStylePlain = iota
+	xlsxStyleBold
+	xlsxStyleMoney
+	xlsxStyleBoldMoney
+	xlsxStyleDate
+)
+
+// excelEpoch is day 0 of Excel's 1900 date system (as counted after its
+// 1900 leap year bug)
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+type xlsxTable struct {
+	zip   *zip.Writer
+	sheet *bufio.Writer
+	row   int
+}
+
+func newXLSXTable(w io.Writer, t Table) (*xlsxTable, error) {
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook(t.Title)},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, p.body); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	x := &xlsxTable{zip: zw, sheet: bufio.NewWriter(f)}
+	x.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		// Keep the header row in view while scrolling
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+		`<cols>`)
+	for i, col := range t.Columns {
+		// PDF millimetres to Excel character widths, roughly
+		width := col.Width / 2
+		if width < 10 {
+			width = 10
+		}
+		n := strconv.Itoa(i + 1)
+		x.sheet.WriteString(`<col min="` + n + `" max="` + n + `" width="` + strconv.FormatFloat(width, 'f', 1, 64) + `" customWidth="1"/>`)
+	}
+	x.sheet.WriteString(`</cols><sheetData>`)
+
+	header := make([]interface{}, len(t.Columns))
+	for i, col := range t.Columns {
+		header[i] = col.Title
+	}
+	return x, x.write(true, header)
+}
+
+func (x *xlsxTable) Row(values ...interface{}) error {
+	return x.write(false, values)
+}
+
+func (x *xlsxTable) Total(values ...interface{}) error {
+	return x.write(true, values)
+}
+
+func (x *xlsxTable) write(bold bool, values []interface{}) error {
+	x.row++
+	r := strconv.Itoa(x.row)
+	x.sheet.WriteString(`<row r="` + r + `">`)
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		ref := xlsxColumn(i) + r
+		switch v := v.(type) {
+		case float64:
+			style := xlsxStyleMoney
+			if bold {
+				style = xlsxStyleBoldMoney
+			}
+			x.number(ref, style, strconv.FormatFloat(v, 'f', -1, 64))
+		case int:
+			x.number(ref, boolStyle(bold), strconv.Itoa(v))
+		case int64:
+			x.number(ref, boolStyle(bold), strconv.FormatInt(v, 10))
+		case time.Time:
+			day := time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, time.UTC)
+			x.number(ref, xlsxStyleDate, strconv.Itoa(int(day.Sub(excelEpoch).Hours()/24)))
+		default:
+			x.sheet.WriteString(`<c r="` + ref + `" t="inlineStr" s="` + strconv.Itoa(boolStyle(bold)) + `"><is><t xml:space="preserve">`)
+			xml.EscapeText(x.sheet, []byte(cellText(v)))
+			x.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := x.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (x *xlsxTable) number(ref string, style int, v string) {
+	x.sheet.WriteString(`<c r="` + ref + `" s="` + strconv.Itoa(style) + `"><v>` + v + `</v></c>`)
+}
+
+func (x *xlsxTable) Close() error {
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
+
+func boolStyle(bold bool) int {
+	if bold {
+		return xlsxStyleBold
+	}
+	return xlsxStylePlain
+}
+
+// xlsxWorkbook names the sheet after the report; sheet names are at most 31
+// characters without []:*?/\
+func xlsxWorkbook(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '-'
+		}
+		return r
+	}, title)
+	if name == "" {
+		name = "Report"
+	}
+	if r := []rune(name); len(r) > 31 {
+		name = string(r[:31])
+	}
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(name))
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="` + escaped.String() + `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+}
+
+// xlsxColumn returns the letters of a zero-based column index: A, B, ... AA
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
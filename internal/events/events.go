@@ -0,0 +1,92 @@
+package events
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Event types emitted by the application
+const (
+	PaymentCompleted = "payment.completed" // payment recorded against a tenant
+	PaymentUnmatched = "payment.unmatched" // M-Pesa payment that matched no tenant
+	PaymentMatched   = "payment.matched"   // unmatched payment assigned to a tenant
+	TenantCreated    = "tenant.created"
+	InvoiceOverdue   = "invoice.overdue" // tenant balance past its due date
+
+	// NotificationCreated is an in-app notification for one user. It is only
+	// sent to the event stream, not offered to webhooks.
+	NotificationCreated = "notification.created"
+)
+
+// Types lists every event type webhook subscribers may register for
+var Types = []string{PaymentCompleted, PaymentUnmatched, PaymentMatched, TenantCreated, InvoiceOverdue}
+
+// IsValidType reports whether t is a known event type
+func IsValidType(t string) bool {
+	for _, v := range Types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is something that happened to a landlord's data
+type Event struct {
+	Type       string      `json:"type"`
+	LandlordID int         `json:"landlord_id"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// New builds an event stamped with the current time
+func New(eventType string, landlordID int, data interface{}) Event {
+	return Event{Type: eventType, LandlordID: landlordID, OccurredAt: time.Now().UTC(), Data: data}
+}
+
+// Publisher emits events. Publishing must not fail the action that caused it.
+type Publisher interface {
+	Publish(ctx context.Context, e Event)
+}
+
+// Handler receives published events. Handlers run synchronously on the
+// publisher's goroutine and must return quickly (e.g. enqueue work).
+type Handler func(ctx context.Context, e Event)
+
+// Bus is an in-process Publisher fanning events out to subscribers
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a handler for every event
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish delivers e to all subscribers. A panicking handler is logged and
+// does not affect the others or the caller.
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	b.mu.RLock()
+	handlers := append([]Handler(nil), b.handlers...)
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("events: handler panic on %s: %v", e.Type, r)
+				}
+			}()
+			h(ctx, e)
+		}()
+	}
+}
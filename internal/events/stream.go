@@ -0,0 +1,142 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// streamBuffer is how many events a slow subscriber may fall behind before
+// further events are dropped for it
+const streamBuffer = 64
+
+// Stream delivers events to live connections such as the dashboard's event
+// stream. Publish it from the Bus; Subscribe from connection handlers.
+type Stream interface {
+	Publisher
+	// Subscribe returns a channel receiving events accepted by match, and a
+	// function that unsubscribes and closes the channel
+	Subscribe(match func(Event) bool) (<-chan Event, func())
+}
+
+// MemoryStream is an in-process Stream. Only connections to the same server
+// see an event; use PostgresStream when running several replicas.
+type MemoryStream struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   map[int]*streamSubscriber
+}
+
+type streamSubscriber struct {
+	ch    chan Event
+	match func(Event) bool
+}
+
+func NewMemoryStream() *MemoryStream {
+	return &MemoryStream{subs: map[int]*streamSubscriber{}}
+}
+
+func (s *MemoryStream) Subscribe(match func(Event) bool) (<-chan Event, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID
+	s.nextID++
+	sub := &streamSubscriber{ch: make(chan Event, streamBuffer), match: match}
+	s.subs[id] = sub
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subs, id)
+			s.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+}
+
+// Publish hands e to every matching subscriber without blocking
+func (s *MemoryStream) Publish(ctx context.Context, e Event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, sub := range s.subs {
+		if !sub.match(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			log.Printf("events: stream subscriber is full, dropped %s", e.Type)
+		}
+	}
+}
+
+// streamChannel is the Postgres NOTIFY channel carrying stream events
+const streamChannel = "smart_rentals_events"
+
+// PostgresStream relays events through Postgres LISTEN/NOTIFY so that every
+// replica's subscribers see events published on any replica. Payloads must
+// stay under Postgres' 8000 byte NOTIFY limit.
+type PostgresStream struct {
+	db    *sql.DB
+	local *MemoryStream
+}
+
+// NewPostgresStream starts listening on dsn and returns the stream. The
+// listener reconnects on its own; events published while it is
+// disconnected are lost, as with any live stream.
+func NewPostgresStream(db *sql.DB, dsn string) (*PostgresStream, error) {
+	listener := pq.NewListener(dsn, 5*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("events: stream listener: %v", err)
+		}
+	})
+	if err := listener.Listen(streamChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	s := &PostgresStream{db: db, local: NewMemoryStream()}
+	go s.relay(listener)
+	return s, nil
+}
+
+func (s *PostgresStream) Subscribe(match func(Event) bool) (<-chan Event, func()) {
+	return s.local.Subscribe(match)
+}
+
+// Publish sends e to all replicas, including this one, through NOTIFY
+func (s *PostgresStream) Publish(ctx context.Context, e Event) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("events: encode %s for stream: %v", e.Type, err)
+		return
+	}
+	if _, err := s.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", streamChannel, string(payload)); err != nil {
+		log.Printf("events: notify %s: %v", e.Type, err)
+	}
+}
+
+func (s *PostgresStream) relay(listener *pq.Listener) {
+	for {
+		select {
+		case n := <-listener.Notify:
+			if n == nil {
+				continue // reconnected; notifications in between are lost
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(n.Extra), &e); err != nil {
+				log.Printf("events: decode stream notification: %v", err)
+				continue
+			}
+			s.local.Publish(context.Background(), e)
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
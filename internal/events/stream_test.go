@@ -0,0 +1,55 @@
+package events
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryStream(t *testing.T) {
+	s := NewMemoryStream()
+	mine, unsubscribe := s.Subscribe(func(e Event) bool { return e.LandlordID == 7 })
+	defer unsubscribe()
+	others, unsubscribeOthers := s.Subscribe(func(e Event) bool { return e.LandlordID != 7 })
+
+	ctx := context.Background()
+	s.Publish(ctx, New(PaymentCompleted, 7, nil))
+	s.Publish(ctx, New(TenantCreated, 8, nil))
+
+	if e := <-mine; e.Type != PaymentCompleted {
+		t.Errorf("landlord 7 received %s", e.Type)
+	}
+	if e := <-others; e.Type != TenantCreated {
+		t.Errorf("other subscriber received %s", e.Type)
+	}
+	select {
+	case e := <-mine:
+		t.Errorf("landlord 7 received another landlord's %s", e.Type)
+	default:
+	}
+
+	// Unsubscribing closes the channel, twice is harmless, and later events
+	// are not sent to it
+	unsubscribeOthers()
+	unsubscribeOthers()
+	if _, ok := <-others; ok {
+		t.Error("channel still open after unsubscribe")
+	}
+	s.Publish(ctx, New(TenantCreated, 8, nil))
+}
+
+func TestMemoryStreamDropsForSlowSubscribers(t *testing.T) {
+	s := NewMemoryStream()
+	ch, unsubscribe := s.Subscribe(func(Event) bool { return true })
+	defer unsubscribe()
+
+	// Publishing never blocks on a subscriber that stopped reading
+	for i := 0; i < streamBuffer+10; i++ {
+		s.Publish(context.Background(), New(PaymentCompleted, i, nil))
+	}
+	if len(ch) != streamBuffer {
+		t.Errorf("buffered %d events, want %d", len(ch), streamBuffer)
+	}
+	if e := <-ch; e.LandlordID != 0 {
+		t.Errorf("first buffered event is landlord %d, want the oldest", e.LandlordID)
+	}
+}
@@ -0,0 +1,303 @@
+// Package listing builds the SQL of list endpoints: filters, sorting and
+// keyset (cursor) pagination, so every list pages and sorts the same way.
+package listing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+var (
+	ErrInvalidLimit  = fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+	ErrInvalidCursor = errors.New("invalid cursor; it must come from next_cursor of the same list and sort")
+	ErrInvalidSort   = errors.New("invalid sort")
+	ErrInvalidFilter = errors.New("invalid filter")
+)
+
+// Spec describes what a list may be sorted by: sort names, as the fields of
+// the listed items, mapped to SQL expressions. Expressions must not be NULL
+// and ID must be unique, so every row has a place in the order.
+type Spec struct {
+	Sorts       map[string]string
+	DefaultSort string // e.g. "-created_at" for newest first
+	ID          string // tie breaker, e.g. "p.id"
+}
+
+// Page is a request for one page of a list
+type Page struct {
+	Limit     int
+	Sort      string // a key of the spec's Sorts
+	Desc      bool
+	WithTotal bool // count all matching rows as well
+
+	spec  Spec
+	after *cursor
+}
+
+// cursor is the sort key of the last item of a page; the next page starts
+// after it
+type cursor struct {
+	Sort  string      `json:"s"`
+	Desc  bool        `json:"d"`
+	Value interface{} `json:"v"`
+	ID    int64       `json:"i"`
+}
+
+// ParsePage reads limit, sort ("amount" ascending, "-amount" descending),
+// cursor and include_total from a query string
+func ParsePage(q url.Values, spec Spec) (Page, error) {
+	p := Page{Limit: DefaultLimit, spec: spec}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxLimit {
+			return p, ErrInvalidLimit
+		}
+		p.Limit = n
+	}
+	if v := q.Get("include_total"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return p, fmt.Errorf("%w: include_total must be true or false", ErrInvalidFilter)
+		}
+		p.WithTotal = b
+	}
+
+	order := q.Get("sort")
+	if v := q.Get("cursor"); v != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(v)
+		if err != nil {
+			return p, ErrInvalidCursor
+		}
+		var c cursor
+		if err := json.Unmarshal(raw, &c); err != nil || !scalar(c.Value) {
+			return p, ErrInvalidCursor
+		}
+		if _, ok := spec.Sorts[c.Sort]; !ok {
+			return p, ErrInvalidCursor
+		}
+		cursorSort := c.Sort
+		if c.Desc {
+			cursorSort = "-" + cursorSort
+		}
+		if order != "" && order != cursorSort {
+			return p, ErrInvalidCursor
+		}
+		order = cursorSort
+		p.after = &c
+	}
+	if order == "" {
+		order = spec.DefaultSort
+	}
+	p.Sort = strings.TrimPrefix(order, "-")
+	p.Desc = strings.HasPrefix(order, "-")
+	if _, ok := spec.Sorts[p.Sort]; !ok {
+		return p, fmt.Errorf("%w: sort by one of %s, prefixed with - for descending", ErrInvalidSort, strings.Join(sortNames(spec), ", "))
+	}
+	return p, nil
+}
+
+// scalar reports whether a decoded cursor value can be a sort key: the
+// string, number or boolean Next encodes, not null, an object or an array
+func scalar(v interface{}) bool {
+	switch v.(type) {
+	case string, float64, bool:
+		return true
+	}
+	return false
+}
+
+func sortNames(spec Spec) []string {
+	names := make([]string, 0, len(spec.Sorts))
+	for name := range spec.Sorts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Query collects the WHERE conditions of a list and their arguments
+type Query struct {
+	Args  []interface{}
+	conds []string
+}
+
+// Arg adds an argument and returns its placeholder
+func (q *Query) Arg(v interface{}) string {
+	q.Args = append(q.Args, v)
+	return "$" + strconv.Itoa(len(q.Args))
+}
+
+// Where adds a condition; its arguments come from Arg
+func (q *Query) Where(cond string) {
+	q.conds = append(q.conds, cond)
+}
+
+// WhereClause is " WHERE ..." joining the conditions, or empty
+func (q *Query) WhereClause() string {
+	if len(q.conds) == 0 {
+		return ""
+	}
+	return " WHERE (" + strings.Join(q.conds, ") AND (") + ")"
+}
+
+// Count is the statement counting every row of a list, for its total.
+// from is the FROM clause with joins.
+func (q *Query) Count(from string) string {
+	return "SELECT COUNT(*) " + from + q.WhereClause()
+}
+
+// Select is the statement fetching a page: the rows after the cursor in
+// sort order, plus one more so Trim can tell whether another page follows
+func (q Query) Select(selectFrom string, p Page) (string, []interface{}) {
+	q.Args = append([]interface{}(nil), q.Args...)
+	col := p.spec.Sorts[p.Sort]
+	dir, cmp := "ASC", ">"
+	if p.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if p.after != nil {
+		q.conds = append(q.conds[:len(q.conds):len(q.conds)],
+			fmt.Sprintf("(%s, %s) %s (%s, %s)", col, p.spec.ID, cmp, q.Arg(p.after.Value), q.Arg(p.after.ID)))
+	}
+	return fmt.Sprintf("%s%s ORDER BY %s %s, %s %s LIMIT %d",
+		selectFrom, q.WhereClause(), col, dir, p.spec.ID, dir, p.Limit+1), q.Args
+}
+
+// Next drops the extra item fetched by Select and returns the cursor of the
+// next page, or nil on the last page. key gives an item's value of the sort
+// field and its id.
+func Next[T any](p Page, items []T, key func(T) (interface{}, int64)) ([]T, *string) {
+	if len(items) <= p.Limit {
+		return items, nil
+	}
+	items = items[:p.Limit]
+	value, id := key(items[len(items)-1])
+	raw, _ := json.Marshal(cursor{Sort: p.Sort, Desc: p.Desc, Value: cursorValue(value), ID: id})
+	next := base64.RawURLEncoding.EncodeToString(raw)
+	return items, &next
+}
+
+// After is the sort value and id of the item the page starts after, for
+// lists paged outside SQL; ok is false on the first page. Timestamps come
+// back as RFC 3339 strings and numbers as float64.
+func (p Page) After() (value interface{}, id int64, ok bool) {
+	if p.after == nil {
+		return nil, 0, false
+	}
+	return p.after.Value, p.after.ID, true
+}
+
+// cursorValue keeps the full precision of timestamps, which Postgres
+// stores to the microsecond
+func cursorValue(v interface{}) interface{} {
+	if t, ok := v.(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+// Filter holds the filters a list may support. Lists ignore those that do
+// not apply to them.
+type Filter struct {
+	Status     string
+	Method     string
+	PropertyID int
+	From       time.Time // inclusive
+	To         time.Time // exclusive: the day after the "to" date
+	MinAmount  *float64
+	MaxAmount  *float64
+	Search     string
+}
+
+// ParseFilter reads status, method, property_id, from and to (YYYY-MM-DD,
+// inclusive), min_amount, max_amount and q (search text) from a query string
+func ParseFilter(q url.Values) (Filter, error) {
+	f := Filter{
+		Status: strings.ToUpper(strings.TrimSpace(q.Get("status"))),
+		Method: strings.ToUpper(strings.TrimSpace(q.Get("method"))),
+		Search: strings.TrimSpace(q.Get("q")),
+	}
+	var err error
+	if v := q.Get("property_id"); v != "" {
+		if f.PropertyID, err = strconv.Atoi(v); err != nil || f.PropertyID <= 0 {
+			return f, fmt.Errorf("%w: invalid property_id", ErrInvalidFilter)
+		}
+	}
+	if v := q.Get("from"); v != "" {
+		if f.From, err = time.Parse("2006-01-02", v); err != nil {
+			return f, fmt.Errorf("%w: invalid from date, expected YYYY-MM-DD", ErrInvalidFilter)
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return f, fmt.Errorf("%w: invalid to date, expected YYYY-MM-DD", ErrInvalidFilter)
+		}
+		f.To = to.AddDate(0, 0, 1)
+	}
+	if !f.From.IsZero() && !f.To.IsZero() && !f.To.After(f.From) {
+		return f, fmt.Errorf("%w: to must not be before from", ErrInvalidFilter)
+	}
+	for param, dst := range map[string]**float64{"min_amount": &f.MinAmount, "max_amount": &f.MaxAmount} {
+		if v := q.Get(param); v != "" {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return f, fmt.Errorf("%w: invalid %s", ErrInvalidFilter, param)
+			}
+			*dst = &n
+		}
+	}
+	if len(f.Search) > 100 {
+		return f, fmt.Errorf("%w: search text must be at most 100 characters", ErrInvalidFilter)
+	}
+	return f, nil
+}
+
+// DateRange limits col, a timestamp, to the filter's dates. Days run
+// midnight to midnight in East Africa Time, where the landlords are.
+func (q *Query) DateRange(col string, f Filter) {
+	if !f.From.IsZero() {
+		q.Where(col + " >= (" + q.Arg(f.From.Format("2006-01-02")) + "::DATE)::TIMESTAMP AT TIME ZONE 'Africa/Nairobi'")
+	}
+	if !f.To.IsZero() {
+		q.Where(col + " < (" + q.Arg(f.To.Format("2006-01-02")) + "::DATE)::TIMESTAMP AT TIME ZONE 'Africa/Nairobi'")
+	}
+}
+
+// AmountRange limits col to the filter's amounts
+func (q *Query) AmountRange(col string, f Filter) {
+	if f.MinAmount != nil {
+		q.Where(col + " >= " + q.Arg(*f.MinAmount))
+	}
+	if f.MaxAmount != nil {
+		q.Where(col + " <= " + q.Arg(*f.MaxAmount))
+	}
+}
+
+// Search keeps rows where any of cols contains the filter's search text,
+// ignoring case
+func (q *Query) Search(f Filter, cols ...string) {
+	if f.Search == "" || len(cols) == 0 {
+		return
+	}
+	pattern := q.Arg("%" + likeEscaper.Replace(f.Search) + "%")
+	conds := make([]string, len(cols))
+	for i, col := range cols {
+		conds[i] = col + " ILIKE " + pattern
+	}
+	q.Where(strings.Join(conds, " OR "))
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
@@ -0,0 +1,238 @@
+package listing
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var paymentSpec = Spec{
+	Sorts:       map[string]string{"created_at": "p.created_at", "amount": "p.amount", "receipt": "p.receipt"},
+	DefaultSort: "-created_at",
+	ID:          "p.id",
+}
+
+func query(s string) url.Values {
+	q, err := url.ParseQuery(s)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// nextCursor returns the cursor Next gives after an item with value and id
+func nextCursor(t *testing.T, p Page, value interface{}, id int64) string {
+	t.Helper()
+	p.Limit = 1
+	_, next := Next(p, []int{0, 1}, func(int) (interface{}, int64) { return value, id })
+	if next == nil {
+		t.Fatal("no next cursor")
+	}
+	return *next
+}
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Page
+		err   error
+	}{
+		{"", Page{Limit: DefaultLimit, Sort: "created_at", Desc: true}, nil},
+		{"limit=10&sort=amount", Page{Limit: 10, Sort: "amount"}, nil},
+		{"sort=-receipt&include_total=true", Page{Limit: DefaultLimit, Sort: "receipt", Desc: true, WithTotal: true}, nil},
+		{"limit=0", Page{}, ErrInvalidLimit},
+		{"limit=201", Page{}, ErrInvalidLimit},
+		{"limit=ten", Page{}, ErrInvalidLimit},
+		{"include_total=maybe", Page{}, ErrInvalidFilter},
+		{"sort=tenant_name", Page{}, ErrInvalidSort},
+		{"sort=--amount", Page{}, ErrInvalidSort},
+		{"sort=p.amount", Page{}, ErrInvalidSort},
+	}
+	for _, tt := range tests {
+		p, err := ParsePage(query(tt.query), paymentSpec)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%q: err = %v, want %v", tt.query, err, tt.err)
+			continue
+		}
+		if err == nil && (p.Limit != tt.want.Limit || p.Sort != tt.want.Sort || p.Desc != tt.want.Desc || p.WithTotal != tt.want.WithTotal) {
+			t.Errorf("%q: page %+v, want %+v", tt.query, p, tt.want)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	at := time.Date(2026, time.October, 5, 10, 30, 0, 123456000, time.UTC)
+	tests := []struct {
+		sort  string
+		value interface{}
+		want  interface{} // as After returns it
+	}{
+		{"-created_at", at, "2026-10-05T10:30:00.123456Z"},
+		{"amount", 15000.5, 15000.5},
+		{"-amount", 15000, float64(15000)},
+		{"receipt", "SJ12ABC", "SJ12ABC"},
+	}
+	for _, tt := range tests {
+		first, err := ParsePage(query("limit=1&sort="+tt.sort), paymentSpec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, ok := first.After(); ok {
+			t.Errorf("%s: first page starts after an item", tt.sort)
+		}
+		cursor := nextCursor(t, first, tt.value, 42)
+
+		// The cursor carries its sort, so sort may be left out or repeated
+		for _, q := range []string{"cursor=" + cursor, "cursor=" + cursor + "&sort=" + tt.sort} {
+			p, err := ParsePage(query(q), paymentSpec)
+			if err != nil {
+				t.Fatalf("%s: %v", q, err)
+			}
+			value, id, ok := p.After()
+			if !ok || id != 42 || !reflect.DeepEqual(value, tt.want) || p.Sort != first.Sort || p.Desc != first.Desc {
+				t.Errorf("%s: after %v (%T), %d, %v; sort %s desc %v", tt.sort, value, value, id, ok, p.Sort, p.Desc)
+			}
+		}
+	}
+}
+
+func TestInvalidCursors(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	amountAsc, _ := ParsePage(query("sort=amount"), paymentSpec)
+	valid := nextCursor(t, amountAsc, 15000.0, 42)
+
+	tests := map[string]string{
+		"not base64":            "cursor=%25%25%25",
+		"padded base64":         "cursor=" + base64.URLEncoding.EncodeToString([]byte(`{"s":"amount","v":1,"i":1}`)),
+		"not json":              "cursor=" + encode("amount:15000"),
+		"no value":              "cursor=" + encode(`{"s":"amount","i":1}`),
+		"null value":            "cursor=" + encode(`{"s":"amount","v":null,"i":1}`),
+		"object value":          "cursor=" + encode(`{"s":"amount","v":{"$gt":0},"i":1}`),
+		"array value":           "cursor=" + encode(`{"s":"amount","v":[1,2],"i":1}`),
+		"unknown sort":          "cursor=" + encode(`{"s":"password_hash","v":"a","i":1}`),
+		"sort changed":          "cursor=" + valid + "&sort=-amount",
+		"other sort field":      "cursor=" + valid + "&sort=receipt",
+		"truncated":             "cursor=" + valid[:len(valid)-4],
+		"id is not a number":    "cursor=" + encode(`{"s":"amount","v":1,"i":"1"}`),
+		"direction is not bool": "cursor=" + encode(`{"s":"amount","d":"yes","v":1,"i":1}`),
+		"sort is not a string":  "cursor=" + encode(`{"s":1,"v":1,"i":1}`),
+		"empty object":          "cursor=" + encode(`{}`),
+		"json null":             "cursor=" + encode(`null`),
+	}
+	for name, q := range tests {
+		if _, err := ParsePage(query(q), paymentSpec); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: err = %v, want ErrInvalidCursor", name, err)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	var q Query
+	q.Where("p.landlord_id = " + q.Arg(7))
+
+	first, _ := ParsePage(query("limit=2&sort=-amount"), paymentSpec)
+	sql, args := q.Select("SELECT p.id FROM payments p", first)
+	if want := "SELECT p.id FROM payments p WHERE (p.landlord_id = $1) ORDER BY p.amount DESC, p.id DESC LIMIT 3"; sql != want {
+		t.Errorf("first page:\n got %s\nwant %s", sql, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{7}) {
+		t.Errorf("first page args %v", args)
+	}
+
+	next, _ := ParsePage(query("limit=2&cursor="+nextCursor(t, first, 15000.0, 42)), paymentSpec)
+	sql, args = q.Select("SELECT p.id FROM payments p", next)
+	// Rows with the same amount are told apart by id, in the same direction
+	if want := "SELECT p.id FROM payments p WHERE (p.landlord_id = $1) AND ((p.amount, p.id) < ($2, $3)) ORDER BY p.amount DESC, p.id DESC LIMIT 3"; sql != want {
+		t.Errorf("next page:\n got %s\nwant %s", sql, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{7, 15000.0, int64(42)}) {
+		t.Errorf("next page args %v", args)
+	}
+	// Select leaves the query as it was for the count
+	if len(q.Args) != 1 || q.Count("FROM payments p") != "SELECT COUNT(*) FROM payments p WHERE (p.landlord_id = $1)" {
+		t.Errorf("query changed by Select: %v, %s", q.Args, q.Count("FROM payments p"))
+	}
+}
+
+// TestPagingWithTies pages through rows sharing sort values the way lists
+// paged outside SQL do, and expects every row exactly once in order
+func TestPagingWithTies(t *testing.T) {
+	type row struct {
+		amount float64
+		id     int64
+	}
+	// Sorted by amount then id, ascending
+	rows := []row{{100, 1}, {100, 4}, {100, 9}, {200, 2}, {200, 3}, {300, 5}, {300, 6}, {300, 7}, {300, 8}}
+	key := func(r row) (interface{}, int64) { return r.amount, r.id }
+
+	for _, limit := range []string{"1", "2", "3", "4", "9", "10"} {
+		var seen []row
+		q := "limit=" + limit + "&sort=amount"
+		for pages := 0; ; pages++ {
+			if pages > len(rows) {
+				t.Fatalf("limit %s: paging does not end", limit)
+			}
+			p, err := ParsePage(query(q), paymentSpec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var page []row
+			for _, r := range rows {
+				if value, id, ok := p.After(); ok {
+					after := value.(float64)
+					if r.amount < after || (r.amount == after && r.id <= id) {
+						continue
+					}
+				}
+				if len(page) <= p.Limit {
+					page = append(page, r)
+				}
+			}
+			page, next := Next(p, page, key)
+			seen = append(seen, page...)
+			if next == nil {
+				break
+			}
+			q = "limit=" + limit + "&cursor=" + *next
+		}
+		if !reflect.DeepEqual(seen, rows) {
+			t.Errorf("limit %s: paged %v, want %v", limit, seen, rows)
+		}
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	f, err := ParseFilter(query("status=completed&method=%20mpesa&property_id=3&from=2026-10-01&to=2026-10-31&min_amount=100&max_amount=2000.5&q=%20Jane%20"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Status != "COMPLETED" || f.Method != "MPESA" || f.PropertyID != 3 || f.Search != "Jane" ||
+		f.From.Format("2006-01-02") != "2026-10-01" || f.To.Format("2006-01-02") != "2026-11-01" ||
+		*f.MinAmount != 100 || *f.MaxAmount != 2000.5 {
+		t.Errorf("filter %+v", f)
+	}
+	for _, q := range []string{"property_id=0", "property_id=x", "from=01/10/2026", "to=2026-13-01", "from=2026-10-02&to=2026-10-01", "min_amount=ten", "q=" + string(make([]byte, 101))} {
+		if _, err := ParseFilter(query(q)); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("%q: err = %v, want ErrInvalidFilter", q, err)
+		}
+	}
+	// A single day is from and to the same date
+	if _, err := ParseFilter(query("from=2026-10-01&to=2026-10-01")); err != nil {
+		t.Errorf("single day: %v", err)
+	}
+}
+
+func TestQuerySearch(t *testing.T) {
+	var q Query
+	q.Search(Filter{Search: "50%_off"}, "t.tenant_name", "u.unit_name")
+	q.Search(Filter{}, "t.tenant_name")
+	if got := q.WhereClause(); got != " WHERE (t.tenant_name ILIKE $1 OR u.unit_name ILIKE $1)" {
+		t.Errorf("where %s", got)
+	}
+	if !reflect.DeepEqual(q.Args, []interface{}{`%50\%\_off%`}) {
+		t.Errorf("args %v", q.Args)
+	}
+}
@@ -5,51 +5,46 @@ import (
 )
 
 type Tenant struct {
-	ID         uint    `json:"id"`
-	TenantName string  `json:"tenant_name"`
-	PaymentNo1 string  `json:"payment_no1"` //1 -> 3
-	PaymentNo2 string  `json:"payment_no2"`
-	Rent       float64 `json:"rent"`
-	Balance    float64 `json:"balance"`
-	UnitID     uint    `json:"unit_id"`     //FK -> units table
-	LandlordID uint    `json:"landlord_id"` //FK -> users table
+	ID          uint    `json:"id"`
+	TenantName  string  `json:"tenant_name"`
+	PaymentNo1  string  `json:"payment_no1"` //1 -> 3
+	PaymentNo2  string  `json:"payment_no2"`
+	Rent        float64 `json:"rent"`
+	Balance     float64 `json:"balance"`
+	RentDueDay  int     `json:"rent_due_day"` // 1-28
+	SMSOptOut   bool    `json:"sms_opt_out"`
+	Email       string  `json:"email"`
+	EmailOptOut bool    `json:"email_opt_out"`
+	UnitID      uint    `json:"unit_id"`     //FK -> units table
+	LandlordID  uint    `json:"landlord_id"` //FK -> users table
+	UserID      *uint   `json:"user_id"`     // linked tenant-role account, if any
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// used for sql joins, joining tenant to unit
+// used for sql joins, joining tenant to unit and property
 type TenantWithUnit struct {
-	ID         uint    `json:"id"`
-	TenantName string  `json:"tenant_name"`
-	PaymentNo1 string  `json:"payment_no1"`
-	PaymentNo2 string  `json:"payment_no2"`
-	Rent       float64 `json:"rent"`
-	Balance    float64 `json:"balance"`
-
-	UnitID    uint    `json:"unit_id"`
-	UnitName  string  `json:"unit_name"`
-	UnitType  string  `json:"unit_type"`
-	UnitPrice float64 `json:"unit_price"`
-
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Tenant
+	UnitName      string `json:"unit_name"`
+	PropertyID    uint   `json:"property_id"`
+	PropertyTitle string `json:"property_title"`
 }
 
 type Property struct {
-	ID           uint      `json:"id"`
-	LandlordID   uint      `json:"landlord_id"` //FK userID users table(landlord is a user)
-	Title        string    `json:"title"`
-	Description  string    `json:"description"`
-	Location     string    `json:"location"`
-	PropertyType string    `json:"property_type"`
-	Vacancy      bool      `json:"vacancy"`
-	TotalRent    int       `json:"total_rent"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-}
-
-// not used at the moment
+	ID             uint      `json:"id"`
+	LandlordID     uint      `json:"landlord_id"`     //FK userID users table(landlord is a user)
+	OrganizationID *uint     `json:"organization_id"` // managing organization, if any
+	Title          string    `json:"title"`
+	Description    string    `json:"description"`
+	Location       string    `json:"location"`
+	PropertyType   string    `json:"property_type"`
+	Vacancy        bool      `json:"vacancy"`
+	TotalRent      int       `json:"total_rent"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
 type Unit struct {
 	ID         uint      `json:"id"`
 	PropertyID uint      `json:"property_id"` //FK property_id -> properties
@@ -69,37 +64,546 @@ type CreateUnitInput struct {
 	UnitPrice  float64 `json:"unit_price" binding:"required"`
 }
 
+// Notification is an in-app notification for a user. TenantID names the
+// tenant it concerns, if any: the tenant's own account, or their landlord.
 type Notification struct {
 	ID       uint   `json:"id"`
+	Type     string `json:"type"` // event that caused it, e.g. payment.unmatched
 	Message  string `json:"message"`
 	IsRead   bool   `json:"is_read"`
-	TenantID uint   `json:"tenant_id"`
+	TenantID *uint  `json:"tenant_id"`
+	UserID   *uint  `json:"user_id"`
 
-	CreatedAt time.Time `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Conversation is a thread between a landlord's staff and one tenant
+type Conversation struct {
+	ID          uint     `json:"id"`
+	LandlordID  uint     `json:"landlord_id"`
+	TenantID    uint     `json:"tenant_id"`
+	TenantName  string   `json:"tenant_name"`
+	UnitName    string   `json:"unit_name"`
+	Subject     string   `json:"subject"`
+	LastMessage *Message `json:"last_message,omitempty"`
+	UnreadCount int      `json:"unread_count"` // messages from the other side not yet read
+
+	LastMessageAt time.Time `json:"last_message_at"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 type Message struct {
-	ID       uint   `json:"id"`
-	Sender   string `json:"sender"`
-	Receiver string `json:"receiver"`
-	Content  string `json:"content"`
+	ID             uint                `json:"id"`
+	ConversationID uint                `json:"conversation_id"`
+	Sender         string              `json:"sender"`      // display name
+	SenderType     string              `json:"sender_type"` // staff or tenant
+	SenderID       *uint               `json:"sender_id"`   // user who sent it, if any
+	Receiver       string              `json:"receiver"`
+	Content        string              `json:"content"`
+	BroadcastID    *uint               `json:"broadcast_id,omitempty"`
+	Attachments    []MessageAttachment `json:"attachments"`
+	ReadAt         *time.Time          `json:"read_at"` // when the other side read it
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MessageAttachment describes a file sent with a message; Data is only
+// loaded for downloads
+type MessageAttachment struct {
+	ID          uint   `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	Data        []byte `json:"-"`
+}
+
+// Broadcast is a message sent to every tenant of a property
+type Broadcast struct {
+	ID         uint   `json:"id"`
+	LandlordID uint   `json:"landlord_id"`
+	PropertyID uint   `json:"property_id"`
+	Subject    string `json:"subject"`
+	Content    string `json:"content"`
+	Recipients int    `json:"recipients"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MaintenanceTicket is a repair request for a unit
+type MaintenanceTicket struct {
+	ID            uint   `json:"id"`
+	LandlordID    uint   `json:"landlord_id"`
+	PropertyID    uint   `json:"property_id"`
+	PropertyTitle string `json:"property_title"`
+	UnitID        uint   `json:"unit_id"`
+	UnitName      string `json:"unit_name"`
+	TenantID      *uint  `json:"tenant_id"`
+	TenantName    string `json:"tenant_name,omitempty"`
+	Title         string `json:"title"`
+	Description   string `json:"description"`
+	Category      string `json:"category"` // plumbing, electrical, appliance, structural, pest_control, security, cleaning, other
+	Priority      string `json:"priority"` // low, normal, high, urgent
+	Status        string `json:"status"`   // open, assigned, in_progress, resolved
+
+	AssigneeID   *uint    `json:"assignee_id"` // caretaker or other staff
+	AssigneeName string   `json:"assignee_name,omitempty"`
+	VendorID     *uint    `json:"vendor_id"`
+	VendorName   string   `json:"vendor_name,omitempty"`
+	Cost         *float64 `json:"cost"`
+	ChargeID     *uint    `json:"charge_id"` // tenant charge when the cost was recharged
+	Charged      *float64 `json:"charged_amount"`
+	Resolution   string   `json:"resolution,omitempty"`
+	ReportedBy   *uint    `json:"reported_by"`
+	Overdue      bool     `json:"overdue"` // unresolved past its SLA deadline
+
+	Photos []MaintenancePhoto `json:"photos,omitempty"`
+
+	DueAt      time.Time  `json:"due_at"` // SLA deadline
+	AssignedAt *time.Time `json:"assigned_at"`
+	StartedAt  *time.Time `json:"started_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// MaintenancePhoto describes a photo of a ticket; Data is only loaded for
+// downloads
+type MaintenancePhoto struct {
+	ID          uint   `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	Data        []byte `json:"-"`
 
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// Vendor is a contractor repair work can be assigned to
+type Vendor struct {
+	ID         uint   `json:"id"`
+	LandlordID uint   `json:"landlord_id"`
+	Name       string `json:"name"`
+	Phone      string `json:"phone"`
+	Email      string `json:"email"`
+	Trade      string `json:"trade"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TenantCharge is an amount added to a tenant's balance besides rent
+type TenantCharge struct {
+	ID          uint    `json:"id"`
+	LandlordID  uint    `json:"landlord_id"`
+	TenantID    uint    `json:"tenant_id"`
+	Amount      float64 `json:"amount"`
+	Description string  `json:"description"`
+	TicketID    *uint   `json:"ticket_id"` // maintenance ticket it recharges
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MaintenanceSLA summarizes a property's tickets created in a period
+type MaintenanceSLA struct {
+	PropertyID         uint     `json:"property_id"`
+	PropertyTitle      string   `json:"property_title"`
+	Tickets            int      `json:"tickets"`
+	Open               int      `json:"open"`
+	Resolved           int      `json:"resolved"`
+	ResolvedOnTime     int      `json:"resolved_on_time"`
+	Breached           int      `json:"breached"`     // resolved late, or still open past the deadline
+	OnTimeRate         *float64 `json:"on_time_rate"` // percent of resolved tickets resolved on time
+	AvgResolutionHours *float64 `json:"avg_resolution_hours"`
+	TotalCost          float64  `json:"total_cost"`
+	Recharged          float64  `json:"recharged"`
+}
+
+// Expense is money spent on a property, optionally on one of its units
+type Expense struct {
+	ID                 uint    `json:"id"`
+	LandlordID         uint    `json:"landlord_id"`
+	PropertyID         uint    `json:"property_id"`
+	PropertyTitle      string  `json:"property_title"`
+	UnitID             *uint   `json:"unit_id"`
+	UnitName           string  `json:"unit_name,omitempty"`
+	VendorID           *uint   `json:"vendor_id"`
+	VendorName         string  `json:"vendor_name,omitempty"`
+	Category           string  `json:"category"`
+	Amount             float64 `json:"amount"`
+	Description        string  `json:"description"`
+	ExpenseDate        string  `json:"expense_date"` // YYYY-MM-DD
+	Reference          string  `json:"reference"`    // invoice number or M-Pesa code
+	RecurringExpenseID *uint   `json:"recurring_expense_id"`
+	ReceiptFilename    string  `json:"receipt_filename,omitempty"` // set when a receipt is attached
+	CreatedBy          *uint   `json:"created_by"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RecurringExpense generates an expense on every occurrence of its schedule
+type RecurringExpense struct {
+	ID            uint    `json:"id"`
+	LandlordID    uint    `json:"landlord_id"`
+	PropertyID    uint    `json:"property_id"`
+	PropertyTitle string  `json:"property_title"`
+	UnitID        *uint   `json:"unit_id"`
+	VendorID      *uint   `json:"vendor_id"`
+	Category      string  `json:"category"`
+	Amount        float64 `json:"amount"`
+	Description   string  `json:"description"`
+	Frequency     string  `json:"frequency"`  // weekly, monthly, quarterly, yearly
+	StartDate     string  `json:"start_date"` // YYYY-MM-DD
+	EndDate       *string `json:"end_date"`
+	NextDate      string  `json:"next_date"`
+	Occurrences   int     `json:"occurrences"` // expenses generated so far
+	Active        bool    `json:"active"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PropertyProfitAndLoss is a property's collected rent against its
+// expenses over a period
+type PropertyProfitAndLoss struct {
+	PropertyID         uint               `json:"property_id"`
+	PropertyTitle      string             `json:"property_title"`
+	RentCollected      float64            `json:"rent_collected"`
+	PaymentCount       int                `json:"payment_count"`
+	Expenses           map[string]float64 `json:"expenses"` // by category
+	TotalExpenses      float64            `json:"total_expenses"`
+	NetOperatingIncome float64            `json:"net_operating_income"`
+}
+
+// ProfitAndLoss is the profit and loss report over [From, To]
+type ProfitAndLoss struct {
+	From       string                  `json:"from"`
+	To         string                  `json:"to"` // inclusive
+	Properties []PropertyProfitAndLoss `json:"properties"`
+	Total      PropertyProfitAndLoss   `json:"total"` // all properties; no property ID or title
+}
+
+// DashboardSummary is the landlord dashboard over the properties a user can
+// see. Rent figures are for the current month.
+type DashboardSummary struct {
+	GeneratedAt       time.Time           `json:"generated_at"`
+	Cached            bool                `json:"cached"`
+	PeriodStart       string              `json:"period_start"`
+	PeriodEnd         string              `json:"period_end"` // inclusive
+	Properties        []PropertyDashboard `json:"properties"`
+	Units             int                 `json:"units"`
+	OccupiedUnits     int                 `json:"occupied_units"`
+	OccupancyRate     float64             `json:"occupancy_rate"` // percent
+	ExpectedRent      float64             `json:"expected_rent"`
+	CollectedRent     float64             `json:"collected_rent"`
+	CollectionRate    float64             `json:"collection_rate"` // percent of expected
+	Arrears           []ArrearsBucket     `json:"arrears"`
+	TotalArrears      float64             `json:"total_arrears"`
+	UnmatchedPayments int                 `json:"unmatched_payments"`
+	UnmatchedAmount   float64             `json:"unmatched_amount"`
+	CollectionTrend   []MonthlyCollection `json:"collection_trend"` // last 12 months, oldest first
+}
+
+// PropertyDashboard is one property's occupancy and rent for the period
+type PropertyDashboard struct {
+	PropertyID     uint    `json:"property_id"`
+	PropertyTitle  string  `json:"property_title"`
+	Units          int     `json:"units"`
+	OccupiedUnits  int     `json:"occupied_units"`
+	OccupancyRate  float64 `json:"occupancy_rate"`
+	ExpectedRent   float64 `json:"expected_rent"`
+	CollectedRent  float64 `json:"collected_rent"`
+	CollectionRate float64 `json:"collection_rate"`
+}
+
+// ArrearsBucket groups tenant balances by how long they have been owed.
+// MaxDays is nil for the open-ended last bucket.
+type ArrearsBucket struct {
+	Label   string  `json:"label"`
+	MinDays int     `json:"min_days"`
+	MaxDays *int    `json:"max_days"`
+	Tenants int     `json:"tenants"`
+	Amount  float64 `json:"amount"`
+}
+
+// MonthlyCollection is the rent collected in a month (YYYY-MM)
+type MonthlyCollection struct {
+	Month     string  `json:"month"`
+	Collected float64 `json:"collected"`
+	Payments  int     `json:"payments"`
+}
+
+// RentRollLine is a unit in the rent roll of a period. Vacant units have no
+// tenant. Balance is the tenant's balance at the end of the period.
+type RentRollLine struct {
+	PropertyID    uint    `json:"property_id"`
+	PropertyTitle string  `json:"property_title"`
+	UnitID        uint    `json:"unit_id"`
+	UnitName      string  `json:"unit_name"`
+	TenantID      *uint   `json:"tenant_id"`
+	TenantName    string  `json:"tenant_name"`
+	Phone         string  `json:"phone"`
+	Rent          float64 `json:"rent"` // the unit price when vacant
+	Billed        float64 `json:"billed"`
+	Paid          float64 `json:"paid"`
+	Balance       float64 `json:"balance"`
+}
+
+// RentRollTotals sums a rent roll
+type RentRollTotals struct {
+	Units         int     `json:"units"`
+	OccupiedUnits int     `json:"occupied_units"`
+	Rent          float64 `json:"rent"` // of occupied units
+	Billed        float64 `json:"billed"`
+	Paid          float64 `json:"paid"`
+	Balance       float64 `json:"balance"`
+}
+
+// ArrearsLine is a tenant owing rent at the end of a period
+type ArrearsLine struct {
+	PropertyID    uint       `json:"property_id"`
+	PropertyTitle string     `json:"property_title"`
+	UnitName      string     `json:"unit_name"`
+	TenantID      uint       `json:"tenant_id"`
+	TenantName    string     `json:"tenant_name"`
+	Phone         string     `json:"phone"`
+	Rent          float64    `json:"rent"`
+	PaidInPeriod  float64    `json:"paid_in_period"`
+	LastPaymentAt *time.Time `json:"last_payment_at"`
+	Balance       float64    `json:"balance"`
+	OwedSince     string     `json:"owed_since"`
+	DaysOverdue   int        `json:"days_overdue"`
+	Bucket        string     `json:"bucket"` // e.g. 31-60
+}
+
+// ArrearsTotals sums an arrears report by aging bucket
+type ArrearsTotals struct {
+	AsOf    string          `json:"as_of"`
+	Tenants int             `json:"tenants"`
+	Balance float64         `json:"balance"`
+	Buckets []ArrearsBucket `json:"buckets"`
+}
+
 // Payment represents a payment transaction (cash or M-Pesa)
 type Payment struct {
 	ID         uint      `json:"id"`
 	LandlordID uint      `json:"landlord_id"`
 	TenantID   *uint     `json:"tenant_id"` // Nullable for unassigned payments
 	Amount     float64   `json:"amount"`
-	Status     string    `json:"status"` // PENDING, COMPLETED, FAILED
-	Method     string    `json:"method"` // CASH, MPESA_TILL, MPESA_PAYBILL
+	Status     string    `json:"status"`   // PENDING, COMPLETED, FAILED
+	Method     string    `json:"method"`   // CASH, MPESA_TILL, MPESA_PAYBILL
+	Category   string    `json:"category"` // rent, deposit, service_charge, utilities, other
 	Receipt    string    `json:"receipt"`
+	ReceiptNo  string    `json:"receipt_no"`  // set when a tenant's payment completes
+	RecordedBy *uint     `json:"recorded_by"` // who recorded a cash payment or assigned it
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+// PaymentWithTenant is a payment with the name of the tenant it is assigned to
+type PaymentWithTenant struct {
+	Payment
+	TenantName string `json:"tenant_name"`
+}
+
+// TaxRate is a rate in force from EffectiveFrom until the next rate of the
+// same tax. It applies to landlords whose annual rent is within the bounds.
+type TaxRate struct {
+	ID              uint      `json:"id"`
+	Tax             string    `json:"tax"` // MRI
+	RatePercent     float64   `json:"rate_percent"`
+	MinAnnualIncome float64   `json:"min_annual_income"`
+	MaxAnnualIncome *float64  `json:"max_annual_income"`
+	EffectiveFrom   string    `json:"effective_from"` // YYYY-MM-DD
+	Notes           string    `json:"notes,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// RentalIncomeReturn is a landlord's Monthly Rental Income tax return: rent
+// received in the month, the tax on it and whether it has been filed. The
+// Filed figures are those on the filed return.
+type RentalIncomeReturn struct {
+	Period         string             `json:"period"` // YYYY-MM
+	GrossRent      float64            `json:"gross_rent"`
+	PaymentCount   int                `json:"payment_count"`
+	AnnualRent     float64            `json:"annual_rent"` // the 12 months to the period's end
+	RatePercent    float64            `json:"rate_percent"`
+	TaxDue         float64            `json:"tax_due"`
+	InScope        bool               `json:"in_scope"`
+	DueDate        string             `json:"due_date"`
+	Status         string             `json:"status"` // OPEN, DUE, OVERDUE, EXEMPT, FILED, PAID
+	PRN            string             `json:"prn,omitempty"`
+	FiledGrossRent *float64           `json:"filed_gross_rent,omitempty"`
+	FiledTaxDue    *float64           `json:"filed_tax_due,omitempty"`
+	Amended        bool               `json:"amended"` // rent received changed since filing
+	FiledAt        *time.Time         `json:"filed_at,omitempty"`
+	PaidAt         *time.Time         `json:"paid_at,omitempty"`
+	Lines          []RentalIncomeLine `json:"lines,omitempty"`
+}
+
+// RentalIncomeLine is the rent a tenant paid in a return's month
+type RentalIncomeLine struct {
+	TenantID      uint    `json:"tenant_id"`
+	TenantName    string  `json:"tenant_name"`
+	PropertyID    uint    `json:"property_id"`
+	PropertyTitle string  `json:"property_title"`
+	Location      string  `json:"location"`
+	UnitName      string  `json:"unit_name"`
+	Amount        float64 `json:"amount"`
+	PaymentCount  int     `json:"payment_count"`
+}
+
+// LandlordBranding is the letterhead on a landlord's receipts and
+// statements. The logo is uploaded separately.
+type LandlordBranding struct {
+	LandlordID    uint      `json:"landlord_id"`
+	BusinessName  string    `json:"business_name"`
+	Address       string    `json:"address"`
+	Phone         string    `json:"phone"`
+	Email         string    `json:"email"`
+	AccentColor   string    `json:"accent_color"`   // #RRGGBB
+	ReceiptPrefix string    `json:"receipt_prefix"` // receipt numbers read PREFIX-000001
+	HasLogo       bool      `json:"has_logo"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ReceiptVerification is what the public verification link reveals about a
+// receipt; the tenant's name is shortened
+type ReceiptVerification struct {
+	ReceiptNo     string    `json:"receipt_no"`
+	IssuedBy      string    `json:"issued_by"`
+	TenantName    string    `json:"tenant_name"`
+	PropertyTitle string    `json:"property_title"`
+	UnitName      string    `json:"unit_name"`
+	Amount        float64   `json:"amount"`
+	Method        string    `json:"method"`
+	PaidAt        time.Time `json:"paid_at"`
+	Status        string    `json:"status"`
+}
+
+// ImportJob is an upload of units and tenants to a property. A dry run only
+// validates; otherwise the rows are applied together or not at all.
+type ImportJob struct {
+	ID             uint             `json:"id"`
+	LandlordID     uint             `json:"landlord_id"`
+	PropertyID     uint             `json:"property_id"`
+	Filename       string           `json:"filename"`
+	DryRun         bool             `json:"dry_run"`
+	Status         string           `json:"status"` // VALIDATED, INVALID, COMPLETED, FAILED
+	Rows           int              `json:"rows"`
+	UnitsCreated   int              `json:"units_created"`
+	TenantsCreated int              `json:"tenants_created"`
+	ErrorCount     int              `json:"error_count"`
+	Errors         []ImportRowError `json:"errors,omitempty"`
+	CreatedBy      *uint            `json:"created_by"`
+	CreatedAt      time.Time        `json:"created_at"`
+	CompletedAt    *time.Time       `json:"completed_at"`
+}
+
+// ImportRowError is a problem with one cell or row of an import file. Row
+// is the spreadsheet row number, counting the header as row 1.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+// StatementUpload is an M-Pesa or bank statement uploaded to reconcile the
+// money received against recorded payments
+type StatementUpload struct {
+	ID          uint            `json:"id"`
+	LandlordID  uint            `json:"landlord_id"`
+	Filename    string          `json:"filename"`
+	Source      string          `json:"source"` // MPESA, BANK
+	TotalLines  int             `json:"total_lines"`
+	Matched     int             `json:"matched"`
+	Unmatched   int             `json:"unmatched"`
+	Duplicates  int             `json:"duplicates"`
+	TotalAmount float64         `json:"total_amount"`
+	Lines       []StatementLine `json:"lines,omitempty"`
+	UploadedBy  *uint           `json:"uploaded_by"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// StatementLine is money received according to a statement. Outcome is what
+// the upload did with it; the payment's current status and tenant show
+// whether an unmatched line has since been assigned.
+type StatementLine struct {
+	ID            uint      `json:"id"`
+	LineNo        int       `json:"line_no"`
+	Reference     string    `json:"reference"`
+	TransactionAt time.Time `json:"transaction_at"`
+	Amount        float64   `json:"amount"`
+	Phone         string    `json:"phone,omitempty"`
+	Payer         string    `json:"payer,omitempty"`
+	Details       string    `json:"details,omitempty"`
+	Outcome       string    `json:"outcome"` // MATCHED, UNMATCHED, DUPLICATE
+	PaymentID     *uint     `json:"payment_id"`
+	PaymentStatus string    `json:"payment_status,omitempty"`
+	TenantID      *uint     `json:"tenant_id"`
+	TenantName    string    `json:"tenant_name,omitempty"`
+}
+
+// AccountingEntry is a balanced journal entry for an accounting package.
+// Source and SourceKey name the record it came from: a rent charge on a due
+// date, a tenant fee, a payment or an expense.
+type AccountingEntry struct {
+	Source    string           `json:"source"` // rent, fee, payment, expense
+	SourceKey string           `json:"source_key"`
+	Number    string           `json:"number"`
+	Date      string           `json:"date"` // YYYY-MM-DD
+	Name      string           `json:"name"` // tenant or vendor
+	Property  string           `json:"property"`
+	Memo      string           `json:"memo"`
+	Lines     []AccountingLine `json:"lines"`
+}
+
+type AccountingLine struct {
+	Account string  `json:"account"`
+	Debit   float64 `json:"debit"`
+	Credit  float64 `json:"credit"`
+}
+
+// AccountingBatch is a recorded export; Entries are only loaded for
+// downloads
+type AccountingBatch struct {
+	ID          uint              `json:"id"`
+	LandlordID  uint              `json:"landlord_id"`
+	Format      string            `json:"format"`
+	From        *string           `json:"from"`
+	To          string            `json:"to"`
+	EntryCount  int               `json:"entry_count"`
+	TotalAmount float64           `json:"total_amount"`
+	Entries     []AccountingEntry `json:"entries,omitempty"`
+	CreatedBy   *uint             `json:"created_by"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// AccountingSettings maps what journal lines hold to a landlord's accounts
+type AccountingSettings struct {
+	LandlordID  uint              `json:"landlord_id"`
+	Accounts    map[string]string `json:"accounts"`
+	XeroTaxRate string            `json:"xero_tax_rate"`
+}
+
+// SearchHit is a tenant, unit, property or payment matching a search.
+// MatchedOn names the field that matched best; Rank orders hits, highest
+// first, an exact match ranking above a prefix, a prefix above a fragment.
+type SearchHit struct {
+	ID         int64      `json:"id"`
+	Title      string     `json:"title"`
+	Subtitle   string     `json:"subtitle,omitempty"`
+	PropertyID *uint      `json:"property_id,omitempty"`
+	UnitID     *uint      `json:"unit_id,omitempty"`
+	TenantID   *uint      `json:"tenant_id,omitempty"`
+	Amount     *float64   `json:"amount,omitempty"`
+	Date       *time.Time `json:"date,omitempty"`
+	MatchedOn  string     `json:"matched_on"`
+	Rank       float64    `json:"rank"`
+}
+
 // LandlordPaymentConfig stores M-Pesa credentials per landlord
 type LandlordPaymentConfig struct {
 	ID                uint      `json:"id" gorm:"primaryKey"`
@@ -113,3 +617,17 @@ type LandlordPaymentConfig struct {
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
 }
+
+// AuditEntry is a state-changing action to record in the audit log
+type AuditEntry struct {
+	ActorID    *int
+	ActorRole  string
+	RequestID  string
+	Action     string // e.g. "payment.assign"
+	EntityType string // e.g. "payment"
+	EntityID   string
+	LandlordID *int
+	Before     interface{}
+	After      interface{}
+	IPAddress  string
+}
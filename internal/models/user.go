@@ -1,21 +1,62 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"regexp"
 	"time"
 )
 
 // User represents our database user
 type User struct {
-	ID           int       `json:"id"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"` //"-" means this wont be included in JSON
-	FullName     string    `json:"full_name"`
-	Phone        string    `json:"phone"`
-	Role         string    `json:"role"`
-	CreatedAT    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID           int        `json:"id"`
+	Email        string     `json:"email"`
+	PasswordHash string     `json:"-"` //"-" means this wont be included in JSON
+	FullName     string     `json:"full_name"`
+	Phone        string     `json:"phone"`
+	Role         string     `json:"role"`
+	TokenVersion int        `json:"-"` // bumped to revoke issued JWTs
+	TOTPEnabled  bool       `json:"totp_enabled"`
+	LockedUntil  *time.Time `json:"locked_until"` // set while locked out after failed logins
+	CreatedAT    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// Stored as JSONB in users.notification_preferences
+	NotificationPreferences NotificationPreferences `json:"notification_preferences"`
+}
+
+// NotificationPreferences are the emails a user chooses to receive
+type NotificationPreferences struct {
+	EmailPaymentReceipts   bool `json:"email_payment_receipts"`   // copy of each receipt sent to tenants
+	EmailMonthlyStatements bool `json:"email_monthly_statements"` // collections summary on the 1st of the month
+}
+
+// DefaultNotificationPreferences apply to keys a user has never set
+var DefaultNotificationPreferences = NotificationPreferences{
+	EmailPaymentReceipts:   true,
+	EmailMonthlyStatements: true,
+}
+
+// Value stores the preferences as JSON
+func (p NotificationPreferences) Value() (driver.Value, error) {
+	b, err := json.Marshal(p)
+	return string(b), err
+}
+
+// Scan reads JSON preferences, keeping defaults for missing keys
+func (p *NotificationPreferences) Scan(src interface{}) error {
+	*p = DefaultNotificationPreferences
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	}
+	return fmt.Errorf("cannot scan %T into NotificationPreferences", src)
 }
 
 // UserLogin represents new login request
@@ -0,0 +1,251 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/config"
+)
+
+// NewEmailNotifier returns an SMTP notifier when SMTP_HOST is set, and the
+// unconfigured notifier otherwise
+func NewEmailNotifier(cfg *config.Config) Notifier {
+	if cfg.SMTP.Host == "" {
+		return NewUnconfiguredNotifier(cfg)
+	}
+	return NewSMTPNotifier(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.Username, cfg.SMTP.Password, cfg.SMTP.From)
+}
+
+// SMTPNotifier sends email through an SMTP server. STARTTLS is used when the
+// server offers it; authentication only when a username is configured, so a
+// local sink such as MailHog works without credentials.
+type SMTPNotifier struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string // "Name <address>" or a bare address
+	Timeout  time.Duration
+}
+
+// NewSMTPNotifier creates an SMTP notifier
+func NewSMTPNotifier(host, port, username, password, from string) *SMTPNotifier {
+	return &SMTPNotifier{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		From:     from,
+		Timeout:  30 * time.Second,
+	}
+}
+
+// Send delivers one email. Only ChannelEmail messages are accepted.
+func (n *SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	if msg.Channel != ChannelEmail {
+		return fmt.Errorf("smtp: unsupported channel %q", msg.Channel)
+	}
+	from, err := mail.ParseAddress(n.From)
+	if err != nil {
+		return fmt.Errorf("smtp: invalid from address: %w", err)
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("smtp: invalid recipient: %w", err)
+	}
+
+	body, err := BuildEmail(from, to, msg)
+	if err != nil {
+		return err
+	}
+
+	dialer := net.Dialer{Timeout: n.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(n.Host, n.Port))
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(n.Timeout))
+	}
+
+	c, err := smtp.NewClient(conn, n.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(tlsConfig(n.Host)); err != nil {
+			return fmt.Errorf("smtp: starttls: %w", err)
+		}
+	}
+	if n.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.Username, n.Password, n.Host)); err != nil {
+			return fmt.Errorf("smtp: auth: %w", err)
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	return c.Quit()
+}
+
+// BuildEmail renders a MIME message: text (and HTML alternative when set),
+// wrapped in multipart/mixed when there are attachments
+func BuildEmail(from, to *mail.Address, msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&buf, "%s: %s\r\n", k, v) }
+
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", randomID(), domainOf(from.Address)))
+	header("MIME-Version", "1.0")
+
+	if len(msg.Attachments) == 0 {
+		if err := writeAlternative(&buf, msg); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	header("Content-Type", "multipart/mixed; boundary="+mixed.Boundary())
+	buf.WriteString("\r\n")
+
+	var content bytes.Buffer
+	if err := writeAlternative(&content, msg); err != nil {
+		return nil, err
+	}
+	// The alternative part carries its own headers; copy them into the mixed part
+	partHeader, partBody := splitHeaders(content.Bytes())
+	part, err := mixed.CreatePart(partHeader)
+	if err != nil {
+		return nil, err
+	}
+	part.Write(partBody)
+
+	for _, a := range msg.Attachments {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", a.ContentType)
+		h.Set("Content-Transfer-Encoding", "base64")
+		h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+		part, err := mixed.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
+		writeBase64Lines(part, a.Data)
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeAlternative writes the content headers and the text body, or
+// multipart/alternative when an HTML version exists
+func writeAlternative(buf *bytes.Buffer, msg Message) error {
+	if msg.HTML == "" {
+		fmt.Fprintf(buf, "Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+		return writeQuotedPrintable(buf, msg.Body)
+	}
+
+	alt := multipart.NewWriter(buf)
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", alt.Boundary())
+	for _, p := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Body},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", p.contentType)
+		h.Set("Content-Transfer-Encoding", "quoted-printable")
+		part, err := alt.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write([]byte(p.body)); err != nil {
+			return err
+		}
+		qp.Close()
+	}
+	return alt.Close()
+}
+
+func writeQuotedPrintable(buf *bytes.Buffer, s string) error {
+	qp := quotedprintable.NewWriter(buf)
+	if _, err := qp.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// splitHeaders separates a "Header: v\r\n...\r\n\r\nbody" block
+func splitHeaders(b []byte) (textproto.MIMEHeader, []byte) {
+	h := textproto.MIMEHeader{}
+	head, body, _ := bytes.Cut(b, []byte("\r\n\r\n"))
+	for _, line := range strings.Split(string(head), "\r\n") {
+		if k, v, ok := strings.Cut(line, ": "); ok {
+			h.Set(k, v)
+		}
+	}
+	return h, body
+}
+
+// writeBase64Lines writes base64 wrapped at 76 characters per RFC 2045
+func writeBase64Lines(w interface{ Write([]byte) (int, error) }, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
+
+func tlsConfig(host string) *tls.Config {
+	return &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func domainOf(address string) string {
+	if i := strings.LastIndexByte(address, '@'); i >= 0 {
+		return address[i+1:]
+	}
+	return "localhost"
+}
@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestBuildEmail(t *testing.T) {
+	from := &mail.Address{Name: "Smart Rentals", Address: "no-reply@rentals.example"}
+	to := &mail.Address{Name: "Jane Wanjiru", Address: "jane@example.com"}
+	pdf := bytes.Repeat([]byte("%PDF-1.4 receipt "), 20)
+	raw, err := BuildEmail(from, to, Message{
+		Channel:     ChannelEmail,
+		Subject:     "Receipt RCT-000123 – KES 15,000",
+		Body:        "Dear Jane,\n\nWe have received your payment.",
+		HTML:        "<p>Dear Jane,</p>",
+		Attachments: []Attachment{{Filename: "receipt RCT-000123.pdf", ContentType: "application/pdf", Data: pdf}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Receipt RCT-000123 – KES 15,000" {
+		t.Errorf("subject = %q (%v)", subject, err)
+	}
+	if !strings.HasSuffix(msg.Header.Get("Message-ID"), "@rentals.example>") {
+		t.Errorf("Message-ID = %q", msg.Header.Get("Message-ID"))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("content type %q (%v)", mediaType, err)
+	}
+	mixed := multipart.NewReader(msg.Body, params["boundary"])
+
+	content, err := mixed.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, _ = mime.ParseMediaType(content.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("first part %q, want multipart/alternative", mediaType)
+	}
+	alt := multipart.NewReader(content, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", "Dear Jane,\r\n\r\nWe have received your payment."}, // quoted-printable text uses CRLF
+		{"text/html; charset=utf-8", "<p>Dear Jane,</p>"},
+	} {
+		part, err := alt.NextRawPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(quotedprintable.NewReader(part))
+		if part.Header.Get("Content-Type") != want.contentType || string(body) != want.body {
+			t.Errorf("part %q = %q, want %q %q", part.Header.Get("Content-Type"), body, want.contentType, want.body)
+		}
+	}
+
+	attachment, err := mixed.NextRawPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attachment.FileName() != "receipt RCT-000123.pdf" {
+		t.Errorf("attachment filename = %q", attachment.FileName())
+	}
+	encoded, _ := io.ReadAll(attachment)
+	for _, line := range strings.Split(strings.TrimRight(string(encoded), "\r\n"), "\r\n") {
+		if len(line) > 76 {
+			t.Errorf("base64 line of %d characters", len(line))
+		}
+	}
+	data, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(encoded)))
+	if err != nil || !bytes.Equal(data, pdf) {
+		t.Errorf("attachment does not round-trip (%v)", err)
+	}
+}
+
+func TestBuildEmailPlainText(t *testing.T) {
+	raw, err := BuildEmail(&mail.Address{Address: "no-reply@rentals.example"}, &mail.Address{Address: "jane@example.com"},
+		Message{Channel: ChannelEmail, Subject: "Statement", Body: "Your statement is ready."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := msg.Header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("content type %q", ct)
+	}
+	body, _ := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if string(body) != "Your statement is ready." {
+		t.Errorf("body %q", body)
+	}
+}
@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Zolet-hash/smart-rentals/internal/config"
+)
+
+// Delivery channels supported by notifiers
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// Message is a single outbound notification to one recipient
+type Message struct {
+	Channel string // ChannelEmail or ChannelSMS
+	To      string // email address or phone number
+	From    string // SMS sender ID; providers use their default when empty
+	Subject string // ignored for SMS
+	Body    string // plain text
+	HTML    string // optional HTML alternative, email only
+
+	Attachments []Attachment // email only
+}
+
+// Attachment is a file sent with an email
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Notifier delivers messages to users. Implementations must be safe for
+// concurrent use since handlers share a single instance.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// ErrNotConfigured is returned when a channel has no provider in production
+var ErrNotConfigured = errors.New("notify: no provider configured for channel")
+
+// LogNotifier writes message metadata to the application log instead of
+// sending it. Bodies are never logged since they can carry password reset
+// tokens. It is the default in development until a real provider is configured.
+type LogNotifier struct{}
+
+// NewLogNotifier creates a notifier that only logs messages
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+// Send logs the message without its body
+func (n *LogNotifier) Send(ctx context.Context, msg Message) error {
+	log.Printf("[notify:%s] to=%s subject=%q body_bytes=%d attachments=%d", msg.Channel, msg.To, msg.Subject, len(msg.Body), len(msg.Attachments))
+	return nil
+}
+
+// NewUnconfiguredNotifier returns the notifier used for a channel without a
+// provider: the LogNotifier in development, and one that refuses every message
+// in production so nothing is silently dropped into the logs
+func NewUnconfiguredNotifier(cfg *config.Config) Notifier {
+	if cfg.Environment == "production" {
+		return refuseNotifier{}
+	}
+	return NewLogNotifier()
+}
+
+type refuseNotifier struct{}
+
+func (refuseNotifier) Send(ctx context.Context, msg Message) error {
+	return fmt.Errorf("%w %q", ErrNotConfigured, msg.Channel)
+}
+
+// Mux routes each message to the notifier registered for its channel,
+// falling back to a default (usually the LogNotifier) for the others
+type Mux struct {
+	channels map[string]Notifier
+	fallback Notifier
+}
+
+// NewMux creates a router that sends unregistered channels to fallback
+func NewMux(fallback Notifier) *Mux {
+	return &Mux{channels: map[string]Notifier{}, fallback: fallback}
+}
+
+// Handle registers the notifier for a channel. Call before first use.
+func (m *Mux) Handle(channel string, n Notifier) {
+	m.channels[channel] = n
+}
+
+// Send delivers the message through its channel's notifier
+func (m *Mux) Send(ctx context.Context, msg Message) error {
+	if n, ok := m.channels[msg.Channel]; ok {
+		return n.Send(ctx, msg)
+	}
+	return m.fallback.Send(ctx, msg)
+}
@@ -0,0 +1,39 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/Zolet-hash/smart-rentals/internal/config"
+)
+
+func TestLogNotifierOmitsBody(t *testing.T) {
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&buf)
+
+	msg := Message{Channel: ChannelEmail, To: "jane@example.com", Subject: "Reset your password", Body: "token=secret-reset-token"}
+	if err := NewLogNotifier().Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "secret-reset-token") {
+		t.Errorf("log carries the body: %s", buf.String())
+	}
+}
+
+func TestUnconfiguredNotifier(t *testing.T) {
+	msg := Message{Channel: ChannelSMS, To: "254712345678", Body: "hello"}
+
+	cfg := &config.Config{Environment: "production"}
+	if err := NewUnconfiguredNotifier(cfg).Send(context.Background(), msg); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("production: got %v, want ErrNotConfigured", err)
+	}
+	cfg.Environment = "development"
+	if _, ok := NewUnconfiguredNotifier(cfg).(*LogNotifier); !ok {
+		t.Errorf("development: want the LogNotifier")
+	}
+}
@@ -0,0 +1,166 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/config"
+	"github.com/Zolet-hash/smart-rentals/internal/phone"
+)
+
+// SMS providers selectable with SMS_PROVIDER
+const (
+	ProviderLog            = "log"
+	ProviderFile           = "file"
+	ProviderAfricasTalking = "africastalking"
+)
+
+// NewSMSNotifier builds the SMS notifier configured for the environment.
+// Unknown or empty providers fall back to logging outside production.
+func NewSMSNotifier(cfg *config.Config) Notifier {
+	switch cfg.SMS.Provider {
+	case ProviderAfricasTalking:
+		return NewAfricasTalking(cfg.SMS.Username, cfg.SMS.APIKey, cfg.SMS.SenderID, cfg.SMS.Username == "sandbox")
+	case ProviderFile:
+		return NewFileNotifier(cfg.SMS.FilePath)
+	default:
+		return NewUnconfiguredNotifier(cfg)
+	}
+}
+
+// AfricasTalking sends SMS through the Africa's Talking bulk messaging API
+type AfricasTalking struct {
+	Username string
+	APIKey   string
+	SenderID string // default sender ID (alphanumeric or short code)
+	Endpoint string
+	Client   *http.Client
+}
+
+// NewAfricasTalking creates an Africa's Talking SMS notifier. The sandbox
+// account ("sandbox" username) uses the sandbox endpoint.
+func NewAfricasTalking(username, apiKey, senderID string, sandbox bool) *AfricasTalking {
+	endpoint := "https://api.africastalking.com/version1/messaging"
+	if sandbox {
+		endpoint = "https://api.sandbox.africastalking.com/version1/messaging"
+	}
+	return &AfricasTalking{
+		Username: username,
+		APIKey:   apiKey,
+		SenderID: senderID,
+		Endpoint: endpoint,
+		Client:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+type atResponse struct {
+	SMSMessageData struct {
+		Message    string `json:"Message"`
+		Recipients []struct {
+			Number     string `json:"number"`
+			Status     string `json:"status"`
+			StatusCode int    `json:"statusCode"`
+			MessageID  string `json:"messageId"`
+		} `json:"Recipients"`
+	} `json:"SMSMessageData"`
+}
+
+// Send posts a single SMS. Only ChannelSMS messages are accepted.
+func (n *AfricasTalking) Send(ctx context.Context, msg Message) error {
+	if msg.Channel != ChannelSMS {
+		return fmt.Errorf("africastalking: unsupported channel %q", msg.Channel)
+	}
+
+	form := url.Values{}
+	form.Set("username", n.Username)
+	form.Set("to", phone.International(msg.To))
+	form.Set("message", msg.Body)
+	if from := firstNonEmpty(msg.From, n.SenderID); from != "" {
+		form.Set("from", from)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.Endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("apiKey", n.APIKey)
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("africastalking: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("africastalking: HTTP %d", resp.StatusCode)
+	}
+
+	var out atResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return fmt.Errorf("africastalking: decode response: %w", err)
+	}
+	if len(out.SMSMessageData.Recipients) == 0 {
+		return fmt.Errorf("africastalking: %s", out.SMSMessageData.Message)
+	}
+	r := out.SMSMessageData.Recipients[0]
+	// 100 Processed, 101 Sent, 102 Queued; anything else is a rejection
+	if r.StatusCode < 100 || r.StatusCode > 102 {
+		return fmt.Errorf("africastalking: %s (%d)", r.Status, r.StatusCode)
+	}
+	return nil
+}
+
+// FileNotifier appends messages as JSON lines to a file. Useful in
+// development to inspect what would have been sent.
+type FileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileNotifier creates a notifier writing to path
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+// Send appends the message to the file
+func (n *FileNotifier) Send(ctx context.Context, msg Message) error {
+	if n.path == "" {
+		return errors.New("file notifier: no output path configured")
+	}
+	line, err := json.Marshal(struct {
+		Time time.Time `json:"time"`
+		Message
+	}{time.Now().UTC(), msg})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
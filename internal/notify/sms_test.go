@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAfricasTalkingSend(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		wantErr  bool
+	}{
+		{"sent", http.StatusCreated, `{"SMSMessageData":{"Message":"Sent to 1/1","Recipients":[{"number":"+254712345678","status":"Success","statusCode":101}]}}`, false},
+		{"rejected number", http.StatusCreated, `{"SMSMessageData":{"Message":"Sent to 0/1","Recipients":[{"number":"+254712345678","status":"InvalidPhoneNumber","statusCode":403}]}}`, true},
+		{"no recipients", http.StatusCreated, `{"SMSMessageData":{"Message":"InvalidSenderId","Recipients":[]}}`, true},
+		{"http error", http.StatusUnauthorized, `The supplied authentication is invalid`, true},
+		{"not json", http.StatusOK, `<html>`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var form map[string]string
+			var apiKey string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil {
+					t.Error(err)
+				}
+				form = map[string]string{"username": r.PostForm.Get("username"), "to": r.PostForm.Get("to"), "message": r.PostForm.Get("message"), "from": r.PostForm.Get("from")}
+				apiKey = r.Header.Get("apiKey")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.response))
+			}))
+			defer srv.Close()
+
+			n := NewAfricasTalking("rentals", "key-1", "RENTALS", false)
+			n.Endpoint = srv.URL
+			err := n.Send(context.Background(), Message{Channel: ChannelSMS, To: "0712 345 678", Body: "Rent received"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send: err = %v, want error %v", err, tt.wantErr)
+			}
+			want := map[string]string{"username": "rentals", "to": "+254712345678", "message": "Rent received", "from": "RENTALS"}
+			for k, v := range want {
+				if form[k] != v {
+					t.Errorf("%s = %q, want %q", k, form[k], v)
+				}
+			}
+			if apiKey != "key-1" {
+				t.Errorf("apiKey header = %q", apiKey)
+			}
+		})
+	}
+}
+
+func TestAfricasTalkingSenderOverride(t *testing.T) {
+	var from string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		from = r.FormValue("from")
+		w.Write([]byte(`{"SMSMessageData":{"Recipients":[{"statusCode":100}]}}`))
+	}))
+	defer srv.Close()
+
+	n := NewAfricasTalking("rentals", "key-1", "RENTALS", false)
+	n.Endpoint = srv.URL
+	if err := n.Send(context.Background(), Message{Channel: ChannelSMS, To: "0712345678", From: "KAMAU APTS", Body: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if from != "KAMAU APTS" {
+		t.Errorf("from = %q, want the landlord's sender ID", from)
+	}
+	if err := n.Send(context.Background(), Message{Channel: ChannelEmail, To: "jane@example.com", Body: "hi"}); err == nil {
+		t.Error("email accepted by the SMS notifier")
+	}
+}
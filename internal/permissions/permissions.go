@@ -0,0 +1,222 @@
+package permissions
+
+// Permission is a single capability checked by RequirePermission, e.g. "tenants:write"
+type Permission string
+
+const (
+	PropertiesRead      Permission = "properties:read"
+	PropertiesWrite     Permission = "properties:write" // create, update and delete properties
+	UnitsRead           Permission = "units:read"
+	UnitsWrite          Permission = "units:write"
+	TenantsRead         Permission = "tenants:read"
+	TenantsWrite        Permission = "tenants:write"
+	PaymentsRead        Permission = "payments:read"
+	PaymentsRecordCash  Permission = "payments:record_cash"
+	PaymentsAssign      Permission = "payments:assign" // match unassigned M-Pesa payments to tenants
+	PaymentsConfigure   Permission = "payments:configure"
+	DelegationsManage   Permission = "delegations:manage"
+	OrganizationsManage Permission = "organizations:manage" // create property management organizations
+	UsersManage         Permission = "users:manage"
+	AuditRead           Permission = "audit:read" // view the audit trail of one's own data
+	WebhooksManage      Permission = "webhooks:manage"
+	NotificationsManage Permission = "notifications:manage" // SMS sender ID, reminder settings and message log
+	MessagesRead        Permission = "messages:read"        // tenant conversations
+	MessagesWrite       Permission = "messages:write"       // reply, start conversations and broadcast
+	MaintenanceRead     Permission = "maintenance:read"
+	MaintenanceWrite    Permission = "maintenance:write" // report, assign and update repair tickets; manage vendors
+	ExpensesRead        Permission = "expenses:read"     // expenses and profit and loss reports
+	ExpensesWrite       Permission = "expenses:write"
+	TaxManage           Permission = "tax:manage" // Monthly Rental Income tax returns and KRA exports
+)
+
+// Roles a user account can have
+const (
+	RoleAdmin      = "admin"
+	RoleLandlord   = "landlord"
+	RoleCaretaker  = "caretaker"
+	RoleAgent      = "agent"
+	RoleAccountant = "accountant"
+	RoleTenant     = "tenant"
+)
+
+// rolePermissions is the ceiling of what each role may do. Staff roles
+// (caretaker, agent, accountant) only exercise these on properties a landlord
+// has delegated to them; see property_delegations.
+var rolePermissions = map[string][]Permission{
+	RoleAdmin: {
+		UsersManage,
+	},
+	RoleLandlord: {
+		PropertiesRead, PropertiesWrite,
+		UnitsRead, UnitsWrite,
+		TenantsRead, TenantsWrite,
+		PaymentsRead, PaymentsRecordCash, PaymentsAssign, PaymentsConfigure,
+		DelegationsManage, OrganizationsManage,
+		AuditRead,
+		WebhooksManage, NotificationsManage,
+		MessagesRead, MessagesWrite,
+		MaintenanceRead, MaintenanceWrite,
+		ExpensesRead, ExpensesWrite,
+		TaxManage,
+	},
+	RoleCaretaker: {
+		PropertiesRead,
+		UnitsRead, UnitsWrite,
+		TenantsRead, TenantsWrite,
+		PaymentsRead, PaymentsRecordCash,
+		MessagesRead, MessagesWrite,
+		MaintenanceRead, MaintenanceWrite,
+		ExpensesRead, ExpensesWrite,
+	},
+	RoleAgent: {
+		PropertiesRead,
+		UnitsRead,
+		TenantsRead, TenantsWrite,
+		PaymentsRead,
+		MessagesRead, MessagesWrite,
+		MaintenanceRead, MaintenanceWrite,
+	},
+	RoleAccountant: {
+		PropertiesRead,
+		UnitsRead,
+		TenantsRead,
+		PaymentsRead, PaymentsRecordCash, PaymentsAssign,
+		MaintenanceRead,
+		ExpensesRead, ExpensesWrite,
+	},
+	// Tenants only reach their own conversations and repair requests,
+	// through tenants.user_id
+	RoleTenant: {
+		MessagesRead, MessagesWrite,
+		MaintenanceRead, MaintenanceWrite,
+	},
+}
+
+// delegatable lists permissions a landlord may grant on a single property.
+// Account-wide permissions (property creation, M-Pesa config...) stay with the landlord.
+var delegatable = map[Permission]bool{
+	PropertiesRead:     true,
+	UnitsRead:          true,
+	UnitsWrite:         true,
+	TenantsRead:        true,
+	TenantsWrite:       true,
+	PaymentsRead:       true,
+	PaymentsRecordCash: true,
+	PaymentsAssign:     true,
+	MessagesRead:       true,
+	MessagesWrite:      true,
+	MaintenanceRead:    true,
+	MaintenanceWrite:   true,
+	ExpensesRead:       true,
+	ExpensesWrite:      true,
+}
+
+// Has reports whether the role grants the permission
+func Has(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// ForRole returns the permissions granted to a role
+func ForRole(role string) []Permission {
+	return append([]Permission(nil), rolePermissions[role]...)
+}
+
+// IsValidRole reports whether role is a known role
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// IsStaffRole reports whether the role acts on a landlord's behalf through delegations
+func IsStaffRole(role string) bool {
+	return role == RoleCaretaker || role == RoleAgent || role == RoleAccountant
+}
+
+// IsDelegatable reports whether a landlord can grant the permission per property
+func IsDelegatable(perm Permission) bool {
+	return delegatable[perm]
+}
+
+// Roles a member can have within an organization
+const (
+	OrgRoleAdmin   = "admin"   // manages members and all org properties
+	OrgRoleManager = "manager" // manages all org properties
+	OrgRoleStaff   = "staff"   // day to day work on org properties
+	OrgRoleOwner   = "owner"   // property owner using the org's management; sees own properties only
+)
+
+// orgRolePermissions are the permissions each org role grants on the
+// organization's properties. Like delegations, they are capped by the
+// member's account role.
+var orgRolePermissions = map[string][]Permission{
+	OrgRoleAdmin: {
+		PropertiesRead, PropertiesWrite,
+		UnitsRead, UnitsWrite,
+		TenantsRead, TenantsWrite,
+		PaymentsRead, PaymentsRecordCash, PaymentsAssign,
+		MessagesRead, MessagesWrite,
+		MaintenanceRead, MaintenanceWrite,
+		ExpensesRead, ExpensesWrite,
+	},
+	OrgRoleManager: {
+		PropertiesRead, PropertiesWrite,
+		UnitsRead, UnitsWrite,
+		TenantsRead, TenantsWrite,
+		PaymentsRead, PaymentsRecordCash, PaymentsAssign,
+		MessagesRead, MessagesWrite,
+		MaintenanceRead, MaintenanceWrite,
+		ExpensesRead, ExpensesWrite,
+	},
+	OrgRoleStaff: {
+		PropertiesRead,
+		UnitsRead, UnitsWrite,
+		TenantsRead, TenantsWrite,
+		PaymentsRead, PaymentsRecordCash,
+		MessagesRead, MessagesWrite,
+		MaintenanceRead, MaintenanceWrite,
+		ExpensesRead, ExpensesWrite,
+	},
+	OrgRoleOwner: {},
+}
+
+// IsValidOrgRole reports whether role is a known organization role
+func IsValidOrgRole(role string) bool {
+	_, ok := orgRolePermissions[role]
+	return ok
+}
+
+// ForOrgMember resolves the permissions an org member holds on the
+// organization's properties: the org role's set limited by the account role
+func ForOrgMember(orgRole, accountRole string) []Permission {
+	perms := []Permission{}
+	for _, p := range orgRolePermissions[orgRole] {
+		if Has(accountRole, p) {
+			perms = append(perms, p)
+		}
+	}
+	return perms
+}
+
+// RoleGrants returns every account role with the permissions it grants
+func RoleGrants() map[string][]Permission {
+	grants := make(map[string][]Permission, len(rolePermissions))
+	for role, perms := range rolePermissions {
+		grants[role] = append([]Permission(nil), perms...)
+	}
+	return grants
+}
+
+// OrgRoleGrants returns every organization role with the permissions it
+// grants before the account role cap
+func OrgRoleGrants() map[string][]Permission {
+	grants := make(map[string][]Permission, len(orgRolePermissions))
+	for role, perms := range orgRolePermissions {
+		grants[role] = append([]Permission(nil), perms...)
+	}
+	return grants
+}
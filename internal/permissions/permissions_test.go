@@ -0,0 +1,77 @@
+package permissions
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestStaffRolesStayWithinDelegations(t *testing.T) {
+	// Staff act only through delegations, so everything their role grants
+	// must be delegatable
+	for _, role := range []string{RoleCaretaker, RoleAgent, RoleAccountant} {
+		if !IsStaffRole(role) {
+			t.Errorf("%s is not a staff role", role)
+		}
+		for _, p := range ForRole(role) {
+			if !IsDelegatable(p) {
+				t.Errorf("%s grants %s, which cannot be delegated", role, p)
+			}
+		}
+	}
+	for _, p := range []Permission{PropertiesWrite, PaymentsConfigure, DelegationsManage, UsersManage} {
+		if IsDelegatable(p) {
+			t.Errorf("account-wide permission %s is delegatable", p)
+		}
+	}
+}
+
+func TestHas(t *testing.T) {
+	tests := []struct {
+		role string
+		perm Permission
+		want bool
+	}{
+		{RoleLandlord, PaymentsConfigure, true},
+		{RoleCaretaker, PaymentsRecordCash, true},
+		{RoleCaretaker, PaymentsAssign, false},
+		{RoleAgent, UnitsWrite, false},
+		{RoleTenant, TenantsRead, false},
+		{RoleAdmin, PropertiesRead, false},
+		{"owner", PropertiesRead, false},
+	}
+	for _, tt := range tests {
+		if got := Has(tt.role, tt.perm); got != tt.want {
+			t.Errorf("Has(%s, %s) = %v, want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+	if IsValidRole("superuser") || !IsValidRole(RoleAccountant) {
+		t.Error("IsValidRole does not follow the role table")
+	}
+}
+
+func TestForOrgMember(t *testing.T) {
+	// The org role's permissions are capped by the account role
+	perms := ForOrgMember(OrgRoleManager, RoleAgent)
+	if slices.Contains(perms, PropertiesWrite) || !slices.Contains(perms, TenantsWrite) {
+		t.Errorf("manager with an agent account: %v", perms)
+	}
+	if perms := ForOrgMember(OrgRoleOwner, RoleLandlord); len(perms) != 0 {
+		t.Errorf("owners get %v on the organization's properties, want none", perms)
+	}
+	if perms := ForOrgMember("unknown", RoleLandlord); len(perms) != 0 {
+		t.Errorf("unknown org role gets %v", perms)
+	}
+}
+
+func TestGrantsAreCopies(t *testing.T) {
+	// SyncPermissions writes these to the database; changing them must not
+	// change the checks made in code
+	RoleGrants()[RoleLandlord][0] = UsersManage
+	OrgRoleGrants()[OrgRoleAdmin][0] = UsersManage
+	if Has(RoleLandlord, UsersManage) || slices.Contains(ForOrgMember(OrgRoleAdmin, RoleAdmin), UsersManage) {
+		t.Error("changing returned grants changed the role tables")
+	}
+	if len(RoleGrants()) != len(rolePermissions) || len(OrgRoleGrants()) != len(orgRolePermissions) {
+		t.Error("grants leave out roles")
+	}
+}
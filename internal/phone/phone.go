@@ -0,0 +1,56 @@
+// Package phone reads Kenyan mobile numbers in the forms tenants, M-Pesa,
+// statements and spreadsheets write them in.
+package phone
+
+import "strings"
+
+// Normalize returns a Kenyan mobile number in the 2547XXXXXXXX (or
+// 2541XXXXXXXX) form M-Pesa reports payers in, so 0712 345 678,
+// +254712345678 and 254712345678 are the same number. Spreadsheets often
+// drop the leading zero, so nine digit numbers are accepted too. Masked
+// numbers such as 2547******78 are not numbers.
+func Normalize(number string) (string, bool) {
+	if strings.Contains(number, "*") {
+		return "", false
+	}
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, number)
+	switch {
+	case len(digits) == 12 && strings.HasPrefix(digits, "254"):
+	case strings.HasPrefix(strings.TrimSpace(number), "+"):
+		return "", false // another country's code
+	case len(digits) == 10 && strings.HasPrefix(digits, "0"):
+		digits = "254" + digits[1:]
+	case len(digits) == 9:
+		digits = "254" + digits
+	default:
+		return "", false
+	}
+	if digits[3] != '7' && digits[3] != '1' {
+		return "", false
+	}
+	return digits, true
+}
+
+// International returns number in +254 form for SMS gateways. Numbers that
+// are not Kenyan mobile numbers are returned without spaces or dashes.
+func International(number string) string {
+	if n, ok := Normalize(number); ok {
+		return "+" + n
+	}
+	return strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(number))
+}
+
+// PaymentNumber returns number as tenant payment numbers are stored:
+// normalized when it is a Kenyan mobile number, otherwise as given, since
+// callbacks may carry masked or hashed MSISDNs that can only match exactly.
+func PaymentNumber(number string) string {
+	if n, ok := Normalize(number); ok {
+		return n
+	}
+	return strings.TrimSpace(number)
+}
@@ -0,0 +1,47 @@
+package phone
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	valid := map[string]string{
+		"254712345678":     "254712345678",
+		"+254 712 345 678": "254712345678",
+		"0712-345-678":     "254712345678",
+		"0112345678":       "254112345678",
+		"712345678":        "254712345678",
+	}
+	for in, want := range valid {
+		if got, ok := Normalize(in); !ok || got != want {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "2547******78", "0712***678", "254212345678", "0212345678", "12345", "+1 415 555 0100"} {
+		if got, ok := Normalize(in); ok {
+			t.Errorf("Normalize(%q) = %q; want rejected", in, got)
+		}
+	}
+}
+
+func TestInternational(t *testing.T) {
+	for in, want := range map[string]string{
+		"0712 345 678":  "+254712345678",
+		"254712345678":  "+254712345678",
+		"+1 415-555-01": "+141555501",
+	} {
+		if got := International(in); got != want {
+			t.Errorf("International(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
+
+func TestPaymentNumber(t *testing.T) {
+	for in, want := range map[string]string{
+		"0712 345 678":   "254712345678",
+		" 2547******78 ": "2547******78",
+		"a1b2c3":         "a1b2c3",
+	} {
+		if got := PaymentNumber(in); got != want {
+			t.Errorf("PaymentNumber(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package utils
+
+import "testing"
+
+func TestGenerateToken(t *testing.T) {
+	a, err := GenerateToken(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateToken(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 64 || a == b {
+		t.Errorf("tokens %q and %q: want two different 64 character tokens", a, b)
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	// SHA-256 of "abc"
+	const want = "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if got := HashToken("abc"); got != want {
+		t.Errorf("HashToken(abc) = %s, want %s", got, want)
+	}
+}
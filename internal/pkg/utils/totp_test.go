@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 SHA-1 test key "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// RFC 6238 appendix B, last six digits
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		if got := totpCode(key, unix/totpPeriod); got != want {
+			t.Errorf("code at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := now.Unix() / totpPeriod
+	key, _ := totpEncoding.DecodeString(rfcSecret)
+
+	got, ok := ValidateTOTP(rfcSecret, "005 924", now, 0)
+	if !ok || got != step {
+		t.Fatalf("current code: got step %d, %v; want %d", got, ok, step)
+	}
+	// A used code cannot be replayed
+	if _, ok := ValidateTOTP(rfcSecret, "005924", now, step); ok {
+		t.Error("replayed code accepted")
+	}
+
+	// One step of clock drift either way, no more
+	for offset, want := range map[int64]bool{-2: false, -1: true, 1: true, 2: false} {
+		code := totpCode(key, step+offset)
+		if _, ok := ValidateTOTP(strings.ToLower(rfcSecret), code, now, 0); ok != want {
+			t.Errorf("code %d steps away: accepted = %v, want %v", offset, ok, want)
+		}
+	}
+	for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := ValidateTOTP(rfcSecret, code, now, 0); ok {
+			t.Errorf("code %q accepted", code)
+		}
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if len(c) != 11 || c[5] != '-' || strings.ContainsAny(c, "01ilo") {
+			t.Errorf("code %q is not xxxxx-xxxxx from the recovery alphabet", c)
+		}
+		if seen[c] {
+			t.Errorf("code %q repeated", c)
+		}
+		seen[c] = true
+		if NormalizeRecoveryCode(" "+strings.ToUpper(c)+" ") != strings.ReplaceAll(c, "-", "") {
+			t.Errorf("NormalizeRecoveryCode does not undo formatting of %q", c)
+		}
+	}
+}
@@ -0,0 +1,866 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/listing"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+)
+
+// Memory is a Store that keeps records in maps, for tests. It follows the
+// database's rules: access through ownership, delegations and organization
+// membership, vacant new units, receipt numbers for completed tenant
+// payments and the foreign keys that make deletes fail. Transactions run one
+// at a time and roll back by restoring the records as they were; writes
+// made outside them are not isolated from them.
+type Memory struct {
+	mu   sync.Mutex
+	txMu sync.Mutex
+	data memData
+}
+
+type memData struct {
+	lastID      int
+	properties  map[int]models.Property
+	units       map[int]models.Unit
+	tenants     map[int]models.Tenant
+	payments    map[int]models.Payment
+	users       map[int]models.User
+	delegations map[[2]int][]permissions.Permission // by property, grantee
+	members     map[[2]int]string                   // org role by organization, user
+	receipts    map[uint]int                        // last receipt number per landlord
+}
+
+func NewMemory() *Memory {
+	return &Memory{data: memData{
+		properties:  map[int]models.Property{},
+		units:       map[int]models.Unit{},
+		tenants:     map[int]models.Tenant{},
+		payments:    map[int]models.Payment{},
+		users:       map[int]models.User{},
+		delegations: map[[2]int][]permissions.Permission{},
+		members:     map[[2]int]string{},
+		receipts:    map[uint]int{},
+	}}
+}
+
+// AddUser stores a user, giving it an ID unless it has one
+func (m *Memory) AddUser(u models.User) models.User {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u.ID == 0 {
+		u.ID = m.data.nextID()
+	} else if u.ID > m.data.lastID {
+		m.data.lastID = u.ID
+	}
+	u.CreatedAT, u.UpdatedAt = time.Now(), time.Now()
+	m.data.users[u.ID] = u
+	return u
+}
+
+// Delegate lets a grantee act on a property with perms
+func (m *Memory) Delegate(propertyID, granteeID int, perms ...permissions.Permission) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data.delegations[[2]int{propertyID, granteeID}] = perms
+}
+
+// AddMember makes a user a member of an organization, as accepting an
+// invitation does
+func (m *Memory) AddMember(orgID, userID int, role string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data.members[[2]int{orgID, userID}] = role
+}
+
+func (m *Memory) Properties() PropertyRepo { return memProperties{m} }
+func (m *Memory) Units() UnitRepo          { return memUnits{m} }
+func (m *Memory) Tenants() TenantRepo      { return memTenants{m} }
+func (m *Memory) Payments() PaymentRepo    { return memPayments{m} }
+func (m *Memory) Users() UserRepo          { return memUsers{m} }
+
+func (m *Memory) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+	m.mu.Lock()
+	saved := m.data.clone()
+	m.mu.Unlock()
+	if err := fn(memTx{m}); err != nil {
+		m.mu.Lock()
+		m.data = saved
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// memTx is the Store within a transaction; WithTx joins it
+type memTx struct{ *Memory }
+
+func (tx memTx) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	return fn(tx)
+}
+
+// lock locks the records for one operation and returns them
+func (m *Memory) lock() (*memData, func()) {
+	m.mu.Lock()
+	return &m.data, m.mu.Unlock
+}
+
+func (d *memData) nextID() int {
+	d.lastID++
+	return d.lastID
+}
+
+func (d memData) clone() memData {
+	d.properties = cloneMap(d.properties)
+	d.units = cloneMap(d.units)
+	d.tenants = cloneMap(d.tenants)
+	d.payments = cloneMap(d.payments)
+	d.users = cloneMap(d.users)
+	d.delegations = cloneMap(d.delegations)
+	d.members = cloneMap(d.members)
+	d.receipts = cloneMap(d.receipts)
+	return d
+}
+
+func cloneMap[K comparable, V any](src map[K]V) map[K]V {
+	dst := make(map[K]V, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// accessible is accessible_property_ids: owned properties, delegated ones
+// and those managed by an organization both the user and the owner are
+// members of
+func (d *memData) accessible(userID int, perm permissions.Permission, propertyID int) bool {
+	p, ok := d.properties[propertyID]
+	if !ok {
+		return false
+	}
+	if int(p.LandlordID) == userID || slices.Contains(d.delegations[[2]int{propertyID, userID}], perm) {
+		return true
+	}
+	if p.OrganizationID == nil {
+		return false
+	}
+	orgID := int(*p.OrganizationID)
+	if _, ok := d.members[[2]int{orgID, int(p.LandlordID)}]; !ok {
+		return false
+	}
+	return slices.Contains(d.memberPermissions(orgID, userID), perm)
+}
+
+// memberPermissions are the user's org role permissions capped by the
+// account role, or nil if the user is not a member
+func (d *memData) memberPermissions(orgID, userID int) []permissions.Permission {
+	role, ok := d.members[[2]int{orgID, userID}]
+	if !ok {
+		return nil
+	}
+	return permissions.ForOrgMember(role, d.users[userID].Role)
+}
+
+// accessibleLandlord is accessible_landlord_ids: the user, and the owners of
+// properties the user may act on
+func (d *memData) accessibleLandlord(userID int, perm permissions.Permission, landlordID uint) bool {
+	if int(landlordID) == userID {
+		return true
+	}
+	for id, p := range d.properties {
+		if p.LandlordID == landlordID && d.accessible(userID, perm, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func memSnapshot(v interface{}, landlordID uint) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// Tag the snapshot with its landlord like the database snapshots
+	var row map[string]interface{}
+	if err := json.Unmarshal(b, &row); err != nil {
+		return nil, err
+	}
+	row["landlord_id"] = landlordID
+	return json.Marshal(row)
+}
+
+// memPage sorts items by the page's sort and returns those after the
+// cursor, a page of them
+func memPage[T any](items []T, page listing.Page, key func(T) (interface{}, int64)) (Page[T], error) {
+	var out Page[T]
+	order := func(a, b T) int {
+		av, aid := key(a)
+		bv, bid := key(b)
+		c := compareKeys(av, aid, bv, bid)
+		if page.Desc {
+			return -c
+		}
+		return c
+	}
+	slices.SortFunc(items, order)
+	if page.WithTotal {
+		n := len(items)
+		out.Total = &n
+	}
+	if value, id, ok := page.After(); ok {
+		kept := items[:0]
+		for _, item := range items {
+			v, iid := key(item)
+			c := compareKeys(v, iid, value, id)
+			if page.Desc {
+				c = -c
+			}
+			if c > 0 {
+				kept = append(kept, item)
+			}
+		}
+		items = kept
+	}
+	if len(items) > page.Limit+1 {
+		items = items[:page.Limit+1]
+	}
+	if items == nil {
+		items = []T{}
+	}
+	out.Items, out.NextCursor = listing.Next(page, items, key)
+	return out, nil
+}
+
+// compareKeys orders (value, id) sort keys. Cursor values arrive as
+// decoded JSON: timestamps as strings and numbers as float64.
+func compareKeys(a interface{}, aid int64, b interface{}, bid int64) int {
+	var c int
+	switch x := a.(type) {
+	case time.Time:
+		y, ok := b.(time.Time)
+		if !ok {
+			s, _ := b.(string)
+			y, _ = time.Parse(time.RFC3339Nano, s)
+		}
+		c = x.Compare(y)
+	case string:
+		y, _ := b.(string)
+		c = strings.Compare(x, y)
+	default:
+		c = cmp.Compare(number(a), number(b))
+	}
+	if c != 0 {
+		return c
+	}
+	return cmp.Compare(aid, bid)
+}
+
+func number(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+// eat is East Africa Time, where list date filters start and end their days
+var eat = time.FixedZone("EAT", 3*60*60)
+
+func memInDates(f listing.Filter, t time.Time) bool {
+	day := func(d time.Time) time.Time { return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, eat) }
+	if !f.From.IsZero() && t.Before(day(f.From)) {
+		return false
+	}
+	return f.To.IsZero() || t.Before(day(f.To))
+}
+
+func memInAmounts(f listing.Filter, v float64) bool {
+	return (f.MinAmount == nil || v >= *f.MinAmount) && (f.MaxAmount == nil || v <= *f.MaxAmount)
+}
+
+// memMatches is listing's search: any field contains the text, ignoring case
+func memMatches(f listing.Filter, fields ...string) bool {
+	if f.Search == "" {
+		return true
+	}
+	text := strings.ToLower(f.Search)
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), text) {
+			return true
+		}
+	}
+	return false
+}
+
+type memProperties struct{ m *Memory }
+
+func (r memProperties) Get(ctx context.Context, userID int, perm permissions.Permission, id int) (models.Property, error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	if !d.accessible(userID, perm, id) {
+		return models.Property{}, ErrNotFound
+	}
+	return d.properties[id], nil
+}
+
+func (r memProperties) List(ctx context.Context, userID int, perm permissions.Permission, page listing.Page, f listing.Filter) (Page[models.Property], error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	var items []models.Property
+	for id, p := range d.properties {
+		if d.accessible(userID, perm, id) && memInAmounts(f, float64(p.TotalRent)) && memMatches(f, p.Title, p.Location) {
+			items = append(items, p)
+		}
+	}
+	return memPage(items, page, propertyKey(page.Sort))
+}
+
+func (r memProperties) Create(ctx context.Context, p *models.Property) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	p.ID = uint(d.nextID())
+	p.CreatedAt, p.UpdatedAt = time.Now(), time.Now()
+	d.properties[int(p.ID)] = *p
+	return nil
+}
+
+func (r memProperties) Update(ctx context.Context, id int, u PropertyUpdate) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	p, ok := d.properties[id]
+	if !ok {
+		return ErrNotFound
+	}
+	setIf(&p.Title, u.Title)
+	setIf(&p.Description, u.Description)
+	setIf(&p.Location, u.Location)
+	setIf(&p.PropertyType, u.PropertyType)
+	setIf(&p.Vacancy, u.Vacancy)
+	setIf(&p.TotalRent, u.TotalRent)
+	p.UpdatedAt = time.Now()
+	d.properties[id] = p
+	return nil
+}
+
+func (r memProperties) Delete(ctx context.Context, id int) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	if _, ok := d.properties[id]; !ok {
+		return ErrNotFound
+	}
+	for unitID, u := range d.units {
+		if int(u.PropertyID) == id && d.unitHasTenants(unitID) {
+			return ErrInUse
+		}
+	}
+	for unitID, u := range d.units {
+		if int(u.PropertyID) == id {
+			delete(d.units, unitID)
+		}
+	}
+	delete(d.properties, id)
+	return nil
+}
+
+func (r memProperties) Snapshot(ctx context.Context, id int) ([]byte, error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	p, ok := d.properties[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return memSnapshot(p, p.LandlordID)
+}
+
+type memUnits struct{ m *Memory }
+
+func (d *memData) unitHasTenants(unitID int) bool {
+	for _, t := range d.tenants {
+		if int(t.UnitID) == unitID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r memUnits) Get(ctx context.Context, userID int, perm permissions.Permission, propertyID, id int) (models.Unit, error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	u, ok := d.units[id]
+	if !ok || int(u.PropertyID) != propertyID || !d.accessible(userID, perm, propertyID) {
+		return models.Unit{}, ErrNotFound
+	}
+	return u, nil
+}
+
+func (r memUnits) LandlordID(ctx context.Context, userID int, perm permissions.Permission, id int) (int, error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	u, ok := d.units[id]
+	if !ok || !d.accessible(userID, perm, int(u.PropertyID)) {
+		return 0, ErrNotFound
+	}
+	return int(d.properties[int(u.PropertyID)].LandlordID), nil
+}
+
+func (r memUnits) List(ctx context.Context, propertyID int, page listing.Page, f listing.Filter) (Page[models.Unit], error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	var items []models.Unit
+	for _, u := range d.units {
+		if int(u.PropertyID) == propertyID && memInAmounts(f, u.UnitPrice) && memMatches(f, u.UnitName) {
+			items = append(items, u)
+		}
+	}
+	return memPage(items, page, unitKey(page.Sort))
+}
+
+func (r memUnits) Create(ctx context.Context, u *models.Unit) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	if _, ok := d.properties[int(u.PropertyID)]; !ok {
+		return ErrNotFound
+	}
+	u.ID = uint(d.nextID())
+	u.Vacancy = true
+	u.CreatedAt, u.UpdatedAt = time.Now(), time.Now()
+	d.units[int(u.ID)] = *u
+	return nil
+}
+
+func (r memUnits) Update(ctx context.Context, id int, upd UnitUpdate) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	u, ok := d.units[id]
+	if !ok {
+		return ErrNotFound
+	}
+	setIf(&u.UnitName, upd.UnitName)
+	setIf(&u.UnitType, upd.UnitType)
+	setIf(&u.UnitPrice, upd.UnitPrice)
+	u.UpdatedAt = time.Now()
+	d.units[id] = u
+	return nil
+}
+
+func (r memUnits) Delete(ctx context.Context, id int) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	if _, ok := d.units[id]; !ok {
+		return ErrNotFound
+	}
+	if d.unitHasTenants(id) {
+		return ErrInUse
+	}
+	delete(d.units, id)
+	return nil
+}
+
+func (r memUnits) SetVacancy(ctx context.Context, id int, vacant bool) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	u, ok := d.units[id]
+	if !ok {
+		return ErrNotFound
+	}
+	u.Vacancy = vacant
+	d.units[id] = u
+	return nil
+}
+
+func (r memUnits) Snapshot(ctx context.Context, id int) ([]byte, error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	u, ok := d.units[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return memSnapshot(u, d.properties[int(u.PropertyID)].LandlordID)
+}
+
+type memTenants struct{ m *Memory }
+
+// withUnit joins a tenant to its unit and property
+func (d *memData) withUnit(t models.Tenant) models.TenantWithUnit {
+	u := d.units[int(t.UnitID)]
+	return models.TenantWithUnit{
+		Tenant:        t,
+		UnitName:      u.UnitName,
+		PropertyID:    u.PropertyID,
+		PropertyTitle: d.properties[int(u.PropertyID)].Title,
+	}
+}
+
+func (r memTenants) Get(ctx context.Context, userID int, perm permissions.Permission, id int) (models.TenantWithUnit, error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	t, ok := d.tenants[id]
+	if !ok {
+		return models.TenantWithUnit{}, ErrNotFound
+	}
+	tu := d.withUnit(t)
+	if !d.accessible(userID, perm, int(tu.PropertyID)) {
+		return models.TenantWithUnit{}, ErrNotFound
+	}
+	return tu, nil
+}
+
+func (r memTenants) List(ctx context.Context, userID int, perm permissions.Permission, unitID int, page listing.Page, f listing.Filter) (Page[models.TenantWithUnit], error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	var items []models.TenantWithUnit
+	for _, t := range d.tenants {
+		tu := d.withUnit(t)
+		if !d.accessible(userID, perm, int(tu.PropertyID)) ||
+			(unitID != 0 && int(t.UnitID) != unitID) ||
+			(f.PropertyID != 0 && int(tu.PropertyID) != f.PropertyID) ||
+			!memInAmounts(f, t.Balance) ||
+			!memMatches(f, t.TenantName, t.PaymentNo1, t.PaymentNo2, tu.UnitName) {
+			continue
+		}
+		items = append(items, tu)
+	}
+	return memPage(items, page, tenantKey(page.Sort))
+}
+
+func (r memTenants) Create(ctx context.Context, t *models.Tenant) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	if _, ok := d.units[int(t.UnitID)]; !ok {
+		return ErrNotFound
+	}
+	t.ID = uint(d.nextID())
+	t.CreatedAt, t.UpdatedAt = time.Now(), time.Now()
+	d.tenants[int(t.ID)] = *t
+	return nil
+}
+
+func (r memTenants) Update(ctx context.Context, id int, u TenantUpdate) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	t, ok := d.tenants[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if u.UserID != nil {
+		t.UserID = nil
+		if *u.UserID != 0 {
+			for otherID, other := range d.tenants {
+				if otherID != id && other.UserID != nil && int(*other.UserID) == *u.UserID {
+					return ErrConflict
+				}
+			}
+			linked := uint(*u.UserID)
+			t.UserID = &linked
+		}
+	}
+	setIf(&t.TenantName, u.TenantName)
+	setIf(&t.PaymentNo1, u.PaymentNo1)
+	setIf(&t.PaymentNo2, u.PaymentNo2)
+	setIf(&t.RentDueDay, u.RentDueDay)
+	setIf(&t.SMSOptOut, u.SMSOptOut)
+	setIf(&t.Email, u.Email)
+	setIf(&t.EmailOptOut, u.EmailOptOut)
+	t.UpdatedAt = time.Now()
+	d.tenants[id] = t
+	return nil
+}
+
+func (r memTenants) Delete(ctx context.Context, id int) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	if _, ok := d.tenants[id]; !ok {
+		return ErrNotFound
+	}
+	delete(d.tenants, id)
+	// Payments stay, unassigned
+	for pid, p := range d.payments {
+		if p.TenantID != nil && int(*p.TenantID) == id {
+			p.TenantID = nil
+			d.payments[pid] = p
+		}
+	}
+	return nil
+}
+
+func (r memTenants) AdjustBalance(ctx context.Context, id int, delta float64) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	t, ok := d.tenants[id]
+	if !ok {
+		return ErrNotFound
+	}
+	t.Balance += delta
+	d.tenants[id] = t
+	return nil
+}
+
+func (r memTenants) Snapshot(ctx context.Context, id int) ([]byte, error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	t, ok := d.tenants[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return memSnapshot(t, t.LandlordID)
+}
+
+type memPayments struct{ m *Memory }
+
+// visible is the scope of payment lists: payments of tenants on accessible
+// properties, and unassigned payments of their landlords
+func (d *memData) visible(userID int, perm permissions.Permission, p models.Payment) bool {
+	if p.TenantID == nil {
+		return d.accessibleLandlord(userID, perm, p.LandlordID)
+	}
+	t, ok := d.tenants[int(*p.TenantID)]
+	return ok && d.accessible(userID, perm, int(d.units[int(t.UnitID)].PropertyID))
+}
+
+func (r memPayments) Get(ctx context.Context, userID int, perm permissions.Permission, id int) (models.Payment, error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	p, ok := d.payments[id]
+	if !ok || !d.visible(userID, perm, p) {
+		return models.Payment{}, ErrNotFound
+	}
+	return p, nil
+}
+
+func (r memPayments) GetForUpdate(ctx context.Context, landlordID, id int) (models.Payment, error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	p, ok := d.payments[id]
+	if !ok || int(p.LandlordID) != landlordID {
+		return models.Payment{}, ErrNotFound
+	}
+	return p, nil
+}
+
+func (r memPayments) List(ctx context.Context, userID int, perm permissions.Permission, page listing.Page, f listing.Filter) (Page[models.PaymentWithTenant], error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	var items []models.PaymentWithTenant
+	for _, p := range d.payments {
+		if !d.visible(userID, perm, p) {
+			continue
+		}
+		var tenant models.TenantWithUnit
+		if p.TenantID != nil {
+			tenant = d.withUnit(d.tenants[int(*p.TenantID)])
+		}
+		if (f.Status != "" && p.Status != f.Status) ||
+			(f.Method != "" && p.Method != f.Method) ||
+			(f.PropertyID != 0 && int(tenant.PropertyID) != f.PropertyID) ||
+			!memInDates(f, p.CreatedAt) || !memInAmounts(f, p.Amount) ||
+			!memMatches(f, tenant.TenantName, p.Receipt, p.ReceiptNo) {
+			continue
+		}
+		items = append(items, models.PaymentWithTenant{Payment: p, TenantName: tenant.TenantName})
+	}
+	key := paymentKey(page.Sort)
+	return memPage(items, page, func(p models.PaymentWithTenant) (interface{}, int64) { return key(p.Payment) })
+}
+
+func (r memPayments) ListByTenant(ctx context.Context, tenantID int, page listing.Page, f listing.Filter) (Page[models.Payment], error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	var items []models.Payment
+	for _, p := range d.payments {
+		if p.TenantID == nil || int(*p.TenantID) != tenantID ||
+			(f.Status != "" && p.Status != f.Status) ||
+			(f.Method != "" && p.Method != f.Method) ||
+			!memInDates(f, p.CreatedAt) || !memInAmounts(f, p.Amount) {
+			continue
+		}
+		items = append(items, p)
+	}
+	return memPage(items, page, paymentKey(page.Sort))
+}
+
+func (r memPayments) Create(ctx context.Context, p *models.Payment) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	p.ID = uint(d.nextID())
+	p.CreatedAt, p.UpdatedAt = time.Now(), time.Now()
+	d.number(p)
+	d.payments[int(p.ID)] = *p
+	return nil
+}
+
+// number gives a completed tenant payment the landlord's next receipt number
+func (d *memData) number(p *models.Payment) {
+	if p.ReceiptNo != "" || p.Status != "COMPLETED" || p.TenantID == nil {
+		return
+	}
+	d.receipts[p.LandlordID]++
+	p.ReceiptNo = fmt.Sprintf("RCT-%06d", d.receipts[p.LandlordID])
+}
+
+func (r memPayments) Assign(ctx context.Context, id, tenantID, recordedBy int) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	p, ok := d.payments[id]
+	if !ok {
+		return ErrNotFound
+	}
+	tid, by := uint(tenantID), uint(recordedBy)
+	p.TenantID, p.RecordedBy, p.Status = &tid, &by, "COMPLETED"
+	p.UpdatedAt = time.Now()
+	d.number(&p)
+	d.payments[id] = p
+	return nil
+}
+
+func (r memPayments) SetCategory(ctx context.Context, id int, category string) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	p, ok := d.payments[id]
+	if !ok {
+		return ErrNotFound
+	}
+	p.Category = category
+	p.UpdatedAt = time.Now()
+	d.payments[id] = p
+	return nil
+}
+
+func (r memPayments) Snapshot(ctx context.Context, id int) ([]byte, error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	p, ok := d.payments[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return memSnapshot(p, p.LandlordID)
+}
+
+type memUsers struct{ m *Memory }
+
+func (r memUsers) Get(ctx context.Context, id int) (models.User, error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	u, ok := d.users[id]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	return u, nil
+}
+
+func (r memUsers) List(ctx context.Context, role string, page listing.Page, f listing.Filter) (Page[models.User], error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	var items []models.User
+	for _, u := range d.users {
+		if (role == "" || u.Role == role) && memMatches(f, u.Email, u.FullName, u.Phone) {
+			items = append(items, u)
+		}
+	}
+	return memPage(items, page, userKey(page.Sort))
+}
+
+func (r memUsers) Update(ctx context.Context, u models.User) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	current, ok := d.users[u.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	for id, other := range d.users {
+		if id != u.ID && other.Email == u.Email {
+			return ErrConflict
+		}
+	}
+	current.Email, current.FullName, current.Phone, current.Role = u.Email, u.FullName, u.Phone, u.Role
+	current.UpdatedAt = time.Now()
+	d.users[u.ID] = current
+	return nil
+}
+
+// Delete removes a user with the properties and payments they own, like
+// the database's cascades
+func (r memUsers) Delete(ctx context.Context, id int) error {
+	d, unlock := r.m.lock()
+	defer unlock()
+	if _, ok := d.users[id]; !ok {
+		return ErrNotFound
+	}
+	for _, t := range d.tenants {
+		if int(t.LandlordID) == id {
+			return ErrInUse
+		}
+	}
+	for propertyID, p := range d.properties {
+		if int(p.LandlordID) == id {
+			for unitID, u := range d.units {
+				if u.PropertyID == p.ID {
+					delete(d.units, unitID)
+				}
+			}
+			delete(d.properties, propertyID)
+		}
+	}
+	for paymentID, p := range d.payments {
+		if int(p.LandlordID) == id {
+			delete(d.payments, paymentID)
+		}
+	}
+	for k := range d.delegations {
+		if k[1] == id {
+			delete(d.delegations, k)
+		}
+	}
+	for k := range d.members {
+		if k[1] == id {
+			delete(d.members, k)
+		}
+	}
+	delete(d.users, id)
+	return nil
+}
+
+func (r memUsers) HasRole(ctx context.Context, id int, role string) (bool, error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	u, ok := d.users[id]
+	return ok && u.Role == role, nil
+}
+
+func (r memUsers) OrgMembership(ctx context.Context, orgID, userID int) (string, []permissions.Permission, error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	role, ok := d.members[[2]int{orgID, userID}]
+	if !ok {
+		return "", nil, ErrNotFound
+	}
+	return role, d.memberPermissions(orgID, userID), nil
+}
+
+func (r memUsers) Snapshot(ctx context.Context, id int) ([]byte, error) {
+	d, unlock := r.m.lock()
+	defer unlock()
+	u, ok := d.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return json.Marshal(u)
+}
+
+// setIf sets *dst to *v when v is set
+func setIf[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Zolet-hash/smart-rentals/internal/listing"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+)
+
+type pgPayments struct{ q dbtx }
+
+const (
+	paymentColumns = `p.id, p.landlord_id, p.tenant_id, p.amount, p.status, p.method, p.category,
+		COALESCE(p.receipt, ''), COALESCE(p.receipt_no, ''), p.recorded_by, p.created_at, p.updated_at`
+	paymentFrom = `FROM payments p
+		LEFT JOIN tenants t ON p.tenant_id = t.id
+		LEFT JOIN units u ON t.unit_id = u.id`
+)
+
+func scanPayment(row rowScanner, extra ...interface{}) (models.Payment, error) {
+	var p models.Payment
+	var tenantID, recordedBy sql.NullInt64
+	dest := append([]interface{}{&p.ID, &p.LandlordID, &tenantID, &p.Amount, &p.Status, &p.Method, &p.Category,
+		&p.Receipt, &p.ReceiptNo, &recordedBy, &p.CreatedAt, &p.UpdatedAt}, extra...)
+	err := row.Scan(dest...)
+	p.TenantID, p.RecordedBy = nullUint(tenantID), nullUint(recordedBy)
+	return p, err
+}
+
+func scanPaymentWithTenant(row rowScanner) (models.PaymentWithTenant, error) {
+	var p models.PaymentWithTenant
+	var err error
+	p.Payment, err = scanPayment(row, &p.TenantName)
+	return p, err
+}
+
+// paymentKey is a payment's sort value and id for a PaymentList or
+// TenantPaymentList sort
+func paymentKey(sort string) func(models.Payment) (interface{}, int64) {
+	return func(p models.Payment) (interface{}, int64) {
+		if sort == "amount" {
+			return p.Amount, int64(p.ID)
+		}
+		return p.CreatedAt, int64(p.ID)
+	}
+}
+
+// paymentScope is the condition that a payment is of a tenant on a property
+// the user may act on with perm, or an unassigned payment of the landlord of
+// such a property
+func paymentScope(q *listing.Query, userID int, perm permissions.Permission) string {
+	user, p := q.Arg(userID), q.Arg(string(perm))
+	return "u.property_id IN (SELECT accessible_property_ids(" + user + ", " + p + "))" +
+		" OR (p.tenant_id IS NULL AND p.landlord_id IN (SELECT accessible_landlord_ids(" + user + ", " + p + ")))"
+}
+
+func (r pgPayments) Get(ctx context.Context, userID int, perm permissions.Permission, id int) (models.Payment, error) {
+	var q listing.Query
+	q.Where("p.id = " + q.Arg(id))
+	q.Where(paymentScope(&q, userID, perm))
+	p, err := scanPayment(r.q.QueryRowContext(ctx, "SELECT "+paymentColumns+" "+paymentFrom+q.WhereClause(), q.Args...))
+	return p, storeError(err)
+}
+
+func (r pgPayments) GetForUpdate(ctx context.Context, landlordID, id int) (models.Payment, error) {
+	p, err := scanPayment(r.q.QueryRowContext(ctx,
+		"SELECT "+paymentColumns+" FROM payments p WHERE p.id = $1 AND p.landlord_id = $2 FOR UPDATE",
+		id, landlordID))
+	return p, storeError(err)
+}
+
+func (r pgPayments) List(ctx context.Context, userID int, perm permissions.Permission, page listing.Page, f listing.Filter) (Page[models.PaymentWithTenant], error) {
+	var q listing.Query
+	q.Where(paymentScope(&q, userID, perm))
+	if f.Status != "" {
+		q.Where("p.status = " + q.Arg(f.Status))
+	}
+	if f.Method != "" {
+		q.Where("p.method = " + q.Arg(f.Method))
+	}
+	if f.PropertyID != 0 {
+		q.Where("u.property_id = " + q.Arg(f.PropertyID))
+	}
+	q.DateRange("p.created_at", f)
+	q.AmountRange("p.amount", f)
+	q.Search(f, "t.tenant_name", "p.receipt", "p.receipt_no")
+
+	key := paymentKey(page.Sort)
+	return list(ctx, r.q, page, q, paymentColumns+", COALESCE(t.tenant_name, '')", paymentFrom, scanPaymentWithTenant,
+		func(p models.PaymentWithTenant) (interface{}, int64) { return key(p.Payment) })
+}
+
+func (r pgPayments) ListByTenant(ctx context.Context, tenantID int, page listing.Page, f listing.Filter) (Page[models.Payment], error) {
+	var q listing.Query
+	q.Where("tenant_id = " + q.Arg(tenantID))
+	if f.Status != "" {
+		q.Where("status = " + q.Arg(f.Status))
+	}
+	if f.Method != "" {
+		q.Where("method = " + q.Arg(f.Method))
+	}
+	q.DateRange("created_at", f)
+	q.AmountRange("amount", f)
+	scan := func(row rowScanner) (models.Payment, error) { return scanPayment(row) }
+	return list(ctx, r.q, page, q, paymentColumns, "FROM payments p", scan, paymentKey(page.Sort))
+}
+
+// Create stores a payment; completed payments of a tenant get their receipt
+// number from the database
+func (r pgPayments) Create(ctx context.Context, p *models.Payment) error {
+	return r.q.QueryRowContext(ctx, `
+		INSERT INTO payments (landlord_id, tenant_id, amount, status, method, receipt, recorded_by, category)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8)
+		RETURNING id, COALESCE(receipt_no, ''), created_at, updated_at`,
+		p.LandlordID, p.TenantID, p.Amount, p.Status, p.Method, p.Receipt, p.RecordedBy, p.Category,
+	).Scan(&p.ID, &p.ReceiptNo, &p.CreatedAt, &p.UpdatedAt)
+}
+
+func (r pgPayments) Assign(ctx context.Context, id, tenantID, recordedBy int) error {
+	return execOne(ctx, r.q,
+		"UPDATE payments SET tenant_id = $1, status = 'COMPLETED', recorded_by = $2, updated_at = NOW() WHERE id = $3",
+		tenantID, recordedBy, id)
+}
+
+func (r pgPayments) SetCategory(ctx context.Context, id int, category string) error {
+	return execOne(ctx, r.q, "UPDATE payments SET category = $1, updated_at = NOW() WHERE id = $2", category, id)
+}
+
+func (r pgPayments) Snapshot(ctx context.Context, id int) ([]byte, error) {
+	return snapshot(ctx, r.q, `SELECT row_to_json(p) FROM payments p WHERE id = $1`, id)
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/listing"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/lib/pq"
+)
+
+// dbtx is what repositories run statements on: the pool, or a transaction
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Postgres is the Store backed by the application database
+type Postgres struct {
+	db *database.Database
+	q  dbtx
+}
+
+func NewPostgres(db *database.Database) *Postgres {
+	return &Postgres{db: db, q: db}
+}
+
+func (s *Postgres) Properties() PropertyRepo { return pgProperties{s.q} }
+func (s *Postgres) Units() UnitRepo          { return pgUnits{s.q} }
+func (s *Postgres) Tenants() TenantRepo      { return pgTenants{s.q} }
+func (s *Postgres) Payments() PaymentRepo    { return pgPayments{s.q} }
+func (s *Postgres) Users() UserRepo          { return pgUsers{s.q} }
+
+// WithTx runs fn in a transaction. Called within one, fn joins it.
+func (s *Postgres) WithTx(ctx context.Context, fn func(tx Store) error) error {
+	if _, ok := s.q.(*sql.Tx); ok {
+		return fn(s)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(&Postgres{db: s.db, q: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InTx is the store on a transaction the caller began, so its writes commit
+// or roll back with the caller's own statements
+func (s *Postgres) InTx(tx *sql.Tx) *Postgres {
+	return &Postgres{db: s.db, q: tx}
+}
+
+// SyncPermissions replaces the role_permissions and org_role_permissions
+// tables with the grants in the permissions package, which SQL access checks
+// read. Replicas starting together take turns through an advisory lock.
+func (s *Postgres) SyncPermissions(ctx context.Context) error {
+	return s.WithTx(ctx, func(tx Store) error {
+		q := tx.(*Postgres).q
+		if _, err := q.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('sync_permissions'))"); err != nil {
+			return err
+		}
+		tables := []struct {
+			table, column string
+			grants        map[string][]permissions.Permission
+		}{
+			{"role_permissions", "role", permissions.RoleGrants()},
+			{"org_role_permissions", "org_role", permissions.OrgRoleGrants()},
+		}
+		for _, t := range tables {
+			var roles, perms []string
+			for role, granted := range t.grants {
+				for _, p := range granted {
+					roles = append(roles, role)
+					perms = append(perms, string(p))
+				}
+			}
+			if _, err := q.ExecContext(ctx, "DELETE FROM "+t.table); err != nil {
+				return err
+			}
+			_, err := q.ExecContext(ctx,
+				"INSERT INTO "+t.table+" ("+t.column+", permission) SELECT * FROM unnest($1::TEXT[], $2::TEXT[])",
+				pq.Array(roles), pq.Array(perms),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// storeError maps database errors to the package's errors
+func storeError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505": // unique_violation
+			return ErrConflict
+		case "23503": // foreign_key_violation
+			return ErrInUse
+		}
+	}
+	return err
+}
+
+// execOne runs a statement that must affect one row
+func execOne(ctx context.Context, q dbtx, query string, args ...interface{}) error {
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return storeError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// snapshot runs an audit snapshot query selecting one row_to_json value
+func snapshot(ctx context.Context, q dbtx, query string, id int) ([]byte, error) {
+	var b []byte
+	err := q.QueryRowContext(ctx, query, id).Scan(&b)
+	return b, storeError(err)
+}
+
+// updateSet builds the SET list of an update from the fields that are set
+type updateSet struct {
+	sets []string
+	args []interface{}
+}
+
+// set assigns col the value; expr, if given, wraps the placeholder, e.g.
+// "NULLIF(%s, ”)"
+func (u *updateSet) set(col string, v interface{}, expr ...string) {
+	u.args = append(u.args, v)
+	placeholder := "$" + strconv.Itoa(len(u.args))
+	if len(expr) > 0 {
+		placeholder = strings.Replace(expr[0], "%s", placeholder, 1)
+	}
+	u.sets = append(u.sets, col+" = "+placeholder)
+}
+
+// exec updates row id of table, stamping updated_at
+func (u *updateSet) exec(ctx context.Context, q dbtx, table string, id int) error {
+	args := append(u.args, id)
+	query := "UPDATE " + table + " SET " + strings.Join(append([]string{"updated_at = NOW()"}, u.sets...), ", ") +
+		" WHERE id = $" + strconv.Itoa(len(args))
+	return execOne(ctx, q, query, args...)
+}
+
+// list runs a list query: the page of rows after the cursor, and their
+// count when the page asks for it. columns is the SELECT list and from the
+// FROM clause with joins.
+func list[T any](ctx context.Context, q dbtx, page listing.Page, lq listing.Query, columns, from string,
+	scan func(rowScanner) (T, error), key func(T) (interface{}, int64)) (Page[T], error) {
+	var out Page[T]
+	if page.WithTotal {
+		var n int
+		if err := q.QueryRowContext(ctx, lq.Count(from), lq.Args...).Scan(&n); err != nil {
+			return out, err
+		}
+		out.Total = &n
+	}
+
+	query, args := lq.Select("SELECT "+columns+" "+from, page)
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return out, cursorError(err)
+	}
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return out, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return out, cursorError(err)
+	}
+	out.Items, out.NextCursor = listing.Next(page, items, key)
+	return out, nil
+}
+
+// cursorError reports a cursor whose value does not fit the sort column,
+// which fails in the database as invalid data
+func cursorError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Class() == "22" {
+		return listing.ErrInvalidCursor
+	}
+	return err
+}
+
+func nullUint(v sql.NullInt64) *uint {
+	if !v.Valid {
+		return nil
+	}
+	u := uint(v.Int64)
+	return &u
+}
+
+// accessible is the condition that col, a property id, is on a property the
+// user may act on with the permission
+func accessible(q *listing.Query, col string, userID int, perm permissions.Permission) string {
+	return col + " IN (SELECT accessible_property_ids(" + q.Arg(userID) + ", " + q.Arg(string(perm)) + "))"
+}
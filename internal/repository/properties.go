@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Zolet-hash/smart-rentals/internal/listing"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+)
+
+type pgProperties struct{ q dbtx }
+
+const propertyColumns = "id, landlord_id, organization_id, title, COALESCE(description, ''), location, COALESCE(property_type, ''), vacancy, total_rent, created_at, updated_at"
+
+func scanProperty(row rowScanner) (models.Property, error) {
+	var p models.Property
+	var orgID sql.NullInt64
+	err := row.Scan(&p.ID, &p.LandlordID, &orgID, &p.Title, &p.Description, &p.Location, &p.PropertyType, &p.Vacancy, &p.TotalRent, &p.CreatedAt, &p.UpdatedAt)
+	p.OrganizationID = nullUint(orgID)
+	return p, err
+}
+
+// propertyKey is a property's sort value and id for a PropertyList sort
+func propertyKey(sort string) func(models.Property) (interface{}, int64) {
+	return func(p models.Property) (interface{}, int64) {
+		switch sort {
+		case "title":
+			return p.Title, int64(p.ID)
+		case "total_rent":
+			return p.TotalRent, int64(p.ID)
+		}
+		return p.CreatedAt, int64(p.ID)
+	}
+}
+
+func (r pgProperties) Get(ctx context.Context, userID int, perm permissions.Permission, id int) (models.Property, error) {
+	p, err := scanProperty(r.q.QueryRowContext(ctx,
+		"SELECT "+propertyColumns+" FROM properties WHERE id = $1 AND id IN (SELECT accessible_property_ids($2, $3))",
+		id, userID, string(perm)))
+	return p, storeError(err)
+}
+
+func (r pgProperties) List(ctx context.Context, userID int, perm permissions.Permission, page listing.Page, f listing.Filter) (Page[models.Property], error) {
+	var q listing.Query
+	q.Where(accessible(&q, "id", userID, perm))
+	q.AmountRange("total_rent", f)
+	q.Search(f, "title", "location")
+	return list(ctx, r.q, page, q, propertyColumns, "FROM properties", scanProperty, propertyKey(page.Sort))
+}
+
+func (r pgProperties) Create(ctx context.Context, p *models.Property) error {
+	return r.q.QueryRowContext(ctx, `
+		INSERT INTO properties (landlord_id, organization_id, title, description, location, property_type, vacancy, total_rent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`,
+		p.LandlordID, p.OrganizationID, p.Title, p.Description, p.Location, p.PropertyType, p.Vacancy, p.TotalRent,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+}
+
+func (r pgProperties) Update(ctx context.Context, id int, u PropertyUpdate) error {
+	var set updateSet
+	if u.Title != nil {
+		set.set("title", *u.Title)
+	}
+	if u.Description != nil {
+		set.set("description", *u.Description)
+	}
+	if u.Location != nil {
+		set.set("location", *u.Location)
+	}
+	if u.PropertyType != nil {
+		set.set("property_type", *u.PropertyType)
+	}
+	if u.Vacancy != nil {
+		set.set("vacancy", *u.Vacancy)
+	}
+	if u.TotalRent != nil {
+		set.set("total_rent", *u.TotalRent)
+	}
+	return set.exec(ctx, r.q, "properties", id)
+}
+
+// Delete removes a property with its units; it is ErrInUse while a unit has
+// tenants
+func (r pgProperties) Delete(ctx context.Context, id int) error {
+	return execOne(ctx, r.q, "DELETE FROM properties WHERE id = $1", id)
+}
+
+func (r pgProperties) Snapshot(ctx context.Context, id int) ([]byte, error) {
+	return snapshot(ctx, r.q, `SELECT row_to_json(p) FROM properties p WHERE id = $1`, id)
+}
@@ -0,0 +1,212 @@
+// Package repository loads and stores properties, units, tenants, payments
+// and users. Lookups take the caller and the permission they act with, so
+// ownership and delegation checks live here instead of in every handler; a
+// record the caller may not reach is ErrNotFound, as if it did not exist.
+//
+// Postgres is the real store; Memory keeps everything in maps so handlers
+// can be exercised without a database.
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Zolet-hash/smart-rentals/internal/listing"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("conflicts with an existing record") // e.g. an account already linked to another tenant
+	ErrInUse    = errors.New("still referenced by other records") // e.g. a unit that has tenants
+)
+
+// Store hands out the repositories. Those of a Store passed to WithTx's fn
+// share its transaction.
+type Store interface {
+	Properties() PropertyRepo
+	Units() UnitRepo
+	Tenants() TenantRepo
+	Payments() PaymentRepo
+	Users() UserRepo
+
+	// WithTx runs fn in a transaction, committed when fn returns nil and
+	// rolled back otherwise
+	WithTx(ctx context.Context, fn func(tx Store) error) error
+}
+
+// Snapshotter loads a record as JSON for audit before/after data. Snapshots
+// carry the landlord_id the record belongs to and never carry secrets.
+type Snapshotter interface {
+	Snapshot(ctx context.Context, id int) ([]byte, error)
+}
+
+// Page is one page of a list
+type Page[T any] struct {
+	Items      []T
+	NextCursor *string // nil on the last page
+	Total      *int    // set when the page asked for it
+}
+
+type PropertyRepo interface {
+	Snapshotter
+	Get(ctx context.Context, userID int, perm permissions.Permission, id int) (models.Property, error)
+	// List lists the properties the user may act on with perm. Filters: q
+	// (title, location) and amounts (total rent).
+	List(ctx context.Context, userID int, perm permissions.Permission, page listing.Page, f listing.Filter) (Page[models.Property], error)
+	// Create stores p and fills in its ID and timestamps
+	Create(ctx context.Context, p *models.Property) error
+	Update(ctx context.Context, id int, u PropertyUpdate) error
+	Delete(ctx context.Context, id int) error
+}
+
+type UnitRepo interface {
+	Snapshotter
+	// Get loads a unit of propertyID the user may act on with perm
+	Get(ctx context.Context, userID int, perm permissions.Permission, propertyID, id int) (models.Unit, error)
+	// LandlordID is the owner of the property of a unit the user may act on
+	// with perm
+	LandlordID(ctx context.Context, userID int, perm permissions.Permission, id int) (int, error)
+	// List lists a property's units. Filters: q (unit name) and amounts
+	// (price). Callers check access to the property.
+	List(ctx context.Context, propertyID int, page listing.Page, f listing.Filter) (Page[models.Unit], error)
+	// Create stores u, vacant, and fills in its ID and timestamps
+	Create(ctx context.Context, u *models.Unit) error
+	Update(ctx context.Context, id int, u UnitUpdate) error
+	Delete(ctx context.Context, id int) error
+	SetVacancy(ctx context.Context, id int, vacant bool) error
+}
+
+type TenantRepo interface {
+	Snapshotter
+	Get(ctx context.Context, userID int, perm permissions.Permission, id int) (models.TenantWithUnit, error)
+	// List lists the tenants the user may act on with perm, of one unit
+	// unless unitID is 0. Filters: property, amounts (balance) and q (name,
+	// phone numbers, unit name).
+	List(ctx context.Context, userID int, perm permissions.Permission, unitID int, page listing.Page, f listing.Filter) (Page[models.TenantWithUnit], error)
+	// Create stores t and fills in its ID and timestamps
+	Create(ctx context.Context, t *models.Tenant) error
+	// Update returns ErrConflict when the account is linked to another tenant
+	Update(ctx context.Context, id int, u TenantUpdate) error
+	Delete(ctx context.Context, id int) error
+	// AdjustBalance adds delta, negative for payments, to the balance
+	AdjustBalance(ctx context.Context, id int, delta float64) error
+}
+
+type PaymentRepo interface {
+	Snapshotter
+	// Get loads a payment of a tenant on a property the user may act on with
+	// perm, or an unassigned payment of the landlord of such a property
+	Get(ctx context.Context, userID int, perm permissions.Permission, id int) (models.Payment, error)
+	// GetForUpdate loads and locks a payment of a landlord until the
+	// transaction ends
+	GetForUpdate(ctx context.Context, landlordID, id int) (models.Payment, error)
+	// List lists the payments Get would load. Filters: status, method,
+	// property, dates, amounts and q (tenant name, reference, receipt number).
+	List(ctx context.Context, userID int, perm permissions.Permission, page listing.Page, f listing.Filter) (Page[models.PaymentWithTenant], error)
+	// ListByTenant lists a tenant's payments. Filters: status, method, dates
+	// and amounts. Callers check access to the tenant.
+	ListByTenant(ctx context.Context, tenantID int, page listing.Page, f listing.Filter) (Page[models.Payment], error)
+	// Create stores p and fills in its ID, receipt number and timestamps
+	Create(ctx context.Context, p *models.Payment) error
+	// Assign gives a payment to a tenant as completed, recorded by the user
+	Assign(ctx context.Context, id, tenantID, recordedBy int) error
+	SetCategory(ctx context.Context, id int, category string) error
+}
+
+type UserRepo interface {
+	Snapshotter
+	Get(ctx context.Context, id int) (models.User, error)
+	// List lists users, of one role unless role is empty. Filters: q (email,
+	// name, phone).
+	List(ctx context.Context, role string, page listing.Page, f listing.Filter) (Page[models.User], error)
+	// Update saves a user's email, name, phone and role
+	Update(ctx context.Context, u models.User) error
+	Delete(ctx context.Context, id int) error
+	HasRole(ctx context.Context, id int, role string) (bool, error)
+	// OrgMembership is a member's role and permissions in an organization,
+	// or ErrNotFound if the user is not a member (invitations that have not
+	// been accepted included). Permissions follow the current account role.
+	OrgMembership(ctx context.Context, orgID, userID int) (role string, perms []permissions.Permission, err error)
+}
+
+// Updates change only the fields that are set
+type (
+	PropertyUpdate struct {
+		Title        *string
+		Description  *string
+		Location     *string
+		PropertyType *string
+		Vacancy      *bool
+		TotalRent    *int
+	}
+
+	UnitUpdate struct {
+		UnitName  *string
+		UnitType  *string
+		UnitPrice *float64
+	}
+
+	TenantUpdate struct {
+		TenantName  *string
+		PaymentNo1  *string
+		PaymentNo2  *string
+		RentDueDay  *int
+		SMSOptOut   *bool
+		Email       *string // empty clears it
+		EmailOptOut *bool
+		UserID      *int // 0 unlinks the account
+	}
+)
+
+// List sorts, named as the JSON fields of the listed models. Pages are
+// parsed against these so cursors fit the repositories' queries.
+var (
+	// Properties by title, total rent or when they were added, newest first
+	PropertyList = listing.Spec{
+		Sorts:       map[string]string{"created_at": "created_at", "title": "title", "total_rent": "total_rent"},
+		DefaultSort: "-created_at",
+		ID:          "id",
+	}
+
+	// Units by name or price, by name
+	UnitList = listing.Spec{
+		Sorts:       map[string]string{"unit_name": "unit_name", "unit_price": "unit_price"},
+		DefaultSort: "unit_name",
+		ID:          "id",
+	}
+
+	// Tenants by name, balance, rent or when they moved in, newest first
+	TenantList = listing.Spec{
+		Sorts: map[string]string{
+			"created_at":  "t.created_at",
+			"tenant_name": "t.tenant_name",
+			"balance":     "COALESCE(t.balance, 0)",
+			"rent":        "COALESCE(t.rent, 0)",
+		},
+		DefaultSort: "-created_at",
+		ID:          "t.id",
+	}
+
+	// Payments by date or amount, newest first
+	PaymentList = listing.Spec{
+		Sorts:       map[string]string{"created_at": "p.created_at", "amount": "p.amount"},
+		DefaultSort: "-created_at",
+		ID:          "p.id",
+	}
+
+	// A tenant's payments by date or amount, newest first
+	TenantPaymentList = listing.Spec{
+		Sorts:       map[string]string{"date": "created_at", "amount": "amount"},
+		DefaultSort: "-date",
+		ID:          "id",
+	}
+
+	// Users by when they signed up, email or name, newest first
+	UserList = listing.Spec{
+		Sorts:       map[string]string{"created_at": "created_at", "email": "email", "full_name": "full_name"},
+		DefaultSort: "-created_at",
+		ID:          "id",
+	}
+)
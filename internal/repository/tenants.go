@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Zolet-hash/smart-rentals/internal/listing"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+)
+
+type pgTenants struct{ q dbtx }
+
+const (
+	tenantColumns = `t.id, t.tenant_name, COALESCE(t.payment_no1, ''), COALESCE(t.payment_no2, ''), COALESCE(t.rent, 0), COALESCE(t.balance, 0),
+		t.rent_due_day, t.sms_opt_out, COALESCE(t.email, ''), t.email_opt_out, t.unit_id, t.landlord_id, t.user_id, t.created_at, t.updated_at,
+		u.unit_name, p.id, p.title`
+	tenantFrom = `FROM tenants t
+		JOIN units u ON t.unit_id = u.id
+		JOIN properties p ON u.property_id = p.id`
+)
+
+func scanTenant(row rowScanner) (models.TenantWithUnit, error) {
+	var t models.TenantWithUnit
+	var userID sql.NullInt64
+	err := row.Scan(&t.ID, &t.TenantName, &t.PaymentNo1, &t.PaymentNo2, &t.Rent, &t.Balance,
+		&t.RentDueDay, &t.SMSOptOut, &t.Email, &t.EmailOptOut, &t.UnitID, &t.LandlordID, &userID, &t.CreatedAt, &t.UpdatedAt,
+		&t.UnitName, &t.PropertyID, &t.PropertyTitle)
+	t.UserID = nullUint(userID)
+	return t, err
+}
+
+// tenantKey is a tenant's sort value and id for a TenantList sort
+func tenantKey(sort string) func(models.TenantWithUnit) (interface{}, int64) {
+	return func(t models.TenantWithUnit) (interface{}, int64) {
+		switch sort {
+		case "tenant_name":
+			return t.TenantName, int64(t.ID)
+		case "balance":
+			return t.Balance, int64(t.ID)
+		case "rent":
+			return t.Rent, int64(t.ID)
+		}
+		return t.CreatedAt, int64(t.ID)
+	}
+}
+
+func (r pgTenants) Get(ctx context.Context, userID int, perm permissions.Permission, id int) (models.TenantWithUnit, error) {
+	t, err := scanTenant(r.q.QueryRowContext(ctx,
+		"SELECT "+tenantColumns+" "+tenantFrom+" WHERE t.id = $1 AND p.id IN (SELECT accessible_property_ids($2, $3))",
+		id, userID, string(perm)))
+	return t, storeError(err)
+}
+
+func (r pgTenants) List(ctx context.Context, userID int, perm permissions.Permission, unitID int, page listing.Page, f listing.Filter) (Page[models.TenantWithUnit], error) {
+	var q listing.Query
+	q.Where(accessible(&q, "p.id", userID, perm))
+	if unitID != 0 {
+		q.Where("t.unit_id = " + q.Arg(unitID))
+	}
+	if f.PropertyID != 0 {
+		q.Where("p.id = " + q.Arg(f.PropertyID))
+	}
+	q.AmountRange("COALESCE(t.balance, 0)", f)
+	q.Search(f, "t.tenant_name", "t.payment_no1", "t.payment_no2", "u.unit_name")
+	return list(ctx, r.q, page, q, tenantColumns, tenantFrom, scanTenant, tenantKey(page.Sort))
+}
+
+func (r pgTenants) Create(ctx context.Context, t *models.Tenant) error {
+	return r.q.QueryRowContext(ctx, `
+		INSERT INTO tenants (unit_id, landlord_id, tenant_name, payment_no1, payment_no2, rent, balance, rent_due_day, email)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7, $8, NULLIF($9, ''))
+		RETURNING id, created_at, updated_at`,
+		t.UnitID, t.LandlordID, t.TenantName, t.PaymentNo1, t.PaymentNo2, t.Rent, t.Balance, t.RentDueDay, t.Email,
+	).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+}
+
+func (r pgTenants) Update(ctx context.Context, id int, u TenantUpdate) error {
+	var set updateSet
+	if u.TenantName != nil {
+		set.set("tenant_name", *u.TenantName)
+	}
+	if u.PaymentNo1 != nil {
+		set.set("payment_no1", *u.PaymentNo1, "NULLIF(%s, '')")
+	}
+	if u.PaymentNo2 != nil {
+		set.set("payment_no2", *u.PaymentNo2, "NULLIF(%s, '')")
+	}
+	if u.RentDueDay != nil {
+		set.set("rent_due_day", *u.RentDueDay)
+	}
+	if u.SMSOptOut != nil {
+		set.set("sms_opt_out", *u.SMSOptOut)
+	}
+	if u.Email != nil {
+		set.set("email", *u.Email, "NULLIF(%s, '')")
+	}
+	if u.EmailOptOut != nil {
+		set.set("email_opt_out", *u.EmailOptOut)
+	}
+	if u.UserID != nil {
+		set.set("user_id", *u.UserID, "NULLIF(%s, 0)")
+	}
+	return set.exec(ctx, r.q, "tenants", id)
+}
+
+func (r pgTenants) Delete(ctx context.Context, id int) error {
+	return execOne(ctx, r.q, "DELETE FROM tenants WHERE id = $1", id)
+}
+
+func (r pgTenants) AdjustBalance(ctx context.Context, id int, delta float64) error {
+	return execOne(ctx, r.q, "UPDATE tenants SET balance = balance + $1 WHERE id = $2", delta, id)
+}
+
+func (r pgTenants) Snapshot(ctx context.Context, id int) ([]byte, error) {
+	return snapshot(ctx, r.q, `SELECT row_to_json(t) FROM tenants t WHERE id = $1`, id)
+}
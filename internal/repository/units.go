@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Zolet-hash/smart-rentals/internal/listing"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+)
+
+type pgUnits struct{ q dbtx }
+
+const unitColumns = "u.id, u.property_id, u.unit_name, COALESCE(u.unit_type, ''), u.unit_price, u.vacancy, u.created_at, u.updated_at"
+
+func scanUnit(row rowScanner) (models.Unit, error) {
+	var u models.Unit
+	err := row.Scan(&u.ID, &u.PropertyID, &u.UnitName, &u.UnitType, &u.UnitPrice, &u.Vacancy, &u.CreatedAt, &u.UpdatedAt)
+	return u, err
+}
+
+// unitKey is a unit's sort value and id for a UnitList sort
+func unitKey(sort string) func(models.Unit) (interface{}, int64) {
+	return func(u models.Unit) (interface{}, int64) {
+		if sort == "unit_price" {
+			return u.UnitPrice, int64(u.ID)
+		}
+		return u.UnitName, int64(u.ID)
+	}
+}
+
+func (r pgUnits) Get(ctx context.Context, userID int, perm permissions.Permission, propertyID, id int) (models.Unit, error) {
+	u, err := scanUnit(r.q.QueryRowContext(ctx, `
+		SELECT `+unitColumns+` FROM units u
+		WHERE u.id = $1 AND u.property_id = $2
+		  AND u.property_id IN (SELECT accessible_property_ids($3, $4))`,
+		id, propertyID, userID, string(perm)))
+	return u, storeError(err)
+}
+
+func (r pgUnits) LandlordID(ctx context.Context, userID int, perm permissions.Permission, id int) (int, error) {
+	var landlordID int
+	err := r.q.QueryRowContext(ctx, `
+		SELECT p.landlord_id FROM units u
+		JOIN properties p ON u.property_id = p.id
+		WHERE u.id = $1 AND p.id IN (SELECT accessible_property_ids($2, $3))`,
+		id, userID, string(perm),
+	).Scan(&landlordID)
+	return landlordID, storeError(err)
+}
+
+func (r pgUnits) List(ctx context.Context, propertyID int, page listing.Page, f listing.Filter) (Page[models.Unit], error) {
+	var q listing.Query
+	q.Where("property_id = " + q.Arg(propertyID))
+	q.AmountRange("unit_price", f)
+	q.Search(f, "unit_name")
+	return list(ctx, r.q, page, q, unitColumns, "FROM units u", scanUnit, unitKey(page.Sort))
+}
+
+func (r pgUnits) Create(ctx context.Context, u *models.Unit) error {
+	return r.q.QueryRowContext(ctx, `
+		INSERT INTO units (property_id, unit_name, unit_type, unit_price, vacancy)
+		VALUES ($1, $2, $3, $4, true)
+		RETURNING id, vacancy, created_at, updated_at`,
+		u.PropertyID, u.UnitName, u.UnitType, u.UnitPrice,
+	).Scan(&u.ID, &u.Vacancy, &u.CreatedAt, &u.UpdatedAt)
+}
+
+func (r pgUnits) Update(ctx context.Context, id int, u UnitUpdate) error {
+	var set updateSet
+	if u.UnitName != nil {
+		set.set("unit_name", *u.UnitName)
+	}
+	if u.UnitType != nil {
+		set.set("unit_type", *u.UnitType)
+	}
+	if u.UnitPrice != nil {
+		set.set("unit_price", *u.UnitPrice)
+	}
+	return set.exec(ctx, r.q, "units", id)
+}
+
+// Delete removes a unit; it is ErrInUse while the unit has tenants
+func (r pgUnits) Delete(ctx context.Context, id int) error {
+	return execOne(ctx, r.q, "DELETE FROM units WHERE id = $1", id)
+}
+
+func (r pgUnits) SetVacancy(ctx context.Context, id int, vacant bool) error {
+	return execOne(ctx, r.q, "UPDATE units SET vacancy = $1 WHERE id = $2", vacant, id)
+}
+
+func (r pgUnits) Snapshot(ctx context.Context, id int) ([]byte, error) {
+	return snapshot(ctx, r.q, `SELECT row_to_json(x) FROM (SELECT u.*, p.landlord_id FROM units u JOIN properties p ON u.property_id = p.id WHERE u.id = $1) x`, id)
+}
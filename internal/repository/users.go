@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Zolet-hash/smart-rentals/internal/listing"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+)
+
+type pgUsers struct{ q dbtx }
+
+// Users are loaded without secrets: no password hash, token version or
+// two-factor secret
+const userColumns = "id, email, COALESCE(full_name, ''), COALESCE(phone, ''), role, totp_enabled, locked_until, created_at, updated_at"
+
+func scanUser(row rowScanner) (models.User, error) {
+	var u models.User
+	var lockedUntil sql.NullTime
+	err := row.Scan(&u.ID, &u.Email, &u.FullName, &u.Phone, &u.Role, &u.TOTPEnabled, &lockedUntil, &u.CreatedAT, &u.UpdatedAt)
+	if lockedUntil.Valid {
+		u.LockedUntil = &lockedUntil.Time
+	}
+	return u, err
+}
+
+// userKey is a user's sort value and id for a UserList sort
+func userKey(sort string) func(models.User) (interface{}, int64) {
+	return func(u models.User) (interface{}, int64) {
+		switch sort {
+		case "email":
+			return u.Email, int64(u.ID)
+		case "full_name":
+			return u.FullName, int64(u.ID)
+		}
+		return u.CreatedAT, int64(u.ID)
+	}
+}
+
+func (r pgUsers) Get(ctx context.Context, id int) (models.User, error) {
+	u, err := scanUser(r.q.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+	return u, storeError(err)
+}
+
+func (r pgUsers) List(ctx context.Context, role string, page listing.Page, f listing.Filter) (Page[models.User], error) {
+	var q listing.Query
+	if role != "" {
+		q.Where("role = " + q.Arg(role))
+	}
+	q.Search(f, "email", "full_name", "phone")
+	return list(ctx, r.q, page, q, userColumns, "FROM users", scanUser, userKey(page.Sort))
+}
+
+// Update returns ErrConflict when another user has the email
+func (r pgUsers) Update(ctx context.Context, u models.User) error {
+	return execOne(ctx, r.q,
+		"UPDATE users SET email = $1, full_name = $2, phone = $3, role = $4, updated_at = NOW() WHERE id = $5",
+		u.Email, u.FullName, u.Phone, u.Role, u.ID)
+}
+
+// Delete removes a user; it is ErrInUse while the user is the landlord of
+// tenants
+func (r pgUsers) Delete(ctx context.Context, id int) error {
+	return execOne(ctx, r.q, "DELETE FROM users WHERE id = $1", id)
+}
+
+func (r pgUsers) HasRole(ctx context.Context, id int, role string) (bool, error) {
+	var ok bool
+	err := r.q.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND role = $2)", id, role).Scan(&ok)
+	return ok, err
+}
+
+func (r pgUsers) OrgMembership(ctx context.Context, orgID, userID int) (string, []permissions.Permission, error) {
+	var role, accountRole string
+	err := r.q.QueryRowContext(ctx, `
+		SELECT m.role, u.role FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1 AND m.user_id = $2`,
+		orgID, userID,
+	).Scan(&role, &accountRole)
+	if err != nil {
+		return "", nil, storeError(err)
+	}
+	return role, permissions.ForOrgMember(role, accountRole), nil
+}
+
+func (r pgUsers) Snapshot(ctx context.Context, id int) ([]byte, error) {
+	return snapshot(ctx, r.q, `SELECT row_to_json(x) FROM (SELECT id, email, full_name, phone, role, totp_enabled, locked_until FROM users WHERE id = $1) x`, id)
+}
@@ -0,0 +1,715 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/lib/pq"
+)
+
+// Accounting export formats
+const (
+	FormatQuickBooksIIF = "quickbooks_iif"
+	FormatQuickBooksCSV = "quickbooks_csv"
+	FormatXeroCSV       = "xero_csv"
+)
+
+// AccountingFormats lists the export formats
+var AccountingFormats = []string{FormatQuickBooksIIF, FormatQuickBooksCSV, FormatXeroCSV}
+
+// Journal entry sources, in the order entries of a day are listed
+const (
+	SourceRent    = "rent"
+	SourceFee     = "fee"
+	SourcePayment = "payment"
+	SourceExpense = "expense"
+)
+
+var sourceOrder = map[string]int{SourceRent: 0, SourceFee: 1, SourcePayment: 2, SourceExpense: 3}
+
+// accountingLock is the advisory lock class serialising a landlord's
+// exports, so two at once cannot both take the same entries
+const accountingLock = 7_231_003
+
+// defaultXeroTaxRate is the Xero tax rate of exported lines; rent on
+// residential property is exempt from VAT
+const defaultXeroTaxRate = "Tax Exempt"
+
+// DefaultAccounts are the accounts journal lines post to unless a landlord
+// maps them to their own. Expense categories are keyed expense.<category>.
+var DefaultAccounts = map[string]string{
+	"accounts_receivable":     "Accounts Receivable",
+	"rental_income":           "Rental Income",
+	"fee_income":              "Tenant Recharges",
+	"tenant_deposits":         "Tenant Deposits",
+	"cash":                    "Cash on Hand",
+	"mpesa":                   "M-Pesa",
+	"bank":                    "Bank",
+	"expenses_paid_from":      "Accounts Payable",
+	"expense.repairs":         "Repairs and Maintenance",
+	"expense.utilities":       "Utilities",
+	"expense.insurance":       "Insurance",
+	"expense.property_tax":    "Rates and Land Rent",
+	"expense.management_fees": "Management Fees",
+	"expense.cleaning":        "Cleaning",
+	"expense.security":        "Security",
+	"expense.salaries":        "Salaries and Wages",
+	"expense.supplies":        "Supplies",
+	"expense.legal":           "Legal and Professional Fees",
+	"expense.other":           "Other Expenses",
+}
+
+var (
+	ErrUnknownAccountingFormat = fmt.Errorf("format must be one of %s", strings.Join(AccountingFormats, ", "))
+	ErrUnknownAccount          = errors.New("accounts may only map the keys listed in the defaults")
+	ErrInvalidAccountName      = errors.New("account names must be at most 100 characters")
+	ErrBatchNotFound           = errors.New("export batch not found")
+)
+
+// AccountingService exports rent charges, tenant fees, payments and
+// expenses as journal entries for QuickBooks and Xero. Every exported
+// record is remembered so later exports only carry new ones.
+type AccountingService struct {
+	DB *database.Database
+}
+
+func NewAccountingService(db *database.Database) *AccountingService {
+	return &AccountingService{DB: db}
+}
+
+// ValidAccountingFormat reports whether format is an export format
+func ValidAccountingFormat(format string) bool {
+	return contains(AccountingFormats, format)
+}
+
+// AccountingFilter bounds an export by date, both ends inclusive. A zero
+// From takes everything not yet exported up to To, which defaults to today.
+type AccountingFilter struct {
+	LandlordID int // 0 for the caller
+	From       time.Time
+	To         time.Time
+}
+
+// rowsQuerier is a database or a transaction
+type rowsQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// accountingLandlord resolves whose books the caller works on: their own, or
+// for staff the landlord given, if they may act on it with perm
+func (s *AccountingService) accountingLandlord(ctx context.Context, userID, landlordID int, perm permissions.Permission) (int, error) {
+	if landlordID == 0 || landlordID == userID {
+		return userID, nil
+	}
+	var ok bool
+	err := s.DB.QueryRowContext(ctx, "SELECT $1 IN (SELECT accessible_landlord_ids($2, $3))", landlordID, userID, string(perm)).Scan(&ok)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrLandlordNotFound
+	}
+	return landlordID, nil
+}
+
+// Settings returns the landlord's account mapping with defaults filled in
+func (s *AccountingService) Settings(ctx context.Context, userID, landlordID int) (models.AccountingSettings, error) {
+	landlordID, err := s.accountingLandlord(ctx, userID, landlordID, permissions.PaymentsRead)
+	if err != nil {
+		return models.AccountingSettings{}, err
+	}
+	return s.settings(ctx, landlordID)
+}
+
+func (s *AccountingService) settings(ctx context.Context, landlordID int) (models.AccountingSettings, error) {
+	set := models.AccountingSettings{LandlordID: uint(landlordID), Accounts: map[string]string{}, XeroTaxRate: defaultXeroTaxRate}
+	for k, v := range DefaultAccounts {
+		set.Accounts[k] = v
+	}
+	var raw []byte
+	var taxRate sql.NullString
+	err := s.DB.QueryRowContext(ctx, "SELECT accounts, xero_tax_rate FROM accounting_settings WHERE landlord_id = $1", landlordID).Scan(&raw, &taxRate)
+	if err == sql.ErrNoRows {
+		return set, nil
+	}
+	if err != nil {
+		return set, err
+	}
+	var overrides map[string]string
+	if err := json.Unmarshal(raw, &overrides); err != nil {
+		return set, err
+	}
+	for k, v := range overrides {
+		if _, known := DefaultAccounts[k]; known {
+			set.Accounts[k] = v
+		}
+	}
+	if taxRate.Valid && taxRate.String != "" {
+		set.XeroTaxRate = taxRate.String
+	}
+	return set, nil
+}
+
+// SaveSettings maps accounts to the landlord's own; an empty name restores
+// the default. A nil xeroTaxRate keeps the current one.
+func (s *AccountingService) SaveSettings(ctx context.Context, userID, landlordID int, accounts map[string]string, xeroTaxRate *string) (models.AccountingSettings, error) {
+	landlordID, err := s.accountingLandlord(ctx, userID, landlordID, permissions.ExpensesWrite)
+	if err != nil {
+		return models.AccountingSettings{}, err
+	}
+	current, err := s.settings(ctx, landlordID)
+	if err != nil {
+		return current, err
+	}
+
+	overrides := map[string]string{}
+	for k, v := range current.Accounts {
+		if v != DefaultAccounts[k] {
+			overrides[k] = v
+		}
+	}
+	for k, v := range accounts {
+		if _, known := DefaultAccounts[k]; !known {
+			return current, ErrUnknownAccount
+		}
+		v = strings.TrimSpace(v)
+		if len(v) > 100 {
+			return current, ErrInvalidAccountName
+		}
+		if v == "" || v == DefaultAccounts[k] {
+			delete(overrides, k)
+			continue
+		}
+		overrides[k] = v
+	}
+	taxRate := current.XeroTaxRate
+	if xeroTaxRate != nil {
+		if taxRate = strings.TrimSpace(*xeroTaxRate); taxRate == "" {
+			taxRate = defaultXeroTaxRate
+		}
+		if len(taxRate) > 50 {
+			return current, ErrInvalidAccountName
+		}
+	}
+
+	raw, err := json.Marshal(overrides)
+	if err != nil {
+		return current, err
+	}
+	_, err = s.DB.ExecContext(ctx, `
+		INSERT INTO accounting_settings (landlord_id, accounts, xero_tax_rate, updated_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (landlord_id) DO UPDATE SET
+			accounts = EXCLUDED.accounts, xero_tax_rate = EXCLUDED.xero_tax_rate,
+			updated_by = EXCLUDED.updated_by, updated_at = NOW()`,
+		landlordID, string(raw), taxRate, userID)
+	if err != nil {
+		return current, err
+	}
+	return s.settings(ctx, landlordID)
+}
+
+// Export returns the journal entries in the filter's range not exported
+// before. With record, they are saved as a batch in format and will not be
+// exported again; otherwise this is a preview.
+func (s *AccountingService) Export(ctx context.Context, userID int, f AccountingFilter, format string, record bool) (models.AccountingBatch, error) {
+	if f.To.IsZero() {
+		f.To = time.Now().In(reminderZone)
+	}
+	batch := models.AccountingBatch{Format: format, To: f.To.Format(dateLayout), Entries: []models.AccountingEntry{}}
+	if !f.From.IsZero() {
+		from := f.From.Format(dateLayout)
+		batch.From = &from
+	}
+	if (record || format != "") && !ValidAccountingFormat(format) {
+		return batch, ErrUnknownAccountingFormat
+	}
+	if !f.From.IsZero() && f.To.Before(f.From) {
+		return batch, ErrInvalidDateRange
+	}
+	landlordID, err := s.accountingLandlord(ctx, userID, f.LandlordID, permissions.PaymentsRead)
+	if err != nil {
+		return batch, err
+	}
+	batch.LandlordID = uint(landlordID)
+	set, err := s.settings(ctx, landlordID)
+	if err != nil {
+		return batch, err
+	}
+
+	if !record {
+		batch.Entries, err = s.entries(ctx, s.DB, userID, landlordID, f, set.Accounts)
+		batch.EntryCount, batch.TotalAmount = len(batch.Entries), entriesTotal(batch.Entries)
+		return batch, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return batch, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1, $2)", accountingLock, landlordID); err != nil {
+		return batch, err
+	}
+	if batch.Entries, err = s.entries(ctx, tx, userID, landlordID, f, set.Accounts); err != nil {
+		return batch, err
+	}
+	batch.EntryCount, batch.TotalAmount = len(batch.Entries), entriesTotal(batch.Entries)
+	if batch.EntryCount == 0 {
+		return batch, nil
+	}
+
+	raw, err := json.Marshal(batch.Entries)
+	if err != nil {
+		return batch, err
+	}
+	createdBy := uint(userID)
+	batch.CreatedBy = &createdBy
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO accounting_export_batches (landlord_id, format, from_date, to_date, entry_count, total_amount, entries, created_by)
+		VALUES ($1, $2, $3::DATE, $4::DATE, $5, $6, $7, $8)
+		RETURNING id, created_at`,
+		landlordID, format, batch.From, batch.To, batch.EntryCount, batch.TotalAmount, string(raw), userID,
+	).Scan(&batch.ID, &batch.CreatedAt)
+	if err != nil {
+		return batch, err
+	}
+
+	sources := make([]string, len(batch.Entries))
+	keys := make([]string, len(batch.Entries))
+	for i, e := range batch.Entries {
+		sources[i], keys[i] = e.Source, e.SourceKey
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO accounting_exported_entries (landlord_id, source, source_key, batch_id)
+		SELECT $1, s, k, $2 FROM unnest($3::TEXT[], $4::TEXT[]) AS e(s, k)`,
+		landlordID, batch.ID, pq.Array(sources), pq.Array(keys))
+	if err != nil {
+		return batch, err
+	}
+	return batch, tx.Commit()
+}
+
+func entriesTotal(entries []models.AccountingEntry) float64 {
+	total := 0.0
+	for _, e := range entries {
+		for _, l := range e.Lines {
+			total += l.Debit
+		}
+	}
+	return roundTo(total, 2)
+}
+
+// notExported is the condition leaving out records already exported;
+// source and key are SQL expressions
+func notExported(landlord, source, key string) string {
+	return `NOT EXISTS (SELECT 1 FROM accounting_exported_entries x
+		WHERE x.landlord_id = ` + landlord + ` AND x.source = ` + source + ` AND x.source_key = ` + key + `)`
+}
+
+// entries collects the journal entries of every source, by date
+func (s *AccountingService) entries(ctx context.Context, db rowsQuerier, userID, landlordID int, f AccountingFilter, accounts map[string]string) ([]models.AccountingEntry, error) {
+	from := f.From
+	if from.IsZero() {
+		from = time.Date(2000, time.January, 1, 0, 0, 0, 0, reminderZone)
+	}
+	fromDate, toDate := from.Format(dateLayout), f.To.AddDate(0, 0, 1).Format(dateLayout)
+
+	var entries []models.AccountingEntry
+	for _, collect := range []func(context.Context, rowsQuerier, int, int, string, string, map[string]string) ([]models.AccountingEntry, error){
+		rentEntries, feeEntries, paymentEntries, expenseEntries,
+	} {
+		e, err := collect(ctx, db, userID, landlordID, fromDate, toDate, accounts)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.Source != b.Source {
+			return sourceOrder[a.Source] < sourceOrder[b.Source]
+		}
+		return a.Number < b.Number
+	})
+	if entries == nil {
+		entries = []models.AccountingEntry{}
+	}
+	return entries, nil
+}
+
+// rentEntries charges rent on each due date in [from, to): the day a
+// tenancy starts and the rent due day of each later month
+func rentEntries(ctx context.Context, db rowsQuerier, userID, landlordID int, from, to string, accounts map[string]string) ([]models.AccountingEntry, error) {
+	var args queryArgs
+	landlord := args.add(landlordID)
+	fromArg, toArg := args.add(from), args.add(to)
+	rows, err := db.QueryContext(ctx, `
+		SELECT t.id, t.tenant_name, pr.title, u.unit_name, t.rent, d.due
+		FROM tenants t
+		JOIN units u ON t.unit_id = u.id
+		JOIN properties pr ON u.property_id = pr.id
+		CROSS JOIN LATERAL (
+			SELECT (t.created_at AT TIME ZONE 'Africa/Nairobi')::DATE AS start
+		) s
+		CROSS JOIN LATERAL (
+			SELECT s.start AS due
+			UNION
+			SELECT g::DATE + t.rent_due_day - 1
+			FROM generate_series(date_trunc('month', s.start::TIMESTAMP), `+toArg+`::DATE::TIMESTAMP, INTERVAL '1 month') g
+			WHERE g::DATE + t.rent_due_day - 1 > s.start
+		) d
+		WHERE t.landlord_id = `+landlord+` AND COALESCE(t.rent, 0) > 0
+		  AND u.property_id IN (SELECT accessible_property_ids(`+args.add(userID)+`, `+args.add(string(permissions.PaymentsRead))+`))
+		  AND d.due >= `+fromArg+`::DATE AND d.due < `+toArg+`::DATE
+		  AND `+notExported(landlord, "'rent'", "t.id || ':' || to_char(d.due, 'YYYY-MM-DD')"),
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AccountingEntry
+	for rows.Next() {
+		var tenantID int
+		var name, property, unit string
+		var rent float64
+		var due time.Time
+		if err := rows.Scan(&tenantID, &name, &property, &unit, &rent, &due); err != nil {
+			return nil, err
+		}
+		day := due.Format(dateLayout)
+		entries = append(entries, models.AccountingEntry{
+			Source: SourceRent, SourceKey: fmt.Sprintf("%d:%s", tenantID, day),
+			Number: fmt.Sprintf("RENT-%d-%s", tenantID, due.Format("20060102")),
+			Date:   day, Name: name, Property: property,
+			Memo:  fmt.Sprintf("Rent %s, %s %s", due.Format("Jan 2006"), property, unit),
+			Lines: balanced(accounts["accounts_receivable"], accounts["rental_income"], rent),
+		})
+	}
+	return entries, rows.Err()
+}
+
+// feeEntries charges tenants for fees other than rent, such as recharged
+// repairs
+func feeEntries(ctx context.Context, db rowsQuerier, userID, landlordID int, from, to string, accounts map[string]string) ([]models.AccountingEntry, error) {
+	var args queryArgs
+	landlord := args.add(landlordID)
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.id, t.tenant_name, pr.title, c.description, c.amount, (c.created_at AT TIME ZONE 'Africa/Nairobi')::DATE
+		FROM tenant_charges c
+		JOIN tenants t ON c.tenant_id = t.id
+		JOIN units u ON t.unit_id = u.id
+		JOIN properties pr ON u.property_id = pr.id
+		WHERE c.landlord_id = `+landlord+`
+		  AND u.property_id IN (SELECT accessible_property_ids(`+args.add(userID)+`, `+args.add(string(permissions.PaymentsRead))+`))
+		  AND c.created_at >= (`+args.add(from)+`::DATE)::TIMESTAMP AT TIME ZONE 'Africa/Nairobi'
+		  AND c.created_at < (`+args.add(to)+`::DATE)::TIMESTAMP AT TIME ZONE 'Africa/Nairobi'
+		  AND `+notExported(landlord, "'fee'", "c.id::TEXT"),
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AccountingEntry
+	for rows.Next() {
+		var id int64
+		var name, property, description string
+		var amount float64
+		var day time.Time
+		if err := rows.Scan(&id, &name, &property, &description, &amount, &day); err != nil {
+			return nil, err
+		}
+		entries = append(entries, models.AccountingEntry{
+			Source: SourceFee, SourceKey: strconv.FormatInt(id, 10), Number: fmt.Sprintf("FEE-%d", id),
+			Date: day.Format(dateLayout), Name: name, Property: property, Memo: description,
+			Lines: balanced(accounts["accounts_receivable"], accounts["fee_income"], amount),
+		})
+	}
+	return entries, rows.Err()
+}
+
+// paymentEntries receives completed tenant payments into the account of
+// their method; deposits are held as a liability rather than settling rent
+func paymentEntries(ctx context.Context, db rowsQuerier, userID, landlordID int, from, to string, accounts map[string]string) ([]models.AccountingEntry, error) {
+	var args queryArgs
+	landlord := args.add(landlordID)
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, t.tenant_name, pr.title, p.amount, p.method, p.category, COALESCE(p.receipt, ''),
+		       COALESCE(p.receipt_no, ''), (p.created_at AT TIME ZONE 'Africa/Nairobi')::DATE
+		FROM payments p
+		JOIN tenants t ON p.tenant_id = t.id
+		JOIN units u ON t.unit_id = u.id
+		JOIN properties pr ON u.property_id = pr.id
+		WHERE p.landlord_id = `+landlord+` AND p.status = 'COMPLETED'
+		  AND u.property_id IN (SELECT accessible_property_ids(`+args.add(userID)+`, `+args.add(string(permissions.PaymentsRead))+`))
+		  AND p.created_at >= (`+args.add(from)+`::DATE)::TIMESTAMP AT TIME ZONE 'Africa/Nairobi'
+		  AND p.created_at < (`+args.add(to)+`::DATE)::TIMESTAMP AT TIME ZONE 'Africa/Nairobi'
+		  AND `+notExported(landlord, "'payment'", "p.id::TEXT"),
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AccountingEntry
+	for rows.Next() {
+		var id int64
+		var name, property, method, category, receipt, receiptNo string
+		var amount float64
+		var day time.Time
+		if err := rows.Scan(&id, &name, &property, &amount, &method, &category, &receipt, &receiptNo, &day); err != nil {
+			return nil, err
+		}
+		number := receiptNo
+		if number == "" {
+			number = fmt.Sprintf("PAY-%d", id)
+		}
+		credit := accounts["accounts_receivable"]
+		if category == "deposit" {
+			credit = accounts["tenant_deposits"]
+		}
+		memo := fmt.Sprintf("%s payment %s", strings.ReplaceAll(category, "_", " "), receipt)
+		entries = append(entries, models.AccountingEntry{
+			Source: SourcePayment, SourceKey: strconv.FormatInt(id, 10), Number: number,
+			Date: day.Format(dateLayout), Name: name, Property: property,
+			Memo:  strings.TrimSpace(strings.ToUpper(memo[:1]) + memo[1:]),
+			Lines: balanced(accounts[paymentAccount(method)], credit, amount),
+		})
+	}
+	return entries, rows.Err()
+}
+
+// paymentAccount is the account key money received by method lands in
+func paymentAccount(method string) string {
+	switch {
+	case method == "CASH":
+		return "cash"
+	case strings.HasPrefix(method, "MPESA"):
+		return "mpesa"
+	}
+	return "bank"
+}
+
+// expenseEntries books expenses to their category's account
+func expenseEntries(ctx context.Context, db rowsQuerier, userID, landlordID int, from, to string, accounts map[string]string) ([]models.AccountingEntry, error) {
+	var args queryArgs
+	landlord := args.add(landlordID)
+	rows, err := db.QueryContext(ctx, `
+		SELECT e.id, COALESCE(v.name, ''), pr.title, e.category, e.description, COALESCE(e.reference, ''), e.amount, e.expense_date
+		FROM expenses e
+		JOIN properties pr ON e.property_id = pr.id
+		LEFT JOIN vendors v ON e.vendor_id = v.id
+		WHERE e.landlord_id = `+landlord+`
+		  AND e.property_id IN (SELECT accessible_property_ids(`+args.add(userID)+`, `+args.add(string(permissions.ExpensesRead))+`))
+		  AND e.expense_date >= `+args.add(from)+`::DATE AND e.expense_date < `+args.add(to)+`::DATE
+		  AND `+notExported(landlord, "'expense'", "e.id::TEXT"),
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AccountingEntry
+	for rows.Next() {
+		var id int64
+		var vendor, property, category, description, reference string
+		var amount float64
+		var day time.Time
+		if err := rows.Scan(&id, &vendor, &property, &category, &description, &reference, &amount, &day); err != nil {
+			return nil, err
+		}
+		number := fmt.Sprintf("EXP-%d", id)
+		if reference != "" {
+			description += " (" + reference + ")"
+		}
+		debit, ok := accounts["expense."+category]
+		if !ok {
+			debit = accounts["expense.other"]
+		}
+		entries = append(entries, models.AccountingEntry{
+			Source: SourceExpense, SourceKey: strconv.FormatInt(id, 10), Number: number,
+			Date: day.Format(dateLayout), Name: vendor, Property: property, Memo: description,
+			Lines: balanced(debit, accounts["expenses_paid_from"], amount),
+		})
+	}
+	return entries, rows.Err()
+}
+
+// balanced is a two-line entry debiting one account and crediting another
+func balanced(debit, credit string, amount float64) []models.AccountingLine {
+	amount = roundTo(amount, 2)
+	return []models.AccountingLine{{Account: debit, Debit: amount}, {Account: credit, Credit: amount}}
+}
+
+// ListBatches returns the recorded exports of the landlords the caller may
+// export for, newest first
+func (s *AccountingService) ListBatches(ctx context.Context, userID, limit, offset int) ([]models.AccountingBatch, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, landlord_id, format, from_date, to_date, entry_count, total_amount, created_by, created_at
+		FROM accounting_export_batches
+		WHERE landlord_id IN (SELECT accessible_landlord_ids($1, $2))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4`,
+		userID, string(permissions.PaymentsRead), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.AccountingBatch{}
+	for rows.Next() {
+		var b models.AccountingBatch
+		if err := scanAccountingBatch(rows, &b); err != nil {
+			return nil, err
+		}
+		list = append(list, b)
+	}
+	return list, rows.Err()
+}
+
+// GetBatch returns a recorded export with its entries
+func (s *AccountingService) GetBatch(ctx context.Context, userID int, id int64) (models.AccountingBatch, error) {
+	var b models.AccountingBatch
+	var raw []byte
+	err := scanAccountingBatch(s.DB.QueryRowContext(ctx, `
+		SELECT id, landlord_id, format, from_date, to_date, entry_count, total_amount, created_by, created_at, entries
+		FROM accounting_export_batches
+		WHERE id = $1 AND landlord_id IN (SELECT accessible_landlord_ids($2, $3))`,
+		id, userID, string(permissions.PaymentsRead),
+	), &b, &raw)
+	if err == sql.ErrNoRows {
+		return b, ErrBatchNotFound
+	}
+	if err != nil {
+		return b, err
+	}
+	return b, json.Unmarshal(raw, &b.Entries)
+}
+
+func scanAccountingBatch(row rowScanner, b *models.AccountingBatch, extra ...interface{}) error {
+	var from sql.NullTime
+	var to time.Time
+	var createdBy sql.NullInt64
+	dest := []interface{}{&b.ID, &b.LandlordID, &b.Format, &from, &to, &b.EntryCount, &b.TotalAmount, &createdBy, &b.CreatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	if from.Valid {
+		f := from.Time.Format(dateLayout)
+		b.From = &f
+	}
+	b.To = to.Format(dateLayout)
+	b.CreatedBy = nullUint(createdBy)
+	return nil
+}
+
+// AccountingFile returns the file name extension and content type of an
+// export format
+func AccountingFile(format string) (ext, contentType string) {
+	if format == FormatQuickBooksIIF {
+		return "iif", "application/octet-stream"
+	}
+	return "csv", "text/csv"
+}
+
+// WriteJournal writes entries in an accounting package's import format:
+// QuickBooks Desktop IIF general journal transactions, a QuickBooks Online
+// journal entry CSV or a Xero manual journal CSV. xeroTaxRate is the tax
+// rate Xero lines carry.
+func WriteJournal(w io.Writer, format string, entries []models.AccountingEntry, xeroTaxRate string) error {
+	switch format {
+	case FormatQuickBooksIIF:
+		return writeIIF(w, entries)
+	case FormatQuickBooksCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"JournalNo", "JournalDate", "AccountName", "Debits", "Credits", "Description"})
+		for _, e := range entries {
+			date := journalDate(e.Date, "02/01/2006")
+			for _, l := range e.Lines {
+				cw.Write([]string{e.Number, date, l.Account, journalAmount(l.Debit), journalAmount(l.Credit), entryDescription(e)})
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case FormatXeroCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"*Narration", "*Date", "Description", "*AccountCode", "*TaxRate", "*Amount"})
+		for _, e := range entries {
+			date := journalDate(e.Date, "02/01/2006")
+			narration := e.Number + " " + entryDescription(e)
+			for _, l := range e.Lines {
+				cw.Write([]string{narration, date, e.Memo, l.Account, xeroTaxRate, signedAmount(l)})
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return ErrUnknownAccountingFormat
+}
+
+// writeIIF writes general journal transactions: the first line of each as
+// TRNS, the rest as SPL, debits positive and credits negative
+func writeIIF(w io.Writer, entries []models.AccountingEntry) error {
+	clean := strings.NewReplacer("\t", " ", "\r", " ", "\n", " ", `"`, "'")
+	var b strings.Builder
+	b.WriteString("!TRNS\tTRNSID\tTRNSTYPE\tDATE\tACCNT\tAMOUNT\tDOCNUM\tMEMO\r\n")
+	b.WriteString("!SPL\tSPLID\tTRNSTYPE\tDATE\tACCNT\tAMOUNT\tDOCNUM\tMEMO\r\n")
+	b.WriteString("!ENDTRNS\r\n")
+	for _, e := range entries {
+		date := journalDate(e.Date, "01/02/2006")
+		for i, l := range e.Lines {
+			kind := "SPL"
+			if i == 0 {
+				kind = "TRNS"
+			}
+			fmt.Fprintf(&b, "%s\t\tGENERAL JOURNAL\t%s\t%s\t%s\t%s\t%s\r\n",
+				kind, date, clean.Replace(l.Account), signedAmount(l), clean.Replace(e.Number), clean.Replace(entryDescription(e)))
+		}
+		b.WriteString("ENDTRNS\r\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func entryDescription(e models.AccountingEntry) string {
+	if e.Name == "" {
+		return e.Memo
+	}
+	return e.Name + " - " + e.Memo
+}
+
+func journalDate(day, layout string) string {
+	t, err := time.Parse(dateLayout, day)
+	if err != nil {
+		return day
+	}
+	return t.Format(layout)
+}
+
+func journalAmount(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func signedAmount(l models.AccountingLine) string {
+	return strconv.FormatFloat(roundTo(l.Debit-l.Credit, 2), 'f', 2, 64)
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+)
+
+// journalEntries are a rent charge and a payment with a tab, newline and
+// quote in the tenant's name
+var journalEntries = []models.AccountingEntry{
+	{Source: SourceRent, Number: "RENT-12-2026-10", Date: "2026-10-05", Name: "Jane \"JW\"\tWanjiru", Memo: "Rent A4, Kamau Flats",
+		Lines: balanced("Accounts Receivable", "Rental Income", 15000)},
+	{Source: SourcePayment, Number: "PAY-88", Date: "2026-10-06", Memo: "M-Pesa SJ12ABC",
+		Lines: balanced("M-Pesa", "Accounts Receivable", 14999.999)},
+}
+
+func TestWriteJournal(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{FormatQuickBooksIIF, "!TRNS\tTRNSID\tTRNSTYPE\tDATE\tACCNT\tAMOUNT\tDOCNUM\tMEMO\r\n" +
+			"!SPL\tSPLID\tTRNSTYPE\tDATE\tACCNT\tAMOUNT\tDOCNUM\tMEMO\r\n" +
+			"!ENDTRNS\r\n" +
+			"TRNS\t\tGENERAL JOURNAL\t10/05/2026\tAccounts Receivable\t15000.00\tRENT-12-2026-10\tJane 'JW' Wanjiru - Rent A4, Kamau Flats\r\n" +
+			"SPL\t\tGENERAL JOURNAL\t10/05/2026\tRental Income\t-15000.00\tRENT-12-2026-10\tJane 'JW' Wanjiru - Rent A4, Kamau Flats\r\n" +
+			"ENDTRNS\r\n" +
+			"TRNS\t\tGENERAL JOURNAL\t10/06/2026\tM-Pesa\t15000.00\tPAY-88\tM-Pesa SJ12ABC\r\n" +
+			"SPL\t\tGENERAL JOURNAL\t10/06/2026\tAccounts Receivable\t-15000.00\tPAY-88\tM-Pesa SJ12ABC\r\n" +
+			"ENDTRNS\r\n"},
+		{FormatQuickBooksCSV, "JournalNo,JournalDate,AccountName,Debits,Credits,Description\n" +
+			"RENT-12-2026-10,05/10/2026,Accounts Receivable,15000.00,,\"Jane \"\"JW\"\"\tWanjiru - Rent A4, Kamau Flats\"\n" +
+			"RENT-12-2026-10,05/10/2026,Rental Income,,15000.00,\"Jane \"\"JW\"\"\tWanjiru - Rent A4, Kamau Flats\"\n" +
+			"PAY-88,06/10/2026,M-Pesa,15000.00,,M-Pesa SJ12ABC\n" +
+			"PAY-88,06/10/2026,Accounts Receivable,,15000.00,M-Pesa SJ12ABC\n"},
+		{FormatXeroCSV, "*Narration,*Date,Description,*AccountCode,*TaxRate,*Amount\n" +
+			"\"RENT-12-2026-10 Jane \"\"JW\"\"\tWanjiru - Rent A4, Kamau Flats\",05/10/2026,\"Rent A4, Kamau Flats\",Accounts Receivable,Tax Exempt,15000.00\n" +
+			"\"RENT-12-2026-10 Jane \"\"JW\"\"\tWanjiru - Rent A4, Kamau Flats\",05/10/2026,\"Rent A4, Kamau Flats\",Rental Income,Tax Exempt,-15000.00\n" +
+			"PAY-88 M-Pesa SJ12ABC,06/10/2026,M-Pesa SJ12ABC,M-Pesa,Tax Exempt,15000.00\n" +
+			"PAY-88 M-Pesa SJ12ABC,06/10/2026,M-Pesa SJ12ABC,Accounts Receivable,Tax Exempt,-15000.00\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := WriteJournal(&buf, tt.format, journalEntries, defaultXeroTaxRate); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s:\ngot  %q\nwant %q", tt.format, got, tt.want)
+		}
+	}
+	if err := WriteJournal(&bytes.Buffer{}, "sage", journalEntries, ""); !errors.Is(err, ErrUnknownAccountingFormat) {
+		t.Errorf("unknown format: err = %v", err)
+	}
+}
+
+func TestJournalBalances(t *testing.T) {
+	for _, e := range journalEntries {
+		var debits, credits float64
+		for _, l := range e.Lines {
+			debits += l.Debit
+			credits += l.Credit
+		}
+		if debits != credits {
+			t.Errorf("%s: debits %v, credits %v", e.Number, debits, credits)
+		}
+	}
+	if got := entriesTotal(journalEntries); got != 30000 {
+		t.Errorf("entriesTotal = %v, want 30000", got)
+	}
+}
+
+func TestPaymentAccount(t *testing.T) {
+	for method, want := range map[string]string{
+		"CASH":          "cash",
+		"MPESA":         "mpesa",
+		"MPESA_PAYBILL": "mpesa",
+		"BANK_TRANSFER": "bank",
+		"CHEQUE":        "bank",
+		"":              "bank",
+	} {
+		if got := paymentAccount(method); got != want {
+			t.Errorf("paymentAccount(%q) = %q, want %q", method, got, want)
+		}
+		if _, ok := DefaultAccounts[paymentAccount(method)]; !ok {
+			t.Errorf("%q has no default account", paymentAccount(method))
+		}
+	}
+}
+
+func TestDefaultAccountsCoverExpenseCategories(t *testing.T) {
+	for _, c := range ExpenseCategories {
+		if _, ok := DefaultAccounts["expense."+c]; !ok {
+			t.Errorf("expense category %s has no default account", c)
+		}
+	}
+}
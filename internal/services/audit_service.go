@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+)
+
+// auditChainLock is the advisory lock key serialising appends to the hash chain
+const auditChainLock = 7_231_001
+
+// genesisHash is the prev_hash of the first audit event
+var genesisHash = strings.Repeat("0", 64)
+
+type AuditService struct {
+	DB *database.Database
+}
+
+func NewAuditService(db *database.Database) *AuditService {
+	return &AuditService{DB: db}
+}
+
+// AuditEvent is a stored audit row
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	ActorID    *int            `json:"actor_id"`
+	ActorRole  string          `json:"actor_role"`
+	RequestID  string          `json:"request_id"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	LandlordID *int            `json:"landlord_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	IPAddress  string          `json:"ip_address"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// AuditFilter narrows List results. Zero values are ignored.
+type AuditFilter struct {
+	LandlordID *int // scope to a landlord's data (or their own actions)
+	ActorID    *int
+	Action     string
+	EntityType string
+	EntityID   string
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
+
+// Record appends an entry to the hash chain. Appends are serialised with a
+// transaction-scoped advisory lock so each row links to its true predecessor.
+func (s *AuditService) Record(ctx context.Context, e models.AuditEntry) error {
+	before, err := canonicalJSON(e.Before)
+	if err != nil {
+		return fmt.Errorf("audit: encode before: %w", err)
+	}
+	after, err := canonicalJSON(e.After)
+	if err != nil {
+		return fmt.Errorf("audit: encode after: %w", err)
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", auditChainLock); err != nil {
+		return err
+	}
+
+	prevHash := genesisHash
+	err = tx.QueryRowContext(ctx, "SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1").Scan(&prevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	ev := AuditEvent{
+		OccurredAt: time.Now().UTC().Truncate(time.Microsecond), // Postgres precision
+		ActorID:    e.ActorID,
+		ActorRole:  e.ActorRole,
+		RequestID:  e.RequestID,
+		Action:     e.Action,
+		EntityType: e.EntityType,
+		EntityID:   e.EntityID,
+		LandlordID: e.LandlordID,
+		Before:     before,
+		After:      after,
+		IPAddress:  e.IPAddress,
+		PrevHash:   prevHash,
+	}
+	ev.Hash = auditHash(ev)
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_events (occurred_at, actor_id, actor_role, request_id, action, entity_type,
+			entity_id, landlord_id, before_data, after_data, ip_address, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		ev.OccurredAt, ev.ActorID, nullString(ev.ActorRole), nullString(ev.RequestID), ev.Action, ev.EntityType,
+		nullString(ev.EntityID), ev.LandlordID, nullJSON(ev.Before), nullJSON(ev.After), nullString(ev.IPAddress),
+		ev.PrevHash, ev.Hash,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// List returns audit events matching the filter, newest first
+func (s *AuditService) List(ctx context.Context, f AuditFilter) ([]AuditEvent, error) {
+	events := []AuditEvent{}
+	err := s.Each(ctx, f, func(ev AuditEvent) error {
+		events = append(events, ev)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Each calls fn with each audit event matching the filter, newest first, as
+// rows are read, so exports do not hold the whole log in memory. An error
+// from fn stops the iteration and is returned.
+func (s *AuditService) Each(ctx context.Context, f AuditFilter, fn func(AuditEvent) error) error {
+	query := `
+		SELECT id, occurred_at, actor_id, actor_role, request_id, action, entity_type, entity_id,
+			landlord_id, before_data, after_data, ip_address, prev_hash, hash
+		FROM audit_events
+		WHERE TRUE`
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if f.LandlordID != nil {
+		p := arg(*f.LandlordID)
+		query += " AND (landlord_id = " + p + " OR actor_id = " + p + ")"
+	}
+	if f.ActorID != nil {
+		query += " AND actor_id = " + arg(*f.ActorID)
+	}
+	if f.Action != "" {
+		query += " AND action = " + arg(f.Action)
+	}
+	if f.EntityType != "" {
+		query += " AND entity_type = " + arg(f.EntityType)
+	}
+	if f.EntityID != "" {
+		query += " AND entity_id = " + arg(f.EntityID)
+	}
+	if !f.From.IsZero() {
+		query += " AND occurred_at >= " + arg(f.From)
+	}
+	if !f.To.IsZero() {
+		query += " AND occurred_at < " + arg(f.To)
+	}
+	query += " ORDER BY id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT " + arg(f.Limit) + " OFFSET " + arg(f.Offset)
+	}
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		ev, err := scanAuditEvent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// AuditVerification is the outcome of walking the hash chain
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`
+	BrokenAt *int64 `json:"broken_at,omitempty"` // first event whose hash or link does not match
+}
+
+// Verify recomputes every hash in order and reports the first broken link
+func (s *AuditService) Verify(ctx context.Context) (AuditVerification, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, occurred_at, actor_id, actor_role, request_id, action, entity_type, entity_id,
+			landlord_id, before_data, after_data, ip_address, prev_hash, hash
+		FROM audit_events
+		ORDER BY id`)
+	if err != nil {
+		return AuditVerification{}, err
+	}
+	defer rows.Close()
+
+	result := AuditVerification{Valid: true}
+	prevHash := genesisHash
+	for rows.Next() {
+		ev, err := scanAuditEvent(rows)
+		if err != nil {
+			return AuditVerification{}, err
+		}
+		result.Checked++
+		if ev.PrevHash != prevHash || auditHash(ev) != ev.Hash {
+			id := ev.ID
+			result.Valid = false
+			result.BrokenAt = &id
+			return result, nil
+		}
+		prevHash = ev.Hash
+	}
+	return result, rows.Err()
+}
+
+func scanAuditEvent(rows *sql.Rows) (AuditEvent, error) {
+	var ev AuditEvent
+	var actorID, landlordID sql.NullInt64
+	var actorRole, requestID, entityID, ip sql.NullString
+	var before, after []byte
+	err := rows.Scan(&ev.ID, &ev.OccurredAt, &actorID, &actorRole, &requestID, &ev.Action, &ev.EntityType,
+		&entityID, &landlordID, &before, &after, &ip, &ev.PrevHash, &ev.Hash)
+	if err != nil {
+		return ev, err
+	}
+	ev.OccurredAt = ev.OccurredAt.UTC()
+	if actorID.Valid {
+		v := int(actorID.Int64)
+		ev.ActorID = &v
+	}
+	if landlordID.Valid {
+		v := int(landlordID.Int64)
+		ev.LandlordID = &v
+	}
+	ev.ActorRole = actorRole.String
+	ev.RequestID = requestID.String
+	ev.EntityID = entityID.String
+	ev.IPAddress = ip.String
+	// JSONB does not preserve formatting; hash the canonical form
+	if ev.Before, err = canonicalJSON(json.RawMessage(before)); err != nil {
+		return ev, err
+	}
+	if ev.After, err = canonicalJSON(json.RawMessage(after)); err != nil {
+		return ev, err
+	}
+	return ev, nil
+}
+
+// auditHash links an event to its predecessor
+func auditHash(ev AuditEvent) string {
+	intOrEmpty := func(v *int) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.Itoa(*v)
+	}
+	fields := []string{
+		ev.PrevHash,
+		ev.OccurredAt.UTC().Format(time.RFC3339Nano),
+		intOrEmpty(ev.ActorID),
+		ev.ActorRole,
+		ev.RequestID,
+		ev.Action,
+		ev.EntityType,
+		ev.EntityID,
+		intOrEmpty(ev.LandlordID),
+		string(ev.Before),
+		string(ev.After),
+		ev.IPAddress,
+	}
+	h := sha256.New()
+	for _, f := range fields {
+		// length-prefix each field so boundaries cannot be shifted
+		fmt.Fprintf(h, "%d:%s|", len(f), f)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// canonicalJSON encodes v with sorted keys and no insignificant whitespace,
+// so the hash is stable across a JSONB round trip. nil encodes to nil.
+func canonicalJSON(v interface{}) (json.RawMessage, error) {
+	var raw []byte
+	switch t := v.(type) {
+	case nil:
+		return nil, nil
+	case json.RawMessage:
+		raw = t
+	case []byte:
+		raw = t
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		raw = b
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, err
+	}
+	return json.Marshal(decoded)
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullJSON(b json.RawMessage) interface{} {
+	if b == nil {
+		return nil
+	}
+	return string(b)
+}
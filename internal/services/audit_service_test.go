@@ -0,0 +1,89 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func auditChain(t *testing.T, n int) []AuditEvent {
+	t.Helper()
+	landlord := 7
+	prev := genesisHash
+	var chain []AuditEvent
+	for i := 0; i < n; i++ {
+		after, err := canonicalJSON(map[string]interface{}{"rent": 15000 + i, "tenant_name": "Jane"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ev := AuditEvent{
+			ID:         int64(i + 1),
+			OccurredAt: time.Date(2026, 10, 18, 9, 0, i, 0, time.UTC),
+			ActorID:    &landlord,
+			Action:     "tenant.update",
+			EntityType: "tenant",
+			EntityID:   "42",
+			LandlordID: &landlord,
+			After:      after,
+			PrevHash:   prev,
+		}
+		ev.Hash = auditHash(ev)
+		prev = ev.Hash
+		chain = append(chain, ev)
+	}
+	return chain
+}
+
+func TestAuditHashChain(t *testing.T) {
+	chain := auditChain(t, 3)
+	for i, ev := range chain {
+		if auditHash(ev) != ev.Hash || (i > 0 && ev.PrevHash != chain[i-1].Hash) {
+			t.Fatalf("event %d does not link to its predecessor", ev.ID)
+		}
+	}
+
+	// Any edited field breaks the event's hash
+	edits := map[string]func(*AuditEvent){
+		"action":      func(ev *AuditEvent) { ev.Action = "tenant.delete" },
+		"entity":      func(ev *AuditEvent) { ev.EntityID = "43" },
+		"time":        func(ev *AuditEvent) { ev.OccurredAt = ev.OccurredAt.Add(time.Microsecond) },
+		"actor":       func(ev *AuditEvent) { ev.ActorID = nil },
+		"after":       func(ev *AuditEvent) { ev.After = json.RawMessage(`{"rent":1}`) },
+		"predecessor": func(ev *AuditEvent) { ev.PrevHash = genesisHash },
+	}
+	for name, edit := range edits {
+		ev := chain[1]
+		edit(&ev)
+		if auditHash(ev) == chain[1].Hash {
+			t.Errorf("editing %s keeps the hash", name)
+		}
+	}
+
+	// Field boundaries cannot be shifted between fields
+	a, b := chain[0], chain[0]
+	a.ActorRole, a.RequestID = "land", "lordreq"
+	b.ActorRole, b.RequestID = "landlord", "req"
+	if auditHash(a) == auditHash(b) {
+		t.Error("shifting text between fields keeps the hash")
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	// JSONB reorders keys and drops whitespace; the hash input must not change
+	x, err := canonicalJSON(json.RawMessage(`{ "b": 1, "a": {"d": [1, 2], "c": null} }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := canonicalJSON(map[string]interface{}{"a": map[string]interface{}{"c": nil, "d": []int{1, 2}}, "b": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(x) != string(y) || string(x) != `{"a":{"c":null,"d":[1,2]},"b":1}` {
+		t.Errorf("canonical forms %s and %s", x, y)
+	}
+	for _, v := range []interface{}{nil, json.RawMessage(nil), json.RawMessage("null")} {
+		if got, err := canonicalJSON(v); err != nil || got != nil {
+			t.Errorf("canonicalJSON(%v) = %s, %v; want nil", v, got, err)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/events"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/lib/pq"
+)
+
+// trendMonths is how many months the collection trend covers, this one included
+const trendMonths = 12
+
+// arrearsBuckets are the aging buckets of the dashboard, by days owed.
+// A max of 0 is open-ended.
+var arrearsBuckets = []struct {
+	label    string
+	min, max int
+}{
+	{"0-30", 0, 30},
+	{"31-60", 31, 60},
+	{"61-90", 61, 90},
+	{"90+", 91, 0},
+}
+
+// DashboardService computes the landlord dashboard. Summaries are cached per
+// user for TTL and dropped early when a payment or tenant event arrives for
+// one of their landlords.
+type DashboardService struct {
+	DB  *database.Database
+	TTL time.Duration // 0 disables the cache
+
+	mu    sync.Mutex
+	cache map[dashboardKey]dashboardEntry
+}
+
+type dashboardKey struct {
+	userID, propertyID int
+}
+
+type dashboardEntry struct {
+	summary   models.DashboardSummary
+	landlords map[int]bool
+	expires   time.Time
+}
+
+func NewDashboardService(db *database.Database, ttl time.Duration) *DashboardService {
+	return &DashboardService{DB: db, TTL: ttl, cache: map[dashboardKey]dashboardEntry{}}
+}
+
+// Summary returns the dashboard over the properties the user can read
+// payments of, or one of them when propertyID is set. refresh bypasses the
+// cache.
+func (s *DashboardService) Summary(ctx context.Context, userID, propertyID int, refresh bool) (models.DashboardSummary, error) {
+	key := dashboardKey{userID, propertyID}
+	now := time.Now()
+	if s.TTL > 0 && !refresh {
+		s.mu.Lock()
+		entry, ok := s.cache[key]
+		s.mu.Unlock()
+		if ok && now.Before(entry.expires) {
+			summary := entry.summary
+			summary.Cached = true
+			return summary, nil
+		}
+	}
+
+	summary, landlords, err := s.build(ctx, userID, propertyID, now)
+	if err != nil {
+		return summary, err
+	}
+	if s.TTL > 0 {
+		s.mu.Lock()
+		for k, e := range s.cache {
+			if !now.Before(e.expires) {
+				delete(s.cache, k)
+			}
+		}
+		s.cache[key] = dashboardEntry{summary: summary, landlords: landlords, expires: now.Add(s.TTL)}
+		s.mu.Unlock()
+	}
+	return summary, nil
+}
+
+// HandleEvent drops cached summaries covering the event's landlord when its
+// payments or tenants change
+func (s *DashboardService) HandleEvent(ctx context.Context, e events.Event) {
+	switch e.Type {
+	case events.PaymentCompleted, events.PaymentUnmatched, events.PaymentMatched, events.TenantCreated:
+	default:
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, entry := range s.cache {
+		if entry.landlords[e.LandlordID] {
+			delete(s.cache, k)
+		}
+	}
+}
+
+func (s *DashboardService) build(ctx context.Context, userID, propertyID int, now time.Time) (models.DashboardSummary, map[int]bool, error) {
+	local := now.In(reminderZone)
+	today := dateOf(local)
+	periodStart := time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, reminderZone)
+	periodEnd := periodStart.AddDate(0, 1, 0)
+
+	summary := models.DashboardSummary{
+		GeneratedAt:     now.UTC(),
+		PeriodStart:     periodStart.Format(dateLayout),
+		PeriodEnd:       periodEnd.AddDate(0, 0, -1).Format(dateLayout),
+		Properties:      []models.PropertyDashboard{},
+		Arrears:         make([]models.ArrearsBucket, len(arrearsBuckets)),
+		CollectionTrend: make([]models.MonthlyCollection, trendMonths),
+	}
+	for i, b := range arrearsBuckets {
+		summary.Arrears[i] = models.ArrearsBucket{Label: b.label, MinDays: b.min}
+		if b.max > 0 {
+			max := b.max
+			summary.Arrears[i].MaxDays = &max
+		}
+	}
+	// Month boundaries of the trend, oldest first; trendMonths+1 of them
+	bounds := make([]string, trendMonths+1)
+	for i := range bounds {
+		month := periodStart.AddDate(0, i-trendMonths+1, 0)
+		bounds[i] = month.Format(time.RFC3339)
+		if i < trendMonths {
+			summary.CollectionTrend[i].Month = month.Format("2006-01")
+		}
+	}
+
+	// Properties with their unit occupancy
+	var args queryArgs
+	query := `
+		SELECT pr.id, pr.landlord_id, pr.title, u.units, u.occupied
+		FROM properties pr
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) AS units, COUNT(*) FILTER (WHERE NOT vacancy) AS occupied
+			FROM units WHERE property_id = pr.id
+		) u
+		WHERE pr.id IN (SELECT accessible_property_ids(` + args.add(userID) + `, ` + args.add(string(permissions.PaymentsRead)) + `))`
+	if propertyID != 0 {
+		query += " AND pr.id = " + args.add(propertyID)
+	}
+	query += " ORDER BY pr.title, pr.id"
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return summary, nil, err
+	}
+	landlords := map[int]bool{}
+	index := map[int]int{}
+	var ids, landlordIDs []int64
+	for rows.Next() {
+		var p models.PropertyDashboard
+		var landlordID int
+		if err := rows.Scan(&p.PropertyID, &landlordID, &p.PropertyTitle, &p.Units, &p.OccupiedUnits); err != nil {
+			rows.Close()
+			return summary, nil, err
+		}
+		if !landlords[landlordID] {
+			landlords[landlordID] = true
+			landlordIDs = append(landlordIDs, int64(landlordID))
+		}
+		index[int(p.PropertyID)] = len(summary.Properties)
+		ids = append(ids, int64(p.PropertyID))
+		summary.Properties = append(summary.Properties, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return summary, nil, err
+	}
+	if len(ids) == 0 {
+		if propertyID != 0 {
+			return summary, nil, ErrPropertyNotFound
+		}
+		return summary, landlords, nil
+	}
+
+	// Expected rent of current tenants and rent collected this month, per property
+	rows, err = s.DB.QueryContext(ctx, `
+		SELECT u.property_id,
+		       COALESCE(SUM(t.rent), 0),
+		       COALESCE((
+		           SELECT SUM(p.amount)
+		           FROM payments p
+		           JOIN tenants pt ON p.tenant_id = pt.id
+		           JOIN units pu ON pt.unit_id = pu.id
+		           WHERE pu.property_id = u.property_id AND p.status = 'COMPLETED'
+		             AND p.created_at >= $2 AND p.created_at < $3
+		       ), 0)
+		FROM units u
+		LEFT JOIN tenants t ON t.unit_id = u.id
+		WHERE u.property_id = ANY($1)
+		GROUP BY u.property_id`,
+		pq.Array(ids), periodStart, periodEnd,
+	)
+	if err != nil {
+		return summary, nil, err
+	}
+	for rows.Next() {
+		var id int
+		var expected, collected float64
+		if err := rows.Scan(&id, &expected, &collected); err != nil {
+			rows.Close()
+			return summary, nil, err
+		}
+		p := &summary.Properties[index[id]]
+		p.ExpectedRent, p.CollectedRent = expected, collected
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return summary, nil, err
+	}
+
+	for i := range summary.Properties {
+		p := &summary.Properties[i]
+		p.OccupancyRate = percent(float64(p.OccupiedUnits), float64(p.Units))
+		p.CollectionRate = percent(p.CollectedRent, p.ExpectedRent)
+		p.ExpectedRent, p.CollectedRent = roundTo(p.ExpectedRent, 2), roundTo(p.CollectedRent, 2)
+		summary.Units += p.Units
+		summary.OccupiedUnits += p.OccupiedUnits
+		summary.ExpectedRent += p.ExpectedRent
+		summary.CollectedRent += p.CollectedRent
+	}
+	summary.OccupancyRate = percent(float64(summary.OccupiedUnits), float64(summary.Units))
+	summary.CollectionRate = percent(summary.CollectedRent, summary.ExpectedRent)
+	summary.ExpectedRent, summary.CollectedRent = roundTo(summary.ExpectedRent, 2), roundTo(summary.CollectedRent, 2)
+
+	// Arrears aging
+	rows, err = s.DB.QueryContext(ctx, `
+		WITH arrears AS (
+			SELECT t.balance, $2::DATE - `+owedSinceSQL("t.balance", "$2::DATE")+` AS days
+			FROM tenants t
+			JOIN units u ON t.unit_id = u.id
+			WHERE u.property_id = ANY($1) AND t.balance > 0
+		)
+		SELECT `+arrearsBucketSQL("days")+` AS bucket, COUNT(*), SUM(balance)
+		FROM arrears
+		GROUP BY bucket`,
+		pq.Array(ids), today.Format(dateLayout),
+	)
+	if err != nil {
+		return summary, nil, err
+	}
+	for rows.Next() {
+		var bucket, tenants int
+		var amount float64
+		if err := rows.Scan(&bucket, &tenants, &amount); err != nil {
+			rows.Close()
+			return summary, nil, err
+		}
+		summary.Arrears[bucket].Tenants = tenants
+		summary.Arrears[bucket].Amount = roundTo(amount, 2)
+		summary.TotalArrears += amount
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return summary, nil, err
+	}
+	summary.TotalArrears = roundTo(summary.TotalArrears, 2)
+
+	// Payments waiting to be matched to a tenant, of the landlords in view
+	err = s.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(amount), 0) FROM payments
+		WHERE tenant_id IS NULL AND landlord_id = ANY($1) AND status <> 'FAILED'`,
+		pq.Array(landlordIDs),
+	).Scan(&summary.UnmatchedPayments, &summary.UnmatchedAmount)
+	if err != nil {
+		return summary, nil, err
+	}
+	summary.UnmatchedAmount = roundTo(summary.UnmatchedAmount, 2)
+
+	// Collections per month; width_bucket numbers the months from 1
+	rows, err = s.DB.QueryContext(ctx, `
+		SELECT width_bucket(p.created_at, $2::TIMESTAMPTZ[]), SUM(p.amount), COUNT(*)
+		FROM payments p
+		JOIN tenants t ON p.tenant_id = t.id
+		JOIN units u ON t.unit_id = u.id
+		WHERE u.property_id = ANY($1) AND p.status = 'COMPLETED'
+		  AND p.created_at >= $3 AND p.created_at < $4
+		GROUP BY 1`,
+		pq.Array(ids), pq.Array(bounds), bounds[0], bounds[trendMonths],
+	)
+	if err != nil {
+		return summary, nil, err
+	}
+	for rows.Next() {
+		var bucket, count int
+		var amount float64
+		if err := rows.Scan(&bucket, &amount, &count); err != nil {
+			rows.Close()
+			return summary, nil, err
+		}
+		if bucket < 1 || bucket > trendMonths {
+			continue
+		}
+		summary.CollectionTrend[bucket-1].Collected = roundTo(amount, 2)
+		summary.CollectionTrend[bucket-1].Payments = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return summary, nil, err
+	}
+
+	return summary, landlords, nil
+}
+
+// owedSinceSQL is the SQL date from which the balance of tenant t has been
+// owed as of date (both SQL expressions). Balances carry no per-month
+// history, so a balance is taken to be the most recent months of rent: owed
+// since the last due date before date, a month earlier for each further
+// month of rent it covers, but never from before the tenancy started.
+func owedSinceSQL(balance, date string) string {
+	due := "(date_trunc('month', " + date + "::TIMESTAMP)::DATE + t.rent_due_day - 1)"
+	lastDue := "(CASE WHEN " + due + " < " + date + " THEN " + due + " ELSE (" + due + " - INTERVAL '1 month')::DATE END)"
+	return "GREATEST((" + lastDue + " - (GREATEST(CEIL(" + balance + " / NULLIF(t.rent, 0)), 1)::INT - 1) * INTERVAL '1 month')::DATE, " +
+		"(t.created_at AT TIME ZONE 'Africa/Nairobi')::DATE)"
+}
+
+// arrearsBucketSQL numbers the arrears bucket of days (an SQL expression)
+// from 0, in the order of arrearsBuckets
+func arrearsBucketSQL(days string) string {
+	sql := "CASE"
+	for i, b := range arrearsBuckets {
+		if b.max > 0 {
+			sql += fmt.Sprintf(" WHEN %s <= %d THEN %d", days, b.max, i)
+		}
+	}
+	return sql + fmt.Sprintf(" ELSE %d END", len(arrearsBuckets)-1)
+}
+
+// arrearsBucket returns the label of the bucket of days owed
+func arrearsBucket(days int) string {
+	for _, b := range arrearsBuckets {
+		if b.max == 0 || days <= b.max {
+			return b.label
+		}
+	}
+	return ""
+}
+
+// percent returns part as a percentage of whole, to one decimal; 0 when
+// whole is 0
+func percent(part, whole float64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return roundTo(part/whole*100, 1)
+}
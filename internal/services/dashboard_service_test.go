@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/events"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+)
+
+func TestArrearsBucket(t *testing.T) {
+	for days, want := range map[int]string{0: "0-30", 30: "0-30", 31: "31-60", 60: "31-60", 61: "61-90", 90: "61-90", 91: "90+", 400: "90+"} {
+		if got := arrearsBucket(days); got != want {
+			t.Errorf("arrearsBucket(%d) = %q, want %q", days, got, want)
+		}
+	}
+	want := "CASE WHEN d <= 30 THEN 0 WHEN d <= 60 THEN 1 WHEN d <= 90 THEN 2 ELSE 3 END"
+	if got := arrearsBucketSQL("d"); got != want {
+		t.Errorf("arrearsBucketSQL = %q, want %q", got, want)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		part, whole, want float64
+	}{
+		{45000, 60000, 75},
+		{1, 3, 33.3},
+		{2, 3, 66.7},
+		{5, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := percent(tt.part, tt.whole); got != tt.want {
+			t.Errorf("percent(%v, %v) = %v, want %v", tt.part, tt.whole, got, tt.want)
+		}
+	}
+}
+
+func TestDashboardCache(t *testing.T) {
+	s := NewDashboardService(nil, time.Minute)
+	mine := dashboardKey{userID: 7}
+	staff := dashboardKey{userID: 9, propertyID: 3}
+	other := dashboardKey{userID: 8}
+	expires := time.Now().Add(time.Minute)
+	s.cache[mine] = dashboardEntry{summary: models.DashboardSummary{PeriodStart: "2026-10-01"}, landlords: map[int]bool{7: true}, expires: expires}
+	s.cache[staff] = dashboardEntry{landlords: map[int]bool{7: true, 12: true}, expires: expires}
+	s.cache[other] = dashboardEntry{landlords: map[int]bool{8: true}, expires: expires}
+
+	// A fresh entry is served without touching the database
+	summary, err := s.Summary(context.Background(), 7, 0, false)
+	if err != nil || !summary.Cached || summary.PeriodStart != "2026-10-01" {
+		t.Fatalf("cached summary = %+v, %v", summary, err)
+	}
+
+	// Events outside payments and tenants keep the cache
+	s.HandleEvent(context.Background(), events.New(events.InvoiceOverdue, 7, nil))
+	if len(s.cache) != 3 {
+		t.Fatalf("%s dropped cache entries", events.InvoiceOverdue)
+	}
+	// A payment for landlord 7 drops every summary covering them
+	s.HandleEvent(context.Background(), events.New(events.PaymentCompleted, 7, nil))
+	if _, ok := s.cache[mine]; ok {
+		t.Error("landlord's own summary kept")
+	}
+	if _, ok := s.cache[staff]; ok {
+		t.Error("staff summary covering the landlord kept")
+	}
+	if _, ok := s.cache[other]; !ok {
+		t.Error("another landlord's summary dropped")
+	}
+}
@@ -0,0 +1,574 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/notify"
+)
+
+// Email templates
+const (
+	TemplateEmailReceipt   = "receipt"
+	TemplateEmailStatement = "statement"
+)
+
+// statementSendDays is how many days into a month last month's statements are sent
+const statementSendDays = 3
+
+var (
+	ErrNoTenantEmail = errors.New("tenant has no email address or opted out of email")
+	ErrInvalidMonth  = errors.New("month must be formatted as YYYY-MM")
+	ErrInvalidRange  = errors.New("from and to must be YYYY-MM-DD with from on or before to")
+)
+
+//go:embed templates/email
+var emailTemplateFS embed.FS
+
+var emailFuncs = map[string]interface{}{
+	"money":    documents.FormatMoney,
+	"period":   documents.FormatPeriod,
+	"lower":    strings.ToLower,
+	"date":     func(t time.Time) string { return t.Format("2 Jan 2006") },
+	"datetime": func(t time.Time) string { return t.In(reminderZone).Format("2 Jan 2006 15:04") },
+}
+
+var (
+	emailHTMLTemplates = map[string]*htmltemplate.Template{}
+	emailTextTemplates = map[string]*template.Template{}
+)
+
+func init() {
+	for _, name := range []string{TemplateEmailReceipt, TemplateEmailStatement} {
+		emailHTMLTemplates[name] = htmltemplate.Must(htmltemplate.New(name).Funcs(emailFuncs).
+			ParseFS(emailTemplateFS, "templates/email/layout.html", "templates/email/"+name+".html"))
+		emailTextTemplates[name] = template.Must(template.New(name+".txt").Funcs(emailFuncs).
+			ParseFS(emailTemplateFS, "templates/email/"+name+".txt"))
+	}
+}
+
+// EmailData is the data available to email templates
+type EmailData struct {
+	Subject       string
+	RecipientName string
+	IssuedBy      string
+	Receipt       *documents.Receipt
+	Statement     *documents.Statement
+}
+
+// RenderEmail executes a named template, returning the text and HTML bodies
+func RenderEmail(name string, data EmailData) (string, string, error) {
+	htmlTmpl, ok := emailHTMLTemplates[name]
+	if !ok {
+		return "", "", fmt.Errorf("unknown email template %q", name)
+	}
+	var text, html bytes.Buffer
+	if err := emailTextTemplates[name].Execute(&text, data); err != nil {
+		return "", "", err
+	}
+	if err := htmlTmpl.ExecuteTemplate(&html, "layout", data); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
+
+// emailJob is an email to queue
+type emailJob struct {
+	LandlordID  int
+	TenantID    *int
+	UserID      *int
+	Template    string
+	To          string
+	Data        EmailData
+	Reference   string // deduplicates the email when set
+	Attachments []notify.Attachment
+}
+
+// queueEmail renders and stores an email with its attachments. It reports
+// whether a new row was created (false when the reference was already used).
+func (s *NotificationService) queueEmail(ctx context.Context, job emailJob) (bool, error) {
+	text, html, err := RenderEmail(job.Template, job.Data)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO notifications (landlord_id, tenant_id, user_id, channel, template, recipient, subject, body, html_body, reference)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''))
+		ON CONFLICT (channel, reference) WHERE reference IS NOT NULL DO NOTHING
+		RETURNING id`,
+		job.LandlordID, job.TenantID, job.UserID, notify.ChannelEmail, job.Template, job.To, job.Data.Subject, text, html, job.Reference,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, a := range job.Attachments {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO notification_attachments (notification_id, filename, content_type, data)
+			VALUES ($1, $2, $3, $4)`,
+			id, a.Filename, a.ContentType, a.Data,
+		)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// emailQueued reports whether an email with the reference exists, so
+// scheduled runs can skip rendering documents that were already sent
+func (s *NotificationService) emailQueued(ctx context.Context, reference string) (bool, error) {
+	var exists bool
+	err := s.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM notifications WHERE channel = $1 AND reference = $2)",
+		notify.ChannelEmail, reference).Scan(&exists)
+	return exists, err
+}
+
+func (s *NotificationService) loadAttachments(ctx context.Context, notificationID int64) ([]notify.Attachment, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT filename, content_type, data FROM notification_attachments WHERE notification_id = $1 ORDER BY id", notificationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []notify.Attachment
+	for rows.Next() {
+		var a notify.Attachment
+		if err := rows.Scan(&a.Filename, &a.ContentType, &a.Data); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
+
+// --- User preferences ---
+
+// GetPreferences returns a user's email preferences
+func (s *NotificationService) GetPreferences(ctx context.Context, userID int) (models.NotificationPreferences, error) {
+	var prefs models.NotificationPreferences
+	err := s.DB.QueryRowContext(ctx, "SELECT notification_preferences FROM users WHERE id = $1", userID).Scan(&prefs)
+	return prefs, err
+}
+
+// SavePreferences stores a user's email preferences
+func (s *NotificationService) SavePreferences(ctx context.Context, userID int, prefs models.NotificationPreferences) error {
+	_, err := s.DB.ExecContext(ctx, "UPDATE users SET notification_preferences = $1, updated_at = NOW() WHERE id = $2", prefs, userID)
+	return err
+}
+
+// --- Receipts ---
+
+// queueReceiptEmails emails a PDF receipt to the tenant (when they have an
+// email address) and a copy to the landlord (when their preferences allow)
+func (s *NotificationService) queueReceiptEmails(ctx context.Context, paymentID int64) error {
+	r, landlordID, err := paymentReceipt(ctx, s.DB, s.Cfg.Receipts.VerifyURL, paymentID)
+	if err == ErrNoReceipt {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var tenantID int
+	var tenantEmail, landlordEmail, landlordName string
+	var tenantOptOut bool
+	var prefs models.NotificationPreferences
+	err = s.DB.QueryRowContext(ctx, `
+		SELECT t.id, COALESCE(t.email, ''), t.email_opt_out, l.email, COALESCE(l.full_name, ''), l.notification_preferences
+		FROM payments p
+		JOIN tenants t ON p.tenant_id = t.id
+		JOIN users l ON p.landlord_id = l.id
+		WHERE p.id = $1`, paymentID,
+	).Scan(&tenantID, &tenantEmail, &tenantOptOut, &landlordEmail, &landlordName, &prefs)
+	if err != nil {
+		return err
+	}
+
+	pdf, err := documents.ReceiptPDF(r)
+	if err != nil {
+		return err
+	}
+	attachments := []notify.Attachment{{Filename: "receipt-" + r.Number + ".pdf", ContentType: documents.ContentTypePDF, Data: pdf}}
+	subject := fmt.Sprintf("Payment receipt %s - KES %s", r.Number, documents.FormatMoney(r.Amount))
+
+	if tenantEmail != "" && !tenantOptOut {
+		_, err := s.queueEmail(ctx, emailJob{
+			LandlordID:  landlordID,
+			TenantID:    &tenantID,
+			Template:    TemplateEmailReceipt,
+			To:          tenantEmail,
+			Data:        EmailData{Subject: subject, RecipientName: r.TenantName, IssuedBy: r.IssuedBy, Receipt: &r},
+			Reference:   fmt.Sprintf("receipt:%d:tenant", paymentID),
+			Attachments: attachments,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if prefs.EmailPaymentReceipts {
+		_, err := s.queueEmail(ctx, emailJob{
+			LandlordID:  landlordID,
+			TenantID:    &tenantID,
+			UserID:      &landlordID,
+			Template:    TemplateEmailReceipt,
+			To:          landlordEmail,
+			Data:        EmailData{Subject: subject, RecipientName: firstNonEmpty(landlordName, landlordEmail), IssuedBy: r.IssuedBy, Receipt: &r},
+			Reference:   fmt.Sprintf("receipt:%d:landlord", paymentID),
+			Attachments: attachments,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --- Statements ---
+
+// statementRecipient is who a tenant statement is about and sent to
+type statementRecipient struct {
+	TenantID     int
+	LandlordID   int
+	TenantName   string
+	Email        string
+	OptedOut     bool
+	Balance      float64
+	UnitName     string
+	PropertyName string
+	IssuedBy     string
+}
+
+// TenantStatement builds the tenant's statement of payments for [start, end)
+func (s *NotificationService) TenantStatement(ctx context.Context, tenantID int, start, end time.Time) (documents.Statement, error) {
+	st, _, err := s.tenantStatement(ctx, tenantID, start, end)
+	return st, err
+}
+
+func (s *NotificationService) tenantStatement(ctx context.Context, tenantID int, start, end time.Time) (documents.Statement, statementRecipient, error) {
+	var r statementRecipient
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT t.id, t.landlord_id, t.tenant_name, COALESCE(t.email, ''), t.email_opt_out, COALESCE(t.balance, 0),
+			u.unit_name, p.title, COALESCE(l.full_name, '')
+		FROM tenants t
+		JOIN units u ON t.unit_id = u.id
+		JOIN properties p ON u.property_id = p.id
+		JOIN users l ON t.landlord_id = l.id
+		WHERE t.id = $1`, tenantID,
+	).Scan(&r.TenantID, &r.LandlordID, &r.TenantName, &r.Email, &r.OptedOut, &r.Balance, &r.UnitName, &r.PropertyName, &r.IssuedBy)
+	if err != nil {
+		return documents.Statement{}, r, err
+	}
+	branding, businessName, err := landlordLetterhead(ctx, s.DB, r.LandlordID)
+	if err != nil {
+		return documents.Statement{}, r, err
+	}
+	r.IssuedBy = firstNonEmpty(businessName, r.IssuedBy, r.PropertyName)
+
+	lines, total, err := s.statementLines(ctx, `
+		SELECT p.created_at, p.method, COALESCE(p.receipt, ''), p.amount
+		FROM payments p
+		WHERE p.tenant_id = $1 AND p.status = 'COMPLETED' AND p.created_at >= $2 AND p.created_at < $3
+		ORDER BY p.created_at`, tenantID, start, end)
+	if err != nil {
+		return documents.Statement{}, r, err
+	}
+	for i := range lines {
+		lines[i].Description = "Rent payment (" + lines[i].Description + ")"
+	}
+
+	return documents.Statement{
+		Title:       "Tenant statement",
+		IssuedBy:    r.IssuedBy,
+		Branding:    branding,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Details: []documents.Field{
+			{Label: "Tenant", Value: r.TenantName},
+			{Label: "Unit", Value: r.UnitName + ", " + r.PropertyName},
+		},
+		Lines: lines,
+		Total: total,
+		Summary: []documents.Field{
+			{Label: "Balance as at " + time.Now().In(reminderZone).Format("2 Jan 2006"), Value: "KES " + documents.FormatMoney(r.Balance)},
+		},
+	}, r, nil
+}
+
+// StatementPeriod returns the [start, end) bounds of a "YYYY-MM" month in
+// local time, defaulting to the month before now when month is empty
+func StatementPeriod(month string, now time.Time) (time.Time, time.Time, error) {
+	var start time.Time
+	if month == "" {
+		now = now.In(reminderZone)
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, reminderZone).AddDate(0, -1, 0)
+	} else {
+		t, err := time.ParseInLocation("2006-01", month, reminderZone)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidMonth
+		}
+		start = t
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// StatementRange returns the [start, end) bounds of the days from and to
+// (YYYY-MM-DD, inclusive) in local time
+func StatementRange(from, to string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation(dateLayout, from, reminderZone)
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidRange
+	}
+	last, err := time.ParseInLocation(dateLayout, to, reminderZone)
+	if err != nil || last.Before(start) {
+		return time.Time{}, time.Time{}, ErrInvalidRange
+	}
+	return start, last.AddDate(0, 0, 1), nil
+}
+
+// LandlordStatement builds the landlord's collections statement for [start, end)
+func (s *NotificationService) LandlordStatement(ctx context.Context, landlordID int, start, end time.Time) (documents.Statement, error) {
+	var name, email string
+	err := s.DB.QueryRowContext(ctx, "SELECT COALESCE(full_name, ''), email FROM users WHERE id = $1", landlordID).Scan(&name, &email)
+	if err != nil {
+		return documents.Statement{}, err
+	}
+
+	lines, total, err := s.statementLines(ctx, `
+		SELECT p.created_at, t.tenant_name || ' - ' || u.unit_name || ', ' || pr.title, COALESCE(p.receipt, ''), p.amount
+		FROM payments p
+		JOIN tenants t ON p.tenant_id = t.id
+		JOIN units u ON t.unit_id = u.id
+		JOIN properties pr ON u.property_id = pr.id
+		WHERE p.landlord_id = $1 AND p.status = 'COMPLETED' AND p.created_at >= $2 AND p.created_at < $3
+		ORDER BY p.created_at`, landlordID, start, end)
+	if err != nil {
+		return documents.Statement{}, err
+	}
+
+	var unmatched int
+	err = s.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM payments
+		WHERE landlord_id = $1 AND tenant_id IS NULL AND created_at >= $2 AND created_at < $3`,
+		landlordID, start, end,
+	).Scan(&unmatched)
+	if err != nil {
+		return documents.Statement{}, err
+	}
+
+	return documents.Statement{
+		Title:       "Collections statement",
+		IssuedBy:    firstNonEmpty(name, email),
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Lines:       lines,
+		Total:       total,
+		Summary: []documents.Field{
+			{Label: "Payments received", Value: fmt.Sprint(len(lines))},
+			{Label: "Unmatched payments", Value: fmt.Sprint(unmatched)},
+		},
+	}, nil
+}
+
+func (s *NotificationService) statementLines(ctx context.Context, query string, args ...interface{}) ([]documents.StatementLine, float64, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	lines := []documents.StatementLine{}
+	var total float64
+	for rows.Next() {
+		var l documents.StatementLine
+		if err := rows.Scan(&l.Date, &l.Description, &l.Reference, &l.Amount); err != nil {
+			return nil, 0, err
+		}
+		l.Date = l.Date.In(reminderZone)
+		total += l.Amount
+		lines = append(lines, l)
+	}
+	return lines, total, rows.Err()
+}
+
+// EmailTenantStatement queues the tenant's statement for [start, end) to
+// their email address
+func (s *NotificationService) EmailTenantStatement(ctx context.Context, tenantID int, start, end time.Time) error {
+	_, err := s.queueTenantStatement(ctx, tenantID, start, end, "")
+	return err
+}
+
+func (s *NotificationService) queueTenantStatement(ctx context.Context, tenantID int, start, end time.Time, reference string) (bool, error) {
+	st, r, err := s.tenantStatement(ctx, tenantID, start, end)
+	if err != nil {
+		return false, err
+	}
+	if r.Email == "" || r.OptedOut {
+		return false, ErrNoTenantEmail
+	}
+	pdf, err := documents.StatementPDF(st)
+	if err != nil {
+		return false, err
+	}
+	return s.queueEmail(ctx, emailJob{
+		LandlordID: r.LandlordID,
+		TenantID:   &r.TenantID,
+		Template:   TemplateEmailStatement,
+		To:         r.Email,
+		Data: EmailData{
+			Subject:       "Your rent statement for " + start.Format("January 2006"),
+			RecipientName: r.TenantName,
+			IssuedBy:      r.IssuedBy,
+			Statement:     &st,
+		},
+		Reference:   reference,
+		Attachments: []notify.Attachment{{Filename: "statement-" + start.Format("2006-01") + ".pdf", ContentType: documents.ContentTypePDF, Data: pdf}},
+	})
+}
+
+// SendMonthlyStatements queues last month's statements during the first
+// days of a month: one per tenant with an email address, and one per
+// landlord who wants them. It returns how many emails were queued.
+func (s *NotificationService) SendMonthlyStatements(ctx context.Context, now time.Time) (int, error) {
+	if now.In(reminderZone).Day() > statementSendDays {
+		return 0, nil
+	}
+	start, end, _ := StatementPeriod("", now)
+	month := start.Format("2006-01")
+	queued := 0
+
+	tenantIDs, err := s.ids(ctx, "SELECT id FROM tenants WHERE COALESCE(email, '') <> '' AND NOT email_opt_out ORDER BY id")
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range tenantIDs {
+		reference := fmt.Sprintf("statement:tenant:%d:%s", id, month)
+		done, err := s.emailQueued(ctx, reference)
+		if err != nil {
+			return queued, err
+		}
+		if done {
+			continue
+		}
+		created, err := s.queueTenantStatement(ctx, id, start, end, reference)
+		if err != nil {
+			return queued, err
+		}
+		if created {
+			queued++
+		}
+	}
+
+	rows, err := s.DB.QueryContext(ctx, "SELECT id, email, COALESCE(full_name, ''), notification_preferences FROM users WHERE role = 'landlord' ORDER BY id")
+	if err != nil {
+		return queued, err
+	}
+	type landlord struct {
+		ID          int
+		Email, Name string
+		Prefs       models.NotificationPreferences
+	}
+	var landlords []landlord
+	for rows.Next() {
+		var l landlord
+		if err := rows.Scan(&l.ID, &l.Email, &l.Name, &l.Prefs); err != nil {
+			rows.Close()
+			return queued, err
+		}
+		if l.Prefs.EmailMonthlyStatements {
+			landlords = append(landlords, l)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return queued, err
+	}
+
+	for _, l := range landlords {
+		reference := fmt.Sprintf("statement:landlord:%d:%s", l.ID, month)
+		done, err := s.emailQueued(ctx, reference)
+		if err != nil {
+			return queued, err
+		}
+		if done {
+			continue
+		}
+		st, err := s.LandlordStatement(ctx, l.ID, start, end)
+		if err != nil {
+			return queued, err
+		}
+		pdf, err := documents.StatementPDF(st)
+		if err != nil {
+			return queued, err
+		}
+		landlordID := l.ID
+		created, err := s.queueEmail(ctx, emailJob{
+			LandlordID: l.ID,
+			UserID:     &landlordID,
+			Template:   TemplateEmailStatement,
+			To:         l.Email,
+			Data: EmailData{
+				Subject:       "Collections statement for " + start.Format("January 2006"),
+				RecipientName: firstNonEmpty(l.Name, l.Email),
+				Statement:     &st,
+			},
+			Reference:   reference,
+			Attachments: []notify.Attachment{{Filename: "collections-" + month + ".pdf", ContentType: documents.ContentTypePDF, Data: pdf}},
+		})
+		if err != nil {
+			return queued, err
+		}
+		if created {
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+func (s *NotificationService) ids(ctx context.Context, query string, args ...interface{}) ([]int, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+)
+
+func TestRenderEmailReceipt(t *testing.T) {
+	text, html, err := RenderEmail(TemplateEmailReceipt, EmailData{
+		Subject:       "Payment receipt",
+		RecipientName: "Jane <b>Wanjiru</b>",
+		IssuedBy:      "Kamau Flats",
+		Receipt: &documents.Receipt{
+			Number:       "RCT-000123",
+			Date:         time.Date(2026, time.October, 5, 7, 30, 0, 0, time.UTC),
+			TenantName:   "Jane Wanjiru",
+			PropertyName: "Kamau Flats",
+			UnitName:     "A4",
+			Amount:       15000,
+			Method:       "mpesa",
+			Reference:    "SJ12ABC",
+			Balance:      2500.5,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"RCT-000123", "5 Oct 2026 10:30", "A4, Kamau Flats", "mpesa (SJ12ABC)", "KES 15,000", "KES 2,500.50"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text body lacks %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(html, "<b>Wanjiru</b>") {
+		t.Error("HTML body does not escape the recipient name")
+	}
+	if !strings.Contains(html, "RCT-000123") {
+		t.Error("HTML body lacks the receipt number")
+	}
+
+	if _, _, err := RenderEmail("no_such_template", EmailData{}); err == nil {
+		t.Error("unknown template rendered")
+	}
+}
+
+func TestStatementPeriod(t *testing.T) {
+	now := time.Date(2026, time.October, 1, 1, 0, 0, 0, reminderZone)
+	tests := []struct {
+		month      string
+		start, end string
+		err        error
+	}{
+		{"", "2026-09-01", "2026-10-01", nil},
+		{"2026-02", "2026-02-01", "2026-03-01", nil},
+		{"2026-12", "2026-12-01", "2027-01-01", nil},
+		{"2026-13", "", "", ErrInvalidMonth},
+		{"Oct 2026", "", "", ErrInvalidMonth},
+	}
+	for _, tt := range tests {
+		start, end, err := StatementPeriod(tt.month, now)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("StatementPeriod(%q): err = %v, want %v", tt.month, err, tt.err)
+			continue
+		}
+		if err == nil && (start.Format(dateLayout) != tt.start || end.Format(dateLayout) != tt.end) {
+			t.Errorf("StatementPeriod(%q) = %s, %s; want %s, %s", tt.month, start.Format(dateLayout), end.Format(dateLayout), tt.start, tt.end)
+		}
+	}
+	// The month before is taken in Kenyan time, not the server's
+	start, _, _ := StatementPeriod("", time.Date(2026, time.September, 30, 22, 0, 0, 0, time.UTC))
+	if start.Format(dateLayout) != "2026-09-01" {
+		t.Errorf("22:00 UTC on 30 Sep is 1 Oct in Nairobi: start = %s", start.Format(dateLayout))
+	}
+}
+
+func TestStatementRange(t *testing.T) {
+	start, end, err := StatementRange("2026-09-15", "2026-10-14")
+	if err != nil || start.Format(dateLayout) != "2026-09-15" || end.Format(dateLayout) != "2026-10-15" {
+		t.Errorf("StatementRange = %s, %s, %v", start.Format(dateLayout), end.Format(dateLayout), err)
+	}
+	if _, end, _ := StatementRange("2026-09-15", "2026-09-15"); end.Format(dateLayout) != "2026-09-16" {
+		t.Errorf("a single day ends the next day, got %s", end.Format(dateLayout))
+	}
+	for _, r := range [][2]string{{"2026-10-14", "2026-09-15"}, {"2026-09-15", ""}, {"15/09/2026", "2026-10-14"}} {
+		if _, _, err := StatementRange(r[0], r[1]); !errors.Is(err, ErrInvalidRange) {
+			t.Errorf("StatementRange(%q, %q): err = %v, want ErrInvalidRange", r[0], r[1], err)
+		}
+	}
+}
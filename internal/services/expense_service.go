@@ -0,0 +1,807 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/lib/pq"
+)
+
+// ExpenseCategories are the categories an expense can be booked under, in
+// the order reports list them
+var ExpenseCategories = []string{"repairs", "utilities", "insurance", "property_tax", "management_fees",
+	"cleaning", "security", "salaries", "supplies", "legal", "other"}
+
+// Recurring expense frequencies
+const (
+	FrequencyWeekly    = "weekly"
+	FrequencyMonthly   = "monthly"
+	FrequencyQuarterly = "quarterly"
+	FrequencyYearly    = "yearly"
+)
+
+// MaxReceiptSize limits expense receipt uploads
+const MaxReceiptSize = 5 << 20
+
+// maxCatchUp bounds how many occurrences of one schedule a single run
+// generates, e.g. for a weekly schedule started years ago
+const maxCatchUp = 120
+
+// dateLayout is how DATE columns are exchanged
+const dateLayout = "2006-01-02"
+
+var (
+	ErrExpenseNotFound          = errors.New("expense not found")
+	ErrRecurringExpenseNotFound = errors.New("recurring expense not found")
+	ErrReceiptNotFound          = errors.New("expense has no receipt")
+	ErrUnitNotOnProperty        = errors.New("unit does not belong to the property")
+	ErrInvalidExpenseCategory   = fmt.Errorf("category must be one of %s", strings.Join(ExpenseCategories, ", "))
+	ErrInvalidFrequency         = errors.New("frequency must be weekly, monthly, quarterly or yearly")
+	ErrInvalidAmount            = errors.New("amount must be positive")
+	ErrInvalidDateRange         = errors.New("end date must not be before the start date")
+	ErrReceiptTooLarge          = fmt.Errorf("receipt must be at most %d MB", MaxReceiptSize>>20)
+)
+
+// ExpenseInput creates or replaces an expense. UnitID and VendorID are optional.
+type ExpenseInput struct {
+	PropertyID  int
+	UnitID      int
+	VendorID    int64
+	Category    string
+	Amount      float64
+	Description string
+	Date        time.Time
+	Reference   string
+}
+
+// ExpenseReceipt is a receipt file attached to an expense
+type ExpenseReceipt struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// ExpenseFilter narrows ListExpenses. Zero values are ignored; To is exclusive.
+type ExpenseFilter struct {
+	PropertyID int
+	UnitID     int
+	VendorID   int64
+	Category   string
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
+
+// RecurringExpenseInput creates a schedule. EndDate is optional.
+type RecurringExpenseInput struct {
+	PropertyID  int
+	UnitID      int
+	VendorID    int64
+	Category    string
+	Amount      float64
+	Description string
+	Frequency   string
+	StartDate   time.Time
+	EndDate     *time.Time
+}
+
+// RecurringExpenseUpdate changes a schedule; nil fields are left unchanged.
+// Changes apply to occurrences generated from now on.
+type RecurringExpenseUpdate struct {
+	Amount      *float64
+	Description *string
+	VendorID    *int64 // 0 clears the vendor
+	EndDate     *time.Time
+	ClearEnd    bool // remove the end date
+	Active      *bool
+}
+
+type ExpenseService struct {
+	DB *database.Database
+}
+
+func NewExpenseService(db *database.Database) *ExpenseService {
+	return &ExpenseService{DB: db}
+}
+
+const expenseSelect = `
+	SELECT e.id, e.landlord_id, e.property_id, p.title, e.unit_id, COALESCE(u.unit_name, ''), e.vendor_id, COALESCE(v.name, ''),
+		e.category, e.amount, e.description, e.expense_date, COALESCE(e.reference, ''), e.recurring_expense_id,
+		COALESCE(e.receipt_filename, ''), e.created_by, e.created_at, e.updated_at
+	FROM expenses e
+	JOIN properties p ON e.property_id = p.id
+	LEFT JOIN units u ON e.unit_id = u.id
+	LEFT JOIN vendors v ON e.vendor_id = v.id`
+
+// ListExpenses returns expenses on properties the user may read, latest first
+func (s *ExpenseService) ListExpenses(ctx context.Context, userID int, f ExpenseFilter) ([]models.Expense, error) {
+	var args queryArgs
+	query := expenseSelect + " WHERE e.property_id IN (SELECT accessible_property_ids(" + args.add(userID) + ", " + args.add(string(permissions.ExpensesRead)) + "))"
+	if f.PropertyID != 0 {
+		query += " AND e.property_id = " + args.add(f.PropertyID)
+	}
+	if f.UnitID != 0 {
+		query += " AND e.unit_id = " + args.add(f.UnitID)
+	}
+	if f.VendorID != 0 {
+		query += " AND e.vendor_id = " + args.add(f.VendorID)
+	}
+	if f.Category != "" {
+		query += " AND e.category = " + args.add(f.Category)
+	}
+	if !f.From.IsZero() {
+		query += " AND e.expense_date >= " + args.add(f.From.Format(dateLayout)) + "::DATE"
+	}
+	if !f.To.IsZero() {
+		query += " AND e.expense_date < " + args.add(f.To.Format(dateLayout)) + "::DATE"
+	}
+	query += " ORDER BY e.expense_date DESC, e.id DESC LIMIT " + args.add(f.Limit) + " OFFSET " + args.add(f.Offset)
+	return s.queryExpenses(ctx, query, args...)
+}
+
+// GetExpense returns an expense the user can reach with perm
+func (s *ExpenseService) GetExpense(ctx context.Context, userID int, perm permissions.Permission, id int64) (models.Expense, error) {
+	list, err := s.queryExpenses(ctx, expenseSelect+`
+		WHERE e.id = $1 AND e.property_id IN (SELECT accessible_property_ids($2, $3))`,
+		id, userID, string(perm))
+	if err != nil {
+		return models.Expense{}, err
+	}
+	if len(list) == 0 {
+		return models.Expense{}, ErrExpenseNotFound
+	}
+	return list[0], nil
+}
+
+func (s *ExpenseService) queryExpenses(ctx context.Context, query string, args ...interface{}) ([]models.Expense, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Expense{}
+	for rows.Next() {
+		var e models.Expense
+		var unitID, vendorID, recurringID, createdBy sql.NullInt64
+		var date time.Time
+		err := rows.Scan(&e.ID, &e.LandlordID, &e.PropertyID, &e.PropertyTitle, &unitID, &e.UnitName, &vendorID, &e.VendorName,
+			&e.Category, &e.Amount, &e.Description, &date, &e.Reference, &recurringID,
+			&e.ReceiptFilename, &createdBy, &e.CreatedAt, &e.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		e.UnitID = nullUint(unitID)
+		e.VendorID = nullUint(vendorID)
+		e.RecurringExpenseID = nullUint(recurringID)
+		e.CreatedBy = nullUint(createdBy)
+		e.ExpenseDate = date.Format(dateLayout)
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+// CreateExpense records an expense with an optional receipt
+func (s *ExpenseService) CreateExpense(ctx context.Context, userID int, in ExpenseInput, receipt *ExpenseReceipt) (models.Expense, error) {
+	landlordID, err := s.checkExpense(ctx, userID, in.PropertyID, in.UnitID, in.VendorID, in.Category, in.Amount)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	if receipt != nil && len(receipt.Data) > MaxReceiptSize {
+		return models.Expense{}, ErrReceiptTooLarge
+	}
+	if receipt == nil {
+		receipt = &ExpenseReceipt{}
+	}
+
+	var id int64
+	err = s.DB.QueryRowContext(ctx, `
+		INSERT INTO expenses (landlord_id, property_id, unit_id, vendor_id, category, amount, description, expense_date, reference,
+			receipt_filename, receipt_content_type, receipt_data, created_by)
+		VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, 0), $5, $6, $7, $8, NULLIF($9, ''),
+			NULLIF($10, ''), NULLIF($11, ''), $12, $13)
+		RETURNING id`,
+		landlordID, in.PropertyID, in.UnitID, in.VendorID, in.Category, in.Amount, in.Description, in.Date.Format(dateLayout), in.Reference,
+		receipt.Filename, receipt.ContentType, nullBytes(receipt.Data), userID,
+	).Scan(&id)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	return s.GetExpense(ctx, userID, permissions.ExpensesWrite, id)
+}
+
+// UpdateExpense replaces an expense's details; the receipt is kept
+func (s *ExpenseService) UpdateExpense(ctx context.Context, userID int, id int64, in ExpenseInput) (models.Expense, error) {
+	if _, err := s.GetExpense(ctx, userID, permissions.ExpensesWrite, id); err != nil {
+		return models.Expense{}, err
+	}
+	landlordID, err := s.checkExpense(ctx, userID, in.PropertyID, in.UnitID, in.VendorID, in.Category, in.Amount)
+	if err != nil {
+		return models.Expense{}, err
+	}
+
+	_, err = s.DB.ExecContext(ctx, `
+		UPDATE expenses
+		SET landlord_id = $2, property_id = $3, unit_id = NULLIF($4, 0), vendor_id = NULLIF($5, 0), category = $6,
+			amount = $7, description = $8, expense_date = $9, reference = NULLIF($10, ''), updated_at = NOW()
+		WHERE id = $1`,
+		id, landlordID, in.PropertyID, in.UnitID, in.VendorID, in.Category, in.Amount, in.Description, in.Date.Format(dateLayout), in.Reference,
+	)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	return s.GetExpense(ctx, userID, permissions.ExpensesWrite, id)
+}
+
+// DeleteExpense removes an expense
+func (s *ExpenseService) DeleteExpense(ctx context.Context, userID int, id int64) error {
+	res, err := s.DB.ExecContext(ctx, `
+		DELETE FROM expenses
+		WHERE id = $1 AND property_id IN (SELECT accessible_property_ids($2, $3))`,
+		id, userID, string(permissions.ExpensesWrite),
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrExpenseNotFound
+	}
+	return nil
+}
+
+// SetReceipt attaches a receipt to an expense, replacing any previous one
+func (s *ExpenseService) SetReceipt(ctx context.Context, userID int, id int64, receipt ExpenseReceipt) (models.Expense, error) {
+	if len(receipt.Data) > MaxReceiptSize {
+		return models.Expense{}, ErrReceiptTooLarge
+	}
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE expenses
+		SET receipt_filename = $2, receipt_content_type = $3, receipt_data = $4, updated_at = NOW()
+		WHERE id = $1 AND property_id IN (SELECT accessible_property_ids($5, $6))`,
+		id, receipt.Filename, receipt.ContentType, receipt.Data, userID, string(permissions.ExpensesWrite),
+	)
+	if err != nil {
+		return models.Expense{}, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.Expense{}, ErrExpenseNotFound
+	}
+	return s.GetExpense(ctx, userID, permissions.ExpensesWrite, id)
+}
+
+// GetReceipt returns an expense's receipt file
+func (s *ExpenseService) GetReceipt(ctx context.Context, userID int, id int64) (ExpenseReceipt, error) {
+	var r ExpenseReceipt
+	var filename, contentType sql.NullString
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT receipt_filename, receipt_content_type, receipt_data
+		FROM expenses
+		WHERE id = $1 AND property_id IN (SELECT accessible_property_ids($2, $3))`,
+		id, userID, string(permissions.ExpensesRead),
+	).Scan(&filename, &contentType, &r.Data)
+	if err == sql.ErrNoRows {
+		return r, ErrExpenseNotFound
+	}
+	if err != nil {
+		return r, err
+	}
+	if r.Data == nil {
+		return r, ErrReceiptNotFound
+	}
+	r.Filename, r.ContentType = filename.String, contentType.String
+	return r, nil
+}
+
+// checkExpense validates an expense's fields and returns the owner of the
+// property, which the user must be able to write expenses on
+func (s *ExpenseService) checkExpense(ctx context.Context, userID, propertyID, unitID int, vendorID int64, category string, amount float64) (int, error) {
+	if !contains(ExpenseCategories, category) {
+		return 0, ErrInvalidExpenseCategory
+	}
+	if amount <= 0 {
+		return 0, ErrInvalidAmount
+	}
+
+	var landlordID int
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT landlord_id FROM properties
+		WHERE id = $1 AND id IN (SELECT accessible_property_ids($2, $3))`,
+		propertyID, userID, string(permissions.ExpensesWrite),
+	).Scan(&landlordID)
+	if err == sql.ErrNoRows {
+		return 0, ErrPropertyNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if unitID != 0 {
+		var ok bool
+		if err := s.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM units WHERE id = $1 AND property_id = $2)", unitID, propertyID).Scan(&ok); err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, ErrUnitNotOnProperty
+		}
+	}
+	if vendorID != 0 {
+		var ok bool
+		if err := s.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM vendors WHERE id = $1 AND landlord_id = $2)", vendorID, landlordID).Scan(&ok); err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, ErrVendorNotFound
+		}
+	}
+	return landlordID, nil
+}
+
+// --- Recurring expenses ---
+
+const recurringSelect = `
+	SELECT r.id, r.landlord_id, r.property_id, p.title, r.unit_id, r.vendor_id, r.category, r.amount, r.description,
+		r.frequency, r.start_date, r.end_date, r.next_date, r.occurrences, r.active, r.created_at, r.updated_at
+	FROM recurring_expenses r
+	JOIN properties p ON r.property_id = p.id`
+
+// ListRecurring returns the schedules on properties the user may read
+func (s *ExpenseService) ListRecurring(ctx context.Context, userID, propertyID int) ([]models.RecurringExpense, error) {
+	var args queryArgs
+	query := recurringSelect + " WHERE r.property_id IN (SELECT accessible_property_ids(" + args.add(userID) + ", " + args.add(string(permissions.ExpensesRead)) + "))"
+	if propertyID != 0 {
+		query += " AND r.property_id = " + args.add(propertyID)
+	}
+	query += " ORDER BY r.active DESC, r.next_date, r.id"
+	return s.queryRecurring(ctx, query, args...)
+}
+
+func (s *ExpenseService) recurring(ctx context.Context, userID int, perm permissions.Permission, id int64) (models.RecurringExpense, error) {
+	list, err := s.queryRecurring(ctx, recurringSelect+`
+		WHERE r.id = $1 AND r.property_id IN (SELECT accessible_property_ids($2, $3))`,
+		id, userID, string(perm))
+	if err != nil {
+		return models.RecurringExpense{}, err
+	}
+	if len(list) == 0 {
+		return models.RecurringExpense{}, ErrRecurringExpenseNotFound
+	}
+	return list[0], nil
+}
+
+func (s *ExpenseService) queryRecurring(ctx context.Context, query string, args ...interface{}) ([]models.RecurringExpense, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.RecurringExpense{}
+	for rows.Next() {
+		var r models.RecurringExpense
+		var unitID, vendorID sql.NullInt64
+		var start, next time.Time
+		var end sql.NullTime
+		err := rows.Scan(&r.ID, &r.LandlordID, &r.PropertyID, &r.PropertyTitle, &unitID, &vendorID, &r.Category, &r.Amount, &r.Description,
+			&r.Frequency, &start, &end, &next, &r.Occurrences, &r.Active, &r.CreatedAt, &r.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		r.UnitID = nullUint(unitID)
+		r.VendorID = nullUint(vendorID)
+		r.StartDate = start.Format(dateLayout)
+		r.NextDate = next.Format(dateLayout)
+		if end.Valid {
+			v := end.Time.Format(dateLayout)
+			r.EndDate = &v
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// CreateRecurring adds a schedule and generates any occurrences already due
+func (s *ExpenseService) CreateRecurring(ctx context.Context, userID int, in RecurringExpenseInput) (models.RecurringExpense, error) {
+	if !validFrequency(in.Frequency) {
+		return models.RecurringExpense{}, ErrInvalidFrequency
+	}
+	if in.EndDate != nil && in.EndDate.Before(in.StartDate) {
+		return models.RecurringExpense{}, ErrInvalidDateRange
+	}
+	landlordID, err := s.checkExpense(ctx, userID, in.PropertyID, in.UnitID, in.VendorID, in.Category, in.Amount)
+	if err != nil {
+		return models.RecurringExpense{}, err
+	}
+
+	var end interface{}
+	if in.EndDate != nil {
+		end = in.EndDate.Format(dateLayout)
+	}
+	var id int64
+	err = s.DB.QueryRowContext(ctx, `
+		INSERT INTO recurring_expenses (landlord_id, property_id, unit_id, vendor_id, category, amount, description,
+			frequency, start_date, end_date, next_date, created_by)
+		VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, 0), $5, $6, $7, $8, $9, $10, $9, $11)
+		RETURNING id`,
+		landlordID, in.PropertyID, in.UnitID, in.VendorID, in.Category, in.Amount, in.Description,
+		in.Frequency, in.StartDate.Format(dateLayout), end, userID,
+	).Scan(&id)
+	if err != nil {
+		return models.RecurringExpense{}, err
+	}
+
+	if _, err := s.generate(ctx, id, today()); err != nil {
+		log.Printf("expenses: generate recurring expense %d: %v", id, err)
+	}
+	return s.recurring(ctx, userID, permissions.ExpensesWrite, id)
+}
+
+// UpdateRecurring changes a schedule. Reactivating a schedule generates
+// occurrences missed while it was paused.
+func (s *ExpenseService) UpdateRecurring(ctx context.Context, userID int, id int64, in RecurringExpenseUpdate) (models.RecurringExpense, error) {
+	r, err := s.recurring(ctx, userID, permissions.ExpensesWrite, id)
+	if err != nil {
+		return r, err
+	}
+	if in.Amount != nil && *in.Amount <= 0 {
+		return r, ErrInvalidAmount
+	}
+	if in.EndDate != nil && in.EndDate.Format(dateLayout) < r.StartDate {
+		return r, ErrInvalidDateRange
+	}
+	if in.VendorID != nil && *in.VendorID != 0 {
+		var ok bool
+		if err := s.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM vendors WHERE id = $1 AND landlord_id = $2)", *in.VendorID, r.LandlordID).Scan(&ok); err != nil {
+			return r, err
+		}
+		if !ok {
+			return r, ErrVendorNotFound
+		}
+	}
+
+	var end interface{}
+	if in.EndDate != nil {
+		end = in.EndDate.Format(dateLayout)
+	}
+	_, err = s.DB.ExecContext(ctx, `
+		UPDATE recurring_expenses
+		SET amount = COALESCE($2, amount),
+			description = COALESCE($3, description),
+			vendor_id = CASE WHEN $4::BIGINT IS NULL THEN vendor_id ELSE NULLIF($4::BIGINT, 0) END,
+			end_date = CASE WHEN $5 THEN NULL ELSE COALESCE($6::DATE, end_date) END,
+			active = COALESCE($7, active),
+			updated_at = NOW()
+		WHERE id = $1`,
+		id, in.Amount, in.Description, in.VendorID, in.ClearEnd, end, in.Active,
+	)
+	if err != nil {
+		return r, err
+	}
+
+	if _, err := s.generate(ctx, id, today()); err != nil {
+		log.Printf("expenses: generate recurring expense %d: %v", id, err)
+	}
+	return s.recurring(ctx, userID, permissions.ExpensesWrite, id)
+}
+
+// DeleteRecurring removes a schedule; expenses it generated are kept
+func (s *ExpenseService) DeleteRecurring(ctx context.Context, userID int, id int64) (models.RecurringExpense, error) {
+	r, err := s.recurring(ctx, userID, permissions.ExpensesWrite, id)
+	if err != nil {
+		return r, err
+	}
+	_, err = s.DB.ExecContext(ctx, "DELETE FROM recurring_expenses WHERE id = $1", id)
+	return r, err
+}
+
+// RunRecurring generates due recurring expenses every interval until ctx
+// is cancelled
+func (s *ExpenseService) RunRecurring(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.GenerateRecurring(ctx, today()); err != nil {
+			log.Printf("expenses: recurring run failed: %v", err)
+		} else if n > 0 {
+			log.Printf("expenses: generated %d recurring expenses", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// GenerateRecurring adds an expense for every occurrence of an active
+// schedule up to and including day, returning how many were added
+func (s *ExpenseService) GenerateRecurring(ctx context.Context, day time.Time) (int, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT id FROM recurring_expenses WHERE active AND next_date <= $1 ORDER BY id", day.Format(dateLayout))
+	if err != nil {
+		return 0, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, id := range ids {
+		n, err := s.generate(ctx, id, day)
+		if err != nil {
+			log.Printf("expenses: generate recurring expense %d: %v", id, err)
+			continue
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// generate adds the due occurrences of one schedule. The row lock lets
+// several servers run the generator; an occurrence is added at most once.
+func (s *ExpenseService) generate(ctx context.Context, id int64, day time.Time) (int, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var r struct {
+		landlordID, propertyID int
+		unitID, vendorID       sql.NullInt64
+		category, description  string
+		frequency              string
+		amount                 float64
+		start                  time.Time
+		end                    sql.NullTime
+		occurrences            int
+	}
+	var createdBy sql.NullInt64
+	err = tx.QueryRowContext(ctx, `
+		SELECT landlord_id, property_id, unit_id, vendor_id, category, description, frequency, amount,
+			start_date, end_date, occurrences, created_by
+		FROM recurring_expenses
+		WHERE id = $1 AND active AND next_date <= $2
+		FOR UPDATE SKIP LOCKED`,
+		id, day.Format(dateLayout),
+	).Scan(&r.landlordID, &r.propertyID, &r.unitID, &r.vendorID, &r.category, &r.description, &r.frequency, &r.amount,
+		&r.start, &r.end, &r.occurrences, &createdBy)
+	if err == sql.ErrNoRows {
+		return 0, nil // not due, paused or being generated elsewhere
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	last := dateOnly(day)
+	if r.end.Valid && r.end.Time.Before(last) {
+		last = dateOnly(r.end.Time)
+	}
+	added := 0
+	next := occurrence(r.start, r.frequency, r.occurrences)
+	for !next.After(last) && added < maxCatchUp {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO expenses (landlord_id, property_id, unit_id, vendor_id, category, amount, description, expense_date,
+				recurring_expense_id, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (recurring_expense_id, expense_date) DO NOTHING`,
+			r.landlordID, r.propertyID, r.unitID, r.vendorID, r.category, r.amount, r.description, next.Format(dateLayout),
+			id, createdBy,
+		)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			added++
+		}
+		r.occurrences++
+		next = occurrence(r.start, r.frequency, r.occurrences)
+	}
+
+	// A schedule past its end date is finished
+	active := !r.end.Valid || !next.After(dateOnly(r.end.Time))
+	_, err = tx.ExecContext(ctx, `
+		UPDATE recurring_expenses SET next_date = $2, occurrences = $3, active = $4, updated_at = NOW()
+		WHERE id = $1`,
+		id, next.Format(dateLayout), r.occurrences, active,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return added, tx.Commit()
+}
+
+// occurrence returns the date of the nth (0-based) occurrence of a schedule.
+// Monthly schedules starting late in the month fall on the month's last day
+// when it is shorter.
+func occurrence(start time.Time, frequency string, n int) time.Time {
+	start = dateOnly(start)
+	switch frequency {
+	case FrequencyWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case FrequencyQuarterly:
+		return addMonths(start, 3*n)
+	case FrequencyYearly:
+		return addMonths(start, 12*n)
+	default:
+		return addMonths(start, n)
+	}
+}
+
+// addMonths adds months to t, clamping the day to the target month's length
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+func validFrequency(f string) bool {
+	switch f {
+	case FrequencyWeekly, FrequencyMonthly, FrequencyQuarterly, FrequencyYearly:
+		return true
+	}
+	return false
+}
+
+// dateOnly drops the time of day, keeping the calendar date in UTC as DATE
+// columns are scanned
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// today is the current date in the business time zone
+func today() time.Time {
+	return dateOnly(time.Now().In(reminderZone))
+}
+
+func nullBytes(b []byte) interface{} {
+	if b == nil {
+		return nil
+	}
+	return b
+}
+
+// --- Profit and loss ---
+
+// ProfitAndLoss compares rent collected (completed payments) with expenses
+// per property over [from, to). Only properties the user may read both
+// payments and expenses on are included.
+func (s *ExpenseService) ProfitAndLoss(ctx context.Context, userID int, from, to time.Time, propertyID int) (models.ProfitAndLoss, error) {
+	report := models.ProfitAndLoss{
+		From:       from.Format(dateLayout),
+		To:         to.AddDate(0, 0, -1).Format(dateLayout),
+		Properties: []models.PropertyProfitAndLoss{},
+		Total:      models.PropertyProfitAndLoss{Expenses: map[string]float64{}},
+	}
+
+	var args queryArgs
+	user := args.add(userID)
+	query := `
+		SELECT id, title FROM properties
+		WHERE id IN (SELECT accessible_property_ids(` + user + `, ` + args.add(string(permissions.ExpensesRead)) + `))
+		  AND id IN (SELECT accessible_property_ids(` + user + `, ` + args.add(string(permissions.PaymentsRead)) + `))`
+	if propertyID != 0 {
+		query += " AND id = " + args.add(propertyID)
+	}
+	query += " ORDER BY title, id"
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return report, err
+	}
+	index := map[int]int{}
+	var ids []int64
+	for rows.Next() {
+		var p models.PropertyProfitAndLoss
+		if err := rows.Scan(&p.PropertyID, &p.PropertyTitle); err != nil {
+			rows.Close()
+			return report, err
+		}
+		p.Expenses = map[string]float64{}
+		index[int(p.PropertyID)] = len(report.Properties)
+		ids = append(ids, int64(p.PropertyID))
+		report.Properties = append(report.Properties, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+	if len(ids) == 0 {
+		return report, nil
+	}
+
+	// Payments reach a property through tenant -> unit
+	rows, err = s.DB.QueryContext(ctx, `
+		SELECT u.property_id, SUM(p.amount), COUNT(*)
+		FROM payments p
+		JOIN tenants t ON p.tenant_id = t.id
+		JOIN units u ON t.unit_id = u.id
+		WHERE u.property_id = ANY($1) AND p.status = 'COMPLETED'
+		  AND p.created_at >= $2 AND p.created_at < $3
+		GROUP BY u.property_id`,
+		pq.Array(ids), from, to,
+	)
+	if err != nil {
+		return report, err
+	}
+	for rows.Next() {
+		var id, count int
+		var amount float64
+		if err := rows.Scan(&id, &amount, &count); err != nil {
+			rows.Close()
+			return report, err
+		}
+		p := &report.Properties[index[id]]
+		p.RentCollected, p.PaymentCount = amount, count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	rows, err = s.DB.QueryContext(ctx, `
+		SELECT property_id, category, SUM(amount)
+		FROM expenses
+		WHERE property_id = ANY($1) AND expense_date >= $2::DATE AND expense_date < $3::DATE
+		GROUP BY property_id, category`,
+		pq.Array(ids), from.Format(dateLayout), to.Format(dateLayout),
+	)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var category string
+		var amount float64
+		if err := rows.Scan(&id, &category, &amount); err != nil {
+			return report, err
+		}
+		p := &report.Properties[index[id]]
+		p.Expenses[category] = amount
+		p.TotalExpenses += amount
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	t := &report.Total
+	for i := range report.Properties {
+		p := &report.Properties[i]
+		p.TotalExpenses = roundTo(p.TotalExpenses, 2)
+		p.NetOperatingIncome = roundTo(p.RentCollected-p.TotalExpenses, 2)
+		t.RentCollected += p.RentCollected
+		t.PaymentCount += p.PaymentCount
+		t.TotalExpenses += p.TotalExpenses
+		for category, amount := range p.Expenses {
+			t.Expenses[category] = roundTo(t.Expenses[category]+amount, 2)
+		}
+	}
+	t.RentCollected = roundTo(t.RentCollected, 2)
+	t.TotalExpenses = roundTo(t.TotalExpenses, 2)
+	t.NetOperatingIncome = roundTo(t.RentCollected-t.TotalExpenses, 2)
+	return report, nil
+}
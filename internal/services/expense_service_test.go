@@ -0,0 +1,51 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOccurrence(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		start     time.Time
+		frequency string
+		n         int
+		want      time.Time
+	}{
+		{date(2026, 1, 15), FrequencyWeekly, 0, date(2026, 1, 15)},
+		{date(2026, 1, 15), FrequencyWeekly, 3, date(2026, 2, 5)},
+		{date(2026, 1, 15), FrequencyMonthly, 1, date(2026, 2, 15)},
+		// A schedule on the 31st falls on each month's last day and goes back
+		// to the 31st, rather than drifting to the 28th
+		{date(2026, 1, 31), FrequencyMonthly, 1, date(2026, 2, 28)},
+		{date(2026, 1, 31), FrequencyMonthly, 2, date(2026, 3, 31)},
+		{date(2026, 1, 31), FrequencyMonthly, 3, date(2026, 4, 30)},
+		{date(2026, 11, 30), FrequencyMonthly, 2, date(2027, 1, 30)},
+		{date(2026, 11, 30), FrequencyQuarterly, 1, date(2027, 2, 28)},
+		{date(2026, 5, 31), FrequencyQuarterly, 2, date(2026, 11, 30)},
+		{date(2028, 2, 29), FrequencyYearly, 1, date(2029, 2, 28)},
+		{date(2028, 2, 29), FrequencyYearly, 4, date(2032, 2, 29)},
+		// The time of day is dropped
+		{time.Date(2026, 3, 10, 18, 45, 0, 0, time.UTC), FrequencyMonthly, 1, date(2026, 4, 10)},
+	}
+	for _, tt := range tests {
+		if got := occurrence(tt.start, tt.frequency, tt.n); !got.Equal(tt.want) {
+			t.Errorf("occurrence(%s, %s, %d) = %s, want %s", tt.start.Format(dateLayout), tt.frequency, tt.n,
+				got.Format(dateLayout), tt.want.Format(dateLayout))
+		}
+	}
+}
+
+func TestValidFrequency(t *testing.T) {
+	for _, f := range []string{FrequencyWeekly, FrequencyMonthly, FrequencyQuarterly, FrequencyYearly} {
+		if !validFrequency(f) {
+			t.Errorf("%s rejected", f)
+		}
+	}
+	for _, f := range []string{"", "daily", "Monthly"} {
+		if validFrequency(f) {
+			t.Errorf("%q accepted", f)
+		}
+	}
+}
@@ -0,0 +1,555 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+	"github.com/Zolet-hash/smart-rentals/internal/events"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/Zolet-hash/smart-rentals/internal/phone"
+	"github.com/Zolet-hash/smart-rentals/internal/repository"
+)
+
+const (
+	MaxImportSize = 5 << 20
+	MaxImportRows = 2000
+)
+
+// Import job statuses
+const (
+	ImportValidated = "VALIDATED" // dry run without errors
+	ImportInvalid   = "INVALID"   // row errors; nothing was applied
+	ImportCompleted = "COMPLETED"
+	ImportFailed    = "FAILED" // valid file the database refused; nothing was applied
+)
+
+var (
+	ErrImportNotFound    = errors.New("import not found")
+	ErrImportTooLarge    = errors.New("import files must be at most 5 MB")
+	ErrEmptyImport       = errors.New("the file has no rows below its header")
+	ErrTooManyImportRows = fmt.Errorf("an import may hold at most %d rows", MaxImportRows)
+	ErrImportColumns     = errors.New("the header row must name the unit_name, unit_type and unit_price columns")
+)
+
+// importAliases maps other common header names to import columns. Headers
+// are compared lower case with spaces and dashes read as underscores.
+var importAliases = map[string]string{
+	"unit":         "unit_name",
+	"unit_no":      "unit_name",
+	"unit_number":  "unit_name",
+	"type":         "unit_type",
+	"price":        "unit_price",
+	"tenant":       "tenant_name",
+	"phone":        "payment_no1",
+	"phone_number": "payment_no1",
+	"due_day":      "rent_due_day",
+}
+
+var importRequired = []string{"unit_name", "unit_type", "unit_price"}
+
+// ImportService creates a property's units, and optionally their tenants,
+// from a CSV or XLSX file. Units and tenants are written through Store, in
+// the transaction that records the job.
+type ImportService struct {
+	DB     *database.Database
+	Store  *repository.Postgres
+	Events events.Publisher
+}
+
+func NewImportService(db *database.Database, publisher events.Publisher) *ImportService {
+	return &ImportService{DB: db, Store: repository.NewPostgres(db), Events: publisher}
+}
+
+// ImportFile is an uploaded spreadsheet; Format is documents.FormatCSV or
+// documents.FormatXLSX
+type ImportFile struct {
+	Filename string
+	Format   string
+	Data     []byte
+}
+
+// importRow is a valid unit row, with its tenant when one is given
+type importRow struct {
+	line      int
+	unitName  string
+	unitType  string
+	unitPrice float64
+	tenant    *importTenant
+}
+
+type importTenant struct {
+	name       string
+	paymentNo1 string
+	paymentNo2 string
+	email      string
+	rent       float64
+	rentDueDay int
+}
+
+// Import validates the file and, unless dryRun, creates its units and
+// tenants in one transaction. Every run is recorded as a job; row errors
+// are part of the job rather than an error.
+func (s *ImportService) Import(ctx context.Context, userID, propertyID int, f ImportFile, dryRun bool) (models.ImportJob, error) {
+	job := models.ImportJob{PropertyID: uint(propertyID), Filename: f.Filename, DryRun: dryRun}
+	var landlordID int
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT landlord_id FROM properties
+		WHERE id = $1 AND id IN (SELECT accessible_property_ids($2, $3))
+		  AND id IN (SELECT accessible_property_ids($2, $4))`,
+		propertyID, userID, permissions.UnitsWrite, permissions.TenantsWrite,
+	).Scan(&landlordID)
+	if err == sql.ErrNoRows {
+		return job, ErrPropertyNotFound
+	}
+	if err != nil {
+		return job, err
+	}
+	job.LandlordID = uint(landlordID)
+	createdBy := uint(userID)
+	job.CreatedBy = &createdBy
+
+	if len(f.Data) > MaxImportSize {
+		return job, ErrImportTooLarge
+	}
+	cells, err := documents.ReadSpreadsheet(f.Data, f.Format)
+	if err != nil {
+		return job, err
+	}
+	rows, rowErrors, err := parseImport(cells)
+	if err != nil {
+		return job, err
+	}
+	job.Rows = len(rows) + countRows(rowErrors)
+
+	existing, err := s.conflicts(ctx, propertyID, landlordID, rows)
+	if err != nil {
+		return job, err
+	}
+	rowErrors = append(rowErrors, existing...)
+	sortImportErrors(rowErrors)
+	job.Errors = rowErrors
+	job.ErrorCount = len(rowErrors)
+
+	switch {
+	case len(rowErrors) > 0:
+		job.Status = ImportInvalid
+	case dryRun:
+		job.Status = ImportValidated
+	default:
+		return s.apply(ctx, job, rows)
+	}
+	return job, s.record(ctx, s.DB, &job)
+}
+
+// apply creates the rows and records the job in one transaction. When the
+// database refuses the rows the job is recorded as failed on its own.
+func (s *ImportService) apply(ctx context.Context, job models.ImportJob, rows []importRow) (models.ImportJob, error) {
+	var tenants []models.Tenant
+
+	err := func() error {
+		tx, err := s.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		store := s.Store.InTx(tx)
+
+		for _, r := range rows {
+			u := models.Unit{
+				PropertyID: uint(job.PropertyID),
+				UnitName:   r.unitName,
+				UnitType:   r.unitType,
+				UnitPrice:  r.unitPrice,
+			}
+			if err := store.Units().Create(ctx, &u); err != nil {
+				return fmt.Errorf("row %d: unit: %w", r.line, err)
+			}
+			job.UnitsCreated++
+			if r.tenant == nil {
+				continue
+			}
+
+			t := models.Tenant{
+				UnitID:     u.ID,
+				LandlordID: uint(job.LandlordID),
+				TenantName: r.tenant.name,
+				PaymentNo1: r.tenant.paymentNo1,
+				PaymentNo2: r.tenant.paymentNo2,
+				Rent:       r.tenant.rent,
+				Balance:    r.tenant.rent,
+				RentDueDay: r.tenant.rentDueDay,
+				Email:      r.tenant.email,
+			}
+			if err := store.Tenants().Create(ctx, &t); err != nil {
+				return fmt.Errorf("row %d: tenant: %w", r.line, err)
+			}
+			if err := store.Units().SetVacancy(ctx, int(u.ID), false); err != nil {
+				return fmt.Errorf("row %d: unit: %w", r.line, err)
+			}
+			job.TenantsCreated++
+			tenants = append(tenants, t)
+		}
+
+		job.Status = ImportCompleted
+		if err := s.record(ctx, tx, &job); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}()
+	if err != nil {
+		failed := job
+		failed.Status = ImportFailed
+		failed.UnitsCreated, failed.TenantsCreated = 0, 0
+		failed.Errors = []models.ImportRowError{{Message: "the rows could not be saved; nothing was changed"}}
+		failed.ErrorCount = 1
+		if recErr := s.record(ctx, s.DB, &failed); recErr != nil {
+			log.Printf("import: recording failed job for property %d: %v", job.PropertyID, recErr)
+		}
+		return failed, err
+	}
+
+	for _, t := range tenants {
+		s.Events.Publish(ctx, events.New(events.TenantCreated, int(job.LandlordID), map[string]interface{}{
+			"tenant_id":   t.ID,
+			"unit_id":     t.UnitID,
+			"tenant_name": t.TenantName,
+			"payment_no1": t.PaymentNo1,
+			"rent":        t.Rent,
+			"balance":     t.Balance,
+		}))
+	}
+	return job, nil
+}
+
+// rowQuerier is a database or a transaction
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// record stores a finished job, setting its ID and times
+func (s *ImportService) record(ctx context.Context, db rowQuerier, job *models.ImportJob) error {
+	errs := job.Errors
+	if errs == nil {
+		errs = []models.ImportRowError{}
+	}
+	raw, err := json.Marshal(errs)
+	if err != nil {
+		return err
+	}
+	return db.QueryRowContext(ctx, `
+		INSERT INTO import_jobs (landlord_id, property_id, filename, dry_run, status, total_rows,
+			units_created, tenants_created, error_count, errors, created_by, completed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW())
+		RETURNING id, created_at, completed_at`,
+		job.LandlordID, job.PropertyID, job.Filename, job.DryRun, job.Status, job.Rows,
+		job.UnitsCreated, job.TenantsCreated, job.ErrorCount, string(raw), job.CreatedBy,
+	).Scan(&job.ID, &job.CreatedAt, &job.CompletedAt)
+}
+
+// conflicts reports units already on the property and payment numbers
+// already used by the landlord's tenants; a shared number would send
+// M-Pesa payments to the wrong tenant
+func (s *ImportService) conflicts(ctx context.Context, propertyID, landlordID int, rows []importRow) ([]models.ImportRowError, error) {
+	units := map[string]bool{}
+	phones := map[string]bool{}
+
+	unitRows, err := s.DB.QueryContext(ctx, "SELECT unit_name FROM units WHERE property_id = $1", propertyID)
+	if err != nil {
+		return nil, err
+	}
+	defer unitRows.Close()
+	for unitRows.Next() {
+		var name string
+		if err := unitRows.Scan(&name); err != nil {
+			return nil, err
+		}
+		units[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	if err := unitRows.Err(); err != nil {
+		return nil, err
+	}
+
+	phoneRows, err := s.DB.QueryContext(ctx, `
+		SELECT COALESCE(payment_no1, ''), COALESCE(payment_no2, '') FROM tenants WHERE landlord_id = $1`, landlordID)
+	if err != nil {
+		return nil, err
+	}
+	defer phoneRows.Close()
+	for phoneRows.Next() {
+		var no1, no2 string
+		if err := phoneRows.Scan(&no1, &no2); err != nil {
+			return nil, err
+		}
+		for _, no := range []string{no1, no2} {
+			if n, ok := phone.Normalize(no); ok {
+				phones[n] = true
+			}
+		}
+	}
+	if err := phoneRows.Err(); err != nil {
+		return nil, err
+	}
+
+	var errs []models.ImportRowError
+	for _, r := range rows {
+		if units[strings.ToLower(r.unitName)] {
+			errs = append(errs, models.ImportRowError{Row: r.line, Column: "unit_name", Value: r.unitName, Message: "a unit with this name already exists on the property"})
+		}
+		if r.tenant == nil {
+			continue
+		}
+		for col, no := range map[string]string{"payment_no1": r.tenant.paymentNo1, "payment_no2": r.tenant.paymentNo2} {
+			if no != "" && phones[no] {
+				errs = append(errs, models.ImportRowError{Row: r.line, Column: col, Value: no, Message: "another of your tenants already pays from this number"})
+			}
+		}
+	}
+	return errs, nil
+}
+
+// parseImport reads the header and validates each row on its own and
+// against the rest of the file. Blank rows are skipped.
+func parseImport(cells [][]string) ([]importRow, []models.ImportRowError, error) {
+	header := -1
+	for i, r := range cells {
+		if !blankRow(r) {
+			header = i
+			break
+		}
+	}
+	if header < 0 {
+		return nil, nil, ErrEmptyImport
+	}
+	cols := map[string]int{}
+	for i, name := range cells[header] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		name = strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+		if alias, ok := importAliases[name]; ok {
+			name = alias
+		}
+		if _, dup := cols[name]; !dup && name != "" {
+			cols[name] = i
+		}
+	}
+	for _, c := range importRequired {
+		if _, ok := cols[c]; !ok {
+			return nil, nil, ErrImportColumns
+		}
+	}
+
+	var rows []importRow
+	var errs []models.ImportRowError
+	units := map[string]int{}  // unit name to first row
+	phones := map[string]int{} // payment number to first row
+	count := 0
+	for i := header + 1; i < len(cells); i++ {
+		if blankRow(cells[i]) {
+			continue
+		}
+		if count++; count > MaxImportRows {
+			return nil, nil, ErrTooManyImportRows
+		}
+		line := i + 1
+		cell := func(name string) string {
+			if c, ok := cols[name]; ok && c < len(cells[i]) {
+				return strings.TrimSpace(cells[i][c])
+			}
+			return ""
+		}
+		fail := func(col, value, msg string) {
+			errs = append(errs, models.ImportRowError{Row: line, Column: col, Value: value, Message: msg})
+		}
+		before := len(errs)
+
+		r := importRow{line: line, unitName: cell("unit_name"), unitType: cell("unit_type")}
+		if r.unitName == "" {
+			fail("unit_name", "", "unit name is required")
+		} else if first, dup := units[strings.ToLower(r.unitName)]; dup {
+			fail("unit_name", r.unitName, fmt.Sprintf("unit also listed on row %d", first))
+		} else {
+			units[strings.ToLower(r.unitName)] = line
+		}
+		if r.unitType == "" {
+			fail("unit_type", "", "unit type is required")
+		}
+		price, ok := parseImportAmount(cell("unit_price"))
+		if !ok {
+			fail("unit_price", cell("unit_price"), "unit price must be an amount above zero")
+		}
+		r.unitPrice = price
+
+		t := importTenant{name: cell("tenant_name"), email: cell("email")}
+		no1, no2 := cell("payment_no1"), cell("payment_no2")
+		if t.name != "" || no1 != "" || no2 != "" || t.email != "" || cell("rent") != "" || cell("rent_due_day") != "" {
+			if t.name == "" {
+				fail("tenant_name", "", "tenant name is required when a tenant is given")
+			}
+			for _, p := range []struct {
+				col, value string
+				into       *string
+			}{{"payment_no1", no1, &t.paymentNo1}, {"payment_no2", no2, &t.paymentNo2}} {
+				if p.value == "" {
+					if p.col == "payment_no1" {
+						fail(p.col, "", "payment number is required when a tenant is given")
+					}
+					continue
+				}
+				n, ok := phone.Normalize(p.value)
+				if !ok {
+					fail(p.col, p.value, "not a Kenyan mobile number")
+					continue
+				}
+				if first, dup := phones[n]; dup {
+					fail(p.col, p.value, fmt.Sprintf("number also used on row %d", first))
+					continue
+				}
+				phones[n] = line
+				*p.into = n
+			}
+
+			t.rent = r.unitPrice
+			if v := cell("rent"); v != "" {
+				if t.rent, ok = parseImportAmount(v); !ok {
+					fail("rent", v, "rent must be an amount above zero")
+				}
+			}
+			t.rentDueDay = 1
+			if v := cell("rent_due_day"); v != "" {
+				day, err := strconv.Atoi(v)
+				if err != nil || day < 1 || day > 28 {
+					fail("rent_due_day", v, "rent due day must be from 1 to 28")
+				}
+				t.rentDueDay = day
+			}
+			if t.email != "" {
+				if addr, err := mail.ParseAddress(t.email); err != nil || addr.Address != t.email {
+					fail("email", t.email, "not a valid email address")
+				}
+			}
+			r.tenant = &t
+		}
+
+		if len(errs) == before {
+			rows = append(rows, r)
+		}
+	}
+	if count == 0 {
+		return nil, nil, ErrEmptyImport
+	}
+	return rows, errs, nil
+}
+
+// parseImportAmount reads a positive amount such as "15,000", "KES 15000"
+// or "Ksh. 15,000"
+func parseImportAmount(v string) (float64, bool) {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	// Longest first, so "KSHS" is not read as "KSH" followed by "S"
+	for _, prefix := range []string{"KSHS", "KSH", "KES"} {
+		if rest, ok := strings.CutPrefix(v, prefix); ok {
+			v = strings.TrimPrefix(strings.TrimSpace(rest), ".")
+			break
+		}
+	}
+	v = strings.NewReplacer(",", "", " ", "").Replace(v)
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f <= 0 {
+		return 0, false
+	}
+	return roundTo(f, 2), true
+}
+
+func blankRow(r []string) bool {
+	for _, c := range r {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// countRows counts the rows with errors
+func countRows(errs []models.ImportRowError) int {
+	seen := map[int]bool{}
+	for _, e := range errs {
+		seen[e.Row] = true
+	}
+	return len(seen)
+}
+
+func sortImportErrors(errs []models.ImportRowError) {
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Row < errs[j].Row })
+}
+
+// --- Jobs ---
+
+const importJobColumns = `id, landlord_id, property_id, filename, dry_run, status, total_rows, units_created,
+	tenants_created, error_count, created_by, created_at, completed_at`
+
+// ListJobs returns a property's imports, newest first, without their errors
+func (s *ImportService) ListJobs(ctx context.Context, userID, propertyID, limit, offset int) ([]models.ImportJob, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+importJobColumns+` FROM import_jobs
+		WHERE property_id = $1 AND property_id IN (SELECT accessible_property_ids($2, $3))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4 OFFSET $5`,
+		propertyID, userID, permissions.UnitsWrite, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.ImportJob{}
+	for rows.Next() {
+		var j models.ImportJob
+		if err := scanImportJob(rows, &j); err != nil {
+			return nil, err
+		}
+		list = append(list, j)
+	}
+	return list, rows.Err()
+}
+
+// GetJob returns an import with its row errors
+func (s *ImportService) GetJob(ctx context.Context, userID int, id int64) (models.ImportJob, error) {
+	var j models.ImportJob
+	var raw []byte
+	err := scanImportJob(s.DB.QueryRowContext(ctx, `
+		SELECT `+importJobColumns+`, errors FROM import_jobs
+		WHERE id = $1 AND property_id IN (SELECT accessible_property_ids($2, $3))`,
+		id, userID, permissions.UnitsWrite,
+	), &j, &raw)
+	if err == sql.ErrNoRows {
+		return j, ErrImportNotFound
+	}
+	if err != nil {
+		return j, err
+	}
+	return j, json.Unmarshal(raw, &j.Errors)
+}
+
+// scanImportJob reads importJobColumns, then any extra columns into extra
+func scanImportJob(row rowScanner, j *models.ImportJob, extra ...interface{}) error {
+	var createdBy sql.NullInt64
+	var completedAt sql.NullTime
+	dest := []interface{}{&j.ID, &j.LandlordID, &j.PropertyID, &j.Filename, &j.DryRun, &j.Status, &j.Rows,
+		&j.UnitsCreated, &j.TenantsCreated, &j.ErrorCount, &createdBy, &j.CreatedAt, &completedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	j.CreatedBy = nullUint(createdBy)
+	if completedAt.Valid {
+		j.CompletedAt = &completedAt.Time
+	}
+	return nil
+}
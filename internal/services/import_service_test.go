@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseImportAmount(t *testing.T) {
+	valid := map[string]float64{
+		"15000":        15000,
+		"15,000":       15000,
+		"KES 15,000":   15000,
+		"KSh 15,000":   15000,
+		"KShs 15,000":  15000,
+		"Kshs.15,000":  15000,
+		"Ksh. 15,000":  15000,
+		"ksh.15000.50": 15000.5,
+		".5":           0.5,
+	}
+	for in, want := range valid {
+		if got, ok := parseImportAmount(in); !ok || got != want {
+			t.Errorf("parseImportAmount(%q) = %v, %v; want %v", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "KSH", "S15000", "-100", "0", "KES KES 10"} {
+		if got, ok := parseImportAmount(in); ok {
+			t.Errorf("parseImportAmount(%q) = %v; want rejected", in, got)
+		}
+	}
+}
+
+func TestParseImport(t *testing.T) {
+	cells := [][]string{
+		{"", ""},
+		{"Unit No", "Type", "Price", "Tenant", "Phone", "Payment-No2", "Email", "Rent", "Due Day"},
+		{"A1", "1BR", "15,000"},
+		{"A2", "1BR", "15000", "Jane Wanjiru", "0712 345 678", "", "jane@example.com", "", "5"},
+		{" ", "", ""},
+		{"a1", "1BR", "15000"},
+		{"A3", "2BR", "KES 20,000", "John Otieno", "+254712345678"},
+		{"A4", "2BR", "20000", "", "0722000000"},
+		{"A5", "2BR", "20000", "Mary", "0733000000", "12345"},
+		{"A6", "", "0", "Ann", "0744000000", "", "ann@", "-5", "29"},
+	}
+	rows, errs, err := parseImport(cells)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 2 || rows[0].unitName != "A1" || rows[0].tenant != nil || rows[1].unitName != "A2" {
+		t.Fatalf("valid rows = %+v", rows)
+	}
+	jane := rows[1].tenant
+	if jane == nil || jane.paymentNo1 != "254712345678" || jane.rent != 15000 || jane.rentDueDay != 5 || jane.email != "jane@example.com" {
+		t.Errorf("tenant = %+v", jane)
+	}
+
+	type key struct {
+		row    int
+		column string
+	}
+	want := map[key]bool{
+		{6, "unit_name"}:     true, // A1 again, in another case
+		{7, "payment_no1"}:   true, // Jane's number in another form
+		{8, "tenant_name"}:   true,
+		{9, "payment_no2"}:   true,
+		{10, "unit_type"}:    true,
+		{10, "unit_price"}:   true,
+		{10, "email"}:        true,
+		{10, "rent"}:         true,
+		{10, "rent_due_day"}: true,
+	}
+	got := map[key]bool{}
+	for _, e := range errs {
+		got[key{e.Row, e.Column}] = true
+	}
+	for k := range want {
+		if !got[k] {
+			t.Errorf("no error on row %d %s", k.row, k.column)
+		}
+	}
+	for k := range got {
+		if !want[k] {
+			t.Errorf("unexpected error on row %d %s", k.row, k.column)
+		}
+	}
+}
+
+func TestParseImportFileErrors(t *testing.T) {
+	header := []string{"unit_name", "unit_type", "unit_price"}
+	tooMany := [][]string{header}
+	for i := 0; i <= MaxImportRows; i++ {
+		tooMany = append(tooMany, []string{"U", "1BR", "1000"})
+	}
+	tests := map[string]struct {
+		cells [][]string
+		want  error
+	}{
+		"empty file":      {nil, ErrEmptyImport},
+		"blank rows only": {[][]string{{"", " "}, {}}, ErrEmptyImport},
+		"header only":     {[][]string{header}, ErrEmptyImport},
+		"missing column":  {[][]string{{"unit_name", "unit_price"}, {"A1", "1000"}}, ErrImportColumns},
+		"too many rows":   {tooMany, ErrTooManyImportRows},
+	}
+	for name, tt := range tests {
+		if _, _, err := parseImport(tt.cells); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", name, err, tt.want)
+		}
+	}
+}
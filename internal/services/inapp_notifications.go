@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+	"github.com/Zolet-hash/smart-rentals/internal/events"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+)
+
+// ChannelInApp marks notifications shown in the app rather than delivered
+// by a notifier. They are stored as sent.
+const ChannelInApp = "in_app"
+
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// inboxCondition matches the in-app notifications user $1 may read. The
+// recipient is fixed when a notification is created, so re-linking a tenant
+// record to another account does not show it the previous occupant's history.
+const inboxCondition = `channel = 'in_app' AND user_id = $1`
+
+// InboxFilter narrows Inbox. Zero values are ignored.
+type InboxFilter struct {
+	UnreadOnly bool
+	Limit      int
+	Offset     int
+}
+
+// inAppNotification is an in-app notification to store
+type inAppNotification struct {
+	LandlordID int
+	TenantID   *int
+	UserID     *int // nil addresses the account linked to the tenant
+	Type       string
+	Message    string
+	Reference  string // deduplicates the notification when set
+}
+
+// createInApp stores a notification and announces it on the event stream.
+// Notifications for a tenant without an app account are dropped.
+func (s *NotificationService) createInApp(ctx context.Context, n inAppNotification) error {
+	if n.UserID == nil {
+		if n.TenantID == nil {
+			return errors.New("in-app notification has no recipient")
+		}
+		var userID sql.NullInt64
+		if err := s.DB.QueryRowContext(ctx, "SELECT user_id FROM tenants WHERE id = $1", *n.TenantID).Scan(&userID); err != nil {
+			return err
+		}
+		if !userID.Valid {
+			return nil
+		}
+		recipient := int(userID.Int64)
+		n.UserID = &recipient
+	}
+
+	var id int64
+	err := s.DB.QueryRowContext(ctx, `
+		INSERT INTO notifications (landlord_id, tenant_id, user_id, channel, template, body, reference, status, sent_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), 'sent', NOW())
+		ON CONFLICT (channel, reference) WHERE reference IS NOT NULL DO NOTHING
+		RETURNING id`,
+		n.LandlordID, n.TenantID, n.UserID, ChannelInApp, n.Type, n.Message, n.Reference,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil // already created
+	}
+	if err != nil {
+		return err
+	}
+
+	s.Events.Publish(ctx, events.New(events.NotificationCreated, n.LandlordID, map[string]interface{}{
+		"notification_id": id,
+		"user_id":         n.UserID,
+		"tenant_id":       n.TenantID,
+		"type":            n.Type,
+		"message":         n.Message,
+	}))
+	return nil
+}
+
+// Inbox returns the user's in-app notifications, newest first
+func (s *NotificationService) Inbox(ctx context.Context, userID int, f InboxFilter) ([]models.Notification, error) {
+	query := `
+		SELECT id, template, body, tenant_id, user_id, read_at, created_at
+		FROM notifications
+		WHERE ` + inboxCondition
+	if f.UnreadOnly {
+		query += " AND read_at IS NULL"
+	}
+	query += " ORDER BY id DESC LIMIT $2 OFFSET $3"
+
+	rows, err := s.DB.QueryContext(ctx, query, userID, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		var tenantID, recipientID sql.NullInt64
+		var readAt sql.NullTime
+		if err := rows.Scan(&n.ID, &n.Type, &n.Message, &tenantID, &recipientID, &readAt, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		if tenantID.Valid {
+			v := uint(tenantID.Int64)
+			n.TenantID = &v
+		}
+		if recipientID.Valid {
+			v := uint(recipientID.Int64)
+			n.UserID = &v
+		}
+		if readAt.Valid {
+			n.ReadAt = &readAt.Time
+			n.IsRead = true
+		}
+		list = append(list, n)
+	}
+	return list, rows.Err()
+}
+
+// UnreadCount returns how many of the user's in-app notifications are unread
+func (s *NotificationService) UnreadCount(ctx context.Context, userID int) (int, error) {
+	var n int
+	err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM notifications WHERE "+inboxCondition+" AND read_at IS NULL", userID).Scan(&n)
+	return n, err
+}
+
+// MarkRead marks one of the user's notifications as read. Marking a read
+// notification again keeps its original read time.
+func (s *NotificationService) MarkRead(ctx context.Context, userID int, id int64) error {
+	res, err := s.DB.ExecContext(ctx,
+		"UPDATE notifications SET read_at = COALESCE(read_at, NOW()) WHERE id = $2 AND "+inboxCondition, userID, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+// MarkAllRead marks every unread notification of the user as read,
+// returning how many changed
+func (s *NotificationService) MarkAllRead(ctx context.Context, userID int) (int64, error) {
+	res, err := s.DB.ExecContext(ctx, "UPDATE notifications SET read_at = NOW() WHERE read_at IS NULL AND "+inboxCondition, userID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// --- Event intake ---
+
+// HandleInAppEvent turns domain events into in-app notifications:
+// unmatched payments and arrears for the landlord, payments and arrears for
+// the tenant. Subscribe it to the event bus.
+func (s *NotificationService) HandleInAppEvent(ctx context.Context, e events.Event) {
+	var err error
+	switch e.Type {
+	case events.PaymentUnmatched:
+		err = s.notifyUnmatchedPayment(ctx, e)
+	case events.PaymentCompleted:
+		err = s.notifyPaymentReceived(ctx, e)
+	case events.InvoiceOverdue:
+		err = s.notifyArrears(ctx, e)
+	default:
+		return
+	}
+	if err != nil {
+		log.Printf("notifications: in-app %s failed: %v", e.Type, err)
+	}
+}
+
+func (s *NotificationService) notifyUnmatchedPayment(ctx context.Context, e events.Event) error {
+	paymentID, err := eventInt(e.Data, "payment_id")
+	if err != nil {
+		return err
+	}
+	var amount float64
+	var receipt string
+	err = s.DB.QueryRowContext(ctx, "SELECT amount, COALESCE(receipt, '') FROM payments WHERE id = $1", paymentID).Scan(&amount, &receipt)
+	if err != nil {
+		return err
+	}
+
+	landlordID := e.LandlordID
+	return s.createInApp(ctx, inAppNotification{
+		LandlordID: landlordID,
+		UserID:     &landlordID,
+		Type:       e.Type,
+		Message:    fmt.Sprintf("Payment of KES %s (%s) could not be matched to a tenant and is pending assignment.", documents.FormatMoney(amount), receipt),
+		Reference:  fmt.Sprintf("%s:%d", e.Type, paymentID),
+	})
+}
+
+func (s *NotificationService) notifyPaymentReceived(ctx context.Context, e events.Event) error {
+	paymentID, err := eventInt(e.Data, "payment_id")
+	if err != nil {
+		return err
+	}
+	var tenantID int
+	var amount, balance float64
+	err = s.DB.QueryRowContext(ctx, `
+		SELECT t.id, p.amount, COALESCE(t.balance, 0)
+		FROM payments p
+		JOIN tenants t ON p.tenant_id = t.id
+		WHERE p.id = $1`, paymentID,
+	).Scan(&tenantID, &amount, &balance)
+	if err != nil {
+		return err
+	}
+
+	return s.createInApp(ctx, inAppNotification{
+		LandlordID: e.LandlordID,
+		TenantID:   &tenantID,
+		Type:       e.Type,
+		Message:    fmt.Sprintf("We received your payment of KES %s. Your balance is KES %s.", documents.FormatMoney(amount), documents.FormatMoney(balance)),
+		Reference:  fmt.Sprintf("%s:%d", e.Type, paymentID),
+	})
+}
+
+func (s *NotificationService) notifyArrears(ctx context.Context, e events.Event) error {
+	var data struct {
+		TenantID    int     `json:"tenant_id"`
+		TenantName  string  `json:"tenant_name"`
+		UnitName    string  `json:"unit_name"`
+		Balance     float64 `json:"balance"`
+		DueDate     string  `json:"due_date"`
+		DaysOverdue int     `json:"days_overdue"`
+	}
+	if err := decodeEventData(e.Data, &data); err != nil {
+		return err
+	}
+	if data.TenantID == 0 {
+		return fmt.Errorf("missing tenant_id")
+	}
+
+	landlordID := e.LandlordID
+	balance := documents.FormatMoney(data.Balance)
+	err := s.createInApp(ctx, inAppNotification{
+		LandlordID: landlordID,
+		TenantID:   &data.TenantID,
+		UserID:     &landlordID,
+		Type:       e.Type,
+		Message:    fmt.Sprintf("%s (%s) is in arrears: KES %s overdue since %s.", data.TenantName, data.UnitName, balance, data.DueDate),
+		Reference:  fmt.Sprintf("%s:%d:%s:landlord", e.Type, data.TenantID, data.DueDate),
+	})
+	if err != nil {
+		return err
+	}
+	return s.createInApp(ctx, inAppNotification{
+		LandlordID: landlordID,
+		TenantID:   &data.TenantID,
+		Type:       e.Type,
+		Message:    fmt.Sprintf("Your rent balance of KES %s was due on %s and is %s overdue.", balance, data.DueDate, pluralDays(data.DaysOverdue)),
+		Reference:  fmt.Sprintf("%s:%d:%s:tenant", e.Type, data.TenantID, data.DueDate),
+	})
+}
+
+// decodeEventData copies event data into v through JSON, so handlers can
+// read events published with maps or structs alike
+func decodeEventData(data interface{}, v interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func pluralDays(n int) string {
+	if n == 1 {
+		return "1 day"
+	}
+	return strconv.Itoa(n) + " days"
+}
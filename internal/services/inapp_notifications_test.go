@@ -0,0 +1,38 @@
+package services
+
+import "testing"
+
+func TestDecodeEventData(t *testing.T) {
+	type arrears struct {
+		TenantID    int     `json:"tenant_id"`
+		Balance     float64 `json:"balance"`
+		DaysOverdue int     `json:"days_overdue"`
+	}
+	// Publishers send maps or structs; both decode the same way
+	for _, data := range []interface{}{
+		map[string]interface{}{"tenant_id": 12, "balance": 2500.5, "days_overdue": 3, "unit_name": "A4"},
+		arrears{TenantID: 12, Balance: 2500.5, DaysOverdue: 3},
+		&arrears{TenantID: 12, Balance: 2500.5, DaysOverdue: 3},
+	} {
+		var got arrears
+		if err := decodeEventData(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got != (arrears{TenantID: 12, Balance: 2500.5, DaysOverdue: 3}) {
+			t.Errorf("decodeEventData(%#v) = %+v", data, got)
+		}
+	}
+
+	var got arrears
+	if err := decodeEventData(map[string]interface{}{"tenant_id": "twelve"}, &got); err == nil {
+		t.Error("a non-numeric tenant_id decoded")
+	}
+}
+
+func TestPluralDays(t *testing.T) {
+	for n, want := range map[int]string{0: "0 days", 1: "1 day", 2: "2 days", 31: "31 days"} {
+		if got := pluralDays(n); got != want {
+			t.Errorf("pluralDays(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
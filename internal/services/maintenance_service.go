@@ -0,0 +1,920 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/lib/pq"
+)
+
+// Ticket statuses
+const (
+	TicketOpen       = "open"
+	TicketAssigned   = "assigned"
+	TicketInProgress = "in_progress"
+	TicketResolved   = "resolved"
+)
+
+// TicketCategories are the kinds of repair a ticket can be filed under
+var TicketCategories = []string{"plumbing", "electrical", "appliance", "structural", "pest_control", "security", "cleaning", "other"}
+
+// slaTargets is how long after reporting a ticket of each priority should
+// be resolved
+var slaTargets = map[string]time.Duration{
+	"urgent": 24 * time.Hour,
+	"high":   3 * 24 * time.Hour,
+	"normal": 7 * 24 * time.Hour,
+	"low":    14 * 24 * time.Hour,
+}
+
+// ticketTransitions lists the statuses each status may move to. Resolved
+// tickets can only be reopened.
+var ticketTransitions = map[string][]string{
+	TicketOpen:       {TicketAssigned, TicketInProgress, TicketResolved},
+	TicketAssigned:   {TicketOpen, TicketInProgress, TicketResolved},
+	TicketInProgress: {TicketAssigned, TicketResolved},
+	TicketResolved:   {TicketOpen},
+}
+
+// Photo limits
+const (
+	MaxTicketPhotos = 10 // per ticket
+	MaxPhotoSize    = 5 << 20
+)
+
+// In-app notification types for tickets
+const (
+	maintenanceReported = "maintenance.reported"
+	maintenanceAssigned = "maintenance.assigned"
+	maintenanceResolved = "maintenance.resolved"
+	maintenanceCharged  = "maintenance.charged"
+)
+
+var (
+	ErrTicketNotFound     = errors.New("maintenance ticket not found")
+	ErrPhotoNotFound      = errors.New("photo not found")
+	ErrVendorNotFound     = errors.New("vendor not found")
+	ErrTenantNotOnUnit    = errors.New("tenant does not occupy this unit")
+	ErrInvalidAssignee    = errors.New("assignee must be staff with maintenance access to the property")
+	ErrInvalidCategory    = fmt.Errorf("category must be one of %s", strings.Join(TicketCategories, ", "))
+	ErrInvalidPriority    = errors.New("priority must be low, normal, high or urgent")
+	ErrInvalidStatus      = errors.New("status must be open, assigned, in_progress or resolved")
+	ErrInvalidTransition  = errors.New("ticket cannot move to that status")
+	ErrTicketUnassigned   = errors.New("assign the ticket to staff or a vendor first")
+	ErrNoTenantToCharge   = errors.New("ticket has no tenant to charge")
+	ErrAlreadyCharged     = errors.New("ticket cost was already charged to the tenant")
+	ErrInvalidCharge      = errors.New("charge amount must be positive and at most the cost")
+	ErrChargeUnauthorized = errors.New("charging the tenant requires tenants:write on the property")
+	ErrTooManyPhotos      = fmt.Errorf("at most %d photos per ticket", MaxTicketPhotos)
+	ErrPhotoTooLarge      = fmt.Errorf("photos must be at most %d MB", MaxPhotoSize>>20)
+	ErrNotAnImage         = errors.New("photos must be images")
+)
+
+// TicketInput reports a ticket. UnitID is required for staff; tenants
+// always report for their own unit.
+type TicketInput struct {
+	UnitID      int
+	TenantID    int // optional for staff: the tenant affected
+	Title       string
+	Description string
+	Category    string
+	Priority    string // defaults to normal
+}
+
+// TicketUpdate changes a ticket's details; nil fields are left unchanged
+type TicketUpdate struct {
+	Title       *string
+	Description *string
+	Category    *string
+	Priority    *string
+}
+
+// TicketCost records what a repair cost and optionally charges the tenant
+type TicketCost struct {
+	Cost              float64
+	Recharge          bool
+	ChargeAmount      float64 // defaults to the full cost
+	ChargeDescription string
+}
+
+// TicketFilter narrows ListTickets. Zero values are ignored.
+type TicketFilter struct {
+	Status     string
+	Priority   string
+	Category   string
+	PropertyID int
+	UnitID     int
+	AssigneeID int
+	Overdue    bool
+	Limit      int
+	Offset     int
+}
+
+type MaintenanceService struct {
+	DB            *database.Database
+	Notifications *NotificationService
+}
+
+func NewMaintenanceService(db *database.Database, notifications *NotificationService) *MaintenanceService {
+	return &MaintenanceService{DB: db, Notifications: notifications}
+}
+
+const ticketSelect = `
+	SELECT tk.id, tk.landlord_id, u.property_id, p.title, tk.unit_id, u.unit_name, tk.tenant_id, COALESCE(t.tenant_name, ''),
+		tk.title, tk.description, tk.category, tk.priority, tk.status,
+		tk.assignee_id, COALESCE(NULLIF(au.full_name, ''), au.email, ''), tk.vendor_id, COALESCE(v.name, ''),
+		tk.cost, ch.id, ch.amount, COALESCE(tk.resolution, ''), tk.reported_by,
+		tk.due_at, tk.assigned_at, tk.started_at, tk.resolved_at, tk.created_at, tk.updated_at
+	FROM maintenance_tickets tk
+	JOIN units u ON tk.unit_id = u.id
+	JOIN properties p ON u.property_id = p.id
+	LEFT JOIN tenants t ON tk.tenant_id = t.id
+	LEFT JOIN users au ON tk.assignee_id = au.id
+	LEFT JOIN vendors v ON tk.vendor_id = v.id
+	LEFT JOIN tenant_charges ch ON ch.ticket_id = tk.id`
+
+// ListTickets returns the tickets the participant can see, newest first
+func (s *MaintenanceService) ListTickets(ctx context.Context, p Participant, f TicketFilter) ([]models.MaintenanceTicket, error) {
+	var args queryArgs
+	query := ticketSelect + " WHERE " + p.scope(permissions.MaintenanceRead, &args)
+	if f.Status != "" {
+		query += " AND tk.status = " + args.add(f.Status)
+	}
+	if f.Priority != "" {
+		query += " AND tk.priority = " + args.add(f.Priority)
+	}
+	if f.Category != "" {
+		query += " AND tk.category = " + args.add(f.Category)
+	}
+	if f.PropertyID != 0 {
+		query += " AND u.property_id = " + args.add(f.PropertyID)
+	}
+	if f.UnitID != 0 {
+		query += " AND tk.unit_id = " + args.add(f.UnitID)
+	}
+	if f.AssigneeID != 0 {
+		query += " AND tk.assignee_id = " + args.add(f.AssigneeID)
+	}
+	if f.Overdue {
+		query += " AND tk.status <> 'resolved' AND tk.due_at < NOW()"
+	}
+	query += " ORDER BY tk.id DESC LIMIT " + args.add(f.Limit) + " OFFSET " + args.add(f.Offset)
+	return s.queryTickets(ctx, query, args...)
+}
+
+// GetTicket returns one ticket with its photos
+func (s *MaintenanceService) GetTicket(ctx context.Context, p Participant, id int64) (models.MaintenanceTicket, error) {
+	tk, err := s.ticket(ctx, p, permissions.MaintenanceRead, id)
+	if err != nil {
+		return tk, err
+	}
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, filename, content_type, size, created_at
+		FROM maintenance_photos WHERE ticket_id = $1 ORDER BY id`, id)
+	if err != nil {
+		return tk, err
+	}
+	defer rows.Close()
+	tk.Photos = []models.MaintenancePhoto{}
+	for rows.Next() {
+		var ph models.MaintenancePhoto
+		if err := rows.Scan(&ph.ID, &ph.Filename, &ph.ContentType, &ph.Size, &ph.CreatedAt); err != nil {
+			return tk, err
+		}
+		tk.Photos = append(tk.Photos, ph)
+	}
+	return tk, rows.Err()
+}
+
+func (s *MaintenanceService) ticket(ctx context.Context, p Participant, perm permissions.Permission, id int64) (models.MaintenanceTicket, error) {
+	var args queryArgs
+	query := ticketSelect + " WHERE tk.id = " + args.add(id) + " AND " + p.scope(perm, &args)
+	list, err := s.queryTickets(ctx, query, args...)
+	if err != nil {
+		return models.MaintenanceTicket{}, err
+	}
+	if len(list) == 0 {
+		return models.MaintenanceTicket{}, ErrTicketNotFound
+	}
+	return list[0], nil
+}
+
+func (s *MaintenanceService) queryTickets(ctx context.Context, query string, args ...interface{}) ([]models.MaintenanceTicket, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	list := []models.MaintenanceTicket{}
+	for rows.Next() {
+		var tk models.MaintenanceTicket
+		var tenantID, assigneeID, vendorID, chargeID, reportedBy sql.NullInt64
+		var cost, charged sql.NullFloat64
+		var assignedAt, startedAt, resolvedAt sql.NullTime
+		err := rows.Scan(&tk.ID, &tk.LandlordID, &tk.PropertyID, &tk.PropertyTitle, &tk.UnitID, &tk.UnitName, &tenantID, &tk.TenantName,
+			&tk.Title, &tk.Description, &tk.Category, &tk.Priority, &tk.Status,
+			&assigneeID, &tk.AssigneeName, &vendorID, &tk.VendorName,
+			&cost, &chargeID, &charged, &tk.Resolution, &reportedBy,
+			&tk.DueAt, &assignedAt, &startedAt, &resolvedAt, &tk.CreatedAt, &tk.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		tk.TenantID = nullUint(tenantID)
+		tk.AssigneeID = nullUint(assigneeID)
+		tk.VendorID = nullUint(vendorID)
+		tk.ChargeID = nullUint(chargeID)
+		tk.ReportedBy = nullUint(reportedBy)
+		if cost.Valid {
+			tk.Cost = &cost.Float64
+		}
+		if charged.Valid {
+			tk.Charged = &charged.Float64
+		}
+		if assignedAt.Valid {
+			tk.AssignedAt = &assignedAt.Time
+		}
+		if startedAt.Valid {
+			tk.StartedAt = &startedAt.Time
+		}
+		if resolvedAt.Valid {
+			tk.ResolvedAt = &resolvedAt.Time
+		}
+		tk.Overdue = tk.Status != TicketResolved && now.After(tk.DueAt)
+		list = append(list, tk)
+	}
+	return list, rows.Err()
+}
+
+// CreateTicket reports a repair with optional photos. Tenants report for the
+// unit they occupy and the landlord is told in-app.
+func (s *MaintenanceService) CreateTicket(ctx context.Context, p Participant, in TicketInput, photos []models.MaintenancePhoto) (models.MaintenanceTicket, error) {
+	if in.Priority == "" {
+		in.Priority = "normal"
+	}
+	if err := validateTicket(in.Category, in.Priority); err != nil {
+		return models.MaintenanceTicket{}, err
+	}
+	if err := validatePhotos(photos, 0); err != nil {
+		return models.MaintenanceTicket{}, err
+	}
+
+	var landlordID int
+	var tenantID *int
+	var err error
+	if p.Tenant {
+		var id int
+		err = s.DB.QueryRowContext(ctx, `
+			SELECT t.id, t.unit_id, p.landlord_id
+			FROM tenants t
+			JOIN units u ON t.unit_id = u.id
+			JOIN properties p ON u.property_id = p.id
+			WHERE t.user_id = $1`, p.UserID,
+		).Scan(&id, &in.UnitID, &landlordID)
+		if err == sql.ErrNoRows {
+			return models.MaintenanceTicket{}, ErrTenantNotLinked
+		}
+		tenantID = &id
+	} else {
+		err = s.DB.QueryRowContext(ctx, `
+			SELECT p.landlord_id FROM units u
+			JOIN properties p ON u.property_id = p.id
+			WHERE u.id = $1 AND u.property_id IN (SELECT accessible_property_ids($2, $3))`,
+			in.UnitID, p.UserID, string(permissions.MaintenanceWrite),
+		).Scan(&landlordID)
+		if err == sql.ErrNoRows {
+			return models.MaintenanceTicket{}, ErrUnitNotFound
+		}
+		if err == nil && in.TenantID != 0 {
+			var onUnit bool
+			err = s.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM tenants WHERE id = $1 AND unit_id = $2)", in.TenantID, in.UnitID).Scan(&onUnit)
+			if err == nil && !onUnit {
+				return models.MaintenanceTicket{}, ErrTenantNotOnUnit
+			}
+			tenantID = &in.TenantID
+		}
+	}
+	if err != nil {
+		return models.MaintenanceTicket{}, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.MaintenanceTicket{}, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO maintenance_tickets (landlord_id, unit_id, tenant_id, title, description, category, priority, reported_by, due_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW() + $9::float8 * INTERVAL '1 second')
+		RETURNING id`,
+		landlordID, in.UnitID, tenantID, in.Title, in.Description, in.Category, in.Priority, p.UserID, slaTargets[in.Priority].Seconds(),
+	).Scan(&id)
+	if err != nil {
+		return models.MaintenanceTicket{}, err
+	}
+	if err := insertPhotos(ctx, tx, id, p.UserID, photos); err != nil {
+		return models.MaintenanceTicket{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.MaintenanceTicket{}, err
+	}
+
+	tk, err := s.GetTicket(ctx, p, id)
+	if err != nil {
+		return tk, err
+	}
+	if p.Tenant {
+		s.notify(ctx, inAppNotification{
+			LandlordID: landlordID,
+			TenantID:   tenantID,
+			UserID:     &landlordID,
+			Type:       maintenanceReported,
+			Message:    fmt.Sprintf("New %s priority repair request for %s (%s): %s", tk.Priority, tk.UnitName, tk.TenantName, tk.Title),
+			Reference:  fmt.Sprintf("maintenance:%d:reported", id),
+		})
+	}
+	return tk, nil
+}
+
+// UpdateTicket changes a ticket's details. A new priority moves the SLA
+// deadline, counted from when the ticket was reported.
+func (s *MaintenanceService) UpdateTicket(ctx context.Context, p Participant, id int64, in TicketUpdate) (models.MaintenanceTicket, error) {
+	if p.Tenant {
+		return models.MaintenanceTicket{}, ErrStaffOnly
+	}
+	tk, err := s.ticket(ctx, p, permissions.MaintenanceWrite, id)
+	if err != nil {
+		return tk, err
+	}
+	category, priority := tk.Category, tk.Priority
+	if in.Category != nil {
+		category = *in.Category
+	}
+	if in.Priority != nil {
+		priority = *in.Priority
+	}
+	if err := validateTicket(category, priority); err != nil {
+		return tk, err
+	}
+
+	_, err = s.DB.ExecContext(ctx, `
+		UPDATE maintenance_tickets
+		SET title = COALESCE($2, title),
+			description = COALESCE($3, description),
+			category = $4,
+			priority = $5,
+			due_at = created_at + $6::float8 * INTERVAL '1 second',
+			updated_at = NOW()
+		WHERE id = $1`,
+		id, in.Title, in.Description, category, priority, slaTargets[priority].Seconds(),
+	)
+	if err != nil {
+		return tk, err
+	}
+	return s.GetTicket(ctx, p, id)
+}
+
+// AssignTicket hands a ticket to a staff member, a vendor or both. Either
+// may be 0 to clear it. Open tickets become assigned; the assignee is told
+// in-app.
+func (s *MaintenanceService) AssignTicket(ctx context.Context, p Participant, id int64, assigneeID int, vendorID int64) (models.MaintenanceTicket, error) {
+	if p.Tenant {
+		return models.MaintenanceTicket{}, ErrStaffOnly
+	}
+	tk, err := s.ticket(ctx, p, permissions.MaintenanceWrite, id)
+	if err != nil {
+		return tk, err
+	}
+
+	if assigneeID != 0 {
+		var ok bool
+		err := s.DB.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM users
+				WHERE id = $1 AND role <> $2
+				  AND $3 IN (SELECT accessible_property_ids($1, $4))
+			)`,
+			assigneeID, permissions.RoleTenant, tk.PropertyID, string(permissions.MaintenanceWrite),
+		).Scan(&ok)
+		if err != nil {
+			return tk, err
+		}
+		if !ok {
+			return tk, ErrInvalidAssignee
+		}
+	}
+	if vendorID != 0 {
+		var ok bool
+		err := s.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM vendors WHERE id = $1 AND landlord_id = $2)", vendorID, tk.LandlordID).Scan(&ok)
+		if err != nil {
+			return tk, err
+		}
+		if !ok {
+			return tk, ErrVendorNotFound
+		}
+	}
+
+	status := tk.Status
+	switch {
+	case assigneeID == 0 && vendorID == 0 && status == TicketAssigned:
+		status = TicketOpen
+	case (assigneeID != 0 || vendorID != 0) && status == TicketOpen:
+		status = TicketAssigned
+	}
+	_, err = s.DB.ExecContext(ctx, `
+		UPDATE maintenance_tickets
+		SET assignee_id = NULLIF($2, 0),
+			vendor_id = NULLIF($3, 0),
+			status = $4,
+			assigned_at = CASE WHEN $2 = 0 AND $3 = 0 THEN NULL ELSE NOW() END,
+			updated_at = NOW()
+		WHERE id = $1`,
+		id, assigneeID, vendorID, status,
+	)
+	if err != nil {
+		return tk, err
+	}
+
+	if assigneeID != 0 && assigneeID != p.UserID {
+		s.notify(ctx, inAppNotification{
+			LandlordID: int(tk.LandlordID),
+			UserID:     &assigneeID,
+			Type:       maintenanceAssigned,
+			Message:    fmt.Sprintf("You were assigned a %s priority repair at %s, %s: %s", tk.Priority, tk.PropertyTitle, tk.UnitName, tk.Title),
+			Reference:  fmt.Sprintf("maintenance:%d:assigned:%d:%d", id, assigneeID, time.Now().Unix()),
+		})
+	}
+	return s.GetTicket(ctx, p, id)
+}
+
+// SetStatus moves a ticket through its workflow. Resolving records the
+// resolution note and tells the tenant; reopening clears the resolution.
+func (s *MaintenanceService) SetStatus(ctx context.Context, p Participant, id int64, status, note string) (models.MaintenanceTicket, error) {
+	if p.Tenant {
+		return models.MaintenanceTicket{}, ErrStaffOnly
+	}
+	if _, ok := ticketTransitions[status]; !ok {
+		return models.MaintenanceTicket{}, ErrInvalidStatus
+	}
+	tk, err := s.ticket(ctx, p, permissions.MaintenanceWrite, id)
+	if err != nil {
+		return tk, err
+	}
+	if status == tk.Status {
+		return s.GetTicket(ctx, p, id)
+	}
+	if !allowedTransition(tk.Status, status) {
+		return tk, ErrInvalidTransition
+	}
+	if status == TicketAssigned && tk.AssigneeID == nil && tk.VendorID == nil {
+		return tk, ErrTicketUnassigned
+	}
+
+	_, err = s.DB.ExecContext(ctx, `
+		UPDATE maintenance_tickets
+		SET status = $2,
+			started_at = CASE WHEN $2 = 'in_progress' THEN COALESCE(started_at, NOW()) ELSE started_at END,
+			resolved_at = CASE WHEN $2 = 'resolved' THEN NOW() END,
+			resolution = CASE WHEN $2 = 'resolved' THEN NULLIF($3, '') END,
+			updated_at = NOW()
+		WHERE id = $1`,
+		id, status, note,
+	)
+	if err != nil {
+		return tk, err
+	}
+
+	if status == TicketResolved && tk.TenantID != nil {
+		tenantID := int(*tk.TenantID)
+		s.notify(ctx, inAppNotification{
+			LandlordID: int(tk.LandlordID),
+			TenantID:   &tenantID,
+			Type:       maintenanceResolved,
+			Message:    fmt.Sprintf("Your repair request \"%s\" has been resolved.", tk.Title),
+			Reference:  fmt.Sprintf("maintenance:%d:resolved:%d", id, time.Now().Unix()),
+		})
+	}
+	return s.GetTicket(ctx, p, id)
+}
+
+// SetCost records the repair cost. With Recharge, the charge amount (the
+// full cost by default) is added to the ticket tenant's balance; a ticket
+// is charged at most once.
+func (s *MaintenanceService) SetCost(ctx context.Context, p Participant, id int64, in TicketCost) (models.MaintenanceTicket, error) {
+	if p.Tenant {
+		return models.MaintenanceTicket{}, ErrStaffOnly
+	}
+	tk, err := s.ticket(ctx, p, permissions.MaintenanceWrite, id)
+	if err != nil {
+		return tk, err
+	}
+
+	if in.Recharge {
+		if tk.TenantID == nil {
+			return tk, ErrNoTenantToCharge
+		}
+		if tk.ChargeID != nil {
+			return tk, ErrAlreadyCharged
+		}
+		if in.ChargeAmount == 0 {
+			in.ChargeAmount = in.Cost
+		}
+		if in.ChargeAmount <= 0 || in.ChargeAmount > in.Cost {
+			return tk, ErrInvalidCharge
+		}
+		var allowed bool
+		err := s.DB.QueryRowContext(ctx, "SELECT $1 IN (SELECT accessible_property_ids($2, $3))",
+			tk.PropertyID, p.UserID, string(permissions.TenantsWrite)).Scan(&allowed)
+		if err != nil {
+			return tk, err
+		}
+		if !allowed {
+			return tk, ErrChargeUnauthorized
+		}
+		if in.ChargeDescription == "" {
+			in.ChargeDescription = "Repair: " + tk.Title
+		}
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return tk, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE maintenance_tickets SET cost = $2, updated_at = NOW() WHERE id = $1", id, in.Cost); err != nil {
+		return tk, err
+	}
+	if in.Recharge {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO tenant_charges (landlord_id, tenant_id, amount, description, ticket_id, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			tk.LandlordID, *tk.TenantID, in.ChargeAmount, in.ChargeDescription, id, p.UserID,
+		)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return tk, ErrAlreadyCharged
+		}
+		if err != nil {
+			return tk, err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE tenants SET balance = COALESCE(balance, 0) + $1, updated_at = NOW() WHERE id = $2", in.ChargeAmount, *tk.TenantID); err != nil {
+			return tk, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return tk, err
+	}
+
+	if in.Recharge {
+		tenantID := int(*tk.TenantID)
+		s.notify(ctx, inAppNotification{
+			LandlordID: int(tk.LandlordID),
+			TenantID:   &tenantID,
+			Type:       maintenanceCharged,
+			Message:    fmt.Sprintf("KES %s for \"%s\" was added to your balance.", documents.FormatMoney(in.ChargeAmount), tk.Title),
+			Reference:  fmt.Sprintf("maintenance:%d:charged", id),
+		})
+	}
+	return s.GetTicket(ctx, p, id)
+}
+
+// AddPhotos attaches photos to a ticket. Tenants may add photos to their
+// own tickets.
+func (s *MaintenanceService) AddPhotos(ctx context.Context, p Participant, id int64, photos []models.MaintenancePhoto) (models.MaintenanceTicket, error) {
+	tk, err := s.ticket(ctx, p, permissions.MaintenanceWrite, id)
+	if err != nil {
+		return tk, err
+	}
+	var existing int
+	if err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM maintenance_photos WHERE ticket_id = $1", id).Scan(&existing); err != nil {
+		return tk, err
+	}
+	if err := validatePhotos(photos, existing); err != nil {
+		return tk, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return tk, err
+	}
+	defer tx.Rollback()
+	if err := insertPhotos(ctx, tx, id, p.UserID, photos); err != nil {
+		return tk, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE maintenance_tickets SET updated_at = NOW() WHERE id = $1", id); err != nil {
+		return tk, err
+	}
+	if err := tx.Commit(); err != nil {
+		return tk, err
+	}
+	return s.GetTicket(ctx, p, id)
+}
+
+// GetPhoto returns a ticket photo with its data
+func (s *MaintenanceService) GetPhoto(ctx context.Context, p Participant, ticketID, photoID int64) (models.MaintenancePhoto, error) {
+	if _, err := s.ticket(ctx, p, permissions.MaintenanceRead, ticketID); err != nil {
+		return models.MaintenancePhoto{}, err
+	}
+	var ph models.MaintenancePhoto
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT id, filename, content_type, size, data, created_at
+		FROM maintenance_photos WHERE id = $1 AND ticket_id = $2`,
+		photoID, ticketID,
+	).Scan(&ph.ID, &ph.Filename, &ph.ContentType, &ph.Size, &ph.Data, &ph.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ph, ErrPhotoNotFound
+	}
+	return ph, err
+}
+
+// SLAReport summarizes, per accessible property, the tickets created in
+// [from, to): how many were resolved within their SLA deadline, how many
+// breached it, average resolution time and repair costs
+func (s *MaintenanceService) SLAReport(ctx context.Context, p Participant, from, to time.Time, propertyID int) ([]models.MaintenanceSLA, error) {
+	if p.Tenant {
+		return nil, ErrStaffOnly
+	}
+	var args queryArgs
+	query := `
+		SELECT p.id, p.title,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE tk.status <> 'resolved'),
+			COUNT(*) FILTER (WHERE tk.status = 'resolved'),
+			COUNT(*) FILTER (WHERE tk.status = 'resolved' AND tk.resolved_at <= tk.due_at),
+			COUNT(*) FILTER (WHERE tk.status = 'resolved' AND tk.resolved_at > tk.due_at
+				OR tk.status <> 'resolved' AND tk.due_at < NOW()),
+			AVG(EXTRACT(EPOCH FROM tk.resolved_at - tk.created_at) / 3600) FILTER (WHERE tk.status = 'resolved'),
+			COALESCE(SUM(tk.cost), 0),
+			COALESCE(SUM(ch.amount), 0)
+		FROM maintenance_tickets tk
+		JOIN units u ON tk.unit_id = u.id
+		JOIN properties p ON u.property_id = p.id
+		LEFT JOIN tenant_charges ch ON ch.ticket_id = tk.id
+		WHERE u.property_id IN (SELECT accessible_property_ids(` + args.add(p.UserID) + `, ` + args.add(string(permissions.MaintenanceRead)) + `))
+		  AND tk.created_at >= ` + args.add(from) + ` AND tk.created_at < ` + args.add(to)
+	if propertyID != 0 {
+		query += " AND p.id = " + args.add(propertyID)
+	}
+	query += " GROUP BY p.id, p.title ORDER BY p.title, p.id"
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.MaintenanceSLA{}
+	for rows.Next() {
+		var r models.MaintenanceSLA
+		var avg sql.NullFloat64
+		err := rows.Scan(&r.PropertyID, &r.PropertyTitle, &r.Tickets, &r.Open, &r.Resolved, &r.ResolvedOnTime, &r.Breached,
+			&avg, &r.TotalCost, &r.Recharged)
+		if err != nil {
+			return nil, err
+		}
+		if avg.Valid {
+			v := roundTo(avg.Float64, 1)
+			r.AvgResolutionHours = &v
+		}
+		if r.Resolved > 0 {
+			v := roundTo(float64(r.ResolvedOnTime)*100/float64(r.Resolved), 1)
+			r.OnTimeRate = &v
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// ListCharges returns a tenant's charges, newest first
+func (s *MaintenanceService) ListCharges(ctx context.Context, userID, tenantID int) ([]models.TenantCharge, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT c.id, c.landlord_id, c.tenant_id, c.amount, c.description, c.ticket_id, c.created_at
+		FROM tenant_charges c
+		JOIN tenants t ON c.tenant_id = t.id
+		JOIN units u ON t.unit_id = u.id
+		WHERE c.tenant_id = $1 AND u.property_id IN (SELECT accessible_property_ids($2, $3))
+		ORDER BY c.id DESC`,
+		tenantID, userID, string(permissions.TenantsRead),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.TenantCharge{}
+	for rows.Next() {
+		var ch models.TenantCharge
+		var ticketID sql.NullInt64
+		if err := rows.Scan(&ch.ID, &ch.LandlordID, &ch.TenantID, &ch.Amount, &ch.Description, &ticketID, &ch.CreatedAt); err != nil {
+			return nil, err
+		}
+		ch.TicketID = nullUint(ticketID)
+		list = append(list, ch)
+	}
+	return list, rows.Err()
+}
+
+// --- Vendors ---
+
+// vendorLandlord resolves whose vendor list the caller manages: their own,
+// or for staff the landlord given, if they may do maintenance work for them
+func (s *MaintenanceService) vendorLandlord(ctx context.Context, p Participant, perm permissions.Permission, landlordID int) (int, error) {
+	if p.Tenant {
+		return 0, ErrStaffOnly
+	}
+	if landlordID == 0 || landlordID == p.UserID {
+		return p.UserID, nil
+	}
+	var ok bool
+	err := s.DB.QueryRowContext(ctx, "SELECT $1 IN (SELECT accessible_landlord_ids($2, $3))", landlordID, p.UserID, string(perm)).Scan(&ok)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrVendorNotFound
+	}
+	return landlordID, nil
+}
+
+// ListVendors returns the vendors of every landlord the caller does
+// maintenance work for
+func (s *MaintenanceService) ListVendors(ctx context.Context, p Participant) ([]models.Vendor, error) {
+	if p.Tenant {
+		return nil, ErrStaffOnly
+	}
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, landlord_id, name, COALESCE(phone, ''), COALESCE(email, ''), COALESCE(trade, ''), created_at, updated_at
+		FROM vendors
+		WHERE landlord_id IN (SELECT accessible_landlord_ids($1, $2))
+		ORDER BY name, id`,
+		p.UserID, string(permissions.MaintenanceRead),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Vendor{}
+	for rows.Next() {
+		var v models.Vendor
+		if err := rows.Scan(&v.ID, &v.LandlordID, &v.Name, &v.Phone, &v.Email, &v.Trade, &v.CreatedAt, &v.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+// CreateVendor adds a vendor for landlordID (0 for the caller's own account)
+func (s *MaintenanceService) CreateVendor(ctx context.Context, p Participant, landlordID int, v models.Vendor) (models.Vendor, error) {
+	landlordID, err := s.vendorLandlord(ctx, p, permissions.MaintenanceWrite, landlordID)
+	if err != nil {
+		return v, err
+	}
+	v.LandlordID = uint(landlordID)
+	err = s.DB.QueryRowContext(ctx, `
+		INSERT INTO vendors (landlord_id, name, phone, email, trade)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''))
+		RETURNING id, created_at, updated_at`,
+		landlordID, v.Name, v.Phone, v.Email, v.Trade,
+	).Scan(&v.ID, &v.CreatedAt, &v.UpdatedAt)
+	return v, err
+}
+
+// GetVendor returns a vendor the caller may manage
+func (s *MaintenanceService) GetVendor(ctx context.Context, p Participant, id int64) (models.Vendor, error) {
+	var v models.Vendor
+	if p.Tenant {
+		return v, ErrStaffOnly
+	}
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT id, landlord_id, name, COALESCE(phone, ''), COALESCE(email, ''), COALESCE(trade, ''), created_at, updated_at
+		FROM vendors
+		WHERE id = $1 AND landlord_id IN (SELECT accessible_landlord_ids($2, $3))`,
+		id, p.UserID, string(permissions.MaintenanceWrite),
+	).Scan(&v.ID, &v.LandlordID, &v.Name, &v.Phone, &v.Email, &v.Trade, &v.CreatedAt, &v.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return v, ErrVendorNotFound
+	}
+	return v, err
+}
+
+// UpdateVendor saves a vendor's details
+func (s *MaintenanceService) UpdateVendor(ctx context.Context, p Participant, v models.Vendor) (models.Vendor, error) {
+	err := s.DB.QueryRowContext(ctx, `
+		UPDATE vendors
+		SET name = $2, phone = NULLIF($3, ''), email = NULLIF($4, ''), trade = NULLIF($5, ''), updated_at = NOW()
+		WHERE id = $1 AND landlord_id IN (SELECT accessible_landlord_ids($6, $7))
+		RETURNING updated_at`,
+		v.ID, v.Name, v.Phone, v.Email, v.Trade, p.UserID, string(permissions.MaintenanceWrite),
+	).Scan(&v.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return v, ErrVendorNotFound
+	}
+	return v, err
+}
+
+// DeleteVendor removes a vendor; tickets assigned to them keep their history
+// but lose the vendor
+func (s *MaintenanceService) DeleteVendor(ctx context.Context, p Participant, id int64) error {
+	res, err := s.DB.ExecContext(ctx, `
+		DELETE FROM vendors
+		WHERE id = $1 AND landlord_id IN (SELECT accessible_landlord_ids($2, $3))`,
+		id, p.UserID, string(permissions.MaintenanceWrite),
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrVendorNotFound
+	}
+	return nil
+}
+
+// notify stores an in-app notification; failures are logged since the
+// ticket change is already saved
+func (s *MaintenanceService) notify(ctx context.Context, n inAppNotification) {
+	if err := s.Notifications.createInApp(ctx, n); err != nil {
+		log.Printf("maintenance: notify %s failed: %v", n.Reference, err)
+	}
+}
+
+func validateTicket(category, priority string) error {
+	if !contains(TicketCategories, category) {
+		return ErrInvalidCategory
+	}
+	if _, ok := slaTargets[priority]; !ok {
+		return ErrInvalidPriority
+	}
+	return nil
+}
+
+// validatePhotos checks new photos against the limits, given how many the
+// ticket already has
+func validatePhotos(photos []models.MaintenancePhoto, existing int) error {
+	if existing+len(photos) > MaxTicketPhotos {
+		return ErrTooManyPhotos
+	}
+	for _, ph := range photos {
+		if len(ph.Data) > MaxPhotoSize {
+			return ErrPhotoTooLarge
+		}
+		if !strings.HasPrefix(ph.ContentType, "image/") {
+			return ErrNotAnImage
+		}
+	}
+	return nil
+}
+
+func insertPhotos(ctx context.Context, tx *sql.Tx, ticketID int64, userID int, photos []models.MaintenancePhoto) error {
+	for _, ph := range photos {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO maintenance_photos (ticket_id, filename, content_type, size, data, uploaded_by)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			ticketID, ph.Filename, ph.ContentType, len(ph.Data), ph.Data, userID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func allowedTransition(from, to string) bool {
+	return contains(ticketTransitions[from], to)
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func nullUint(v sql.NullInt64) *uint {
+	if !v.Valid {
+		return nil
+	}
+	u := uint(v.Int64)
+	return &u
+}
+
+func roundTo(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+)
+
+func TestValidateTicket(t *testing.T) {
+	tests := []struct {
+		category, priority string
+		want               error
+	}{
+		{"plumbing", "urgent", nil},
+		{"pest_control", "low", nil},
+		{"roof", "normal", ErrInvalidCategory},
+		{"Plumbing", "normal", ErrInvalidCategory},
+		{"electrical", "critical", ErrInvalidPriority},
+		{"electrical", "", ErrInvalidPriority},
+	}
+	for _, tt := range tests {
+		if err := validateTicket(tt.category, tt.priority); !errors.Is(err, tt.want) {
+			t.Errorf("validateTicket(%q, %q) = %v, want %v", tt.category, tt.priority, err, tt.want)
+		}
+	}
+}
+
+func TestSLATargetsIncreaseWithLowerPriority(t *testing.T) {
+	order := []string{"urgent", "high", "normal", "low"}
+	if len(slaTargets) != len(order) {
+		t.Fatalf("%d SLA targets, want one per priority in %v", len(slaTargets), order)
+	}
+	for i := 1; i < len(order); i++ {
+		if slaTargets[order[i]] <= slaTargets[order[i-1]] {
+			t.Errorf("%s target %v is not longer than %s target %v", order[i], slaTargets[order[i]], order[i-1], slaTargets[order[i-1]])
+		}
+	}
+}
+
+func TestAllowedTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{TicketOpen, TicketAssigned, true},
+		{TicketOpen, TicketResolved, true},
+		{TicketAssigned, TicketInProgress, true},
+		{TicketInProgress, TicketResolved, true},
+		{TicketInProgress, TicketOpen, false},
+		{TicketResolved, TicketOpen, true},
+		{TicketResolved, TicketInProgress, false},
+		{TicketResolved, TicketAssigned, false},
+		{TicketOpen, TicketOpen, false},
+		{"closed", TicketOpen, false},
+	}
+	for _, tt := range tests {
+		if got := allowedTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("allowedTransition(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePhotos(t *testing.T) {
+	photo := func(contentType string, size int) models.MaintenancePhoto {
+		return models.MaintenancePhoto{ContentType: contentType, Data: make([]byte, size)}
+	}
+	tests := []struct {
+		name     string
+		photos   []models.MaintenancePhoto
+		existing int
+		want     error
+	}{
+		{"photos", []models.MaintenancePhoto{photo("image/jpeg", 100), photo("image/png", MaxPhotoSize)}, 0, nil},
+		{"fills the ticket", []models.MaintenancePhoto{photo("image/jpeg", 100)}, MaxTicketPhotos - 1, nil},
+		{"over the ticket limit", []models.MaintenancePhoto{photo("image/jpeg", 100), photo("image/jpeg", 100)}, MaxTicketPhotos - 1, ErrTooManyPhotos},
+		{"too large", []models.MaintenancePhoto{photo("image/jpeg", MaxPhotoSize+1)}, 0, ErrPhotoTooLarge},
+		{"not an image", []models.MaintenancePhoto{photo("application/pdf", 100)}, 0, ErrNotAnImage},
+	}
+	for _, tt := range tests {
+		if err := validatePhotos(tt.photos, tt.existing); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,562 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/lib/pq"
+)
+
+// Message sender types
+const (
+	SenderStaff  = "staff"
+	SenderTenant = "tenant"
+)
+
+// Message limits
+const (
+	MaxMessageLength      = 4000 // characters
+	MaxMessageAttachments = 5
+	MaxAttachmentSize     = 5 << 20 // bytes per file
+)
+
+// messageReceived is the in-app notification type for new messages
+const messageReceived = "message.received"
+
+var (
+	ErrConversationNotFound = errors.New("conversation not found")
+	ErrAttachmentNotFound   = errors.New("attachment not found")
+	ErrRecipientNotFound    = errors.New("tenant or property not found or unauthorized")
+	ErrTenantNotLinked      = errors.New("your account is not linked to a tenant")
+	ErrStaffOnly            = errors.New("only landlords and their staff can do this")
+	ErrNoRecipients         = errors.New("property has no tenants")
+	ErrEmptyMessage         = errors.New("message content is required")
+	ErrMessageTooLong       = fmt.Errorf("message must be at most %d characters", MaxMessageLength)
+	ErrTooManyAttachments   = fmt.Errorf("at most %d attachments per message", MaxMessageAttachments)
+	ErrAttachmentTooLarge   = fmt.Errorf("attachments must be at most %d MB", MaxAttachmentSize>>20)
+)
+
+// Participant is the caller's side of a conversation. Tenant-role users
+// reach the conversations of the tenant linked to their account; everyone
+// else those of tenants on properties they may access.
+type Participant struct {
+	UserID int
+	Tenant bool
+}
+
+func NewParticipant(userID int, role string) Participant {
+	return Participant{UserID: userID, Tenant: role == permissions.RoleTenant}
+}
+
+func (p Participant) senderType() string {
+	if p.Tenant {
+		return SenderTenant
+	}
+	return SenderStaff
+}
+
+// scope is a condition on tenants t and units u limiting rows to tenants
+// the participant can reach with perm
+func (p Participant) scope(perm permissions.Permission, args *queryArgs) string {
+	if p.Tenant {
+		return "t.user_id = " + args.add(p.UserID)
+	}
+	return "u.property_id IN (SELECT accessible_property_ids(" + args.add(p.UserID) + ", " + args.add(string(perm)) + "))"
+}
+
+// queryArgs collects positional arguments while a query is built
+type queryArgs []interface{}
+
+func (a *queryArgs) add(v interface{}) string {
+	*a = append(*a, v)
+	return "$" + strconv.Itoa(len(*a))
+}
+
+// ConversationFilter narrows ListConversations. Zero values are ignored.
+type ConversationFilter struct {
+	TenantID   int
+	PropertyID int
+	Limit      int
+	Offset     int
+}
+
+type MessagingService struct {
+	DB            *database.Database
+	Notifications *NotificationService
+}
+
+func NewMessagingService(db *database.Database, notifications *NotificationService) *MessagingService {
+	return &MessagingService{DB: db, Notifications: notifications}
+}
+
+const conversationSelect = `
+	SELECT c.id, c.landlord_id, c.tenant_id, t.tenant_name, u.unit_name, c.subject, c.last_message_at, c.created_at,
+		(SELECT COUNT(*) FROM messages m WHERE m.conversation_id = c.id AND m.sender_type <> %s AND m.read_at IS NULL),
+		lm.id, lm.sender_type, lm.content, lm.created_at
+	FROM conversations c
+	JOIN tenants t ON c.tenant_id = t.id
+	JOIN units u ON t.unit_id = u.id
+	LEFT JOIN LATERAL (
+		SELECT id, sender_type, content, created_at FROM messages
+		WHERE conversation_id = c.id ORDER BY id DESC LIMIT 1
+	) lm ON TRUE`
+
+// ListConversations returns the participant's conversations, most recently active first
+func (s *MessagingService) ListConversations(ctx context.Context, p Participant, f ConversationFilter) ([]models.Conversation, error) {
+	var args queryArgs
+	query := fmt.Sprintf(conversationSelect, args.add(p.senderType())) + " WHERE " + p.scope(permissions.MessagesRead, &args)
+	if f.TenantID != 0 {
+		query += " AND c.tenant_id = " + args.add(f.TenantID)
+	}
+	if f.PropertyID != 0 {
+		query += " AND u.property_id = " + args.add(f.PropertyID)
+	}
+	query += " ORDER BY c.last_message_at DESC, c.id DESC LIMIT " + args.add(f.Limit) + " OFFSET " + args.add(f.Offset)
+	return s.queryConversations(ctx, query, args...)
+}
+
+// GetConversation returns one conversation the participant can read
+func (s *MessagingService) GetConversation(ctx context.Context, p Participant, id int64) (models.Conversation, error) {
+	return s.conversation(ctx, p, permissions.MessagesRead, id)
+}
+
+func (s *MessagingService) conversation(ctx context.Context, p Participant, perm permissions.Permission, id int64) (models.Conversation, error) {
+	var args queryArgs
+	query := fmt.Sprintf(conversationSelect, args.add(p.senderType())) +
+		" WHERE c.id = " + args.add(id) + " AND " + p.scope(perm, &args)
+	list, err := s.queryConversations(ctx, query, args...)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	if len(list) == 0 {
+		return models.Conversation{}, ErrConversationNotFound
+	}
+	return list[0], nil
+}
+
+func (s *MessagingService) queryConversations(ctx context.Context, query string, args ...interface{}) ([]models.Conversation, error) {
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Conversation{}
+	for rows.Next() {
+		var c models.Conversation
+		var lastID sql.NullInt64
+		var lastSender, lastContent sql.NullString
+		var lastAt sql.NullTime
+		err := rows.Scan(&c.ID, &c.LandlordID, &c.TenantID, &c.TenantName, &c.UnitName, &c.Subject, &c.LastMessageAt, &c.CreatedAt,
+			&c.UnreadCount, &lastID, &lastSender, &lastContent, &lastAt)
+		if err != nil {
+			return nil, err
+		}
+		if lastID.Valid {
+			c.LastMessage = &models.Message{
+				ID:             uint(lastID.Int64),
+				ConversationID: c.ID,
+				SenderType:     lastSender.String,
+				Content:        lastContent.String,
+				CreatedAt:      lastAt.Time,
+			}
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
+
+// CreateConversation starts a thread with a first message. Staff pick the
+// tenant; a tenant always writes to their own landlord and tenantID is ignored.
+func (s *MessagingService) CreateConversation(ctx context.Context, p Participant, tenantID int, subject, content string, attachments []models.MessageAttachment) (models.Conversation, error) {
+	if err := validateMessage(content, attachments); err != nil {
+		return models.Conversation{}, err
+	}
+
+	var landlordID int
+	var err error
+	if p.Tenant {
+		err = s.DB.QueryRowContext(ctx, "SELECT id, landlord_id FROM tenants WHERE user_id = $1", p.UserID).Scan(&tenantID, &landlordID)
+		if err == sql.ErrNoRows {
+			return models.Conversation{}, ErrTenantNotLinked
+		}
+	} else {
+		var args queryArgs
+		query := `SELECT t.landlord_id FROM tenants t JOIN units u ON t.unit_id = u.id
+			WHERE t.id = ` + args.add(tenantID) + " AND " + p.scope(permissions.MessagesWrite, &args)
+		err = s.DB.QueryRowContext(ctx, query, args...).Scan(&landlordID)
+		if err == sql.ErrNoRows {
+			return models.Conversation{}, ErrRecipientNotFound
+		}
+	}
+	if err != nil {
+		return models.Conversation{}, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	defer tx.Rollback()
+
+	var conversationID int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO conversations (landlord_id, tenant_id, subject, created_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id`,
+		landlordID, tenantID, subject, p.UserID,
+	).Scan(&conversationID)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	messageID, err := insertMessage(ctx, tx, conversationID, p, content, attachments, nil)
+	if err != nil {
+		return models.Conversation{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Conversation{}, err
+	}
+
+	s.deliver(ctx, p, landlordID, tenantID, messageID, content)
+	return s.conversation(ctx, p, permissions.MessagesRead, conversationID)
+}
+
+// ListMessages returns a conversation's messages, newest first
+func (s *MessagingService) ListMessages(ctx context.Context, p Participant, conversationID int64, limit, offset int) ([]models.Message, error) {
+	if _, err := s.conversation(ctx, p, permissions.MessagesRead, conversationID); err != nil {
+		return nil, err
+	}
+	return s.queryMessages(ctx, "m.conversation_id = $1 ORDER BY m.id DESC LIMIT $2 OFFSET $3", conversationID, limit, offset)
+}
+
+// SendMessage adds a message to a conversation and forwards it to the other side
+func (s *MessagingService) SendMessage(ctx context.Context, p Participant, conversationID int64, content string, attachments []models.MessageAttachment) (models.Message, error) {
+	if err := validateMessage(content, attachments); err != nil {
+		return models.Message{}, err
+	}
+	conv, err := s.conversation(ctx, p, permissions.MessagesWrite, conversationID)
+	if err != nil {
+		return models.Message{}, err
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Message{}, err
+	}
+	defer tx.Rollback()
+
+	messageID, err := insertMessage(ctx, tx, conversationID, p, content, attachments, nil)
+	if err != nil {
+		return models.Message{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Message{}, err
+	}
+
+	s.deliver(ctx, p, int(conv.LandlordID), int(conv.TenantID), messageID, content)
+	list, err := s.queryMessages(ctx, "m.id = $1", messageID)
+	if err != nil {
+		return models.Message{}, err
+	}
+	return list[0], nil
+}
+
+// MarkRead records that the participant read the other side's messages,
+// returning how many were newly marked
+func (s *MessagingService) MarkRead(ctx context.Context, p Participant, conversationID int64) (int64, error) {
+	if _, err := s.conversation(ctx, p, permissions.MessagesRead, conversationID); err != nil {
+		return 0, err
+	}
+	res, err := s.DB.ExecContext(ctx, `
+		UPDATE messages SET read_at = NOW()
+		WHERE conversation_id = $1 AND sender_type <> $2 AND read_at IS NULL`,
+		conversationID, p.senderType(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetAttachment returns an attachment with its data
+func (s *MessagingService) GetAttachment(ctx context.Context, p Participant, conversationID, attachmentID int64) (models.MessageAttachment, error) {
+	if _, err := s.conversation(ctx, p, permissions.MessagesRead, conversationID); err != nil {
+		return models.MessageAttachment{}, err
+	}
+	var a models.MessageAttachment
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT a.id, a.filename, a.content_type, a.size, a.data
+		FROM message_attachments a
+		JOIN messages m ON a.message_id = m.id
+		WHERE a.id = $1 AND m.conversation_id = $2`,
+		attachmentID, conversationID,
+	).Scan(&a.ID, &a.Filename, &a.ContentType, &a.Size, &a.Data)
+	if err == sql.ErrNoRows {
+		return a, ErrAttachmentNotFound
+	}
+	return a, err
+}
+
+// Broadcast sends a message to every tenant of a property, each in a new
+// conversation of their own so replies stay private
+func (s *MessagingService) Broadcast(ctx context.Context, p Participant, propertyID int, subject, content string) (models.Broadcast, error) {
+	if p.Tenant {
+		return models.Broadcast{}, ErrStaffOnly
+	}
+	if err := validateMessage(content, nil); err != nil {
+		return models.Broadcast{}, err
+	}
+
+	var landlordID int
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT landlord_id FROM properties
+		WHERE id = $1 AND id IN (SELECT accessible_property_ids($2, $3))`,
+		propertyID, p.UserID, string(permissions.MessagesWrite),
+	).Scan(&landlordID)
+	if err == sql.ErrNoRows {
+		return models.Broadcast{}, ErrRecipientNotFound
+	}
+	if err != nil {
+		return models.Broadcast{}, err
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT t.id FROM tenants t
+		JOIN units u ON t.unit_id = u.id
+		WHERE u.property_id = $1
+		ORDER BY t.id`, propertyID)
+	if err != nil {
+		return models.Broadcast{}, err
+	}
+	var tenantIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return models.Broadcast{}, err
+		}
+		tenantIDs = append(tenantIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return models.Broadcast{}, err
+	}
+	if len(tenantIDs) == 0 {
+		return models.Broadcast{}, ErrNoRecipients
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Broadcast{}, err
+	}
+	defer tx.Rollback()
+
+	b := models.Broadcast{LandlordID: uint(landlordID), PropertyID: uint(propertyID), Subject: subject, Content: content, Recipients: len(tenantIDs)}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO message_broadcasts (landlord_id, property_id, subject, content, recipients, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		landlordID, propertyID, subject, content, len(tenantIDs), p.UserID,
+	).Scan(&b.ID, &b.CreatedAt)
+	if err != nil {
+		return models.Broadcast{}, err
+	}
+
+	broadcastID := int64(b.ID)
+	messageIDs := make([]int64, len(tenantIDs))
+	for i, tenantID := range tenantIDs {
+		var conversationID int64
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO conversations (landlord_id, tenant_id, subject, created_by)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id`,
+			landlordID, tenantID, subject, p.UserID,
+		).Scan(&conversationID)
+		if err != nil {
+			return models.Broadcast{}, err
+		}
+		if messageIDs[i], err = insertMessage(ctx, tx, conversationID, p, content, nil, &broadcastID); err != nil {
+			return models.Broadcast{}, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Broadcast{}, err
+	}
+
+	for i, tenantID := range tenantIDs {
+		s.deliver(ctx, p, landlordID, tenantID, messageIDs[i], content)
+	}
+	return b, nil
+}
+
+func validateMessage(content string, attachments []models.MessageAttachment) error {
+	if strings.TrimSpace(content) == "" {
+		return ErrEmptyMessage
+	}
+	if utf8.RuneCountInString(content) > MaxMessageLength {
+		return ErrMessageTooLong
+	}
+	if len(attachments) > MaxMessageAttachments {
+		return ErrTooManyAttachments
+	}
+	for _, a := range attachments {
+		if len(a.Data) > MaxAttachmentSize {
+			return ErrAttachmentTooLarge
+		}
+	}
+	return nil
+}
+
+// insertMessage stores a message with its attachments and bumps the
+// conversation's activity time
+func insertMessage(ctx context.Context, tx *sql.Tx, conversationID int64, p Participant, content string, attachments []models.MessageAttachment, broadcastID *int64) (int64, error) {
+	var messageID int64
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO messages (conversation_id, sender_type, sender_id, content, broadcast_id)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`,
+		conversationID, p.senderType(), p.UserID, content, broadcastID,
+	).Scan(&messageID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, a := range attachments {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO message_attachments (message_id, filename, content_type, size, data)
+			VALUES ($1, $2, $3, $4, $5)`,
+			messageID, a.Filename, a.ContentType, len(a.Data), a.Data,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE conversations SET last_message_at = NOW() WHERE id = $1", conversationID)
+	return messageID, err
+}
+
+// queryMessages loads messages matching where (with its args) and their
+// attachment metadata
+func (s *MessagingService) queryMessages(ctx context.Context, where string, args ...interface{}) ([]models.Message, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT m.id, m.conversation_id, m.sender_type, m.sender_id,
+			CASE WHEN m.sender_type = 'tenant' THEN t.tenant_name ELSE COALESCE(NULLIF(su.full_name, ''), su.email, 'Staff') END,
+			CASE WHEN m.sender_type = 'tenant' THEN COALESCE(NULLIF(lu.full_name, ''), lu.email) ELSE t.tenant_name END,
+			m.content, m.broadcast_id, m.read_at, m.created_at
+		FROM messages m
+		JOIN conversations c ON m.conversation_id = c.id
+		JOIN tenants t ON c.tenant_id = t.id
+		JOIN users lu ON c.landlord_id = lu.id
+		LEFT JOIN users su ON m.sender_id = su.id
+		WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Message{}
+	index := map[int64]int{}
+	for rows.Next() {
+		var m models.Message
+		var senderID, broadcastID sql.NullInt64
+		var readAt sql.NullTime
+		err := rows.Scan(&m.ID, &m.ConversationID, &m.SenderType, &senderID, &m.Sender, &m.Receiver,
+			&m.Content, &broadcastID, &readAt, &m.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if senderID.Valid {
+			v := uint(senderID.Int64)
+			m.SenderID = &v
+		}
+		if broadcastID.Valid {
+			v := uint(broadcastID.Int64)
+			m.BroadcastID = &v
+		}
+		if readAt.Valid {
+			m.ReadAt = &readAt.Time
+		}
+		m.Attachments = []models.MessageAttachment{}
+		index[int64(m.ID)] = len(list)
+		list = append(list, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return list, nil
+	}
+
+	ids := make([]int64, 0, len(list))
+	for id := range index {
+		ids = append(ids, id)
+	}
+	attRows, err := s.DB.QueryContext(ctx, `
+		SELECT id, message_id, filename, content_type, size
+		FROM message_attachments WHERE message_id = ANY($1) ORDER BY id`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer attRows.Close()
+	for attRows.Next() {
+		var a models.MessageAttachment
+		var messageID int64
+		if err := attRows.Scan(&a.ID, &messageID, &a.Filename, &a.ContentType, &a.Size); err != nil {
+			return nil, err
+		}
+		m := &list[index[messageID]]
+		m.Attachments = append(m.Attachments, a)
+	}
+	return list, attRows.Err()
+}
+
+// deliver tells the other side about a new message. Tenants with an app
+// account get an in-app notification, others an SMS; staff messages from a
+// tenant notify the landlord in-app. Failures are logged: the message is
+// already stored.
+func (s *MessagingService) deliver(ctx context.Context, from Participant, landlordID, tenantID int, messageID int64, content string) {
+	reference := fmt.Sprintf("message:%d", messageID)
+	n := inAppNotification{
+		LandlordID: landlordID,
+		TenantID:   &tenantID,
+		Type:       messageReceived,
+		Reference:  reference,
+	}
+
+	var err error
+	if from.Tenant {
+		n.UserID = &landlordID
+		n.Message = "New message from your tenant: " + preview(content)
+		err = s.Notifications.createInApp(ctx, n)
+	} else {
+		var linked bool
+		err = s.DB.QueryRowContext(ctx, "SELECT user_id IS NOT NULL FROM tenants WHERE id = $1", tenantID).Scan(&linked)
+		if err == nil && linked {
+			n.Message = "New message from your landlord: " + preview(content)
+			err = s.Notifications.createInApp(ctx, n)
+		} else if err == nil {
+			err = s.Notifications.QueueMessageSMS(ctx, tenantID, content, reference)
+		}
+	}
+	if err != nil {
+		log.Printf("messaging: deliver message %d failed: %v", messageID, err)
+	}
+}
+
+func preview(content string) string {
+	const max = 100
+	if r := []rune(content); len(r) > max {
+		return string(r[:max-3]) + "..."
+	}
+	return content
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+)
+
+func TestValidateMessage(t *testing.T) {
+	file := func(size int) models.MessageAttachment { return models.MessageAttachment{Data: make([]byte, size)} }
+	tests := []struct {
+		name        string
+		content     string
+		attachments []models.MessageAttachment
+		want        error
+	}{
+		{"text", "The water is back on.", nil, nil},
+		{"blank", " \n\t", nil, ErrEmptyMessage},
+		{"longest", strings.Repeat("é", MaxMessageLength), nil, nil},
+		{"too long", strings.Repeat("a", MaxMessageLength+1), nil, ErrMessageTooLong},
+		{"attachments", "Lease", []models.MessageAttachment{file(10), file(MaxAttachmentSize)}, nil},
+		{"too many attachments", "Photos", make([]models.MessageAttachment, MaxMessageAttachments+1), ErrTooManyAttachments},
+		{"attachment too large", "Photo", []models.MessageAttachment{file(MaxAttachmentSize + 1)}, ErrAttachmentTooLarge},
+	}
+	for _, tt := range tests {
+		if err := validateMessage(tt.content, tt.attachments); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	if got := preview("Rent is due on the 5th."); got != "Rent is due on the 5th." {
+		t.Errorf("short message changed: %q", got)
+	}
+	long := strings.Repeat("ü", 150)
+	got := preview(long)
+	if r := []rune(got); len(r) != 100 || !strings.HasSuffix(got, "...") {
+		t.Errorf("preview is %d runes: %q", len(r), got)
+	}
+}
+
+func TestParticipantScope(t *testing.T) {
+	var args queryArgs
+	args.add(99)
+
+	tenant := NewParticipant(7, permissions.RoleTenant)
+	if got := tenant.scope(permissions.MessagesRead, &args); got != "t.user_id = $2" || tenant.senderType() != SenderTenant {
+		t.Errorf("tenant scope %q, sender %s", got, tenant.senderType())
+	}
+
+	staff := NewParticipant(8, permissions.RoleCaretaker)
+	got := staff.scope(permissions.MessagesRead, &args)
+	if got != "u.property_id IN (SELECT accessible_property_ids($3, $4))" || staff.senderType() != SenderStaff {
+		t.Errorf("staff scope %q, sender %s", got, staff.senderType())
+	}
+	if len(args) != 4 || args[2] != 8 || args[3] != string(permissions.MessagesRead) {
+		t.Errorf("args = %v", args)
+	}
+}
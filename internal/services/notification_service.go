@@ -0,0 +1,638 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/config"
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+	"github.com/Zolet-hash/smart-rentals/internal/events"
+	"github.com/Zolet-hash/smart-rentals/internal/notify"
+)
+
+// Notification statuses
+const (
+	NotificationQueued  = "queued"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+	NotificationSkipped = "skipped" // tenant opted out or the landlord disabled the message type
+)
+
+// SMS templates
+const (
+	TemplatePaymentReceived = "payment_received"
+	TemplateBalanceReminder = "balance_reminder"
+	TemplateOverdueNotice   = "overdue_notice"
+	TemplateNewMessage      = "new_message" // conversation message for tenants without the app
+)
+
+const (
+	notificationMaxAttempts = 5
+	notificationBaseBackoff = time.Minute
+	notificationLease       = 2 * time.Minute
+	notificationBatchSize   = 50
+
+	// Forwarded conversation messages are cut to three SMS parts
+	maxMessageSMSLength = 450
+
+	// Scheduled messages are only sent during the day
+	reminderSendFrom  = 8
+	reminderSendUntil = 20
+)
+
+// reminderZone is the landlords' local time (Kenya, no daylight saving).
+// Due dates and quiet hours are computed in it.
+var reminderZone = time.FixedZone("EAT", 3*60*60)
+
+var (
+	ErrInvalidSenderID     = errors.New("sender ID must be 1-11 letters, digits or spaces")
+	ErrInvalidReminderDays = errors.New("reminder_days_before must be between 1 and 14")
+)
+
+var senderIDPattern = regexp.MustCompile(`^[A-Za-z0-9 ]{1,11}$`)
+
+var smsTemplates = map[string]*template.Template{
+	TemplatePaymentReceived: smsTemplate(TemplatePaymentReceived,
+		`Dear {{.TenantName}}, payment of KES {{money .Amount}}{{with .Receipt}} (ref {{.}}){{end}} for {{.UnitName}}, {{.PropertyName}} has been received. `+
+			`{{if gt .Balance 0.0}}Balance due: KES {{money .Balance}}.{{else}}Your rent account is fully paid. Thank you.{{end}}`),
+	TemplateBalanceReminder: smsTemplate(TemplateBalanceReminder,
+		`Dear {{.TenantName}}, this is a reminder that your rent balance of KES {{money .Balance}} for {{.UnitName}}, {{.PropertyName}} `+
+			`is due on {{date .DueDate}}.`),
+	TemplateOverdueNotice: smsTemplate(TemplateOverdueNotice,
+		`Dear {{.TenantName}}, your rent balance of KES {{money .Balance}} for {{.UnitName}}, {{.PropertyName}} was due on {{date .DueDate}} `+
+			`and is now {{.DaysOverdue}} day{{if ne .DaysOverdue 1}}s{{end}} overdue. Please pay as soon as possible.`),
+	TemplateNewMessage: smsTemplate(TemplateNewMessage,
+		`{{.PropertyName}}: {{.Message}}`),
+}
+
+func smsTemplate(name, text string) *template.Template {
+	return template.Must(template.New(name).Funcs(template.FuncMap{
+		"money": documents.FormatMoney,
+		"date":  func(t time.Time) string { return t.Format("2 Jan 2006") },
+	}).Parse(text))
+}
+
+// SMSData is the data available to SMS templates
+type SMSData struct {
+	TenantName   string
+	PropertyName string
+	UnitName     string
+	Amount       float64
+	Receipt      string
+	Balance      float64
+	DueDate      time.Time
+	DaysOverdue  int
+	Message      string
+}
+
+// RenderSMS executes a named template
+func RenderSMS(name string, data SMSData) (string, error) {
+	tmpl, ok := smsTemplates[name]
+	if !ok {
+		return "", fmt.Errorf("unknown SMS template %q", name)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+type NotificationService struct {
+	DB       *database.Database
+	Cfg      *config.Config
+	Notifier notify.Notifier
+	Events   events.Publisher
+}
+
+func NewNotificationService(db *database.Database, cfg *config.Config, notifier notify.Notifier, publisher events.Publisher) *NotificationService {
+	return &NotificationService{DB: db, Cfg: cfg, Notifier: notifier, Events: publisher}
+}
+
+// NotificationSettings are a landlord's SMS preferences
+type NotificationSettings struct {
+	SenderID           string `json:"sms_sender_id"` // empty uses the platform default
+	PaymentReceipts    bool   `json:"payment_receipts"`
+	BalanceReminders   bool   `json:"balance_reminders"`
+	OverdueNotices     bool   `json:"overdue_notices"`
+	ReminderDaysBefore int    `json:"reminder_days_before"`
+}
+
+// DefaultNotificationSettings apply to landlords who have not saved settings
+var DefaultNotificationSettings = NotificationSettings{
+	PaymentReceipts:    true,
+	BalanceReminders:   true,
+	OverdueNotices:     true,
+	ReminderDaysBefore: 3,
+}
+
+// Notification is a stored outbound message
+type Notification struct {
+	ID        int64      `json:"id"`
+	TenantID  *int       `json:"tenant_id"`
+	Channel   string     `json:"channel"`
+	Template  string     `json:"template"`
+	Recipient string     `json:"recipient"`
+	Subject   string     `json:"subject,omitempty"` // email only
+	Body      string     `json:"body"`
+	Status    string     `json:"status"`
+	Attempts  int        `json:"attempts"`
+	LastError string     `json:"last_error"`
+	SentAt    *time.Time `json:"sent_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NotificationFilter narrows ListNotifications. Zero values are ignored.
+type NotificationFilter struct {
+	Channel  string
+	TenantID int
+	Status   string
+	Template string
+	Limit    int
+}
+
+// --- Settings ---
+
+// GetSettings returns the landlord's settings, or the defaults
+func (s *NotificationService) GetSettings(ctx context.Context, landlordID int) (NotificationSettings, error) {
+	settings := DefaultNotificationSettings
+	var senderID sql.NullString
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT sms_sender_id, payment_receipts, balance_reminders, overdue_notices, reminder_days_before
+		FROM notification_settings WHERE landlord_id = $1`, landlordID,
+	).Scan(&senderID, &settings.PaymentReceipts, &settings.BalanceReminders, &settings.OverdueNotices, &settings.ReminderDaysBefore)
+	if err == sql.ErrNoRows {
+		return DefaultNotificationSettings, nil
+	}
+	settings.SenderID = senderID.String
+	return settings, err
+}
+
+// SaveSettings validates and stores the landlord's settings
+func (s *NotificationService) SaveSettings(ctx context.Context, landlordID int, settings NotificationSettings) error {
+	settings.SenderID = strings.TrimSpace(settings.SenderID)
+	if settings.SenderID != "" && !senderIDPattern.MatchString(settings.SenderID) {
+		return ErrInvalidSenderID
+	}
+	if settings.ReminderDaysBefore < 1 || settings.ReminderDaysBefore > 14 {
+		return ErrInvalidReminderDays
+	}
+
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO notification_settings (landlord_id, sms_sender_id, payment_receipts, balance_reminders, overdue_notices, reminder_days_before)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6)
+		ON CONFLICT (landlord_id) DO UPDATE SET
+			sms_sender_id = EXCLUDED.sms_sender_id,
+			payment_receipts = EXCLUDED.payment_receipts,
+			balance_reminders = EXCLUDED.balance_reminders,
+			overdue_notices = EXCLUDED.overdue_notices,
+			reminder_days_before = EXCLUDED.reminder_days_before,
+			updated_at = NOW()`,
+		landlordID, settings.SenderID, settings.PaymentReceipts, settings.BalanceReminders, settings.OverdueNotices, settings.ReminderDaysBefore,
+	)
+	return err
+}
+
+// ListNotifications returns the landlord's outbound messages, newest first
+func (s *NotificationService) ListNotifications(ctx context.Context, landlordID int, f NotificationFilter) ([]Notification, error) {
+	query := `
+		SELECT id, tenant_id, channel, template, recipient, COALESCE(subject, ''), body, status, attempts, COALESCE(last_error, ''), sent_at, created_at
+		FROM notifications
+		WHERE landlord_id = $1`
+	args := []interface{}{landlordID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if f.Channel != "" {
+		query += " AND channel = " + arg(f.Channel)
+	}
+	if f.TenantID != 0 {
+		query += " AND tenant_id = " + arg(f.TenantID)
+	}
+	if f.Status != "" {
+		query += " AND status = " + arg(f.Status)
+	}
+	if f.Template != "" {
+		query += " AND template = " + arg(f.Template)
+	}
+	query += " ORDER BY id DESC LIMIT " + arg(f.Limit)
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Notification{}
+	for rows.Next() {
+		var n Notification
+		var tenantID sql.NullInt64
+		var sentAt sql.NullTime
+		if err := rows.Scan(&n.ID, &tenantID, &n.Channel, &n.Template, &n.Recipient, &n.Subject, &n.Body, &n.Status,
+			&n.Attempts, &n.LastError, &sentAt, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		if tenantID.Valid {
+			v := int(tenantID.Int64)
+			n.TenantID = &v
+		}
+		if sentAt.Valid {
+			n.SentAt = &sentAt.Time
+		}
+		list = append(list, n)
+	}
+	return list, rows.Err()
+}
+
+// --- Recipients ---
+
+// smsRecipient is a tenant with everything needed to address and render an SMS
+type smsRecipient struct {
+	TenantID     int
+	LandlordID   int
+	TenantName   string
+	Phone        string
+	Balance      float64
+	RentDueDay   int
+	OptedOut     bool
+	CreatedAt    time.Time
+	UnitName     string
+	PropertyName string
+	Settings     NotificationSettings
+}
+
+const smsRecipientQuery = `
+	SELECT t.id, t.landlord_id, t.tenant_name, t.payment_no1, COALESCE(t.balance, 0), t.rent_due_day, t.sms_opt_out, t.created_at,
+		u.unit_name, p.title,
+		COALESCE(ns.sms_sender_id, ''), COALESCE(ns.payment_receipts, TRUE), COALESCE(ns.balance_reminders, TRUE),
+		COALESCE(ns.overdue_notices, TRUE), COALESCE(ns.reminder_days_before, 3)
+	FROM tenants t
+	JOIN units u ON t.unit_id = u.id
+	JOIN properties p ON u.property_id = p.id
+	LEFT JOIN notification_settings ns ON ns.landlord_id = t.landlord_id`
+
+func scanSMSRecipient(row rowScanner) (smsRecipient, error) {
+	var r smsRecipient
+	err := row.Scan(&r.TenantID, &r.LandlordID, &r.TenantName, &r.Phone, &r.Balance, &r.RentDueDay, &r.OptedOut, &r.CreatedAt,
+		&r.UnitName, &r.PropertyName,
+		&r.Settings.SenderID, &r.Settings.PaymentReceipts, &r.Settings.BalanceReminders,
+		&r.Settings.OverdueNotices, &r.Settings.ReminderDaysBefore)
+	return r, err
+}
+
+// queueSMS renders a template for a tenant and queues it. The message is
+// stored as skipped when the tenant opted out or the type is disabled, so
+// the log explains why nothing was sent. A non-empty reference makes the
+// call idempotent; it reports whether a new row was created.
+func (s *NotificationService) queueSMS(ctx context.Context, r smsRecipient, tmpl string, enabled bool, data SMSData, reference string) (bool, error) {
+	data.TenantName = r.TenantName
+	data.UnitName = r.UnitName
+	data.PropertyName = r.PropertyName
+	body, err := RenderSMS(tmpl, data)
+	if err != nil {
+		return false, err
+	}
+
+	status, reason := NotificationQueued, ""
+	switch {
+	case r.OptedOut:
+		status, reason = NotificationSkipped, "tenant opted out of SMS"
+	case !enabled:
+		status, reason = NotificationSkipped, "disabled in landlord settings"
+	}
+
+	result, err := s.DB.ExecContext(ctx, `
+		INSERT INTO notifications (landlord_id, tenant_id, channel, template, recipient, sender, body, reference, status, last_error)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, NULLIF($8, ''), $9, NULLIF($10, ''))
+		ON CONFLICT (channel, reference) WHERE reference IS NOT NULL DO NOTHING`,
+		r.LandlordID, r.TenantID, notify.ChannelSMS, tmpl, r.Phone, r.Settings.SenderID, body, reference, status, reason,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// --- Event intake ---
+
+// HandleEvent queues a receipt SMS and receipt emails when a payment is
+// recorded against a tenant. Subscribe it to the event bus.
+func (s *NotificationService) HandleEvent(ctx context.Context, e events.Event) {
+	if e.Type != events.PaymentCompleted {
+		return
+	}
+	paymentID, err := eventInt(e.Data, "payment_id")
+	if err != nil {
+		log.Printf("notifications: %s without payment_id: %v", e.Type, err)
+		return
+	}
+	if err := s.queuePaymentReceipt(ctx, paymentID); err != nil {
+		log.Printf("notifications: queue receipt for payment %d failed: %v", paymentID, err)
+	}
+	if err := s.queueReceiptEmails(ctx, paymentID); err != nil {
+		log.Printf("notifications: queue receipt emails for payment %d failed: %v", paymentID, err)
+	}
+}
+
+func (s *NotificationService) queuePaymentReceipt(ctx context.Context, paymentID int64) error {
+	var tenantID int
+	var amount float64
+	var receipt sql.NullString
+	err := s.DB.QueryRowContext(ctx, "SELECT tenant_id, amount, receipt FROM payments WHERE id = $1 AND tenant_id IS NOT NULL", paymentID).
+		Scan(&tenantID, &amount, &receipt)
+	if err != nil {
+		return err
+	}
+
+	r, err := scanSMSRecipient(s.DB.QueryRowContext(ctx, smsRecipientQuery+" WHERE t.id = $1", tenantID))
+	if err != nil {
+		return err
+	}
+
+	_, err = s.queueSMS(ctx, r, TemplatePaymentReceived, r.Settings.PaymentReceipts, SMSData{
+		Amount:  amount,
+		Receipt: receipt.String,
+		Balance: r.Balance,
+	}, fmt.Sprintf("payment_received:%d", paymentID))
+	return err
+}
+
+// eventInt reads an integer field from event data, accepting numbers and
+// numeric strings (handlers pass path params through as strings)
+func eventInt(data interface{}, field string) (int64, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return 0, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return 0, err
+	}
+	v, ok := m[field]
+	if !ok || v == nil {
+		return 0, fmt.Errorf("missing %s", field)
+	}
+	return strconv.ParseInt(fmt.Sprint(v), 10, 64)
+}
+
+// --- Reminder scheduler ---
+
+// RunReminders checks for due reminders, overdue balances and monthly
+// statements every interval until ctx is cancelled. Nothing is sent outside daytime hours. Several
+// instances may run: each message is deduplicated by its reference.
+func (s *NotificationService) RunReminders(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now().In(reminderZone)
+		if h := now.Hour(); h >= reminderSendFrom && h < reminderSendUntil {
+			if _, err := s.SendReminders(ctx, now); err != nil {
+				log.Printf("notifications: reminder run failed: %v", err)
+			}
+			if _, err := s.SendMonthlyStatements(ctx, now); err != nil {
+				log.Printf("notifications: statement run failed: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendReminders queues a balance reminder for tenants whose next due date is
+// within their landlord's reminder window, and an overdue notice (plus an
+// invoice.overdue event) once per missed due date. It returns how many
+// messages were queued.
+func (s *NotificationService) SendReminders(ctx context.Context, now time.Time) (int, error) {
+	rows, err := s.DB.QueryContext(ctx, smsRecipientQuery+" WHERE t.balance > 0 ORDER BY t.id")
+	if err != nil {
+		return 0, err
+	}
+	var recipients []smsRecipient
+	for rows.Next() {
+		r, err := scanSMSRecipient(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		recipients = append(recipients, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	today := dateOf(now.In(reminderZone))
+	queued := 0
+	for _, r := range recipients {
+		lastDue, nextDue := rentDueDates(today, r.RentDueDay)
+
+		if days := daysBetween(today, nextDue); days <= r.Settings.ReminderDaysBefore {
+			created, err := s.queueSMS(ctx, r, TemplateBalanceReminder, r.Settings.BalanceReminders, SMSData{
+				Balance: r.Balance,
+				DueDate: nextDue,
+			}, fmt.Sprintf("balance_reminder:%d:%s", r.TenantID, nextDue.Format("2006-01-02")))
+			if err != nil {
+				return queued, err
+			}
+			if created {
+				queued++
+			}
+		}
+
+		// Only due dates after the tenancy started count as missed
+		if !lastDue.After(dateOf(r.CreatedAt.In(reminderZone))) {
+			continue
+		}
+		daysOverdue := daysBetween(lastDue, today)
+		created, err := s.queueSMS(ctx, r, TemplateOverdueNotice, r.Settings.OverdueNotices, SMSData{
+			Balance:     r.Balance,
+			DueDate:     lastDue,
+			DaysOverdue: daysOverdue,
+		}, fmt.Sprintf("overdue_notice:%d:%s", r.TenantID, lastDue.Format("2006-01-02")))
+		if err != nil {
+			return queued, err
+		}
+		if created {
+			queued++
+			s.Events.Publish(ctx, events.New(events.InvoiceOverdue, r.LandlordID, map[string]interface{}{
+				"tenant_id":    r.TenantID,
+				"tenant_name":  r.TenantName,
+				"unit_name":    r.UnitName,
+				"balance":      r.Balance,
+				"due_date":     lastDue.Format("2006-01-02"),
+				"days_overdue": daysOverdue,
+			}))
+		}
+	}
+	return queued, nil
+}
+
+// rentDueDates returns the latest due date before today and the first due
+// date on or after today for a rent due day (1-28)
+func rentDueDates(today time.Time, day int) (last, next time.Time) {
+	y, m, _ := today.Date()
+	due := time.Date(y, m, day, 0, 0, 0, 0, today.Location())
+	if due.Before(today) {
+		return due, due.AddDate(0, 1, 0)
+	}
+	return due.AddDate(0, -1, 0), due
+}
+
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// --- Dispatcher ---
+
+type claimedNotification struct {
+	ID        int64
+	Channel   string
+	Recipient string
+	Sender    string
+	Subject   string
+	Body      string
+	HTML      string
+	Attempts  int
+}
+
+// Run sends queued notifications every interval until ctx is cancelled.
+// Rows are claimed with SKIP LOCKED and leased, so several instances may run.
+func (s *NotificationService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := s.ProcessQueued(ctx)
+			if err != nil {
+				log.Printf("notifications: dispatcher error: %v", err)
+				break
+			}
+			if n < notificationBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessQueued claims a batch of due notifications and sends them, returning how many were claimed
+func (s *NotificationService) ProcessQueued(ctx context.Context) (int, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		UPDATE notifications SET next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM notifications
+			WHERE status = 'queued' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, channel, recipient, COALESCE(sender, ''), COALESCE(subject, ''), body, COALESCE(html_body, ''), attempts`,
+		notificationBatchSize, notificationLease.Seconds(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var batch []claimedNotification
+	for rows.Next() {
+		var n claimedNotification
+		if err := rows.Scan(&n.ID, &n.Channel, &n.Recipient, &n.Sender, &n.Subject, &n.Body, &n.HTML, &n.Attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, n := range batch {
+		s.send(ctx, n)
+	}
+	return len(batch), nil
+}
+
+// send delivers one notification and records the outcome, retrying with
+// exponential backoff on failure
+func (s *NotificationService) send(ctx context.Context, n claimedNotification) {
+	attempts := n.Attempts + 1
+	msg := notify.Message{
+		Channel: n.Channel,
+		To:      n.Recipient,
+		From:    n.Sender,
+		Subject: n.Subject,
+		Body:    n.Body,
+		HTML:    n.HTML,
+	}
+	var err error
+	if n.Channel == notify.ChannelEmail {
+		msg.Attachments, err = s.loadAttachments(ctx, n.ID)
+	}
+	if err == nil {
+		err = s.Notifier.Send(ctx, msg)
+	}
+
+	var query string
+	var args []interface{}
+	switch {
+	case err == nil:
+		query = "UPDATE notifications SET status = 'sent', attempts = $2, last_error = NULL, sent_at = NOW() WHERE id = $1"
+		args = []interface{}{n.ID, attempts}
+	case attempts >= notificationMaxAttempts:
+		query = "UPDATE notifications SET status = 'failed', attempts = $2, last_error = $3 WHERE id = $1"
+		args = []interface{}{n.ID, attempts, err.Error()}
+	default:
+		backoff := notificationBaseBackoff * time.Duration(1<<(attempts-1))
+		query = "UPDATE notifications SET attempts = $2, last_error = $3, next_attempt_at = NOW() + $4 * INTERVAL '1 second' WHERE id = $1"
+		args = []interface{}{n.ID, attempts, err.Error(), backoff.Seconds()}
+	}
+	if _, dbErr := s.DB.ExecContext(ctx, query, args...); dbErr != nil {
+		log.Printf("notifications: record result for %d failed: %v", n.ID, dbErr)
+	}
+}
+
+// QueueMessageSMS forwards a conversation message to a tenant by SMS,
+// honouring their opt-out. Long messages are shortened.
+func (s *NotificationService) QueueMessageSMS(ctx context.Context, tenantID int, message, reference string) error {
+	r, err := scanSMSRecipient(s.DB.QueryRowContext(ctx, smsRecipientQuery+" WHERE t.id = $1", tenantID))
+	if err != nil {
+		return err
+	}
+	if runes := []rune(message); len(runes) > maxMessageSMSLength {
+		message = string(runes[:maxMessageSMSLength-3]) + "..."
+	}
+	_, err = s.queueSMS(ctx, r, TemplateNewMessage, true, SMSData{Message: message}, reference)
+	return err
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRenderSMS(t *testing.T) {
+	due := time.Date(2026, time.October, 5, 0, 0, 0, 0, reminderZone)
+	tests := []struct {
+		name     string
+		template string
+		data     SMSData
+		want     string
+	}{
+		{"receipt with balance", TemplatePaymentReceived,
+			SMSData{TenantName: "Jane", PropertyName: "Kamau Flats", UnitName: "A4", Amount: 12500, Receipt: "SJ12ABC", Balance: 2500},
+			"Dear Jane, payment of KES 12,500 (ref SJ12ABC) for A4, Kamau Flats has been received. Balance due: KES 2,500."},
+		{"receipt paid up", TemplatePaymentReceived,
+			SMSData{TenantName: "Jane", PropertyName: "Kamau Flats", UnitName: "A4", Amount: 15000},
+			"Dear Jane, payment of KES 15,000 for A4, Kamau Flats has been received. Your rent account is fully paid. Thank you."},
+		{"reminder", TemplateBalanceReminder,
+			SMSData{TenantName: "Jane", PropertyName: "Kamau Flats", UnitName: "A4", Balance: 15000.5, DueDate: due},
+			"Dear Jane, this is a reminder that your rent balance of KES 15,000.50 for A4, Kamau Flats is due on 5 Oct 2026."},
+		{"overdue one day", TemplateOverdueNotice,
+			SMSData{TenantName: "Jane", PropertyName: "Kamau Flats", UnitName: "A4", Balance: 15000, DueDate: due, DaysOverdue: 1},
+			"Dear Jane, your rent balance of KES 15,000 for A4, Kamau Flats was due on 5 Oct 2026 and is now 1 day overdue. Please pay as soon as possible."},
+		{"overdue days", TemplateOverdueNotice,
+			SMSData{TenantName: "Jane", PropertyName: "Kamau Flats", UnitName: "A4", Balance: 15000, DueDate: due, DaysOverdue: 3},
+			"Dear Jane, your rent balance of KES 15,000 for A4, Kamau Flats was due on 5 Oct 2026 and is now 3 days overdue. Please pay as soon as possible."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderSMS(tt.template, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+	if _, err := RenderSMS("no_such_template", SMSData{}); err == nil {
+		t.Error("unknown template rendered")
+	}
+}
+
+func TestRentDueDates(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 0, 0, 0, 0, reminderZone) }
+	tests := []struct {
+		today      time.Time
+		dueDay     int
+		last, next time.Time
+	}{
+		{day(time.October, 18), 5, day(time.October, 5), day(time.November, 5)},
+		{day(time.October, 5), 5, day(time.September, 5), day(time.October, 5)},
+		{day(time.October, 1), 5, day(time.September, 5), day(time.October, 5)},
+		{day(time.December, 20), 1, day(time.December, 1), time.Date(2027, time.January, 1, 0, 0, 0, 0, reminderZone)},
+	}
+	for _, tt := range tests {
+		last, next := rentDueDates(tt.today, tt.dueDay)
+		if !last.Equal(tt.last) || !next.Equal(tt.next) {
+			t.Errorf("rentDueDates(%s, %d) = %s, %s; want %s, %s", tt.today.Format(dateLayout), tt.dueDay,
+				last.Format(dateLayout), next.Format(dateLayout), tt.last.Format(dateLayout), tt.next.Format(dateLayout))
+		}
+	}
+}
+
+func TestEventInt(t *testing.T) {
+	tests := []struct {
+		data    interface{}
+		want    int64
+		wantErr bool
+	}{
+		{map[string]interface{}{"payment_id": 42}, 42, false},
+		{map[string]interface{}{"payment_id": "42"}, 42, false},
+		{struct {
+			PaymentID int64 `json:"payment_id"`
+		}{9007199254740993}, 9007199254740993, false},
+		{map[string]interface{}{"payment_id": nil}, 0, true},
+		{map[string]interface{}{}, 0, true},
+		{map[string]interface{}{"payment_id": "abc"}, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := eventInt(tt.data, "payment_id")
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("eventInt(%v) = %d, %v; want %d, error %v", tt.data, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
@@ -9,21 +10,36 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Zolet-hash/smart-rentals/internal/config"
 	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/events"
 	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/phone"
 	"github.com/Zolet-hash/smart-rentals/internal/utils"
 )
 
+// PaymentCategories are what a payment can be for. Only rent counts towards
+// Monthly Rental Income tax.
+var PaymentCategories = []string{"rent", "deposit", "service_charge", "utilities", "other"}
+
+var ErrInvalidPaymentCategory = fmt.Errorf("category must be one of %s", strings.Join(PaymentCategories, ", "))
+
+// ValidPaymentCategory reports whether category is one of PaymentCategories
+func ValidPaymentCategory(category string) bool {
+	return contains(PaymentCategories, category)
+}
+
 type PaymentService struct {
-	DB  *database.Database
-	Cfg *config.Config
+	DB     *database.Database
+	Cfg    *config.Config
+	Events events.Publisher
 }
 
-func NewPaymentService(db *database.Database, cfg *config.Config) *PaymentService {
-	return &PaymentService{DB: db, Cfg: cfg}
+func NewPaymentService(db *database.Database, cfg *config.Config, publisher events.Publisher) *PaymentService {
+	return &PaymentService{DB: db, Cfg: cfg, Events: publisher}
 }
 
 // --- Types ---
@@ -226,33 +242,21 @@ func (s *PaymentService) ProcessCallback(payload C2BConfirmationPayload) error {
 
 	// 3. Find Tenant (Auto-Match) within this Landlord
 	var tenantID *uint
-	var currentBalance float64
-
-	// Try match PaymentNo1 or PaymentNo2
-	// AND landlord_id = $2 to ensure we don't match tenant from another landlord (though shortcode implies landlord)
-	row := s.DB.QueryRow(`
-		SELECT id, balance 
-		FROM tenants 
-		WHERE (payment_no1 = $1 OR payment_no2 = $1) 
-		AND landlord_id = $2
-	`, payload.MSISDN, landlordID)
-
-	var tID uint
-	err = row.Scan(&tID, &currentBalance)
-
 	status := "COMPLETED"        // Default if matched
 	txnMethod := "MPESA_PAYBILL" // Could verify shortcode type but paybill/till are same bucket usually
 
-	if err == nil {
+	tID, matched, err := matchTenant(context.Background(), s.DB, int(landlordID), payload.MSISDN)
+	if err != nil {
+		return err
+	}
+	if matched {
 		// Matched!
-		tenantID = &tID
-	} else if err == sql.ErrNoRows {
+		t := uint(tID)
+		tenantID = &t
+	} else {
 		// Unmatched
 		log.Printf("Unmatched Payment from %s for Landlord %d", payload.MSISDN, landlordID)
-		tenantID = nil
 		status = "PENDING"
-	} else {
-		return err
 	}
 
 	// 4. Create Payment Record
@@ -261,10 +265,12 @@ func (s *PaymentService) ProcessCallback(payload C2BConfirmationPayload) error {
 	// Use explicit SQL to avoid GORM complexity for now, or use models if preferred. The user prompt used models.
 	// But `s.DB` is *database.Database which is sql.DB wrapper. I need to use SQL.
 
-	_, err = s.DB.Exec(`
+	var paymentID int64
+	err = s.DB.QueryRow(`
 		INSERT INTO payments (landlord_id, tenant_id, amount, status, method, receipt, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
-	`, landlordID, tenantID, payload.TransAmount, status, txnMethod, payload.TransID)
+		RETURNING id
+	`, landlordID, tenantID, payload.TransAmount, status, txnMethod, payload.TransID).Scan(&paymentID)
 
 	if err != nil {
 		return err
@@ -286,9 +292,52 @@ func (s *PaymentService) ProcessCallback(payload C2BConfirmationPayload) error {
 		}
 	}
 
+	// 6. Notify subscribers
+	eventType := events.PaymentUnmatched
+	if tenantID != nil {
+		eventType = events.PaymentCompleted
+	}
+	s.Events.Publish(context.Background(), events.New(eventType, int(landlordID), map[string]interface{}{
+		"payment_id": paymentID,
+		"tenant_id":  tenantID,
+		"amount":     payload.TransAmount,
+		"receipt":    payload.TransID,
+		"method":     txnMethod,
+		"status":     status,
+		"msisdn":     payload.MSISDN,
+		"bill_ref":   payload.BillRefNumber,
+	}))
+
 	return nil
 }
 
+// matchTenant finds the landlord's tenant paying from number, the rule for
+// M-Pesa callbacks and statements alike: the number must equal the tenant's
+// payment_no1 or payment_no2, which are stored normalized. Masked statement
+// numbers never match, nor do lines without a number, such as most bank
+// deposits; both are left for assignment by hand.
+func matchTenant(ctx context.Context, db rowQuerier, landlordID int, number string) (int, bool, error) {
+	number = phone.PaymentNumber(number)
+	if number == "" {
+		return 0, false, nil
+	}
+	var id int
+	err := db.QueryRowContext(ctx, `
+		SELECT id FROM tenants
+		WHERE (payment_no1 = $1 OR payment_no2 = $1)
+		  AND landlord_id = $2
+		ORDER BY id LIMIT 1`,
+		number, landlordID,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return id, true, nil
+}
+
 // SaveLandlordConfig upserts the config and registers URLs
 func (s *PaymentService) SaveLandlordConfig(landlordID uint, shortCode, shortCodeType, key, secret, env string, validationEnabled bool, baseURL string) error {
 	// Encrypt Secrets
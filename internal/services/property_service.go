@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/Zolet-hash/smart-rentals/internal/listing"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/Zolet-hash/smart-rentals/internal/repository"
+)
+
+var (
+	ErrPropertyNotFound   = errors.New("property not found or unauthorized")
+	ErrPropertyOccupied   = errors.New("property still has tenants; ensure all units are vacated")
+	ErrOrgForbidden       = errors.New("not allowed to add properties to this organization")
+	ErrInvalidOwner       = errors.New("owner must be an owner member of the organization")
+	ErrOwnerWithoutOrg    = errors.New("owner_id requires organization_id")
+	ErrInvalidTotalRent   = errors.New("total rent must not be negative")
+	ErrPropertyIncomplete = errors.New("title, location and property type are required")
+)
+
+// PropertyService holds the rules for properties: who may create one for
+// whom, and that occupied properties stay
+type PropertyService struct {
+	Store repository.Store
+}
+
+func NewPropertyService(store repository.Store) *PropertyService {
+	return &PropertyService{Store: store}
+}
+
+// PropertyInput creates a property. OrganizationID and OwnerID are
+// optional: with both, a member of the organization creates the property on
+// behalf of one of its owners.
+type PropertyInput struct {
+	Title          string
+	Description    string
+	Location       string
+	PropertyType   string
+	Vacancy        bool
+	TotalRent      int
+	OrganizationID int
+	OwnerID        int
+}
+
+// notFound replaces a repository miss with the service's error for the record
+func notFound(err, domainErr error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return domainErr
+	}
+	return err
+}
+
+func (s *PropertyService) GetProperty(ctx context.Context, userID int, perm permissions.Permission, id int) (models.Property, error) {
+	p, err := s.Store.Properties().Get(ctx, userID, perm, id)
+	return p, notFound(err, ErrPropertyNotFound)
+}
+
+// ListProperties lists the user's properties plus any delegated to them
+func (s *PropertyService) ListProperties(ctx context.Context, userID int, page listing.Page, f listing.Filter) (repository.Page[models.Property], error) {
+	return s.Store.Properties().List(ctx, userID, permissions.PropertiesRead, page, f)
+}
+
+// CreateProperty stores a property owned by the user, or by in.OwnerID when
+// the user may add properties to in.OrganizationID
+func (s *PropertyService) CreateProperty(ctx context.Context, userID int, in PropertyInput) (models.Property, error) {
+	if in.Title == "" || in.Location == "" || in.PropertyType == "" {
+		return models.Property{}, ErrPropertyIncomplete
+	}
+	if in.TotalRent < 0 {
+		return models.Property{}, ErrInvalidTotalRent
+	}
+
+	landlordID := userID
+	if in.OrganizationID != 0 {
+		_, perms, err := s.Store.Users().OrgMembership(ctx, in.OrganizationID, userID)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && !slices.Contains(perms, permissions.PropertiesWrite)) {
+			return models.Property{}, ErrOrgForbidden
+		}
+		if err != nil {
+			return models.Property{}, err
+		}
+		// Only owners who accepted the organization's invitation count as
+		// members, so nobody is made an owner without agreeing to it
+		if in.OwnerID != 0 && in.OwnerID != userID {
+			role, _, err := s.Store.Users().OrgMembership(ctx, in.OrganizationID, in.OwnerID)
+			if errors.Is(err, repository.ErrNotFound) || (err == nil && role != permissions.OrgRoleOwner) {
+				return models.Property{}, ErrInvalidOwner
+			}
+			if err != nil {
+				return models.Property{}, err
+			}
+			landlordID = in.OwnerID
+		}
+	} else if in.OwnerID != 0 && in.OwnerID != userID {
+		return models.Property{}, ErrOwnerWithoutOrg
+	}
+
+	p := models.Property{
+		LandlordID:   uint(landlordID),
+		Title:        in.Title,
+		Description:  in.Description,
+		Location:     in.Location,
+		PropertyType: in.PropertyType,
+		Vacancy:      in.Vacancy,
+		TotalRent:    in.TotalRent,
+	}
+	if in.OrganizationID != 0 {
+		orgID := uint(in.OrganizationID)
+		p.OrganizationID = &orgID
+	}
+	if err := s.Store.Properties().Create(ctx, &p); err != nil {
+		return models.Property{}, err
+	}
+	return p, nil
+}
+
+// UpdateProperty applies the set fields of u to a property the user may write
+func (s *PropertyService) UpdateProperty(ctx context.Context, userID, id int, u repository.PropertyUpdate) error {
+	if u.TotalRent != nil && *u.TotalRent < 0 {
+		return ErrInvalidTotalRent
+	}
+	if _, err := s.GetProperty(ctx, userID, permissions.PropertiesWrite, id); err != nil {
+		return err
+	}
+	return notFound(s.Store.Properties().Update(ctx, id, u), ErrPropertyNotFound)
+}
+
+// DeleteProperty removes a property with its units. Properties with tenants
+// are kept: remove the tenants first.
+func (s *PropertyService) DeleteProperty(ctx context.Context, userID, id int) error {
+	if _, err := s.GetProperty(ctx, userID, permissions.PropertiesWrite, id); err != nil {
+		return err
+	}
+	err := s.Store.Properties().Delete(ctx, id)
+	if errors.Is(err, repository.ErrInUse) {
+		return ErrPropertyOccupied
+	}
+	return notFound(err, ErrPropertyNotFound)
+}
+
+// Snapshot is a property's audit record
+func (s *PropertyService) Snapshot(ctx context.Context, id int) ([]byte, error) {
+	return s.Store.Properties().Snapshot(ctx, id)
+}
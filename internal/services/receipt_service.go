@@ -0,0 +1,271 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"image"
+	_ "image/jpeg" // logo formats accepted by DecodeConfig
+	_ "image/png"
+	"regexp"
+	"strings"
+
+	"github.com/Zolet-hash/smart-rentals/internal/config"
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+)
+
+const (
+	MaxLogoSize      = 1 << 20
+	maxLogoDimension = 2000 // pixels, either side
+)
+
+var (
+	ErrPaymentNotFound = errors.New("payment not found")
+	ErrNoReceipt       = errors.New("receipts are issued for completed payments matched to a tenant")
+	ErrUnknownReceipt  = errors.New("no receipt matches this verification code")
+	ErrInvalidLogo     = errors.New("logo must be a PNG or JPEG image of at most 2000x2000 pixels")
+	ErrLogoTooLarge    = errors.New("logo must be at most 1 MB")
+	ErrInvalidBranding = errors.New("accent_color must be #RRGGBB and receipt_prefix 1-10 capital letters or digits")
+	ErrNoLogo          = errors.New("no logo uploaded")
+)
+
+var (
+	verificationPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	accentPattern       = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+	prefixPattern       = regexp.MustCompile(`^[A-Z0-9]{1,10}$`)
+)
+
+// ReceiptService renders payment receipts, answers the public receipt
+// verification link and keeps each landlord's letterhead. Receipt numbers
+// and verification codes are assigned by the database when a payment is
+// completed against a tenant.
+type ReceiptService struct {
+	DB        *database.Database
+	VerifyURL string // base of the verification link; empty leaves the QR code out
+}
+
+func NewReceiptService(db *database.Database, cfg *config.Config) *ReceiptService {
+	return &ReceiptService{DB: db, VerifyURL: cfg.Receipts.VerifyURL}
+}
+
+// PaymentReceipt returns the receipt of a payment to a tenant on a property
+// the user can read payments of
+func (s *ReceiptService) PaymentReceipt(ctx context.Context, userID int, paymentID int64) (documents.Receipt, error) {
+	var exists bool
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM payments p
+			JOIN tenants t ON p.tenant_id = t.id
+			JOIN units u ON t.unit_id = u.id
+			WHERE p.id = $1 AND u.property_id IN (SELECT accessible_property_ids($2, $3))
+		)`, paymentID, userID, permissions.PaymentsRead,
+	).Scan(&exists)
+	if err != nil {
+		return documents.Receipt{}, err
+	}
+	if !exists {
+		return documents.Receipt{}, ErrPaymentNotFound
+	}
+	r, _, err := paymentReceipt(ctx, s.DB, s.VerifyURL, paymentID)
+	return r, err
+}
+
+// paymentReceipt builds the receipt document of a payment and returns its
+// landlord. The balance is the tenant's balance just after the payment.
+func paymentReceipt(ctx context.Context, db *database.Database, verifyURL string, paymentID int64) (documents.Receipt, int, error) {
+	var r documents.Receipt
+	var landlordID int
+	var number, code sql.NullString
+	var landlordName string
+	err := db.QueryRowContext(ctx, `
+		SELECT p.receipt_no, p.verification_code, COALESCE(p.receipt, ''), p.method, p.amount, p.created_at, p.landlord_id,
+			t.tenant_name, u.unit_name, pr.title, COALESCE(l.full_name, ''),
+			COALESCE(t.balance, 0)
+				+ (SELECT COALESCE(SUM(later.amount), 0) FROM payments later
+				   WHERE later.tenant_id = t.id AND later.status = 'COMPLETED' AND (later.created_at, later.id) > (p.created_at, p.id))
+				- (SELECT COALESCE(SUM(c.amount), 0) FROM tenant_charges c
+				   WHERE c.tenant_id = t.id AND c.created_at > p.created_at)
+		FROM payments p
+		JOIN tenants t ON p.tenant_id = t.id
+		JOIN units u ON t.unit_id = u.id
+		JOIN properties pr ON u.property_id = pr.id
+		JOIN users l ON p.landlord_id = l.id
+		WHERE p.id = $1`, paymentID,
+	).Scan(&number, &code, &r.Reference, &r.Method, &r.Amount, &r.Date, &landlordID,
+		&r.TenantName, &r.UnitName, &r.PropertyName, &landlordName, &r.Balance)
+	if err == sql.ErrNoRows {
+		return r, 0, ErrNoReceipt
+	}
+	if err != nil {
+		return r, 0, err
+	}
+	if !number.Valid {
+		return r, landlordID, ErrNoReceipt
+	}
+	r.Number = number.String
+	r.Date = r.Date.In(reminderZone)
+	if verifyURL != "" && code.Valid {
+		r.VerifyURL = verifyURL + "/" + code.String
+	}
+
+	branding, businessName, err := landlordLetterhead(ctx, db, landlordID)
+	if err != nil {
+		return r, landlordID, err
+	}
+	r.Branding = branding
+	r.IssuedBy = firstNonEmpty(businessName, landlordName, r.PropertyName)
+	return r, landlordID, nil
+}
+
+// landlordLetterhead returns a landlord's branding for documents, nil when
+// they have not set any, and their business name
+func landlordLetterhead(ctx context.Context, db *database.Database, landlordID int) (*documents.Branding, string, error) {
+	var b documents.Branding
+	var name string
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(business_name, ''), COALESCE(address, ''), COALESCE(phone, ''), COALESCE(email, ''),
+			COALESCE(accent_color, ''), logo, COALESCE(logo_content_type, '')
+		FROM landlord_branding WHERE landlord_id = $1`, landlordID,
+	).Scan(&name, &b.Address, &b.Phone, &b.Email, &b.AccentColor, &b.Logo, &b.LogoType)
+	if err == sql.ErrNoRows {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &b, name, nil
+}
+
+// Verify looks a receipt up by the code on its verification link
+func (s *ReceiptService) Verify(ctx context.Context, code string) (models.ReceiptVerification, error) {
+	var v models.ReceiptVerification
+	code = strings.ToLower(code)
+	if !verificationPattern.MatchString(code) {
+		return v, ErrUnknownReceipt
+	}
+	var landlordName, businessName string
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT p.receipt_no, p.amount, p.method, p.created_at, p.status,
+			t.tenant_name, pr.title, u.unit_name, COALESCE(l.full_name, ''), COALESCE(b.business_name, '')
+		FROM payments p
+		JOIN tenants t ON p.tenant_id = t.id
+		JOIN units u ON t.unit_id = u.id
+		JOIN properties pr ON u.property_id = pr.id
+		JOIN users l ON p.landlord_id = l.id
+		LEFT JOIN landlord_branding b ON b.landlord_id = p.landlord_id
+		WHERE p.verification_code = $1`, code,
+	).Scan(&v.ReceiptNo, &v.Amount, &v.Method, &v.PaidAt, &v.Status,
+		&v.TenantName, &v.PropertyTitle, &v.UnitName, &landlordName, &businessName)
+	if err == sql.ErrNoRows {
+		return v, ErrUnknownReceipt
+	}
+	if err != nil {
+		return v, err
+	}
+	v.IssuedBy = firstNonEmpty(businessName, landlordName, v.PropertyTitle)
+	v.TenantName = shortName(v.TenantName)
+	return v, nil
+}
+
+// shortName keeps the first name and the initials of the others, e.g.
+// "Wanjiru K."
+func shortName(name string) string {
+	parts := strings.Fields(name)
+	if len(parts) == 0 {
+		return ""
+	}
+	short := parts[0]
+	for _, p := range parts[1:] {
+		short += " " + string([]rune(p)[0]) + "."
+	}
+	return short
+}
+
+// --- Branding ---
+
+// GetBranding returns a landlord's letterhead, with defaults when unset
+func (s *ReceiptService) GetBranding(ctx context.Context, landlordID int) (models.LandlordBranding, error) {
+	b := models.LandlordBranding{LandlordID: uint(landlordID), ReceiptPrefix: "RCT"}
+	err := s.DB.QueryRowContext(ctx, `
+		SELECT COALESCE(business_name, ''), COALESCE(address, ''), COALESCE(phone, ''), COALESCE(email, ''),
+			COALESCE(accent_color, ''), receipt_prefix, logo IS NOT NULL, updated_at
+		FROM landlord_branding WHERE landlord_id = $1`, landlordID,
+	).Scan(&b.BusinessName, &b.Address, &b.Phone, &b.Email, &b.AccentColor, &b.ReceiptPrefix, &b.HasLogo, &b.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return b, nil
+	}
+	return b, err
+}
+
+// SaveBranding replaces a landlord's letterhead details, keeping the logo.
+// A new receipt prefix applies to receipts issued from then on.
+func (s *ReceiptService) SaveBranding(ctx context.Context, landlordID int, b models.LandlordBranding) (models.LandlordBranding, error) {
+	if b.ReceiptPrefix == "" {
+		b.ReceiptPrefix = "RCT"
+	}
+	if (b.AccentColor != "" && !accentPattern.MatchString(b.AccentColor)) || !prefixPattern.MatchString(b.ReceiptPrefix) {
+		return b, ErrInvalidBranding
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO landlord_branding (landlord_id, business_name, address, phone, email, accent_color, receipt_prefix)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7)
+		ON CONFLICT (landlord_id) DO UPDATE SET
+			business_name = EXCLUDED.business_name,
+			address = EXCLUDED.address,
+			phone = EXCLUDED.phone,
+			email = EXCLUDED.email,
+			accent_color = EXCLUDED.accent_color,
+			receipt_prefix = EXCLUDED.receipt_prefix,
+			updated_at = NOW()`,
+		landlordID, b.BusinessName, b.Address, b.Phone, b.Email, b.AccentColor, b.ReceiptPrefix,
+	)
+	if err != nil {
+		return b, err
+	}
+	return s.GetBranding(ctx, landlordID)
+}
+
+// SetLogo stores the logo printed on a landlord's documents
+func (s *ReceiptService) SetLogo(ctx context.Context, landlordID int, data []byte) (models.LandlordBranding, error) {
+	if len(data) > MaxLogoSize {
+		return models.LandlordBranding{}, ErrLogoTooLarge
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "png" && format != "jpeg") || cfg.Width > maxLogoDimension || cfg.Height > maxLogoDimension {
+		return models.LandlordBranding{}, ErrInvalidLogo
+	}
+	_, err = s.DB.ExecContext(ctx, `
+		INSERT INTO landlord_branding (landlord_id, logo, logo_content_type)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (landlord_id) DO UPDATE SET logo = EXCLUDED.logo, logo_content_type = EXCLUDED.logo_content_type, updated_at = NOW()`,
+		landlordID, data, "image/"+format,
+	)
+	if err != nil {
+		return models.LandlordBranding{}, err
+	}
+	return s.GetBranding(ctx, landlordID)
+}
+
+// DeleteLogo removes a landlord's logo
+func (s *ReceiptService) DeleteLogo(ctx context.Context, landlordID int) error {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE landlord_branding SET logo = NULL, logo_content_type = NULL, updated_at = NOW()
+		WHERE landlord_id = $1`, landlordID)
+	return err
+}
+
+// Logo returns a landlord's logo and its content type
+func (s *ReceiptService) Logo(ctx context.Context, landlordID int) ([]byte, string, error) {
+	var data []byte
+	var contentType sql.NullString
+	err := s.DB.QueryRowContext(ctx, "SELECT logo, logo_content_type FROM landlord_branding WHERE landlord_id = $1", landlordID).
+		Scan(&data, &contentType)
+	if err == sql.ErrNoRows || (err == nil && data == nil) {
+		return nil, "", ErrNoLogo
+	}
+	return data, contentType.String, err
+}
@@ -0,0 +1,17 @@
+package services
+
+import "testing"
+
+func TestShortName(t *testing.T) {
+	for name, want := range map[string]string{
+		"Jane Wanjiru Kamau": "Jane W. K.",
+		"  Jane   Wanjiru ":  "Jane W.",
+		"Jane":               "Jane",
+		"Ébène Ölander":      "Ébène Ö.",
+		"":                   "",
+	} {
+		if got := shortName(name); got != want {
+			t.Errorf("shortName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
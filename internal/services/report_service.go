@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+)
+
+// ReportService builds the rent roll and arrears reports. Rows are handed to
+// a callback as they are read so large portfolios are never held in memory.
+type ReportService struct {
+	DB *database.Database
+}
+
+func NewReportService(db *database.Database) *ReportService {
+	return &ReportService{DB: db}
+}
+
+// ReportFilter selects the properties and period [From, To) of a report
+type ReportFilter struct {
+	PropertyID int
+	From       time.Time
+	To         time.Time
+}
+
+// IssuedBy returns the name printed on a user's reports
+func (s *ReportService) IssuedBy(ctx context.Context, userID int) (string, error) {
+	var name, email string
+	err := s.DB.QueryRowContext(ctx, "SELECT COALESCE(full_name, ''), email FROM users WHERE id = $1", userID).Scan(&name, &email)
+	return firstNonEmpty(name, email), err
+}
+
+// scope restricts pr to the properties whose tenants and payments the user
+// can read, or the one asked for
+func (s *ReportService) scope(ctx context.Context, userID int, f ReportFilter, args *queryArgs) (string, error) {
+	user := args.add(userID)
+	where := `pr.id IN (SELECT accessible_property_ids(` + user + `, ` + args.add(string(permissions.TenantsRead)) + `))
+		  AND pr.id IN (SELECT accessible_property_ids(` + user + `, ` + args.add(string(permissions.PaymentsRead)) + `))`
+	if f.PropertyID == 0 {
+		return where, nil
+	}
+	where += " AND pr.id = " + args.add(f.PropertyID)
+
+	// Checked up front so callers can still answer with an error before
+	// streaming anything
+	var found bool
+	err := s.DB.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM properties pr WHERE "+where+")", *args...).Scan(&found)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", ErrPropertyNotFound
+	}
+	return where, nil
+}
+
+// RentRoll emits every unit of the properties in scope with its tenant, rent,
+// rent and charges billed in the period, payments received in it and the
+// balance at its end. Rent is billed when a tenancy starts (its opening
+// balance) and on each later due date.
+func (s *ReportService) RentRoll(ctx context.Context, userID int, f ReportFilter, emit func(models.RentRollLine) error) (models.RentRollTotals, error) {
+	var totals models.RentRollTotals
+	var args queryArgs
+	scope, err := s.scope(ctx, userID, f, &args)
+	if err != nil {
+		return totals, err
+	}
+	from, to := args.add(f.From), args.add(f.To)
+	fromDate, toDate := args.add(f.From.Format(dateLayout)), args.add(f.To.Format(dateLayout))
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT pr.id, pr.title, u.id, u.unit_name, t.id, COALESCE(t.tenant_name, ''), COALESCE(t.payment_no1, ''),
+		       COALESCE(t.rent, u.unit_price),
+		       COALESCE(t.rent, 0) * COALESCE(d.due_dates, 0) + COALESCE(c.charged, 0),
+		       COALESCE(p.paid, 0),
+		       COALESCE(t.balance, 0) + COALESCE(p.paid_after, 0) - COALESCE(c.charged_after, 0)
+		FROM properties pr
+		JOIN units u ON u.property_id = pr.id
+		LEFT JOIN tenants t ON t.unit_id = u.id AND t.created_at < `+to+`
+		LEFT JOIN LATERAL (
+			SELECT (s.start >= `+fromDate+`::DATE AND s.start < `+toDate+`::DATE)::INT + COUNT(m.due) AS due_dates
+			FROM (SELECT (t.created_at AT TIME ZONE 'Africa/Nairobi')::DATE AS start) s
+			LEFT JOIN LATERAL (
+				SELECT g::DATE + t.rent_due_day - 1 AS due
+				FROM generate_series(date_trunc('month', `+fromDate+`::TIMESTAMP), `+toDate+`::TIMESTAMP, INTERVAL '1 month') g
+			) m ON m.due > s.start AND m.due >= `+fromDate+`::DATE AND m.due < `+toDate+`::DATE
+			GROUP BY s.start
+		) d ON t.id IS NOT NULL
+		LEFT JOIN LATERAL (
+			SELECT SUM(amount) FILTER (WHERE created_at >= `+from+` AND created_at < `+to+`) AS paid,
+			       SUM(amount) FILTER (WHERE created_at >= `+to+`) AS paid_after
+			FROM payments WHERE tenant_id = t.id AND status = 'COMPLETED'
+		) p ON t.id IS NOT NULL
+		LEFT JOIN LATERAL (
+			SELECT SUM(amount) FILTER (WHERE created_at >= `+from+` AND created_at < `+to+`) AS charged,
+			       SUM(amount) FILTER (WHERE created_at >= `+to+`) AS charged_after
+			FROM tenant_charges WHERE tenant_id = t.id
+		) c ON t.id IS NOT NULL
+		WHERE `+scope+`
+		ORDER BY pr.title, pr.id, u.unit_name, u.id, t.id`,
+		args...,
+	)
+	if err != nil {
+		return totals, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l models.RentRollLine
+		var tenantID sql.NullInt64
+		if err := rows.Scan(&l.PropertyID, &l.PropertyTitle, &l.UnitID, &l.UnitName, &tenantID, &l.TenantName, &l.Phone,
+			&l.Rent, &l.Billed, &l.Paid, &l.Balance); err != nil {
+			return totals, err
+		}
+		l.TenantID = nullUint(tenantID)
+
+		totals.Units++
+		if l.TenantID != nil {
+			totals.OccupiedUnits++
+			totals.Rent += l.Rent
+		}
+		totals.Billed += l.Billed
+		totals.Paid += l.Paid
+		totals.Balance += l.Balance
+		if err := emit(l); err != nil {
+			return totals, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return totals, err
+	}
+
+	totals.Rent = roundTo(totals.Rent, 2)
+	totals.Billed = roundTo(totals.Billed, 2)
+	totals.Paid = roundTo(totals.Paid, 2)
+	totals.Balance = roundTo(totals.Balance, 2)
+	return totals, nil
+}
+
+// Arrears emits the tenants owing money at the end of the period (or now,
+// for a period still running), most overdue first within each property,
+// with how long their balance has been owed.
+func (s *ReportService) Arrears(ctx context.Context, userID int, f ReportFilter, emit func(models.ArrearsLine) error) (models.ArrearsTotals, error) {
+	asOf := f.To
+	if now := time.Now(); now.Before(asOf) {
+		asOf = now
+	}
+	asOfDate := dateOf(asOf.Add(-time.Nanosecond).In(reminderZone))
+
+	totals := models.ArrearsTotals{AsOf: asOfDate.Format(dateLayout), Buckets: make([]models.ArrearsBucket, len(arrearsBuckets))}
+	for i, b := range arrearsBuckets {
+		totals.Buckets[i] = models.ArrearsBucket{Label: b.label, MinDays: b.min}
+		if b.max > 0 {
+			max := b.max
+			totals.Buckets[i].MaxDays = &max
+		}
+	}
+
+	var args queryArgs
+	scope, err := s.scope(ctx, userID, f, &args)
+	if err != nil {
+		return totals, err
+	}
+	from, at, date := args.add(f.From), args.add(asOf), args.add(asOfDate.Format(dateLayout))
+
+	rows, err := s.DB.QueryContext(ctx, `
+		WITH closing AS (
+			SELECT pr.id AS property_id, pr.title, u.unit_name, t.id, t.tenant_name, COALESCE(t.payment_no1, '') AS phone,
+			       t.rent, t.rent_due_day, t.created_at,
+			       COALESCE(p.paid, 0) AS paid, p.last_paid,
+			       COALESCE(t.balance, 0) + COALESCE(p.paid_after, 0) - COALESCE(c.charged_after, 0) AS balance
+			FROM tenants t
+			JOIN units u ON t.unit_id = u.id
+			JOIN properties pr ON u.property_id = pr.id
+			LEFT JOIN LATERAL (
+				SELECT SUM(amount) FILTER (WHERE created_at >= `+from+` AND created_at < `+at+`) AS paid,
+				       SUM(amount) FILTER (WHERE created_at >= `+at+`) AS paid_after,
+				       MAX(created_at) FILTER (WHERE created_at < `+at+`) AS last_paid
+				FROM payments WHERE tenant_id = t.id AND status = 'COMPLETED'
+			) p ON TRUE
+			LEFT JOIN LATERAL (
+				SELECT SUM(amount) FILTER (WHERE created_at >= `+at+`) AS charged_after
+				FROM tenant_charges WHERE tenant_id = t.id
+			) c ON TRUE
+			WHERE `+scope+` AND t.created_at < `+at+`
+		)
+		SELECT property_id, title, unit_name, id, tenant_name, phone, COALESCE(rent, 0), paid, last_paid, balance,
+		       since, `+date+`::DATE - since
+		FROM (SELECT t.*, `+owedSinceSQL("t.balance", date+"::DATE")+` AS since FROM closing t WHERE t.balance > 0) a
+		ORDER BY title, property_id, since, unit_name, id`,
+		args...,
+	)
+	if err != nil {
+		return totals, err
+	}
+	defer rows.Close()
+
+	var balance float64
+	for rows.Next() {
+		var l models.ArrearsLine
+		var lastPaid sql.NullTime
+		var since time.Time
+		if err := rows.Scan(&l.PropertyID, &l.PropertyTitle, &l.UnitName, &l.TenantID, &l.TenantName, &l.Phone, &l.Rent,
+			&l.PaidInPeriod, &lastPaid, &l.Balance, &since, &l.DaysOverdue); err != nil {
+			return totals, err
+		}
+		if lastPaid.Valid {
+			l.LastPaymentAt = &lastPaid.Time
+		}
+		l.OwedSince = since.Format(dateLayout)
+		l.Bucket = arrearsBucket(l.DaysOverdue)
+
+		totals.Tenants++
+		balance += l.Balance
+		for i := range totals.Buckets {
+			if totals.Buckets[i].Label == l.Bucket {
+				totals.Buckets[i].Tenants++
+				totals.Buckets[i].Amount += l.Balance
+			}
+		}
+		if err := emit(l); err != nil {
+			return totals, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return totals, err
+	}
+
+	totals.Balance = roundTo(balance, 2)
+	for i := range totals.Buckets {
+		totals.Buckets[i].Amount = roundTo(totals.Buckets[i].Amount, 2)
+	}
+	return totals, nil
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+)
+
+// Search result groups
+const (
+	SearchTenants    = "tenants"
+	SearchUnits      = "units"
+	SearchProperties = "properties"
+	SearchPayments   = "payments"
+)
+
+const (
+	DefaultSearchLimit = 10
+	MaxSearchLimit     = 50
+)
+
+var ErrSearchTooShort = errors.New("search text must be 2 to 100 characters")
+
+// SearchService finds tenants, units, properties and payments by name,
+// phone number fragment, location or receipt code. Text matches use the
+// trigram indexes on the searched columns.
+type SearchService struct {
+	DB *database.Database
+}
+
+func NewSearchService(db *database.Database) *SearchService {
+	return &SearchService{DB: db}
+}
+
+// searchField is a column a group is searched on. Phone fields match on
+// their digits, so 0712 345 finds 254712345678.
+type searchField struct {
+	name  string
+	expr  string
+	phone bool
+}
+
+// searchGroup is how one kind of record is searched: the permission needed,
+// its FROM clause, the hit columns (id, title, subtitle, property, unit,
+// tenant, amount, date) and the scope condition, where $USER and $PERM are
+// the caller and the permission
+type searchGroup struct {
+	name    string
+	perm    permissions.Permission
+	columns string
+	from    string
+	scope   string
+	fields  []searchField
+}
+
+var searchGroups = []searchGroup{
+	{
+		name:    SearchTenants,
+		perm:    permissions.TenantsRead,
+		columns: "t.id, t.tenant_name, u.unit_name || ', ' || p.title, p.id, u.id, t.id, t.balance, NULL::TIMESTAMPTZ",
+		from:    "FROM tenants t JOIN units u ON t.unit_id = u.id JOIN properties p ON u.property_id = p.id",
+		scope:   "p.id IN (SELECT accessible_property_ids($USER, $PERM))",
+		fields: []searchField{
+			{name: "tenant_name", expr: "t.tenant_name"},
+			{name: "payment_no1", expr: "t.payment_no1", phone: true},
+			{name: "payment_no2", expr: "t.payment_no2", phone: true},
+		},
+	},
+	{
+		name:    SearchUnits,
+		perm:    permissions.UnitsRead,
+		columns: "u.id, u.unit_name, p.title, p.id, u.id, NULL::INTEGER, u.unit_price, NULL::TIMESTAMPTZ",
+		from:    "FROM units u JOIN properties p ON u.property_id = p.id",
+		scope:   "p.id IN (SELECT accessible_property_ids($USER, $PERM))",
+		fields:  []searchField{{name: "unit_name", expr: "u.unit_name"}},
+	},
+	{
+		name:    SearchProperties,
+		perm:    permissions.PropertiesRead,
+		columns: "p.id, p.title, p.location, p.id, NULL::INTEGER, NULL::INTEGER, p.total_rent, NULL::TIMESTAMPTZ",
+		from:    "FROM properties p",
+		scope:   "p.id IN (SELECT accessible_property_ids($USER, $PERM))",
+		fields: []searchField{
+			{name: "title", expr: "p.title"},
+			{name: "location", expr: "p.location"},
+		},
+	},
+	{
+		// Unassigned payments belong to the landlord rather than a property
+		name:    SearchPayments,
+		perm:    permissions.PaymentsRead,
+		columns: "py.id, COALESCE(py.receipt, py.receipt_no, ''), COALESCE(t.tenant_name, 'Unassigned'), u.property_id, u.id, t.id, py.amount, py.created_at",
+		from:    "FROM payments py LEFT JOIN tenants t ON py.tenant_id = t.id LEFT JOIN units u ON t.unit_id = u.id",
+		scope: "u.property_id IN (SELECT accessible_property_ids($USER, $PERM))" +
+			" OR (py.tenant_id IS NULL AND py.landlord_id IN (SELECT accessible_landlord_ids($USER, $PERM)))",
+		fields: []searchField{
+			{name: "receipt", expr: "py.receipt"},
+			{name: "receipt_no", expr: "py.receipt_no"},
+		},
+	},
+}
+
+// Search returns the records matching text in each group the caller's role
+// may read, best first, at most limit per group. Staff only find records on
+// the properties delegated to them.
+func (s *SearchService) Search(ctx context.Context, userID int, role, text string, limit int) (map[string][]models.SearchHit, error) {
+	text = strings.TrimSpace(text)
+	if n := len([]rune(text)); n < 2 || n > 100 {
+		return nil, ErrSearchTooShort
+	}
+	if limit < 1 || limit > MaxSearchLimit {
+		limit = DefaultSearchLimit
+	}
+
+	results := map[string][]models.SearchHit{}
+	for _, g := range searchGroups {
+		if !permissions.Has(role, g.perm) {
+			continue
+		}
+		hits, err := s.searchGroup(ctx, g, userID, text, limit)
+		if err != nil {
+			return nil, err
+		}
+		results[g.name] = hits
+	}
+	return results, nil
+}
+
+func (s *SearchService) searchGroup(ctx context.Context, g searchGroup, userID int, text string, limit int) ([]models.SearchHit, error) {
+	var args queryArgs
+	scope := strings.NewReplacer("$USER", args.add(userID), "$PERM", args.add(string(g.perm))).Replace(g.scope)
+	exact, prefix := args.add(text), args.add(likeEscaper.Replace(text)+"%")
+	fragment := args.add("%" + likeEscaper.Replace(text) + "%")
+	digits := ""
+	if d := searchDigits(text); d != "" {
+		digits = args.add("%" + d + "%")
+	}
+
+	var ranks, matches []string
+	var fields []searchField
+	for _, f := range g.fields {
+		if f.phone {
+			if digits == "" {
+				continue
+			}
+			// A phone fragment is a strong signal; rank it like a prefix match
+			match := "regexp_replace(" + f.expr + `, '\D', '', 'g') LIKE ` + digits
+			ranks = append(ranks, "CASE WHEN "+match+" THEN 2.5 ELSE 0 END")
+			matches = append(matches, match)
+		} else {
+			ranks = append(ranks, "CASE WHEN "+f.expr+" IS NULL THEN 0"+
+				" WHEN lower("+f.expr+") = lower("+exact+") THEN 3"+
+				" WHEN "+f.expr+" ILIKE "+prefix+" THEN 2"+
+				" WHEN "+f.expr+" ILIKE "+fragment+" THEN 1 ELSE 0 END"+
+				" + COALESCE(word_similarity("+exact+", "+f.expr+"), 0)")
+			matches = append(matches, f.expr+" ILIKE "+fragment, exact+" <% "+f.expr)
+		}
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return []models.SearchHit{}, nil
+	}
+
+	query := "SELECT " + g.columns + ", " + strings.Join(ranks, ", ") + " " + g.from +
+		" WHERE (" + scope + ") AND (" + strings.Join(matches, " OR ") + ")" +
+		" ORDER BY GREATEST(" + strings.Join(ranks, ", ") + ") DESC, 2, 1" +
+		" LIMIT " + args.add(limit)
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []models.SearchHit{}
+	for rows.Next() {
+		var h models.SearchHit
+		var subtitle sql.NullString
+		var propertyID, unitID, tenantID sql.NullInt64
+		var amount sql.NullFloat64
+		var date sql.NullTime
+		fieldRanks := make([]float64, len(fields))
+		dest := []interface{}{&h.ID, &h.Title, &subtitle, &propertyID, &unitID, &tenantID, &amount, &date}
+		for i := range fieldRanks {
+			dest = append(dest, &fieldRanks[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		h.Subtitle = subtitle.String
+		h.PropertyID, h.UnitID, h.TenantID = nullUint(propertyID), nullUint(unitID), nullUint(tenantID)
+		if amount.Valid {
+			h.Amount = &amount.Float64
+		}
+		if date.Valid {
+			h.Date = &date.Time
+		}
+		best := 0
+		for i, r := range fieldRanks {
+			if r > fieldRanks[best] {
+				best = i
+			}
+		}
+		h.Rank, h.MatchedOn = roundTo(fieldRanks[best], 3), fields[best].name
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
+
+// likeEscaper escapes LIKE wildcards in search text
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// searchDigits is the phone number fragment in text, without a leading 0 or
+// 254 so it matches numbers stored either way; empty when text is not a
+// phone number of at least three digits
+func searchDigits(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' || r == ' ' || r == '-':
+		default:
+			return ""
+		}
+	}
+	digits := b.String()
+	if len(digits) > 9 && strings.HasPrefix(digits, "254") {
+		digits = digits[3:]
+	}
+	digits = strings.TrimPrefix(digits, "0")
+	if len(digits) < 3 {
+		return ""
+	}
+	return digits
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSearchDigits(t *testing.T) {
+	for text, want := range map[string]string{
+		"0712 345":      "712345",
+		"254712345678":  "712345678",
+		"+254 712-3456": "7123456",
+		"254712345":     "254712345", // nine digits may be a fragment of any number
+		"712":           "712",
+		"254":           "254", // too short to be a 254 prefix
+		"2547":          "2547",
+		"0712":          "712",
+		"07":            "",
+		"SJ12ABC":       "",
+		"Jane 0712":     "",
+		"":              "",
+		"12 Kamau Road": "",
+	} {
+		if got := searchDigits(text); got != want {
+			t.Errorf("searchDigits(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestLikeEscaper(t *testing.T) {
+	if got := likeEscaper.Replace(`50%_off\`); got != `50\%\_off\\` {
+		t.Errorf("likeEscaper = %q", got)
+	}
+}
+
+// Search checks its text, and which groups the role may read, before any
+// query runs
+func TestSearchWithoutQueries(t *testing.T) {
+	s := NewSearchService(nil)
+	for _, text := range []string{"", "a", "  a  ", strings.Repeat("é", 101)} {
+		if _, err := s.Search(context.Background(), 7, "landlord", text, 10); !errors.Is(err, ErrSearchTooShort) {
+			t.Errorf("Search(%q): err = %v, want ErrSearchTooShort", text, err)
+		}
+	}
+	results, err := s.Search(context.Background(), 7, "no_such_role", "Jane", 10)
+	if err != nil || len(results) != 0 {
+		t.Errorf("unknown role: %v, %v; want no groups", results, err)
+	}
+}
+
+func TestSearchGroupsNeedAPermission(t *testing.T) {
+	seen := map[string]bool{}
+	for _, g := range searchGroups {
+		if g.perm == "" || len(g.fields) == 0 || !strings.Contains(g.scope, "$USER") {
+			t.Errorf("group %s: permission %q, %d fields, scope %q", g.name, g.perm, len(g.fields), g.scope)
+		}
+		if seen[g.name] {
+			t.Errorf("group %s listed twice", g.name)
+		}
+		seen[g.name] = true
+	}
+}
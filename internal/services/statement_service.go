@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/events"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/Zolet-hash/smart-rentals/internal/statements"
+)
+
+const MaxStatementSize = 10 << 20
+
+// Statement line outcomes
+const (
+	LineMatched   = "MATCHED"   // new completed payment for the tenant paying from the number
+	LineUnmatched = "UNMATCHED" // new pending payment awaiting manual assignment
+	LineDuplicate = "DUPLICATE" // the receipt was already recorded
+)
+
+// statementLock is the advisory lock class serialising a landlord's
+// statement uploads, so a statement sent twice at once is not applied twice
+const statementLock = 7_231_002
+
+var (
+	ErrStatementNotFound = errors.New("statement upload not found")
+	ErrLandlordNotFound  = errors.New("landlord not found or unauthorized")
+	ErrStatementTooLarge = fmt.Errorf("statements must be at most %d MB", MaxStatementSize>>20)
+	ErrEmptyStatement    = errors.New("the statement has no money received")
+)
+
+// StatementService reconciles uploaded M-Pesa and bank statements against
+// recorded payments. Money the callbacks never reported becomes payments:
+// matched to tenants by the callbacks' rules where possible, otherwise
+// pending like an unmatched callback for assignment by hand.
+type StatementService struct {
+	DB     *database.Database
+	Events events.Publisher
+}
+
+func NewStatementService(db *database.Database, publisher events.Publisher) *StatementService {
+	return &StatementService{DB: db, Events: publisher}
+}
+
+// StatementFile is an uploaded statement; Format is documents.FormatCSV,
+// FormatXLSX or FormatPDF. Password opens protected PDFs.
+type StatementFile struct {
+	Filename string
+	Format   string
+	Password string
+	Data     []byte
+}
+
+// Upload reads the statement and records every line: receipts already in
+// payments are duplicates, the rest become payments in one transaction.
+// landlordID 0 is the caller; staff name the landlord they reconcile for.
+func (s *StatementService) Upload(ctx context.Context, userID, landlordID int, f StatementFile) (models.StatementUpload, error) {
+	upload := models.StatementUpload{Filename: f.Filename}
+	if landlordID == 0 {
+		landlordID = userID
+	}
+	var ok bool
+	err := s.DB.QueryRowContext(ctx, "SELECT $1 IN (SELECT accessible_landlord_ids($2, $3))", landlordID, userID, string(permissions.PaymentsAssign)).Scan(&ok)
+	if err != nil {
+		return upload, err
+	}
+	if !ok {
+		return upload, ErrLandlordNotFound
+	}
+	upload.LandlordID = uint(landlordID)
+	uploadedBy := uint(userID)
+	upload.UploadedBy = &uploadedBy
+
+	if len(f.Data) > MaxStatementSize {
+		return upload, ErrStatementTooLarge
+	}
+	st, err := statements.Parse(f.Data, f.Format, f.Password, reminderZone)
+	if err != nil {
+		return upload, err
+	}
+	if len(st.Transactions) == 0 {
+		return upload, ErrEmptyStatement
+	}
+	upload.Source = st.Source
+
+	method := "BANK"
+	if st.Source == statements.SourceMpesa {
+		method = "MPESA_PAYBILL"
+		var codeType string
+		err := s.DB.QueryRowContext(ctx, "SELECT short_code_type FROM landlord_payment_configs WHERE landlord_id = $1", landlordID).Scan(&codeType)
+		if err != nil && err != sql.ErrNoRows {
+			return upload, err
+		}
+		if strings.EqualFold(codeType, "till") {
+			method = "MPESA_TILL"
+		}
+	}
+
+	var published []events.Event
+	err = func() error {
+		tx, err := s.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1, $2)", statementLock, landlordID); err != nil {
+			return err
+		}
+
+		seen := map[string]uint{}
+		for _, t := range st.Transactions {
+			line := models.StatementLine{LineNo: t.Line, Reference: t.Reference, TransactionAt: t.Time,
+				Amount: roundTo(t.Amount, 2), Phone: t.Phone, Payer: t.Payer, Details: t.Details}
+			upload.TotalLines++
+			upload.TotalAmount += line.Amount
+
+			paymentID, dup := seen[t.Reference]
+			if !dup {
+				var id int64
+				err := tx.QueryRowContext(ctx, "SELECT id FROM payments WHERE landlord_id = $1 AND receipt = $2 ORDER BY id LIMIT 1",
+					landlordID, t.Reference).Scan(&id)
+				if err != nil && err != sql.ErrNoRows {
+					return err
+				}
+				dup, paymentID = err == nil, uint(id)
+			}
+			if dup {
+				line.Outcome = LineDuplicate
+				line.PaymentID = &paymentID
+				upload.Duplicates++
+				upload.Lines = append(upload.Lines, line)
+				continue
+			}
+
+			tenantID, matched, err := matchTenant(ctx, tx, landlordID, t.Phone)
+			if err != nil {
+				return err
+			}
+			var tenant interface{}
+			status := "PENDING"
+			if matched {
+				tenant, status = tenantID, "COMPLETED"
+			}
+			var id int64
+			err = tx.QueryRowContext(ctx, `
+				INSERT INTO payments (landlord_id, tenant_id, amount, status, method, receipt, recorded_by, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+				RETURNING id`,
+				landlordID, tenant, line.Amount, status, method, t.Reference, userID, t.Time,
+			).Scan(&id)
+			if err != nil {
+				return fmt.Errorf("line %d: payment: %w", t.Line, err)
+			}
+			paymentID = uint(id)
+			seen[t.Reference] = paymentID
+			line.PaymentID = &paymentID
+			line.PaymentStatus = status
+
+			eventType := events.PaymentUnmatched
+			if matched {
+				if _, err := tx.ExecContext(ctx, "UPDATE tenants SET balance = balance - $1 WHERE id = $2", line.Amount, tenantID); err != nil {
+					return fmt.Errorf("line %d: balance: %w", t.Line, err)
+				}
+				tid := uint(tenantID)
+				line.TenantID = &tid
+				line.Outcome = LineMatched
+				upload.Matched++
+				eventType = events.PaymentCompleted
+			} else {
+				line.Outcome = LineUnmatched
+				upload.Unmatched++
+			}
+			upload.Lines = append(upload.Lines, line)
+			published = append(published, events.New(eventType, landlordID, map[string]interface{}{
+				"payment_id": id,
+				"tenant_id":  tenant,
+				"amount":     line.Amount,
+				"receipt":    t.Reference,
+				"method":     method,
+				"status":     status,
+				"msisdn":     t.Phone,
+				"statement":  true,
+			}))
+		}
+		upload.TotalAmount = roundTo(upload.TotalAmount, 2)
+
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO statement_uploads (landlord_id, filename, source, total_lines, matched, unmatched, duplicates, total_amount, uploaded_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING id, created_at`,
+			landlordID, upload.Filename, upload.Source, upload.TotalLines, upload.Matched, upload.Unmatched,
+			upload.Duplicates, upload.TotalAmount, userID,
+		).Scan(&upload.ID, &upload.CreatedAt)
+		if err != nil {
+			return err
+		}
+		for i := range upload.Lines {
+			l := &upload.Lines[i]
+			err := tx.QueryRowContext(ctx, `
+				INSERT INTO statement_lines (upload_id, line_no, reference, transaction_at, amount, phone, payer, details, outcome, payment_id)
+				VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), $9, $10)
+				RETURNING id`,
+				upload.ID, l.LineNo, l.Reference, l.TransactionAt, l.Amount, l.Phone, l.Payer, l.Details, l.Outcome, l.PaymentID,
+			).Scan(&l.ID)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", l.LineNo, err)
+			}
+		}
+		return tx.Commit()
+	}()
+	if err != nil {
+		return upload, err
+	}
+
+	for _, e := range published {
+		s.Events.Publish(ctx, e)
+	}
+	log.Printf("statement %d: %d lines for landlord %d, %d matched, %d unmatched, %d duplicates",
+		upload.ID, upload.TotalLines, landlordID, upload.Matched, upload.Unmatched, upload.Duplicates)
+	return upload, nil
+}
+
+const statementUploadColumns = `id, landlord_id, filename, source, total_lines, matched, unmatched, duplicates,
+	total_amount, uploaded_by, created_at`
+
+// ListUploads returns the statements uploaded for the landlords the caller
+// reconciles payments for
+func (s *StatementService) ListUploads(ctx context.Context, userID, limit, offset int) ([]models.StatementUpload, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT `+statementUploadColumns+` FROM statement_uploads
+		WHERE landlord_id IN (SELECT accessible_landlord_ids($1, $2))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4`,
+		userID, string(permissions.PaymentsAssign), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.StatementUpload{}
+	for rows.Next() {
+		var u models.StatementUpload
+		if err := scanStatementUpload(rows, &u); err != nil {
+			return nil, err
+		}
+		list = append(list, u)
+	}
+	return list, rows.Err()
+}
+
+// GetUpload returns an upload with its lines and where their payments are
+// now. With unmatchedOnly, only lines whose payment still awaits a tenant.
+func (s *StatementService) GetUpload(ctx context.Context, userID int, id int64, unmatchedOnly bool) (models.StatementUpload, error) {
+	var u models.StatementUpload
+	err := scanStatementUpload(s.DB.QueryRowContext(ctx, `
+		SELECT `+statementUploadColumns+` FROM statement_uploads
+		WHERE id = $1 AND landlord_id IN (SELECT accessible_landlord_ids($2, $3))`,
+		id, userID, string(permissions.PaymentsAssign),
+	), &u)
+	if err == sql.ErrNoRows {
+		return u, ErrStatementNotFound
+	}
+	if err != nil {
+		return u, err
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT l.id, l.line_no, l.reference, l.transaction_at, l.amount, COALESCE(l.phone, ''), COALESCE(l.payer, ''),
+		       COALESCE(l.details, ''), l.outcome, l.payment_id, COALESCE(p.status, ''), p.tenant_id, COALESCE(t.tenant_name, '')
+		FROM statement_lines l
+		LEFT JOIN payments p ON p.id = l.payment_id
+		LEFT JOIN tenants t ON t.id = p.tenant_id
+		WHERE l.upload_id = $1
+		  AND (NOT $2 OR (p.id IS NOT NULL AND p.tenant_id IS NULL))
+		ORDER BY l.line_no, l.id`,
+		id, unmatchedOnly)
+	if err != nil {
+		return u, err
+	}
+	defer rows.Close()
+
+	u.Lines = []models.StatementLine{}
+	for rows.Next() {
+		var l models.StatementLine
+		var paymentID, tenantID sql.NullInt64
+		err := rows.Scan(&l.ID, &l.LineNo, &l.Reference, &l.TransactionAt, &l.Amount, &l.Phone, &l.Payer,
+			&l.Details, &l.Outcome, &paymentID, &l.PaymentStatus, &tenantID, &l.TenantName)
+		if err != nil {
+			return u, err
+		}
+		l.PaymentID = nullUint(paymentID)
+		l.TenantID = nullUint(tenantID)
+		u.Lines = append(u.Lines, l)
+	}
+	return u, rows.Err()
+}
+
+func scanStatementUpload(row rowScanner, u *models.StatementUpload) error {
+	var uploadedBy sql.NullInt64
+	err := row.Scan(&u.ID, &u.LandlordID, &u.Filename, &u.Source, &u.TotalLines, &u.Matched, &u.Unmatched,
+		&u.Duplicates, &u.TotalAmount, &uploadedBy, &u.CreatedAt)
+	if err != nil {
+		return err
+	}
+	u.UploadedBy = nullUint(uploadedBy)
+	return nil
+}
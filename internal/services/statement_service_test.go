@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+	"github.com/Zolet-hash/smart-rentals/internal/statements"
+)
+
+// noQueries fails the test on any query
+type noQueries struct{ t *testing.T }
+
+func (q noQueries) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	q.t.Fatalf("unexpected query with %v", args)
+	return nil
+}
+
+func TestBankLineWithoutNumberIsUnmatched(t *testing.T) {
+	csv := "Transaction Date,Description,Credit,Debit,Reference\n" +
+		"2026-10-01,CASH DEPOSIT NAIROBI BRANCH,15000,,FT26100112\n"
+	st, err := statements.Parse([]byte(csv), documents.FormatCSV, "", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Transactions) != 1 || st.Transactions[0].Phone != "" {
+		t.Fatalf("transactions = %+v, want one without a number", st.Transactions)
+	}
+
+	// Tenants without a second number must not look like its payer
+	_, matched, err := matchTenant(context.Background(), noQueries{t}, 1, st.Transactions[0].Phone)
+	if err != nil || matched {
+		t.Errorf("matchTenant = %v, %v; want no match", matched, err)
+	}
+	for _, number := range []string{"", "   "} {
+		if _, matched, _ := matchTenant(context.Background(), noQueries{t}, 1, number); matched {
+			t.Errorf("matchTenant(%q) matched", number)
+		}
+	}
+}
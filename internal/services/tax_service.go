@@ -0,0 +1,414 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/lib/pq"
+)
+
+// TaxMRI is Monthly Rental Income tax on residential rent
+const TaxMRI = "MRI"
+
+// MRI return statuses. Unfiled returns are OPEN until the month ends, then
+// DUE until the 20th of the next month and OVERDUE after it; EXEMPT when the
+// landlord's annual rent is outside the rate's bounds.
+const (
+	ReturnOpen    = "OPEN"
+	ReturnDue     = "DUE"
+	ReturnOverdue = "OVERDUE"
+	ReturnExempt  = "EXEMPT"
+	ReturnFiled   = "FILED"
+	ReturnPaid    = "PAID"
+)
+
+// mriDueDay is the day of the following month MRI is filed and paid by
+const mriDueDay = 20
+
+var (
+	ErrInvalidPeriod   = errors.New("period must be a month formatted as YYYY-MM")
+	ErrInvalidYear     = errors.New("year must be a four-digit year no later than this one")
+	ErrPeriodOpen      = errors.New("a return can only be filed once its month has ended")
+	ErrInvalidPRN      = errors.New("prn must be the payment registration number from iTax (6-30 letters or digits)")
+	ErrInvalidKRAPin   = errors.New("kra_pin must be a letter, 9 digits and a letter, e.g. A123456789B")
+	ErrNoKRAPin        = errors.New("set your KRA PIN before exporting returns")
+	ErrInvalidTaxRate  = errors.New("rate_percent must be 0-100, min_annual_income at least 0 and below max_annual_income, and effective_from YYYY-MM-DD")
+	ErrUnknownTax      = errors.New("tax must be MRI")
+	ErrTaxRateExists   = errors.New("a rate of this tax already takes effect on that date")
+	ErrTaxRateNotFound = errors.New("tax rate not found")
+)
+
+var (
+	prnPattern    = regexp.MustCompile(`^[A-Za-z0-9]{6,30}$`)
+	kraPinPattern = regexp.MustCompile(`^[A-Z][0-9]{9}[A-Z]$`)
+)
+
+// TaxService computes landlords' Monthly Rental Income tax from the rent
+// they received and tracks the returns they file. Rates and thresholds
+// live in tax_rates.
+type TaxService struct {
+	DB *database.Database
+}
+
+func NewTaxService(db *database.Database) *TaxService {
+	return &TaxService{DB: db}
+}
+
+// TaxRateInput adds a rate; MaxAnnualIncome nil means no upper bound
+type TaxRateInput struct {
+	Tax             string   `json:"tax"`
+	RatePercent     float64  `json:"rate_percent"`
+	MinAnnualIncome float64  `json:"min_annual_income"`
+	MaxAnnualIncome *float64 `json:"max_annual_income"`
+	EffectiveFrom   string   `json:"effective_from"`
+	Notes           string   `json:"notes"`
+}
+
+// ListRates returns the rates of tax, or of every tax when it is empty,
+// latest first
+func (s *TaxService) ListRates(ctx context.Context, tax string) ([]models.TaxRate, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT id, tax, rate_percent, min_annual_income, max_annual_income, effective_from, COALESCE(notes, ''), created_at
+		FROM tax_rates
+		WHERE $1 = '' OR tax = $1
+		ORDER BY tax, effective_from DESC`, tax)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.TaxRate{}
+	for rows.Next() {
+		var r models.TaxRate
+		var maxIncome sql.NullFloat64
+		var from time.Time
+		if err := rows.Scan(&r.ID, &r.Tax, &r.RatePercent, &r.MinAnnualIncome, &maxIncome, &from, &r.Notes, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		if maxIncome.Valid {
+			r.MaxAnnualIncome = &maxIncome.Float64
+		}
+		r.EffectiveFrom = from.Format(dateLayout)
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// AddRate records a rate taking effect on in.EffectiveFrom. Returns already
+// filed keep the rate they were filed at.
+func (s *TaxService) AddRate(ctx context.Context, userID int, in TaxRateInput) (models.TaxRate, error) {
+	in.Tax = strings.ToUpper(strings.TrimSpace(in.Tax))
+	if in.Tax != TaxMRI {
+		return models.TaxRate{}, ErrUnknownTax
+	}
+	from, err := time.Parse(dateLayout, in.EffectiveFrom)
+	if err != nil || in.RatePercent < 0 || in.RatePercent > 100 || in.MinAnnualIncome < 0 ||
+		(in.MaxAnnualIncome != nil && *in.MaxAnnualIncome <= in.MinAnnualIncome) {
+		return models.TaxRate{}, ErrInvalidTaxRate
+	}
+
+	r := models.TaxRate{Tax: in.Tax, RatePercent: roundTo(in.RatePercent, 2), MinAnnualIncome: in.MinAnnualIncome,
+		MaxAnnualIncome: in.MaxAnnualIncome, EffectiveFrom: from.Format(dateLayout), Notes: strings.TrimSpace(in.Notes)}
+	err = s.DB.QueryRowContext(ctx, `
+		INSERT INTO tax_rates (tax, rate_percent, min_annual_income, max_annual_income, effective_from, notes, created_by)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7)
+		RETURNING id, created_at`,
+		r.Tax, r.RatePercent, r.MinAnnualIncome, r.MaxAnnualIncome, r.EffectiveFrom, r.Notes, userID,
+	).Scan(&r.ID, &r.CreatedAt)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return r, ErrTaxRateExists
+	}
+	return r, err
+}
+
+// DeleteRate removes a rate entered by mistake
+func (s *TaxService) DeleteRate(ctx context.Context, id int) error {
+	res, err := s.DB.ExecContext(ctx, "DELETE FROM tax_rates WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTaxRateNotFound
+	}
+	return nil
+}
+
+// KRAPin returns the landlord's KRA PIN, or ErrNoKRAPin
+func (s *TaxService) KRAPin(ctx context.Context, landlordID int) (string, error) {
+	var pin string
+	err := s.DB.QueryRowContext(ctx, "SELECT kra_pin FROM landlord_tax_profiles WHERE landlord_id = $1", landlordID).Scan(&pin)
+	if err == sql.ErrNoRows {
+		return "", ErrNoKRAPin
+	}
+	return pin, err
+}
+
+// SetKRAPin saves the landlord's KRA PIN, returning it as stored
+func (s *TaxService) SetKRAPin(ctx context.Context, landlordID int, pin string) (string, error) {
+	pin = strings.ToUpper(strings.TrimSpace(pin))
+	if !kraPinPattern.MatchString(pin) {
+		return "", ErrInvalidKRAPin
+	}
+	_, err := s.DB.ExecContext(ctx, `
+		INSERT INTO landlord_tax_profiles (landlord_id, kra_pin) VALUES ($1, $2)
+		ON CONFLICT (landlord_id) DO UPDATE SET kra_pin = EXCLUDED.kra_pin, updated_at = NOW()`,
+		landlordID, pin)
+	return pin, err
+}
+
+// ParsePeriod reads a YYYY-MM period as the first instant of the month in
+// Kenyan time
+func ParsePeriod(v string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01", v, reminderZone)
+	if err != nil {
+		return time.Time{}, ErrInvalidPeriod
+	}
+	return t, nil
+}
+
+// monthRent is the rent received in a month
+type monthRent struct {
+	amount float64
+	count  int
+}
+
+// filedReturn is a row of mri_returns
+type filedReturn struct {
+	grossRent, taxDue float64
+	prn, status       string
+	filedAt           time.Time
+	paidAt            sql.NullTime
+}
+
+// Returns lists the landlord's MRI returns for each month of year, up to the
+// current month
+func (s *TaxService) Returns(ctx context.Context, landlordID, year int, now time.Time) ([]models.RentalIncomeReturn, error) {
+	now = now.In(reminderZone)
+	if year < 2000 || year > now.Year() {
+		return nil, ErrInvalidYear
+	}
+	first := time.Date(year, time.January, 1, 0, 0, 0, 0, reminderZone)
+	last := time.Date(year, time.December, 1, 0, 0, 0, 0, reminderZone)
+	if current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, reminderZone); current.Before(last) {
+		last = current
+	}
+	return s.returns(ctx, landlordID, first, last, now)
+}
+
+// Return is the landlord's MRI return for the month starting at period, with
+// the rent each tenant paid in it
+func (s *TaxService) Return(ctx context.Context, landlordID int, period, now time.Time) (models.RentalIncomeReturn, error) {
+	list, err := s.returns(ctx, landlordID, period, period, now.In(reminderZone))
+	if err != nil {
+		return models.RentalIncomeReturn{}, err
+	}
+	r := list[0]
+	r.Lines, err = s.rentLines(ctx, landlordID, period)
+	return r, err
+}
+
+// returns builds the returns of the months first to last
+func (s *TaxService) returns(ctx context.Context, landlordID int, first, last, now time.Time) ([]models.RentalIncomeReturn, error) {
+	rates, err := s.ListRates(ctx, TaxMRI)
+	if err != nil {
+		return nil, err
+	}
+	// Annual rent looks back 12 months from each period
+	rent, err := s.monthlyRent(ctx, landlordID, first.AddDate(0, -11, 0), last.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+	filed, err := s.filedReturns(ctx, landlordID, first, last)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []models.RentalIncomeReturn{}
+	for p := first; !p.After(last); p = p.AddDate(0, 1, 0) {
+		annual := 0.0
+		for m := p.AddDate(0, -11, 0); !m.After(p); m = m.AddDate(0, 1, 0) {
+			annual += rent[m.Format("2006-01")].amount
+		}
+		var f *filedReturn
+		if fr, ok := filed[p.Format("2006-01")]; ok {
+			f = &fr
+		}
+		list = append(list, buildReturn(p, rent[p.Format("2006-01")], annual, rateFor(rates, p), f, now))
+	}
+	return list, nil
+}
+
+// rateFor returns the rate in force on day, from rates ordered latest first
+func rateFor(rates []models.TaxRate, day time.Time) *models.TaxRate {
+	d := day.Format(dateLayout)
+	for i := range rates {
+		if rates[i].EffectiveFrom <= d {
+			return &rates[i]
+		}
+	}
+	return nil
+}
+
+func buildReturn(period time.Time, rent monthRent, annual float64, rate *models.TaxRate, filed *filedReturn, now time.Time) models.RentalIncomeReturn {
+	due := time.Date(period.Year(), period.Month()+1, mriDueDay, 0, 0, 0, 0, reminderZone)
+	r := models.RentalIncomeReturn{
+		Period:       period.Format("2006-01"),
+		GrossRent:    roundTo(rent.amount, 2),
+		PaymentCount: rent.count,
+		AnnualRent:   roundTo(annual, 2),
+		DueDate:      due.Format(dateLayout),
+	}
+	if rate != nil {
+		r.RatePercent = rate.RatePercent
+		r.InScope = annual >= rate.MinAnnualIncome && (rate.MaxAnnualIncome == nil || annual <= *rate.MaxAnnualIncome)
+	}
+	if r.InScope {
+		r.TaxDue = roundTo(r.GrossRent*r.RatePercent/100, 2)
+	}
+
+	switch {
+	case filed != nil:
+		r.Status, r.PRN = filed.status, filed.prn
+		r.FiledGrossRent, r.FiledTaxDue = &filed.grossRent, &filed.taxDue
+		r.Amended = math.Abs(filed.grossRent-r.GrossRent) >= 0.005
+		r.FiledAt = &filed.filedAt
+		if filed.paidAt.Valid {
+			r.PaidAt = &filed.paidAt.Time
+		}
+	case now.Before(period.AddDate(0, 1, 0)):
+		r.Status = ReturnOpen
+	case !r.InScope:
+		r.Status = ReturnExempt
+	case now.Before(due.AddDate(0, 0, 1)):
+		r.Status = ReturnDue
+	default:
+		r.Status = ReturnOverdue
+	}
+	return r
+}
+
+// monthlyRent sums the landlord's completed rent payments from tenants per
+// month in [from, to), keyed YYYY-MM
+func (s *TaxService) monthlyRent(ctx context.Context, landlordID int, from, to time.Time) (map[string]monthRent, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT to_char(created_at AT TIME ZONE 'Africa/Nairobi', 'YYYY-MM'), SUM(amount), COUNT(*)
+		FROM payments
+		WHERE landlord_id = $1 AND status = 'COMPLETED' AND category = 'rent' AND tenant_id IS NOT NULL
+		  AND created_at >= $2 AND created_at < $3
+		GROUP BY 1`,
+		landlordID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rent := map[string]monthRent{}
+	for rows.Next() {
+		var month string
+		var m monthRent
+		if err := rows.Scan(&month, &m.amount, &m.count); err != nil {
+			return nil, err
+		}
+		rent[month] = m
+	}
+	return rent, rows.Err()
+}
+
+func (s *TaxService) filedReturns(ctx context.Context, landlordID int, first, last time.Time) (map[string]filedReturn, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT to_char(period, 'YYYY-MM'), gross_rent, tax_due, prn, status, filed_at, paid_at
+		FROM mri_returns
+		WHERE landlord_id = $1 AND period >= $2::DATE AND period <= $3::DATE`,
+		landlordID, first.Format(dateLayout), last.Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	filed := map[string]filedReturn{}
+	for rows.Next() {
+		var month string
+		var f filedReturn
+		if err := rows.Scan(&month, &f.grossRent, &f.taxDue, &f.prn, &f.status, &f.filedAt, &f.paidAt); err != nil {
+			return nil, err
+		}
+		filed[month] = f
+	}
+	return filed, rows.Err()
+}
+
+// rentLines is the rent each tenant paid in the month starting at period
+func (s *TaxService) rentLines(ctx context.Context, landlordID int, period time.Time) ([]models.RentalIncomeLine, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		SELECT t.id, t.tenant_name, pr.id, pr.title, pr.location, u.unit_name, SUM(p.amount), COUNT(*)
+		FROM payments p
+		JOIN tenants t ON p.tenant_id = t.id
+		JOIN units u ON t.unit_id = u.id
+		JOIN properties pr ON u.property_id = pr.id
+		WHERE p.landlord_id = $1 AND p.status = 'COMPLETED' AND p.category = 'rent'
+		  AND p.created_at >= $2 AND p.created_at < $3
+		GROUP BY t.id, t.tenant_name, pr.id, pr.title, pr.location, u.unit_name
+		ORDER BY pr.title, pr.id, u.unit_name, t.tenant_name, t.id`,
+		landlordID, period, period.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []models.RentalIncomeLine{}
+	for rows.Next() {
+		var l models.RentalIncomeLine
+		if err := rows.Scan(&l.TenantID, &l.TenantName, &l.PropertyID, &l.PropertyTitle, &l.Location, &l.UnitName,
+			&l.Amount, &l.PaymentCount); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// File records the month's return as filed with iTax under prn, and paid
+// when paid is set, at the figures computed now. Filing again amends it.
+func (s *TaxService) File(ctx context.Context, userID, landlordID int, period time.Time, prn string, paid bool, now time.Time) (models.RentalIncomeReturn, error) {
+	prn = strings.ToUpper(strings.TrimSpace(prn))
+	if !prnPattern.MatchString(prn) {
+		return models.RentalIncomeReturn{}, ErrInvalidPRN
+	}
+	if now.Before(period.AddDate(0, 1, 0)) {
+		return models.RentalIncomeReturn{}, ErrPeriodOpen
+	}
+	r, err := s.Return(ctx, landlordID, period, now)
+	if err != nil {
+		return r, err
+	}
+
+	status := ReturnFiled
+	if paid {
+		status = ReturnPaid
+	}
+	_, err = s.DB.ExecContext(ctx, `
+		INSERT INTO mri_returns (landlord_id, period, gross_rent, rate_percent, tax_due, prn, status, filed_by, paid_at)
+		VALUES ($1, $2::DATE, $3, $4, $5, $6, $7, $8, CASE WHEN $9 THEN NOW() END)
+		ON CONFLICT (landlord_id, period) DO UPDATE SET
+			gross_rent = EXCLUDED.gross_rent,
+			rate_percent = EXCLUDED.rate_percent,
+			tax_due = EXCLUDED.tax_due,
+			prn = EXCLUDED.prn,
+			status = EXCLUDED.status,
+			filed_by = EXCLUDED.filed_by,
+			filed_at = NOW(),
+			paid_at = CASE WHEN $9 THEN COALESCE(mri_returns.paid_at, NOW()) END,
+			updated_at = NOW()`,
+		landlordID, period.Format(dateLayout), r.GrossRent, r.RatePercent, r.TaxDue, prn, status, userID, paid)
+	if err != nil {
+		return r, err
+	}
+	return s.Return(ctx, landlordID, period, now)
+}
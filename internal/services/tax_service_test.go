@@ -0,0 +1,98 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+)
+
+func TestBuildReturn(t *testing.T) {
+	ceiling := 15000000.0
+	rates := []models.TaxRate{
+		{RatePercent: 7.5, MinAnnualIncome: 288000, MaxAnnualIncome: &ceiling, EffectiveFrom: "2024-01-01"},
+		{RatePercent: 10, MinAnnualIncome: 144000, MaxAnnualIncome: &ceiling, EffectiveFrom: "2023-01-01"},
+	}
+	period := time.Date(2026, time.September, 1, 0, 0, 0, 0, reminderZone)
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2026, month, day, hour, 0, 0, 0, reminderZone)
+	}
+	rent := monthRent{amount: 50000, count: 2}
+
+	tests := []struct {
+		name    string
+		annual  float64
+		rate    *models.TaxRate
+		now     time.Time
+		status  string
+		inScope bool
+		taxDue  float64
+	}{
+		{"month not over", 600000, &rates[0], at(time.September, 30, 23), ReturnOpen, true, 3750},
+		{"due", 600000, &rates[0], at(time.October, 1, 0), ReturnDue, true, 3750},
+		{"due on the 20th", 600000, &rates[0], at(time.October, 20, 23), ReturnDue, true, 3750},
+		{"overdue after the 20th", 600000, &rates[0], at(time.October, 21, 0), ReturnOverdue, true, 3750},
+		{"at the lower bound", 288000, &rates[0], at(time.October, 5, 0), ReturnDue, true, 3750},
+		{"below the lower bound", 287999, &rates[0], at(time.October, 5, 0), ReturnExempt, false, 0},
+		{"above the upper bound", 15000001, &rates[0], at(time.October, 5, 0), ReturnExempt, false, 0},
+		{"no upper bound", 15000001, &models.TaxRate{RatePercent: 7.5, MinAnnualIncome: 288000}, at(time.October, 5, 0), ReturnDue, true, 3750},
+		{"no rate in force", 600000, nil, at(time.October, 5, 0), ReturnExempt, false, 0},
+		{"exempt while open", 100, &rates[0], at(time.September, 15, 0), ReturnOpen, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := buildReturn(period, rent, tt.annual, tt.rate, nil, tt.now)
+			if r.Status != tt.status || r.InScope != tt.inScope || r.TaxDue != tt.taxDue {
+				t.Errorf("status %s, in scope %v, tax %v; want %s, %v, %v", r.Status, r.InScope, r.TaxDue, tt.status, tt.inScope, tt.taxDue)
+			}
+			if r.Period != "2026-09" || r.DueDate != "2026-10-20" {
+				t.Errorf("period %s due %s, want 2026-09 due 2026-10-20", r.Period, r.DueDate)
+			}
+		})
+	}
+}
+
+func TestBuildReturnFiled(t *testing.T) {
+	period := time.Date(2026, time.September, 1, 0, 0, 0, 0, reminderZone)
+	rate := &models.TaxRate{RatePercent: 7.5, MinAnnualIncome: 288000}
+	now := time.Date(2026, time.November, 1, 0, 0, 0, 0, reminderZone)
+	filed := &filedReturn{
+		grossRent: 50000, taxDue: 3750, prn: "PRN123456", status: ReturnPaid,
+		filedAt: time.Date(2026, time.October, 10, 0, 0, 0, 0, reminderZone),
+		paidAt:  sql.NullTime{Time: time.Date(2026, time.October, 11, 0, 0, 0, 0, reminderZone), Valid: true},
+	}
+
+	r := buildReturn(period, monthRent{amount: 50000, count: 2}, 600000, rate, filed, now)
+	if r.Status != ReturnPaid || r.PRN != "PRN123456" || r.Amended || r.PaidAt == nil {
+		t.Errorf("filed return: status %s, prn %s, amended %v, paid %v", r.Status, r.PRN, r.Amended, r.PaidAt)
+	}
+
+	// A late payment for the month changes the rent received after filing
+	r = buildReturn(period, monthRent{amount: 65000, count: 3}, 615000, rate, filed, now)
+	if !r.Amended || r.TaxDue != 4875 || *r.FiledTaxDue != 3750 {
+		t.Errorf("late payment: amended %v, tax %v, filed tax %v", r.Amended, r.TaxDue, *r.FiledTaxDue)
+	}
+}
+
+func TestRateFor(t *testing.T) {
+	rates := []models.TaxRate{
+		{ID: 3, EffectiveFrom: "2024-01-01"},
+		{ID: 2, EffectiveFrom: "2023-01-01"},
+	}
+	tests := []struct {
+		day  time.Time
+		want uint
+	}{
+		{time.Date(2026, 1, 1, 0, 0, 0, 0, reminderZone), 3},
+		{time.Date(2024, 1, 1, 0, 0, 0, 0, reminderZone), 3},
+		{time.Date(2023, 12, 1, 0, 0, 0, 0, reminderZone), 2},
+		{time.Date(2022, 12, 1, 0, 0, 0, 0, reminderZone), 0},
+	}
+	for _, tt := range tests {
+		got := rateFor(rates, tt.day)
+		if (got == nil && tt.want != 0) || (got != nil && got.ID != tt.want) {
+			t.Errorf("rateFor(%s) = %v, want rate %d", tt.day.Format(dateLayout), got, tt.want)
+		}
+	}
+}
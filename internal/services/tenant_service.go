@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Zolet-hash/smart-rentals/internal/events"
+	"github.com/Zolet-hash/smart-rentals/internal/listing"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/Zolet-hash/smart-rentals/internal/phone"
+	"github.com/Zolet-hash/smart-rentals/internal/repository"
+)
+
+var (
+	ErrTenantNotFound    = errors.New("tenant not found or unauthorized")
+	ErrTenantIncomplete  = errors.New("tenant name and payment number are required")
+	ErrInvalidRent       = errors.New("rent must be positive")
+	ErrInvalidRentDueDay = errors.New("rent due day must be between 1 and 28")
+	ErrInvalidTenantUser = errors.New("user_id must be a user with the tenant role")
+	ErrTenantUserTaken   = errors.New("user is already linked to another tenant")
+)
+
+// TenantService holds the rules for tenants: a tenant occupies a unit from
+// onboarding until removal, and starts owing a month's rent
+type TenantService struct {
+	Store  repository.Store
+	Events events.Publisher
+}
+
+func NewTenantService(store repository.Store, publisher events.Publisher) *TenantService {
+	return &TenantService{Store: store, Events: publisher}
+}
+
+// TenantInput onboards a tenant. RentDueDay defaults to the 1st.
+type TenantInput struct {
+	TenantName string
+	PaymentNo1 string
+	PaymentNo2 string
+	Rent       float64
+	RentDueDay int
+	Email      string
+}
+
+func (s *TenantService) GetTenant(ctx context.Context, userID int, perm permissions.Permission, id int) (models.TenantWithUnit, error) {
+	t, err := s.Store.Tenants().Get(ctx, userID, perm, id)
+	return t, notFound(err, ErrTenantNotFound)
+}
+
+// ListTenants lists the tenants the user may read, of one unit unless
+// unitID is 0
+func (s *TenantService) ListTenants(ctx context.Context, userID, unitID int, page listing.Page, f listing.Filter) (repository.Page[models.TenantWithUnit], error) {
+	return s.Store.Tenants().List(ctx, userID, permissions.TenantsRead, unitID, page, f)
+}
+
+// CreateTenant onboards a tenant onto a unit the user may add tenants to
+// and marks the unit occupied. The tenant belongs to the property's landlord
+// even when a caretaker onboards them.
+func (s *TenantService) CreateTenant(ctx context.Context, userID, unitID int, in TenantInput) (models.Tenant, error) {
+	if in.TenantName == "" || in.PaymentNo1 == "" {
+		return models.Tenant{}, ErrTenantIncomplete
+	}
+	if in.Rent <= 0 {
+		return models.Tenant{}, ErrInvalidRent
+	}
+	if in.RentDueDay == 0 {
+		in.RentDueDay = 1
+	}
+	if in.RentDueDay < 1 || in.RentDueDay > 28 {
+		return models.Tenant{}, ErrInvalidRentDueDay
+	}
+
+	landlordID, err := s.Store.Units().LandlordID(ctx, userID, permissions.TenantsWrite, unitID)
+	if err != nil {
+		return models.Tenant{}, notFound(err, ErrUnitNotFound)
+	}
+
+	// Spec: "Balance initialized = rent". Payment numbers are stored the
+	// way M-Pesa reports payers so callbacks match them exactly.
+	t := models.Tenant{
+		UnitID:     uint(unitID),
+		LandlordID: uint(landlordID),
+		TenantName: in.TenantName,
+		PaymentNo1: phone.PaymentNumber(in.PaymentNo1),
+		PaymentNo2: phone.PaymentNumber(in.PaymentNo2),
+		Rent:       in.Rent,
+		Balance:    in.Rent,
+		RentDueDay: in.RentDueDay,
+		Email:      in.Email,
+	}
+	err = s.Store.WithTx(ctx, func(tx repository.Store) error {
+		if err := tx.Tenants().Create(ctx, &t); err != nil {
+			return err
+		}
+		return tx.Units().SetVacancy(ctx, unitID, false)
+	})
+	if err != nil {
+		return models.Tenant{}, notFound(err, ErrUnitNotFound)
+	}
+
+	s.Events.Publish(ctx, events.New(events.TenantCreated, landlordID, map[string]interface{}{
+		"tenant_id":   t.ID,
+		"unit_id":     unitID,
+		"tenant_name": t.TenantName,
+		"payment_no1": t.PaymentNo1,
+		"rent":        t.Rent,
+		"balance":     t.Balance,
+	}))
+	return t, nil
+}
+
+// UpdateTenant applies the set fields of u to a tenant the user may write.
+// A linked account must have the tenant role and serve no other tenant.
+func (s *TenantService) UpdateTenant(ctx context.Context, userID, id int, u repository.TenantUpdate) error {
+	if u.RentDueDay != nil && (*u.RentDueDay < 1 || *u.RentDueDay > 28) {
+		return ErrInvalidRentDueDay
+	}
+	if _, err := s.GetTenant(ctx, userID, permissions.TenantsWrite, id); err != nil {
+		return err
+	}
+	if u.UserID != nil && *u.UserID != 0 {
+		isTenant, err := s.Store.Users().HasRole(ctx, *u.UserID, permissions.RoleTenant)
+		if err != nil {
+			return err
+		}
+		if !isTenant {
+			return ErrInvalidTenantUser
+		}
+	}
+
+	if u.PaymentNo1 != nil {
+		no := phone.PaymentNumber(*u.PaymentNo1)
+		u.PaymentNo1 = &no
+	}
+	if u.PaymentNo2 != nil {
+		no := phone.PaymentNumber(*u.PaymentNo2)
+		u.PaymentNo2 = &no
+	}
+
+	err := s.Store.Tenants().Update(ctx, id, u)
+	if errors.Is(err, repository.ErrConflict) {
+		return ErrTenantUserTaken
+	}
+	return notFound(err, ErrTenantNotFound)
+}
+
+// RemoveTenant deletes a tenant and vacates their unit. Tenants are hard
+// deleted; their payments stay, unassigned.
+func (s *TenantService) RemoveTenant(ctx context.Context, userID, id int) error {
+	err := s.Store.WithTx(ctx, func(tx repository.Store) error {
+		t, err := tx.Tenants().Get(ctx, userID, permissions.TenantsWrite, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Tenants().Delete(ctx, id); err != nil {
+			return err
+		}
+		return tx.Units().SetVacancy(ctx, int(t.UnitID), true)
+	})
+	return notFound(err, ErrTenantNotFound)
+}
+
+// Snapshot is a tenant's audit record
+func (s *TenantService) Snapshot(ctx context.Context, id int) ([]byte, error) {
+	return s.Store.Tenants().Snapshot(ctx, id)
+}
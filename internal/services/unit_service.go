@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Zolet-hash/smart-rentals/internal/listing"
+	"github.com/Zolet-hash/smart-rentals/internal/models"
+	"github.com/Zolet-hash/smart-rentals/internal/permissions"
+	"github.com/Zolet-hash/smart-rentals/internal/repository"
+)
+
+var (
+	ErrUnitNotFound     = errors.New("unit not found or unauthorized")
+	ErrUnitOccupied     = errors.New("unit has active tenants; remove them first")
+	ErrInvalidUnitPrice = errors.New("unit price must be positive")
+	ErrUnitIncomplete   = errors.New("unit name and type are required")
+)
+
+// UnitService holds the rules for units: they are added vacant to a
+// property and only deleted once empty
+type UnitService struct {
+	Store repository.Store
+}
+
+func NewUnitService(store repository.Store) *UnitService {
+	return &UnitService{Store: store}
+}
+
+// UnitInput creates a unit
+type UnitInput struct {
+	UnitName  string
+	UnitType  string
+	UnitPrice float64
+}
+
+func (s *UnitService) GetUnit(ctx context.Context, userID int, perm permissions.Permission, propertyID, id int) (models.Unit, error) {
+	u, err := s.Store.Units().Get(ctx, userID, perm, propertyID, id)
+	return u, notFound(err, ErrUnitNotFound)
+}
+
+// ListUnits lists the units of a property the user may read
+func (s *UnitService) ListUnits(ctx context.Context, userID, propertyID int, page listing.Page, f listing.Filter) (repository.Page[models.Unit], error) {
+	if _, err := s.Store.Properties().Get(ctx, userID, permissions.UnitsRead, propertyID); err != nil {
+		return repository.Page[models.Unit]{}, notFound(err, ErrPropertyNotFound)
+	}
+	return s.Store.Units().List(ctx, propertyID, page, f)
+}
+
+// CreateUnit adds a vacant unit to a property the user may add units to
+func (s *UnitService) CreateUnit(ctx context.Context, userID, propertyID int, in UnitInput) (models.Unit, error) {
+	if in.UnitName == "" || in.UnitType == "" {
+		return models.Unit{}, ErrUnitIncomplete
+	}
+	if in.UnitPrice <= 0 {
+		return models.Unit{}, ErrInvalidUnitPrice
+	}
+	if _, err := s.Store.Properties().Get(ctx, userID, permissions.UnitsWrite, propertyID); err != nil {
+		return models.Unit{}, notFound(err, ErrPropertyNotFound)
+	}
+
+	u := models.Unit{
+		PropertyID: uint(propertyID),
+		UnitName:   in.UnitName,
+		UnitType:   in.UnitType,
+		UnitPrice:  in.UnitPrice,
+	}
+	if err := s.Store.Units().Create(ctx, &u); err != nil {
+		return models.Unit{}, notFound(err, ErrPropertyNotFound)
+	}
+	return u, nil
+}
+
+// UpdateUnit applies the set fields of u to a unit the user may write
+func (s *UnitService) UpdateUnit(ctx context.Context, userID, propertyID, id int, u repository.UnitUpdate) error {
+	if u.UnitPrice != nil && *u.UnitPrice <= 0 {
+		return ErrInvalidUnitPrice
+	}
+	if _, err := s.GetUnit(ctx, userID, permissions.UnitsWrite, propertyID, id); err != nil {
+		return err
+	}
+	return notFound(s.Store.Units().Update(ctx, id, u), ErrUnitNotFound)
+}
+
+// DeleteUnit removes a unit that has no tenants
+func (s *UnitService) DeleteUnit(ctx context.Context, userID, propertyID, id int) error {
+	if _, err := s.GetUnit(ctx, userID, permissions.UnitsWrite, propertyID, id); err != nil {
+		return err
+	}
+	err := s.Store.Units().Delete(ctx, id)
+	if errors.Is(err, repository.ErrInUse) {
+		return ErrUnitOccupied
+	}
+	return notFound(err, ErrUnitNotFound)
+}
+
+// Snapshot is a unit's audit record
+func (s *UnitService) Snapshot(ctx context.Context, id int) ([]byte, error) {
+	return s.Store.Units().Snapshot(ctx, id)
+}
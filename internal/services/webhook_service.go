@@ -0,0 +1,694 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/config"
+	"github.com/Zolet-hash/smart-rentals/internal/database"
+	"github.com/Zolet-hash/smart-rentals/internal/events"
+	pkgutils "github.com/Zolet-hash/smart-rentals/internal/pkg/utils"
+	"github.com/Zolet-hash/smart-rentals/internal/utils"
+	"github.com/lib/pq"
+)
+
+// Webhook delivery statuses
+const (
+	WebhookPending   = "pending"
+	WebhookSucceeded = "succeeded"
+	WebhookFailed    = "failed"
+)
+
+// WebhookTestEvent is sent by POST /webhooks/:id/test
+const WebhookTestEvent = "webhook.test"
+
+const (
+	webhookMaxAttempts  = 10
+	webhookBaseBackoff  = 30 * time.Second
+	webhookMaxBackoff   = 6 * time.Hour
+	webhookLease        = 2 * time.Minute // a claimed delivery is retried after this if the worker dies
+	webhookBatchSize    = 20
+	webhookMaxResponse  = 1024
+	webhookSignatureHdr = "X-Webhook-Signature"
+)
+
+var (
+	ErrWebhookNotFound   = errors.New("webhook endpoint not found")
+	ErrInvalidWebhookURL = errors.New("webhook URL must be an absolute https URL on a public host")
+	ErrInvalidEventType  = errors.New("unknown event type")
+)
+
+type WebhookService struct {
+	DB     *database.Database
+	Cfg    *config.Config
+	Client *http.Client
+}
+
+func NewWebhookService(db *database.Database, cfg *config.Config) *WebhookService {
+	return &WebhookService{
+		DB:     db,
+		Cfg:    cfg,
+		Client: newWebhookClient(cfg.Webhooks.AllowPrivateHosts),
+	}
+}
+
+// errNonPublicAddress stops a delivery to an address inside our network
+var errNonPublicAddress = errors.New("webhook host resolves to a non-public address")
+
+// nonPublicPrefixes are ranges outside net.IP's private/loopback checks that
+// still must not be reachable from webhooks (CGNAT, benchmarking, IETF, NAT64)
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// isPublicAddr reports whether ip is a globally routable unicast address
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() ||
+		ip.IsLinkLocalUnicast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// newWebhookClient builds the delivery client. Every connection's resolved
+// address is checked as it is dialled, so hostnames pointing inside the
+// network (or DNS rebinding between validation and delivery) are refused,
+// and redirects are not followed. allowPrivate lifts the address check for
+// local development against receivers on localhost.
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(host)
+			if err != nil || !isPublicAddr(ip) {
+				return errNonPublicAddress
+			}
+			return nil
+		}
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy:                 nil, // a proxy would dial on our behalf, past the address check
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          20,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 10 * time.Second,
+		},
+		// A redirect is recorded as the endpoint's (non-2xx) response
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+type WebhookEndpoint struct {
+	ID          int       `json:"id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Events      []string  `json:"events"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	EndpointID     int             `json:"endpoint_id"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code"`
+	LastError      string          `json:"last_error"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// webhookEnvelope is the JSON body POSTed to endpoints
+type webhookEnvelope struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	LandlordID int         `json:"landlord_id"`
+	CreatedAt  time.Time   `json:"created_at"`
+	Data       interface{} `json:"data"`
+}
+
+// --- Endpoint management ---
+
+// validateWebhookURL requires https (http is allowed outside production for
+// local testing) and a host that resolves only to public addresses, unless
+// private hosts are allowed. The delivery client checks addresses again on
+// every connection; this only turns bad URLs away early.
+func (s *WebhookService) validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+	production := s.Cfg.Environment == "production"
+	if u.Scheme != "https" && (production || u.Scheme != "http") {
+		return ErrInvalidWebhookURL
+	}
+	if s.Cfg.Webhooks.AllowPrivateHosts {
+		return nil
+	}
+
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		return ErrInvalidWebhookURL
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if !isPublicAddr(ip) {
+			return ErrInvalidWebhookURL
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return ErrInvalidWebhookURL
+	}
+	for _, ip := range addrs {
+		if !isPublicAddr(ip) {
+			return ErrInvalidWebhookURL
+		}
+	}
+	return nil
+}
+
+func validateEventTypes(types []string) error {
+	for _, t := range types {
+		if !events.IsValidType(t) {
+			return fmt.Errorf("%w: %s", ErrInvalidEventType, t)
+		}
+	}
+	return nil
+}
+
+// CreateEndpoint registers an endpoint and returns it with its signing
+// secret. The secret is only ever returned here and by RotateSecret.
+func (s *WebhookService) CreateEndpoint(landlordID int, rawURL, description string, eventTypes []string) (WebhookEndpoint, string, error) {
+	if err := s.validateWebhookURL(rawURL); err != nil {
+		return WebhookEndpoint{}, "", err
+	}
+	if err := validateEventTypes(eventTypes); err != nil {
+		return WebhookEndpoint{}, "", err
+	}
+
+	secret, encrypted, err := s.newSecret()
+	if err != nil {
+		return WebhookEndpoint{}, "", err
+	}
+
+	ep := WebhookEndpoint{URL: rawURL, Description: description, Events: eventTypes, Active: true}
+	err = s.DB.QueryRow(`
+		INSERT INTO webhook_endpoints (landlord_id, url, description, secret, events)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`,
+		landlordID, rawURL, description, encrypted, pq.Array(eventTypes),
+	).Scan(&ep.ID, &ep.CreatedAt, &ep.UpdatedAt)
+	if err != nil {
+		return WebhookEndpoint{}, "", err
+	}
+	return ep, secret, nil
+}
+
+// ListEndpoints returns the landlord's endpoints
+func (s *WebhookService) ListEndpoints(landlordID int) ([]WebhookEndpoint, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, url, COALESCE(description, ''), events, active, created_at, updated_at
+		FROM webhook_endpoints
+		WHERE landlord_id = $1
+		ORDER BY created_at DESC`,
+		landlordID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	endpoints := []WebhookEndpoint{}
+	for rows.Next() {
+		var ep WebhookEndpoint
+		if err := rows.Scan(&ep.ID, &ep.URL, &ep.Description, pq.Array(&ep.Events), &ep.Active, &ep.CreatedAt, &ep.UpdatedAt); err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, rows.Err()
+}
+
+// UpdateEndpoint changes the given fields; nil leaves a field unchanged
+func (s *WebhookService) UpdateEndpoint(landlordID, endpointID int, rawURL, description *string, eventTypes []string, active *bool) error {
+	if rawURL != nil {
+		if err := s.validateWebhookURL(*rawURL); err != nil {
+			return err
+		}
+	}
+	var eventsArg interface{}
+	if eventTypes != nil {
+		if err := validateEventTypes(eventTypes); err != nil {
+			return err
+		}
+		eventsArg = pq.Array(eventTypes)
+	}
+
+	result, err := s.DB.Exec(`
+		UPDATE webhook_endpoints SET
+			url = COALESCE($1, url),
+			description = COALESCE($2, description),
+			events = COALESCE($3, events),
+			active = COALESCE($4, active),
+			updated_at = NOW()
+		WHERE id = $5 AND landlord_id = $6`,
+		rawURL, description, eventsArg, active, endpointID, landlordID,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// DeleteEndpoint removes an endpoint and its delivery log
+func (s *WebhookService) DeleteEndpoint(landlordID, endpointID int) error {
+	result, err := s.DB.Exec("DELETE FROM webhook_endpoints WHERE id = $1 AND landlord_id = $2", endpointID, landlordID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// RotateSecret replaces an endpoint's signing secret and returns the new one
+func (s *WebhookService) RotateSecret(landlordID, endpointID int) (string, error) {
+	secret, encrypted, err := s.newSecret()
+	if err != nil {
+		return "", err
+	}
+	result, err := s.DB.Exec("UPDATE webhook_endpoints SET secret = $1, updated_at = NOW() WHERE id = $2 AND landlord_id = $3",
+		encrypted, endpointID, landlordID)
+	if err != nil {
+		return "", err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return "", ErrWebhookNotFound
+	}
+	return secret, nil
+}
+
+func (s *WebhookService) newSecret() (string, string, error) {
+	token, err := pkgutils.GenerateToken(24)
+	if err != nil {
+		return "", "", err
+	}
+	secret := "whsec_" + token
+	encrypted, err := utils.Encrypt(secret, s.Cfg.JWT.Secret)
+	if err != nil {
+		return "", "", err
+	}
+	return secret, encrypted, nil
+}
+
+// ListDeliveries returns the most recent deliveries of an endpoint
+func (s *WebhookService) ListDeliveries(landlordID, endpointID, limit int) ([]WebhookDelivery, error) {
+	var owned bool
+	err := s.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM webhook_endpoints WHERE id = $1 AND landlord_id = $2)", endpointID, landlordID).Scan(&owned)
+	if err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, ErrWebhookNotFound
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT id, endpoint_id, event_id, event_type, payload, status, attempts,
+			CASE WHEN status = 'pending' THEN next_attempt_at END,
+			last_attempt_at, last_status_code, COALESCE(last_error, ''), delivered_at, created_at
+		FROM webhook_deliveries
+		WHERE endpoint_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`,
+		endpointID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhookDelivery(row rowScanner) (WebhookDelivery, error) {
+	var d WebhookDelivery
+	var next, last, delivered sql.NullTime
+	var code sql.NullInt64
+	err := row.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&next, &last, &code, &d.LastError, &delivered, &d.CreatedAt)
+	if err != nil {
+		return d, err
+	}
+	if next.Valid {
+		d.NextAttemptAt = &next.Time
+	}
+	if last.Valid {
+		d.LastAttemptAt = &last.Time
+	}
+	if code.Valid {
+		c := int(code.Int64)
+		d.LastStatusCode = &c
+	}
+	if delivered.Valid {
+		d.DeliveredAt = &delivered.Time
+	}
+	return d, nil
+}
+
+// --- Event intake ---
+
+// HandleEvent queues a delivery for every active endpoint of the event's
+// landlord subscribed to its type. Subscribe it to the event bus.
+func (s *WebhookService) HandleEvent(ctx context.Context, e events.Event) {
+	eventID, payload, err := buildWebhookPayload(e)
+	if err != nil {
+		log.Printf("webhooks: encode %s failed: %v", e.Type, err)
+		return
+	}
+
+	_, err = s.DB.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload)
+		SELECT id, $1, $2, $3
+		FROM webhook_endpoints
+		WHERE landlord_id = $4 AND active AND $2 = ANY(events)`,
+		eventID, e.Type, payload, e.LandlordID,
+	)
+	if err != nil {
+		log.Printf("webhooks: enqueue %s for landlord %d failed: %v", e.Type, e.LandlordID, err)
+	}
+}
+
+func buildWebhookPayload(e events.Event) (string, []byte, error) {
+	token, err := pkgutils.GenerateToken(12)
+	if err != nil {
+		return "", nil, err
+	}
+	eventID := "evt_" + token
+	payload, err := json.Marshal(webhookEnvelope{
+		ID:         eventID,
+		Type:       e.Type,
+		LandlordID: e.LandlordID,
+		CreatedAt:  e.OccurredAt,
+		Data:       e.Data,
+	})
+	return eventID, payload, err
+}
+
+// SendTest queues a test event for one endpoint and attempts it immediately,
+// returning the resulting delivery
+func (s *WebhookService) SendTest(ctx context.Context, landlordID, endpointID int) (WebhookDelivery, error) {
+	var owned bool
+	err := s.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM webhook_endpoints WHERE id = $1 AND landlord_id = $2)", endpointID, landlordID).Scan(&owned)
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+	if !owned {
+		return WebhookDelivery{}, ErrWebhookNotFound
+	}
+
+	eventID, payload, err := buildWebhookPayload(events.New(WebhookTestEvent, landlordID, map[string]interface{}{
+		"message": "This is a test event",
+	}))
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+
+	// Lease it immediately so the background worker does not race this attempt
+	var deliveryID int64
+	err = s.DB.QueryRowContext(ctx, `
+		INSERT INTO webhook_deliveries (endpoint_id, event_id, event_type, payload, next_attempt_at)
+		VALUES ($1, $2, $3, $4, NOW() + $5 * INTERVAL '1 second')
+		RETURNING id`,
+		endpointID, eventID, WebhookTestEvent, payload, webhookLease.Seconds(),
+	).Scan(&deliveryID)
+	if err != nil {
+		return WebhookDelivery{}, err
+	}
+
+	s.attempt(ctx, claimedDelivery{ID: deliveryID, EndpointID: endpointID, EventID: eventID, EventType: WebhookTestEvent, Payload: payload})
+
+	row := s.DB.QueryRowContext(ctx, `
+		SELECT id, endpoint_id, event_id, event_type, payload, status, attempts,
+			CASE WHEN status = 'pending' THEN next_attempt_at END,
+			last_attempt_at, last_status_code, COALESCE(last_error, ''), delivered_at, created_at
+		FROM webhook_deliveries WHERE id = $1`, deliveryID)
+	return scanWebhookDelivery(row)
+}
+
+// --- Delivery worker ---
+
+type claimedDelivery struct {
+	ID         int64
+	EndpointID int
+	EventID    string
+	EventType  string
+	Payload    []byte
+	Attempts   int
+}
+
+// Run delivers due webhooks every interval until ctx is cancelled. Several
+// instances may run concurrently: rows are claimed with SKIP LOCKED and leased.
+func (s *WebhookService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := s.ProcessDue(ctx)
+			if err != nil {
+				log.Printf("webhooks: worker error: %v", err)
+				break
+			}
+			if n < webhookBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessDue claims a batch of due deliveries and attempts them, returning how many were claimed
+func (s *WebhookService) ProcessDue(ctx context.Context) (int, error) {
+	rows, err := s.DB.QueryContext(ctx, `
+		UPDATE webhook_deliveries SET next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, endpoint_id, event_id, event_type, payload, attempts`,
+		webhookBatchSize, webhookLease.Seconds(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var batch []claimedDelivery
+	for rows.Next() {
+		var d claimedDelivery
+		if err := rows.Scan(&d.ID, &d.EndpointID, &d.EventID, &d.EventType, &d.Payload, &d.Attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, d := range batch {
+		s.attempt(ctx, d)
+	}
+	return len(batch), nil
+}
+
+// attempt POSTs one delivery and records the outcome, scheduling a retry
+// with exponential backoff on failure
+func (s *WebhookService) attempt(ctx context.Context, d claimedDelivery) {
+	var endpointURL, encryptedSecret string
+	var active bool
+	err := s.DB.QueryRowContext(ctx, "SELECT url, secret, active FROM webhook_endpoints WHERE id = $1", d.EndpointID).
+		Scan(&endpointURL, &encryptedSecret, &active)
+	if err != nil {
+		log.Printf("webhooks: load endpoint %d failed: %v", d.EndpointID, err)
+		return // lease expires and the delivery is retried
+	}
+	if !active {
+		s.finish(ctx, d.ID, WebhookFailed, d.Attempts, nil, "endpoint disabled", "")
+		return
+	}
+
+	secret, err := utils.Decrypt(encryptedSecret, s.Cfg.JWT.Secret)
+	if err != nil {
+		s.finish(ctx, d.ID, WebhookFailed, d.Attempts, nil, "cannot decrypt endpoint secret", "")
+		return
+	}
+
+	attempts := d.Attempts + 1
+	statusCode, body, err := s.post(ctx, endpointURL, secret, d)
+	switch {
+	case err == nil && statusCode >= 200 && statusCode < 300:
+		s.finish(ctx, d.ID, WebhookSucceeded, attempts, &statusCode, "", body)
+	case attempts >= webhookMaxAttempts:
+		s.finish(ctx, d.ID, WebhookFailed, attempts, nullableCode(statusCode), attemptError(statusCode, err), body)
+	default:
+		s.retryLater(ctx, d.ID, attempts, nullableCode(statusCode), attemptError(statusCode, err), body)
+	}
+}
+
+func (s *WebhookService) post(ctx context.Context, endpointURL, secret string, d claimedDelivery) (int, string, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointURL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SmartRentals-Webhooks/1.0")
+	req.Header.Set("X-Webhook-Id", d.EventID)
+	req.Header.Set("X-Webhook-Event", d.EventType)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(d.ID, 10))
+	req.Header.Set(webhookSignatureHdr, "t="+timestamp+",v1="+SignWebhook(secret, timestamp, d.Payload))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookMaxResponse))
+	return resp.StatusCode, string(body), nil
+}
+
+// SignWebhook computes the v1 signature: hex HMAC-SHA256 of "<timestamp>.<body>".
+// Receivers recompute it with their secret and compare in constant time.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff is the delay before the next attempt after `attempts` failures
+func webhookBackoff(attempts int) time.Duration {
+	delay := webhookBaseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= webhookMaxBackoff {
+			return webhookMaxBackoff
+		}
+	}
+	return delay
+}
+
+func (s *WebhookService) retryLater(ctx context.Context, id int64, attempts int, code *int, errMsg, body string) {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET
+			attempts = $2, last_attempt_at = NOW(), last_status_code = $3, last_error = $4, last_response = $5,
+			next_attempt_at = NOW() + $6 * INTERVAL '1 second'
+		WHERE id = $1`,
+		id, attempts, code, errMsg, body, webhookBackoff(attempts).Seconds(),
+	)
+	if err != nil {
+		log.Printf("webhooks: record attempt for delivery %d failed: %v", id, err)
+	}
+}
+
+func (s *WebhookService) finish(ctx context.Context, id int64, status string, attempts int, code *int, errMsg, body string) {
+	_, err := s.DB.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET
+			status = $2, attempts = $3, last_attempt_at = NOW(), last_status_code = $4,
+			last_error = NULLIF($5, ''), last_response = $6,
+			delivered_at = CASE WHEN $2::TEXT = 'succeeded' THEN NOW() END
+		WHERE id = $1`,
+		id, status, attempts, code, errMsg, body,
+	)
+	if err != nil {
+		log.Printf("webhooks: record result for delivery %d failed: %v", id, err)
+	}
+}
+
+func nullableCode(code int) *int {
+	if code == 0 {
+		return nil
+	}
+	return &code
+}
+
+func attemptError(code int, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("endpoint responded with HTTP %d", code)
+}
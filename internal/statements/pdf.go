@@ -0,0 +1,179 @@
+package statements
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// phrase is text drawn together on one line of a PDF page
+type phrase struct {
+	x0, x1 float64
+	text   string
+}
+
+// pdfTable recovers the transaction table of a PDF statement as rows of
+// cells. Columns are placed by the position of the header above them, and
+// details wrapped onto further lines are joined to their transaction.
+func pdfTable(data []byte, password string) (rows [][]string, err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			rows, err = nil, fmt.Errorf("%w: unreadable PDF", ErrUnsupportedFile)
+		}
+	}()
+
+	tried := false
+	r, err := pdf.NewReaderEncrypted(bytes.NewReader(data), int64(len(data)), func() string {
+		if tried {
+			return ""
+		}
+		tried = true
+		return password
+	})
+	if err == pdf.ErrInvalidPassword {
+		return nil, ErrPasswordRequired
+	}
+	if err != nil {
+		return nil, ErrUnsupportedFile
+	}
+
+	var header []phrase
+	var cols map[string]int
+	// open is whether the last row is a transaction that wrapped text may
+	// still belong to
+	open := false
+	for n := 1; n <= r.NumPage(); n++ {
+		p := r.Page(n)
+		if p.V.IsNull() {
+			continue
+		}
+		open = false
+		for _, line := range pageLines(p.Content().Text) {
+			texts := make([]string, len(line))
+			for i, ph := range line {
+				texts[i] = ph.text
+			}
+			if c, ok := findColumns(texts); ok {
+				header, cols, open = line, c, false
+				rows = append(rows, texts)
+				continue
+			}
+			if header == nil {
+				continue
+			}
+
+			cells := make([]string, len(header))
+			for _, ph := range line {
+				i := nearestColumn(header, ph)
+				cells[i] = strings.TrimSpace(cells[i] + " " + ph.text)
+			}
+			if cells[cols[colTime]] != "" {
+				rows = append(rows, cells)
+				open = true
+				continue
+			}
+			// A line without a time is details wrapped from the transaction
+			// above, or a heading or footer when it strays into other columns
+			if open && onlyText(cells, cols) {
+				prev := rows[len(rows)-1]
+				for i, c := range cells {
+					if c != "" {
+						prev[i] = strings.TrimSpace(prev[i] + " " + c)
+					}
+				}
+				continue
+			}
+			open = false
+		}
+	}
+	return rows, nil
+}
+
+// onlyText reports whether the filled cells are all details or party text
+func onlyText(cells []string, cols map[string]int) bool {
+	for i, c := range cells {
+		if c == "" {
+			continue
+		}
+		if d, ok := cols[colDetails]; ok && d == i {
+			continue
+		}
+		if p, ok := cols[colParty]; ok && p == i {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// pageLines groups a page's glyphs into lines, top to bottom, and each line
+// into phrases split where the gap is wider than a few spaces
+func pageLines(glyphs []pdf.Text) [][]phrase {
+	sort.SliceStable(glyphs, func(i, j int) bool {
+		if math.Abs(glyphs[i].Y-glyphs[j].Y) > 1 {
+			return glyphs[i].Y > glyphs[j].Y
+		}
+		return glyphs[i].X < glyphs[j].X
+	})
+
+	var lines [][]phrase
+	var line []phrase
+	lastY := math.NaN()
+	for _, g := range glyphs {
+		if g.S == "" {
+			continue
+		}
+		if math.IsNaN(lastY) || math.Abs(g.Y-lastY) > 1 {
+			if len(line) > 0 {
+				lines = append(lines, line)
+			}
+			line, lastY = nil, g.Y
+		}
+		size := g.FontSize
+		if size <= 0 {
+			size = 8
+		}
+		if len(line) == 0 || g.X-line[len(line)-1].x1 > size*1.2 {
+			line = append(line, phrase{x0: g.X, x1: g.X + g.W, text: g.S})
+			continue
+		}
+		cur := &line[len(line)-1]
+		if g.X-cur.x1 > size*0.2 {
+			cur.text += " "
+		}
+		cur.text += g.S
+		cur.x1 = g.X + g.W
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	for _, l := range lines {
+		for i := range l {
+			l[i].text = strings.TrimSpace(l[i].text)
+		}
+	}
+	return lines
+}
+
+// nearestColumn returns the header phrase horizontally closest to ph;
+// overlapping counts as distance zero
+func nearestColumn(header []phrase, ph phrase) int {
+	best, bestDist := 0, math.Inf(1)
+	for i, h := range header {
+		dist := 0.0
+		switch {
+		case ph.x1 < h.x0:
+			dist = h.x0 - ph.x1
+		case ph.x0 > h.x1:
+			dist = ph.x0 - h.x1
+		}
+		if dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
@@ -0,0 +1,262 @@
+// Package statements reads the money received in M-Pesa statements (CSV or
+// PDF exports) and bank statements (CSV or XLSX) so it can be reconciled
+// against recorded payments.
+package statements
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+)
+
+// Statement sources
+const (
+	SourceMpesa = "MPESA"
+	SourceBank  = "BANK"
+)
+
+var (
+	ErrUnsupportedFile  = errors.New("upload an M-Pesa statement as CSV or PDF, or a bank statement as CSV or XLSX")
+	ErrPasswordRequired = errors.New("the PDF is password protected; send its password")
+	ErrNoTransactions   = errors.New("no transaction table found; the statement needs a date column and a paid in, credit or amount column")
+)
+
+// Transaction is money received according to a statement. Reference is the
+// M-Pesa receipt or bank reference; lines without one get a reference
+// derived from their contents so re-uploads are recognised.
+type Transaction struct {
+	Line      int // row of the file, or of the PDF table across pages
+	Reference string
+	Time      time.Time
+	Amount    float64
+	Phone     string // payer's number as printed, possibly masked as 2547******78
+	Payer     string
+	Details   string
+}
+
+type Statement struct {
+	Source       string
+	Transactions []Transaction
+}
+
+// Parse reads a statement in format (documents.FormatCSV, FormatXLSX or
+// FormatPDF). Withdrawals, failed transactions and rows that are not
+// transactions are left out. Times without a zone are read in loc.
+func Parse(data []byte, format, password string, loc *time.Location) (Statement, error) {
+	var rows [][]string
+	var err error
+	switch format {
+	case documents.FormatCSV, documents.FormatXLSX:
+		rows, err = documents.ReadSpreadsheet(data, format)
+	case documents.FormatPDF:
+		rows, err = pdfTable(data, password)
+	default:
+		return Statement{}, ErrUnsupportedFile
+	}
+	if err != nil {
+		return Statement{}, err
+	}
+	return parseTable(rows, loc)
+}
+
+// Column roles, and the headers statements name them with. Headers are
+// compared lower case without punctuation.
+const (
+	colReference = "reference"
+	colTime      = "time"
+	colDetails   = "details"
+	colCredit    = "credit"
+	colDebit     = "debit"
+	colAmount    = "amount"
+	colStatus    = "status"
+	colParty     = "party"
+)
+
+var columnHeaders = map[string][]string{
+	colReference: {"receipt no", "receipt", "receipt number", "transaction id", "transaction ref", "transaction reference",
+		"reference", "reference no", "reference number", "ref", "ref no", "bank reference", "cheque no"},
+	colTime: {"completion time", "transaction date", "date", "value date", "posting date", "post date", "txn date",
+		"trans date", "booking date", "date time", "transaction time"},
+	colDetails: {"details", "description", "narration", "narrative", "particulars", "transaction details", "remarks"},
+	colCredit:  {"paid in", "credit", "credits", "credit amount", "money in", "deposit", "deposits", "cr", "amount in"},
+	colDebit:   {"withdrawn", "withdrawal", "withdrawals", "debit", "debits", "debit amount", "money out", "dr", "amount out"},
+	colAmount:  {"amount", "transaction amount"},
+	colStatus:  {"transaction status", "status"},
+	colParty:   {"other party info", "other party", "counterparty", "payer", "sender", "customer name"},
+}
+
+// headerRows is how far down a statement its table header may start
+const headerRows = 40
+
+var headerPunctuation = strings.NewReplacer(".", " ", "/", " ", "_", " ", "-", " ", ":", " ", "(", " ", ")", " ")
+
+func headerKey(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(headerPunctuation.Replace(s))), " ")
+}
+
+// findColumns maps column roles to indexes when row is a transaction table
+// header
+func findColumns(row []string) (map[string]int, bool) {
+	cols := map[string]int{}
+	for i, cell := range row {
+		key := headerKey(cell)
+		for role, names := range columnHeaders {
+			if _, seen := cols[role]; seen {
+				continue
+			}
+			for _, n := range names {
+				if key == n {
+					cols[role] = i
+				}
+			}
+		}
+	}
+	_, hasTime := cols[colTime]
+	_, hasCredit := cols[colCredit]
+	_, hasAmount := cols[colAmount]
+	return cols, hasTime && (hasCredit || hasAmount)
+}
+
+func parseTable(rows [][]string, loc *time.Location) (Statement, error) {
+	header := -1
+	var cols map[string]int
+	for i := 0; i < len(rows) && i < headerRows; i++ {
+		if c, ok := findColumns(rows[i]); ok {
+			header, cols = i, c
+			break
+		}
+	}
+	if header < 0 {
+		return Statement{}, ErrNoTransactions
+	}
+
+	st := Statement{Source: SourceBank}
+	if _, ok := cols[colCredit]; ok && headerKey(rows[header][cols[colCredit]]) == "paid in" {
+		st.Source = SourceMpesa
+	}
+	st.Transactions = []Transaction{}
+	derived := map[string]int{}
+
+	for i := header + 1; i < len(rows); i++ {
+		row := rows[i]
+		cell := func(role string) string {
+			if c, ok := cols[role]; ok && c < len(row) {
+				return strings.TrimSpace(row[c])
+			}
+			return ""
+		}
+		// Pages of a PDF repeat the header
+		if _, ok := findColumns(row); ok {
+			continue
+		}
+		if status := strings.ToLower(cell(colStatus)); status != "" && status != "completed" && status != "success" && status != "successful" {
+			continue
+		}
+		at, ok := parseTime(cell(colTime), loc)
+		if !ok {
+			continue
+		}
+		amount, ok := parseAmount(cell(colCredit))
+		if _, hasCredit := cols[colCredit]; !hasCredit {
+			amount, ok = parseAmount(cell(colAmount))
+		}
+		if !ok || amount <= 0 {
+			continue // withdrawals, charges and blank lines
+		}
+
+		t := Transaction{Line: i + 1, Reference: cell(colReference), Time: at, Amount: amount, Details: cell(colDetails)}
+		t.Phone, t.Payer = payer(cell(colParty))
+		if t.Phone == "" {
+			t.Phone, t.Payer = payer(t.Details)
+		}
+		if t.Reference == "" {
+			sum := sha1.Sum([]byte(fmt.Sprintf("%s|%.2f|%s", at.Format(time.RFC3339), amount, t.Details)))
+			t.Reference = "STMT-" + strings.ToUpper(hex.EncodeToString(sum[:6]))
+			// Identical lines, such as two equal deposits on a day, are told
+			// apart by their order
+			if derived[t.Reference]++; derived[t.Reference] > 1 {
+				t.Reference += fmt.Sprintf("-%d", derived[t.Reference])
+			}
+		}
+		st.Transactions = append(st.Transactions, t)
+	}
+	return st, nil
+}
+
+// timeLayouts are the date formats seen in Kenyan statements; day before
+// month wherever it is ambiguous
+var timeLayouts = []string{
+	"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02",
+	"02/01/2006 15:04:05", "02/01/2006 15:04", "02/01/2006", "2/1/2006",
+	"02-01-2006 15:04:05", "02-01-2006", "02.01.2006",
+	"02 Jan 2006 15:04", "02 Jan 2006", "2 Jan 2006", "02-Jan-2006", "02-Jan-06", "02 January 2006",
+	"02/01/06",
+}
+
+func parseTime(v string, loc *time.Location) (time.Time, bool) {
+	v = strings.Join(strings.Fields(v), " ")
+	if v == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, true
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, v, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseAmount reads amounts such as "1,500.00", "KES 1500", "(200.00)" or
+// "1,500.00 CR"
+func parseAmount(v string) (float64, bool) {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	negative := false
+	if strings.HasPrefix(v, "(") && strings.HasSuffix(v, ")") {
+		negative, v = true, strings.Trim(v, "()")
+	}
+	if strings.HasSuffix(v, "DR") {
+		negative = true
+	}
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "CR"), "DR")
+	for _, prefix := range []string{"KES", "KSHS", "KSH"} {
+		v = strings.TrimPrefix(v, prefix)
+	}
+	v = strings.NewReplacer(",", "", " ", "").Replace(v)
+	if v == "" || v == "-" {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, false
+	}
+	if negative {
+		f = -f
+	}
+	return f, true
+}
+
+// phonePattern finds Kenyan mobile numbers, including the masked ones on
+// newer M-Pesa statements (2547******78, 0712***678)
+var phonePattern = regexp.MustCompile(`(?:\+?254|\b0)[17][0-9*]{8}\b`)
+
+// payer splits text such as "254712345678 - JANE DOE" into the number and
+// the name after it
+func payer(text string) (string, string) {
+	loc := phonePattern.FindStringIndex(text)
+	if loc == nil {
+		return "", ""
+	}
+	phone := strings.TrimPrefix(text[loc[0]:loc[1]], "+")
+	name := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(text[loc[1]:]), "-:"))
+	return phone, name
+}
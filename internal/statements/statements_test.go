@@ -0,0 +1,141 @@
+package statements
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zolet-hash/smart-rentals/internal/documents"
+)
+
+var nairobi = time.FixedZone("EAT", 3*60*60)
+
+func TestParseMpesaCSV(t *testing.T) {
+	csv := strings.Join([]string{
+		"MPESA FULL STATEMENT",
+		"Customer Name,Kamau Flats",
+		"",
+		"Receipt No.,Completion Time,Details,Transaction Status,Paid In,Withdrawn,Balance",
+		"SJ12ABC,2026-10-05 10:30:00,Funds received from - 254712345678 JANE DOE,Completed,\"15,000.00\",,\"15,000.00\"",
+		"SJ12ABD,2026-10-05 11:00:00,Pay Bill Charge,Completed,,-30.00,\"14,970.00\"",
+		"SJ12ABE,2026-10-06 09:00:00,Funds received from - 2547******78 JOHN OTIENO,Failed,\"5,000.00\",,",
+		"Receipt No.,Completion Time,Details,Transaction Status,Paid In,Withdrawn,Balance",
+		"SJ12ABF,06/10/2026 12:15,Funds received from - 2547******78 JOHN OTIENO,Completed,\"5,000.00\",,\"19,970.00\"",
+	}, "\n")
+	st, err := Parse([]byte(csv), documents.FormatCSV, "", nairobi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Source != SourceMpesa {
+		t.Errorf("source %s, want %s", st.Source, SourceMpesa)
+	}
+	want := []Transaction{
+		{Line: 5, Reference: "SJ12ABC", Time: time.Date(2026, 10, 5, 10, 30, 0, 0, nairobi), Amount: 15000, Phone: "254712345678", Payer: "JANE DOE"},
+		{Line: 9, Reference: "SJ12ABF", Time: time.Date(2026, 10, 6, 12, 15, 0, 0, nairobi), Amount: 5000, Phone: "2547******78", Payer: "JOHN OTIENO"},
+	}
+	if len(st.Transactions) != len(want) {
+		t.Fatalf("got %d transactions, want %d: %+v", len(st.Transactions), len(want), st.Transactions)
+	}
+	for i, w := range want {
+		g := st.Transactions[i]
+		if g.Line != w.Line || g.Reference != w.Reference || !g.Time.Equal(w.Time) || g.Amount != w.Amount || g.Phone != w.Phone || g.Payer != w.Payer {
+			t.Errorf("transaction %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestParseBankCSVDerivesReferences(t *testing.T) {
+	csv := strings.Join([]string{
+		"Date,Narration,Credit,Debit",
+		"01/10/2026,CASH DEPOSIT NAIROBI BRANCH,15000,",
+		"01/10/2026,CASH DEPOSIT NAIROBI BRANCH,15000,",
+		"02/10/2026,ATM WITHDRAWAL,,2000",
+	}, "\n")
+	st, err := Parse([]byte(csv), documents.FormatCSV, "", nairobi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Source != SourceBank || len(st.Transactions) != 2 {
+		t.Fatalf("source %s with %d transactions", st.Source, len(st.Transactions))
+	}
+	first, second := st.Transactions[0].Reference, st.Transactions[1].Reference
+	if !strings.HasPrefix(first, "STMT-") || second != first+"-2" {
+		t.Errorf("references %q and %q; want a derived reference and the same with -2", first, second)
+	}
+
+	// Uploading the same statement again derives the same references
+	again, err := Parse([]byte(csv), documents.FormatCSV, "", nairobi)
+	if err != nil || again.Transactions[0].Reference != first || again.Transactions[1].Reference != second {
+		t.Errorf("re-upload references differ: %+v (%v)", again.Transactions, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	if _, err := Parse([]byte("Name,Phone\nJane,0712345678\n"), documents.FormatCSV, "", nairobi); !errors.Is(err, ErrNoTransactions) {
+		t.Errorf("no table: err = %v", err)
+	}
+	if _, err := Parse([]byte("{}"), "json", "", nairobi); !errors.Is(err, ErrUnsupportedFile) {
+		t.Errorf("unsupported format: err = %v", err)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"1,500.00", 1500, true},
+		{"KES 1500", 1500, true},
+		{"Kshs 1,500", 1500, true},
+		{"(200.00)", -200, true},
+		{"1,500.00 CR", 1500, true},
+		{"1,500.00 DR", -1500, true},
+		{"-30.00", -30, true},
+		{"", 0, false},
+		{"-", 0, false},
+		{"n/a", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := parseAmount(tt.in); got != tt.want || ok != tt.ok {
+			t.Errorf("parseAmount(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	want := time.Date(2026, time.October, 2, 0, 0, 0, 0, nairobi)
+	// Day before month wherever it is ambiguous
+	for _, v := range []string{"2026-10-02", "02/10/2026", "2/10/2026", "02-10-2026", "02.10.2026", "02 Oct 2026", "02-Oct-26", "02  October   2026"} {
+		if got, ok := parseTime(v, nairobi); !ok || !got.Equal(want) {
+			t.Errorf("parseTime(%q) = %v, %v; want %v", v, got, ok, want)
+		}
+	}
+	if got, ok := parseTime("2026-10-02T09:00:00Z", nairobi); !ok || !got.Equal(time.Date(2026, 10, 2, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("RFC 3339 time read as %v", got)
+	}
+	for _, v := range []string{"", "yesterday", "13/13/2026"} {
+		if _, ok := parseTime(v, nairobi); ok {
+			t.Errorf("parseTime(%q) accepted", v)
+		}
+	}
+}
+
+func TestPayer(t *testing.T) {
+	tests := []struct {
+		text, phone, name string
+	}{
+		{"254712345678 - JANE DOE", "254712345678", "JANE DOE"},
+		{"Funds received from - +254712345678 JANE DOE", "254712345678", "JANE DOE"},
+		{"0712***678: JOHN", "0712***678", "JOHN"},
+		{"2547******78 JOHN OTIENO", "2547******78", "JOHN OTIENO"},
+		{"CASH DEPOSIT NAIROBI BRANCH", "", ""},
+		{"ACCOUNT 10712345678", "", ""},
+	}
+	for _, tt := range tests {
+		if phone, name := payer(tt.text); phone != tt.phone || name != tt.name {
+			t.Errorf("payer(%q) = %q, %q; want %q, %q", tt.text, phone, name, tt.phone, tt.name)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+-- Track a per-user token version so that password changes revoke every
+-- previously issued JWT (tokens carry the version in their "ver" claim)
+ALTER TABLE users
+ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0;
+
+-- Single-use, time-limited password reset tokens. Only the SHA-256 hash of
+-- the token is stored; the plain token is sent to the user once.
+CREATE TABLE password_reset_tokens (
+    id              BIGSERIAL PRIMARY KEY,
+    user_id         INTEGER NOT NULL,
+    token_hash      VARCHAR(64) NOT NULL UNIQUE,
+    channel         VARCHAR(20) NOT NULL DEFAULT 'email',
+    expires_at      TIMESTAMPTZ NOT NULL,
+    used_at         TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_password_reset_tokens_user
+        FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_password_reset_tokens_user ON password_reset_tokens(user_id);
+
+COMMENT ON TABLE password_reset_tokens IS 'Self-service password reset tokens (hashed, single use)';
+COMMENT ON COLUMN password_reset_tokens.used_at IS 'Set when the token is consumed or superseded by a newer request';
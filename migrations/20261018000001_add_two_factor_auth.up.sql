@@ -0,0 +1,34 @@
+-- TOTP two-factor authentication (RFC 6238)
+ALTER TABLE users
+ADD COLUMN totp_secret TEXT,                            -- AES-GCM encrypted, set during enrollment
+ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN totp_enabled_at TIMESTAMPTZ,
+ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;    -- last accepted time step, prevents code replay
+
+-- Single-use recovery codes for when the authenticator device is lost
+CREATE TABLE user_recovery_codes (
+    id              BIGSERIAL PRIMARY KEY,
+    user_id         INTEGER NOT NULL,
+    code_hash       VARCHAR(64) NOT NULL,
+    used_at         TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_user_recovery_codes_user
+        FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_user_recovery_codes_user ON user_recovery_codes(user_id);
+
+-- Per-role policy controlling whether 2FA is mandatory
+CREATE TABLE mfa_policies (
+    role            VARCHAR(50) PRIMARY KEY,
+    required        BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_by      INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO mfa_policies (role, required) VALUES ('admin', FALSE), ('landlord', FALSE);
+
+COMMENT ON TABLE mfa_policies IS 'Roles for which two-factor authentication is mandatory';
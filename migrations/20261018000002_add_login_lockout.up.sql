@@ -0,0 +1,22 @@
+-- Progressive account lockout after repeated failed logins
+ALTER TABLE users
+ADD COLUMN failed_login_attempts INTEGER NOT NULL DEFAULT 0,  -- failures since last lockout/success
+ADD COLUMN lockout_count INTEGER NOT NULL DEFAULT 0,          -- consecutive lockouts, doubles the next lock duration
+ADD COLUMN locked_until TIMESTAMPTZ,
+ADD COLUMN last_failed_login_at TIMESTAMPTZ;
+
+-- Record of login attempts (password and second factor) for investigation
+CREATE TABLE login_attempts (
+    id              BIGSERIAL PRIMARY KEY,
+    user_id         INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    email           VARCHAR(255) NOT NULL,
+    ip_address      VARCHAR(64),
+    success         BOOLEAN NOT NULL,
+    reason          VARCHAR(50),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_login_attempts_email ON login_attempts(email, created_at DESC);
+CREATE INDEX idx_login_attempts_ip ON login_attempts(ip_address, created_at DESC);
+
+COMMENT ON COLUMN users.locked_until IS 'Login is refused until this time; cleared by admin unlock';
@@ -0,0 +1,57 @@
+-- Landlords delegate access to specific properties to staff accounts
+-- (caretaker, agent, accountant). The grantee's role caps what they can do;
+-- the delegation narrows it down to the listed permissions on one property.
+CREATE TABLE property_delegations (
+    id              BIGSERIAL PRIMARY KEY,
+    landlord_id     INTEGER NOT NULL,
+    grantee_id      INTEGER NOT NULL,
+    property_id     INTEGER NOT NULL,
+    permissions     TEXT[] NOT NULL DEFAULT '{}',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_property_delegations_landlord
+        FOREIGN KEY (landlord_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_property_delegations_grantee
+        FOREIGN KEY (grantee_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_property_delegations_property
+        FOREIGN KEY (property_id)
+        REFERENCES properties (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT uq_property_delegations_grantee_property
+        UNIQUE (grantee_id, property_id)
+);
+
+CREATE INDEX idx_property_delegations_landlord ON property_delegations(landlord_id);
+
+-- Properties a user may act on with a given permission: their own, plus any
+-- delegated to them with that permission. Handlers scope queries with
+--   property_id IN (SELECT accessible_property_ids($user, 'tenants:read'))
+CREATE OR REPLACE FUNCTION accessible_property_ids(p_user INTEGER, p_perm TEXT)
+RETURNS SETOF INTEGER AS $$
+    SELECT id FROM properties WHERE landlord_id = p_user
+    UNION
+    SELECT property_id FROM property_delegations
+    WHERE grantee_id = p_user AND p_perm = ANY(permissions)
+$$ LANGUAGE sql STABLE;
+
+-- Landlords whose account-level records (e.g. unassigned payments) a user may
+-- see with a given permission: themselves plus the owners of accessible properties
+CREATE OR REPLACE FUNCTION accessible_landlord_ids(p_user INTEGER, p_perm TEXT)
+RETURNS SETOF INTEGER AS $$
+    SELECT p_user
+    UNION
+    SELECT landlord_id FROM properties
+    WHERE id IN (SELECT accessible_property_ids(p_user, p_perm))
+$$ LANGUAGE sql STABLE;
+
+-- Who recorded a payment by hand (cash payments, manual assignment)
+ALTER TABLE payments
+ADD COLUMN recorded_by INTEGER REFERENCES users (id) ON DELETE SET NULL;
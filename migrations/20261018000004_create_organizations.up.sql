@@ -0,0 +1,55 @@
+-- Property management companies. Properties stay owned by their owner
+-- (properties.landlord_id) but can be managed by an organization whose
+-- members then act on them according to their member permissions.
+CREATE TABLE organizations (
+    id                      SERIAL PRIMARY KEY,
+    name                    VARCHAR(255) NOT NULL,
+    management_fee_percent  NUMERIC(5,2) NOT NULL DEFAULT 0
+        CHECK (management_fee_percent >= 0 AND management_fee_percent <= 100),
+    created_by              INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at              TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- role is the member's role within the organization (admin, manager, staff, owner);
+-- permissions are resolved from it when the member is added or updated
+CREATE TABLE organization_members (
+    organization_id INTEGER NOT NULL,
+    user_id         INTEGER NOT NULL,
+    role            VARCHAR(50) NOT NULL,
+    permissions     TEXT[] NOT NULL DEFAULT '{}',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (organization_id, user_id),
+
+    CONSTRAINT fk_organization_members_organization
+        FOREIGN KEY (organization_id)
+        REFERENCES organizations (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_organization_members_user
+        FOREIGN KEY (user_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_organization_members_user ON organization_members(user_id);
+
+ALTER TABLE properties
+ADD COLUMN organization_id INTEGER REFERENCES organizations (id) ON DELETE SET NULL;
+
+CREATE INDEX idx_properties_organization ON properties(organization_id);
+
+-- Extend property access with organization membership
+CREATE OR REPLACE FUNCTION accessible_property_ids(p_user INTEGER, p_perm TEXT)
+RETURNS SETOF INTEGER AS $$
+    SELECT id FROM properties WHERE landlord_id = p_user
+    UNION
+    SELECT property_id FROM property_delegations
+    WHERE grantee_id = p_user AND p_perm = ANY(permissions)
+    UNION
+    SELECT p.id FROM properties p
+    JOIN organization_members m ON m.organization_id = p.organization_id
+    WHERE m.user_id = p_user AND p_perm = ANY(m.permissions)
+$$ LANGUAGE sql STABLE;
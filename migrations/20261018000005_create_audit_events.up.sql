@@ -0,0 +1,40 @@
+-- Append-only audit trail of state-changing actions. Each row carries the
+-- hash of the previous row so any edit or removal breaks the chain.
+-- actor_id and landlord_id deliberately have no foreign keys: audit rows must
+-- survive (and never be rewritten by) user deletion.
+CREATE TABLE audit_events (
+    id              BIGSERIAL PRIMARY KEY,
+    occurred_at     TIMESTAMPTZ NOT NULL,
+    actor_id        INTEGER,
+    actor_role      VARCHAR(50),
+    request_id      VARCHAR(64),
+    action          VARCHAR(100) NOT NULL,
+    entity_type     VARCHAR(50) NOT NULL,
+    entity_id       VARCHAR(100),
+    landlord_id     INTEGER, -- landlord whose data was affected, for landlord-scoped views
+    before_data     JSONB,
+    after_data      JSONB,
+    ip_address      VARCHAR(64),
+    prev_hash       CHAR(64) NOT NULL,
+    hash            CHAR(64) NOT NULL UNIQUE
+);
+
+CREATE INDEX idx_audit_events_occurred_at ON audit_events(occurred_at);
+CREATE INDEX idx_audit_events_actor ON audit_events(actor_id, occurred_at);
+CREATE INDEX idx_audit_events_landlord ON audit_events(landlord_id, occurred_at);
+CREATE INDEX idx_audit_events_entity ON audit_events(entity_type, entity_id);
+
+CREATE OR REPLACE FUNCTION audit_events_append_only()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_audit_events_no_update
+BEFORE UPDATE OR DELETE ON audit_events
+FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER trg_audit_events_no_truncate
+BEFORE TRUNCATE ON audit_events
+FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,47 @@
+-- Landlord-registered webhook endpoints. secret is encrypted with the
+-- application key, like M-Pesa credentials, and used to sign payloads.
+CREATE TABLE webhook_endpoints (
+    id              SERIAL PRIMARY KEY,
+    landlord_id     INTEGER NOT NULL,
+    url             TEXT NOT NULL,
+    description     VARCHAR(255),
+    secret          TEXT NOT NULL,
+    events          TEXT[] NOT NULL DEFAULT '{}',
+    active          BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_webhook_endpoints_landlord
+        FOREIGN KEY (landlord_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_endpoints_landlord ON webhook_endpoints(landlord_id);
+
+-- One row per event per endpoint; doubles as the delivery log.
+-- status: pending (waiting for next_attempt_at), succeeded, failed (gave up)
+CREATE TABLE webhook_deliveries (
+    id                  BIGSERIAL PRIMARY KEY,
+    endpoint_id         INTEGER NOT NULL,
+    event_id            VARCHAR(64) NOT NULL,
+    event_type          VARCHAR(50) NOT NULL,
+    payload             JSONB NOT NULL,
+    status              VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts            INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_attempt_at     TIMESTAMPTZ,
+    last_status_code    INTEGER,
+    last_error          TEXT,
+    last_response       TEXT,
+    delivered_at        TIMESTAMPTZ,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_webhook_deliveries_endpoint
+        FOREIGN KEY (endpoint_id)
+        REFERENCES webhook_endpoints (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_endpoint ON webhook_deliveries(endpoint_id, created_at DESC);
@@ -0,0 +1,58 @@
+-- Day of the month rent falls due (capped at 28 so it exists in every month)
+-- and the tenant's choice to stop receiving SMS
+ALTER TABLE tenants
+ADD COLUMN rent_due_day SMALLINT NOT NULL DEFAULT 1 CHECK (rent_due_day BETWEEN 1 AND 28),
+ADD COLUMN sms_opt_out BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Per-landlord notification settings. Landlords without a row get the defaults.
+CREATE TABLE notification_settings (
+    landlord_id             INTEGER PRIMARY KEY,
+    sms_sender_id           VARCHAR(11),
+    payment_receipts        BOOLEAN NOT NULL DEFAULT TRUE,
+    balance_reminders       BOOLEAN NOT NULL DEFAULT TRUE,
+    overdue_notices         BOOLEAN NOT NULL DEFAULT TRUE,
+    reminder_days_before    SMALLINT NOT NULL DEFAULT 3 CHECK (reminder_days_before BETWEEN 1 AND 14),
+    updated_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_notification_settings_landlord
+        FOREIGN KEY (landlord_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE
+);
+
+-- Outbound notifications and their delivery status. Rows are queued and sent
+-- by the dispatcher; reference deduplicates scheduled messages
+-- (e.g. one reminder per tenant per due date).
+-- status: queued, sent, failed (gave up), skipped (opted out or disabled)
+CREATE TABLE notifications (
+    id                  BIGSERIAL PRIMARY KEY,
+    landlord_id         INTEGER NOT NULL,
+    tenant_id           INTEGER,
+    channel             VARCHAR(20) NOT NULL,
+    template            VARCHAR(50) NOT NULL,
+    recipient           VARCHAR(255) NOT NULL,
+    sender              VARCHAR(20),
+    body                TEXT NOT NULL,
+    reference           VARCHAR(100),
+    status              VARCHAR(20) NOT NULL DEFAULT 'queued',
+    attempts            INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error          TEXT,
+    sent_at             TIMESTAMPTZ,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_notifications_landlord
+        FOREIGN KEY (landlord_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_notifications_tenant
+        FOREIGN KEY (tenant_id)
+        REFERENCES tenants (id)
+        ON DELETE SET NULL
+);
+
+CREATE UNIQUE INDEX idx_notifications_reference ON notifications(channel, reference) WHERE reference IS NOT NULL;
+CREATE INDEX idx_notifications_due ON notifications(next_attempt_at) WHERE status = 'queued';
+CREATE INDEX idx_notifications_landlord ON notifications(landlord_id, created_at DESC);
+CREATE INDEX idx_notifications_tenant ON notifications(tenant_id);
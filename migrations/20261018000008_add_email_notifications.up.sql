@@ -0,0 +1,32 @@
+-- Per-user email preferences (see models.NotificationPreferences)
+ALTER TABLE users
+ADD COLUMN notification_preferences JSONB NOT NULL
+    DEFAULT '{"email_payment_receipts": true, "email_monthly_statements": true}';
+
+-- Optional tenant email for receipts and statements
+ALTER TABLE tenants
+ADD COLUMN email VARCHAR(255),
+ADD COLUMN email_opt_out BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Email notifications share the notifications queue. user_id is set when
+-- the recipient is a user (e.g. the landlord) rather than a tenant.
+ALTER TABLE notifications
+ADD COLUMN user_id INTEGER REFERENCES users (id) ON DELETE SET NULL,
+ADD COLUMN subject VARCHAR(255),
+ADD COLUMN html_body TEXT;
+
+-- Files attached to an email notification (PDF receipts and statements)
+CREATE TABLE notification_attachments (
+    id                  BIGSERIAL PRIMARY KEY,
+    notification_id     BIGINT NOT NULL,
+    filename            VARCHAR(255) NOT NULL,
+    content_type        VARCHAR(100) NOT NULL,
+    data                BYTEA NOT NULL,
+
+    CONSTRAINT fk_notification_attachments_notification
+        FOREIGN KEY (notification_id)
+        REFERENCES notifications (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notification_attachments_notification ON notification_attachments(notification_id);
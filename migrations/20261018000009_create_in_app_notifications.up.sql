@@ -0,0 +1,14 @@
+-- In-app notifications share the notifications table with channel 'in_app'.
+-- They are stored as sent and have no recipient address; read_at tracks
+-- whether the user has seen them.
+ALTER TABLE notifications
+ALTER COLUMN recipient DROP NOT NULL,
+ADD COLUMN read_at TIMESTAMPTZ;
+
+-- Links a tenant record to a user account with the tenant role, so the
+-- tenant can read notifications addressed to them
+ALTER TABLE tenants
+ADD COLUMN user_id INTEGER UNIQUE REFERENCES users (id) ON DELETE SET NULL;
+
+CREATE INDEX idx_notifications_inbox_user ON notifications(user_id, id DESC) WHERE channel = 'in_app';
+CREATE INDEX idx_notifications_inbox_tenant ON notifications(tenant_id, id DESC) WHERE channel = 'in_app' AND user_id IS NULL;
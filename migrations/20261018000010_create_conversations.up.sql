@@ -0,0 +1,60 @@
+-- Threaded conversations between a landlord's staff and one tenant
+CREATE TABLE conversations (
+    id                  BIGSERIAL PRIMARY KEY,
+    landlord_id         INTEGER NOT NULL,
+    tenant_id           INTEGER NOT NULL,
+    subject             VARCHAR(255) NOT NULL,
+    created_by          INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    last_message_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_conversations_landlord
+        FOREIGN KEY (landlord_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_conversations_tenant
+        FOREIGN KEY (tenant_id)
+        REFERENCES tenants (id)
+        ON DELETE CASCADE
+);
+
+-- Property-wide announcements. Each tenant receives the broadcast as a
+-- message in a conversation of their own, so replies stay private.
+CREATE TABLE message_broadcasts (
+    id                  BIGSERIAL PRIMARY KEY,
+    landlord_id         INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    property_id         INTEGER NOT NULL REFERENCES properties (id) ON DELETE CASCADE,
+    subject             VARCHAR(255) NOT NULL,
+    content             TEXT NOT NULL,
+    recipients          INTEGER NOT NULL DEFAULT 0,
+    created_by          INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- read_at is set when the other side (tenant or staff) reads the message
+CREATE TABLE messages (
+    id                  BIGSERIAL PRIMARY KEY,
+    conversation_id     BIGINT NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+    sender_type         VARCHAR(10) NOT NULL CHECK (sender_type IN ('staff', 'tenant')),
+    sender_id           INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    content             TEXT NOT NULL,
+    broadcast_id        BIGINT REFERENCES message_broadcasts (id) ON DELETE SET NULL,
+    read_at             TIMESTAMPTZ,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE message_attachments (
+    id                  BIGSERIAL PRIMARY KEY,
+    message_id          BIGINT NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+    filename            VARCHAR(255) NOT NULL,
+    content_type        VARCHAR(100) NOT NULL,
+    size                INTEGER NOT NULL,
+    data                BYTEA NOT NULL
+);
+
+CREATE INDEX idx_conversations_tenant ON conversations(tenant_id, last_message_at DESC);
+CREATE INDEX idx_conversations_landlord ON conversations(landlord_id, last_message_at DESC);
+CREATE INDEX idx_messages_conversation ON messages(conversation_id, id DESC);
+CREATE INDEX idx_messages_unread ON messages(conversation_id, sender_type) WHERE read_at IS NULL;
+CREATE INDEX idx_message_attachments_message ON message_attachments(message_id);
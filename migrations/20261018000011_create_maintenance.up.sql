@@ -0,0 +1,97 @@
+-- Contractors a landlord sends repair work to
+CREATE TABLE vendors (
+    id                  BIGSERIAL PRIMARY KEY,
+    landlord_id         INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name                VARCHAR(255) NOT NULL,
+    phone               VARCHAR(50),
+    email               VARCHAR(255),
+    trade               VARCHAR(100),
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Repair requests for a unit, reported by its tenant or by staff.
+-- due_at is the SLA deadline derived from the priority.
+CREATE TABLE maintenance_tickets (
+    id                  BIGSERIAL PRIMARY KEY,
+    landlord_id         INTEGER NOT NULL,
+    unit_id             INTEGER NOT NULL,
+    tenant_id           INTEGER REFERENCES tenants (id) ON DELETE SET NULL,
+    title               VARCHAR(255) NOT NULL,
+    description         TEXT NOT NULL DEFAULT '',
+    category            VARCHAR(30) NOT NULL
+                        CHECK (category IN ('plumbing', 'electrical', 'appliance', 'structural', 'pest_control', 'security', 'cleaning', 'other')),
+    priority            VARCHAR(10) NOT NULL DEFAULT 'normal'
+                        CHECK (priority IN ('low', 'normal', 'high', 'urgent')),
+    status              VARCHAR(20) NOT NULL DEFAULT 'open'
+                        CHECK (status IN ('open', 'assigned', 'in_progress', 'resolved')),
+    assignee_id         INTEGER REFERENCES users (id) ON DELETE SET NULL, -- caretaker or other staff
+    vendor_id           BIGINT REFERENCES vendors (id) ON DELETE SET NULL,
+    cost                NUMERIC(12, 2) CHECK (cost >= 0),
+    resolution          TEXT,
+    reported_by         INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    due_at              TIMESTAMPTZ NOT NULL,
+    assigned_at         TIMESTAMPTZ,
+    started_at          TIMESTAMPTZ,
+    resolved_at         TIMESTAMPTZ,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_maintenance_tickets_landlord
+        FOREIGN KEY (landlord_id)
+        REFERENCES users (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT fk_maintenance_tickets_unit
+        FOREIGN KEY (unit_id)
+        REFERENCES units (id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE maintenance_photos (
+    id                  BIGSERIAL PRIMARY KEY,
+    ticket_id           BIGINT NOT NULL REFERENCES maintenance_tickets (id) ON DELETE CASCADE,
+    filename            VARCHAR(255) NOT NULL,
+    content_type        VARCHAR(100) NOT NULL,
+    size                INTEGER NOT NULL,
+    data                BYTEA NOT NULL,
+    uploaded_by         INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Amounts added to a tenant's balance besides rent, such as repairs
+-- recharged from a maintenance ticket (at most one charge per ticket)
+CREATE TABLE tenant_charges (
+    id                  BIGSERIAL PRIMARY KEY,
+    landlord_id         INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    tenant_id           INTEGER NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    amount              NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    description         VARCHAR(255) NOT NULL,
+    ticket_id           BIGINT UNIQUE REFERENCES maintenance_tickets (id) ON DELETE SET NULL,
+    created_by          INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_vendors_landlord ON vendors(landlord_id);
+CREATE INDEX idx_maintenance_tickets_unit ON maintenance_tickets(unit_id, created_at DESC);
+CREATE INDEX idx_maintenance_tickets_tenant ON maintenance_tickets(tenant_id);
+CREATE INDEX idx_maintenance_tickets_assignee ON maintenance_tickets(assignee_id) WHERE status <> 'resolved';
+CREATE INDEX idx_maintenance_tickets_open_due ON maintenance_tickets(due_at) WHERE status <> 'resolved';
+CREATE INDEX idx_maintenance_photos_ticket ON maintenance_photos(ticket_id);
+CREATE INDEX idx_tenant_charges_tenant ON tenant_charges(tenant_id, created_at DESC);
+
+-- Existing organization members get the maintenance permissions of their
+-- org role, capped by their account role as when members are added
+UPDATE organization_members m
+SET permissions = m.permissions || ARRAY['maintenance:read', 'maintenance:write'], updated_at = NOW()
+FROM users u
+WHERE u.id = m.user_id AND m.role IN ('admin', 'manager', 'staff')
+  AND u.role IN ('landlord', 'caretaker', 'agent')
+  AND NOT 'maintenance:read' = ANY(m.permissions);
+
+UPDATE organization_members m
+SET permissions = m.permissions || ARRAY['maintenance:read'], updated_at = NOW()
+FROM users u
+WHERE u.id = m.user_id AND m.role IN ('admin', 'manager', 'staff')
+  AND u.role = 'accountant'
+  AND NOT 'maintenance:read' = ANY(m.permissions);
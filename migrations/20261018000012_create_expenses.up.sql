@@ -0,0 +1,68 @@
+-- Expenses that repeat on a schedule (service charges, insurance, salaries).
+-- The generator adds an expense for each occurrence up to today; next_date
+-- is the next occurrence, occurrences how many have been generated.
+CREATE TABLE recurring_expenses (
+    id                  BIGSERIAL PRIMARY KEY,
+    landlord_id         INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    property_id         INTEGER NOT NULL REFERENCES properties (id) ON DELETE CASCADE,
+    unit_id             INTEGER REFERENCES units (id) ON DELETE SET NULL,
+    vendor_id           BIGINT REFERENCES vendors (id) ON DELETE SET NULL,
+    category            VARCHAR(30) NOT NULL
+                        CHECK (category IN ('repairs', 'utilities', 'insurance', 'property_tax', 'management_fees',
+                                            'cleaning', 'security', 'salaries', 'supplies', 'legal', 'other')),
+    amount              NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    description         VARCHAR(255) NOT NULL,
+    frequency           VARCHAR(10) NOT NULL CHECK (frequency IN ('weekly', 'monthly', 'quarterly', 'yearly')),
+    start_date          DATE NOT NULL,
+    end_date            DATE,
+    next_date           DATE NOT NULL,
+    occurrences         INTEGER NOT NULL DEFAULT 0,
+    active              BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by          INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CHECK (end_date IS NULL OR end_date >= start_date)
+);
+
+-- Money spent on a property, optionally on one unit. The receipt (scan or
+-- photo) is stored with the expense.
+CREATE TABLE expenses (
+    id                      BIGSERIAL PRIMARY KEY,
+    landlord_id             INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    property_id             INTEGER NOT NULL REFERENCES properties (id) ON DELETE CASCADE,
+    unit_id                 INTEGER REFERENCES units (id) ON DELETE SET NULL,
+    vendor_id               BIGINT REFERENCES vendors (id) ON DELETE SET NULL,
+    category                VARCHAR(30) NOT NULL
+                            CHECK (category IN ('repairs', 'utilities', 'insurance', 'property_tax', 'management_fees',
+                                                'cleaning', 'security', 'salaries', 'supplies', 'legal', 'other')),
+    amount                  NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    description             VARCHAR(255) NOT NULL,
+    expense_date            DATE NOT NULL,
+    reference               VARCHAR(100), -- invoice or M-Pesa code
+    recurring_expense_id    BIGINT REFERENCES recurring_expenses (id) ON DELETE SET NULL,
+    receipt_filename        VARCHAR(255),
+    receipt_content_type    VARCHAR(100),
+    receipt_data            BYTEA,
+    created_by              INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT uq_expenses_recurring_date UNIQUE (recurring_expense_id, expense_date)
+);
+
+CREATE INDEX idx_expenses_property_date ON expenses(property_id, expense_date);
+CREATE INDEX idx_expenses_landlord_date ON expenses(landlord_id, expense_date);
+CREATE INDEX idx_recurring_expenses_due ON recurring_expenses(next_date) WHERE active;
+
+-- Profit and loss reports sum completed payments per property over a period
+CREATE INDEX IF NOT EXISTS idx_payments_tenant_created ON payments(tenant_id, created_at) WHERE status = 'COMPLETED';
+
+-- Existing organization members get the expense permissions of their org
+-- role, capped by their account role as when members are added
+UPDATE organization_members m
+SET permissions = m.permissions || ARRAY['expenses:read', 'expenses:write'], updated_at = NOW()
+FROM users u
+WHERE u.id = m.user_id AND m.role IN ('admin', 'manager', 'staff')
+  AND u.role IN ('landlord', 'caretaker', 'accountant')
+  AND NOT 'expenses:read' = ANY(m.permissions);
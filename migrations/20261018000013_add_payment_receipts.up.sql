@@ -0,0 +1,76 @@
+-- Verification codes are random; gen_random_bytes comes from pgcrypto
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+-- How a landlord's receipts and statements look. The receipt prefix starts
+-- each receipt number, e.g. RCT-000042.
+CREATE TABLE landlord_branding (
+    landlord_id         INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    business_name       VARCHAR(255),
+    address             VARCHAR(255),
+    phone               VARCHAR(50),
+    email               VARCHAR(255),
+    accent_color        CHAR(7) CHECK (accent_color ~ '^#[0-9A-Fa-f]{6}$'),
+    receipt_prefix      VARCHAR(10) NOT NULL DEFAULT 'RCT' CHECK (receipt_prefix ~ '^[A-Z0-9]{1,10}$'),
+    logo                BYTEA,
+    logo_content_type   VARCHAR(100),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Last receipt number issued per landlord
+CREATE TABLE receipt_sequences (
+    landlord_id     INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    last_number     BIGINT NOT NULL
+);
+
+-- Completed tenant payments get a receipt number, sequential per landlord,
+-- and a code for the public verification link printed on the receipt
+ALTER TABLE payments
+ADD COLUMN receipt_no VARCHAR(30),
+ADD COLUMN verification_code VARCHAR(32);
+
+CREATE UNIQUE INDEX uq_payments_landlord_receipt_no ON payments(landlord_id, receipt_no);
+CREATE UNIQUE INDEX uq_payments_verification_code ON payments(verification_code);
+
+-- Number existing receipts in the order they were paid
+WITH numbered AS (
+    SELECT id, landlord_id, ROW_NUMBER() OVER (PARTITION BY landlord_id ORDER BY created_at, id) AS n
+    FROM payments
+    WHERE status = 'COMPLETED' AND tenant_id IS NOT NULL
+)
+UPDATE payments p
+SET receipt_no = 'RCT-' || LPAD(numbered.n::TEXT, 6, '0'),
+    verification_code = encode(gen_random_bytes(16), 'hex')
+FROM numbered
+WHERE p.id = numbered.id;
+
+INSERT INTO receipt_sequences (landlord_id, last_number)
+SELECT landlord_id, COUNT(*) FROM payments WHERE receipt_no IS NOT NULL GROUP BY landlord_id;
+
+-- Payments are recorded from several places (cash, M-Pesa callbacks,
+-- matching), so the number is assigned here. The sequence row lock
+-- serializes a landlord's receipts and rolls back with the payment, so
+-- numbers have no gaps.
+CREATE OR REPLACE FUNCTION assign_receipt_number()
+RETURNS trigger AS $$
+DECLARE
+    n BIGINT;
+    prefix VARCHAR(10);
+BEGIN
+    IF NEW.status = 'COMPLETED' AND NEW.tenant_id IS NOT NULL AND NEW.receipt_no IS NULL THEN
+        INSERT INTO receipt_sequences (landlord_id, last_number)
+        VALUES (NEW.landlord_id, 1)
+        ON CONFLICT (landlord_id) DO UPDATE SET last_number = receipt_sequences.last_number + 1
+        RETURNING last_number INTO n;
+
+        SELECT b.receipt_prefix INTO prefix FROM landlord_branding b WHERE b.landlord_id = NEW.landlord_id;
+        NEW.receipt_no := COALESCE(prefix, 'RCT') || '-' || LPAD(n::TEXT, 6, '0');
+        NEW.verification_code := encode(gen_random_bytes(16), 'hex');
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_assign_receipt_number
+BEFORE INSERT OR UPDATE OF status, tenant_id ON payments
+FOR EACH ROW
+EXECUTE FUNCTION assign_receipt_number();
@@ -0,0 +1,21 @@
+-- Bulk uploads of units and tenants to a property. Row errors are kept
+-- with the job so the report can be downloaded later.
+CREATE TABLE import_jobs (
+    id                  BIGSERIAL PRIMARY KEY,
+    landlord_id         INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    property_id         INTEGER NOT NULL REFERENCES properties (id) ON DELETE CASCADE,
+    filename            VARCHAR(255) NOT NULL,
+    dry_run             BOOLEAN NOT NULL DEFAULT FALSE,
+    status              VARCHAR(20) NOT NULL
+                        CHECK (status IN ('VALIDATED', 'INVALID', 'COMPLETED', 'FAILED')),
+    total_rows          INTEGER NOT NULL DEFAULT 0,
+    units_created       INTEGER NOT NULL DEFAULT 0,
+    tenants_created     INTEGER NOT NULL DEFAULT 0,
+    error_count         INTEGER NOT NULL DEFAULT 0,
+    errors              JSONB NOT NULL DEFAULT '[]',
+    created_by          INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at        TIMESTAMPTZ
+);
+
+CREATE INDEX idx_import_jobs_property ON import_jobs(property_id, created_at DESC);
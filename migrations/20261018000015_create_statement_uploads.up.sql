@@ -0,0 +1,37 @@
+-- M-Pesa and bank statements uploaded for reconciliation. Each money-in line
+-- is kept with what became of it: a new payment matched to a tenant, a new
+-- pending payment awaiting manual assignment, or a payment already recorded.
+CREATE TABLE statement_uploads (
+    id                  BIGSERIAL PRIMARY KEY,
+    landlord_id         INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    filename            VARCHAR(255) NOT NULL,
+    source              VARCHAR(10) NOT NULL CHECK (source IN ('MPESA', 'BANK')),
+    total_lines         INTEGER NOT NULL DEFAULT 0,
+    matched             INTEGER NOT NULL DEFAULT 0,
+    unmatched           INTEGER NOT NULL DEFAULT 0,
+    duplicates          INTEGER NOT NULL DEFAULT 0,
+    total_amount        NUMERIC(12,2) NOT NULL DEFAULT 0,
+    uploaded_by         INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_statement_uploads_landlord ON statement_uploads(landlord_id, created_at DESC);
+
+CREATE TABLE statement_lines (
+    id                  BIGSERIAL PRIMARY KEY,
+    upload_id           BIGINT NOT NULL REFERENCES statement_uploads (id) ON DELETE CASCADE,
+    line_no             INTEGER NOT NULL,
+    reference           VARCHAR(255) NOT NULL,
+    transaction_at      TIMESTAMPTZ NOT NULL,
+    amount              NUMERIC(12,2) NOT NULL,
+    phone               VARCHAR(20),
+    payer               VARCHAR(255),
+    details             TEXT,
+    outcome             VARCHAR(20) NOT NULL CHECK (outcome IN ('MATCHED', 'UNMATCHED', 'DUPLICATE')),
+    payment_id          BIGINT REFERENCES payments (id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_statement_lines_upload ON statement_lines(upload_id, line_no);
+
+-- Statement lines are de-duplicated against the landlord's receipts
+CREATE INDEX IF NOT EXISTS idx_payments_landlord_receipt ON payments(landlord_id, receipt);
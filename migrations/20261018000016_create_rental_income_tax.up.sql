@@ -0,0 +1,56 @@
+-- Monthly Rental Income (MRI) tax is due on gross rent received, so payments
+-- say what they were for; deposits and service charges are not rent.
+ALTER TABLE payments
+ADD COLUMN category VARCHAR(20) NOT NULL DEFAULT 'rent'
+    CHECK (category IN ('rent', 'deposit', 'service_charge', 'utilities', 'other'));
+
+COMMENT ON COLUMN payments.category IS 'What the payment was for: rent, deposit, service_charge, utilities or other';
+
+-- MRI returns sum a landlord's completed rent payments per month
+CREATE INDEX idx_payments_landlord_rent ON payments(landlord_id, created_at)
+    WHERE status = 'COMPLETED' AND category = 'rent';
+
+-- Tax rates and the annual rent they apply between. A rate holds from its
+-- effective date until the next one; admins add rows as the law changes.
+CREATE TABLE tax_rates (
+    id                  SERIAL PRIMARY KEY,
+    tax                 VARCHAR(20) NOT NULL CHECK (tax IN ('MRI')),
+    rate_percent        NUMERIC(5, 2) NOT NULL CHECK (rate_percent >= 0 AND rate_percent <= 100),
+    min_annual_income   NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    max_annual_income   NUMERIC(14, 2),
+    effective_from      DATE NOT NULL,
+    notes               VARCHAR(255),
+    created_by          INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_tax_rates_effective UNIQUE (tax, effective_from),
+    CHECK (max_annual_income IS NULL OR max_annual_income > min_annual_income)
+);
+
+INSERT INTO tax_rates (tax, rate_percent, min_annual_income, max_annual_income, effective_from, notes) VALUES
+    ('MRI', 10.00, 144000, 10000000, '2016-01-01', 'Finance Act 2015'),
+    ('MRI', 7.50, 288000, 15000000, '2024-01-01', 'Finance Act 2023');
+
+-- The landlord's KRA PIN, needed to file with iTax
+CREATE TABLE landlord_tax_profiles (
+    landlord_id         INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    kra_pin             VARCHAR(11) NOT NULL,
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Filed MRI returns. The figures are those filed; the month's rent is
+-- recomputed on read so later changes show as an amendment.
+CREATE TABLE mri_returns (
+    id                  BIGSERIAL PRIMARY KEY,
+    landlord_id         INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    period              DATE NOT NULL, -- first day of the month
+    gross_rent          NUMERIC(14, 2) NOT NULL,
+    rate_percent        NUMERIC(5, 2) NOT NULL,
+    tax_due             NUMERIC(14, 2) NOT NULL,
+    prn                 VARCHAR(30) NOT NULL,
+    status              VARCHAR(10) NOT NULL CHECK (status IN ('FILED', 'PAID')),
+    filed_by            INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    filed_at            TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    paid_at             TIMESTAMPTZ,
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_mri_returns_period UNIQUE (landlord_id, period)
+);
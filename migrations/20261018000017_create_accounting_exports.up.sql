@@ -0,0 +1,37 @@
+-- Each landlord's chart of accounts for journal exports: account names (or
+-- codes, for Xero) keyed by what they hold, e.g. rental_income. Keys not set
+-- use the defaults in the accounting export service.
+CREATE TABLE accounting_settings (
+    landlord_id         INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    accounts            JSONB NOT NULL DEFAULT '{}',
+    xero_tax_rate       VARCHAR(50),
+    updated_by          INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- An export of journal entries to an accounting package. The entries are
+-- kept so the batch can be downloaded again in any format.
+CREATE TABLE accounting_export_batches (
+    id                  BIGSERIAL PRIMARY KEY,
+    landlord_id         INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    format              VARCHAR(20) NOT NULL,
+    from_date           DATE,
+    to_date             DATE NOT NULL,
+    entry_count         INTEGER NOT NULL,
+    total_amount        NUMERIC(14, 2) NOT NULL,
+    entries             JSONB NOT NULL,
+    created_by          INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_accounting_export_batches_landlord ON accounting_export_batches(landlord_id, created_at DESC);
+
+-- What has been exported, so no rent charge, payment, fee or expense is
+-- exported twice. source_key identifies the record within its source.
+CREATE TABLE accounting_exported_entries (
+    landlord_id         INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    source              VARCHAR(10) NOT NULL CHECK (source IN ('rent', 'fee', 'payment', 'expense')),
+    source_key          VARCHAR(50) NOT NULL,
+    batch_id            BIGINT NOT NULL REFERENCES accounting_export_batches (id) ON DELETE CASCADE,
+    PRIMARY KEY (landlord_id, source, source_key)
+);
@@ -0,0 +1,24 @@
+-- Lists page by keyset: each sort is (column, id), so an index in that
+-- order serves both the page and the cursor condition.
+
+-- Payments, newest first or by amount; a tenant's history by date
+DROP INDEX IF EXISTS idx_payments_created_at;
+CREATE INDEX idx_payments_created_id ON payments(created_at DESC, id DESC);
+CREATE INDEX idx_payments_amount_id ON payments(amount, id);
+CREATE INDEX idx_payments_tenant_created_id ON payments(tenant_id, created_at DESC, id DESC);
+
+-- Tenants, of a unit or all, newest first or by name
+CREATE INDEX idx_tenants_unit ON tenants(unit_id);
+CREATE INDEX idx_tenants_created_id ON tenants(created_at DESC, id DESC);
+CREATE INDEX idx_tenants_name_id ON tenants(tenant_name, id);
+
+-- Properties, of a landlord, newest first or by title
+CREATE INDEX idx_properties_landlord ON properties(landlord_id);
+CREATE INDEX idx_properties_created_id ON properties(created_at DESC, id DESC);
+CREATE INDEX idx_properties_title_id ON properties(title, id);
+
+-- Units of a property by name
+CREATE INDEX idx_units_property_name_id ON units(property_id, unit_name, id);
+
+-- Users, newest first
+CREATE INDEX idx_users_created_id ON users(created_at DESC, id DESC);
@@ -0,0 +1,18 @@
+-- Search matches fragments anywhere in names, phone numbers and receipt
+-- codes (ILIKE '%...%') and misspelt words (word similarity, <%); trigram
+-- indexes serve both.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_tenants_name_trgm ON tenants USING GIN (tenant_name gin_trgm_ops);
+
+-- Phone numbers are searched by their digits, whatever way they were typed
+CREATE INDEX idx_tenants_payment_no1_trgm ON tenants USING GIN ((regexp_replace(payment_no1, '\D', '', 'g')) gin_trgm_ops);
+CREATE INDEX idx_tenants_payment_no2_trgm ON tenants USING GIN ((regexp_replace(payment_no2, '\D', '', 'g')) gin_trgm_ops);
+
+CREATE INDEX idx_units_name_trgm ON units USING GIN (unit_name gin_trgm_ops);
+
+CREATE INDEX idx_properties_title_trgm ON properties USING GIN (title gin_trgm_ops);
+CREATE INDEX idx_properties_location_trgm ON properties USING GIN (location gin_trgm_ops);
+
+CREATE INDEX idx_payments_receipt_trgm ON payments USING GIN (receipt gin_trgm_ops);
+CREATE INDEX idx_payments_receipt_no_trgm ON payments USING GIN (receipt_no gin_trgm_ops);
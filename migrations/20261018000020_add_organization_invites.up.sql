@@ -0,0 +1,176 @@
+-- Organization membership now needs the user's consent: admins invite an
+-- email address and the account holder accepts. Until then the user is not a
+-- member and their properties cannot be managed through the organization.
+CREATE TABLE organization_invites (
+    id              BIGSERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL,
+    email           VARCHAR(255) NOT NULL,
+    role            VARCHAR(50) NOT NULL,
+    invited_by      INTEGER REFERENCES users (id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_organization_invites_organization
+        FOREIGN KEY (organization_id)
+        REFERENCES organizations (id)
+        ON DELETE CASCADE,
+
+    CONSTRAINT uq_organization_invites_email
+        UNIQUE (organization_id, email)
+);
+
+CREATE INDEX idx_organization_invites_email ON organization_invites(email);
+
+-- Owners were added without their consent; turn those memberships into
+-- invitations they have to accept
+INSERT INTO organization_invites (organization_id, email, role, invited_by, created_at)
+SELECT m.organization_id, lower(u.email), m.role, o.created_by, m.created_at
+FROM organization_members m
+JOIN users u ON u.id = m.user_id
+JOIN organizations o ON o.id = m.organization_id
+WHERE m.role = 'owner'
+ON CONFLICT (organization_id, email) DO NOTHING;
+
+DELETE FROM organization_members WHERE role = 'owner';
+
+-- Grants of each account role and organization role, mirrored from the
+-- permissions package (the server re-syncs them on startup) so member
+-- permissions are worked out when they are checked instead of copied into
+-- organization_members, where they went stale when an account role changed
+CREATE TABLE role_permissions (
+    role        VARCHAR(50) NOT NULL,
+    permission  VARCHAR(100) NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE org_role_permissions (
+    org_role    VARCHAR(50) NOT NULL,
+    permission  VARCHAR(100) NOT NULL,
+    PRIMARY KEY (org_role, permission)
+);
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('accountant', 'properties:read'),
+    ('accountant', 'units:read'),
+    ('accountant', 'tenants:read'),
+    ('accountant', 'payments:read'),
+    ('accountant', 'payments:record_cash'),
+    ('accountant', 'payments:assign'),
+    ('accountant', 'maintenance:read'),
+    ('accountant', 'expenses:read'),
+    ('accountant', 'expenses:write'),
+    ('admin', 'users:manage'),
+    ('agent', 'properties:read'),
+    ('agent', 'units:read'),
+    ('agent', 'tenants:read'),
+    ('agent', 'tenants:write'),
+    ('agent', 'payments:read'),
+    ('agent', 'messages:read'),
+    ('agent', 'messages:write'),
+    ('agent', 'maintenance:read'),
+    ('agent', 'maintenance:write'),
+    ('caretaker', 'properties:read'),
+    ('caretaker', 'units:read'),
+    ('caretaker', 'units:write'),
+    ('caretaker', 'tenants:read'),
+    ('caretaker', 'tenants:write'),
+    ('caretaker', 'payments:read'),
+    ('caretaker', 'payments:record_cash'),
+    ('caretaker', 'messages:read'),
+    ('caretaker', 'messages:write'),
+    ('caretaker', 'maintenance:read'),
+    ('caretaker', 'maintenance:write'),
+    ('caretaker', 'expenses:read'),
+    ('caretaker', 'expenses:write'),
+    ('landlord', 'properties:read'),
+    ('landlord', 'properties:write'),
+    ('landlord', 'units:read'),
+    ('landlord', 'units:write'),
+    ('landlord', 'tenants:read'),
+    ('landlord', 'tenants:write'),
+    ('landlord', 'payments:read'),
+    ('landlord', 'payments:record_cash'),
+    ('landlord', 'payments:assign'),
+    ('landlord', 'payments:configure'),
+    ('landlord', 'delegations:manage'),
+    ('landlord', 'organizations:manage'),
+    ('landlord', 'audit:read'),
+    ('landlord', 'webhooks:manage'),
+    ('landlord', 'notifications:manage'),
+    ('landlord', 'messages:read'),
+    ('landlord', 'messages:write'),
+    ('landlord', 'maintenance:read'),
+    ('landlord', 'maintenance:write'),
+    ('landlord', 'expenses:read'),
+    ('landlord', 'expenses:write'),
+    ('landlord', 'tax:manage'),
+    ('tenant', 'messages:read'),
+    ('tenant', 'messages:write'),
+    ('tenant', 'maintenance:read'),
+    ('tenant', 'maintenance:write');
+
+INSERT INTO org_role_permissions (org_role, permission) VALUES
+    ('admin', 'properties:read'),
+    ('admin', 'properties:write'),
+    ('admin', 'units:read'),
+    ('admin', 'units:write'),
+    ('admin', 'tenants:read'),
+    ('admin', 'tenants:write'),
+    ('admin', 'payments:read'),
+    ('admin', 'payments:record_cash'),
+    ('admin', 'payments:assign'),
+    ('admin', 'messages:read'),
+    ('admin', 'messages:write'),
+    ('admin', 'maintenance:read'),
+    ('admin', 'maintenance:write'),
+    ('admin', 'expenses:read'),
+    ('admin', 'expenses:write'),
+    ('manager', 'properties:read'),
+    ('manager', 'properties:write'),
+    ('manager', 'units:read'),
+    ('manager', 'units:write'),
+    ('manager', 'tenants:read'),
+    ('manager', 'tenants:write'),
+    ('manager', 'payments:read'),
+    ('manager', 'payments:record_cash'),
+    ('manager', 'payments:assign'),
+    ('manager', 'messages:read'),
+    ('manager', 'messages:write'),
+    ('manager', 'maintenance:read'),
+    ('manager', 'maintenance:write'),
+    ('manager', 'expenses:read'),
+    ('manager', 'expenses:write'),
+    ('staff', 'properties:read'),
+    ('staff', 'units:read'),
+    ('staff', 'units:write'),
+    ('staff', 'tenants:read'),
+    ('staff', 'tenants:write'),
+    ('staff', 'payments:read'),
+    ('staff', 'payments:record_cash'),
+    ('staff', 'messages:read'),
+    ('staff', 'messages:write'),
+    ('staff', 'maintenance:read'),
+    ('staff', 'maintenance:write'),
+    ('staff', 'expenses:read'),
+    ('staff', 'expenses:write');
+
+-- Org members act on the organization's properties with their org role's
+-- permissions capped by their account role, and only on properties whose
+-- owner is a member too
+CREATE OR REPLACE FUNCTION accessible_property_ids(p_user INTEGER, p_perm TEXT)
+RETURNS SETOF INTEGER AS $$
+    SELECT id FROM properties WHERE landlord_id = p_user
+    UNION
+    SELECT property_id FROM property_delegations
+    WHERE grantee_id = p_user AND p_perm = ANY(permissions)
+    UNION
+    SELECT p.id FROM properties p
+    JOIN organization_members m ON m.organization_id = p.organization_id
+    JOIN users u ON u.id = m.user_id
+    JOIN org_role_permissions orp ON orp.org_role = m.role AND orp.permission = p_perm
+    JOIN role_permissions rp ON rp.role = u.role AND rp.permission = p_perm
+    WHERE m.user_id = p_user
+      AND EXISTS (SELECT 1 FROM organization_members o
+                  WHERE o.organization_id = p.organization_id AND o.user_id = p.landlord_id)
+$$ LANGUAGE sql STABLE;
+
+ALTER TABLE organization_members DROP COLUMN permissions;
@@ -0,0 +1,11 @@
+-- In-app notifications for a tenant were read through the tenant record's
+-- current user_id, so re-linking the record to another account exposed the
+-- previous occupant's history. The recipient account is now stored when a
+-- notification is created. Existing ones go to the account linked today,
+-- which is who can already read them.
+UPDATE notifications n
+SET user_id = t.user_id
+FROM tenants t
+WHERE n.channel = 'in_app' AND n.user_id IS NULL AND n.tenant_id = t.id AND t.user_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_notifications_inbox_tenant;
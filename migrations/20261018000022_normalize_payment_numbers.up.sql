@@ -0,0 +1,18 @@
+-- Payment numbers are stored the way M-Pesa reports payers (2547XXXXXXXX)
+-- so callbacks and statements match them by plain equality. Numbers that
+-- are not Kenyan mobile numbers are kept as typed.
+UPDATE tenants
+SET payment_no1 = '254' || right(regexp_replace(payment_no1, '\D', '', 'g'), 9)
+WHERE payment_no1 NOT LIKE '%*%'
+  AND regexp_replace(payment_no1, '\D', '', 'g') ~ CASE WHEN btrim(payment_no1) LIKE '+%'
+    THEN '^254[17][0-9]{8}$' ELSE '^(254|0)?[17][0-9]{8}$' END;
+
+UPDATE tenants
+SET payment_no2 = '254' || right(regexp_replace(payment_no2, '\D', '', 'g'), 9)
+WHERE payment_no2 NOT LIKE '%*%'
+  AND regexp_replace(payment_no2, '\D', '', 'g') ~ CASE WHEN btrim(payment_no2) LIKE '+%'
+    THEN '^254[17][0-9]{8}$' ELSE '^(254|0)?[17][0-9]{8}$' END;
+
+-- Payer matching looks numbers up within a landlord
+CREATE INDEX idx_tenants_landlord_payment_no1 ON tenants (landlord_id, payment_no1);
+CREATE INDEX idx_tenants_landlord_payment_no2 ON tenants (landlord_id, payment_no2);
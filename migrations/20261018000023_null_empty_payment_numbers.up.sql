@@ -0,0 +1,4 @@
+-- A missing payment number is NULL, never '', so a payer without a number
+-- cannot equal it
+UPDATE tenants SET payment_no1 = NULL WHERE btrim(payment_no1) = '';
+UPDATE tenants SET payment_no2 = NULL WHERE btrim(payment_no2) = '';